@@ -92,3 +92,61 @@ func Convert_v1alpha4_NutanixClusterStatus_To_v1beta1_NutanixClusterStatus(in *N
 	// Ref: https://github.com/kubernetes/kubernetes/issues/98380
 	return Convert_v1alpha4_NutanixClusterStatus_To_v1beta1_NutanixClusterStatus(in, out, s)
 }
+
+// Convert_v1beta1_NutanixClusterStatus_To_v1alpha4_NutanixClusterStatus converts NutanixClusterStatus in NutanixClusterResource from v1beta1 to v1alpha4 version.
+//
+//nolint:all
+func Convert_v1beta1_NutanixClusterStatus_To_v1alpha4_NutanixClusterStatus(in *infrav1beta1.NutanixClusterStatus, out *NutanixClusterStatus, s apiconversion.Scope) error {
+	// ObservedGeneration, PrismCentralVersion and Phase do not exist in v1alpha4
+	return autoConvert_v1beta1_NutanixClusterStatus_To_v1alpha4_NutanixClusterStatus(in, out, s)
+}
+
+// The v1alpha4 NutanixFailureDomain corresponds to the v1beta1 NutanixFailureDomainConfig. The v1beta1 NutanixFailureDomain
+// kind has no peer in v1alpha4, so its conversion is disabled and the failure domains are converted manually.
+
+// Convert_v1beta1_NutanixFailureDomainConfig_To_v1alpha4_NutanixFailureDomain converts NutanixFailureDomainConfig in NutanixClusterResource from v1beta1 to v1alpha4 version.
+func Convert_v1beta1_NutanixFailureDomainConfig_To_v1alpha4_NutanixFailureDomain(in *infrav1beta1.NutanixFailureDomainConfig, out *NutanixFailureDomain, s apiconversion.Scope) error {
+	// Weight does not exist in v1alpha4
+	out.Name = in.Name
+	if err := Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(&in.Cluster, &out.Cluster, s); err != nil {
+		return err
+	}
+	if in.Subnets != nil {
+		out.Subnets = make([]NutanixResourceIdentifier, len(in.Subnets))
+		for i := range in.Subnets {
+			if err := Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(&in.Subnets[i], &out.Subnets[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Subnets = nil
+	}
+	out.ControlPlane = in.ControlPlane
+	return nil
+}
+
+// Convert_v1alpha4_NutanixFailureDomain_To_v1beta1_NutanixFailureDomainConfig converts NutanixFailureDomain in NutanixClusterResource from v1alpha4 to v1beta1 version.
+func Convert_v1alpha4_NutanixFailureDomain_To_v1beta1_NutanixFailureDomainConfig(in *NutanixFailureDomain, out *infrav1beta1.NutanixFailureDomainConfig, s apiconversion.Scope) error {
+	out.Name = in.Name
+	if err := Convert_v1alpha4_NutanixResourceIdentifier_To_v1beta1_NutanixResourceIdentifier(&in.Cluster, &out.Cluster, s); err != nil {
+		return err
+	}
+	if in.Subnets != nil {
+		out.Subnets = make([]infrav1beta1.NutanixResourceIdentifier, len(in.Subnets))
+		for i := range in.Subnets {
+			if err := Convert_v1alpha4_NutanixResourceIdentifier_To_v1beta1_NutanixResourceIdentifier(&in.Subnets[i], &out.Subnets[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Subnets = nil
+	}
+	out.ControlPlane = in.ControlPlane
+	return nil
+}
+
+// Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier converts NutanixResourceIdentifier from v1beta1 to v1alpha4 version.
+func Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(in *infrav1beta1.NutanixResourceIdentifier, out *NutanixResourceIdentifier, s apiconversion.Scope) error {
+	// Category does not exist in v1alpha4
+	return autoConvert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(in, out, s)
+}
@@ -99,6 +99,7 @@ type NutanixCluster struct {
 }
 
 // NutanixFailureDomain configures failure domain information for Nutanix.
+// +k8s:conversion-gen=false
 type NutanixFailureDomain struct {
 	// name defines the unique name of a failure domain.
 	// Name is required and must be at most 64 characters in length.
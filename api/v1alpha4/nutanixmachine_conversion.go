@@ -72,3 +72,11 @@ func Convert_v1alpha4_NutanixMachineStatus_To_v1beta1_NutanixMachineStatus(in *N
 	// Ref: https://github.com/kubernetes/kubernetes/issues/98380
 	return Convert_v1alpha4_NutanixMachineStatus_To_v1beta1_NutanixMachineStatus(in, out, s)
 }
+
+// Convert_v1beta1_NutanixMachineStatus_To_v1alpha4_NutanixMachineStatus converts NutanixMachineStatus in NutanixMachineResource from v1beta1 to v1alpha4 version.
+//
+//nolint:all
+func Convert_v1beta1_NutanixMachineStatus_To_v1alpha4_NutanixMachineStatus(in *infrav1beta1.NutanixMachineStatus, out *NutanixMachineStatus, s apiconversion.Scope) error {
+	// FailureDomain, ObservedGeneration and PowerState do not exist in v1alpha4
+	return autoConvert_v1beta1_NutanixMachineStatus_To_v1alpha4_NutanixMachineStatus(in, out, s)
+}
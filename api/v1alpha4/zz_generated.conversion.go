@@ -70,21 +70,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NutanixClusterStatus)(nil), (*NutanixClusterStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NutanixClusterStatus_To_v1alpha4_NutanixClusterStatus(a.(*v1beta1.NutanixClusterStatus), b.(*NutanixClusterStatus), scope)
-	}); err != nil {
-		return err
-	}
-	if err := s.AddGeneratedConversionFunc((*NutanixFailureDomain)(nil), (*v1beta1.NutanixFailureDomain)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1alpha4_NutanixFailureDomain_To_v1beta1_NutanixFailureDomain(a.(*NutanixFailureDomain), b.(*v1beta1.NutanixFailureDomain), scope)
-	}); err != nil {
-		return err
-	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NutanixFailureDomain)(nil), (*NutanixFailureDomain)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NutanixFailureDomain_To_v1alpha4_NutanixFailureDomain(a.(*v1beta1.NutanixFailureDomain), b.(*NutanixFailureDomain), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NutanixMachine)(nil), (*v1beta1.NutanixMachine)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha4_NutanixMachine_To_v1beta1_NutanixMachine(a.(*NutanixMachine), b.(*v1beta1.NutanixMachine), scope)
 	}); err != nil {
@@ -105,11 +90,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NutanixMachineStatus)(nil), (*NutanixMachineStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NutanixMachineStatus_To_v1alpha4_NutanixMachineStatus(a.(*v1beta1.NutanixMachineStatus), b.(*NutanixMachineStatus), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NutanixMachineTemplate)(nil), (*v1beta1.NutanixMachineTemplate)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha4_NutanixMachineTemplate_To_v1beta1_NutanixMachineTemplate(a.(*NutanixMachineTemplate), b.(*v1beta1.NutanixMachineTemplate), scope)
 	}); err != nil {
@@ -145,11 +125,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1beta1.NutanixResourceIdentifier)(nil), (*NutanixResourceIdentifier)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(a.(*v1beta1.NutanixResourceIdentifier), b.(*NutanixResourceIdentifier), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddConversionFunc((*apiv1alpha4.APIEndpoint)(nil), (*apiv1beta1.APIEndpoint)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha4_APIEndpoint_To_v1beta1_APIEndpoint(a.(*apiv1alpha4.APIEndpoint), b.(*apiv1beta1.APIEndpoint), scope)
 	}); err != nil {
@@ -165,6 +140,11 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*NutanixFailureDomain)(nil), (*v1beta1.NutanixFailureDomainConfig)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha4_NutanixFailureDomain_To_v1beta1_NutanixFailureDomainConfig(a.(*NutanixFailureDomain), b.(*v1beta1.NutanixFailureDomainConfig), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*NutanixMachineSpec)(nil), (*v1beta1.NutanixMachineSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha4_NutanixMachineSpec_To_v1beta1_NutanixMachineSpec(a.(*NutanixMachineSpec), b.(*v1beta1.NutanixMachineSpec), scope)
 	}); err != nil {
@@ -195,16 +175,36 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1beta1.NutanixClusterStatus)(nil), (*NutanixClusterStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NutanixClusterStatus_To_v1alpha4_NutanixClusterStatus(a.(*v1beta1.NutanixClusterStatus), b.(*NutanixClusterStatus), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddConversionFunc((*v1beta1.NutanixFailureDomainConfig)(nil), (*NutanixFailureDomain)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NutanixFailureDomainConfig_To_v1alpha4_NutanixFailureDomain(a.(*v1beta1.NutanixFailureDomainConfig), b.(*NutanixFailureDomain), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1beta1.NutanixMachineSpec)(nil), (*NutanixMachineSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1beta1_NutanixMachineSpec_To_v1alpha4_NutanixMachineSpec(a.(*v1beta1.NutanixMachineSpec), b.(*NutanixMachineSpec), scope)
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1beta1.NutanixMachineStatus)(nil), (*NutanixMachineStatus)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NutanixMachineStatus_To_v1alpha4_NutanixMachineStatus(a.(*v1beta1.NutanixMachineStatus), b.(*NutanixMachineStatus), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1beta1.NutanixMachineTemplateResource)(nil), (*NutanixMachineTemplateResource)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1beta1_NutanixMachineTemplateResource_To_v1alpha4_NutanixMachineTemplateResource(a.(*v1beta1.NutanixMachineTemplateResource), b.(*NutanixMachineTemplateResource), scope)
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1beta1.NutanixResourceIdentifier)(nil), (*NutanixResourceIdentifier)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(a.(*v1beta1.NutanixResourceIdentifier), b.(*NutanixResourceIdentifier), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*apiv1beta1.ObjectMeta)(nil), (*apiv1alpha4.ObjectMeta)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1beta1_ObjectMeta_To_v1alpha4_ObjectMeta(a.(*apiv1beta1.ObjectMeta), b.(*apiv1alpha4.ObjectMeta), scope)
 	}); err != nil {
@@ -314,7 +314,17 @@ func autoConvert_v1alpha4_NutanixClusterSpec_To_v1beta1_NutanixClusterSpec(in *N
 		return err
 	}
 	out.PrismCentral = (*credentials.NutanixPrismEndpoint)(unsafe.Pointer(in.PrismCentral))
-	out.FailureDomains = *(*[]v1beta1.NutanixFailureDomain)(unsafe.Pointer(&in.FailureDomains))
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = make([]v1beta1.NutanixFailureDomainConfig, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha4_NutanixFailureDomain_To_v1beta1_NutanixFailureDomainConfig(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.FailureDomains = nil
+	}
 	return nil
 }
 
@@ -323,7 +333,21 @@ func autoConvert_v1beta1_NutanixClusterSpec_To_v1alpha4_NutanixClusterSpec(in *v
 		return err
 	}
 	out.PrismCentral = (*credentials.NutanixPrismEndpoint)(unsafe.Pointer(in.PrismCentral))
-	out.FailureDomains = *(*[]NutanixFailureDomain)(unsafe.Pointer(&in.FailureDomains))
+	// WARNING: in.Proxy requires manual conversion: does not exist in peer-type
+	// WARNING: in.ClientCertificate requires manual conversion: does not exist in peer-type
+	// WARNING: in.InsecureSkipVerify requires manual conversion: does not exist in peer-type
+	if in.FailureDomains != nil {
+		in, out := &in.FailureDomains, &out.FailureDomains
+		*out = make([]NutanixFailureDomain, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NutanixFailureDomainConfig_To_v1alpha4_NutanixFailureDomain(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.FailureDomains = nil
+	}
+	// WARNING: in.MachineDefaults requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -340,46 +364,14 @@ func autoConvert_v1beta1_NutanixClusterStatus_To_v1alpha4_NutanixClusterStatus(i
 	out.Ready = in.Ready
 	out.FailureDomains = *(*apiv1alpha4.FailureDomains)(unsafe.Pointer(&in.FailureDomains))
 	out.Conditions = *(*apiv1alpha4.Conditions)(unsafe.Pointer(&in.Conditions))
+	// WARNING: in.ObservedGeneration requires manual conversion: does not exist in peer-type
+	// WARNING: in.PrismCentralVersion requires manual conversion: does not exist in peer-type
+	// WARNING: in.Phase requires manual conversion: does not exist in peer-type
 	out.FailureReason = (*errors.ClusterStatusError)(unsafe.Pointer(in.FailureReason))
 	out.FailureMessage = (*string)(unsafe.Pointer(in.FailureMessage))
 	return nil
 }
 
-// Convert_v1beta1_NutanixClusterStatus_To_v1alpha4_NutanixClusterStatus is an autogenerated conversion function.
-func Convert_v1beta1_NutanixClusterStatus_To_v1alpha4_NutanixClusterStatus(in *v1beta1.NutanixClusterStatus, out *NutanixClusterStatus, s conversion.Scope) error {
-	return autoConvert_v1beta1_NutanixClusterStatus_To_v1alpha4_NutanixClusterStatus(in, out, s)
-}
-
-func autoConvert_v1alpha4_NutanixFailureDomain_To_v1beta1_NutanixFailureDomain(in *NutanixFailureDomain, out *v1beta1.NutanixFailureDomain, s conversion.Scope) error {
-	out.Name = in.Name
-	if err := Convert_v1alpha4_NutanixResourceIdentifier_To_v1beta1_NutanixResourceIdentifier(&in.Cluster, &out.Cluster, s); err != nil {
-		return err
-	}
-	out.Subnets = *(*[]v1beta1.NutanixResourceIdentifier)(unsafe.Pointer(&in.Subnets))
-	out.ControlPlane = in.ControlPlane
-	return nil
-}
-
-// Convert_v1alpha4_NutanixFailureDomain_To_v1beta1_NutanixFailureDomain is an autogenerated conversion function.
-func Convert_v1alpha4_NutanixFailureDomain_To_v1beta1_NutanixFailureDomain(in *NutanixFailureDomain, out *v1beta1.NutanixFailureDomain, s conversion.Scope) error {
-	return autoConvert_v1alpha4_NutanixFailureDomain_To_v1beta1_NutanixFailureDomain(in, out, s)
-}
-
-func autoConvert_v1beta1_NutanixFailureDomain_To_v1alpha4_NutanixFailureDomain(in *v1beta1.NutanixFailureDomain, out *NutanixFailureDomain, s conversion.Scope) error {
-	out.Name = in.Name
-	if err := Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(&in.Cluster, &out.Cluster, s); err != nil {
-		return err
-	}
-	out.Subnets = *(*[]NutanixResourceIdentifier)(unsafe.Pointer(&in.Subnets))
-	out.ControlPlane = in.ControlPlane
-	return nil
-}
-
-// Convert_v1beta1_NutanixFailureDomain_To_v1alpha4_NutanixFailureDomain is an autogenerated conversion function.
-func Convert_v1beta1_NutanixFailureDomain_To_v1alpha4_NutanixFailureDomain(in *v1beta1.NutanixFailureDomain, out *NutanixFailureDomain, s conversion.Scope) error {
-	return autoConvert_v1beta1_NutanixFailureDomain_To_v1alpha4_NutanixFailureDomain(in, out, s)
-}
-
 func autoConvert_v1alpha4_NutanixMachine_To_v1beta1_NutanixMachine(in *NutanixMachine, out *v1beta1.NutanixMachine, s conversion.Scope) error {
 	out.ObjectMeta = in.ObjectMeta
 	if err := Convert_v1alpha4_NutanixMachineSpec_To_v1beta1_NutanixMachineSpec(&in.Spec, &out.Spec, s); err != nil {
@@ -465,9 +457,27 @@ func autoConvert_v1alpha4_NutanixMachineSpec_To_v1beta1_NutanixMachineSpec(in *N
 	if err := Convert_v1alpha4_NutanixResourceIdentifier_To_v1beta1_NutanixResourceIdentifier(&in.Cluster, &out.Cluster, s); err != nil {
 		return err
 	}
-	out.Subnets = *(*[]v1beta1.NutanixResourceIdentifier)(unsafe.Pointer(&in.Subnets))
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]v1beta1.NutanixResourceIdentifier, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha4_NutanixResourceIdentifier_To_v1beta1_NutanixResourceIdentifier(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Subnets = nil
+	}
 	out.AdditionalCategories = *(*[]v1beta1.NutanixCategoryIdentifier)(unsafe.Pointer(&in.AdditionalCategories))
-	out.Project = (*v1beta1.NutanixResourceIdentifier)(unsafe.Pointer(in.Project))
+	if in.Project != nil {
+		in, out := &in.Project, &out.Project
+		*out = new(v1beta1.NutanixResourceIdentifier)
+		if err := Convert_v1alpha4_NutanixResourceIdentifier_To_v1beta1_NutanixResourceIdentifier(*in, *out, s); err != nil {
+			return err
+		}
+	} else {
+		out.Project = nil
+	}
 	out.BootType = v1beta1.NutanixBootType(in.BootType)
 	out.SystemDiskSize = in.SystemDiskSize
 	out.BootstrapRef = (*v1.ObjectReference)(unsafe.Pointer(in.BootstrapRef))
@@ -482,16 +492,54 @@ func autoConvert_v1beta1_NutanixMachineSpec_To_v1alpha4_NutanixMachineSpec(in *v
 	if err := Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(&in.Image, &out.Image, s); err != nil {
 		return err
 	}
+	// WARNING: in.ImageClusterScoped requires manual conversion: does not exist in peer-type
 	if err := Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(&in.Cluster, &out.Cluster, s); err != nil {
 		return err
 	}
-	out.Subnets = *(*[]NutanixResourceIdentifier)(unsafe.Pointer(&in.Subnets))
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]NutanixResourceIdentifier, len(*in))
+		for i := range *in {
+			if err := Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Subnets = nil
+	}
+	// WARNING: in.NICs requires manual conversion: does not exist in peer-type
 	out.AdditionalCategories = *(*[]NutanixCategoryIdentifier)(unsafe.Pointer(&in.AdditionalCategories))
-	out.Project = (*NutanixResourceIdentifier)(unsafe.Pointer(in.Project))
+	// WARNING: in.PlacementPolicyCategories requires manual conversion: does not exist in peer-type
+	if in.Project != nil {
+		in, out := &in.Project, &out.Project
+		*out = new(NutanixResourceIdentifier)
+		if err := Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(*in, *out, s); err != nil {
+			return err
+		}
+	} else {
+		out.Project = nil
+	}
+	// WARNING: in.Owner requires manual conversion: does not exist in peer-type
 	out.BootType = NutanixBootType(in.BootType)
+	// WARNING: in.SecureBoot requires manual conversion: does not exist in peer-type
 	out.SystemDiskSize = in.SystemDiskSize
+	// WARNING: in.SystemDiskStorageContainer requires manual conversion: does not exist in peer-type
+	// WARNING: in.SystemDiskBus requires manual conversion: does not exist in peer-type
 	out.BootstrapRef = (*v1.ObjectReference)(unsafe.Pointer(in.BootstrapRef))
+	// WARNING: in.BootstrapFormat requires manual conversion: does not exist in peer-type
+	// WARNING: in.BootstrapISO requires manual conversion: does not exist in peer-type
+	// WARNING: in.EjectBootstrapISO requires manual conversion: does not exist in peer-type
+	// WARNING: in.NameServers requires manual conversion: does not exist in peer-type
+	// WARNING: in.SearchDomains requires manual conversion: does not exist in peer-type
 	// WARNING: in.GPUs requires manual conversion: does not exist in peer-type
+	// WARNING: in.SerialPorts requires manual conversion: does not exist in peer-type
+	// WARNING: in.VolumeGroups requires manual conversion: does not exist in peer-type
+	// WARNING: in.CheckGuestTools requires manual conversion: does not exist in peer-type
+	// WARNING: in.PowerOnAfterCreate requires manual conversion: does not exist in peer-type
+	// WARNING: in.VMNameTemplate requires manual conversion: does not exist in peer-type
+	// WARNING: in.HostnameTemplate requires manual conversion: does not exist in peer-type
+	// WARNING: in.ExistingVMUUID requires manual conversion: does not exist in peer-type
+	// WARNING: in.ExtraVMConfig requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -510,18 +558,17 @@ func autoConvert_v1beta1_NutanixMachineStatus_To_v1alpha4_NutanixMachineStatus(i
 	out.Ready = in.Ready
 	out.Addresses = *(*[]apiv1alpha4.MachineAddress)(unsafe.Pointer(&in.Addresses))
 	out.VmUUID = in.VmUUID
+	// WARNING: in.PowerState requires manual conversion: does not exist in peer-type
+	// WARNING: in.FailureDomain requires manual conversion: does not exist in peer-type
+	// WARNING: in.ClusterUUID requires manual conversion: does not exist in peer-type
 	out.NodeRef = (*v1.ObjectReference)(unsafe.Pointer(in.NodeRef))
 	out.Conditions = *(*apiv1alpha4.Conditions)(unsafe.Pointer(&in.Conditions))
+	// WARNING: in.ObservedGeneration requires manual conversion: does not exist in peer-type
 	out.FailureReason = (*errors.MachineStatusError)(unsafe.Pointer(in.FailureReason))
 	out.FailureMessage = (*string)(unsafe.Pointer(in.FailureMessage))
 	return nil
 }
 
-// Convert_v1beta1_NutanixMachineStatus_To_v1alpha4_NutanixMachineStatus is an autogenerated conversion function.
-func Convert_v1beta1_NutanixMachineStatus_To_v1alpha4_NutanixMachineStatus(in *v1beta1.NutanixMachineStatus, out *NutanixMachineStatus, s conversion.Scope) error {
-	return autoConvert_v1beta1_NutanixMachineStatus_To_v1alpha4_NutanixMachineStatus(in, out, s)
-}
-
 func autoConvert_v1alpha4_NutanixMachineTemplate_To_v1beta1_NutanixMachineTemplate(in *NutanixMachineTemplate, out *v1beta1.NutanixMachineTemplate, s conversion.Scope) error {
 	out.ObjectMeta = in.ObjectMeta
 	if err := Convert_v1alpha4_NutanixMachineTemplateSpec_To_v1beta1_NutanixMachineTemplateSpec(&in.Spec, &out.Spec, s); err != nil {
@@ -650,10 +697,6 @@ func autoConvert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceId
 	out.Type = NutanixIdentifierType(in.Type)
 	out.UUID = (*string)(unsafe.Pointer(in.UUID))
 	out.Name = (*string)(unsafe.Pointer(in.Name))
+	// WARNING: in.Category requires manual conversion: does not exist in peer-type
 	return nil
 }
-
-// Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier is an autogenerated conversion function.
-func Convert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(in *v1beta1.NutanixResourceIdentifier, out *NutanixResourceIdentifier, s conversion.Scope) error {
-	return autoConvert_v1beta1_NutanixResourceIdentifier_To_v1alpha4_NutanixResourceIdentifier(in, out, s)
-}
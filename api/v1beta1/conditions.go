@@ -31,6 +31,13 @@ const (
 
 	// FailureDomainsReconciliationFailed indicates the failure domain reconciliation failed
 	FailureDomainsReconciliationFailed = "FailureDomainsReconciliationFailed"
+
+	// FailureDomainsMigratedCondition indicates the status of the migration of the failure domains
+	// defined on the NutanixCluster spec to NutanixFailureDomain objects
+	FailureDomainsMigratedCondition capiv1.ConditionType = "FailureDomainsMigrated"
+
+	// FailureDomainsMigrationFailed indicates the NutanixFailureDomain objects could not be created, updated or deleted
+	FailureDomainsMigrationFailed = "FailureDomainsMigrationFailed"
 )
 
 const (
@@ -52,6 +59,14 @@ const (
 	VMProvisionedCondition capiv1.ConditionType = "VMProvisioned"
 
 	VMProvisionedTaskFailed = "FailedVMTask"
+	VMNameConflict          = "VMNameConflict"
+	VMAdoptionFailed        = "VMAdoptionFailed"
+	VMProvisioningFailed    = "VMProvisioningFailed"
+	// VMRecreating is set while the VM is deleted to be recreated on request of the recreate-vm annotation
+	VMRecreating = "VMRecreating"
+	// QuotaExceeded (Severity=Warning) documents that the VM could not be created since a Prism Central quota, e.g.
+	// of the project, was exceeded. The creation is retried after a long interval.
+	QuotaExceeded = "QuotaExceeded"
 
 	// VMAddressesAssignedCondition shows the status of the process of assigning the VM addresses
 	VMAddressesAssignedCondition capiv1.ConditionType = "VMAddressesAssigned"
@@ -61,6 +76,16 @@ const (
 	ClusterInfrastructureNotReady = "ClusterInfrastructureNotReady"
 	BootstrapDataNotReady         = "BootstrapDataNotReady"
 	ControlplaneNotInitialized    = "ControlplaneNotInitialized"
+	WaitingForIPAddresses         = "WaitingForIPAddresses"
+)
+
+const (
+	// IPAddressesAllocatedCondition shows whether the IPAM providers allocated the IP addresses claimed for the network
+	// interfaces of the VM
+	IPAddressesAllocatedCondition capiv1.ConditionType = "IPAddressesAllocated"
+
+	// WaitingForIPAddress (Severity=Info) documents a NutanixMachine waiting for an IPAddressClaim to be fulfilled
+	WaitingForIPAddress = "WaitingForIPAddress"
 )
 
 const (
@@ -70,9 +95,170 @@ const (
 	ProjectAssignationFailed = "ProjectAssignationFailed"
 )
 
+const (
+	// OwnerAssignedCondition shows the status of the process of setting the owner of the VMs
+	OwnerAssignedCondition capiv1.ConditionType = "OwnerAssigned"
+
+	// OwnerAssignationFailed (Severity=Error) documents that the owner of a VM could not be found in Prism Central
+	OwnerAssignationFailed = "OwnerAssignationFailed"
+)
+
 const (
 	// CredentialRefSecretOwnerSetCondition shows the status of setting the Owner
 	CredentialRefSecretOwnerSetCondition capiv1.ConditionType = "CredentialRefSecretOwnerSet"
 
 	CredentialRefSecretOwnerSetFailed = "CredentialRefSecretOwnerSetFailed"
+	// CredentialRefNamespaceNotAllowed (Severity=Error) documents a credential secret in a namespace that NutanixClusters
+	// of the namespace of the NutanixCluster are not allowed to reference.
+	CredentialRefNamespaceNotAllowed = "CredentialRefNamespaceNotAllowed"
+	// CredentialSecretInvalid (Severity=Error) documents a credential secret that holds no valid credentials. The message
+	// names the invalid field of the credentials data.
+	CredentialSecretInvalid = "CredentialSecretInvalid"
+)
+
+const (
+	// TrustBundleSecureCondition shows whether the certificates of the additional trust bundle meet the configured security requirements
+	TrustBundleSecureCondition capiv1.ConditionType = "TrustBundleSecure"
+
+	TrustBundleWeakCertificate = "TrustBundleWeakCertificate"
+)
+
+const (
+	// TrustBundleValidCondition shows whether the additional trust bundle can be parsed and its certificates are valid
+	TrustBundleValidCondition capiv1.ConditionType = "TrustBundleValid"
+
+	// TrustBundleInvalid (Severity=Error) documents a trust bundle that holds no PEM encoded certificates or a
+	// certificate that cannot be parsed. The trust bundle is not used.
+	TrustBundleInvalid = "TrustBundleInvalid"
+	// TrustBundleCertificateExpired (Severity=Warning) documents a trust bundle with an expired certificate. The trust
+	// bundle is still used.
+	TrustBundleCertificateExpired = "TrustBundleCertificateExpired"
+	// TrustBundleCertificateExpiring (Severity=Warning) documents a trust bundle with a certificate that expires soon
+	TrustBundleCertificateExpiring = "TrustBundleCertificateExpiring"
+)
+
+const (
+	// ControlPlaneSpreadCondition shows whether the control plane machines are placed in distinct failure domains
+	ControlPlaneSpreadCondition capiv1.ConditionType = "ControlPlaneSpread"
+
+	ControlPlaneNotSpread = "ControlPlaneNotSpread"
+)
+
+const (
+	// InsecureTLSCondition is set if the TLS certificate of Prism Central is not verified
+	InsecureTLSCondition capiv1.ConditionType = "InsecureTLS"
+
+	InsecureSkipVerifyEnabled = "InsecureSkipVerifyEnabled"
+)
+
+const (
+	// PrismCentralUnreachableCondition is set while requests to Prism Central are held back after consecutive connection failures
+	PrismCentralUnreachableCondition capiv1.ConditionType = "PrismCentralUnreachable"
+
+	PrismCentralCircuitOpen = "PrismCentralCircuitOpen"
+)
+
+const (
+	// PrismCentralHealthyCondition shows whether the Prism Central endpoint of the NutanixCluster responded to the
+	// last periodic health check
+	PrismCentralHealthyCondition capiv1.ConditionType = "PrismCentralHealthy"
+
+	PrismCentralHealthCheckFailed = "PrismCentralHealthCheckFailed"
+)
+
+const (
+	// BootstrapDataReadyCondition shows whether the bootstrap data secret of the Machine is present and populated. The
+	// VM is only created once the bootstrap data is ready.
+	BootstrapDataReadyCondition capiv1.ConditionType = "BootstrapDataReady"
+
+	WaitingForBootstrapData = "WaitingForBootstrapData"
+)
+
+const (
+	// GuestToolsReadyCondition shows whether Nutanix Guest Tools are enabled on the VM and reachable from Prism
+	// Central. The condition is only set if checkGuestTools is enabled on the NutanixMachine.
+	GuestToolsReadyCondition capiv1.ConditionType = "GuestToolsReady"
+
+	GuestToolsNotInstalled = "GuestToolsNotInstalled"
+	GuestToolsNotReady     = "GuestToolsNotReady"
+)
+
+const (
+	// FailureDomainMissingCondition is set if the failure domain the VM of the NutanixMachine was placed in was removed
+	// from the NutanixCluster. The VM keeps running in its placement; the Machine is placed in another failure domain
+	// once it is recreated, e.g. by remediation or a rollout.
+	FailureDomainMissingCondition capiv1.ConditionType = "FailureDomainMissing"
+
+	FailureDomainRemoved = "FailureDomainRemoved"
+)
+
+const (
+	// HostnameChangePendingCondition is set if the hostname of a VM that was already powered on differs from the
+	// rendered hostname of the NutanixMachine. The hostname of a booted VM is only changed by recreating the VM.
+	HostnameChangePendingCondition capiv1.ConditionType = "HostnameChangePending"
+
+	HostnameChangeRequiresRecreation = "HostnameChangeRequiresRecreation"
+)
+
+const (
+	// DeletionBlockedCondition is set while the VM of a deleted NutanixMachine is not deleted because it carries the
+	// protection category configured on the controller
+	DeletionBlockedCondition capiv1.ConditionType = "DeletionBlocked"
+
+	VMDeletionProtected = "VMDeletionProtected"
+)
+
+const (
+	// NodeDrainedCondition shows whether the Node of a deleted NutanixMachine is cordoned and drained. It is only set
+	// if the controller is configured to wait for Nodes to be drained before their VMs are deleted.
+	NodeDrainedCondition capiv1.ConditionType = "NodeDrained"
+
+	// NodeNotDrained (Severity=Info) documents a Node that is not cordoned or still runs pods that are evicted when
+	// the Node is drained. The VM is not deleted yet.
+	NodeNotDrained = "NodeNotDrained"
+	// NodeDrainTimedOut (Severity=Warning) documents a Node that was not drained within the node drain timeout of the
+	// controller. The VM is deleted anyway.
+	NodeDrainTimedOut = "NodeDrainTimedOut"
+)
+
+const (
+	// VolumeGroupsAttachedCondition shows the status of the process of attaching the volume groups to the VM
+	VolumeGroupsAttachedCondition capiv1.ConditionType = "VolumeGroupsAttached"
+
+	VolumeGroupsAttachFailed = "VolumeGroupsAttachFailed"
+)
+
+const (
+	// PlacementPolicyAppliedCondition shows whether the placement policy categories of the NutanixMachine were assigned
+	// to the VM in its create request
+	PlacementPolicyAppliedCondition capiv1.ConditionType = "PlacementPolicyApplied"
+
+	// PlacementPolicyCategoriesNotFound (Severity=Error) documents that placement policy categories do not exist in
+	// Prism Central
+	PlacementPolicyCategoriesNotFound = "PlacementPolicyCategoriesNotFound"
+)
+
+const (
+	// ImageReadyCondition shows whether the image of the VM is ready to be used
+	ImageReadyCondition capiv1.ConditionType = "ImageReady"
+
+	ImageReadyFailed  = "ImageReadyFailed"
+	ImageReadyTimeout = "ImageReadyTimeout"
+	// WaitingForImage (Severity=Info) documents that the creation of the VM waits for its image to become ready, e.g.
+	// for an upload to complete
+	WaitingForImage = "WaitingForImage"
+)
+
+const (
+	// VMPoweredOnCondition shows whether the VM of a NutanixMachine with powerOnAfterCreate disabled was powered on.
+	// The condition is only set if powerOnAfterCreate is disabled.
+	VMPoweredOnCondition capiv1.ConditionType = "VMPoweredOn"
+
+	WaitingForPowerOn = "WaitingForPowerOn"
+)
+
+const (
+	// PausedCondition is set while the reconciliation of the object is paused with the paused annotations or a
+	// paused CAPI Cluster. The condition is removed once the reconciliation is resumed.
+	PausedCondition capiv1.ConditionType = "Paused"
 )
@@ -25,6 +25,12 @@ type NutanixBootType string
 // NutanixGPUIdentifierType is an enumeration of different resource identifier types for GPU entities.
 type NutanixGPUIdentifierType string
 
+// NutanixBootstrapFormat is an enumeration of different bootstrap data formats.
+type NutanixBootstrapFormat string
+
+// NutanixDiskBus is an enumeration of different bus types of virtual disks.
+type NutanixDiskBus string
+
 const (
 	// NutanixIdentifierUUID is a resource identifier identifying the object by UUID.
 	NutanixIdentifierUUID NutanixIdentifierType = "uuid"
@@ -32,12 +38,31 @@ const (
 	// NutanixIdentifierName is a resource identifier identifying the object by Name.
 	NutanixIdentifierName NutanixIdentifierType = "name"
 
+	// NutanixIdentifierCategory is a resource identifier identifying the object by a category assigned to it. It is
+	// only supported for subnets.
+	NutanixIdentifierCategory NutanixIdentifierType = "category"
+
 	// NutanixBootTypeLegacy is a resource identifier identifying the legacy boot type for virtual machines.
 	NutanixBootTypeLegacy NutanixBootType = "legacy"
 
 	// NutanixBootTypeUEFI is a resource identifier identifying the UEFI boot type for virtual machines.
 	NutanixBootTypeUEFI NutanixBootType = "uefi"
 
+	// NutanixBootstrapFormatCloudInit is the bootstrap format for cloud-init bootstrap data.
+	NutanixBootstrapFormatCloudInit NutanixBootstrapFormat = "cloud-init"
+
+	// NutanixBootstrapFormatIgnition is the bootstrap format for Ignition bootstrap data.
+	NutanixBootstrapFormatIgnition NutanixBootstrapFormat = "ignition"
+
+	// NutanixDiskBusSCSI is the SCSI bus type of virtual disks.
+	NutanixDiskBusSCSI NutanixDiskBus = "SCSI"
+
+	// NutanixDiskBusPCI is the PCI bus type of virtual disks.
+	NutanixDiskBusPCI NutanixDiskBus = "PCI"
+
+	// NutanixDiskBusSATA is the SATA bus type of virtual disks.
+	NutanixDiskBusSATA NutanixDiskBus = "SATA"
+
 	// NutanixGPUIdentifierName is a resource identifier identifying a GPU by Name.
 	NutanixGPUIdentifierName NutanixGPUIdentifierType = "name"
 
@@ -50,6 +75,18 @@ const (
 	// DefaultCAPICategoryKeyForName is the default category key used for CAPI clusters for cluster names.
 	DefaultCAPICategoryKeyForName = "KubernetesClusterName"
 
+	// DefaultCAPICategoryKeyForMachineRole is the category key used for the role of the Machines, control-plane or worker.
+	DefaultCAPICategoryKeyForMachineRole = "KubernetesMachineRole"
+
+	// DefaultCAPICategoryKeyForMachineDeployment is the category key used for the MachineDeployment owning the Machines.
+	DefaultCAPICategoryKeyForMachineDeployment = "KubernetesMachineDeployment"
+
+	// MachineRoleControlPlane is the value of the machine role category of control plane Machines.
+	MachineRoleControlPlane = "control-plane"
+
+	// MachineRoleWorker is the value of the machine role category of worker Machines.
+	MachineRoleWorker = "worker"
+
 	// DefaultCAPICategoryDescription is the default category description used for CAPI clusters.
 	DefaultCAPICategoryDescription = "Managed by CAPX"
 
@@ -57,12 +94,18 @@ const (
 	ObsoleteDefaultCAPICategoryOwnedValue = "owned"
 )
 
+const (
+	// PausedAnnotation pauses the reconciliation of the NutanixCluster or NutanixMachine it is set on, like the
+	// cluster.x-k8s.io/paused annotation, without pausing the reconciliation of the other CAPI objects.
+	PausedAnnotation = "infrastructure.cluster.x-k8s.io/nutanix-paused"
+)
+
 // NutanixResourceIdentifier holds the identity of a Nutanix PC resource (cluster, image, subnet, etc.)
 // +union
 type NutanixResourceIdentifier struct {
 	// Type is the identifier type to use for this resource.
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Enum:=uuid;name
+	// +kubebuilder:validation:Enum:=uuid;name;category
 	Type NutanixIdentifierType `json:"type"`
 
 	// uuid is the UUID of the resource in the PC.
@@ -72,6 +115,11 @@ type NutanixResourceIdentifier struct {
 	// name is the resource name in the PC
 	// +optional
 	Name *string `json:"name,omitempty"`
+
+	// category is a category assigned to the resource in the PC. Exactly one resource must have the category assigned.
+	// Only subnets can be identified by category.
+	// +optional
+	Category *NutanixCategoryIdentifier `json:"category,omitempty"`
 }
 
 type NutanixCategoryIdentifier struct {
@@ -18,6 +18,7 @@ package v1beta1
 
 import (
 	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/errors"
@@ -32,6 +33,44 @@ const (
 	// API Server.
 	NutanixClusterFinalizer           = "nutanixcluster.infrastructure.cluster.x-k8s.io"
 	NutanixClusterCredentialFinalizer = "nutanixcluster/infrastructure.cluster.x-k8s.io"
+
+	// NutanixClusterCredentialHashAnnotation is set on the credential secret of a NutanixCluster and holds the
+	// hash of the secret content, used to detect rotated credentials.
+	NutanixClusterCredentialHashAnnotation = "nutanixcluster.infrastructure.cluster.x-k8s.io/credential-hash"
+
+	// NutanixClusterTrustBundleHashAnnotation is set on the additional trust bundle configmap of a NutanixCluster and
+	// holds the hash of the configmap content, used to detect rotated CA certificates.
+	NutanixClusterTrustBundleHashAnnotation = "nutanixcluster.infrastructure.cluster.x-k8s.io/trust-bundle-hash"
+
+	// AllowInsecureSkipVerifyAnnotation must be set on a NutanixCluster to allow insecureSkipVerify to be enabled.
+	AllowInsecureSkipVerifyAnnotation = "nutanixcluster.infrastructure.cluster.x-k8s.io/allow-insecure-skip-verify"
+
+	// SkipFailureDomainValidationAnnotation disables the validation of the failure domains of a NutanixCluster
+	// against Prism Central, e.g. when applying manifests while Prism Central is not reachable.
+	SkipFailureDomainValidationAnnotation = "nutanixcluster.infrastructure.cluster.x-k8s.io/skip-failure-domain-validation"
+
+	// ForceDeleteAnnotation allows a NutanixCluster to be deleted while NutanixMachines of the cluster still exist.
+	// The VMs of the remaining NutanixMachines may be left behind in Prism Central.
+	ForceDeleteAnnotation = "nutanixcluster.infrastructure.cluster.x-k8s.io/force-delete"
+)
+
+// NutanixClusterPhase is a summary of the reconcile state of a NutanixCluster.
+// +kubebuilder:validation:Enum:=Provisioning;Ready;Deleting;Failed
+type NutanixClusterPhase string
+
+const (
+	// NutanixClusterPhaseProvisioning is the phase of a NutanixCluster that is not ready yet.
+	NutanixClusterPhaseProvisioning NutanixClusterPhase = "Provisioning"
+
+	// NutanixClusterPhaseReady is the phase of a ready NutanixCluster without conditions in error.
+	NutanixClusterPhaseReady NutanixClusterPhase = "Ready"
+
+	// NutanixClusterPhaseDeleting is the phase of a NutanixCluster that is being deleted.
+	NutanixClusterPhaseDeleting NutanixClusterPhase = "Deleting"
+
+	// NutanixClusterPhaseFailed is the phase of a NutanixCluster with a terminal failure, or with a condition that is
+	// false with the Error severity.
+	NutanixClusterPhaseFailed NutanixClusterPhase = "Failed"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -54,13 +93,34 @@ type NutanixClusterSpec struct {
 	// +optional
 	PrismCentral *credentialTypes.NutanixPrismEndpoint `json:"prismCentral"`
 
+	// proxy configures the proxy used to access Prism Central. The settings apply to the Prism Central
+	// endpoint of this cluster only and take precedence over the proxy environment variables of the controller.
+	// +optional
+	Proxy *NutanixProxySpec `json:"proxy,omitempty"`
+
+	// clientCertificate configures the client certificate used for mutual TLS authentication against
+	// Prism Central, e.g. when Prism Central is fronted by a gateway requiring client certificates.
+	// +optional
+	ClientCertificate *NutanixClientCertificate `json:"clientCertificate,omitempty"`
+
+	// insecureSkipVerify disables the verification of the TLS certificate of Prism Central.
+	// This must only be used in lab environments and requires the annotation
+	// nutanixcluster.infrastructure.cluster.x-k8s.io/allow-insecure-skip-verify to be set on the NutanixCluster.
+	// +optional
+	InsecureSkipVerify *bool `json:"insecureSkipVerify,omitempty"`
+
 	// failureDomains configures failure domains information for the Nutanix platform.
 	// When set, the failure domains defined here may be used to spread Machines across
 	// prism element clusters to improve fault tolerance of the cluster.
 	// +listType=map
 	// +listMapKey=name
 	// +optional
-	FailureDomains []NutanixFailureDomain `json:"failureDomains"`
+	FailureDomains []NutanixFailureDomainConfig `json:"failureDomains"`
+
+	// machineDefaults holds the machine settings used for the NutanixMachines of the cluster that do not set them.
+	// Settings of the NutanixMachine always take precedence.
+	// +optional
+	MachineDefaults *NutanixMachineDefaults `json:"machineDefaults,omitempty"`
 }
 
 // NutanixClusterStatus defines the observed state of NutanixCluster
@@ -77,6 +137,20 @@ type NutanixClusterStatus struct {
 	// +optional
 	Conditions capiv1.Conditions `json:"conditions,omitempty"`
 
+	// ObservedGeneration is the generation of the NutanixCluster that was last reconciled successfully.
+	// The conditions reflect this generation as well.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// PrismCentralVersion is the version of Prism Central detected when the client for Prism Central was created.
+	// +optional
+	PrismCentralVersion string `json:"prismCentralVersion,omitempty"`
+
+	// Phase summarizes the reconcile state of the NutanixCluster. It is derived from the conditions and the failure
+	// status at the end of each reconcile.
+	// +optional
+	Phase NutanixClusterPhase `json:"phase,omitempty"`
+
 	// Will be set in case of failure of Cluster instance
 	// +optional
 	FailureReason *errors.ClusterStatusError `json:"failureReason,omitempty"`
@@ -92,6 +166,7 @@ type NutanixClusterStatus struct {
 //+kubebuilder:storageversion
 //+kubebuilder:printcolumn:name="ControlplaneEndpoint",type="string",JSONPath=".spec.controlPlaneEndpoint.host",description="ControlplaneEndpoint"
 //+kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="in ready status"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="NutanixCluster phase"
 
 // NutanixCluster is the Schema for the nutanixclusters API
 type NutanixCluster struct {
@@ -102,8 +177,8 @@ type NutanixCluster struct {
 	Status NutanixClusterStatus `json:"status,omitempty"`
 }
 
-// NutanixFailureDomain configures failure domain information for Nutanix.
-type NutanixFailureDomain struct {
+// NutanixFailureDomainConfig configures failure domain information for Nutanix.
+type NutanixFailureDomainConfig struct {
 	// name defines the unique name of a failure domain.
 	// Name is required and must be at most 64 characters in length.
 	// It must consist of only lower case alphanumeric characters and hyphens (-).
@@ -133,6 +208,67 @@ type NutanixFailureDomain struct {
 	// indicates if a failure domain is suited for control plane nodes
 	// +kubebuilder:validation:Required
 	ControlPlane bool `json:"controlPlane,omitempty"`
+
+	// weight is the relative weight of the failure domain used when placing machines that have no
+	// failure domain assigned. Machines are distributed across the eligible failure domains
+	// proportionally to their weights. Defaults to 1 if not set.
+	// The weight does not apply to machines that Cluster API assigns a failure domain to. In particular,
+	// the KubeadmControlPlane always assigns one to its machines and spreads them evenly across the
+	// failure domains suited for control plane nodes, so in practice the weight only places workers,
+	// e.g. of MachineDeployments without a failure domain.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+// NutanixMachineDefaults holds the default machine settings of a NutanixCluster. The defaults are merged into the spec
+// of the NutanixMachines of the cluster whose corresponding fields are not set.
+type NutanixMachineDefaults struct {
+	// image identifies the image of the VMs of NutanixMachines that do not set an image.
+	// +optional
+	Image *NutanixResourceIdentifier `json:"image,omitempty"`
+
+	// cluster identifies the Prism Element cluster of the VMs of NutanixMachines that do not set a cluster.
+	// The cluster is not used for Machines placed in a failure domain.
+	// +optional
+	Cluster *NutanixResourceIdentifier `json:"cluster,omitempty"`
+
+	// subnets identifies the subnets of the VMs of NutanixMachines that set neither subnets nor nics.
+	// The subnets are not used for Machines placed in a failure domain.
+	// +optional
+	Subnets []NutanixResourceIdentifier `json:"subnets,omitempty"`
+
+	// project identifies the project of the VMs of NutanixMachines that do not set a project.
+	// +optional
+	Project *NutanixResourceIdentifier `json:"project,omitempty"`
+}
+
+// NutanixProxySpec configures the proxy used to access Prism Central.
+type NutanixProxySpec struct {
+	// httpProxy is the URL of the proxy for HTTP requests.
+	// +optional
+	HTTPProxy string `json:"httpProxy,omitempty"`
+
+	// httpsProxy is the URL of the proxy for HTTPS requests.
+	// +optional
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+
+	// noProxy is the list of hosts, domains (e.g. .example.com), IP addresses or CIDRs
+	// that are accessed without the proxy.
+	// +optional
+	NoProxy []string `json:"noProxy,omitempty"`
+}
+
+// NutanixClientCertificate references the PEM encoded client certificate and private key used for mutual TLS
+// authentication. The referenced secrets must be in the namespace of the NutanixCluster.
+type NutanixClientCertificate struct {
+	// certificateRef references the secret key holding the PEM encoded client certificate.
+	// +optional
+	CertificateRef *corev1.SecretKeySelector `json:"certificateRef,omitempty"`
+
+	// keyRef references the secret key holding the PEM encoded private key of the client certificate.
+	// +optional
+	KeyRef *corev1.SecretKeySelector `json:"keyRef,omitempty"`
 }
 
 // GetConditions returns the set of conditions for this object.
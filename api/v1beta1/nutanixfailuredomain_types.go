@@ -0,0 +1,101 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// NutanixFailureDomainKind represents the Kind of NutanixFailureDomain
+	NutanixFailureDomainKind = "NutanixFailureDomain"
+)
+
+// NutanixFailureDomainSpec defines the desired state of NutanixFailureDomain
+type NutanixFailureDomainSpec struct {
+	// prismElementCluster is to identify the cluster (the Prism Element under management of the Prism Central),
+	// in which the Machine's VM will be created. The cluster identifier (uuid or name) can be obtained
+	// from the Prism Central console or using the prism_central API.
+	// +kubebuilder:validation:Required
+	PrismElementCluster NutanixResourceIdentifier `json:"prismElementCluster"`
+
+	// subnets holds a list of identifiers (one or more) of the cluster's network subnets
+	// for the Machine's VM to connect to. The subnet identifiers (uuid or name) can be
+	// obtained from the Prism Central console or using the prism_central API.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	// +listType=map
+	// +listMapKey=type
+	Subnets []NutanixResourceIdentifier `json:"subnets"`
+
+	// indicates if a failure domain is suited for control plane nodes
+	// +optional
+	ControlPlane bool `json:"controlPlane,omitempty"`
+
+	// weight is the relative weight of the failure domain used when placing machines that have no
+	// failure domain assigned. Defaults to 1 if not set.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Weight *int32 `json:"weight,omitempty"`
+}
+
+// NutanixFailureDomainStatus defines the observed state of NutanixFailureDomain
+type NutanixFailureDomainStatus struct {
+	// conditions represent the latest states of the failure domain.
+	// +optional
+	Conditions capiv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:path=nutanixfailuredomains,shortName=nfd,scope=Namespaced,categories=cluster-api
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this NutanixFailureDomain belongs"
+//+kubebuilder:printcolumn:name="ControlPlane",type="boolean",JSONPath=".spec.controlPlane",description="Suited for control plane nodes"
+
+// NutanixFailureDomain is the Schema for the nutanixfailuredomains API
+type NutanixFailureDomain struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NutanixFailureDomainSpec   `json:"spec,omitempty"`
+	Status NutanixFailureDomainStatus `json:"status,omitempty"`
+}
+
+// GetConditions returns the set of conditions for this object.
+func (nfd *NutanixFailureDomain) GetConditions() capiv1.Conditions {
+	return nfd.Status.Conditions
+}
+
+// SetConditions sets the conditions on this object.
+func (nfd *NutanixFailureDomain) SetConditions(conditions capiv1.Conditions) {
+	nfd.Status.Conditions = conditions
+}
+
+//+kubebuilder:object:root=true
+
+// NutanixFailureDomainList contains a list of NutanixFailureDomain
+type NutanixFailureDomainList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NutanixFailureDomain `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NutanixFailureDomain{}, &NutanixFailureDomainList{})
+}
@@ -20,6 +20,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/errors"
 )
@@ -35,6 +36,22 @@ const (
 	// resources associated with NutanixMachine before removing it from the
 	// API Server.
 	NutanixMachineFinalizer = "nutanixmachine.infrastructure.cluster.x-k8s.io"
+
+	// NutanixMachineFailureDomainAnnotation records the failure domain selected for a NutanixMachine that has
+	// no failure domain assigned, so the same failure domain is used on subsequent reconciles.
+	NutanixMachineFailureDomainAnnotation = "nutanixmachine.infrastructure.cluster.x-k8s.io/failure-domain"
+
+	// NutanixMachineSkipPrismValidationAnnotation disables the verification of the resources referenced by a
+	// NutanixMachine against Prism Central, e.g. when applying manifests while Prism Central is not reachable.
+	NutanixMachineSkipPrismValidationAnnotation = "nutanixmachine.infrastructure.cluster.x-k8s.io/skip-prism-validation"
+
+	// NutanixMachineShutdownRequestedAnnotation records the time at which the guest shutdown of the VM of a
+	// deleted NutanixMachine was requested, in RFC3339 format.
+	NutanixMachineShutdownRequestedAnnotation = "nutanixmachine.infrastructure.cluster.x-k8s.io/shutdown-requested"
+
+	// NutanixMachineRecreateVMAnnotation requests the deletion of the VM of a NutanixMachine without deleting the
+	// NutanixMachine. Once the VM is deleted, the annotation is removed and a new VM is created.
+	NutanixMachineRecreateVMAnnotation = "nutanixmachine.infrastructure.cluster.x-k8s.io/recreate-vm"
 )
 
 // NutanixMachineSpec defines the desired state of NutanixMachine
@@ -52,49 +69,217 @@ type NutanixMachineSpec struct {
 	// +kubebuilder:validation:Minimum=1
 	VCPUSockets int32 `json:"vcpuSockets"`
 	// memorySize is the memory size (in Quantity format) of the VM
-	// The minimum memorySize is 2Gi bytes
+	// The minimum memorySize is 2Gi bytes, or 4Gi bytes for VMs with UEFI secure boot, which also need at least
+	// 2 vCPUs. The VM gets the size rounded up to a whole number of Mi bytes, e.g. 4G results in 3815Mi.
 	// +kubebuilder:validation:Required
 	MemorySize resource.Quantity `json:"memorySize"`
 	// image is to identify the rhcos image uploaded to the Prism Central (PC)
 	// The image identifier (uuid or name) can be obtained from the Prism Central console
 	// or using the prism_central API.
-	// +kubebuilder:validation:Required
+	// If not set, the image of the machine defaults of the NutanixCluster is used.
+	// +optional
 	Image NutanixResourceIdentifier `json:"image"`
+	// imageClusterScoped scopes the resolution of the image to the copies present on the cluster of the Machine's
+	// failure domain, or on the cluster set on the NutanixMachine. This selects the right image if images with the
+	// same name exist in several clusters. Creating the VM fails if the image is not present on the cluster.
+	// +optional
+	ImageClusterScoped bool `json:"imageClusterScoped,omitempty"`
 	// cluster is to identify the cluster (the Prism Element under management
 	// of the Prism Central), in which the Machine's VM will be created.
 	// The cluster identifier (uuid or name) can be obtained from the Prism Central console
 	// or using the prism_central API.
+	// If not set, the cluster of the machine defaults of the NutanixCluster is used unless the Machine is placed in a
+	// failure domain.
 	// +kubebuilder:validation:Optional
 	Cluster NutanixResourceIdentifier `json:"cluster"`
 	// subnet is to identify the cluster's network subnet to use for the Machine's VM
 	// The cluster identifier (uuid or name) can be obtained from the Prism Central console
 	// or using the prism_central API.
+	// If neither subnet nor nics are set, the subnets of the machine defaults of the NutanixCluster are used unless
+	// the Machine is placed in a failure domain.
 	// +kubebuilder:validation:Optional
 	Subnets []NutanixResourceIdentifier `json:"subnet"`
-	// List of categories that need to be added to the machines. Categories must already exist in Prism Central
+	// nics configures the network interfaces of the Machine's VM with static IP addresses or IP addresses
+	// claimed from an IPAM provider. If set, the network interfaces are created for the subnets of the nics
+	// instead of the subnets of the subnet field or of the failure domain.
+	// +optional
+	NICs []NutanixMachineNIC `json:"nics,omitempty"`
+	// List of categories that need to be added to the machines. Categories must already exist in Prism Central.
+	// Changes are applied to existing VMs. Other categories of the VMs except the ownership categories of the cluster
+	// are removed.
 	// +kubebuilder:validation:Optional
 	AdditionalCategories []NutanixCategoryIdentifier `json:"additionalCategories,omitempty"`
+	// placementPolicyCategories are categories driving Prism Central placement policies, e.g. VM-host affinity
+	// policies. They are assigned to the VM in its create request, so Prism Central places the VM according to the
+	// policies from the start. The categories must already exist in Prism Central. Like additional categories, changes
+	// are applied to existing VMs. The PlacementPolicyApplied condition reports if they could not be assigned.
+	// +optional
+	PlacementPolicyCategories []NutanixCategoryIdentifier `json:"placementPolicyCategories,omitempty"`
 	// Add the machine resources to a Prism Central project
+	// If not set, the project of the machine defaults of the NutanixCluster is used.
 	// +optional
 	Project *NutanixResourceIdentifier `json:"project,omitempty"`
+	// owner is the Prism Central user set as owner of the VM, so Prism Central attributes the VM to this user instead
+	// of the user of the Prism Central credentials, e.g. for auditing. The user is referenced by UUID or by name, which
+	// is the user principal name of the user. Has no effect on existing VMs.
+	// +optional
+	Owner *NutanixResourceIdentifier `json:"owner,omitempty"`
 	// Defines the boot type of the virtual machine. Only supports UEFI and Legacy
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Enum:=legacy;uefi
 	BootType NutanixBootType `json:"bootType,omitempty"`
+	// secureBoot enables UEFI secure boot on the VM, which is then created with the Q35 machine type.
+	// Only allowed if the bootType is uefi. Has no effect on existing VMs.
+	// +optional
+	SecureBoot *bool `json:"secureBoot,omitempty"`
 
 	// systemDiskSize is size (in Quantity format) of the system disk of the VM
-	// The minimum systemDiskSize is 20Gi bytes
+	// The minimum systemDiskSize is 20Gi bytes. The disk gets the size rounded up to a whole number of Mi bytes.
+	// The systemDiskSize can be increased to grow the system disk of an existing VM, but cannot be decreased.
 	// +kubebuilder:validation:Required
 	SystemDiskSize resource.Quantity `json:"systemDiskSize"`
 
+	// systemDiskStorageContainer is the storage container (uuid) the system disk of the VM is placed on.
+	// Storage containers cannot be identified by name.
+	// The storage container must exist on the Prism Element cluster the VM is created on.
+	// The default storage container of the cluster is used if not set.
+	// +optional
+	SystemDiskStorageContainer *NutanixResourceIdentifier `json:"systemDiskStorageContainer,omitempty"`
+
+	// systemDiskBus is the bus type of the system disk of the VM, one of SCSI, PCI or SATA.
+	// Has no effect on existing VMs.
+	// +kubebuilder:validation:Enum:=SCSI;PCI;SATA
+	// +kubebuilder:default:=SCSI
+	// +optional
+	SystemDiskBus NutanixDiskBus `json:"systemDiskBus,omitempty"`
+
 	// BootstrapRef is a reference to a bootstrap provider-specific resource
 	// that holds configuration details.
 	// +optional
 	BootstrapRef *corev1.ObjectReference `json:"bootstrapRef,omitempty"`
 
+	// bootstrapFormat is the format of the bootstrap data of the Machine, one of cloud-init or ignition.
+	// The bootstrap data is passed to the VM with guest customization in both cases. Ignition is required by
+	// distributions like Flatcar Container Linux. The format must match the format of the bootstrap provider.
+	// +kubebuilder:validation:Enum:=cloud-init;ignition
+	// +kubebuilder:default:=cloud-init
+	// +optional
+	BootstrapFormat NutanixBootstrapFormat `json:"bootstrapFormat,omitempty"`
+
+	// bootstrapISO is the image (uuid or name) of an ISO that is attached to the VM as a SATA CD-ROM, for example an
+	// ISO carrying the unattend data of Windows images that do not run cloud-init. The bootstrap data of the Machine
+	// is still passed with guest customization. Not supported with the ignition bootstrap format.
+	// Has no effect on existing VMs.
+	// +optional
+	BootstrapISO *NutanixResourceIdentifier `json:"bootstrapISO,omitempty"`
+
+	// ejectBootstrapISO ejects the bootstrap ISO from the CD-ROM of the VM once the Node of the Machine exists, so
+	// the VM does not boot from or rerun the bootstrap ISO. Requires bootstrapISO.
+	// +optional
+	EjectBootstrapISO bool `json:"ejectBootstrapISO,omitempty"`
+
+	// nameServers are the IP addresses of the DNS servers configured on the VM at first boot, independent of DHCP.
+	// The name servers are configured with systemd-resolved.
+	// +optional
+	NameServers []string `json:"nameServers,omitempty"`
+
+	// searchDomains are the DNS search domains configured on the VM at first boot, independent of DHCP.
+	// The search domains are configured with systemd-resolved.
+	// +optional
+	SearchDomains []string `json:"searchDomains,omitempty"`
+
 	// List of GPU devices that need to be added to the machines.
 	// +kubebuilder:validation:Optional
 	GPUs []NutanixGPU `json:"gpus,omitempty"`
+
+	// serialPorts configures the serial ports of the VM, for example for appliances emulating a license dongle
+	// on a serial port. The indices of the serial ports must be unique. Has no effect on existing VMs.
+	// +optional
+	SerialPorts []NutanixSerialPort `json:"serialPorts,omitempty"`
+
+	// List of volume groups that need to be attached to the machines. Volume groups must already exist in Prism Central
+	// +kubebuilder:validation:Optional
+	VolumeGroups []NutanixResourceIdentifier `json:"volumeGroups,omitempty"`
+
+	// checkGuestTools enables polling the VM for the readiness of Nutanix Guest Tools once the VM is created. The
+	// readiness is reported in the GuestToolsReady condition, which is Unknown if Nutanix Guest Tools are not
+	// installed on the VM.
+	// +optional
+	CheckGuestTools bool `json:"checkGuestTools,omitempty"`
+
+	// powerOnAfterCreate powers on the VM once it is created. If false, the VM is created powered off and the
+	// NutanixMachine waits in the VMPoweredOn condition until the VM is powered on outside of the controller, e.g.
+	// by a separate automation. The Machine is not failed while it waits. Has no effect on existing VMs.
+	// +kubebuilder:default:=true
+	// +optional
+	PowerOnAfterCreate *bool `json:"powerOnAfterCreate,omitempty"`
+
+	// vmNameTemplate is a Go template rendering the name of the VM in Prism Central, for example
+	// "{{ .Cluster.Namespace }}-{{ .Machine.Name }}". The template has access to the Cluster, the Machine and the
+	// NutanixMachine objects. The rendered name must not exceed 64 characters. The name of the Machine is used if
+	// the template is not set. The hostname of the VM is set with hostnameTemplate.
+	// The template cannot be changed once set. The VM is renamed if the rendered name changes, e.g. with the labels of
+	// the Machine, unless another VM already has the name.
+	// +optional
+	VMNameTemplate string `json:"vmNameTemplate,omitempty"`
+
+	// hostnameTemplate is a Go template rendering the hostname of the VM passed in the guest customization, for example
+	// "{{ .Machine.Name }}.example.com". The template has access to the same objects as vmNameTemplate. The rendered
+	// hostname must be a valid DNS subdomain and is the name of the Node of the Machine. The name of the Machine is
+	// used if the template is not set.
+	// A changed hostname is applied to VMs that were created powered off and were not powered on yet. Other VMs keep
+	// their hostname and get the HostnameChangePending condition until they are recreated.
+	// +optional
+	HostnameTemplate string `json:"hostnameTemplate,omitempty"`
+
+	// existingVMUUID is the UUID of an existing VM in Prism Central that is adopted as the VM of the Machine instead
+	// of creating a new VM, for example when migrating existing VMs. The ownership categories of the cluster and the
+	// additional categories are applied to the adopted VM, which is deleted with the Machine. The VM must not be owned
+	// by another Machine. The other fields of the spec are not applied to the adopted VM.
+	// The UUID cannot be changed once set.
+	// +optional
+	ExistingVMUUID string `json:"existingVMUUID,omitempty"`
+
+	// extraVMConfig holds fields of the Prism Central v3 VM intent, i.e. of its "spec" and "metadata", that are not
+	// exposed by the NutanixMachine, for example {"spec":{"resources":{"vga_console_enabled":false}}}. It is merged
+	// into the VM intent generated for the VM before the VM is created: objects are merged recursively, all other
+	// values, including lists, replace the generated values. The VM name and the ownership categories of the cluster
+	// cannot be set. This is an escape hatch that is ignored unless the controller runs with --enable-extra-vm-config.
+	// Has no effect on existing VMs.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	ExtraVMConfig *runtime.RawExtension `json:"extraVMConfig,omitempty"`
+}
+
+// NutanixMachineNIC configures a network interface of the Machine's VM
+type NutanixMachineNIC struct {
+	// subnet is to identify the subnet the network interface is connected to.
+	// The subnet must exist on the cluster the Machine's VM is created on.
+	// +kubebuilder:validation:Required
+	Subnet NutanixResourceIdentifier `json:"subnet"`
+
+	// ipAddress is the static IPv4 address of the network interface. Prism Central assigns the address to the
+	// network interface on subnets with IP address management.
+	// +optional
+	IPAddress *string `json:"ipAddress,omitempty"`
+
+	// ipAddressPoolRef is a reference to the IPAM pool the IPv4 address of the network interface is claimed from
+	// with an IPAddressClaim, if ipAddress is not set. The VM is only created once the IPAM provider allocated
+	// the address.
+	// +optional
+	IPAddressPoolRef *corev1.TypedLocalObjectReference `json:"ipAddressPoolRef,omitempty"`
+}
+
+// NutanixSerialPort configures a serial port of the Machine's VM
+type NutanixSerialPort struct {
+	// index is the index of the serial port on the VM
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3
+	Index int64 `json:"index"`
+
+	// isConnected connects the serial port to the VM
+	// +optional
+	IsConnected bool `json:"isConnected,omitempty"`
 }
 
 // NutanixMachineStatus defines the observed state of NutanixMachine
@@ -114,6 +299,19 @@ type NutanixMachineStatus struct {
 	// +optional
 	VmUUID string `json:"vmUUID,omitempty"`
 
+	// PowerState is the power state of the Nutanix VM, ON or OFF, as last observed by the controller.
+	// +optional
+	PowerState string `json:"powerState,omitempty"`
+
+	// failureDomain is the name of the failure domain the Nutanix VM was placed in.
+	// +optional
+	FailureDomain *string `json:"failureDomain,omitempty"`
+
+	// clusterUUID is the UUID of the Prism Element cluster the Nutanix VM was placed on, as resolved from the failure
+	// domain or the cluster of the NutanixMachine, and as last observed on the VM by the controller.
+	// +optional
+	ClusterUUID string `json:"clusterUUID,omitempty"`
+
 	// NodeRef is a reference to the corresponding workload cluster Node if it exists.
 	// +optional
 	NodeRef *corev1.ObjectReference `json:"nodeRef,omitempty"`
@@ -122,6 +320,11 @@ type NutanixMachineStatus struct {
 	// +optional
 	Conditions capiv1.Conditions `json:"conditions,omitempty"`
 
+	// ObservedGeneration is the generation of the NutanixMachine that was last reconciled successfully.
+	// The conditions reflect this generation as well.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// Will be set in case of failure of Machine instance
 	// +optional
 	FailureReason *errors.MachineStatusError `json:"failureReason,omitempty"`
@@ -138,6 +341,7 @@ type NutanixMachineStatus struct {
 //+kubebuilder:printcolumn:name="Address",type="string",JSONPath=".status.addresses[0].address",description="The VM address"
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="NutanixMachine ready status"
 // +kubebuilder:printcolumn:name="ProviderID",type="string",JSONPath=".spec.providerID",description="NutanixMachine instance ID"
+// +kubebuilder:printcolumn:name="PowerState",type="string",JSONPath=".status.powerState",description="Power state of the Nutanix VM",priority=1
 // +kubebuilder:printcolumn:name="NodeRef",type="string",JSONPath=".status.nodeRef.name",description="Corresponding workload cluster node"
 
 // NutanixMachine is the Schema for the nutanixmachines API
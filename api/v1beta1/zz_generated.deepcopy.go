@@ -24,7 +24,7 @@ package v1beta1
 import (
 	"github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
 	"k8s.io/api/core/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 	apiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/errors"
 )
@@ -44,6 +44,31 @@ func (in *NutanixCategoryIdentifier) DeepCopy() *NutanixCategoryIdentifier {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NutanixClientCertificate) DeepCopyInto(out *NutanixClientCertificate) {
+	*out = *in
+	if in.CertificateRef != nil {
+		in, out := &in.CertificateRef, &out.CertificateRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KeyRef != nil {
+		in, out := &in.KeyRef, &out.KeyRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixClientCertificate.
+func (in *NutanixClientCertificate) DeepCopy() *NutanixClientCertificate {
+	if in == nil {
+		return nil
+	}
+	out := new(NutanixClientCertificate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NutanixCluster) DeepCopyInto(out *NutanixCluster) {
 	*out = *in
@@ -112,13 +137,33 @@ func (in *NutanixClusterSpec) DeepCopyInto(out *NutanixClusterSpec) {
 		*out = new(credentials.NutanixPrismEndpoint)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(NutanixProxySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClientCertificate != nil {
+		in, out := &in.ClientCertificate, &out.ClientCertificate
+		*out = new(NutanixClientCertificate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InsecureSkipVerify != nil {
+		in, out := &in.InsecureSkipVerify, &out.InsecureSkipVerify
+		*out = new(bool)
+		**out = **in
+	}
 	if in.FailureDomains != nil {
 		in, out := &in.FailureDomains, &out.FailureDomains
-		*out = make([]NutanixFailureDomain, len(*in))
+		*out = make([]NutanixFailureDomainConfig, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.MachineDefaults != nil {
+		in, out := &in.MachineDefaults, &out.MachineDefaults
+		*out = new(NutanixMachineDefaults)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixClusterSpec.
@@ -172,6 +217,33 @@ func (in *NutanixClusterStatus) DeepCopy() *NutanixClusterStatus {
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NutanixFailureDomain) DeepCopyInto(out *NutanixFailureDomain) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixFailureDomain.
+func (in *NutanixFailureDomain) DeepCopy() *NutanixFailureDomain {
+	if in == nil {
+		return nil
+	}
+	out := new(NutanixFailureDomain)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NutanixFailureDomain) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NutanixFailureDomainConfig) DeepCopyInto(out *NutanixFailureDomainConfig) {
 	*out = *in
 	in.Cluster.DeepCopyInto(&out.Cluster)
 	if in.Subnets != nil {
@@ -181,14 +253,101 @@ func (in *NutanixFailureDomain) DeepCopyInto(out *NutanixFailureDomain) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixFailureDomain.
-func (in *NutanixFailureDomain) DeepCopy() *NutanixFailureDomain {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixFailureDomainConfig.
+func (in *NutanixFailureDomainConfig) DeepCopy() *NutanixFailureDomainConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(NutanixFailureDomain)
+	out := new(NutanixFailureDomainConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NutanixFailureDomainList) DeepCopyInto(out *NutanixFailureDomainList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NutanixFailureDomain, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixFailureDomainList.
+func (in *NutanixFailureDomainList) DeepCopy() *NutanixFailureDomainList {
+	if in == nil {
+		return nil
+	}
+	out := new(NutanixFailureDomainList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NutanixFailureDomainList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NutanixFailureDomainSpec) DeepCopyInto(out *NutanixFailureDomainSpec) {
+	*out = *in
+	in.PrismElementCluster.DeepCopyInto(&out.PrismElementCluster)
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]NutanixResourceIdentifier, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Weight != nil {
+		in, out := &in.Weight, &out.Weight
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixFailureDomainSpec.
+func (in *NutanixFailureDomainSpec) DeepCopy() *NutanixFailureDomainSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NutanixFailureDomainSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NutanixFailureDomainStatus) DeepCopyInto(out *NutanixFailureDomainStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixFailureDomainStatus.
+func (in *NutanixFailureDomainStatus) DeepCopy() *NutanixFailureDomainStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NutanixFailureDomainStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -245,6 +404,43 @@ func (in *NutanixMachine) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NutanixMachineDefaults) DeepCopyInto(out *NutanixMachineDefaults) {
+	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(NutanixResourceIdentifier)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cluster != nil {
+		in, out := &in.Cluster, &out.Cluster
+		*out = new(NutanixResourceIdentifier)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]NutanixResourceIdentifier, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Project != nil {
+		in, out := &in.Project, &out.Project
+		*out = new(NutanixResourceIdentifier)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixMachineDefaults.
+func (in *NutanixMachineDefaults) DeepCopy() *NutanixMachineDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(NutanixMachineDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NutanixMachineList) DeepCopyInto(out *NutanixMachineList) {
 	*out = *in
@@ -277,6 +473,32 @@ func (in *NutanixMachineList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NutanixMachineNIC) DeepCopyInto(out *NutanixMachineNIC) {
+	*out = *in
+	in.Subnet.DeepCopyInto(&out.Subnet)
+	if in.IPAddress != nil {
+		in, out := &in.IPAddress, &out.IPAddress
+		*out = new(string)
+		**out = **in
+	}
+	if in.IPAddressPoolRef != nil {
+		in, out := &in.IPAddressPoolRef, &out.IPAddressPoolRef
+		*out = new(v1.TypedLocalObjectReference)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixMachineNIC.
+func (in *NutanixMachineNIC) DeepCopy() *NutanixMachineNIC {
+	if in == nil {
+		return nil
+	}
+	out := new(NutanixMachineNIC)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NutanixMachineSpec) DeepCopyInto(out *NutanixMachineSpec) {
 	*out = *in
@@ -290,22 +512,64 @@ func (in *NutanixMachineSpec) DeepCopyInto(out *NutanixMachineSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.NICs != nil {
+		in, out := &in.NICs, &out.NICs
+		*out = make([]NutanixMachineNIC, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.AdditionalCategories != nil {
 		in, out := &in.AdditionalCategories, &out.AdditionalCategories
 		*out = make([]NutanixCategoryIdentifier, len(*in))
 		copy(*out, *in)
 	}
+	if in.PlacementPolicyCategories != nil {
+		in, out := &in.PlacementPolicyCategories, &out.PlacementPolicyCategories
+		*out = make([]NutanixCategoryIdentifier, len(*in))
+		copy(*out, *in)
+	}
 	if in.Project != nil {
 		in, out := &in.Project, &out.Project
 		*out = new(NutanixResourceIdentifier)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Owner != nil {
+		in, out := &in.Owner, &out.Owner
+		*out = new(NutanixResourceIdentifier)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecureBoot != nil {
+		in, out := &in.SecureBoot, &out.SecureBoot
+		*out = new(bool)
+		**out = **in
+	}
 	out.SystemDiskSize = in.SystemDiskSize.DeepCopy()
+	if in.SystemDiskStorageContainer != nil {
+		in, out := &in.SystemDiskStorageContainer, &out.SystemDiskStorageContainer
+		*out = new(NutanixResourceIdentifier)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.BootstrapRef != nil {
 		in, out := &in.BootstrapRef, &out.BootstrapRef
 		*out = new(v1.ObjectReference)
 		**out = **in
 	}
+	if in.BootstrapISO != nil {
+		in, out := &in.BootstrapISO, &out.BootstrapISO
+		*out = new(NutanixResourceIdentifier)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NameServers != nil {
+		in, out := &in.NameServers, &out.NameServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SearchDomains != nil {
+		in, out := &in.SearchDomains, &out.SearchDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.GPUs != nil {
 		in, out := &in.GPUs, &out.GPUs
 		*out = make([]NutanixGPU, len(*in))
@@ -313,6 +577,28 @@ func (in *NutanixMachineSpec) DeepCopyInto(out *NutanixMachineSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SerialPorts != nil {
+		in, out := &in.SerialPorts, &out.SerialPorts
+		*out = make([]NutanixSerialPort, len(*in))
+		copy(*out, *in)
+	}
+	if in.VolumeGroups != nil {
+		in, out := &in.VolumeGroups, &out.VolumeGroups
+		*out = make([]NutanixResourceIdentifier, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PowerOnAfterCreate != nil {
+		in, out := &in.PowerOnAfterCreate, &out.PowerOnAfterCreate
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ExtraVMConfig != nil {
+		in, out := &in.ExtraVMConfig, &out.ExtraVMConfig
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixMachineSpec.
@@ -333,6 +619,11 @@ func (in *NutanixMachineStatus) DeepCopyInto(out *NutanixMachineStatus) {
 		*out = make([]apiv1beta1.MachineAddress, len(*in))
 		copy(*out, *in)
 	}
+	if in.FailureDomain != nil {
+		in, out := &in.FailureDomain, &out.FailureDomain
+		*out = new(string)
+		**out = **in
+	}
 	if in.NodeRef != nil {
 		in, out := &in.NodeRef, &out.NodeRef
 		*out = new(v1.ObjectReference)
@@ -458,6 +749,26 @@ func (in *NutanixMachineTemplateSpec) DeepCopy() *NutanixMachineTemplateSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NutanixProxySpec) DeepCopyInto(out *NutanixProxySpec) {
+	*out = *in
+	if in.NoProxy != nil {
+		in, out := &in.NoProxy, &out.NoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixProxySpec.
+func (in *NutanixProxySpec) DeepCopy() *NutanixProxySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NutanixProxySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NutanixResourceIdentifier) DeepCopyInto(out *NutanixResourceIdentifier) {
 	*out = *in
@@ -471,6 +782,11 @@ func (in *NutanixResourceIdentifier) DeepCopyInto(out *NutanixResourceIdentifier
 		*out = new(string)
 		**out = **in
 	}
+	if in.Category != nil {
+		in, out := &in.Category, &out.Category
+		*out = new(NutanixCategoryIdentifier)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixResourceIdentifier.
@@ -482,3 +798,18 @@ func (in *NutanixResourceIdentifier) DeepCopy() *NutanixResourceIdentifier {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NutanixSerialPort) DeepCopyInto(out *NutanixSerialPort) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NutanixSerialPort.
+func (in *NutanixSerialPort) DeepCopy() *NutanixSerialPort {
+	if in == nil {
+		return nil
+	}
+	out := new(NutanixSerialPort)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// validate-manifest validates the NutanixCluster and NutanixMachineTemplate objects of YAML manifests with the
+// validation of the admission webhooks. If Prism Central credentials are given, the clusters, subnets, images and
+// projects referenced by name are looked up in Prism Central.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	prismgoclient "github.com/nutanix-cloud-native/prism-go-client"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	"github.com/nutanix-cloud-native/cluster-api-provider-nutanix/controllers"
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// manifestObject is a NutanixCluster or NutanixMachineTemplate read from a manifest
+type manifestObject struct {
+	Source string
+	Kind   string
+	Name   string
+	// Object is nil if the object could not be decoded
+	Object    interface{}
+	DecodeErr error
+}
+
+// objectResult is the validation result of a single object
+type objectResult struct {
+	Source string   `json:"source"`
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// summary is the validation result of all objects
+type summary struct {
+	ReferencesVerified bool           `json:"referencesVerified"`
+	Objects            []objectResult `json:"objects"`
+	Invalid            int            `json:"invalid"`
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run validates the manifests given as arguments and returns the exit code
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate-manifest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	endpoint := fs.String("endpoint", os.Getenv("NUTANIX_ENDPOINT"), "Address of Prism Central. Resources referenced by name are only looked up if set. Defaults to $NUTANIX_ENDPOINT.")
+	port := fs.String("port", os.Getenv("NUTANIX_PORT"), "Port of Prism Central. Defaults to $NUTANIX_PORT or 9440.")
+	username := fs.String("username", os.Getenv("NUTANIX_USER"), "User name for Prism Central. Defaults to $NUTANIX_USER. The password is read from $NUTANIX_PASSWORD.")
+	insecureDefault, _ := strconv.ParseBool(os.Getenv("NUTANIX_INSECURE"))
+	insecure := fs.Bool("insecure", insecureDefault, "Skip verification of the Prism Central certificate. Defaults to $NUTANIX_INSECURE.")
+	trustBundleFile := fs.String("trust-bundle", "", "Path of a PEM encoded CA bundle used to verify the Prism Central certificate.")
+	output := fs.String("output", outputText, "Output format of the summary, one of text or json.")
+	credentialFileDir := fs.String("credential-file-dir", "", "Credential file directory of the controller. Credential references of kind File are rejected if not set, as by the controller.")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: validate-manifest [flags] <manifest>... (use - to read from stdin)\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 || (*output != outputText && *output != outputJSON) {
+		fs.Usage()
+		return 2
+	}
+	nutanixClient.CredentialFiles.SetDirectory(*credentialFileDir)
+
+	objects := make([]manifestObject, 0)
+	for _, path := range fs.Args() {
+		objs, err := readManifest(path, stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to read manifest %s: %v\n", path, err)
+			return 1
+		}
+		objects = append(objects, objs...)
+	}
+
+	var client *nutanixClientV3.Client
+	if *endpoint != "" {
+		trustBundle := ""
+		if *trustBundleFile != "" {
+			data, err := os.ReadFile(*trustBundleFile)
+			if err != nil {
+				fmt.Fprintf(stderr, "failed to read trust bundle %s: %v\n", *trustBundleFile, err)
+				return 1
+			}
+			trustBundle = string(data)
+		}
+		c, err := newClient(ctx, prismgoclient.Credentials{
+			Endpoint: *endpoint,
+			Port:     *port,
+			Username: *username,
+			Password: os.Getenv("NUTANIX_PASSWORD"),
+			Insecure: *insecure,
+		}, trustBundle)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to create client for Prism Central %s: %v\n", *endpoint, err)
+			return 1
+		}
+		client = c
+	}
+
+	s := validate(ctx, client, objects)
+	if err := printSummary(stdout, *output, s); err != nil {
+		fmt.Fprintf(stderr, "failed to print summary: %v\n", err)
+		return 1
+	}
+	if s.Invalid > 0 {
+		return 1
+	}
+	return 0
+}
+
+func newClient(ctx context.Context, cred prismgoclient.Credentials, trustBundle string) (*nutanixClientV3.Client, error) {
+	helper, err := nutanixClient.NewNutanixClientHelper(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return helper.GetClient(ctx, cred, trustBundle)
+}
+
+// readManifest reads the manifest at the given path, or stdin if the path is -
+func readManifest(path string, stdin io.Reader) ([]manifestObject, error) {
+	if path == "-" {
+		return decodeManifest("stdin", stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return decodeManifest(path, f)
+}
+
+// decodeManifest returns the NutanixCluster and NutanixMachineTemplate objects of a multi-document YAML manifest.
+// Objects of other kinds are skipped. Objects with unknown or mistyped fields are returned with a decode error.
+func decodeManifest(source string, r io.Reader) ([]manifestObject, error) {
+	objects := make([]manifestObject, 0)
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for i := 0; ; i++ {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := utilyaml.ToJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %d is not valid YAML: %v", i, err)
+		}
+		if len(bytes.TrimSpace(data)) == 0 || string(bytes.TrimSpace(data)) == "null" {
+			continue
+		}
+		meta := &metav1.PartialObjectMetadata{}
+		if err := json.Unmarshal(data, meta); err != nil {
+			return nil, fmt.Errorf("document %d is not a Kubernetes object: %v", i, err)
+		}
+		if meta.APIVersion != infrav1.GroupVersion.String() {
+			continue
+		}
+
+		var obj interface{}
+		switch meta.Kind {
+		case infrav1.NutanixClusterKind:
+			obj = &infrav1.NutanixCluster{}
+		case infrav1.NutanixMachineTemplateKind:
+			obj = &infrav1.NutanixMachineTemplate{}
+		default:
+			continue
+		}
+		manifestObj := manifestObject{
+			Source: fmt.Sprintf("%s#%d", source, i),
+			Kind:   meta.Kind,
+			Name:   meta.Name,
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(obj); err != nil {
+			manifestObj.DecodeErr = err
+		} else {
+			manifestObj.Object = obj
+		}
+		objects = append(objects, manifestObj)
+	}
+}
+
+// validate validates all objects. Resources referenced by name are only looked up if a client is given.
+func validate(ctx context.Context, client *nutanixClientV3.Client, objects []manifestObject) summary {
+	s := summary{
+		ReferencesVerified: client != nil,
+		Objects:            make([]objectResult, 0, len(objects)),
+	}
+	for _, obj := range objects {
+		result := objectResult{
+			Source: obj.Source,
+			Kind:   obj.Kind,
+			Name:   obj.Name,
+		}
+		if obj.DecodeErr != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("schema: %v", obj.DecodeErr))
+		} else {
+			allErrs, err := validateObject(ctx, client, obj.Object)
+			if err != nil {
+				result.Errors = append(result.Errors, err.Error())
+			}
+			for _, fieldErr := range allErrs {
+				result.Errors = append(result.Errors, fieldErr.Error())
+			}
+		}
+		result.Valid = len(result.Errors) == 0
+		if !result.Valid {
+			s.Invalid++
+		}
+		s.Objects = append(s.Objects, result)
+	}
+	return s
+}
+
+func validateObject(ctx context.Context, client *nutanixClientV3.Client, obj interface{}) (field.ErrorList, error) {
+	switch o := obj.(type) {
+	case *infrav1.NutanixCluster:
+		// Identifiers are normalized like by the defaulting webhook before they are validated
+		allErrs := controllers.DefaultFailureDomains(o)
+		allErrs = append(allErrs, controllers.ValidateNutanixClusterSpec(o)...)
+		var getClient func() (*nutanixClientV3.Client, error)
+		if client != nil {
+			getClient = func() (*nutanixClientV3.Client, error) {
+				return client, nil
+			}
+		}
+		fdErrs, err := controllers.ValidateFailureDomainReferences(ctx, getClient, o)
+		return append(allErrs, fdErrs...), err
+	case *infrav1.NutanixMachineTemplate:
+		specPath := field.NewPath("spec", "template", "spec")
+		allErrs := controllers.DefaultNutanixMachineSpec(specPath, &o.Spec.Template.Spec)
+		allErrs = append(allErrs, controllers.ValidateNutanixMachineSpec(specPath, &o.Spec.Template.Spec)...)
+		allErrs = append(allErrs, controllers.ValidateNutanixMachineReferences(ctx, client, specPath, &o.Spec.Template.Spec)...)
+		return allErrs, nil
+	default:
+		return nil, fmt.Errorf("unsupported object %T", obj)
+	}
+}
+
+func printSummary(w io.Writer, output string, s summary) error {
+	if output == outputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s)
+	}
+
+	var b strings.Builder
+	for _, result := range s.Objects {
+		status := "OK"
+		if !result.Valid {
+			status = "INVALID"
+		}
+		fmt.Fprintf(&b, "%-7s %s/%s (%s)\n", status, result.Kind, result.Name, result.Source)
+		for _, err := range result.Errors {
+			fmt.Fprintf(&b, "        - %s\n", err)
+		}
+	}
+	if !s.ReferencesVerified {
+		b.WriteString("Resources referenced by name were not looked up in Prism Central because no endpoint was given.\n")
+	}
+	fmt.Fprintf(&b, "%d object(s) validated, %d invalid\n", len(s.Objects), s.Invalid)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifest = `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+kind: NutanixCluster
+metadata:
+  name: cluster
+spec:
+  prismCentral:
+    address: prism-central.example.com
+    port: 9440
+  failureDomains:
+  - name: fd-1
+    cluster:
+      type: uuid
+      uuid: 00000000-0000-0000-0000-000000000001
+    subnets:
+    - type: name
+      name: subnet
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+kind: NutanixMachineTemplate
+metadata:
+  name: invalid-template
+spec:
+  template:
+    spec:
+      vcpusPerSocket: 1
+      vcpuSockets: 2
+      memorySize: 4Gi
+      systemDiskSize: 40Gi
+      image:
+        type: uuid
+        uuid: not-a-uuid
+      cluster:
+        type: name
+        name: pe
+      subnet:
+      - type: name
+        name: subnet
+---
+apiVersion: infrastructure.cluster.x-k8s.io/v1beta1
+kind: NutanixMachineTemplate
+metadata:
+  name: unknown-field
+spec:
+  template:
+    spec:
+      imageName: image
+`
+
+func TestDecodeManifest(t *testing.T) {
+	objects, err := decodeManifest("manifest.yaml", strings.NewReader(testManifest))
+	assert.NoError(t, err)
+	assert.Len(t, objects, 3)
+
+	assert.Equal(t, "NutanixCluster", objects[0].Kind)
+	assert.Equal(t, "cluster", objects[0].Name)
+	assert.Equal(t, "manifest.yaml#1", objects[0].Source)
+	assert.NotNil(t, objects[0].Object)
+	assert.NoError(t, objects[0].DecodeErr)
+
+	assert.Equal(t, "NutanixMachineTemplate", objects[1].Kind)
+	assert.NotNil(t, objects[1].Object)
+
+	assert.Equal(t, "unknown-field", objects[2].Name)
+	assert.Nil(t, objects[2].Object)
+	assert.Error(t, objects[2].DecodeErr)
+}
+
+func TestValidate(t *testing.T) {
+	objects, err := decodeManifest("manifest.yaml", strings.NewReader(testManifest))
+	assert.NoError(t, err)
+
+	s := validate(context.Background(), nil, objects)
+	assert.False(t, s.ReferencesVerified)
+	assert.Equal(t, 2, s.Invalid)
+	assert.Len(t, s.Objects, 3)
+	assert.True(t, s.Objects[0].Valid)
+	assert.False(t, s.Objects[1].Valid)
+	assert.Len(t, s.Objects[1].Errors, 1)
+	assert.Contains(t, s.Objects[1].Errors[0], "spec.template.spec.image.uuid")
+	assert.False(t, s.Objects[2].Valid)
+	assert.Contains(t, s.Objects[2].Errors[0], "schema")
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv("NUTANIX_ENDPOINT", "")
+
+	var stdout, stderr bytes.Buffer
+	exitCode := run(context.Background(), []string{"-output", "json", "-"}, strings.NewReader(testManifest), &stdout, &stderr)
+	assert.Equal(t, 1, exitCode)
+	s := summary{}
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &s))
+	assert.Equal(t, 2, s.Invalid)
+
+	stdout.Reset()
+	exitCode = run(context.Background(), []string{"-"}, strings.NewReader(strings.Split(testManifest, "---\n")[1]), &stdout, &stderr)
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, stdout.String(), "OK      NutanixCluster/cluster")
+	assert.Contains(t, stdout.String(), "1 object(s) validated, 0 invalid")
+
+	exitCode = run(context.Background(), nil, strings.NewReader(""), &stdout, &stderr)
+	assert.Equal(t, 2, exitCode)
+}
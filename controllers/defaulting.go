@@ -0,0 +1,166 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
+)
+
+// DefaultNutanixMachineSpec normalizes the cluster, subnet, image and project identifiers of a NutanixMachine spec,
+// including the subnets of the network interfaces. The memory size and vCPUs are set to the minimums of the boot type
+// if they are not set.
+// Errors are returned for identifiers whose type cannot be inferred.
+func DefaultNutanixMachineSpec(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	defaultMachineResources(spec)
+	// The image is optional if it is set in the machine defaults of the NutanixCluster
+	if !isEmptyResourceIdentifier(spec.Image) {
+		allErrs = append(allErrs, defaultResourceIdentifier(specPath.Child("image"), &spec.Image)...)
+	}
+	// The cluster is optional if the Machine is placed in a failure domain
+	if !isEmptyResourceIdentifier(spec.Cluster) {
+		allErrs = append(allErrs, defaultResourceIdentifier(specPath.Child("cluster"), &spec.Cluster)...)
+	}
+	for i := range spec.Subnets {
+		allErrs = append(allErrs, defaultResourceIdentifier(specPath.Child("subnet").Index(i), &spec.Subnets[i])...)
+	}
+	for i := range spec.NICs {
+		allErrs = append(allErrs, defaultResourceIdentifier(specPath.Child("nics").Index(i).Child("subnet"), &spec.NICs[i].Subnet)...)
+	}
+	if spec.Project != nil {
+		allErrs = append(allErrs, defaultResourceIdentifier(specPath.Child("project"), spec.Project)...)
+	}
+	if spec.Owner != nil {
+		allErrs = append(allErrs, defaultResourceIdentifier(specPath.Child("owner"), spec.Owner)...)
+	}
+	return allErrs
+}
+
+// defaultMachineResources sets the memory size and vCPUs that are not set to the minimums of the boot type. The vCPUs
+// are placed in a single socket if the number of sockets is not set.
+func defaultMachineResources(spec *infrav1.NutanixMachineSpec) {
+	minMemorySize, minVCPUs := getMachineResourceMinimums(spec)
+	if spec.MemorySize.IsZero() {
+		spec.MemorySize = minMemorySize
+	}
+	if spec.VCPUSockets == 0 {
+		spec.VCPUSockets = 1
+	}
+	if spec.VCPUsPerSocket == 0 {
+		// Round up so the VM gets at least the minimum number of vCPUs
+		spec.VCPUsPerSocket = (minVCPUs + spec.VCPUSockets - 1) / spec.VCPUSockets
+	}
+}
+
+// DefaultPrismCentral normalizes the Prism Central endpoint of a NutanixCluster: the https scheme is removed from the
+// address and the port defaults to 9440. Endpoints that cannot be normalized are left unchanged and rejected by the
+// validation.
+func DefaultPrismCentral(nutanixCluster *infrav1.NutanixCluster) {
+	prismCentral := nutanixCluster.Spec.PrismCentral
+	if prismCentral == nil || prismCentral.Address == "" {
+		return
+	}
+	normalized, err := nutanixClient.NormalizePrismEndpoint(*prismCentral)
+	if err != nil {
+		return
+	}
+	prismCentral.Address = normalized.Address
+	prismCentral.Port = normalized.Port
+}
+
+// DefaultFailureDomains normalizes the cluster and subnet identifiers of the failure domains of a NutanixCluster.
+// Errors are returned for identifiers whose type cannot be inferred.
+func DefaultFailureDomains(nutanixCluster *infrav1.NutanixCluster) field.ErrorList {
+	allErrs := field.ErrorList{}
+	fdPath := field.NewPath("spec", "failureDomains")
+	for i := range nutanixCluster.Spec.FailureDomains {
+		fd := &nutanixCluster.Spec.FailureDomains[i]
+		allErrs = append(allErrs, defaultResourceIdentifier(fdPath.Index(i).Child("cluster"), &fd.Cluster)...)
+		for j := range fd.Subnets {
+			allErrs = append(allErrs, defaultResourceIdentifier(fdPath.Index(i).Child("subnets").Index(j), &fd.Subnets[j])...)
+		}
+	}
+	return allErrs
+}
+
+// DefaultMachineDefaults normalizes the image, cluster, subnet and project identifiers of the machine defaults of a
+// NutanixCluster. Errors are returned for identifiers whose type cannot be inferred.
+func DefaultMachineDefaults(nutanixCluster *infrav1.NutanixCluster) field.ErrorList {
+	machineDefaults := nutanixCluster.Spec.MachineDefaults
+	if machineDefaults == nil {
+		return nil
+	}
+	allErrs := field.ErrorList{}
+	defaultsPath := field.NewPath("spec", "machineDefaults")
+	if machineDefaults.Image != nil {
+		allErrs = append(allErrs, defaultResourceIdentifier(defaultsPath.Child("image"), machineDefaults.Image)...)
+	}
+	if machineDefaults.Cluster != nil {
+		allErrs = append(allErrs, defaultResourceIdentifier(defaultsPath.Child("cluster"), machineDefaults.Cluster)...)
+	}
+	for i := range machineDefaults.Subnets {
+		allErrs = append(allErrs, defaultResourceIdentifier(defaultsPath.Child("subnets").Index(i), &machineDefaults.Subnets[i])...)
+	}
+	if machineDefaults.Project != nil {
+		allErrs = append(allErrs, defaultResourceIdentifier(defaultsPath.Child("project"), machineDefaults.Project)...)
+	}
+	return allErrs
+}
+
+// defaultResourceIdentifier trims the name and uuid of the identifier and infers the type of the identifier if it is
+// not set. The type can only be inferred if exactly one of name and uuid is set, or if only the category is set.
+func defaultResourceIdentifier(path *field.Path, identifier *infrav1.NutanixResourceIdentifier) field.ErrorList {
+	identifier.Name = trimIdentifierValue(identifier.Name)
+	identifier.UUID = trimIdentifierValue(identifier.UUID)
+	if identifier.Type != "" {
+		return nil
+	}
+	switch {
+	case identifier.Name != nil && identifier.UUID != nil:
+		return field.ErrorList{field.Invalid(path.Child("uuid"), *identifier.UUID, "uuid must not be set together with name if type is not set")}
+	case identifier.Name != nil:
+		identifier.Type = infrav1.NutanixIdentifierName
+	case identifier.UUID != nil:
+		identifier.Type = infrav1.NutanixIdentifierUUID
+	case identifier.Category != nil:
+		identifier.Type = infrav1.NutanixIdentifierCategory
+	default:
+		return field.ErrorList{field.Required(path, "either name or uuid must be set")}
+	}
+	return nil
+}
+
+// trimIdentifierValue returns the value without surrounding whitespace, or nil if the value is blank
+func trimIdentifierValue(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+func isEmptyResourceIdentifier(identifier infrav1.NutanixResourceIdentifier) bool {
+	return identifier.Type == "" && identifier.Name == nil && identifier.UUID == nil
+}
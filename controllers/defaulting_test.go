@@ -0,0 +1,285 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+func TestDefaultResourceIdentifier(t *testing.T) {
+	tests := []struct {
+		name          string
+		identifier    infrav1.NutanixResourceIdentifier
+		expected      infrav1.NutanixResourceIdentifier
+		expectedError string
+	}{
+		{
+			name:       "infers type name",
+			identifier: infrav1.NutanixResourceIdentifier{Name: pointer.String("image")},
+			expected:   infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("image")},
+		},
+		{
+			name:       "infers type uuid",
+			identifier: infrav1.NutanixResourceIdentifier{UUID: pointer.String(testImageUUID)},
+			expected:   infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: pointer.String(testImageUUID)},
+		},
+		{
+			name:       "infers type category",
+			identifier: infrav1.NutanixResourceIdentifier{Category: &infrav1.NutanixCategoryIdentifier{Key: "network", Value: "prod"}},
+			expected:   infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierCategory, Category: &infrav1.NutanixCategoryIdentifier{Key: "network", Value: "prod"}},
+		},
+		{
+			name:       "trims whitespace",
+			identifier: infrav1.NutanixResourceIdentifier{Name: pointer.String(" image\n")},
+			expected:   infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("image")},
+		},
+		{
+			name:       "ignores blank values",
+			identifier: infrav1.NutanixResourceIdentifier{Name: pointer.String("  "), UUID: pointer.String(testImageUUID)},
+			expected:   infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: pointer.String(testImageUUID)},
+		},
+		{
+			name:       "keeps set type",
+			identifier: infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, Name: pointer.String("image"), UUID: pointer.String(testImageUUID + " ")},
+			expected:   infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, Name: pointer.String("image"), UUID: pointer.String(testImageUUID)},
+		},
+		{
+			name:          "rejects name and uuid without type",
+			identifier:    infrav1.NutanixResourceIdentifier{Name: pointer.String("image"), UUID: pointer.String(testImageUUID)},
+			expectedError: "spec.image.uuid",
+		},
+		{
+			name:          "rejects neither name nor uuid",
+			identifier:    infrav1.NutanixResourceIdentifier{Name: pointer.String("")},
+			expectedError: "spec.image: Required value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			identifier := tt.identifier
+			allErrs := defaultResourceIdentifier(field.NewPath("spec", "image"), &identifier)
+			if tt.expectedError != "" {
+				g.Expect(allErrs).To(HaveLen(1))
+				g.Expect(allErrs[0].Error()).To(ContainSubstring(tt.expectedError))
+				return
+			}
+			g.Expect(allErrs).To(BeEmpty())
+			g.Expect(identifier).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestDefaultNutanixMachineSpec(t *testing.T) {
+	g := NewWithT(t)
+	spec := &infrav1.NutanixMachineSpec{
+		Image:   infrav1.NutanixResourceIdentifier{Name: pointer.String("image ")},
+		Cluster: infrav1.NutanixResourceIdentifier{UUID: pointer.String(testPEUUID)},
+		Subnets: []infrav1.NutanixResourceIdentifier{
+			{Name: pointer.String("subnet")},
+			{UUID: pointer.String(testSubnetUUID)},
+		},
+		Project: &infrav1.NutanixResourceIdentifier{Name: pointer.String("project")},
+	}
+	g.Expect(DefaultNutanixMachineSpec(field.NewPath("spec"), spec)).To(BeEmpty())
+	g.Expect(spec.Image).To(Equal(infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("image")}))
+	g.Expect(spec.Cluster.Type).To(Equal(infrav1.NutanixIdentifierUUID))
+	g.Expect(spec.Subnets[0].Type).To(Equal(infrav1.NutanixIdentifierName))
+	g.Expect(spec.Subnets[1].Type).To(Equal(infrav1.NutanixIdentifierUUID))
+	g.Expect(spec.Project.Type).To(Equal(infrav1.NutanixIdentifierName))
+
+	// The cluster is optional for Machines placed in failure domains
+	spec = &infrav1.NutanixMachineSpec{Image: infrav1.NutanixResourceIdentifier{Name: pointer.String("image")}}
+	g.Expect(DefaultNutanixMachineSpec(field.NewPath("spec"), spec)).To(BeEmpty())
+	g.Expect(spec.Cluster.Type).To(BeEmpty())
+
+	spec = &infrav1.NutanixMachineSpec{
+		Image:   infrav1.NutanixResourceIdentifier{Name: pointer.String("image"), UUID: pointer.String(testImageUUID)},
+		Subnets: []infrav1.NutanixResourceIdentifier{{}},
+		Project: &infrav1.NutanixResourceIdentifier{},
+	}
+	allErrs := DefaultNutanixMachineSpec(field.NewPath("spec"), spec)
+	g.Expect(allErrs).To(HaveLen(3))
+	g.Expect(allErrs[0].Field).To(Equal("spec.image.uuid"))
+	g.Expect(allErrs[1].Field).To(Equal("spec.subnet[0]"))
+	g.Expect(allErrs[2].Field).To(Equal("spec.project"))
+}
+
+func TestDefaultMachineResources(t *testing.T) {
+	tests := []struct {
+		name                   string
+		spec                   infrav1.NutanixMachineSpec
+		expectedMemorySize     string
+		expectedVCPUsPerSocket int32
+		expectedVCPUSockets    int32
+	}{
+		{
+			name:                   "legacy minimums",
+			expectedMemorySize:     "2Gi",
+			expectedVCPUsPerSocket: 1,
+			expectedVCPUSockets:    1,
+		},
+		{
+			name:                   "secure boot minimums",
+			spec:                   infrav1.NutanixMachineSpec{BootType: infrav1.NutanixBootTypeUEFI, SecureBoot: pointer.Bool(true)},
+			expectedMemorySize:     "4Gi",
+			expectedVCPUsPerSocket: 2,
+			expectedVCPUSockets:    1,
+		},
+		{
+			name:                   "secure boot vCPUs spread across set sockets",
+			spec:                   infrav1.NutanixMachineSpec{BootType: infrav1.NutanixBootTypeUEFI, SecureBoot: pointer.Bool(true), VCPUSockets: 2},
+			expectedMemorySize:     "4Gi",
+			expectedVCPUsPerSocket: 1,
+			expectedVCPUSockets:    2,
+		},
+		{
+			name: "keeps set values",
+			spec: infrav1.NutanixMachineSpec{
+				MemorySize:     resource.MustParse("8Gi"),
+				VCPUsPerSocket: 4,
+				VCPUSockets:    2,
+			},
+			expectedMemorySize:     "8Gi",
+			expectedVCPUsPerSocket: 4,
+			expectedVCPUSockets:    2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			spec := tt.spec
+			spec.Image = infrav1.NutanixResourceIdentifier{Name: pointer.String("image")}
+			g.Expect(DefaultNutanixMachineSpec(field.NewPath("spec"), &spec)).To(BeEmpty())
+			g.Expect(spec.MemorySize.Cmp(resource.MustParse(tt.expectedMemorySize))).To(BeZero())
+			g.Expect(spec.VCPUsPerSocket).To(Equal(tt.expectedVCPUsPerSocket))
+			g.Expect(spec.VCPUSockets).To(Equal(tt.expectedVCPUSockets))
+			g.Expect(ValidateNutanixMachineSpec(field.NewPath("spec"), &spec)).To(BeEmpty())
+		})
+	}
+}
+
+func TestDefaultPrismCentral(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     string
+		port        int32
+		wantAddress string
+		wantPort    int32
+	}{
+		{name: "missing port", address: "prism.example.com", wantAddress: "prism.example.com", wantPort: 9440},
+		{name: "explicit port", address: "prism.example.com", port: 9441, wantAddress: "prism.example.com", wantPort: 9441},
+		{name: "address with scheme", address: "https://prism.example.com:9441", wantAddress: "prism.example.com", wantPort: 9441},
+		{name: "invalid address is left unchanged", address: "http://prism.example.com", wantAddress: "http://prism.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			nutanixCluster := &infrav1.NutanixCluster{
+				Spec: infrav1.NutanixClusterSpec{
+					PrismCentral: &credentialTypes.NutanixPrismEndpoint{Address: tt.address, Port: tt.port},
+				},
+			}
+			DefaultPrismCentral(nutanixCluster)
+			g.Expect(nutanixCluster.Spec.PrismCentral.Address).To(Equal(tt.wantAddress))
+			g.Expect(nutanixCluster.Spec.PrismCentral.Port).To(Equal(tt.wantPort))
+		})
+	}
+}
+
+func TestDefaultFailureDomains(t *testing.T) {
+	g := NewWithT(t)
+	nutanixCluster := &infrav1.NutanixCluster{
+		Spec: infrav1.NutanixClusterSpec{
+			FailureDomains: []infrav1.NutanixFailureDomainConfig{
+				{
+					Name:    "fd-1",
+					Cluster: infrav1.NutanixResourceIdentifier{Name: pointer.String(" pe ")},
+					Subnets: []infrav1.NutanixResourceIdentifier{{UUID: pointer.String(testSubnetUUID)}},
+				},
+				{
+					Name:    "fd-2",
+					Cluster: infrav1.NutanixResourceIdentifier{},
+					Subnets: []infrav1.NutanixResourceIdentifier{{Name: pointer.String("subnet")}},
+				},
+			},
+		},
+	}
+	allErrs := DefaultFailureDomains(nutanixCluster)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.failureDomains[1].cluster"))
+	fd := nutanixCluster.Spec.FailureDomains[0]
+	g.Expect(fd.Cluster).To(Equal(infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("pe")}))
+	g.Expect(fd.Subnets[0].Type).To(Equal(infrav1.NutanixIdentifierUUID))
+	g.Expect(nutanixCluster.Spec.FailureDomains[1].Subnets[0].Type).To(Equal(infrav1.NutanixIdentifierName))
+}
+
+func TestDefaultMachineDefaults(t *testing.T) {
+	g := NewWithT(t)
+	nutanixCluster := &infrav1.NutanixCluster{
+		Spec: infrav1.NutanixClusterSpec{
+			MachineDefaults: &infrav1.NutanixMachineDefaults{
+				Image:   &infrav1.NutanixResourceIdentifier{Name: pointer.String(" image ")},
+				Cluster: &infrav1.NutanixResourceIdentifier{},
+				Subnets: []infrav1.NutanixResourceIdentifier{{UUID: pointer.String(testSubnetUUID)}},
+				Project: &infrav1.NutanixResourceIdentifier{Name: pointer.String("project")},
+			},
+		},
+	}
+	allErrs := DefaultMachineDefaults(nutanixCluster)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.machineDefaults.cluster"))
+	machineDefaults := nutanixCluster.Spec.MachineDefaults
+	g.Expect(*machineDefaults.Image).To(Equal(infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("image")}))
+	g.Expect(machineDefaults.Subnets[0].Type).To(Equal(infrav1.NutanixIdentifierUUID))
+	g.Expect(machineDefaults.Project.Type).To(Equal(infrav1.NutanixIdentifierName))
+
+	g.Expect(DefaultMachineDefaults(&infrav1.NutanixCluster{})).To(BeEmpty())
+}
+
+func TestWebhooksDefault(t *testing.T) {
+	g := NewWithT(t)
+	template := newTestNutanixMachineTemplate()
+	template.Spec.Template.Spec.Image = infrav1.NutanixResourceIdentifier{Name: pointer.String("image")}
+	g.Expect(NewNutanixMachineTemplateValidator().Default(context.Background(), template)).To(Succeed())
+	g.Expect(template.Spec.Template.Spec.Image.Type).To(Equal(infrav1.NutanixIdentifierName))
+
+	template.Spec.Template.Spec.Image = infrav1.NutanixResourceIdentifier{Name: pointer.String("image"), UUID: pointer.String(testImageUUID)}
+	err := NewNutanixMachineTemplateValidator().Default(context.Background(), template)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("spec.template.spec.image"))
+
+	nutanixMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: infrav1.NutanixMachineSpec{
+			Image: infrav1.NutanixResourceIdentifier{UUID: pointer.String(testImageUUID)},
+		},
+	}
+	g.Expect((&NutanixMachineValidator{}).Default(context.Background(), nutanixMachine)).To(Succeed())
+	g.Expect(nutanixMachine.Spec.Image.Type).To(Equal(infrav1.NutanixIdentifierUUID))
+}
@@ -17,10 +17,21 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
@@ -28,31 +39,258 @@ import (
 	"github.com/nutanix-cloud-native/prism-go-client/utils"
 	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	coreinformers "k8s.io/client-go/informers/core/v1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 )
 
 const (
 	providerIdPrefix = "nutanix://"
 
+	// nutanixFailureDomainNameHashLength is the length of the hash suffix of the names of NutanixFailureDomain objects
+	nutanixFailureDomainNameHashLength = 10
+
 	taskSucceededMessage = "SUCCEEDED"
 	serviceNamePECluster = "AOS"
 
 	subnetTypeOverlay = "OVERLAY"
 
 	gpuUnused = "UNUSED"
+
+	vmPowerStateOn            = "ON"
+	vmPowerStateOff           = "OFF"
+	vmPowerStateMechanismACPI = "ACPI"
+
+	vmKind               = "vm"
+	storageContainerKind = "storage_container"
+
+	volumeGroupAttachmentTimeout = 5 * time.Minute
+
+	// formats of bootstrap data secrets as set by the bootstrap providers
+	secretFormatCloudConfig = "cloud-config"
+	secretFormatIgnition    = "ignition"
+
+	// maxUserDataSize is the maximum size of the base64 encoded user data of a VM accepted by Prism Central
+	maxUserDataSize = 32 * 1024
+
+	// maxVMDescriptionLength is the maximum length of the description of a VM accepted by Prism Central
+	maxVMDescriptionLength = 1000
+
+	// resolvedDNSDropInPath is the systemd-resolved configuration file holding the name servers and search domains
+	// of a NutanixMachine
+	resolvedDNSDropInPath = "/etc/systemd/resolved.conf.d/capx-dns.conf"
+)
+
+// isReconciliationPaused returns true if the CAPI Cluster is paused, or one of the objects has the CAPI paused
+// annotation or the Nutanix specific paused annotation
+func isReconciliationPaused(cluster *capiv1.Cluster, objects ...metav1.Object) bool {
+	if cluster.Spec.Paused {
+		return true
+	}
+	for _, o := range objects {
+		if annotations.HasPaused(o) {
+			return true
+		}
+		if _, ok := o.GetAnnotations()[infrav1.PausedAnnotation]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// markReconciliationPaused sets the Paused condition on the object. The object is only patched if the condition was
+// not set yet, so no further writes happen while the reconciliation stays paused.
+func markReconciliationPaused(ctx context.Context, c client.Client, obj interface {
+	client.Object
+	conditions.Setter
+}) error {
+	if conditions.IsTrue(obj, infrav1.PausedCondition) {
+		return nil
+	}
+	patchHelper, err := patch.NewHelper(obj, c)
+	if err != nil {
+		return err
+	}
+	conditions.MarkTrue(obj, infrav1.PausedCondition)
+	return patchHelper.Patch(ctx, obj, patch.WithOwnedConditions{Conditions: []capiv1.ConditionType{infrav1.PausedCondition}})
+}
+
+// withReconcileTimeout returns a context that is canceled once the reconcile timeout expired.
+// The context has no deadline if the timeout is 0.
+func withReconcileTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// checkReconcileTimeout returns a context.DeadlineExceeded error if the reconcile timeout of the context expired, as
+// the reconcile may have been aborted regardless of the returned error. Otherwise the error is returned unchanged.
+func checkReconcileTimeout(ctx context.Context, timeout time.Duration, err error) error {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	timeoutErr := fmt.Errorf("reconcile did not complete within %s: %w", timeout, context.DeadlineExceeded)
+	if err == nil {
+		return timeoutErr
+	}
+	return fmt.Errorf("%w: %v", timeoutErr, err)
+}
+
+// jitterRequeue adds a random jitter of up to the factor times the requeue interval to the RequeueAfter of the result,
+// so objects that were requeued at the same time are not reconciled at the same instant again. The result is returned
+// unchanged if the factor is 0.
+func jitterRequeue(result reconcile.Result, factor float64) reconcile.Result {
+	if factor <= 0 || result.RequeueAfter <= 0 {
+		return result
+	}
+	result.RequeueAfter = wait.Jitter(result.RequeueAfter, factor)
+	return result
+}
+
+// requeueCause classifies the cause for which a reconcile is requeued
+type requeueCause int
+
+const (
+	// requeueForTransientError is the cause of reconciles that are retried after a short interval, e.g. while another
+	// task of a VM is in progress
+	requeueForTransientError requeueCause = iota
+	// requeueForExternalCondition is the cause of reconciles that wait for a condition outside of the provider, e.g.
+	// the bootstrap data or the Node of a Machine. The requeue interval grows the longer the condition is waited for.
+	requeueForExternalCondition
+	// requeueForTerminalError is the cause of reconciles that cannot succeed without a change of the object. They are
+	// not requeued.
+	requeueForTerminalError
+)
+
+const (
+	// transientErrorRequeueInterval is the requeue interval of transient errors
+	transientErrorRequeueInterval = 5 * time.Second
+	// externalConditionMinRequeueInterval is the requeue interval of external conditions that were just started to
+	// be waited for
+	externalConditionMinRequeueInterval = 10 * time.Second
+	// externalConditionMaxRequeueInterval is the maximum requeue interval of external conditions
+	externalConditionMaxRequeueInterval = 2 * time.Minute
 )
 
-// CreateNutanixClient creates a new Nutanix client from the environment
+// requeueFor returns the result of a reconcile requeued for the cause. The requeue interval of an external condition
+// starts at externalConditionMinRequeueInterval and doubles with the time the condition has been waited for, up to
+// externalConditionMaxRequeueInterval.
+func requeueFor(cause requeueCause, waited time.Duration) reconcile.Result {
+	switch cause {
+	case requeueForTransientError:
+		return reconcile.Result{RequeueAfter: transientErrorRequeueInterval}
+	case requeueForExternalCondition:
+		interval := externalConditionMinRequeueInterval
+		for interval < waited && interval < externalConditionMaxRequeueInterval {
+			interval *= 2
+		}
+		if interval > externalConditionMaxRequeueInterval {
+			interval = externalConditionMaxRequeueInterval
+		}
+		return reconcile.Result{RequeueAfter: interval}
+	default:
+		return reconcile.Result{}
+	}
+}
+
+// conditionAge returns the time since the last transition of the condition of the object, or 0 if the object does
+// not have the condition
+func conditionAge(obj conditions.Getter, conditionType capiv1.ConditionType) time.Duration {
+	condition := conditions.Get(obj, conditionType)
+	if condition == nil || condition.LastTransitionTime.IsZero() {
+		return 0
+	}
+	return time.Since(condition.LastTransitionTime.Time)
+}
+
+// transientErrorMessages are the messages of errors returned if Prism Central is temporarily unable to serve requests.
+// The Prism client does not return the status code of failed requests, so the status texts are matched.
+var transientErrorMessages = []string{
+	"Internal Server Error",
+	"Bad Gateway",
+	"Service Unavailable",
+	"Gateway Timeout",
+	"Too Many Requests",
+	"invalid Nutanix credentials",
+}
+
+// isTransientError returns true if the error is expected to be resolved without a change of the object, e.g. since
+// Prism Central cannot be reached, is overloaded, or the credentials are being rotated
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if nutanixClientHelper.IsConnectionError(err) {
+		return true
+	}
+	msg := err.Error()
+	// Errors are often wrapped without %w, so their messages are matched as well
+	for _, transientErr := range []error{context.Canceled, context.DeadlineExceeded, errLeadershipLost} {
+		if errors.Is(err, transientErr) || strings.Contains(msg, transientErr.Error()) {
+			return true
+		}
+	}
+	for _, transientErrorMessage := range transientErrorMessages {
+		if strings.Contains(msg, transientErrorMessage) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateNutanixClient returns the cached Nutanix client of the cluster or creates a new Nutanix client from the environment
 func CreateNutanixClient(ctx context.Context, secretInformer coreinformers.SecretInformer, cmInformer coreinformers.ConfigMapInformer, nutanixCluster *infrav1.NutanixCluster) (*nutanixClientV3.Client, error) {
 	log := ctrl.LoggerFrom(ctx)
-	log.V(1).Info("creating nutanix client")
+	removed, err := nutanixClientHelper.RefreshCredentialFile(nutanixCluster)
+	if err != nil {
+		log.Error(err, "error reading credential file")
+		return nil, err
+	}
+	if removed > 0 {
+		log.Info(fmt.Sprintf("content of credential file for cluster %s changed. Invalidated %d cached nutanix clients", nutanixCluster.Name, removed))
+	}
 	helper, err := nutanixClientHelper.NewNutanixClientHelper(secretInformer, cmInformer)
 	if err != nil {
 		log.Error(err, "error creating nutanix client helper")
 		return nil, err
 	}
-	return helper.GetClientFromEnvironment(ctx, nutanixCluster)
+	// Credentials that cannot be read are left to the creation of the client to report
+	credentialHash, err := helper.GetCredentialHash(nutanixCluster)
+	if err != nil {
+		log.V(1).Info(fmt.Sprintf("failed to read the credentials of cluster %s: %v", nutanixCluster.Name, err))
+	}
+	if client, ok := nutanixClientHelper.NutanixClientCache.Get(nutanixCluster, credentialHash); ok {
+		log.V(1).Info("using cached nutanix client")
+		return client, nil
+	}
+	log.V(1).Info("creating nutanix client")
+	// Keep the endpoint as defined on the cluster since creating the client defaults its namespaces
+	prismCentral := nutanixCluster.Spec.PrismCentral.DeepCopy()
+	client, err := helper.GetClientFromEnvironment(ctx, nutanixCluster)
+	if err != nil {
+		return nil, err
+	}
+	nutanixClientHelper.NutanixClientCache.Set(nutanixCluster, prismCentral, credentialHash, client)
+	// The version is informational, clients are used even if it cannot be detected
+	version, err := nutanixClientHelper.GetPrismCentralVersion(ctx, client)
+	if err != nil {
+		log.Info(fmt.Sprintf("failed to detect the version of Prism Central of cluster %s: %v", nutanixCluster.Name, err))
+	} else {
+		log.Info(fmt.Sprintf("detected Prism Central version %s for cluster %s", version, nutanixCluster.Name))
+		nutanixClientHelper.NutanixClientCache.SetPrismCentralVersion(nutanixCluster, version)
+	}
+	return client, nil
 }
 
 // DeleteVM deletes a VM and is invoked by the NutanixMachineReconciler
@@ -76,6 +314,279 @@ func DeleteVM(ctx context.Context, client *nutanixClientV3.Client, vmName, vmUUI
 	return deleteTaskUUID, nil
 }
 
+// ShutdownVM requests an ACPI shutdown of the guest OS of a VM and returns the UUID of the update task
+func ShutdownVM(ctx context.Context, client *nutanixClientV3.Client, vm *nutanixClientV3.VMIntentResponse) (string, error) {
+	log := ctrl.LoggerFrom(ctx)
+	if vm.Metadata == nil || vm.Metadata.UUID == nil || vm.Spec == nil || vm.Spec.Resources == nil {
+		return "", fmt.Errorf("cannot shut down VM without metadata UUID and spec resources")
+	}
+	vmUUID := *vm.Metadata.UUID
+
+	log.Info(fmt.Sprintf("Requesting guest shutdown of VM with UUID: %s", vmUUID))
+	vm.Spec.Resources.PowerState = utils.StringPtr(vmPowerStateOff)
+	vm.Spec.Resources.PowerStateMechanism = &nutanixClientV3.VMPowerStateMechanism{
+		Mechanism: utils.StringPtr(vmPowerStateMechanismACPI),
+	}
+	vmUpdateResponse, err := client.V3.UpdateVM(ctx, vmUUID, &nutanixClientV3.VMIntentInput{
+		Metadata: vm.Metadata,
+		Spec:     vm.Spec,
+	})
+	if err != nil {
+		log.Error(err, fmt.Sprintf("error shutting down vm with UUID %s", vmUUID))
+		return "", err
+	}
+	return GetTaskUUIDFromVM(vmUpdateResponse)
+}
+
+// UpdateVMDescription sets the description of a VM and returns the UUID of the update task
+func UpdateVMDescription(ctx context.Context, client *nutanixClientV3.Client, vm *nutanixClientV3.VMIntentResponse, description string) (string, error) {
+	if vm.Metadata == nil || vm.Metadata.UUID == nil || vm.Spec == nil {
+		return "", fmt.Errorf("cannot update description of VM without metadata UUID and spec")
+	}
+	vm.Spec.Description = utils.StringPtr(description)
+	vmUpdateResponse, err := client.V3.UpdateVM(ctx, *vm.Metadata.UUID, &nutanixClientV3.VMIntentInput{
+		Metadata: vm.Metadata,
+		Spec:     vm.Spec,
+	})
+	if err != nil {
+		return "", err
+	}
+	return GetTaskUUIDFromVM(vmUpdateResponse)
+}
+
+// UpdateVMName sets the name of a VM and returns the UUID of the update task
+func UpdateVMName(ctx context.Context, client *nutanixClientV3.Client, vm *nutanixClientV3.VMIntentResponse, name string) (string, error) {
+	if vm.Metadata == nil || vm.Metadata.UUID == nil || vm.Spec == nil {
+		return "", fmt.Errorf("cannot update name of VM without metadata UUID and spec")
+	}
+	vm.Spec.Name = utils.StringPtr(name)
+	vmUpdateResponse, err := client.V3.UpdateVM(ctx, *vm.Metadata.UUID, &nutanixClientV3.VMIntentInput{
+		Metadata: vm.Metadata,
+		Spec:     vm.Spec,
+	})
+	if err != nil {
+		return "", err
+	}
+	return GetTaskUUIDFromVM(vmUpdateResponse)
+}
+
+// UpdateVMGuestCustomization replaces the guest customization of a VM and returns the UUID of the update task. The
+// guest customization is only applied when the VM is powered on for the first time.
+func UpdateVMGuestCustomization(ctx context.Context, client *nutanixClientV3.Client, vm *nutanixClientV3.VMIntentResponse, guestCustomization *nutanixClientV3.GuestCustomization) (string, error) {
+	if vm.Metadata == nil || vm.Metadata.UUID == nil || vm.Spec == nil || vm.Spec.Resources == nil {
+		return "", fmt.Errorf("cannot update guest customization of VM without metadata UUID and resources")
+	}
+	vm.Spec.Resources.GuestCustomization = guestCustomization
+	vmUpdateResponse, err := client.V3.UpdateVM(ctx, *vm.Metadata.UUID, &nutanixClientV3.VMIntentInput{
+		Metadata: vm.Metadata,
+		Spec:     vm.Spec,
+	})
+	if err != nil {
+		return "", err
+	}
+	return GetTaskUUIDFromVM(vmUpdateResponse)
+}
+
+// UpdateVMCategories replaces the categories of a VM and returns the UUID of the update task
+func UpdateVMCategories(ctx context.Context, client *nutanixClientV3.Client, vm *nutanixClientV3.VMIntentResponse, categories map[string]string) (string, error) {
+	if vm.Metadata == nil || vm.Metadata.UUID == nil || vm.Spec == nil {
+		return "", fmt.Errorf("cannot update categories of VM without metadata UUID and spec")
+	}
+	vm.Metadata.Categories = categories
+	vmUpdateResponse, err := client.V3.UpdateVM(ctx, *vm.Metadata.UUID, &nutanixClientV3.VMIntentInput{
+		Metadata: vm.Metadata,
+		Spec:     vm.Spec,
+	})
+	if err != nil {
+		return "", err
+	}
+	return GetTaskUUIDFromVM(vmUpdateResponse)
+}
+
+// buildVMCategories returns the categories of a VM with all categories except the ownership categories of the cluster
+// and the machine role categories replaced by the given additional categories. The ownership and machine role
+// categories are kept as they are, and the current ownership categories are restored if restoreOwnership is set.
+func buildVMCategories(current, additionalCategories map[string]string, clusterName string, restoreOwnership bool) map[string]string {
+	managedKeys := map[string]bool{
+		infrav1.DefaultCAPICategoryKeyForMachineRole:       true,
+		infrav1.DefaultCAPICategoryKeyForMachineDeployment: true,
+	}
+	for _, ci := range append(GetDefaultCAPICategoryIdentifiers(clusterName), GetObsoleteDefaultCAPICategoryIdentifiers(clusterName)...) {
+		managedKeys[ci.Key] = true
+	}
+	categories := make(map[string]string, len(additionalCategories)+len(managedKeys))
+	for key, value := range current {
+		if managedKeys[key] {
+			categories[key] = value
+		}
+	}
+	for key, value := range additionalCategories {
+		if !managedKeys[key] {
+			categories[key] = value
+		}
+	}
+	if restoreOwnership {
+		for _, ci := range GetDefaultCAPICategoryIdentifiers(clusterName) {
+			categories[ci.Key] = ci.Value
+		}
+	}
+	return categories
+}
+
+// categoriesEqual returns true if both category maps hold the same keys and values
+func categoriesEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if bValue, ok := b[key]; !ok || bValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// GetVMSystemDisk returns the system disk of a VM, which is the disk cloned from the image of the VM or a seed disk of
+// the image. Returns nil if the VM has no such disk.
+func GetVMSystemDisk(vm *nutanixClientV3.VMIntentResponse) *nutanixClientV3.VMDisk {
+	if vm.Spec == nil || vm.Spec.Resources == nil {
+		return nil
+	}
+	for _, disk := range vm.Spec.Resources.DiskList {
+		if disk == nil || disk.DataSourceReference == nil || disk.VolumeGroupReference != nil {
+			continue
+		}
+		if kind := utils.StringValue(disk.DataSourceReference.Kind); kind != "image" && kind != "vm_disk" {
+			continue
+		}
+		if disk.DeviceProperties != nil && utils.StringValue(disk.DeviceProperties.DeviceType) != "" && utils.StringValue(disk.DeviceProperties.DeviceType) != "DISK" {
+			continue
+		}
+		return disk
+	}
+	return nil
+}
+
+// getVMDiskSizeMib returns the size of a VM disk in MiB
+func getVMDiskSizeMib(disk *nutanixClientV3.VMDisk) int64 {
+	if disk.DiskSizeMib != nil {
+		return *disk.DiskSizeMib
+	}
+	if disk.DiskSizeBytes != nil {
+		return *disk.DiskSizeBytes / (1024 * 1024)
+	}
+	return 0
+}
+
+// UpdateVMSystemDiskSize sets the size of the system disk of a VM and returns the UUID of the update task
+func UpdateVMSystemDiskSize(ctx context.Context, client *nutanixClientV3.Client, vm *nutanixClientV3.VMIntentResponse, diskSizeMib int64) (string, error) {
+	if vm.Metadata == nil || vm.Metadata.UUID == nil || vm.Spec == nil {
+		return "", fmt.Errorf("cannot update system disk of VM without metadata UUID and spec")
+	}
+	systemDisk := GetVMSystemDisk(vm)
+	if systemDisk == nil {
+		return "", fmt.Errorf("VM with UUID %s has no system disk", *vm.Metadata.UUID)
+	}
+	systemDisk.DiskSizeMib = utils.Int64Ptr(diskSizeMib)
+	// The size in bytes takes precedence over the size in MiB
+	systemDisk.DiskSizeBytes = nil
+	vmUpdateResponse, err := client.V3.UpdateVM(ctx, *vm.Metadata.UUID, &nutanixClientV3.VMIntentInput{
+		Metadata: vm.Metadata,
+		Spec:     vm.Spec,
+	})
+	if err != nil {
+		return "", err
+	}
+	return GetTaskUUIDFromVM(vmUpdateResponse)
+}
+
+// buildVMDescription returns the description of the VM of a Machine identifying the CAPI objects owning the VM
+func buildVMDescription(namespace, clusterName, machineName string) string {
+	return fmt.Sprintf("%s. Namespace: %s, Cluster: %s, Machine: %s", infrav1.DefaultCAPICategoryDescription, namespace, clusterName, machineName)
+}
+
+// buildVMDescriptionWithAnnotations returns the description of the VM of a Machine identifying the CAPI objects owning
+// the VM, followed by the values of the annotations of the Machine with the given prefix. Annotations are ordered by
+// key and listed as "<key without prefix>: <value>". Annotations are ignored if the prefix is empty. The description
+// is truncated to the maximum VM description length of Prism Central.
+func buildVMDescriptionWithAnnotations(namespace, clusterName, machineName string, annotations map[string]string, prefix string) string {
+	description := buildVMDescription(namespace, clusterName, machineName)
+	if prefix == "" {
+		return description
+	}
+	keys := make([]string, 0)
+	for key, value := range annotations {
+		if strings.HasPrefix(key, prefix) && strings.TrimSpace(value) != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return description
+	}
+	sort.Strings(keys)
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.Join(strings.Fields(annotations[key]), " ")
+		if name := strings.TrimPrefix(key, prefix); name != "" {
+			value = fmt.Sprintf("%s: %s", name, value)
+		}
+		entries = append(entries, value)
+	}
+	return truncateVMDescription(fmt.Sprintf("%s. %s", description, strings.Join(entries, ", ")))
+}
+
+// truncateVMDescription truncates the description to the maximum VM description length of Prism Central. Truncated
+// descriptions end with an ellipsis and are never cut within a multi-byte character.
+func truncateVMDescription(description string) string {
+	if len(description) <= maxVMDescriptionLength {
+		return description
+	}
+	const ellipsis = "..."
+	cut := maxVMDescriptionLength - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(description[cut]) {
+		cut--
+	}
+	return description[:cut] + ellipsis
+}
+
+// isVMDescriptionOfMachine returns true if the description of a VM identifies the given Machine, with or without
+// annotations appended to it
+func isVMDescriptionOfMachine(description, namespace, clusterName, machineName string) bool {
+	machineDescription := buildVMDescription(namespace, clusterName, machineName)
+	return description == machineDescription || strings.HasPrefix(description, machineDescription+". ")
+}
+
+// IsVMPoweredOff returns true if the power state of the VM is OFF
+func IsVMPoweredOff(vm *nutanixClientV3.VMIntentResponse) bool {
+	if vm.Status == nil || vm.Status.Resources == nil || vm.Status.Resources.PowerState == nil {
+		return false
+	}
+	return *vm.Status.Resources.PowerState == vmPowerStateOff
+}
+
+// GetVMClusterUUID returns the UUID of the Prism Element cluster the VM runs on, or an empty string if it is not known
+// yet. The observed cluster of the VM takes precedence over the cluster of its spec.
+func GetVMClusterUUID(vm *nutanixClientV3.VMIntentResponse) string {
+	if vm == nil {
+		return ""
+	}
+	if vm.Status != nil && vm.Status.ClusterReference != nil && utils.StringValue(vm.Status.ClusterReference.UUID) != "" {
+		return *vm.Status.ClusterReference.UUID
+	}
+	if vm.Spec != nil && vm.Spec.ClusterReference != nil {
+		return utils.StringValue(vm.Spec.ClusterReference.UUID)
+	}
+	return ""
+}
+
+// GetVMPowerState returns the power state of the VM, or an empty string if it is not known yet
+func GetVMPowerState(vm *nutanixClientV3.VMIntentResponse) string {
+	if vm == nil || vm.Status == nil || vm.Status.Resources == nil {
+		return ""
+	}
+	return utils.StringValue(vm.Status.Resources.PowerState)
+}
+
 // FindVMByUUID retrieves the VM with the given vm UUID. Returns nil if not found
 func FindVMByUUID(ctx context.Context, client *nutanixClientV3.Client, uuid string) (*nutanixClientV3.VMIntentResponse, error) {
 	log := ctrl.LoggerFrom(ctx)
@@ -144,7 +655,8 @@ func FindVM(ctx context.Context, client *nutanixClientV3.Client, nutanixMachine
 		// Now, we create VMs with the same name as the Machine name in line with other CAPI providers.
 		// This check is to ensure that we are deleting the correct VM for both cases as older CAPX VMs
 		// will have the NutanixMachine name as the VM name.
-		if *vm.Spec.Name != vmName && *vm.Spec.Name != nutanixMachine.Name {
+		// Adopted VMs keep their name.
+		if vmUUID != nutanixMachine.Spec.ExistingVMUUID && *vm.Spec.Name != vmName && *vm.Spec.Name != nutanixMachine.Name {
 			return nil, fmt.Errorf("found VM with UUID %s but name %s did not match %s", vmUUID, *vm.Spec.Name, vmName)
 		}
 		return vm, nil
@@ -165,22 +677,20 @@ func FindVMByName(ctx context.Context, client *nutanixClientV3.Client, vmName st
 	log := ctrl.LoggerFrom(ctx)
 	log.Info(fmt.Sprintf("Checking if VM with name %s exists.", vmName))
 
-	res, err := client.V3.ListVM(ctx, &nutanixClientV3.DSMetadata{
-		Filter: utils.StringPtr(fmt.Sprintf("vm_name==%s", vmName)),
-	})
+	vms, err := nutanixClientHelper.ListAllVMs(ctx, client, fmt.Sprintf("vm_name==%s", vmName))
 	if err != nil {
 		return nil, err
 	}
 
-	if len(res.Entities) > 1 {
-		return nil, fmt.Errorf("error: found more than one (%v) vms with name %s", len(res.Entities), vmName)
+	if len(vms) > 1 {
+		return nil, fmt.Errorf("error: found more than one (%v) vms with name %s", len(vms), vmName)
 	}
 
-	if len(res.Entities) == 0 {
+	if len(vms) == 0 {
 		return nil, nil
 	}
 
-	return FindVMByUUID(ctx, client, *res.Entities[0].Metadata.UUID)
+	return FindVMByUUID(ctx, client, *vms[0].Metadata.UUID)
 }
 
 // GetPEUUID returns the UUID of the Prism Element cluster with the given name
@@ -201,13 +711,13 @@ func GetPEUUID(ctx context.Context, client *nutanixClientV3.Client, peName, peUU
 		return *peIntentResponse.Metadata.UUID, nil
 	} else if peName != nil && *peName != "" {
 		filter := getFilterForName(*peName)
-		responsePEs, err := client.V3.ListAllCluster(ctx, filter)
+		responsePEs, err := nutanixClientHelper.ListAllClusters(ctx, client, filter)
 		if err != nil {
 			return "", err
 		}
 		// Validate filtered PEs
 		foundPEs := make([]*nutanixClientV3.ClusterIntentResponse, 0)
-		for _, s := range responsePEs.Entities {
+		for _, s := range responsePEs {
 			peSpec := s.Spec
 			if *peSpec.Name == *peName && hasPEClusterServiceEnabled(s, serviceNamePECluster) {
 				foundPEs = append(foundPEs, s)
@@ -225,12 +735,20 @@ func GetPEUUID(ctx context.Context, client *nutanixClientV3.Client, peName, peUU
 	return "", fmt.Errorf("failed to retrieve Prism Element cluster by name or uuid. Verify input parameters")
 }
 
-// GetMibValueOfQuantity returns the given quantity value in Mib
+// GetMibValueOfQuantity returns the given quantity value in Mib. Values that are not a whole number of Mib are
+// rounded up, so VMs never get less memory or disk space than requested.
 func GetMibValueOfQuantity(quantity resource.Quantity) int64 {
-	return quantity.Value() / (1024 * 1024)
+	const mib = 1024 * 1024
+	value := quantity.Value()
+	if value <= 0 {
+		return 0
+	}
+	return (value + mib - 1) / mib
 }
 
-func CreateSystemDiskSpec(imageUUID string, systemDiskSize int64) (*nutanixClientV3.VMDisk, error) {
+// CreateSystemDiskSpec returns the system disk of a VM cloned from the image, attached to the given bus. The disk is
+// attached to the SCSI bus if no bus is set.
+func CreateSystemDiskSpec(imageUUID string, systemDiskSize int64, bus infrav1.NutanixDiskBus) (*nutanixClientV3.VMDisk, error) {
 	if imageUUID == "" {
 		return nil, fmt.Errorf("image UUID must be set when creating system disk")
 	}
@@ -244,9 +762,253 @@ func CreateSystemDiskSpec(imageUUID string, systemDiskSize int64) (*nutanixClien
 		},
 		DiskSizeMib: utils.Int64Ptr(systemDiskSize),
 	}
+	if bus == "" {
+		bus = infrav1.NutanixDiskBusSCSI
+	}
+	systemDisk.DeviceProperties = &nutanixClientV3.VMDiskDeviceProperties{
+		DeviceType: utils.StringPtr("DISK"),
+		DiskAddress: &nutanixClientV3.DiskAddress{
+			AdapterType: utils.StringPtr(string(bus)),
+		},
+	}
 	return systemDisk, nil
 }
 
+// CreateBootstrapISODiskSpec returns a SATA CD-ROM of a VM holding the image of the bootstrap ISO. The CD-ROM is not
+// attached to the IDE bus, which is used by the CD-ROM of the guest customization.
+func CreateBootstrapISODiskSpec(imageUUID string) *nutanixClientV3.VMDisk {
+	return &nutanixClientV3.VMDisk{
+		DataSourceReference: &nutanixClientV3.Reference{
+			Kind: utils.StringPtr("image"),
+			UUID: utils.StringPtr(imageUUID),
+		},
+		DeviceProperties: &nutanixClientV3.VMDiskDeviceProperties{
+			DeviceType: utils.StringPtr("CDROM"),
+			DiskAddress: &nutanixClientV3.DiskAddress{
+				AdapterType: utils.StringPtr(string(infrav1.NutanixDiskBusSATA)),
+			},
+		},
+	}
+}
+
+// getVMBootstrapISODisks returns the CD-ROMs of a VM holding an image
+func getVMBootstrapISODisks(vm *nutanixClientV3.VMIntentResponse) []*nutanixClientV3.VMDisk {
+	if vm.Spec == nil || vm.Spec.Resources == nil {
+		return nil
+	}
+	disks := make([]*nutanixClientV3.VMDisk, 0)
+	for _, disk := range vm.Spec.Resources.DiskList {
+		if disk == nil || disk.DataSourceReference == nil || utils.StringValue(disk.DataSourceReference.Kind) != "image" {
+			continue
+		}
+		if disk.DeviceProperties == nil || utils.StringValue(disk.DeviceProperties.DeviceType) != "CDROM" {
+			continue
+		}
+		disks = append(disks, disk)
+	}
+	return disks
+}
+
+// EjectVMBootstrapISOs ejects the images from the CD-ROMs of a VM and returns the UUID of the update task. The
+// CD-ROM devices are kept.
+func EjectVMBootstrapISOs(ctx context.Context, client *nutanixClientV3.Client, vm *nutanixClientV3.VMIntentResponse) (string, error) {
+	if vm.Metadata == nil || vm.Metadata.UUID == nil || vm.Spec == nil {
+		return "", fmt.Errorf("cannot eject bootstrap ISO of VM without metadata UUID and spec")
+	}
+	for _, disk := range getVMBootstrapISODisks(vm) {
+		disk.DataSourceReference = nil
+		// The size of an empty CD-ROM is not set
+		disk.DiskSizeMib = nil
+		disk.DiskSizeBytes = nil
+	}
+	vmUpdateResponse, err := client.V3.UpdateVM(ctx, *vm.Metadata.UUID, &nutanixClientV3.VMIntentInput{
+		Metadata: vm.Metadata,
+		Spec:     vm.Spec,
+	})
+	if err != nil {
+		return "", err
+	}
+	return GetTaskUUIDFromVM(vmUpdateResponse)
+}
+
+// CreateGuestCustomizationSpec returns the guest customization of a VM passing the bootstrap data in the given format
+// to the VM. Cloud-init bootstrap data is gzip compressed if it is larger than the compression threshold, and passed as
+// is otherwise. Compression is disabled if the threshold is 0. Ignition bootstrap data is amended with the hostname of
+// the VM, since Ignition does not consume the hostname of the metadata. An error is returned if the bootstrap data
+// exceeds the maximum user data size of Prism Central.
+func CreateGuestCustomizationSpec(format infrav1.NutanixBootstrapFormat, bootstrapData []byte, hostname, vmUUID string, compressionThreshold int) (*nutanixClientV3.GuestCustomization, error) {
+	userData := bootstrapData
+	compressed := false
+	switch format {
+	case "", infrav1.NutanixBootstrapFormatCloudInit:
+		if compressionThreshold > 0 && len(bootstrapData) > compressionThreshold {
+			gzipData, err := gzipUserData(bootstrapData)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compress bootstrap data: %v", err)
+			}
+			userData = gzipData
+			compressed = true
+		}
+	case infrav1.NutanixBootstrapFormatIgnition:
+		ignitionData, err := addIgnitionHostname(bootstrapData, hostname)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignition bootstrap data: %v", err)
+		}
+		userData = ignitionData
+	default:
+		return nil, fmt.Errorf("unsupported bootstrap format %s", format)
+	}
+
+	encodedUserData := base64.StdEncoding.EncodeToString(userData)
+	if len(encodedUserData) > maxUserDataSize {
+		if compressed {
+			return nil, fmt.Errorf("bootstrap data of %d bytes exceeds the maximum user data size of %d bytes even when compressed (%d bytes base64 encoded)",
+				len(bootstrapData), maxUserDataSize, len(encodedUserData))
+		}
+		return nil, fmt.Errorf("bootstrap data of %d bytes exceeds the maximum user data size of %d bytes (%d bytes base64 encoded)",
+			len(bootstrapData), maxUserDataSize, len(encodedUserData))
+	}
+
+	metadata := fmt.Sprintf("{\"hostname\": \"%s\", \"uuid\": \"%s\"}", hostname, vmUUID)
+	return &nutanixClientV3.GuestCustomization{
+		IsOverridable: utils.BoolPtr(true),
+		CloudInit: &nutanixClientV3.GuestCustomizationCloudInit{
+			UserData: utils.StringPtr(encodedUserData),
+			MetaData: utils.StringPtr(base64.StdEncoding.EncodeToString([]byte(metadata))),
+		},
+	}, nil
+}
+
+// gzipUserData returns the gzip compressed user data. Cloud-init detects and decompresses gzip compressed user data.
+func gzipUserData(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// addIgnitionHostname adds the /etc/hostname file to an Ignition config unless the config already contains it.
+// Ignition spec versions 2.x and 3.x are supported.
+func addIgnitionHostname(data []byte, hostname string) ([]byte, error) {
+	return addIgnitionFile(data, "/etc/hostname", hostname)
+}
+
+// addIgnitionFile adds a file with the given contents to an Ignition config unless the config already contains a file
+// at the path. Ignition spec versions 2.x and 3.x are supported.
+func addIgnitionFile(data []byte, path, contents string) ([]byte, error) {
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	ignition, _ := config["ignition"].(map[string]interface{})
+	version, _ := ignition["version"].(string)
+	file := map[string]interface{}{
+		"path": path,
+		"mode": 420,
+		"contents": map[string]interface{}{
+			"source": "data:," + url.PathEscape(contents),
+		},
+	}
+	switch {
+	case strings.HasPrefix(version, "2."):
+		file["filesystem"] = "root"
+	case strings.HasPrefix(version, "3."):
+		file["overwrite"] = true
+	default:
+		return nil, fmt.Errorf("unsupported ignition version %q", version)
+	}
+
+	storage, ok := config["storage"].(map[string]interface{})
+	if !ok {
+		storage = map[string]interface{}{}
+		config["storage"] = storage
+	}
+	files, _ := storage["files"].([]interface{})
+	for _, f := range files {
+		if existing, ok := f.(map[string]interface{}); ok && existing["path"] == path {
+			return data, nil
+		}
+	}
+	storage["files"] = append(files, file)
+	return json.Marshal(config)
+}
+
+// AddDNSConfig adds the name servers and search domains to the bootstrap data of the given format. They are written
+// to a systemd-resolved drop-in file, which takes precedence over the DNS settings received with DHCP. The bootstrap
+// data is returned as is if neither name servers nor search domains are set.
+func AddDNSConfig(format infrav1.NutanixBootstrapFormat, bootstrapData []byte, nameServers, searchDomains []string) ([]byte, error) {
+	if len(nameServers) == 0 && len(searchDomains) == 0 {
+		return bootstrapData, nil
+	}
+	var dropIn strings.Builder
+	dropIn.WriteString("[Resolve]\n")
+	if len(nameServers) > 0 {
+		fmt.Fprintf(&dropIn, "DNS=%s\n", strings.Join(nameServers, " "))
+	}
+	if len(searchDomains) > 0 {
+		fmt.Fprintf(&dropIn, "Domains=%s\n", strings.Join(searchDomains, " "))
+	}
+
+	switch format {
+	case "", infrav1.NutanixBootstrapFormatCloudInit:
+		return addCloudConfigDNS(bootstrapData, dropIn.String())
+	case infrav1.NutanixBootstrapFormatIgnition:
+		// Ignition writes the file before systemd-resolved is started
+		return addIgnitionFile(bootstrapData, resolvedDNSDropInPath, dropIn.String())
+	default:
+		return nil, fmt.Errorf("unsupported bootstrap format %s", format)
+	}
+}
+
+// addCloudConfigDNS adds the systemd-resolved drop-in file to a cloud-config. systemd-resolved is restarted before
+// the commands of the cloud-config run, since it is started before cloud-init writes the file.
+func addCloudConfigDNS(data []byte, dropIn string) ([]byte, error) {
+	// The header lines, like the "#cloud-config" line, are comments that would be lost when marshaling the config
+	var header strings.Builder
+	isCloudConfig := false
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		header.WriteString(line)
+		if strings.TrimSpace(line) == "#cloud-config" {
+			isCloudConfig = true
+		}
+	}
+	if !isCloudConfig {
+		return nil, fmt.Errorf("name servers and search domains can only be added to #cloud-config bootstrap data")
+	}
+
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid cloud-config bootstrap data: %v", err)
+	}
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	files, _ := config["write_files"].([]interface{})
+	config["write_files"] = append(files, map[string]interface{}{
+		"path":        resolvedDNSDropInPath,
+		"permissions": "0644",
+		"content":     dropIn,
+	})
+	commands, _ := config["runcmd"].([]interface{})
+	config["runcmd"] = append([]interface{}{"systemctl try-restart systemd-resolved"}, commands...)
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(header.String()), out...), nil
+}
+
 // GetSubnetUUID returns the UUID of the subnet with the given name
 func GetSubnetUUID(ctx context.Context, client *nutanixClientV3.Client, peUUID string, subnetName, subnetUUID *string) (string, error) {
 	var foundSubnetUUID string
@@ -264,13 +1026,13 @@ func GetSubnetUUID(ctx context.Context, client *nutanixClientV3.Client, peUUID s
 	} else if subnetName != nil {
 		filter := getFilterForName(*subnetName)
 		// Not using additional filtering since we want to list overlay and vlan subnets
-		responseSubnets, err := client.V3.ListAllSubnet(ctx, filter, nil)
+		responseSubnets, err := nutanixClientHelper.ListAllSubnets(ctx, client, filter)
 		if err != nil {
 			return "", err
 		}
 		// Validate filtered Subnets
 		foundSubnets := make([]*nutanixClientV3.SubnetIntentResponse, 0)
-		for _, subnet := range responseSubnets.Entities {
+		for _, subnet := range responseSubnets {
 			if subnet == nil || subnet.Spec == nil || subnet.Spec.Name == nil || subnet.Spec.Resources == nil || subnet.Spec.Resources.SubnetType == nil {
 				continue
 			}
@@ -300,6 +1062,22 @@ func GetSubnetUUID(ctx context.Context, client *nutanixClientV3.Client, peUUID s
 	return foundSubnetUUID, nil
 }
 
+// GetSubnetUUIDByIdentifier returns the UUID of the subnet identified by UUID, name or category that is available on the
+// Prism Element cluster with the given UUID
+func GetSubnetUUIDByIdentifier(ctx context.Context, client *nutanixClientV3.Client, peUUID string, identifier infrav1.NutanixResourceIdentifier) (string, error) {
+	if identifier.Type != infrav1.NutanixIdentifierCategory {
+		return GetSubnetUUID(ctx, client, peUUID, identifier.Name, identifier.UUID)
+	}
+	if identifier.Category == nil {
+		return "", fmt.Errorf("subnet category must be passed in order to retrieve the subnet by category")
+	}
+	subnet, err := nutanixClientHelper.FindSubnetByCategory(ctx, client, peUUID, identifier.Category.Key, identifier.Category.Value)
+	if err != nil {
+		return "", err
+	}
+	return *subnet.Metadata.UUID, nil
+}
+
 // GetImageUUID returns the UUID of the image with the given name
 func GetImageUUID(ctx context.Context, client *nutanixClientV3.Client, imageName, imageUUID *string) (string, error) {
 	var foundImageUUID string
@@ -317,13 +1095,13 @@ func GetImageUUID(ctx context.Context, client *nutanixClientV3.Client, imageName
 		foundImageUUID = *imageIntentResponse.Metadata.UUID
 	} else if imageName != nil {
 		filter := getFilterForName(*imageName)
-		responseImages, err := client.V3.ListAllImage(ctx, filter)
+		responseImages, err := nutanixClientHelper.ListAllImages(ctx, client, filter)
 		if err != nil {
 			return "", err
 		}
 		// Validate filtered Images
 		foundImages := make([]*nutanixClientV3.ImageIntentResponse, 0)
-		for _, s := range responseImages.Entities {
+		for _, s := range responseImages {
 			imageSpec := s.Spec
 			if *imageSpec.Name == *imageName {
 				foundImages = append(foundImages, s)
@@ -343,6 +1121,141 @@ func GetImageUUID(ctx context.Context, client *nutanixClientV3.Client, imageName
 	return foundImageUUID, nil
 }
 
+// GetClusterImageUUID returns the UUID of the image with the given name or UUID whose copy is present on the Prism
+// Element cluster with the given UUID. Images with the same name on other clusters are ignored.
+func GetClusterImageUUID(ctx context.Context, client *nutanixClientV3.Client, imageName, imageUUID *string, peUUID string) (string, error) {
+	if imageUUID == nil && imageName == nil {
+		return "", fmt.Errorf("image name or image uuid must be passed in order to retrieve the image")
+	}
+	if imageUUID != nil {
+		image, err := client.V3.GetImage(ctx, *imageUUID)
+		if err != nil {
+			return "", fmt.Errorf("failed to find image with UUID %s: %v", *imageUUID, err)
+		}
+		if !isImageOnCluster(image, peUUID) {
+			return "", fmt.Errorf("image with UUID %s is not present on cluster with UUID %s", *imageUUID, peUUID)
+		}
+		return *image.Metadata.UUID, nil
+	}
+	responseImages, err := nutanixClientHelper.ListAllImages(ctx, client, getFilterForName(*imageName))
+	if err != nil {
+		return "", err
+	}
+	foundImages := make([]*nutanixClientV3.ImageIntentResponse, 0)
+	for _, image := range responseImages {
+		if *image.Spec.Name == *imageName && isImageOnCluster(image, peUUID) {
+			foundImages = append(foundImages, image)
+		}
+	}
+	switch len(foundImages) {
+	case 0:
+		return "", fmt.Errorf("failed to find image with name %s on cluster with UUID %s", *imageName, peUUID)
+	case 1:
+		return *foundImages[0].Metadata.UUID, nil
+	default:
+		return "", fmt.Errorf("more than one image found with name %s on cluster with UUID %s", *imageName, peUUID)
+	}
+}
+
+// isImageOnCluster returns true if a copy of the image is present on the Prism Element cluster with the given UUID
+func isImageOnCluster(image *nutanixClientV3.ImageIntentResponse, peUUID string) bool {
+	if image.Status == nil {
+		return false
+	}
+	for _, ref := range image.Status.Resources.CurrentClusterReferenceList {
+		if ref != nil && ref.UUID == peUUID {
+			return true
+		}
+	}
+	return false
+}
+
+// FindVolumeGroup retrieves the volume group with the given name or UUID. Returns nil if not found
+func FindVolumeGroup(ctx context.Context, client *nutanixClientV3.Client, vgName, vgUUID *string) (*nutanixClientV3.VolumeGroupResponse, error) {
+	if vgUUID == nil && vgName == nil {
+		return nil, fmt.Errorf("volume group name or volume group uuid must be passed in order to retrieve the volume group")
+	}
+	if vgUUID != nil {
+		vg, err := client.V3.GetVolumeGroup(ctx, *vgUUID)
+		if err != nil {
+			if strings.Contains(fmt.Sprint(err), "ENTITY_NOT_FOUND") {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return vg, nil
+	}
+	responseVGs, err := nutanixClientHelper.ListAllVolumeGroups(ctx, client, getFilterForName(*vgName))
+	if err != nil {
+		return nil, err
+	}
+	// Validate filtered volume groups
+	foundVGs := make([]*nutanixClientV3.VolumeGroupResponse, 0)
+	for _, vg := range responseVGs {
+		if vg.Spec != nil && utils.StringValue(vg.Spec.Name) == *vgName {
+			foundVGs = append(foundVGs, vg)
+		}
+	}
+	if len(foundVGs) == 0 {
+		return nil, nil
+	} else if len(foundVGs) > 1 {
+		return nil, fmt.Errorf("more than one volume group found with name %s", *vgName)
+	}
+	return foundVGs[0], nil
+}
+
+// AttachVolumeGroup attaches the VM to the volume group and waits until the attachment is complete
+func AttachVolumeGroup(ctx context.Context, client *nutanixClientV3.Client, vg *nutanixClientV3.VolumeGroupResponse, vmUUID string) error {
+	return updateVolumeGroupAttachment(ctx, client, vg, vmUUID, true)
+}
+
+// DetachVolumeGroup detaches the VM from the volume group and waits until the detachment is complete
+func DetachVolumeGroup(ctx context.Context, client *nutanixClientV3.Client, vg *nutanixClientV3.VolumeGroupResponse, vmUUID string) error {
+	return updateVolumeGroupAttachment(ctx, client, vg, vmUUID, false)
+}
+
+func updateVolumeGroupAttachment(ctx context.Context, client *nutanixClientV3.Client, vg *nutanixClientV3.VolumeGroupResponse, vmUUID string, attach bool) error {
+	log := ctrl.LoggerFrom(ctx)
+	if vg.Metadata == nil || vg.Metadata.UUID == nil || vg.Spec == nil {
+		return fmt.Errorf("cannot update attachments of volume group without metadata UUID and spec")
+	}
+	vgUUID := *vg.Metadata.UUID
+	if vg.Spec.Resources == nil {
+		vg.Spec.Resources = &nutanixClientV3.VolumeGroupResources{}
+	}
+	if nutanixClientHelper.IsVMAttachedToVolumeGroup(vg.Spec.Resources, vmUUID) != attach {
+		if attach {
+			log.Info(fmt.Sprintf("Attaching VM with UUID %s to volume group with UUID %s", vmUUID, vgUUID))
+			vg.Spec.Resources.AttachmentList = append(vg.Spec.Resources.AttachmentList, &nutanixClientV3.VMAttachment{
+				VMReference: &nutanixClientV3.Reference{
+					Kind: utils.StringPtr(vmKind),
+					UUID: utils.StringPtr(vmUUID),
+				},
+			})
+		} else {
+			log.Info(fmt.Sprintf("Detaching VM with UUID %s from volume group with UUID %s", vmUUID, vgUUID))
+			attachments := make([]*nutanixClientV3.VMAttachment, 0)
+			for _, attachment := range vg.Spec.Resources.AttachmentList {
+				if attachment != nil && attachment.VMReference != nil && utils.StringValue(attachment.VMReference.UUID) == vmUUID {
+					continue
+				}
+				attachments = append(attachments, attachment)
+			}
+			vg.Spec.Resources.AttachmentList = attachments
+		}
+		_, err := client.V3.UpdateVolumeGroup(ctx, vgUUID, &nutanixClientV3.VolumeGroupInput{
+			Metadata: vg.Metadata,
+			Spec:     vg.Spec,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update attachments of volume group with UUID %s: %v", vgUUID, err)
+		}
+	}
+	return nutanixClientHelper.WaitForVolumeGroupAttachment(ctx, client, vgUUID, vmUUID, attach, nutanixClientHelper.WaitOptions{
+		Timeout: volumeGroupAttachmentTimeout,
+	})
+}
+
 // HasTaskInProgress returns true if the given task is in progress
 func HasTaskInProgress(ctx context.Context, client *nutanixClientV3.Client, taskUUID string) (bool, error) {
 	log := ctrl.LoggerFrom(ctx)
@@ -351,7 +1264,7 @@ func HasTaskInProgress(ctx context.Context, client *nutanixClientV3.Client, task
 		return false, err
 	}
 	if taskStatus != taskSucceededMessage {
-		log.V(1).Info(fmt.Sprintf("VM task with UUID %s still in progress: %s", taskUUID, taskStatus))
+		log.V(1).Info("VM task still in progress", nutanixClientHelper.LogKeyTaskUUID, taskUUID, "status", taskStatus)
 		return true, nil
 	}
 	return false, nil
@@ -386,13 +1299,7 @@ func GetTaskUUIDFromVM(vm *nutanixClientV3.VMIntentResponse) (string, error) {
 func GetSubnetUUIDList(ctx context.Context, client *nutanixClientV3.Client, machineSubnets []infrav1.NutanixResourceIdentifier, peUUID string) ([]string, error) {
 	subnetUUIDs := make([]string, 0)
 	for _, machineSubnet := range machineSubnets {
-		subnetUUID, err := GetSubnetUUID(
-			ctx,
-			client,
-			peUUID,
-			machineSubnet.Name,
-			machineSubnet.UUID,
-		)
+		subnetUUID, err := GetSubnetUUIDByIdentifier(ctx, client, peUUID, machineSubnet)
 		if err != nil {
 			return subnetUUIDs, err
 		}
@@ -411,6 +1318,24 @@ func GetDefaultCAPICategoryIdentifiers(clusterName string) []*infrav1.NutanixCat
 	}
 }
 
+// GetMachineRoleCategoryIdentifiers returns the category identifiers of the role of a Machine and of the
+// MachineDeployment owning the Machine. The MachineDeployment category is omitted if machineDeployment is empty.
+func GetMachineRoleCategoryIdentifiers(role, machineDeployment string) []*infrav1.NutanixCategoryIdentifier {
+	categoryIdentifiers := []*infrav1.NutanixCategoryIdentifier{
+		{
+			Key:   infrav1.DefaultCAPICategoryKeyForMachineRole,
+			Value: role,
+		},
+	}
+	if machineDeployment != "" {
+		categoryIdentifiers = append(categoryIdentifiers, &infrav1.NutanixCategoryIdentifier{
+			Key:   infrav1.DefaultCAPICategoryKeyForMachineDeployment,
+			Value: machineDeployment,
+		})
+	}
+	return categoryIdentifiers
+}
+
 // GetObsoleteDefaultCAPICategoryIdentifiers returns the default CAPI category identifiers
 func GetObsoleteDefaultCAPICategoryIdentifiers(clusterName string) []*infrav1.NutanixCategoryIdentifier {
 	return []*infrav1.NutanixCategoryIdentifier{
@@ -509,13 +1434,13 @@ func deleteCategoryKeyValues(ctx context.Context, client *nutanixClientV3.Client
 
 		if !ignoreKeyDeletion {
 			// check if there are remaining category values
-			categoryKeyValues, err := client.V3.ListCategoryValues(ctx, key, &nutanixClientV3.CategoryListMetadata{})
+			categoryKeyValues, err := nutanixClientHelper.ListAllCategoryValues(ctx, client, key)
 			if err != nil {
 				errorMsg := fmt.Errorf("failed to get values of category with key %s: %v", key, err)
 				log.Error(errorMsg, "failed to get values of category")
 				return errorMsg
 			}
-			if len(categoryKeyValues.Entities) > 0 {
+			if len(categoryKeyValues) > 0 {
 				errorMsg := fmt.Errorf("cannot remove category with key %s because it still has category values assigned", key)
 				log.Error(errorMsg, "cannot remove category")
 				return errorMsg
@@ -635,12 +1560,12 @@ func GetProjectUUID(ctx context.Context, client *nutanixClientV3.Client, project
 		foundProjectUUID = *projectIntentResponse.Metadata.UUID
 	} else if projectName != nil {
 		filter := getFilterForName(*projectName)
-		responseProjects, err := client.V3.ListAllProject(ctx, filter)
+		responseProjects, err := nutanixClientHelper.ListAllProjects(ctx, client, filter)
 		if err != nil {
 			return "", err
 		}
 		foundProjects := make([]*nutanixClientV3.Project, 0)
-		for _, s := range responseProjects.Entities {
+		for _, s := range responseProjects {
 			projectSpec := s.Spec
 			if projectSpec.Name == *projectName {
 				foundProjects = append(foundProjects, s)
@@ -660,10 +1585,79 @@ func GetProjectUUID(ctx context.Context, client *nutanixClientV3.Client, project
 	return foundProjectUUID, nil
 }
 
+// errUserNotFound is returned if a Prism Central user does not exist
+var errUserNotFound = errors.New("user not found")
+
+// GetUserUUID returns the UUID of the Prism Central user with the given UUID or name. The name of a user is its user
+// principal name, or its username for users of an identity provider. Returns errUserNotFound if the user does not exist.
+func GetUserUUID(ctx context.Context, client *nutanixClientV3.Client, userName, userUUID *string) (string, error) {
+	if userUUID == nil && userName == nil {
+		return "", fmt.Errorf("name or uuid must be passed in order to retrieve the user")
+	}
+	if userUUID != nil {
+		user, err := client.V3.GetUser(ctx, *userUUID)
+		if err != nil {
+			if strings.Contains(fmt.Sprint(err), "ENTITY_NOT_FOUND") {
+				return "", fmt.Errorf("failed to find user with UUID %s: %w", *userUUID, errUserNotFound)
+			}
+			return "", fmt.Errorf("failed to get user with UUID %s: %v", *userUUID, err)
+		}
+		if user.Metadata == nil || utils.StringValue(user.Metadata.UUID) == "" {
+			return "", fmt.Errorf("failed to find user with UUID %s: %w", *userUUID, errUserNotFound)
+		}
+		return *user.Metadata.UUID, nil
+	}
+	// Users cannot be filtered by name, so all users are matched
+	responseUsers, err := nutanixClientHelper.ListAllUsers(ctx, client, "")
+	if err != nil {
+		return "", err
+	}
+	foundUsers := make([]*nutanixClientV3.UserIntentResponse, 0)
+	for _, user := range responseUsers {
+		if user.Metadata != nil && utils.StringValue(user.Metadata.UUID) != "" && getUserName(user) == *userName {
+			foundUsers = append(foundUsers, user)
+		}
+	}
+	if len(foundUsers) == 0 {
+		return "", fmt.Errorf("failed to retrieve user by name %s: %w", *userName, errUserNotFound)
+	} else if len(foundUsers) > 1 {
+		return "", fmt.Errorf("more than one user found with name %s", *userName)
+	}
+	return *foundUsers[0].Metadata.UUID, nil
+}
+
+// getUserName returns the user principal name of a directory service user, the username of an identity provider user,
+// or the name reported in the status of the user otherwise
+func getUserName(user *nutanixClientV3.UserIntentResponse) string {
+	if user.Spec != nil && user.Spec.Resources != nil {
+		if dsUser := user.Spec.Resources.DirectoryServiceUser; dsUser != nil && utils.StringValue(dsUser.UserPrincipalName) != "" {
+			return *dsUser.UserPrincipalName
+		}
+		if idpUser := user.Spec.Resources.IdentityProviderUser; idpUser != nil && utils.StringValue(idpUser.Username) != "" {
+			return *idpUser.Username
+		}
+	}
+	if user.Status != nil {
+		return utils.StringValue(user.Status.Name)
+	}
+	return ""
+}
+
 func getFilterForName(name string) string {
 	return fmt.Sprintf("name==%s", name)
 }
 
+// getResourceIdentifierString returns the description of a resource identifier used in messages
+func getResourceIdentifierString(identifier infrav1.NutanixResourceIdentifier) string {
+	if identifier.Type == infrav1.NutanixIdentifierUUID {
+		return fmt.Sprintf("with UUID %s", utils.StringValue(identifier.UUID))
+	}
+	if identifier.Type == infrav1.NutanixIdentifierCategory && identifier.Category != nil {
+		return fmt.Sprintf("with category %s=%s", identifier.Category.Key, identifier.Category.Value)
+	}
+	return fmt.Sprintf("with name %s", utils.StringValue(identifier.Name))
+}
+
 func hasPEClusterServiceEnabled(peCluster *nutanixClientV3.ClusterIntentResponse, serviceName string) bool {
 	if peCluster.Status == nil ||
 		peCluster.Status.Resources == nil ||
@@ -723,12 +1717,12 @@ func GetGPU(ctx context.Context, client *nutanixClientV3.Client, peUUID string,
 
 func GetGPUsForPE(ctx context.Context, client *nutanixClientV3.Client, peUUID string) ([]*nutanixClientV3.GPU, error) {
 	gpus := make([]*nutanixClientV3.GPU, 0)
-	hosts, err := client.V3.ListAllHost(ctx)
+	hosts, err := nutanixClientHelper.ListAllHosts(ctx, client)
 	if err != nil {
 		return gpus, err
 	}
 
-	for _, host := range hosts.Entities {
+	for _, host := range hosts {
 		if host == nil ||
 			host.Status == nil ||
 			host.Status.ClusterReference == nil ||
@@ -749,7 +1743,7 @@ func GetGPUsForPE(ctx context.Context, client *nutanixClientV3.Client, peUUID st
 }
 
 // GetFailureDomain gets the failure domain with a given name from a NutanixCluster object.
-func GetFailureDomain(failureDomainName string, nutanixCluster *infrav1.NutanixCluster) (*infrav1.NutanixFailureDomain, error) {
+func GetFailureDomain(failureDomainName string, nutanixCluster *infrav1.NutanixCluster) (*infrav1.NutanixFailureDomainConfig, error) {
 	if failureDomainName == "" {
 		return nil, fmt.Errorf("failure domain name must be set when searching for failure domains on a Nutanix cluster object")
 	}
@@ -763,3 +1757,165 @@ func GetFailureDomain(failureDomainName string, nutanixCluster *infrav1.NutanixC
 	}
 	return nil, fmt.Errorf("failed to find failure domain %s on nutanix cluster object", failureDomainName)
 }
+
+// GetNutanixFailureDomainName returns the name of the NutanixFailureDomain object for a failure domain of a
+// NutanixCluster. The name is a valid DNS-1123 subdomain ending with a hash of the names of the NutanixCluster and the
+// failure domain, so the objects of different NutanixClusters never share a name, e.g. cluster a with failure domain
+// b-c and cluster a-b with failure domain c.
+func GetNutanixFailureDomainName(nutanixClusterName, failureDomainName string) string {
+	hash := sha256.Sum256([]byte(nutanixClusterName + "/" + failureDomainName))
+	suffix := hex.EncodeToString(hash[:])[:nutanixFailureDomainNameHashLength]
+	// Characters other than lowercase alphanumerics are replaced, so the name consists of a single DNS-1123 label
+	prefix := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(nutanixClusterName+"-"+failureDomainName))
+	if maxLength := validation.DNS1123SubdomainMaxLength - len(suffix) - 1; len(prefix) > maxLength {
+		prefix = prefix[:maxLength]
+	}
+	prefix = strings.Trim(prefix, "-")
+	if prefix == "" {
+		return suffix
+	}
+	return prefix + "-" + suffix
+}
+
+// GetFailureDomainWeight returns the placement weight of a failure domain. Defaults to 1 if no weight is set.
+func GetFailureDomainWeight(failureDomain infrav1.NutanixFailureDomainConfig) int64 {
+	if failureDomain.Weight == nil {
+		return 1
+	}
+	return int64(*failureDomain.Weight)
+}
+
+// SelectFailureDomain selects the failure domain the next machine should be placed in. Failure domains are filled
+// proportionally to their weights, based on the amount of machines already placed in each of them.
+func SelectFailureDomain(failureDomains []infrav1.NutanixFailureDomainConfig, placedMachines map[string]int) (*infrav1.NutanixFailureDomainConfig, error) {
+	var selected *infrav1.NutanixFailureDomainConfig
+	for i := range failureDomains {
+		fd := &failureDomains[i]
+		weight := GetFailureDomainWeight(*fd)
+		if weight < 1 {
+			return nil, fmt.Errorf("weight of failure domain %s must be greater than 0 but was %d", fd.Name, weight)
+		}
+		if selected == nil {
+			selected = fd
+			continue
+		}
+		// Pick the failure domain with the lowest load relative to its weight after placing the machine:
+		// (placed(fd)+1)/weight(fd) < (placed(selected)+1)/weight(selected)
+		if int64(placedMachines[fd.Name]+1)*GetFailureDomainWeight(*selected) < int64(placedMachines[selected.Name]+1)*weight {
+			selected = fd
+		}
+	}
+	if selected == nil {
+		return nil, fmt.Errorf("no failure domains available for machine placement")
+	}
+	return selected, nil
+}
+
+// ValidateExtraVMConfig verifies that the extraVMConfig of a NutanixMachine is a JSON object that only sets known
+// fields of the "spec" and "metadata" of the Prism Central v3 VM intent, and does not set the VM name or the ownership
+// categories of the cluster
+func ValidateExtraVMConfig(extraVMConfig *runtime.RawExtension) error {
+	_, err := parseExtraVMConfig(extraVMConfig)
+	return err
+}
+
+// MergeExtraVMConfig returns the VM intent with the extraVMConfig of a NutanixMachine merged into it. Objects are merged
+// recursively, all other values replace the values of the VM intent.
+func MergeExtraVMConfig(vmInput *nutanixClientV3.VMIntentInput, extraVMConfig *runtime.RawExtension) (*nutanixClientV3.VMIntentInput, error) {
+	extra, err := parseExtraVMConfig(extraVMConfig)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(vmInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal VM intent: %v", err)
+	}
+	merged, err := decodeJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal VM intent: %v", err)
+	}
+	mergeJSONObjects(merged, extra)
+	if data, err = json.Marshal(merged); err != nil {
+		return nil, fmt.Errorf("failed to marshal merged VM intent: %v", err)
+	}
+	mergedInput := &nutanixClientV3.VMIntentInput{}
+	if err := decodeVMIntentInput(data, mergedInput); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal merged VM intent: %v", err)
+	}
+	return mergedInput, nil
+}
+
+// parseExtraVMConfig returns the extraVMConfig of a NutanixMachine as JSON object after validating it
+func parseExtraVMConfig(extraVMConfig *runtime.RawExtension) (map[string]interface{}, error) {
+	if extraVMConfig == nil || len(extraVMConfig.Raw) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	extra, err := decodeJSONObject(extraVMConfig.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("must be a JSON object: %v", err)
+	}
+	for key, value := range extra {
+		if key != "spec" && key != "metadata" {
+			return nil, fmt.Errorf("only spec and metadata can be set but found %q", key)
+		}
+		if _, ok := value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("%s must be a JSON object", key)
+		}
+	}
+	if spec, ok := extra["spec"].(map[string]interface{}); ok {
+		if _, ok := spec["name"]; ok {
+			return nil, fmt.Errorf("spec.name is managed by the controller and cannot be set")
+		}
+	}
+	if metadata, ok := extra["metadata"].(map[string]interface{}); ok {
+		if categories, ok := metadata["categories"].(map[string]interface{}); ok {
+			for key := range categories {
+				if key == infrav1.DefaultCAPICategoryKeyForName || strings.HasPrefix(key, infrav1.ObsoleteDefaultCAPICategoryPrefix) {
+					return nil, fmt.Errorf("metadata.categories.%s is managed by the controller and cannot be set", key)
+				}
+			}
+		}
+	}
+	// Reject fields that are unknown to the VM intent, since they would be silently dropped when creating the VM
+	if err := decodeVMIntentInput(extraVMConfig.Raw, &nutanixClientV3.VMIntentInput{}); err != nil {
+		return nil, err
+	}
+	return extra, nil
+}
+
+// decodeJSONObject decodes a JSON object, keeping numbers as json.Number so large integers are not rounded
+func decodeJSONObject(data []byte) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// decodeVMIntentInput decodes a VM intent, rejecting fields that are unknown to the VM intent
+func decodeVMIntentInput(data []byte, vmInput *nutanixClientV3.VMIntentInput) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(vmInput)
+}
+
+// mergeJSONObjects merges src into dst. Objects are merged recursively, all other values of src replace the values of
+// dst.
+func mergeJSONObjects(dst, src map[string]interface{}) {
+	for key, srcValue := range src {
+		srcObj, srcIsObj := srcValue.(map[string]interface{})
+		dstObj, dstIsObj := dst[key].(map[string]interface{})
+		if srcIsObj && dstIsObj {
+			mergeJSONObjects(dstObj, srcObj)
+			continue
+		}
+		dst[key] = srcValue
+	}
+}
@@ -17,14 +17,35 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/pointer"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 
 	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nutanixClientHelper "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -83,7 +104,7 @@ func TestControllerHelpers(t *testing.T) {
 			})
 			It("should return the correct failuredomain", func() {
 				r := util.RandomString(10)
-				fd1 := infrav1.NutanixFailureDomain{
+				fd1 := infrav1.NutanixFailureDomainConfig{
 					Name: fd1Name,
 					Cluster: infrav1.NutanixResourceIdentifier{
 						Type: infrav1.NutanixIdentifierName,
@@ -96,7 +117,7 @@ func TestControllerHelpers(t *testing.T) {
 						},
 					},
 				}
-				fd2 := infrav1.NutanixFailureDomain{
+				fd2 := infrav1.NutanixFailureDomainConfig{
 					Name: fd2Name,
 					Cluster: infrav1.NutanixResourceIdentifier{
 						Type: infrav1.NutanixIdentifierName,
@@ -109,7 +130,7 @@ func TestControllerHelpers(t *testing.T) {
 						},
 					},
 				}
-				ntnxCluster.Spec.FailureDomains = []infrav1.NutanixFailureDomain{
+				ntnxCluster.Spec.FailureDomains = []infrav1.NutanixFailureDomainConfig{
 					fd1,
 					fd2,
 				}
@@ -121,3 +142,720 @@ func TestControllerHelpers(t *testing.T) {
 		})
 	})
 }
+
+func TestSelectFailureDomain(t *testing.T) {
+	g := NewWithT(t)
+
+	failureDomains := []infrav1.NutanixFailureDomainConfig{
+		{
+			Name:   "fd-1",
+			Weight: pointer.Int32(1),
+		},
+		{
+			Name:   "fd-2",
+			Weight: pointer.Int32(3),
+		},
+	}
+	placedMachines := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		fd, err := SelectFailureDomain(failureDomains, placedMachines)
+		g.Expect(err).NotTo(HaveOccurred())
+		placedMachines[fd.Name]++
+	}
+	g.Expect(placedMachines["fd-1"]).To(BeNumerically("~", 100, 5))
+	g.Expect(placedMachines["fd-2"]).To(BeNumerically("~", 300, 5))
+
+	_, err := SelectFailureDomain(nil, placedMachines)
+	g.Expect(err).To(HaveOccurred())
+
+	failureDomains[0].Weight = pointer.Int32(0)
+	_, err = SelectFailureDomain(failureDomains, placedMachines)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestGetNutanixFailureDomainName(t *testing.T) {
+	g := NewWithT(t)
+
+	// Names of different clusters and failure domains never collide
+	g.Expect(GetNutanixFailureDomainName("a", "b-c")).NotTo(Equal(GetNutanixFailureDomainName("a-b", "c")))
+	g.Expect(GetNutanixFailureDomainName("cluster", "fd-1")).To(Equal(GetNutanixFailureDomainName("cluster", "fd-1")))
+	g.Expect(GetNutanixFailureDomainName("cluster", "fd-1")).To(HavePrefix("cluster-fd-1-"))
+
+	for _, tt := range []struct{ cluster, failureDomain string }{
+		{cluster: "cluster", failureDomain: "fd-1"},
+		{cluster: "my.cluster", failureDomain: "Zone_A"},
+		{cluster: "cluster", failureDomain: "-"},
+		{cluster: strings.Repeat("c", 253), failureDomain: strings.Repeat("f", 253)},
+	} {
+		name := GetNutanixFailureDomainName(tt.cluster, tt.failureDomain)
+		g.Expect(validation.IsDNS1123Subdomain(name)).To(BeEmpty(), name)
+	}
+}
+
+const testHostUUID = "00000000-0000-0000-0000-000000000030"
+
+func TestCreateGuestCustomizationSpec(t *testing.T) {
+	const (
+		hostname = "machine-1"
+		vmUUID   = "00000000-0000-0000-0000-000000000050"
+	)
+	cloudConfig := []byte("#cloud-config\nruncmd:\n- kubeadm init\n")
+	ignitionV3 := []byte(`{"ignition":{"version":"3.3.0"},"systemd":{"units":[{"name":"kubeadm.service","enabled":true}]}}`)
+	ignitionV2 := []byte(`{"ignition":{"version":"2.3.0"},"storage":{"files":[{"path":"/etc/kubeadm.yml","filesystem":"root"}]}}`)
+	ignitionWithHostname := []byte(`{"ignition":{"version":"3.3.0"},"storage":{"files":[{"path":"/etc/hostname","contents":{"source":"data:,custom"}}]}}`)
+
+	tests := []struct {
+		name             string
+		format           infrav1.NutanixBootstrapFormat
+		bootstrapData    []byte
+		expectError      bool
+		expectedUserData string
+		expectedFile     map[string]interface{}
+	}{
+		{
+			name:             "cloud-init bootstrap data is passed as is",
+			format:           infrav1.NutanixBootstrapFormatCloudInit,
+			bootstrapData:    cloudConfig,
+			expectedUserData: string(cloudConfig),
+		},
+		{
+			name:             "unset format defaults to cloud-init",
+			bootstrapData:    cloudConfig,
+			expectedUserData: string(cloudConfig),
+		},
+		{
+			name:          "ignition v3 bootstrap data gets the hostname",
+			format:        infrav1.NutanixBootstrapFormatIgnition,
+			bootstrapData: ignitionV3,
+			expectedFile: map[string]interface{}{
+				"path":      "/etc/hostname",
+				"mode":      float64(420),
+				"overwrite": true,
+				"contents":  map[string]interface{}{"source": "data:," + hostname},
+			},
+		},
+		{
+			name:          "ignition v2 bootstrap data gets the hostname",
+			format:        infrav1.NutanixBootstrapFormatIgnition,
+			bootstrapData: ignitionV2,
+			expectedFile: map[string]interface{}{
+				"path":       "/etc/hostname",
+				"mode":       float64(420),
+				"filesystem": "root",
+				"contents":   map[string]interface{}{"source": "data:," + hostname},
+			},
+		},
+		{
+			name:             "ignition bootstrap data with a hostname is passed as is",
+			format:           infrav1.NutanixBootstrapFormatIgnition,
+			bootstrapData:    ignitionWithHostname,
+			expectedUserData: string(ignitionWithHostname),
+		},
+		{
+			name:          "cloud-init bootstrap data with ignition format",
+			format:        infrav1.NutanixBootstrapFormatIgnition,
+			bootstrapData: cloudConfig,
+			expectError:   true,
+		},
+		{
+			name:          "ignition bootstrap data without version",
+			format:        infrav1.NutanixBootstrapFormatIgnition,
+			bootstrapData: []byte(`{"ignition":{}}`),
+			expectError:   true,
+		},
+		{
+			name:          "unsupported format",
+			format:        "sysprep",
+			bootstrapData: cloudConfig,
+			expectError:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			guestCustomization, err := CreateGuestCustomizationSpec(tt.format, tt.bootstrapData, hostname, vmUUID, 0)
+			if tt.expectError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(*guestCustomization.IsOverridable).To(BeTrue())
+
+			metadata, err := base64.StdEncoding.DecodeString(*guestCustomization.CloudInit.MetaData)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(metadata).To(MatchJSON(fmt.Sprintf(`{"hostname": %q, "uuid": %q}`, hostname, vmUUID)))
+
+			userData, err := base64.StdEncoding.DecodeString(*guestCustomization.CloudInit.UserData)
+			g.Expect(err).NotTo(HaveOccurred())
+			if tt.expectedFile == nil {
+				g.Expect(string(userData)).To(Equal(tt.expectedUserData))
+				return
+			}
+			config := map[string]interface{}{}
+			g.Expect(json.Unmarshal(userData, &config)).To(Succeed())
+			g.Expect(config["ignition"]).To(HaveKey("version"))
+			files := config["storage"].(map[string]interface{})["files"].([]interface{})
+			g.Expect(files).To(ContainElement(tt.expectedFile))
+		})
+	}
+
+	t.Run("payload differs between formats", func(t *testing.T) {
+		g := NewWithT(t)
+		cloudInit, err := CreateGuestCustomizationSpec(infrav1.NutanixBootstrapFormatCloudInit, ignitionV3, hostname, vmUUID, 0)
+		g.Expect(err).NotTo(HaveOccurred())
+		ignition, err := CreateGuestCustomizationSpec(infrav1.NutanixBootstrapFormatIgnition, ignitionV3, hostname, vmUUID, 0)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(*ignition.CloudInit.UserData).NotTo(Equal(*cloudInit.CloudInit.UserData))
+		g.Expect(*ignition.CloudInit.MetaData).To(Equal(*cloudInit.CloudInit.MetaData))
+	})
+}
+
+func TestAddDNSConfig(t *testing.T) {
+	nameServers := []string{"10.0.0.1", "10.0.0.2"}
+	searchDomains := []string{"corp.example.com"}
+	expectedDropIn := "[Resolve]\nDNS=10.0.0.1 10.0.0.2\nDomains=corp.example.com\n"
+	cloudConfig := []byte("## template: jinja\n#cloud-config\n\nwrite_files:\n- path: /etc/kubeadm.yml\n  content: |\n    kind: InitConfiguration\nruncmd:\n- kubeadm init\n")
+	ignitionV3 := []byte(`{"ignition":{"version":"3.3.0"}}`)
+
+	t.Run("cloud-config gets the systemd-resolved drop-in", func(t *testing.T) {
+		g := NewWithT(t)
+		data, err := AddDNSConfig(infrav1.NutanixBootstrapFormatCloudInit, cloudConfig, nameServers, searchDomains)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(string(data)).To(HavePrefix("## template: jinja\n#cloud-config\n"))
+
+		config := map[string]interface{}{}
+		g.Expect(yaml.Unmarshal(data, &config)).To(Succeed())
+		g.Expect(config["write_files"]).To(ConsistOf(
+			HaveKeyWithValue("path", "/etc/kubeadm.yml"),
+			And(
+				HaveKeyWithValue("path", resolvedDNSDropInPath),
+				HaveKeyWithValue("content", expectedDropIn),
+			),
+		))
+		// systemd-resolved is restarted before kubeadm runs
+		g.Expect(config["runcmd"]).To(Equal([]interface{}{"systemctl try-restart systemd-resolved", "kubeadm init"}))
+	})
+
+	t.Run("ignition config gets the systemd-resolved drop-in", func(t *testing.T) {
+		g := NewWithT(t)
+		data, err := AddDNSConfig(infrav1.NutanixBootstrapFormatIgnition, ignitionV3, nil, searchDomains)
+		g.Expect(err).NotTo(HaveOccurred())
+		config := map[string]interface{}{}
+		g.Expect(json.Unmarshal(data, &config)).To(Succeed())
+		files := config["storage"].(map[string]interface{})["files"].([]interface{})
+		g.Expect(files).To(ConsistOf(And(
+			HaveKeyWithValue("path", resolvedDNSDropInPath),
+			HaveKeyWithValue("contents", map[string]interface{}{"source": "data:," + url.PathEscape("[Resolve]\nDomains=corp.example.com\n")}),
+		)))
+	})
+
+	t.Run("bootstrap data is passed as is without DNS settings", func(t *testing.T) {
+		g := NewWithT(t)
+		data, err := AddDNSConfig(infrav1.NutanixBootstrapFormatCloudInit, cloudConfig, nil, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(data).To(Equal(cloudConfig))
+	})
+
+	t.Run("shell script bootstrap data is rejected", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := AddDNSConfig(infrav1.NutanixBootstrapFormatCloudInit, []byte("#!/bin/sh\nkubeadm init\n"), nameServers, nil)
+		g.Expect(err).To(MatchError(ContainSubstring("#cloud-config")))
+	})
+}
+
+func TestBootstrapFormatMatches(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(bootstrapFormatMatches("", "cloud-config")).To(BeTrue())
+	g.Expect(bootstrapFormatMatches(infrav1.NutanixBootstrapFormatCloudInit, "cloud-config")).To(BeTrue())
+	g.Expect(bootstrapFormatMatches(infrav1.NutanixBootstrapFormatCloudInit, "ignition")).To(BeFalse())
+	g.Expect(bootstrapFormatMatches(infrav1.NutanixBootstrapFormatIgnition, "ignition")).To(BeTrue())
+	g.Expect(bootstrapFormatMatches(infrav1.NutanixBootstrapFormatIgnition, "cloud-config")).To(BeFalse())
+}
+
+func TestCreateGuestCustomizationSpecCompression(t *testing.T) {
+	// compressible bootstrap data exceeding the maximum user data size
+	largeCloudConfig := []byte("#cloud-config\nwrite_files:\n- content: |\n    " + strings.Repeat("compressible ", 4*1024) + "\n")
+	// incompressible bootstrap data exceeding the maximum user data size
+	randomData := make([]byte, maxUserDataSize)
+	_, err := rand.Read(randomData)
+	NewWithT(t).Expect(err).NotTo(HaveOccurred())
+	randomCloudConfig := []byte("#cloud-config\n# " + base64.StdEncoding.EncodeToString(randomData) + "\n")
+
+	tests := []struct {
+		name                 string
+		format               infrav1.NutanixBootstrapFormat
+		bootstrapData        []byte
+		compressionThreshold int
+		expectCompressed     bool
+		expectedError        string
+	}{
+		{
+			name:                 "bootstrap data below threshold is not compressed",
+			bootstrapData:        []byte("#cloud-config\n"),
+			compressionThreshold: 1024,
+		},
+		{
+			name:                 "bootstrap data above threshold is compressed",
+			bootstrapData:        largeCloudConfig,
+			compressionThreshold: 1024,
+			expectCompressed:     true,
+		},
+		{
+			name:                 "oversized bootstrap data without compression",
+			bootstrapData:        largeCloudConfig,
+			compressionThreshold: 0,
+			expectedError:        fmt.Sprintf("bootstrap data of %d bytes exceeds the maximum user data size of %d bytes", len(largeCloudConfig), maxUserDataSize),
+		},
+		{
+			name:                 "oversized bootstrap data even when compressed",
+			bootstrapData:        randomCloudConfig,
+			compressionThreshold: 1024,
+			expectedError:        fmt.Sprintf("bootstrap data of %d bytes exceeds the maximum user data size of %d bytes even when compressed", len(randomCloudConfig), maxUserDataSize),
+		},
+		{
+			name:                 "ignition bootstrap data is not compressed",
+			format:               infrav1.NutanixBootstrapFormatIgnition,
+			bootstrapData:        []byte(`{"ignition":{"version":"3.3.0"}}`),
+			compressionThreshold: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			guestCustomization, err := CreateGuestCustomizationSpec(tt.format, tt.bootstrapData, "machine-1", "00000000-0000-0000-0000-000000000050", tt.compressionThreshold)
+			if tt.expectedError != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.expectedError)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+
+			userData, err := base64.StdEncoding.DecodeString(*guestCustomization.CloudInit.UserData)
+			g.Expect(err).NotTo(HaveOccurred())
+			if !tt.expectCompressed {
+				g.Expect(userData[:2]).NotTo(Equal([]byte{0x1f, 0x8b}))
+				return
+			}
+			g.Expect(len(*guestCustomization.CloudInit.UserData)).To(BeNumerically("<=", maxUserDataSize))
+			r, err := gzip.NewReader(bytes.NewReader(userData))
+			g.Expect(err).NotTo(HaveOccurred())
+			decompressed, err := io.ReadAll(r)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(decompressed).To(Equal(tt.bootstrapData))
+		})
+	}
+}
+
+// writeCountingClient is a client that counts the writes of the wrapped client
+type writeCountingClient struct {
+	client.Client
+	writes int
+}
+
+func (c *writeCountingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.writes++
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *writeCountingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.writes++
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *writeCountingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.writes++
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *writeCountingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.writes++
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *writeCountingClient) Status() client.StatusWriter {
+	return &writeCountingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type writeCountingStatusWriter struct {
+	client.StatusWriter
+	client *writeCountingClient
+}
+
+func (w *writeCountingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	w.client.writes++
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *writeCountingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	w.client.writes++
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+func TestIsReconciliationPaused(t *testing.T) {
+	g := NewWithT(t)
+	cluster := &capiv1.Cluster{}
+	nutanixMachine := &infrav1.NutanixMachine{}
+	g.Expect(isReconciliationPaused(cluster, nutanixMachine)).To(BeFalse())
+
+	nutanixMachine.Annotations = map[string]string{infrav1.PausedAnnotation: ""}
+	g.Expect(isReconciliationPaused(cluster, nutanixMachine)).To(BeTrue())
+
+	nutanixMachine.Annotations = map[string]string{capiv1.PausedAnnotation: ""}
+	g.Expect(isReconciliationPaused(cluster, nutanixMachine)).To(BeTrue())
+
+	nutanixMachine.Annotations = nil
+	cluster.Spec.Paused = true
+	g.Expect(isReconciliationPaused(cluster, nutanixMachine)).To(BeTrue())
+}
+
+func TestJitterRequeue(t *testing.T) {
+	g := NewWithT(t)
+	const base = 10 * time.Second
+	for i := 0; i < 100; i++ {
+		result := jitterRequeue(reconcile.Result{RequeueAfter: base}, 0.5)
+		g.Expect(result.RequeueAfter).To(BeNumerically(">=", base))
+		g.Expect(result.RequeueAfter).To(BeNumerically("<", base+base/2))
+	}
+
+	// Results are unchanged without jitter factor or requeue interval
+	g.Expect(jitterRequeue(reconcile.Result{RequeueAfter: base}, 0)).To(Equal(reconcile.Result{RequeueAfter: base}))
+	g.Expect(jitterRequeue(reconcile.Result{Requeue: true}, 0.5)).To(Equal(reconcile.Result{Requeue: true}))
+}
+
+func TestRequeueFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cause  requeueCause
+		waited time.Duration
+		expect reconcile.Result
+	}{
+		{
+			name:   "transient error",
+			cause:  requeueForTransientError,
+			waited: time.Hour,
+			expect: reconcile.Result{RequeueAfter: transientErrorRequeueInterval},
+		},
+		{
+			name:   "external condition just started",
+			cause:  requeueForExternalCondition,
+			expect: reconcile.Result{RequeueAfter: externalConditionMinRequeueInterval},
+		},
+		{
+			name:   "external condition waited for a while",
+			cause:  requeueForExternalCondition,
+			waited: 25 * time.Second,
+			expect: reconcile.Result{RequeueAfter: 40 * time.Second},
+		},
+		{
+			name:   "external condition waited for long",
+			cause:  requeueForExternalCondition,
+			waited: time.Hour,
+			expect: reconcile.Result{RequeueAfter: externalConditionMaxRequeueInterval},
+		},
+		{
+			name:   "terminal error",
+			cause:  requeueForTerminalError,
+			expect: reconcile.Result{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(requeueFor(tt.cause, tt.waited)).To(Equal(tt.expect))
+		})
+	}
+}
+
+func TestConditionAge(t *testing.T) {
+	g := NewWithT(t)
+	nutanixMachine := &infrav1.NutanixMachine{}
+	g.Expect(conditionAge(nutanixMachine, infrav1.BootstrapDataReadyCondition)).To(BeZero())
+
+	nutanixMachine.Status.Conditions = capiv1.Conditions{{
+		Type:               infrav1.BootstrapDataReadyCondition,
+		Status:             "False",
+		LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+	}}
+	g.Expect(conditionAge(nutanixMachine, infrav1.BootstrapDataReadyCondition)).To(BeNumerically(">=", time.Minute))
+	g.Expect(requeueFor(requeueForExternalCondition, conditionAge(nutanixMachine, infrav1.BootstrapDataReadyCondition)).RequeueAfter).
+		To(Equal(80 * time.Second))
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{name: "no error"},
+		{name: "connection refused", err: errors.New("dial tcp 10.0.0.1:9440: connect: connection refused"), transient: true},
+		{name: "service unavailable", err: errors.New("status: 503 Service Unavailable, error-response: {}"), transient: true},
+		{name: "rate limited", err: errors.New("status: 429 Too Many Requests"), transient: true},
+		{name: "wrapped deadline", err: fmt.Errorf("failed to list images: %w", context.DeadlineExceeded), transient: true},
+		{name: "deadline wrapped without %w", err: fmt.Errorf("failed to list images: %v", context.DeadlineExceeded), transient: true},
+		{name: "leadership lost", err: fmt.Errorf("failed to create VM: %w", errLeadershipLost), transient: true},
+		{name: "image not found", err: errors.New("found no image with name ubuntu")},
+		{name: "invalid spec", err: errors.New("status: 422 Unprocessable Entity, error-response: invalid memory size")},
+		{name: "failed task", err: fmt.Errorf("creation task failed: %w", nutanixClientHelper.ErrTaskFailed)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(isTransientError(tt.err)).To(Equal(tt.transient))
+		})
+	}
+}
+
+// clusterImageTestService returns images with copies on Prism Element clusters
+type clusterImageTestService struct {
+	nutanixClientV3.Service
+	images []*nutanixClientV3.ImageIntentResponse
+}
+
+func (s *clusterImageTestService) GetImage(_ context.Context, uuid string) (*nutanixClientV3.ImageIntentResponse, error) {
+	for _, image := range s.images {
+		if *image.Metadata.UUID == uuid {
+			return image, nil
+		}
+	}
+	return nil, fmt.Errorf("ENTITY_NOT_FOUND: image %s not found", uuid)
+}
+
+func (s *clusterImageTestService) ListImage(_ context.Context, _ *nutanixClientV3.DSMetadata) (*nutanixClientV3.ImageListIntentResponse, error) {
+	return &nutanixClientV3.ImageListIntentResponse{Entities: s.images}, nil
+}
+
+func TestGetClusterImageUUID(t *testing.T) {
+	clusterImage := func(uuid, name string, peUUIDs ...string) *nutanixClientV3.ImageIntentResponse {
+		image := &nutanixClientV3.ImageIntentResponse{
+			Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(uuid)},
+			Spec:     &nutanixClientV3.Image{Name: pointer.String(name)},
+			Status:   &nutanixClientV3.ImageDefStatus{},
+		}
+		for _, peUUID := range peUUIDs {
+			image.Status.Resources.CurrentClusterReferenceList = append(image.Status.Resources.CurrentClusterReferenceList,
+				&nutanixClientV3.ReferenceValues{Kind: "cluster", UUID: peUUID})
+		}
+		return image
+	}
+	// The same image name exists on both clusters
+	client := &nutanixClientV3.Client{V3: &clusterImageTestService{images: []*nutanixClientV3.ImageIntentResponse{
+		clusterImage("image-pe-1", "rhcos", "pe-1"),
+		clusterImage("image-pe-2", "rhcos", "pe-2"),
+		clusterImage("other-image", "other", "pe-1", "pe-2"),
+	}}}
+
+	tests := []struct {
+		name          string
+		imageName     *string
+		imageUUID     *string
+		peUUID        string
+		expectedUUID  string
+		expectedCause string
+	}{
+		{
+			name:         "name resolves to the copy on the first cluster",
+			imageName:    pointer.String("rhcos"),
+			peUUID:       "pe-1",
+			expectedUUID: "image-pe-1",
+		},
+		{
+			name:         "name resolves to the copy on the second cluster",
+			imageName:    pointer.String("rhcos"),
+			peUUID:       "pe-2",
+			expectedUUID: "image-pe-2",
+		},
+		{
+			name:          "name not present on the cluster",
+			imageName:     pointer.String("rhcos"),
+			peUUID:        "pe-3",
+			expectedCause: "failed to find image with name rhcos on cluster with UUID pe-3",
+		},
+		{
+			name:         "UUID present on the cluster",
+			imageUUID:    pointer.String("other-image"),
+			peUUID:       "pe-2",
+			expectedUUID: "other-image",
+		},
+		{
+			name:          "UUID not present on the cluster",
+			imageUUID:     pointer.String("image-pe-1"),
+			peUUID:        "pe-2",
+			expectedCause: "image with UUID image-pe-1 is not present on cluster with UUID pe-2",
+		},
+		{
+			name:          "UUID not found",
+			imageUUID:     pointer.String("missing"),
+			peUUID:        "pe-1",
+			expectedCause: "failed to find image with UUID missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			imageUUID, err := GetClusterImageUUID(context.Background(), client, tt.imageName, tt.imageUUID, tt.peUUID)
+			if tt.expectedCause != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.expectedCause)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(imageUUID).To(Equal(tt.expectedUUID))
+		})
+	}
+
+	// Without scoping to a cluster the image name is ambiguous
+	_, err := GetImageUUID(context.Background(), client, pointer.String("rhcos"), nil)
+	NewWithT(t).Expect(err).To(MatchError(ContainSubstring("more than one image found with name rhcos")))
+}
+
+func TestCreateSystemDiskSpec(t *testing.T) {
+	tests := []struct {
+		name        string
+		bus         infrav1.NutanixDiskBus
+		expectedBus string
+	}{
+		{
+			name:        "defaults to SCSI",
+			expectedBus: "SCSI",
+		},
+		{
+			name:        "PCI",
+			bus:         infrav1.NutanixDiskBusPCI,
+			expectedBus: "PCI",
+		},
+		{
+			name:        "SATA",
+			bus:         infrav1.NutanixDiskBusSATA,
+			expectedBus: "SATA",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			disk, err := CreateSystemDiskSpec("image-uuid", 20480, tt.bus)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(*disk.DataSourceReference.UUID).To(Equal("image-uuid"))
+			g.Expect(*disk.DiskSizeMib).To(Equal(int64(20480)))
+			g.Expect(*disk.DeviceProperties.DeviceType).To(Equal("DISK"))
+			g.Expect(*disk.DeviceProperties.DiskAddress.AdapterType).To(Equal(tt.expectedBus))
+		})
+	}
+}
+
+func TestCreateBootstrapISODiskSpec(t *testing.T) {
+	g := NewWithT(t)
+	disk := CreateBootstrapISODiskSpec("iso-uuid")
+	g.Expect(*disk.DataSourceReference.Kind).To(Equal("image"))
+	g.Expect(*disk.DataSourceReference.UUID).To(Equal("iso-uuid"))
+	g.Expect(*disk.DeviceProperties.DeviceType).To(Equal("CDROM"))
+	g.Expect(*disk.DeviceProperties.DiskAddress.AdapterType).To(Equal("SATA"))
+	g.Expect(disk.DiskSizeMib).To(BeNil())
+}
+
+func TestGetMibValueOfQuantity(t *testing.T) {
+	tests := []struct {
+		quantity    string
+		expectedMib int64
+	}{
+		{quantity: "4Gi", expectedMib: 4096},
+		{quantity: "4096Mi", expectedMib: 4096},
+		{quantity: "0.5Gi", expectedMib: 512},
+		{quantity: "1.5Gi", expectedMib: 1536},
+		{quantity: "4G", expectedMib: 3815},
+		{quantity: "1048577", expectedMib: 2},
+		{quantity: "1Ki", expectedMib: 1},
+		{quantity: "0", expectedMib: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.quantity, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(GetMibValueOfQuantity(resource.MustParse(tt.quantity))).To(Equal(tt.expectedMib))
+		})
+	}
+}
+
+func TestGetVMClusterUUID(t *testing.T) {
+	reference := func(uuid string) *nutanixClientV3.Reference {
+		return &nutanixClientV3.Reference{Kind: pointer.String("cluster"), UUID: pointer.String(uuid)}
+	}
+	tests := []struct {
+		name         string
+		vm           *nutanixClientV3.VMIntentResponse
+		expectedUUID string
+	}{
+		{
+			name: "observed cluster",
+			vm: &nutanixClientV3.VMIntentResponse{
+				Spec:   &nutanixClientV3.VM{ClusterReference: reference("spec-cluster")},
+				Status: &nutanixClientV3.VMDefStatus{ClusterReference: reference("status-cluster")},
+			},
+			expectedUUID: "status-cluster",
+		},
+		{
+			name: "cluster of the spec while the VM is created",
+			vm: &nutanixClientV3.VMIntentResponse{
+				Spec:   &nutanixClientV3.VM{ClusterReference: reference("spec-cluster")},
+				Status: &nutanixClientV3.VMDefStatus{},
+			},
+			expectedUUID: "spec-cluster",
+		},
+		{
+			name: "unknown cluster",
+			vm:   &nutanixClientV3.VMIntentResponse{},
+		},
+		{
+			name: "no VM",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(GetVMClusterUUID(tt.vm)).To(Equal(tt.expectedUUID))
+		})
+	}
+}
+
+func TestMergeExtraVMConfig(t *testing.T) {
+	g := NewWithT(t)
+	vmInput := &nutanixClientV3.VMIntentInput{
+		Metadata: &nutanixClientV3.Metadata{
+			Kind:       pointer.String("vm"),
+			Categories: map[string]string{infrav1.DefaultCAPICategoryKeyForName: "cluster"},
+		},
+		Spec: &nutanixClientV3.VM{
+			Name: pointer.String("vm"),
+			Resources: &nutanixClientV3.VMResources{
+				MemorySizeMib: pointer.Int64(4096),
+				NumSockets:    pointer.Int64(2),
+				NicList:       []*nutanixClientV3.VMNic{{SubnetReference: &nutanixClientV3.Reference{UUID: pointer.String("subnet")}}},
+			},
+		},
+	}
+	extraVMConfig := &runtime.RawExtension{Raw: []byte(`{
+		"spec": {"description": "extra", "resources": {"vga_console_enabled": false, "num_sockets": 4}},
+		"metadata": {"categories": {"Environment": "test"}}
+	}`)}
+
+	merged, err := MergeExtraVMConfig(vmInput, extraVMConfig)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(merged.Spec.Name).To(Equal(pointer.String("vm")))
+	g.Expect(merged.Spec.Description).To(Equal(pointer.String("extra")))
+	g.Expect(merged.Spec.Resources.VgaConsoleEnabled).To(Equal(pointer.Bool(false)))
+	g.Expect(merged.Spec.Resources.NumSockets).To(Equal(pointer.Int64(4)))
+	g.Expect(merged.Spec.Resources.MemorySizeMib).To(Equal(pointer.Int64(4096)))
+	g.Expect(merged.Spec.Resources.NicList).To(Equal(vmInput.Spec.Resources.NicList))
+	g.Expect(merged.Metadata.Categories).To(Equal(map[string]string{
+		infrav1.DefaultCAPICategoryKeyForName: "cluster",
+		"Environment":                         "test",
+	}))
+	// The generated VM intent is not modified
+	g.Expect(vmInput.Spec.Description).To(BeNil())
+	g.Expect(vmInput.Spec.Resources.NumSockets).To(Equal(pointer.Int64(2)))
+
+	// Lists replace the generated lists
+	merged, err = MergeExtraVMConfig(vmInput, &runtime.RawExtension{Raw: []byte(`{"spec":{"resources":{"nic_list":[]}}}`)})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(merged.Spec.Resources.NicList).To(BeEmpty())
+
+	_, err = MergeExtraVMConfig(vmInput, &runtime.RawExtension{Raw: []byte(`{"spec":{"name":"other"}}`)})
+	g.Expect(err).To(HaveOccurred())
+}
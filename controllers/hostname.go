@@ -0,0 +1,118 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+)
+
+// parseHostnameTemplate parses the hostname template. Missing map keys are an error to not silently render incomplete
+// hostnames.
+func parseHostnameTemplate(hostnameTemplate string) (*template.Template, error) {
+	return template.New("hostname").Option("missingkey=error").Parse(hostnameTemplate)
+}
+
+// renderHostname renders the hostname template with the given objects. An error is returned if the rendered hostname
+// is not a valid DNS subdomain.
+func renderHostname(hostnameTemplate string, data vmNameTemplateData) (string, error) {
+	tmpl, err := parseHostnameTemplate(hostnameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid hostname template: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("failed to render hostname template: %v", err)
+	}
+	hostname := strings.TrimSpace(buf.String())
+	if errs := validation.IsDNS1123Subdomain(hostname); len(errs) > 0 {
+		return "", fmt.Errorf("rendered hostname %q is not a valid DNS subdomain: %s", hostname, strings.Join(errs, ", "))
+	}
+	return hostname, nil
+}
+
+// validateHostnameTemplate verifies that the hostname template of the NutanixMachine spec at the given path can be parsed
+func validateHostnameTemplate(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if spec.HostnameTemplate == "" {
+		return allErrs
+	}
+	if _, err := parseHostnameTemplate(spec.HostnameTemplate); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("hostnameTemplate"), spec.HostnameTemplate, err.Error()))
+	}
+	return allErrs
+}
+
+// getHostname returns the hostname the VM of the NutanixMachine is expected to have, which is the rendered hostname
+// template if set, and the name of the Machine otherwise
+func getHostname(rctx *nctx.MachineContext) (string, error) {
+	if rctx.NutanixMachine.Spec.HostnameTemplate == "" {
+		return rctx.Machine.Name, nil
+	}
+	return renderHostname(rctx.NutanixMachine.Spec.HostnameTemplate, vmNameTemplateData{
+		Cluster:        rctx.Cluster,
+		Machine:        rctx.Machine,
+		NutanixMachine: rctx.NutanixMachine,
+	})
+}
+
+// vmGuestMetadata is the metadata passed to the VM in its guest customization
+type vmGuestMetadata struct {
+	Hostname string `json:"hostname"`
+	UUID     string `json:"uuid"`
+}
+
+// getVMGuestMetadata returns the metadata of the guest customization of the VM. Returns false if the VM has no guest
+// customization metadata, e.g. since it was adopted, or if the metadata cannot be decoded.
+func getVMGuestMetadata(vm *nutanixClientV3.VMIntentResponse) (vmGuestMetadata, bool) {
+	metadata := vmGuestMetadata{}
+	if vm == nil || vm.Spec == nil || vm.Spec.Resources == nil || vm.Spec.Resources.GuestCustomization == nil ||
+		vm.Spec.Resources.GuestCustomization.CloudInit == nil || vm.Spec.Resources.GuestCustomization.CloudInit.MetaData == nil {
+		return metadata, false
+	}
+	data, err := base64.StdEncoding.DecodeString(*vm.Spec.Resources.GuestCustomization.CloudInit.MetaData)
+	if err != nil {
+		return metadata, false
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil || metadata.Hostname == "" {
+		return metadata, false
+	}
+	return metadata, true
+}
+
+// getMachineHostname returns the hostname of the Machine recorded in the addresses of the NutanixMachine, or the name
+// of the Machine if no hostname was recorded yet
+func getMachineHostname(rctx *nctx.MachineContext) string {
+	for _, address := range rctx.NutanixMachine.Status.Addresses {
+		if address.Type == capiv1.MachineHostName && address.Address != "" {
+			return address.Address
+		}
+	}
+	return rctx.Machine.Name
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+func TestGetHostname(t *testing.T) {
+	tests := []struct {
+		name             string
+		hostnameTemplate string
+		expectedHostname string
+		expectErr        bool
+	}{
+		{
+			name:             "name of the Machine without template",
+			expectedHostname: "cluster-md-0-abcde",
+		},
+		{
+			name:             "rendered template",
+			hostnameTemplate: "{{ .Machine.Name }}.{{ .Cluster.Namespace }}.example.com",
+			expectedHostname: "cluster-md-0-abcde.prod.example.com",
+		},
+		{
+			name:             "invalid DNS subdomain",
+			hostnameTemplate: "{{ .Machine.Name }}_node",
+			expectErr:        true,
+		},
+		{
+			name:             "empty hostname",
+			hostnameTemplate: "{{ .Machine.Annotations.hostname }}",
+			expectErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			rctx := newVMNameTestMachineContext("")
+			rctx.NutanixMachine.Spec.HostnameTemplate = tt.hostnameTemplate
+			hostname, err := getHostname(rctx)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(hostname).To(Equal(tt.expectedHostname))
+		})
+	}
+}
+
+func TestValidateHostnameTemplate(t *testing.T) {
+	g := NewWithT(t)
+	spec := &infrav1.NutanixMachineSpec{HostnameTemplate: "{{ .Machine.Name }}.example.com"}
+	g.Expect(validateHostnameTemplate(field.NewPath("spec"), spec)).To(BeEmpty())
+
+	spec.HostnameTemplate = "{{ .Machine.Name "
+	allErrs := validateHostnameTemplate(field.NewPath("spec"), spec)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.hostnameTemplate"))
+}
+
+func TestNutanixMachineReconcileHostname(t *testing.T) {
+	tests := []struct {
+		name               string
+		hostnameTemplate   string
+		powerOnAfterCreate *bool
+		powerState         string
+		poweredOn          bool
+		existingVMUUID     string
+		taskStatus         string
+		expectedPending    bool
+		expectedHostname   string
+		expectedCondition  bool
+		expectedEvents     []string
+	}{
+		{
+			name:               "updates hostname of VM that was not powered on yet",
+			hostnameTemplate:   "{{ .Machine.Name }}.example.com",
+			powerOnAfterCreate: pointer.Bool(false),
+			powerState:         vmPowerStateOff,
+			taskStatus:         "SUCCEEDED",
+			expectedHostname:   "machine.example.com",
+			expectedEvents:     []string{hostnameUpdatedEventReason},
+		},
+		{
+			name:              "sets condition for running VM",
+			hostnameTemplate:  "{{ .Machine.Name }}.example.com",
+			powerState:        vmPowerStateOn,
+			taskStatus:        "SUCCEEDED",
+			expectedCondition: true,
+			expectedEvents:    []string{hostnameChangePendingEventReason},
+		},
+		{
+			name:               "sets condition for VM that was powered off after it was powered on",
+			hostnameTemplate:   "{{ .Machine.Name }}.example.com",
+			powerOnAfterCreate: pointer.Bool(false),
+			powerState:         vmPowerStateOff,
+			poweredOn:          true,
+			taskStatus:         "SUCCEEDED",
+			expectedCondition:  true,
+			expectedEvents:     []string{hostnameChangePendingEventReason},
+		},
+		{
+			name:       "keeps the expected hostname",
+			powerState: vmPowerStateOn,
+			taskStatus: "SUCCEEDED",
+		},
+		{
+			name:             "keeps the hostname of adopted VMs",
+			hostnameTemplate: "{{ .Machine.Name }}.example.com",
+			powerState:       vmPowerStateOn,
+			existingVMUUID:   "vm-uuid",
+			taskStatus:       "SUCCEEDED",
+		},
+		{
+			name:               "postpones update while a task is in progress",
+			hostnameTemplate:   "{{ .Machine.Name }}.example.com",
+			powerOnAfterCreate: pointer.Bool(false),
+			powerState:         vmPowerStateOff,
+			taskStatus:         "RUNNING",
+			expectedPending:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+			bootstrapSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "default"},
+				Data:       map[string][]byte{"value": []byte("#cloud-config")},
+			}
+			guestCustomization, err := CreateGuestCustomizationSpec(infrav1.NutanixBootstrapFormatCloudInit, []byte("#cloud-config"), "machine", "instance-uuid", 0)
+			g.Expect(err).NotTo(HaveOccurred())
+			service := &vmDescriptionTestService{
+				vm: &nutanixClientV3.VMIntentResponse{
+					Metadata: &nutanixClientV3.Metadata{UUID: pointer.String("vm-uuid")},
+					Spec: &nutanixClientV3.VM{
+						Name: pointer.String("machine"),
+						Resources: &nutanixClientV3.VMResources{
+							PowerState:         pointer.String(tt.powerState),
+							GuestCustomization: guestCustomization,
+						},
+					},
+					Status: &nutanixClientV3.VMDefStatus{
+						ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "last-task"},
+					},
+				},
+				taskStatus: tt.taskStatus,
+			}
+			nutanixMachine := &infrav1.NutanixMachine{
+				Spec: infrav1.NutanixMachineSpec{
+					HostnameTemplate:   tt.hostnameTemplate,
+					ExistingVMUUID:     tt.existingVMUUID,
+					PowerOnAfterCreate: tt.powerOnAfterCreate,
+					BootstrapRef:       &corev1.ObjectReference{Kind: "Secret", Name: "bootstrap", Namespace: "default"},
+				},
+				Status: infrav1.NutanixMachineStatus{VmUUID: "vm-uuid"},
+			}
+			if tt.poweredOn {
+				conditions.MarkTrue(nutanixMachine, infrav1.VMPoweredOnCondition)
+			}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixMachineReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(bootstrapSecret).Build(),
+				Recorder: recorder,
+			}
+			pending, err := reconciler.reconcileHostname(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+				Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}},
+				NutanixMachine: nutanixMachine,
+			}, service.vm)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pending).To(Equal(tt.expectedPending))
+			g.Expect(receivedEventReasons(recorder)).To(ConsistOf(tt.expectedEvents))
+			if tt.expectedCondition {
+				g.Expect(conditions.IsTrue(nutanixMachine, infrav1.HostnameChangePendingCondition)).To(BeTrue())
+				g.Expect(conditions.GetReason(nutanixMachine, infrav1.HostnameChangePendingCondition)).To(Equal(infrav1.HostnameChangeRequiresRecreation))
+			} else {
+				g.Expect(conditions.Has(nutanixMachine, infrav1.HostnameChangePendingCondition)).To(BeFalse())
+			}
+			if tt.expectedHostname == "" {
+				g.Expect(service.updates).To(BeEmpty())
+				return
+			}
+			g.Expect(service.updates).To(HaveLen(1))
+			metadata, ok := getVMGuestMetadata(&nutanixClientV3.VMIntentResponse{Spec: service.updates[0].Spec})
+			g.Expect(ok).To(BeTrue())
+			g.Expect(metadata.Hostname).To(Equal(tt.expectedHostname))
+			// The instance ID is kept to not reset cloud-init
+			g.Expect(metadata.UUID).To(Equal("instance-uuid"))
+		})
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddresses,verbs=get;list;watch
+
+// ipAddressClaimName returns the name of the IPAddressClaim of the network interface of the NutanixMachine with the given index
+func ipAddressClaimName(nutanixMachine *infrav1.NutanixMachine, index int) string {
+	return fmt.Sprintf("%s-nic-%d", nutanixMachine.Name, index)
+}
+
+// machineSubnets returns the subnets of the network interfaces of the NutanixMachine if they are set, and the given
+// subnets of the NutanixMachine or its failure domain otherwise
+func machineSubnets(nutanixMachine *infrav1.NutanixMachine, subnets []infrav1.NutanixResourceIdentifier) []infrav1.NutanixResourceIdentifier {
+	if len(nutanixMachine.Spec.NICs) == 0 {
+		return subnets
+	}
+	nicSubnets := make([]infrav1.NutanixResourceIdentifier, 0, len(nutanixMachine.Spec.NICs))
+	for _, nic := range nutanixMachine.Spec.NICs {
+		nicSubnets = append(nicSubnets, nic.Subnet)
+	}
+	return nicSubnets
+}
+
+// reconcileIPAddressClaims creates an IPAddressClaim for each network interface of the NutanixMachine that references
+// an IPAM pool, and returns true once the IPAM providers allocated an IP address for all claims. The claims are owned
+// by the NutanixMachine and are deleted together with it.
+func (r *NutanixMachineReconciler) reconcileIPAddressClaims(rctx *nctx.MachineContext) (bool, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	claimed := false
+	pending := []string{}
+	for i, nic := range rctx.NutanixMachine.Spec.NICs {
+		if nic.IPAddress != nil || nic.IPAddressPoolRef == nil {
+			continue
+		}
+		claimed = true
+		claim := &ipamv1.IPAddressClaim{}
+		claimKey := client.ObjectKey{Namespace: rctx.NutanixMachine.Namespace, Name: ipAddressClaimName(rctx.NutanixMachine, i)}
+		err := r.Client.Get(rctx.Context, claimKey, claim)
+		if apierrors.IsNotFound(err) {
+			claim = &ipamv1.IPAddressClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      claimKey.Name,
+					Namespace: claimKey.Namespace,
+					Labels:    map[string]string{capiv1.ClusterLabelName: rctx.Cluster.Name},
+				},
+				Spec: ipamv1.IPAddressClaimSpec{PoolRef: *nic.IPAddressPoolRef},
+			}
+			if err := ctrlutil.SetControllerReference(rctx.NutanixMachine, claim, r.Scheme); err != nil {
+				return false, err
+			}
+			log.Info(fmt.Sprintf("Creating IPAddressClaim %s for network interface %d of NutanixMachine %s", claim.Name, i, rctx.NutanixMachine.Name))
+			if err := r.Client.Create(rctx.Context, claim); err != nil {
+				return false, fmt.Errorf("failed to create IPAddressClaim %s: %v", claim.Name, err)
+			}
+		} else if err != nil {
+			return false, fmt.Errorf("failed to get IPAddressClaim %s: %v", claimKey.Name, err)
+		}
+		if claim.Status.AddressRef.Name == "" {
+			log.Info(fmt.Sprintf("Waiting for an IP address to be allocated for IPAddressClaim %s", claim.Name))
+			pending = append(pending, claim.Name)
+		}
+	}
+	if !claimed {
+		return true, nil
+	}
+	if len(pending) > 0 {
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.IPAddressesAllocatedCondition, infrav1.WaitingForIPAddress, capiv1.ConditionSeverityInfo,
+			"waiting for the IPAM provider to fulfill IPAddressClaims %s", strings.Join(pending, ", "))
+		return false, nil
+	}
+	conditions.MarkTrue(rctx.NutanixMachine, infrav1.IPAddressesAllocatedCondition)
+	return true, nil
+}
+
+// ipAddressClaimRequeue returns the result of a reconcile waiting for IPAddressClaims to be fulfilled. The requeue
+// interval grows like for other external conditions, bounded by the configured IPAddressClaim requeue interval.
+func (r *NutanixMachineReconciler) ipAddressClaimRequeue(rctx *nctx.MachineContext) reconcile.Result {
+	result := requeueFor(requeueForExternalCondition, conditionAge(rctx.NutanixMachine, infrav1.IPAddressesAllocatedCondition))
+	if maxInterval := r.controllerConfig.ipAddressClaimRequeueInterval(); maxInterval > 0 && result.RequeueAfter > maxInterval {
+		result.RequeueAfter = maxInterval
+	}
+	return result
+}
+
+// getClaimedIPAddress returns the IP address allocated for the IPAddressClaim of the network interface with the given index
+func (r *NutanixMachineReconciler) getClaimedIPAddress(rctx *nctx.MachineContext, index int) (string, error) {
+	claim := &ipamv1.IPAddressClaim{}
+	claimKey := client.ObjectKey{Namespace: rctx.NutanixMachine.Namespace, Name: ipAddressClaimName(rctx.NutanixMachine, index)}
+	if err := r.Client.Get(rctx.Context, claimKey, claim); err != nil {
+		return "", fmt.Errorf("failed to get IPAddressClaim %s: %v", claimKey.Name, err)
+	}
+	if claim.Status.AddressRef.Name == "" {
+		return "", fmt.Errorf("no IP address was allocated for IPAddressClaim %s yet", claim.Name)
+	}
+	address := &ipamv1.IPAddress{}
+	addressKey := client.ObjectKey{Namespace: rctx.NutanixMachine.Namespace, Name: claim.Status.AddressRef.Name}
+	if err := r.Client.Get(rctx.Context, addressKey, address); err != nil {
+		return "", fmt.Errorf("failed to get IPAddress %s of IPAddressClaim %s: %v", addressKey.Name, claim.Name, err)
+	}
+	return address.Spec.Address, nil
+}
+
+// assignNICIPAddresses sets the static or claimed IP address of each network interface of the NutanixMachine on the
+// corresponding NIC of the VM spec. NICs without an IP address get their address with DHCP.
+func (r *NutanixMachineReconciler) assignNICIPAddresses(rctx *nctx.MachineContext, nicList []*nutanixClientV3.VMNic) error {
+	for i, nic := range rctx.NutanixMachine.Spec.NICs {
+		if i >= len(nicList) {
+			break
+		}
+		var ip string
+		switch {
+		case nic.IPAddress != nil:
+			ip = *nic.IPAddress
+		case nic.IPAddressPoolRef != nil:
+			claimedIP, err := r.getClaimedIPAddress(rctx, i)
+			if err != nil {
+				return err
+			}
+			ip = claimedIP
+		default:
+			continue
+		}
+		nicList[i].IPEndpointList = []*nutanixClientV3.IPAddress{{IP: &ip}}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+func newIPAMTestMachineContext(nics []infrav1.NutanixMachineNIC) *nctx.MachineContext {
+	return &nctx.MachineContext{
+		Context: context.Background(),
+		Cluster: &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+		NutanixMachine: &infrav1.NutanixMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default", UID: "machine-uid"},
+			Spec:       infrav1.NutanixMachineSpec{NICs: nics},
+		},
+	}
+}
+
+func newTestNICList(count int) []*nutanixClientV3.VMNic {
+	nicList := make([]*nutanixClientV3.VMNic, count)
+	for i := range nicList {
+		nicList[i] = &nutanixClientV3.VMNic{}
+	}
+	return nicList
+}
+
+func TestMachineSubnets(t *testing.T) {
+	g := NewWithT(t)
+	subnets := []infrav1.NutanixResourceIdentifier{{Type: infrav1.NutanixIdentifierName, Name: pointer.String("subnet")}}
+	nutanixMachine := &infrav1.NutanixMachine{}
+	g.Expect(machineSubnets(nutanixMachine, subnets)).To(Equal(subnets))
+
+	nicSubnet := infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: pointer.String(testSubnetUUID)}
+	nutanixMachine.Spec.NICs = []infrav1.NutanixMachineNIC{{Subnet: nicSubnet}}
+	g.Expect(machineSubnets(nutanixMachine, subnets)).To(Equal([]infrav1.NutanixResourceIdentifier{nicSubnet}))
+}
+
+func TestAssignStaticNICIPAddresses(t *testing.T) {
+	g := NewWithT(t)
+	rctx := newIPAMTestMachineContext([]infrav1.NutanixMachineNIC{
+		{IPAddress: pointer.String("10.0.0.10")},
+		{},
+	})
+	reconciler := &NutanixMachineReconciler{}
+
+	allocated, err := reconciler.reconcileIPAddressClaims(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allocated).To(BeTrue())
+	// Machines without claims do not get the condition
+	g.Expect(conditions.Has(rctx.NutanixMachine, infrav1.IPAddressesAllocatedCondition)).To(BeFalse())
+
+	nicList := newTestNICList(2)
+	g.Expect(reconciler.assignNICIPAddresses(rctx, nicList)).To(Succeed())
+	g.Expect(nicList[0].IPEndpointList).To(HaveLen(1))
+	g.Expect(*nicList[0].IPEndpointList[0].IP).To(Equal("10.0.0.10"))
+	// The second NIC gets its address with DHCP
+	g.Expect(nicList[1].IPEndpointList).To(BeEmpty())
+}
+
+func TestAssignClaimedNICIPAddresses(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+
+	poolRef := corev1.TypedLocalObjectReference{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"}
+	rctx := newIPAMTestMachineContext([]infrav1.NutanixMachineNIC{
+		{IPAddress: pointer.String("10.0.0.10")},
+		{IPAddressPoolRef: &poolRef},
+	})
+	reconciler := &NutanixMachineReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Scheme: scheme,
+	}
+
+	// The claim is created for the NIC referencing the pool only
+	allocated, err := reconciler.reconcileIPAddressClaims(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allocated).To(BeFalse())
+	g.Expect(conditions.IsFalse(rctx.NutanixMachine, infrav1.IPAddressesAllocatedCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(rctx.NutanixMachine, infrav1.IPAddressesAllocatedCondition)).To(Equal(infrav1.WaitingForIPAddress))
+	g.Expect(conditions.GetMessage(rctx.NutanixMachine, infrav1.IPAddressesAllocatedCondition)).To(ContainSubstring("machine-nic-1"))
+	claims := &ipamv1.IPAddressClaimList{}
+	g.Expect(reconciler.Client.List(ctx, claims)).To(Succeed())
+	g.Expect(claims.Items).To(HaveLen(1))
+	claim := &claims.Items[0]
+	g.Expect(claim.Name).To(Equal("machine-nic-1"))
+	g.Expect(claim.Spec.PoolRef).To(Equal(poolRef))
+	g.Expect(claim.Labels).To(HaveKeyWithValue(capiv1.ClusterLabelName, "cluster"))
+	g.Expect(metav1.IsControlledBy(claim, rctx.NutanixMachine)).To(BeTrue())
+
+	// The VM cannot be created before the address is allocated
+	g.Expect(reconciler.assignNICIPAddresses(rctx, newTestNICList(2))).NotTo(Succeed())
+
+	// The IPAM provider allocates the address
+	address := &ipamv1.IPAddress{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine-nic-1", Namespace: "default"},
+		Spec: ipamv1.IPAddressSpec{
+			ClaimRef: corev1.LocalObjectReference{Name: claim.Name},
+			PoolRef:  poolRef,
+			Address:  "10.0.1.20",
+			Prefix:   24,
+			Gateway:  "10.0.1.1",
+		},
+	}
+	g.Expect(reconciler.Client.Create(ctx, address)).To(Succeed())
+	claim.Status.AddressRef = corev1.LocalObjectReference{Name: address.Name}
+	g.Expect(reconciler.Client.Update(ctx, claim)).To(Succeed())
+
+	allocated, err = reconciler.reconcileIPAddressClaims(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allocated).To(BeTrue())
+	g.Expect(conditions.IsTrue(rctx.NutanixMachine, infrav1.IPAddressesAllocatedCondition)).To(BeTrue())
+	g.Expect(reconciler.Client.List(ctx, claims, client.InNamespace("default"))).To(Succeed())
+	g.Expect(claims.Items).To(HaveLen(1))
+
+	nicList := newTestNICList(2)
+	g.Expect(reconciler.assignNICIPAddresses(rctx, nicList)).To(Succeed())
+	g.Expect(*nicList[0].IPEndpointList[0].IP).To(Equal("10.0.0.10"))
+	g.Expect(*nicList[1].IPEndpointList[0].IP).To(Equal("10.0.1.20"))
+}
+
+func TestNutanixMachineReconcileNormalWaitsForIPAddressClaims(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	poolRef := corev1.TypedLocalObjectReference{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "pool"}
+	rctx := newIPAMTestMachineContext([]infrav1.NutanixMachineNIC{{IPAddressPoolRef: &poolRef}})
+	rctx.Cluster.Status.InfrastructureReady = true
+	rctx.Machine = &capiv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+		Spec:       capiv1.MachineSpec{Bootstrap: capiv1.Bootstrap{DataSecretName: pointer.String("bootstrap")}},
+	}
+	rctx.NutanixCluster = &infrav1.NutanixCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}}
+	bootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	reconciler := &NutanixMachineReconciler{
+		Client:           fake.NewClientBuilder().WithScheme(scheme).WithObjects(bootstrapSecret).Build(),
+		Scheme:           scheme,
+		controllerConfig: &ControllerConfig{IPAddressClaimRequeueInterval: 30 * time.Second},
+	}
+
+	// The machine is requeued while the claim is pending
+	result, err := reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(externalConditionMinRequeueInterval))
+	g.Expect(conditions.GetReason(rctx.NutanixMachine, infrav1.IPAddressesAllocatedCondition)).To(Equal(infrav1.WaitingForIPAddress))
+	g.Expect(conditions.GetReason(rctx.NutanixMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.WaitingForIPAddresses))
+
+	// The requeue interval grows the longer the claim is pending, bounded by the configured interval
+	for i := range rctx.NutanixMachine.Status.Conditions {
+		if rctx.NutanixMachine.Status.Conditions[i].Type == infrav1.IPAddressesAllocatedCondition {
+			rctx.NutanixMachine.Status.Conditions[i].LastTransitionTime = metav1.NewTime(time.Now().Add(-10 * time.Minute))
+		}
+	}
+	result, err = reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(30 * time.Second))
+	reconciler.controllerConfig = nil
+	g.Expect(reconciler.ipAddressClaimRequeue(rctx).RequeueAfter).To(Equal(externalConditionMaxRequeueInterval))
+
+	// The machine proceeds to create the VM once the address is bound
+	claim := &ipamv1.IPAddressClaim{}
+	g.Expect(reconciler.Client.Get(ctx, client.ObjectKey{Namespace: "default", Name: "machine-nic-0"}, claim)).To(Succeed())
+	claim.Status.AddressRef = corev1.LocalObjectReference{Name: "machine-nic-0"}
+	g.Expect(reconciler.Client.Update(ctx, claim)).To(Succeed())
+	allocated, err := reconciler.reconcileIPAddressClaims(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allocated).To(BeTrue())
+	g.Expect(conditions.IsTrue(rctx.NutanixMachine, infrav1.IPAddressesAllocatedCondition)).To(BeTrue())
+}
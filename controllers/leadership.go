@@ -0,0 +1,94 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errLeadershipLost is returned when a request to Prism Central is not submitted since the controller is not the
+// leader anymore
+var errLeadershipLost = errors.New("the controller does not hold the leader election lease")
+
+// leadership tracks whether the controller holds the leader election lease. Only the leader polls tasks and mutates
+// VMs in Prism Central: reconciles short-circuit while the lease is not held, and the context of a reconcile is canceled
+// once the lease is lost, which stops waiting for tasks and aborts pending requests. A new leader finds VMs whose
+// creation was submitted by the previous leader by the UUID in the NutanixMachine status or by name.
+//
+// leadership is added to the manager as a runnable that needs leader election. The manager starts it once the lease is
+// acquired, or right away if leader election is disabled, and cancels its context once the lease is lost. The lease is
+// never acquired again by the same manager.
+type leadership struct {
+	mu   sync.Mutex
+	held bool
+	// lost is closed once the lease is lost
+	lost chan struct{}
+}
+
+// newLeadership returns a leadership that does not hold the lease until it is started by the manager
+func newLeadership() *leadership {
+	return &leadership{lost: make(chan struct{})}
+}
+
+// Start marks the lease as held until the context is canceled
+func (l *leadership) Start(ctx context.Context) error {
+	l.mu.Lock()
+	l.held = true
+	l.mu.Unlock()
+
+	<-ctx.Done()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held = false
+	close(l.lost)
+	return nil
+}
+
+// NeedLeaderElection makes the manager start the leadership only once the lease is acquired
+func (l *leadership) NeedLeaderElection() bool {
+	return true
+}
+
+// isLeader returns true if the lease is held. A nil leadership, e.g. of a reconciler that was not set up with a
+// manager, always holds the lease.
+func (l *leadership) isLeader() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held
+}
+
+// withLeadership returns a context that is canceled once the lease is lost
+func (l *leadership) withLeadership(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if l == nil {
+		return ctx, cancel
+	}
+	go func() {
+		select {
+		case <-l.lost:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+// machineTimeToReady observes the provisioning latency of NutanixMachines
+var machineTimeToReady = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name: "capx_nutanixmachine_time_to_ready_seconds",
+		Help: "Duration from the creation of a NutanixMachine until its VM is provisioned and the NutanixMachine is ready.",
+		// 15s to about 2h
+		Buckets: prometheus.ExponentialBuckets(15, 2, 10),
+	},
+	[]string{"failure_domain"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(machineTimeToReady)
+}
+
+// observeMachineTimeToReady records the duration from the creation of the NutanixMachine until now. The creation
+// timestamp is used as the start time, so the duration is not reset by restarts of the controller.
+func observeMachineTimeToReady(rctx *nctx.MachineContext) {
+	created := rctx.NutanixMachine.CreationTimestamp
+	if created.IsZero() {
+		return
+	}
+	machineTimeToReady.WithLabelValues(getMachineFailureDomainName(rctx)).Observe(time.Since(created.Time).Seconds())
+}
+
+// getMachineFailureDomainName returns the failure domain of the Machine, or the failure domain the NutanixMachine was
+// placed in. Returns an empty string if the Machine is not placed in a failure domain.
+func getMachineFailureDomainName(rctx *nctx.MachineContext) string {
+	if rctx.Machine.Spec.FailureDomain != nil && *rctx.Machine.Spec.FailureDomain != "" {
+		return *rctx.Machine.Spec.FailureDomain
+	}
+	if rctx.NutanixMachine.Status.FailureDomain != nil {
+		return *rctx.NutanixMachine.Status.FailureDomain
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+// machineTimeToReadySamples returns the number of samples and their sum observed for the failure domain
+func machineTimeToReadySamples(g *WithT, failureDomain string) (uint64, float64) {
+	metric := &dto.Metric{}
+	g.Expect(machineTimeToReady.WithLabelValues(failureDomain).(prometheus.Histogram).Write(metric)).To(Succeed())
+	return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+}
+
+func TestNutanixMachineTimeToReadyMetric(t *testing.T) {
+	g := NewWithT(t)
+	const (
+		clusterName   = "cluster"
+		failureDomain = "time-to-ready-fd"
+		vmUUID        = "00000000-0000-0000-0000-000000000080"
+	)
+	// The NutanixMachine was created before the controller started
+	ntnxMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "machine",
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-10 * time.Minute)),
+		},
+		Spec: infrav1.NutanixMachineSpec{
+			BootstrapRef:   &corev1.ObjectReference{Kind: "Secret", Name: "bootstrap", Namespace: "default"},
+			ExistingVMUUID: vmUUID,
+		},
+	}
+	bootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	// The existing VM already carries the categories and description of the Machine
+	service := &existingVMTestService{
+		vms: []*nutanixClientV3.VMIntentResponse{
+			{
+				Metadata: &nutanixClientV3.Metadata{
+					UUID:       pointer.String(vmUUID),
+					Categories: map[string]string{infrav1.DefaultCAPICategoryKeyForName: clusterName},
+				},
+				Spec: &nutanixClientV3.VM{
+					Name:        pointer.String("machine"),
+					Description: pointer.String(buildVMDescription("default", clusterName, "machine")),
+				},
+				Status: &nutanixClientV3.VMDefStatus{
+					Resources: &nutanixClientV3.VMResourcesDefStatus{
+						NicList: []*nutanixClientV3.VMNicOutputStatus{
+							{IPEndpointList: []*nutanixClientV3.IPAddress{{IP: pointer.String("10.0.0.10")}}},
+						},
+					},
+				},
+			},
+		},
+	}
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	reconciler := &NutanixMachineReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(ntnxMachine, bootstrapSecret).Build(),
+	}
+	rctx := &nctx.MachineContext{
+		Context:       context.Background(),
+		NutanixClient: &nutanixClientV3.Client{V3: service},
+		Cluster: &capiv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: "default"},
+			Status:     capiv1.ClusterStatus{InfrastructureReady: true},
+		},
+		Machine: &capiv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+			Spec:       capiv1.MachineSpec{FailureDomain: pointer.String(failureDomain)},
+		},
+		NutanixCluster: &infrav1.NutanixCluster{ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: "default"}},
+		NutanixMachine: ntnxMachine,
+	}
+	count, _ := machineTimeToReadySamples(g, failureDomain)
+	g.Expect(count).To(BeZero())
+
+	_, err := reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ntnxMachine.Status.Ready).To(BeTrue())
+	count, sum := machineTimeToReadySamples(g, failureDomain)
+	g.Expect(count).To(Equal(uint64(1)))
+	g.Expect(sum).To(BeNumerically(">=", (10 * time.Minute).Seconds()))
+
+	// The duration is only observed once the NutanixMachine becomes ready
+	rctx.Machine.Status.InfrastructureReady = true
+	rctx.Machine.Spec.ProviderID = pointer.String(ntnxMachine.Spec.ProviderID)
+	ntnxMachine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: "machine"}
+	_, err = reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	count, _ = machineTimeToReadySamples(g, failureDomain)
+	g.Expect(count).To(Equal(uint64(1)))
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+// nodeDrainTimedOutEventReason is the reason of the events recorded when the VM of a deleted NutanixMachine is deleted
+// although its Node was not drained within the node drain timeout
+const nodeDrainTimedOutEventReason = "NodeDrainTimedOut"
+
+// reconcileNodeDrain returns true once the VM of the deleted NutanixMachine can be deleted. If a node drain timeout is
+// configured, the VM is kept until the Node of the Machine is cordoned and drained in the workload cluster, in addition
+// to the drain of CAPI, or until the timeout expired. The NodeDrained condition tracks the wait. Machines without a
+// Node and Machines of deleted Clusters are not waited for.
+func (r *NutanixMachineReconciler) reconcileNodeDrain(rctx *nctx.MachineContext) bool {
+	log := ctrl.LoggerFrom(rctx.Context)
+	timeout := r.controllerConfig.nodeDrainTimeout()
+	if timeout == 0 || rctx.Cluster == nil || !rctx.Cluster.DeletionTimestamp.IsZero() ||
+		rctx.Machine == nil || rctx.Machine.Status.NodeRef == nil {
+		return true
+	}
+	if conditions.IsTrue(rctx.NutanixMachine, infrav1.NodeDrainedCondition) ||
+		conditions.GetReason(rctx.NutanixMachine, infrav1.NodeDrainedCondition) == infrav1.NodeDrainTimedOut {
+		return true
+	}
+	nodeName := rctx.Machine.Status.NodeRef.Name
+	drained, msg, err := r.isNodeDrained(rctx, nodeName)
+	if err != nil {
+		msg = fmt.Sprintf("failed to check whether node %s is drained: %v", nodeName, err)
+	}
+	if drained {
+		log.Info(fmt.Sprintf("Node %s is drained. Continuing with delete", nodeName))
+		conditions.MarkTrue(rctx.NutanixMachine, infrav1.NodeDrainedCondition)
+		return true
+	}
+	if conditions.IsFalse(rctx.NutanixMachine, infrav1.NodeDrainedCondition) &&
+		conditionAge(rctx.NutanixMachine, infrav1.NodeDrainedCondition) >= timeout {
+		msg = fmt.Sprintf("node %s was not drained within %s: %s. Deleting the VM anyway", nodeName, timeout, msg)
+		log.Info(msg)
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.NodeDrainedCondition, infrav1.NodeDrainTimedOut, capiv1.ConditionSeverityWarning, "%s", msg)
+		r.recordEvent(rctx.NutanixMachine, corev1.EventTypeWarning, nodeDrainTimedOutEventReason, msg)
+		return true
+	}
+	log.Info(fmt.Sprintf("Waiting for node %s to be drained before deleting the VM: %s", nodeName, msg))
+	conditions.MarkFalse(rctx.NutanixMachine, infrav1.NodeDrainedCondition, infrav1.NodeNotDrained, capiv1.ConditionSeverityInfo, "%s", msg)
+	return false
+}
+
+// isNodeDrained returns true if the Node with the given name is cordoned and runs no pods that are evicted when the
+// Node is drained, or if the Node does not exist anymore. Returns a message describing why the Node is not drained
+// otherwise.
+func (r *NutanixMachineReconciler) isNodeDrained(rctx *nctx.MachineContext, nodeName string) (bool, string, error) {
+	clusterKey := apitypes.NamespacedName{Namespace: rctx.Cluster.Namespace, Name: rctx.Cluster.Name}
+	remoteClient, err := nctx.GetRemoteClient(rctx.Context, r.Client, clusterKey)
+	if err != nil {
+		return false, "", err
+	}
+	node := &corev1.Node{}
+	if err := remoteClient.Get(rctx.Context, client.ObjectKey{Name: nodeName}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, "", nil
+		}
+		return false, "", err
+	}
+	if !node.Spec.Unschedulable {
+		return false, fmt.Sprintf("node %s is not cordoned", nodeName), nil
+	}
+	pods := &corev1.PodList{}
+	if err := remoteClient.List(rctx.Context, pods, client.MatchingFields{"spec.nodeName": nodeName}); err != nil {
+		return false, "", err
+	}
+	remaining := 0
+	for i := range pods.Items {
+		if pods.Items[i].Spec.NodeName == nodeName && isPodEvictedByDrain(&pods.Items[i]) {
+			remaining++
+		}
+	}
+	if remaining > 0 {
+		return false, fmt.Sprintf("%d pods are still running on node %s", remaining, nodeName), nil
+	}
+	return true, "", nil
+}
+
+// isPodEvictedByDrain returns true if the pod is evicted when its Node is drained. Pods of DaemonSets, mirror pods of
+// static pods and completed pods are not evicted.
+func isPodEvictedByDrain(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	if controllerRef := metav1.GetControllerOf(pod); controllerRef != nil && controllerRef.Kind == "DaemonSet" {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+func newNodeDrainTestPod(name, nodeName string, phase corev1.PodPhase, ownerKind string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: nodeName},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+	if ownerKind != "" {
+		pod.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "apps/v1",
+			Kind:       ownerKind,
+			Name:       "owner",
+			UID:        "owner-uid",
+			Controller: pointer.Bool(true),
+		}}
+	}
+	return pod
+}
+
+func TestNutanixMachineReconcileNodeDrain(t *testing.T) {
+	tests := []struct {
+		name              string
+		nodeDrainTimeout  time.Duration
+		unschedulable     bool
+		noNode            bool
+		pods              []client.Object
+		notDrainedSince   time.Duration
+		expectedProceed   bool
+		expectedReason    string
+		expectedCondition bool
+		expectedEvents    []string
+	}{
+		{
+			name:            "proceeds without waiting if disabled",
+			expectedProceed: true,
+		},
+		{
+			name:             "proceeds if the node is drained",
+			nodeDrainTimeout: time.Minute,
+			unschedulable:    true,
+			pods: []client.Object{
+				newNodeDrainTestPod("daemonset", "node", corev1.PodRunning, "DaemonSet"),
+				newNodeDrainTestPod("completed", "node", corev1.PodSucceeded, "ReplicaSet"),
+				newNodeDrainTestPod("other-node", "other-node", corev1.PodRunning, "ReplicaSet"),
+			},
+			expectedProceed:   true,
+			expectedCondition: true,
+		},
+		{
+			name:              "proceeds if the node does not exist",
+			nodeDrainTimeout:  time.Minute,
+			noNode:            true,
+			expectedProceed:   true,
+			expectedCondition: true,
+		},
+		{
+			name:             "waits if the node is not cordoned",
+			nodeDrainTimeout: time.Minute,
+			expectedReason:   infrav1.NodeNotDrained,
+		},
+		{
+			name:             "waits if pods are running on the node",
+			nodeDrainTimeout: time.Minute,
+			unschedulable:    true,
+			pods: []client.Object{
+				newNodeDrainTestPod("workload", "node", corev1.PodRunning, "ReplicaSet"),
+			},
+			expectedReason: infrav1.NodeNotDrained,
+		},
+		{
+			name:             "proceeds once the timeout expired",
+			nodeDrainTimeout: time.Minute,
+			unschedulable:    true,
+			pods: []client.Object{
+				newNodeDrainTestPod("workload", "node", corev1.PodRunning, "ReplicaSet"),
+			},
+			notDrainedSince: 2 * time.Minute,
+			expectedProceed: true,
+			expectedReason:  infrav1.NodeDrainTimedOut,
+			expectedEvents:  []string{nodeDrainTimedOutEventReason},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+			objects := tt.pods
+			if !tt.noNode {
+				objects = append(objects, &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "node"},
+					Spec:       corev1.NodeSpec{Unschedulable: tt.unschedulable},
+				})
+			}
+			clusterKey := client.ObjectKey{Namespace: "default", Name: "cluster"}
+			nctx.RemoteClientCache[clusterKey] = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+			defer nctx.RemoveRemoteClient(clusterKey)
+
+			nutanixMachine := &infrav1.NutanixMachine{}
+			if tt.notDrainedSince != 0 {
+				conditions.Set(nutanixMachine, &capiv1.Condition{
+					Type:               infrav1.NodeDrainedCondition,
+					Status:             corev1.ConditionFalse,
+					Severity:           capiv1.ConditionSeverityInfo,
+					Reason:             infrav1.NodeNotDrained,
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-tt.notDrainedSince)),
+				})
+			}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixMachineReconciler{
+				Recorder:         recorder,
+				controllerConfig: &ControllerConfig{NodeDrainTimeout: tt.nodeDrainTimeout},
+			}
+			proceed := reconciler.reconcileNodeDrain(&nctx.MachineContext{
+				Context: context.Background(),
+				Cluster: &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: clusterKey.Name, Namespace: clusterKey.Namespace}},
+				Machine: &capiv1.Machine{
+					ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+					Status:     capiv1.MachineStatus{NodeRef: &corev1.ObjectReference{Kind: "Node", Name: "node"}},
+				},
+				NutanixMachine: nutanixMachine,
+			})
+			g.Expect(proceed).To(Equal(tt.expectedProceed))
+			g.Expect(receivedEventReasons(recorder)).To(ConsistOf(tt.expectedEvents))
+			switch {
+			case tt.expectedCondition:
+				g.Expect(conditions.IsTrue(nutanixMachine, infrav1.NodeDrainedCondition)).To(BeTrue())
+			case tt.expectedReason != "":
+				g.Expect(conditions.IsFalse(nutanixMachine, infrav1.NodeDrainedCondition)).To(BeTrue())
+				g.Expect(conditions.GetReason(nutanixMachine, infrav1.NodeDrainedCondition)).To(Equal(tt.expectedReason))
+			default:
+				g.Expect(conditions.Has(nutanixMachine, infrav1.NodeDrainedCondition)).To(BeFalse())
+			}
+		})
+	}
+}
@@ -18,7 +18,13 @@ package controllers
 
 import (
 	"context"
+	"crypto/x509"
+	goerrors "errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -28,6 +34,8 @@ import (
 	apitypes "k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
 	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	capiutil "sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
@@ -45,15 +53,56 @@ import (
 	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
 	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
 	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
 )
 
+const (
+	// failureDomainsRetryInterval is the interval after which failure domains that could not be resolved are retried
+	failureDomainsRetryInterval = time.Minute
+	// prismCentralFailureThreshold is the number of consecutive connection failures after which requests to a Prism Central endpoint are held back
+	prismCentralFailureThreshold = 3
+	// prismCentralBaseBackoff is the initial delay requests to an unreachable Prism Central endpoint are held back for
+	prismCentralBaseBackoff = 10 * time.Second
+	// prismCentralMaxBackoff is the maximum delay requests to an unreachable Prism Central endpoint are held back for
+	prismCentralMaxBackoff = 5 * time.Minute
+	// trustBundleExpiryWarningPeriod is the time before the expiry of a certificate of the additional trust bundle from
+	// which on the certificate is reported as expiring
+	trustBundleExpiryWarningPeriod = 30 * 24 * time.Hour
+
+	// Reasons of the events recorded on transitions of the NutanixCluster
+	finalizerAddedEventReason                   = "FinalizerAdded"
+	finalizerRemovedEventReason                 = "FinalizerRemoved"
+	credentialSecretFinalizerAddedEventReason   = "CredentialSecretFinalizerAdded"
+	credentialSecretFinalizerRemovedEventReason = "CredentialSecretFinalizerRemoved"
+	credentialSecretChangedEventReason          = "CredentialSecretChanged"
+	credentialSecretDeletedEventReason          = "CredentialSecretDeleted"
+	trustBundleChangedEventReason               = "TrustBundleChanged"
+	failureDomainsReconciledEventReason         = "FailureDomainsReconciled"
+	categoriesCreatedEventReason                = "CategoriesCreated"
+	categoriesDeletedEventReason                = "CategoriesDeleted"
+	clusterReadyEventReason                     = "ClusterReady"
+)
+
+// errCredentialNamespaceNotAllowed is returned for credential secrets in a namespace that the NutanixCluster is not
+// allowed to reference
+var errCredentialNamespaceNotAllowed = goerrors.New("credential namespace not allowed")
+
 // NutanixClusterReconciler reconciles a NutanixCluster object
 type NutanixClusterReconciler struct {
 	Client            client.Client
 	SecretInformer    coreinformers.SecretInformer
 	ConfigMapInformer coreinformers.ConfigMapInformer
 	Scheme            *runtime.Scheme
+	Recorder          record.EventRecorder
 	controllerConfig  *ControllerConfig
+	// circuitBreaker holds back reconciliations of NutanixClusters with an unreachable Prism Central endpoint
+	circuitBreaker *nutanixClient.CircuitBreaker
+	// leadership tracks whether the controller holds the leader election lease. Nil if not set up with a manager.
+	leadership *leadership
+	// seedVMsCollected holds the UIDs of the NutanixClusters whose seed VMs were deleted since the seed disk pool is
+	// disabled
+	seedVMsCollected sync.Map
 }
 
 func NewNutanixClusterReconciler(client client.Client, secretInformer coreinformers.SecretInformer, configMapInformer coreinformers.ConfigMapInformer, scheme *runtime.Scheme, copts ...ControllerConfigOpts) (*NutanixClusterReconciler, error) {
@@ -69,15 +118,22 @@ func NewNutanixClusterReconciler(client client.Client, secretInformer coreinform
 		ConfigMapInformer: configMapInformer,
 		Scheme:            scheme,
 		controllerConfig:  controllerConf,
+		circuitBreaker:    nutanixClient.NewCircuitBreaker(clock.RealClock{}, prismCentralFailureThreshold, prismCentralBaseBackoff, prismCentralMaxBackoff),
 	}, nil
 }
 
 // SetupWithManager sets up the NutanixCluster controller with the Manager.
 func (r *NutanixClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
 	log := ctrl.LoggerFrom(ctx)
+	r.Recorder = mgr.GetEventRecorderFor("nutanixcluster-controller")
+	r.leadership = newLeadership()
+	if err := mgr.Add(r.leadership); err != nil {
+		return fmt.Errorf("failed to add leadership: %v", err)
+	}
 	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&infrav1.NutanixCluster{}). // Watch the controlled, infrastructure resource.
-		WithOptions(controller.Options{MaxConcurrentReconciles: r.controllerConfig.MaxConcurrentReconciles}).
+		Owns(&infrav1.NutanixFailureDomain{}).
+		WithOptions(r.controllerOptions()).
 		Build(r)
 	if err != nil {
 		return err
@@ -98,14 +154,157 @@ func (r *NutanixClusterReconciler) SetupWithManager(ctx context.Context, mgr ctr
 		return err
 	}
 
+	if err = c.Watch(
+		// Watch the credential secrets to detect rotated credentials. Secrets of other namespaces are not owned by the
+		// NutanixClusters referencing them.
+		&source.Kind{Type: &corev1.Secret{}},
+		handler.EnqueueRequestsFromMapFunc(r.mapSecretToNutanixClusters(ctx)),
+	); err != nil {
+		return err
+	}
+
+	if err = c.Watch(
+		// Watch the trust bundle configmaps to detect rotated CA certificates.
+		&source.Kind{Type: &corev1.ConfigMap{}},
+		handler.EnqueueRequestsFromMapFunc(r.mapTrustBundleConfigMapToNutanixClusters(ctx)),
+	); err != nil {
+		return err
+	}
+
+	if err = c.Watch(
+		// Watch the control plane machines to verify they are spread across failure domains.
+		&source.Kind{Type: &infrav1.NutanixMachine{}},
+		handler.EnqueueRequestsFromMapFunc(r.mapNutanixMachineToNutanixCluster(ctx)),
+	); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// controllerOptions returns the options of the NutanixCluster controller
+func (r *NutanixClusterReconciler) controllerOptions() controller.Options {
+	return controller.Options{MaxConcurrentReconciles: r.controllerConfig.MaxConcurrentReconciles}
+}
+
+func (r *NutanixClusterReconciler) mapNutanixMachineToNutanixCluster(ctx context.Context) handler.MapFunc {
+	return func(o client.Object) []ctrl.Request {
+		log := ctrl.LoggerFrom(ctx)
+		nutanixMachine, ok := o.(*infrav1.NutanixMachine)
+		if !ok {
+			log.Error(fmt.Errorf("expected a NutanixMachine object in mapNutanixMachineToNutanixCluster but was %T", o), "unexpected type")
+			return nil
+		}
+		if !nctx.IsControlPlaneMachine(nutanixMachine) {
+			return nil
+		}
+		cluster, err := capiutil.GetClusterFromMetadata(ctx, r.Client, nutanixMachine.ObjectMeta)
+		if err != nil {
+			log.V(1).Info(fmt.Sprintf("CAPI cluster for NutanixMachine %s not found: %v", nutanixMachine.Name, err))
+			return nil
+		}
+		if cluster.Spec.InfrastructureRef == nil || cluster.Spec.InfrastructureRef.Kind != infrav1.NutanixClusterKind {
+			return nil
+		}
+		return []ctrl.Request{{
+			NamespacedName: client.ObjectKey{
+				Namespace: cluster.Namespace,
+				Name:      cluster.Spec.InfrastructureRef.Name,
+			},
+		}}
+	}
+}
+
+// mapTrustBundleConfigMapToNutanixClusters returns the requests of the NutanixClusters using the configmap as their
+// additional trust bundle
+func (r *NutanixClusterReconciler) mapTrustBundleConfigMapToNutanixClusters(ctx context.Context) handler.MapFunc {
+	return func(o client.Object) []ctrl.Request {
+		log := ctrl.LoggerFrom(ctx)
+		cm, ok := o.(*corev1.ConfigMap)
+		if !ok {
+			log.Error(fmt.Errorf("expected a ConfigMap object in mapTrustBundleConfigMapToNutanixClusters but was %T", o), "unexpected type")
+			return nil
+		}
+		nutanixClusters := &infrav1.NutanixClusterList{}
+		if err := r.Client.List(ctx, nutanixClusters); err != nil {
+			log.Error(err, "failed to list NutanixClusters")
+			return nil
+		}
+		requests := make([]ctrl.Request, 0)
+		for _, nutanixCluster := range nutanixClusters.Items {
+			if usesTrustBundleConfigMap(&nutanixCluster, cm.Namespace, cm.Name) {
+				requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&nutanixCluster)})
+			}
+		}
+		return requests
+	}
+}
+
+// mapSecretToNutanixClusters returns the requests of the NutanixClusters using the secret for their credentials or
+// client certificate
+func (r *NutanixClusterReconciler) mapSecretToNutanixClusters(ctx context.Context) handler.MapFunc {
+	return func(o client.Object) []ctrl.Request {
+		log := ctrl.LoggerFrom(ctx)
+		secret, ok := o.(*corev1.Secret)
+		if !ok {
+			log.Error(fmt.Errorf("expected a Secret object in mapSecretToNutanixClusters but was %T", o), "unexpected type")
+			return nil
+		}
+		nutanixClusters := &infrav1.NutanixClusterList{}
+		if err := r.Client.List(ctx, nutanixClusters); err != nil {
+			log.Error(err, "failed to list NutanixClusters")
+			return nil
+		}
+		requests := make([]ctrl.Request, 0)
+		for _, nutanixCluster := range nutanixClusters.Items {
+			if usesSecret(&nutanixCluster, secret.Namespace, secret.Name) {
+				requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&nutanixCluster)})
+			}
+		}
+		return requests
+	}
+}
+
+// usesSecret returns true if the credential secret or the client certificate of the NutanixCluster is the given secret
+func usesSecret(nutanixCluster *infrav1.NutanixCluster, namespace, name string) bool {
+	if prismCentral := nutanixCluster.Spec.PrismCentral; prismCentral != nil && prismCentral.CredentialRef != nil {
+		credentialRef := prismCentral.CredentialRef
+		if credentialRef.Kind == credentialTypes.SecretKind && credentialRefNamespace(nutanixCluster, credentialRef) == namespace && credentialRef.Name == name {
+			return true
+		}
+	}
+	clientCertificate := nutanixCluster.Spec.ClientCertificate
+	if clientCertificate == nil || nutanixCluster.Namespace != namespace {
+		return false
+	}
+	for _, ref := range []*corev1.SecretKeySelector{clientCertificate.CertificateRef, clientCertificate.KeyRef} {
+		if ref != nil && ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// usesTrustBundleConfigMap returns true if the additional trust bundle of the NutanixCluster is the given configmap
+func usesTrustBundleConfigMap(nutanixCluster *infrav1.NutanixCluster, namespace, name string) bool {
+	if nutanixCluster.Spec.PrismCentral == nil || nutanixCluster.Spec.PrismCentral.AdditionalTrustBundle == nil {
+		return false
+	}
+	trustBundleRef := nutanixCluster.Spec.PrismCentral.AdditionalTrustBundle
+	trustBundleNamespace := trustBundleRef.Namespace
+	if trustBundleNamespace == "" {
+		trustBundleNamespace = nutanixCluster.Namespace
+	}
+	return trustBundleRef.Kind == credentialTypes.NutanixTrustBundleKindConfigMap && trustBundleNamespace == namespace && trustBundleRef.Name == name
+}
+
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;update;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=nutanixclusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=nutanixclusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=nutanixclusters/finalizers,verbs=update
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=nutanixfailuredomains,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -118,7 +317,17 @@ func (r *NutanixClusterReconciler) SetupWithManager(ctx context.Context, mgr ctr
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.8.3/pkg/reconcile
 func (r *NutanixClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, reterr error) {
 	log := ctrl.LoggerFrom(ctx)
+	if !r.leadership.isLeader() {
+		log.Info("Skipping the reconciliation of the NutanixCluster since the controller is not the leader")
+		return reconcile.Result{}, nil
+	}
 	log.Info("Reconciling the NutanixCluster")
+	defer func() {
+		res = jitterRequeue(res, r.controllerConfig.requeueJitterFactor())
+	}()
+	// Tasks are not polled and Prism Central resources are not mutated anymore once the leadership is lost
+	ctx, cancel := r.leadership.withLeadership(ctx)
+	defer cancel()
 
 	var err error
 
@@ -149,20 +358,29 @@ func (r *NutanixClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		log.Info("Waiting for Cluster Controller to set OwnerRef for the NutanixCluster object")
 		return reconcile.Result{}, nil
 	}
-	if annotations.IsPaused(capiCluster, cluster) {
-		log.Info("The NutanixCluster object linked to a cluster that is paused")
+	ctx = nutanixClient.WithLogValues(ctx, nutanixClient.LogKeyCluster, capiCluster.Name)
+	log = ctrl.LoggerFrom(ctx)
+	if isReconciliationPaused(capiCluster, cluster) {
+		log.Info("The NutanixCluster object is paused or linked to a cluster that is paused")
+		// Finalizers are kept while the reconciliation is paused
+		if err := markReconciliationPaused(ctx, r.Client, cluster); err != nil {
+			log.Error(err, "failed to set the Paused condition of the NutanixCluster")
+			return reconcile.Result{}, err
+		}
 		return reconcile.Result{}, nil
 	}
-	log.Info(fmt.Sprintf("Fetched the owner Cluster: %s", capiCluster.Name))
+	log.V(1).Info("Fetched the owner Cluster")
 
 	// Initialize the patch helper.
 	patchHelper, err := patch.NewHelper(cluster, r.Client)
 	if err != nil {
 		log.Error(err, "Failed to configure the patch helper")
-		return ctrl.Result{Requeue: true}, nil
+		return requeueFor(requeueForTransientError, 0), nil
 	}
+	conditions.Delete(cluster, infrav1.PausedCondition)
 
 	defer func() {
+		cluster.Status.Phase = nutanixClusterPhase(cluster)
 		// Always attempt to Patch the NutanixCluster object and its status after each reconciliation.
 		if err := patchHelper.Patch(ctx, cluster); err != nil {
 			reterr = kerrors.NewAggregate([]error{reterr, err})
@@ -173,32 +391,61 @@ func (r *NutanixClusterReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	err = r.reconcileCredentialRef(ctx, cluster)
 	if err != nil {
 		log.Error(err, fmt.Sprintf("error occurred while reconciling credential ref for cluster %s", capiCluster.Name))
-		conditions.MarkFalse(cluster, infrav1.CredentialRefSecretOwnerSetCondition, infrav1.CredentialRefSecretOwnerSetFailed, capiv1.ConditionSeverityError, err.Error())
+		reason := infrav1.CredentialRefSecretOwnerSetFailed
+		if goerrors.Is(err, errCredentialNamespaceNotAllowed) {
+			reason = infrav1.CredentialRefNamespaceNotAllowed
+		} else if goerrors.Is(err, nutanixClient.ErrInvalidCredentials) {
+			reason = infrav1.CredentialSecretInvalid
+		}
+		r.markFalseWithEvent(cluster, infrav1.CredentialRefSecretOwnerSetCondition, reason, capiv1.ConditionSeverityError, err.Error())
 		return reconcile.Result{}, err
 	}
 	conditions.MarkTrue(cluster, infrav1.CredentialRefSecretOwnerSetCondition)
 
-	v3Client, err := CreateNutanixClient(ctx, r.SecretInformer, r.ConfigMapInformer, cluster)
+	err = r.reconcileTrustBundleRef(ctx, cluster)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("error occurred while reconciling trust bundle ref for cluster %s", capiCluster.Name))
+		return reconcile.Result{}, err
+	}
+
+	r.reconcileInsecureTLS(ctx, cluster)
+
+	if result, open := r.reconcilePrismCentralCircuit(ctx, cluster); open {
+		return result, nil
+	}
+
+	// Prism Central requests are canceled once the reconcile timeout expired. The NutanixCluster is still patched
+	// with the context of the reconcile request.
+	reconcileCtx, cancel := withReconcileTimeout(ctx, r.controllerConfig.reconcileTimeout())
+	defer cancel()
+
+	v3Client, err := CreateNutanixClient(reconcileCtx, r.SecretInformer, r.ConfigMapInformer, cluster)
 	if err != nil {
 		conditions.MarkFalse(cluster, infrav1.PrismCentralClientCondition, infrav1.PrismCentralClientInitializationFailed, capiv1.ConditionSeverityError, err.Error())
-		return ctrl.Result{Requeue: true}, fmt.Errorf("nutanix client error: %v", err)
+		return ctrl.Result{Requeue: true}, checkReconcileTimeout(reconcileCtx, r.controllerConfig.reconcileTimeout(), fmt.Errorf("nutanix client error: %v", err))
 	}
 	conditions.MarkTrue(cluster, infrav1.PrismCentralClientCondition)
+	if version := nutanixClient.NutanixClientCache.PrismCentralVersion(cluster); version != "" {
+		cluster.Status.PrismCentralVersion = version
+	}
 
 	rctx := &nctx.ClusterContext{
-		Context:        ctx,
+		Context:        reconcileCtx,
 		Cluster:        capiCluster,
 		NutanixCluster: cluster,
 		NutanixClient:  v3Client,
 	}
 
 	// Check for request action
+	var result reconcile.Result
 	if !cluster.DeletionTimestamp.IsZero() {
 		// NutanixCluster is being deleted
-		return r.reconcileDelete(rctx)
+		result, err = r.reconcileDelete(rctx)
+	} else {
+		result, err = r.reconcileNormal(rctx)
 	}
 
-	return r.reconcileNormal(rctx)
+	return r.recordPrismCentralResult(ctx, cluster, result, checkReconcileTimeout(reconcileCtx, r.controllerConfig.reconcileTimeout(), err))
 }
 
 func (r *NutanixClusterReconciler) reconcileDelete(rctx *nctx.ClusterContext) (reconcile.Result, error) {
@@ -213,11 +460,22 @@ func (r *NutanixClusterReconciler) reconcileDelete(rctx *nctx.ClusterContext) (r
 
 	if len(nutanixMachines) > 0 {
 		log.Info(fmt.Sprintf("waiting for %d nutanixmachines to be deleted", len(nutanixMachines)))
-		return reconcile.Result{RequeueAfter: 5 * time.Second}, nil
+		var waited time.Duration
+		if deletionTimestamp := rctx.NutanixCluster.DeletionTimestamp; deletionTimestamp != nil {
+			waited = time.Since(deletionTimestamp.Time)
+		}
+		return requeueFor(requeueForExternalCondition, waited), nil
 	}
 
 	log.V(1).Info("no existing nutanixMachine resources found. Continuing with deleting cluster")
 
+	// Seed VMs carry the categories of the cluster, so they are deleted before the categories
+	err = r.reconcileSeedVMsDelete(rctx)
+	if err != nil {
+		log.Error(err, "error occurred while deleting seed VMs of cluster")
+		return reconcile.Result{}, err
+	}
+
 	err = r.reconcileCategoriesDelete(rctx)
 	if err != nil {
 		log.Error(err, "error occurred while running deletion of categories")
@@ -231,7 +489,9 @@ func (r *NutanixClusterReconciler) reconcileDelete(rctx *nctx.ClusterContext) (r
 	}
 
 	// Remove the finalizer from the NutanixCluster object
-	ctrlutil.RemoveFinalizer(rctx.NutanixCluster, infrav1.NutanixClusterFinalizer)
+	if ctrlutil.RemoveFinalizer(rctx.NutanixCluster, infrav1.NutanixClusterFinalizer) {
+		r.recordEvent(rctx.NutanixCluster, corev1.EventTypeNormal, finalizerRemovedEventReason, "Removed the finalizer of the NutanixCluster")
+	}
 
 	// Remove the workload cluster client from cache
 	clusterKey := apitypes.NamespacedName{
@@ -240,6 +500,9 @@ func (r *NutanixClusterReconciler) reconcileDelete(rctx *nctx.ClusterContext) (r
 	}
 	nctx.RemoveRemoteClient(clusterKey)
 
+	// Remove the nutanix client from cache
+	nutanixClient.NutanixClientCache.Delete(rctx.NutanixCluster)
+
 	return reconcile.Result{}, nil
 }
 
@@ -247,27 +510,47 @@ func (r *NutanixClusterReconciler) reconcileNormal(rctx *nctx.ClusterContext) (r
 	log := ctrl.LoggerFrom(rctx.Context)
 	if rctx.NutanixCluster.Status.FailureReason != nil || rctx.NutanixCluster.Status.FailureMessage != nil {
 		log.Error(fmt.Errorf("nutanix cluster has failed. Will not reconcile %s", rctx.NutanixCluster.Name), "Nutanix Cluster failed")
-		return reconcile.Result{}, nil
+		return requeueFor(requeueForTerminalError, 0), nil
 	}
 	log.Info("Handling NutanixCluster reconciling")
 
 	// Add finalizer first if not exist to avoid the race condition between init and delete
 	if !ctrlutil.ContainsFinalizer(rctx.NutanixCluster, infrav1.NutanixClusterFinalizer) {
 		ctrlutil.AddFinalizer(rctx.NutanixCluster, infrav1.NutanixClusterFinalizer)
+		r.recordEvent(rctx.NutanixCluster, corev1.EventTypeNormal, finalizerAddedEventReason, "Added the finalizer of the NutanixCluster")
 	}
 
 	// Reconciling failure domains before Ready check to allow failure domains to be modified
-	if err := r.reconcileFailureDomains(rctx); err != nil {
+	result := reconcile.Result{}
+	failureDomainsFailed, err := r.reconcileFailureDomains(rctx)
+	if err != nil {
 		log.Error(err, "failed to reconcile failure domains for cluster")
 		return reconcile.Result{}, err
 	}
+	if failureDomainsFailed {
+		// The resolved failure domains are used meanwhile
+		result.RequeueAfter = failureDomainsRetryInterval
+	}
+
+	if err := r.reconcileFailureDomainMigration(rctx); err != nil {
+		log.Error(err, "failed to migrate failure domains to NutanixFailureDomain objects")
+		return reconcile.Result{}, err
+	}
+
+	if err := r.reconcileControlPlaneSpread(rctx); err != nil {
+		log.Error(err, "failed to verify spread of control plane machines across failure domains")
+		return reconcile.Result{}, err
+	}
+
+	r.collectSeedVMs(rctx)
 
 	if rctx.NutanixCluster.Status.Ready {
 		log.Info("NutanixCluster is already in ready status.")
-		return reconcile.Result{}, nil
+		rctx.NutanixCluster.Status.ObservedGeneration = rctx.NutanixCluster.Generation
+		return result, nil
 	}
 
-	err := r.reconcileCategories(rctx)
+	err = r.reconcileCategories(rctx)
 	if err != nil {
 		log.Error(err, "error occurred while reconciling categories")
 		// Don't return fatal error but keep retrying until categories are created.
@@ -275,25 +558,200 @@ func (r *NutanixClusterReconciler) reconcileNormal(rctx *nctx.ClusterContext) (r
 	}
 
 	rctx.NutanixCluster.Status.Ready = true
-	return reconcile.Result{}, nil
+	rctx.NutanixCluster.Status.ObservedGeneration = rctx.NutanixCluster.Generation
+	r.recordEvent(rctx.NutanixCluster, corev1.EventTypeNormal, clusterReadyEventReason, "The NutanixCluster is ready")
+	return result, nil
+}
+
+// nutanixClusterPhase derives the phase of a NutanixCluster from its deletion timestamp, failure status, conditions
+// and ready status, in that order:
+//   - Deleting while the NutanixCluster is being deleted.
+//   - Failed if a terminal failure is recorded, or a condition is false with the Error severity.
+//   - Ready once the NutanixCluster is ready.
+//   - Provisioning otherwise.
+func nutanixClusterPhase(nutanixCluster *infrav1.NutanixCluster) infrav1.NutanixClusterPhase {
+	if !nutanixCluster.DeletionTimestamp.IsZero() {
+		return infrav1.NutanixClusterPhaseDeleting
+	}
+	if nutanixCluster.Status.FailureReason != nil || nutanixCluster.Status.FailureMessage != nil {
+		return infrav1.NutanixClusterPhaseFailed
+	}
+	for _, condition := range nutanixCluster.Status.Conditions {
+		if condition.Status == corev1.ConditionFalse && condition.Severity == capiv1.ConditionSeverityError {
+			return infrav1.NutanixClusterPhaseFailed
+		}
+	}
+	if nutanixCluster.Status.Ready {
+		return infrav1.NutanixClusterPhaseReady
+	}
+	return infrav1.NutanixClusterPhaseProvisioning
 }
 
-func (r *NutanixClusterReconciler) reconcileFailureDomains(rctx *nctx.ClusterContext) error {
+// reconcileFailureDomains records the failure domains whose Prism Element cluster and subnets are found in Prism
+// Central in the status. Failure domains that could not be resolved are removed from the status, so no Machines are
+// placed in them, and true is returned to retry them later.
+func (r *NutanixClusterReconciler) reconcileFailureDomains(rctx *nctx.ClusterContext) (bool, error) {
 	log := ctrl.LoggerFrom(rctx.Context)
 	if len(rctx.NutanixCluster.Spec.FailureDomains) == 0 {
 		log.V(1).Info("no failure domains defined on cluster")
 		conditions.MarkTrue(rctx.NutanixCluster, infrav1.NoFailureDomainsReconciled)
-		return nil
+		return false, nil
 	}
 	log.V(1).Info("Reconciling failure domains for cluster")
+	for _, fd := range rctx.NutanixCluster.Spec.FailureDomains {
+		if fd.Weight != nil && *fd.Weight < 1 {
+			errorMsg := fmt.Errorf("weight of failure domain %s must be greater than 0 but was %d", fd.Name, *fd.Weight)
+			r.markFalseWithEvent(rctx.NutanixCluster, infrav1.FailureDomainsReconciled, infrav1.FailureDomainsReconciliationFailed, capiv1.ConditionSeverityError, errorMsg.Error())
+			return false, errorMsg
+		}
+	}
 	// If failure domains is nil on status object, first create empty slice
 	if rctx.NutanixCluster.Status.FailureDomains == nil {
 		rctx.NutanixCluster.Status.FailureDomains = make(capiv1.FailureDomains, 0)
 	}
+	_, skipResolution := rctx.NutanixCluster.GetAnnotations()[infrav1.SkipFailureDomainValidationAnnotation]
+	failedFailureDomains := make([]string, 0)
 	for _, fd := range rctx.NutanixCluster.Spec.FailureDomains {
+		if !skipResolution {
+			if err := resolveFailureDomain(rctx.Context, rctx.NutanixClient, fd); err != nil {
+				log.Error(err, fmt.Sprintf("failed to resolve failure domain %s", fd.Name))
+				failedFailureDomains = append(failedFailureDomains, fmt.Sprintf("%s (%v)", fd.Name, err))
+				delete(rctx.NutanixCluster.Status.FailureDomains, fd.Name)
+				continue
+			}
+		}
 		rctx.NutanixCluster.Status.FailureDomains[fd.Name] = capiv1.FailureDomainSpec{ControlPlane: fd.ControlPlane}
 	}
-	conditions.MarkTrue(rctx.NutanixCluster, infrav1.FailureDomainsReconciled)
+	if len(failedFailureDomains) > 0 {
+		r.markFalseWithEvent(rctx.NutanixCluster, infrav1.FailureDomainsReconciled, infrav1.FailureDomainsReconciliationFailed, capiv1.ConditionSeverityWarning,
+			fmt.Sprintf("failed to resolve %d of %d failure domains: %s", len(failedFailureDomains), len(rctx.NutanixCluster.Spec.FailureDomains), strings.Join(failedFailureDomains, "; ")))
+		return true, nil
+	}
+	r.markTrueWithEvent(rctx.NutanixCluster, infrav1.FailureDomainsReconciled, failureDomainsReconciledEventReason,
+		fmt.Sprintf("Reconciled %d failure domains", len(rctx.NutanixCluster.Spec.FailureDomains)))
+	return false, nil
+}
+
+// resolveFailureDomain verifies that the Prism Element cluster and the subnets of the failure domain exist in Prism Central
+func resolveFailureDomain(ctx context.Context, client *nutanixClientV3.Client, fd infrav1.NutanixFailureDomainConfig) error {
+	peUUID, err := GetPEUUID(ctx, client, fd.Cluster.Name, fd.Cluster.UUID)
+	if err != nil {
+		return fmt.Errorf("cluster %s not found: %v", getResourceIdentifierString(fd.Cluster), err)
+	}
+	for _, subnet := range fd.Subnets {
+		if _, err := GetSubnetUUIDByIdentifier(ctx, client, peUUID, subnet); err != nil {
+			return fmt.Errorf("subnet %s not found: %v", getResourceIdentifierString(subnet), err)
+		}
+	}
+	return nil
+}
+
+// reconcileFailureDomainMigration creates or updates a NutanixFailureDomain object for each failure domain defined
+// on the NutanixCluster spec, and deletes the NutanixFailureDomain objects of the NutanixCluster whose failure domains
+// were removed from the spec. The failure domains on the spec remain the source of truth until they are removed.
+func (r *NutanixClusterReconciler) reconcileFailureDomainMigration(rctx *nctx.ClusterContext) error {
+	log := ctrl.LoggerFrom(rctx.Context)
+	names := make(map[string]bool, len(rctx.NutanixCluster.Spec.FailureDomains))
+	for _, fd := range rctx.NutanixCluster.Spec.FailureDomains {
+		names[GetNutanixFailureDomainName(rctx.NutanixCluster.Name, fd.Name)] = true
+	}
+	if err := r.deleteStaleNutanixFailureDomains(rctx, names); err != nil {
+		conditions.MarkFalse(rctx.NutanixCluster, infrav1.FailureDomainsMigratedCondition, infrav1.FailureDomainsMigrationFailed, capiv1.ConditionSeverityWarning, err.Error())
+		return err
+	}
+	if len(rctx.NutanixCluster.Spec.FailureDomains) == 0 {
+		conditions.Delete(rctx.NutanixCluster, infrav1.FailureDomainsMigratedCondition)
+		return nil
+	}
+	for _, fd := range rctx.NutanixCluster.Spec.FailureDomains {
+		nfd := &infrav1.NutanixFailureDomain{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      GetNutanixFailureDomainName(rctx.NutanixCluster.Name, fd.Name),
+				Namespace: rctx.NutanixCluster.Namespace,
+			},
+		}
+		result, err := ctrlutil.CreateOrUpdate(rctx.Context, r.Client, nfd, func() error {
+			if nfd.Labels == nil {
+				nfd.Labels = make(map[string]string)
+			}
+			nfd.Labels[capiv1.ClusterLabelName] = rctx.Cluster.Name
+			nfd.Spec.PrismElementCluster = fd.Cluster
+			nfd.Spec.Subnets = fd.Subnets
+			nfd.Spec.ControlPlane = fd.ControlPlane
+			nfd.Spec.Weight = fd.Weight
+			return ctrlutil.SetControllerReference(rctx.NutanixCluster, nfd, r.Scheme)
+		})
+		if err != nil {
+			errorMsg := fmt.Errorf("failed to create or update NutanixFailureDomain %s: %v", nfd.Name, err)
+			conditions.MarkFalse(rctx.NutanixCluster, infrav1.FailureDomainsMigratedCondition, infrav1.FailureDomainsMigrationFailed, capiv1.ConditionSeverityWarning, errorMsg.Error())
+			return errorMsg
+		}
+		if result != ctrlutil.OperationResultNone {
+			log.Info(fmt.Sprintf("NutanixFailureDomain %s for failure domain %s %s", nfd.Name, fd.Name, result))
+		}
+	}
+	conditions.MarkTrue(rctx.NutanixCluster, infrav1.FailureDomainsMigratedCondition)
+	return nil
+}
+
+// deleteStaleNutanixFailureDomains deletes the NutanixFailureDomain objects controlled by the NutanixCluster whose names
+// are not in names, e.g. since their failure domains were removed from the spec
+func (r *NutanixClusterReconciler) deleteStaleNutanixFailureDomains(rctx *nctx.ClusterContext, names map[string]bool) error {
+	log := ctrl.LoggerFrom(rctx.Context)
+	nfdList := &infrav1.NutanixFailureDomainList{}
+	if err := r.Client.List(rctx.Context, nfdList, client.InNamespace(rctx.NutanixCluster.Namespace), client.MatchingLabels{capiv1.ClusterLabelName: rctx.Cluster.Name}); err != nil {
+		return fmt.Errorf("failed to list NutanixFailureDomains of cluster %s: %v", rctx.Cluster.Name, err)
+	}
+	for i := range nfdList.Items {
+		nfd := &nfdList.Items[i]
+		if names[nfd.Name] || !metav1.IsControlledBy(nfd, rctx.NutanixCluster) {
+			continue
+		}
+		if err := r.Client.Delete(rctx.Context, nfd); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete NutanixFailureDomain %s: %v", nfd.Name, err)
+		}
+		log.Info(fmt.Sprintf("NutanixFailureDomain %s deleted since its failure domain was removed", nfd.Name))
+	}
+	return nil
+}
+
+// reconcileControlPlaneSpread verifies that no two control plane machines are placed in the same failure domain
+func (r *NutanixClusterReconciler) reconcileControlPlaneSpread(rctx *nctx.ClusterContext) error {
+	log := ctrl.LoggerFrom(rctx.Context)
+	if len(rctx.NutanixCluster.Spec.FailureDomains) == 0 {
+		conditions.Delete(rctx.NutanixCluster, infrav1.ControlPlaneSpreadCondition)
+		return nil
+	}
+	nutanixMachines, err := rctx.GetNutanixMachinesInCluster(r.Client)
+	if err != nil {
+		return fmt.Errorf("failed to list machines of cluster %s: %v", rctx.NutanixCluster.Name, err)
+	}
+	machinesPerFailureDomain := make(map[string][]string)
+	for _, m := range nutanixMachines {
+		if !nctx.IsControlPlaneMachine(m) || m.Status.FailureDomain == nil {
+			continue
+		}
+		machinesPerFailureDomain[*m.Status.FailureDomain] = append(machinesPerFailureDomain[*m.Status.FailureDomain], m.Name)
+	}
+	failureDomainNames := make([]string, 0, len(machinesPerFailureDomain))
+	for fd := range machinesPerFailureDomain {
+		failureDomainNames = append(failureDomainNames, fd)
+	}
+	sort.Strings(failureDomainNames)
+	sharedFailureDomains := make([]string, 0)
+	for _, fd := range failureDomainNames {
+		if machines := machinesPerFailureDomain[fd]; len(machines) > 1 {
+			sort.Strings(machines)
+			sharedFailureDomains = append(sharedFailureDomains, fmt.Sprintf("%s (%s)", fd, strings.Join(machines, ", ")))
+		}
+	}
+	if len(sharedFailureDomains) > 0 {
+		msg := fmt.Sprintf("control plane machines share failure domains: %s", strings.Join(sharedFailureDomains, "; "))
+		log.Info(msg)
+		conditions.MarkFalse(rctx.NutanixCluster, infrav1.ControlPlaneSpreadCondition, infrav1.ControlPlaneNotSpread, capiv1.ConditionSeverityWarning, msg)
+		return nil
+	}
+	conditions.MarkTrue(rctx.NutanixCluster, infrav1.ControlPlaneSpreadCondition)
 	return nil
 }
 
@@ -303,13 +761,42 @@ func (r *NutanixClusterReconciler) reconcileCategories(rctx *nctx.ClusterContext
 	defaultCategories := GetDefaultCAPICategoryIdentifiers(rctx.Cluster.Name)
 	_, err := GetOrCreateCategories(rctx.Context, rctx.NutanixClient, defaultCategories)
 	if err != nil {
-		conditions.MarkFalse(rctx.NutanixCluster, infrav1.ClusterCategoryCreatedCondition, infrav1.ClusterCategoryCreationFailed, capiv1.ConditionSeverityError, err.Error())
+		r.markFalseWithEvent(rctx.NutanixCluster, infrav1.ClusterCategoryCreatedCondition, infrav1.ClusterCategoryCreationFailed, capiv1.ConditionSeverityError, err.Error())
+		return err
+	}
+	r.markTrueWithEvent(rctx.NutanixCluster, infrav1.ClusterCategoryCreatedCondition, categoriesCreatedEventReason, "Created the categories of the cluster")
+	return nil
+}
+
+// reconcileSeedVMsDelete deletes the seed VMs of the seed disk pool owned by the cluster
+func (r *NutanixClusterReconciler) reconcileSeedVMsDelete(rctx *nctx.ClusterContext) error {
+	log := ctrl.LoggerFrom(rctx.Context)
+	deleted, err := deleteSeedVMs(rctx.Context, rctx.NutanixClient, rctx.Cluster.Name)
+	if err != nil {
 		return err
 	}
-	conditions.MarkTrue(rctx.NutanixCluster, infrav1.ClusterCategoryCreatedCondition)
+	if deleted > 0 {
+		log.Info(fmt.Sprintf("Deleted %d seed VMs of cluster %s", deleted, rctx.Cluster.Name))
+	}
 	return nil
 }
 
+// collectSeedVMs deletes the seed VMs of the cluster left by an earlier run with the seed disk pool enabled once per
+// NutanixCluster. Failures are retried on the next reconcile without failing it.
+func (r *NutanixClusterReconciler) collectSeedVMs(rctx *nctx.ClusterContext) {
+	if !r.controllerConfig.collectSeedVMs() {
+		return
+	}
+	if _, collected := r.seedVMsCollected.Load(rctx.NutanixCluster.UID); collected {
+		return
+	}
+	if err := r.reconcileSeedVMsDelete(rctx); err != nil {
+		ctrl.LoggerFrom(rctx.Context).Error(err, "failed to delete seed VMs of the disabled seed disk pool")
+		return
+	}
+	r.seedVMsCollected.Store(rctx.NutanixCluster.UID, true)
+}
+
 func (r *NutanixClusterReconciler) reconcileCategoriesDelete(rctx *nctx.ClusterContext) error {
 	log := ctrl.LoggerFrom(rctx.Context)
 	log.Info(fmt.Sprintf("Reconciling deletion of categories for cluster %s", rctx.Cluster.Name))
@@ -319,9 +806,10 @@ func (r *NutanixClusterReconciler) reconcileCategoriesDelete(rctx *nctx.ClusterC
 		obsoleteCategories := GetObsoleteDefaultCAPICategoryIdentifiers(rctx.Cluster.Name)
 		err := DeleteCategories(rctx.Context, rctx.NutanixClient, defaultCategories, obsoleteCategories)
 		if err != nil {
-			conditions.MarkFalse(rctx.NutanixCluster, infrav1.ClusterCategoryCreatedCondition, infrav1.DeletionFailed, capiv1.ConditionSeverityWarning, err.Error())
+			r.markFalseWithEvent(rctx.NutanixCluster, infrav1.ClusterCategoryCreatedCondition, infrav1.DeletionFailed, capiv1.ConditionSeverityWarning, err.Error())
 			return err
 		}
+		r.recordEvent(rctx.NutanixCluster, corev1.EventTypeNormal, categoriesDeletedEventReason, "Deleted the categories of the cluster")
 	} else {
 		log.V(1).Info(fmt.Sprintf("skipping category deletion since they were not created for cluster %s", rctx.Cluster.Name))
 	}
@@ -338,6 +826,10 @@ func (r *NutanixClusterReconciler) reconcileCredentialRefDelete(ctx context.Cont
 	if credentialRef == nil {
 		return nil
 	}
+	if credentialRefNamespace(nutanixCluster, credentialRef) != nutanixCluster.Namespace {
+		log.V(1).Info(fmt.Sprintf("Credential secret %s of cluster %s is shared from another namespace. Skipping its deletion", credentialRef.Name, nutanixCluster.Name))
+		return nil
+	}
 	log.V(1).Info(fmt.Sprintf("Credential ref is kind Secret for cluster %s. Continue with deletion of secret", nutanixCluster.Name))
 	secret := &corev1.Secret{}
 	secretKey := client.ObjectKey{
@@ -352,10 +844,17 @@ func (r *NutanixClusterReconciler) reconcileCredentialRefDelete(ctx context.Cont
 		}
 		return err
 	}
-	ctrlutil.RemoveFinalizer(secret, infrav1.NutanixClusterCredentialFinalizer)
-	log.V(1).Info(fmt.Sprintf("removing finalizers from secret %s in namespace %s for cluster %s", secret.Name, secret.Namespace, nutanixCluster.Name))
-	if err := r.Client.Update(ctx, secret); err != nil {
-		return err
+	// Without managed finalizers, the secret is only updated to remove a finalizer added before they were disabled.
+	// Other finalizers are left to whoever added them and are not waited for.
+	finalizerRemoved := ctrlutil.RemoveFinalizer(secret, infrav1.NutanixClusterCredentialFinalizer)
+	if finalizerRemoved || r.controllerConfig.manageCredentialFinalizers() {
+		log.V(1).Info(fmt.Sprintf("removing finalizers from secret %s in namespace %s for cluster %s", secret.Name, secret.Namespace, nutanixCluster.Name))
+		if err := r.Client.Update(ctx, secret); err != nil {
+			return err
+		}
+	}
+	if finalizerRemoved {
+		r.recordEvent(nutanixCluster, corev1.EventTypeNormal, credentialSecretFinalizerRemovedEventReason, fmt.Sprintf("Removed the finalizer of credential secret %s", secret.Name))
 	}
 
 	if secret.DeletionTimestamp.IsZero() {
@@ -363,6 +862,7 @@ func (r *NutanixClusterReconciler) reconcileCredentialRefDelete(ctx context.Cont
 		if err := r.Client.Delete(ctx, secret); err != nil {
 			return err
 		}
+		r.recordEvent(nutanixCluster, corev1.EventTypeNormal, credentialSecretDeletedEventReason, fmt.Sprintf("Deleted credential secret %s", secret.Name))
 	}
 
 	return nil
@@ -378,9 +878,14 @@ func (r *NutanixClusterReconciler) reconcileCredentialRef(ctx context.Context, n
 		return nil
 	}
 	log.V(1).Info(fmt.Sprintf("credential ref is kind Secret for cluster %s", nutanixCluster.Name))
+	secretNamespace := credentialRefNamespace(nutanixCluster, credentialRef)
+	if !r.controllerConfig.credentialNamespaceAllowed(nutanixCluster.Namespace, secretNamespace) {
+		return fmt.Errorf("%w: secret %s in namespace %s cannot be referenced by cluster %s in namespace %s", errCredentialNamespaceNotAllowed,
+			credentialRef.Name, secretNamespace, nutanixCluster.Name, nutanixCluster.Namespace)
+	}
 	secret := &corev1.Secret{}
 	secretKey := client.ObjectKey{
-		Namespace: nutanixCluster.Namespace,
+		Namespace: secretNamespace,
 		Name:      credentialRef.Name,
 	}
 	err = r.Client.Get(ctx, secretKey, secret)
@@ -389,24 +894,36 @@ func (r *NutanixClusterReconciler) reconcileCredentialRef(ctx context.Context, n
 		log.Error(errorMsg, "error occurred fetching cluster")
 		return errorMsg
 	}
-	// Check if ownerRef is already set on nutanixCluster object
-	if !capiutil.IsOwnedByObject(secret, nutanixCluster) {
-		// Check if another nutanixCluster already has set ownerRef. Secret can only be owned by one nutanixCluster object
-		if capiutil.HasOwner(secret.OwnerReferences, infrav1.GroupVersion.String(), []string{
-			nutanixCluster.Kind,
-		}) {
-			return fmt.Errorf("secret %s already owned by another nutanixCluster object", secret.Name)
-		}
-		// Set nutanixCluster ownerRef on the secret
-		secret.OwnerReferences = capiutil.EnsureOwnerRef(secret.OwnerReferences, metav1.OwnerReference{
-			APIVersion: infrav1.GroupVersion.String(),
-			Kind:       nutanixCluster.Kind,
-			UID:        nutanixCluster.UID,
-			Name:       nutanixCluster.Name,
-		})
+	finalizerAdded := false
+	// Secrets of other namespaces are shared and cannot be owned by the nutanixCluster object
+	if secretNamespace == nutanixCluster.Namespace {
+		// Check if ownerRef is already set on nutanixCluster object
+		if !capiutil.IsOwnedByObject(secret, nutanixCluster) {
+			// Check if another nutanixCluster already has set ownerRef. Secret can only be owned by one nutanixCluster object
+			if capiutil.HasOwner(secret.OwnerReferences, infrav1.GroupVersion.String(), []string{
+				nutanixCluster.Kind,
+			}) {
+				return fmt.Errorf("secret %s already owned by another nutanixCluster object", secret.Name)
+			}
+			// Set nutanixCluster ownerRef on the secret
+			secret.OwnerReferences = capiutil.EnsureOwnerRef(secret.OwnerReferences, metav1.OwnerReference{
+				APIVersion: infrav1.GroupVersion.String(),
+				Kind:       nutanixCluster.Kind,
+				UID:        nutanixCluster.UID,
+				Name:       nutanixCluster.Name,
+			})
+		}
+		if r.controllerConfig.manageCredentialFinalizers() {
+			finalizerAdded = ctrlutil.AddFinalizer(secret, infrav1.NutanixClusterCredentialFinalizer)
+		}
 	}
-	if !ctrlutil.ContainsFinalizer(secret, infrav1.NutanixClusterCredentialFinalizer) {
-		ctrlutil.AddFinalizer(secret, infrav1.NutanixClusterCredentialFinalizer)
+	// Invalidate the cached clients if the credentials were rotated
+	credentialHash := nutanixClient.GetCredentialSecretHash(secret)
+	previousCredentialHash := secret.GetAnnotations()[infrav1.NutanixClusterCredentialHashAnnotation]
+	if previousCredentialHash != credentialHash {
+		removed := nutanixClient.NutanixClientCache.InvalidateCredentialRef(secret.Namespace, secret.Name)
+		log.Info(fmt.Sprintf("content of secret %s for cluster %s changed. Invalidated %d cached nutanix clients", secret.Name, nutanixCluster.Name, removed))
+		annotations.AddAnnotations(secret, map[string]string{infrav1.NutanixClusterCredentialHashAnnotation: credentialHash})
 	}
 	err = r.Client.Update(ctx, secret)
 	if err != nil {
@@ -414,5 +931,275 @@ func (r *NutanixClusterReconciler) reconcileCredentialRef(ctx context.Context, n
 		log.Error(errorMsg, "failed to update secret")
 		return errorMsg
 	}
+	if finalizerAdded {
+		r.recordEvent(nutanixCluster, corev1.EventTypeNormal, credentialSecretFinalizerAddedEventReason, fmt.Sprintf("Added the finalizer to credential secret %s", secret.Name))
+	}
+	// The hash is recorded for the first time when the secret is adopted
+	if previousCredentialHash != "" && previousCredentialHash != credentialHash {
+		r.recordEvent(nutanixCluster, corev1.EventTypeNormal, credentialSecretChangedEventReason, fmt.Sprintf("Content of credential secret %s changed. Cached Prism Central clients were invalidated", secret.Name))
+	}
+	// Invalid credentials are reported before the Prism Central client fails to authenticate with them
+	data, ok := secret.Data[credentialTypes.KeyName]
+	if !ok {
+		return fmt.Errorf("%w: secret %s of cluster %s has no %q key", nutanixClient.ErrInvalidCredentials, secret.Name, nutanixCluster.Name, credentialTypes.KeyName)
+	}
+	if err := nutanixClient.ValidateCredentials(data); err != nil {
+		return fmt.Errorf("secret %s of cluster %s holds %w", secret.Name, nutanixCluster.Name, err)
+	}
+	return nil
+}
+
+// credentialRefNamespace returns the namespace of the credential secret of a NutanixCluster, which defaults to the
+// namespace of the NutanixCluster
+func credentialRefNamespace(nutanixCluster *infrav1.NutanixCluster, credentialRef *credentialTypes.NutanixCredentialReference) string {
+	if credentialRef.Namespace != "" {
+		return credentialRef.Namespace
+	}
+	return nutanixCluster.Namespace
+}
+
+func (r *NutanixClusterReconciler) reconcileTrustBundleRef(ctx context.Context, nutanixCluster *infrav1.NutanixCluster) error {
+	log := ctrl.LoggerFrom(ctx)
+	if nutanixCluster.Spec.PrismCentral == nil || nutanixCluster.Spec.PrismCentral.AdditionalTrustBundle == nil {
+		conditions.Delete(nutanixCluster, infrav1.TrustBundleValidCondition)
+		conditions.Delete(nutanixCluster, infrav1.TrustBundleSecureCondition)
+		return nil
+	}
+	if err := r.reconcileTrustBundleConfigMap(ctx, nutanixCluster); err != nil {
+		return err
+	}
+	trustBundle, err := r.getTrustBundle(ctx, nutanixCluster)
+	if err != nil {
+		return err
+	}
+	certs, err := nutanixClient.ParseTrustBundle(trustBundle)
+	if err != nil {
+		errorMsg := fmt.Errorf("invalid trust bundle for cluster %s: %v", nutanixCluster.Name, err)
+		r.markFalseWithEvent(nutanixCluster, infrav1.TrustBundleValidCondition, infrav1.TrustBundleInvalid, capiv1.ConditionSeverityError, errorMsg.Error())
+		return errorMsg
+	}
+	r.reconcileTrustBundleExpiry(nutanixCluster, certs, time.Now())
+	if r.controllerConfig == nil || r.controllerConfig.TrustBundlePolicy.IsEmpty() {
+		log.V(1).Info(fmt.Sprintf("no trust bundle policy configured. Skipping validation of trust bundle for cluster %s", nutanixCluster.Name))
+		conditions.Delete(nutanixCluster, infrav1.TrustBundleSecureCondition)
+		return nil
+	}
+	for _, cert := range certs {
+		if err := nutanixClient.ValidateCertificateStrength(cert, r.controllerConfig.TrustBundlePolicy); err != nil {
+			errorMsg := fmt.Errorf("trust bundle for cluster %s does not meet security requirements: %v", nutanixCluster.Name, err)
+			conditions.MarkFalse(nutanixCluster, infrav1.TrustBundleSecureCondition, infrav1.TrustBundleWeakCertificate, capiv1.ConditionSeverityError, errorMsg.Error())
+			return errorMsg
+		}
+	}
+	conditions.MarkTrue(nutanixCluster, infrav1.TrustBundleSecureCondition)
 	return nil
 }
+
+// reconcileTrustBundleExpiry sets the TrustBundleValid condition of the NutanixCluster according to the expiry of the
+// certificates of its additional trust bundle. Expired and expiring certificates are only warned about, since the
+// other certificates of the trust bundle may still be valid.
+func (r *NutanixClusterReconciler) reconcileTrustBundleExpiry(nutanixCluster *infrav1.NutanixCluster, certs []*x509.Certificate, now time.Time) {
+	var expired, expiring []string
+	for _, cert := range certs {
+		certName := fmt.Sprintf("%s (expires %s)", cert.Subject.CommonName, cert.NotAfter.UTC().Format(time.RFC3339))
+		switch {
+		case now.After(cert.NotAfter):
+			expired = append(expired, certName)
+		case now.Add(trustBundleExpiryWarningPeriod).After(cert.NotAfter):
+			expiring = append(expiring, certName)
+		}
+	}
+	switch {
+	case len(expired) > 0:
+		r.markFalseWithEvent(nutanixCluster, infrav1.TrustBundleValidCondition, infrav1.TrustBundleCertificateExpired, capiv1.ConditionSeverityWarning,
+			fmt.Sprintf("trust bundle contains expired certificates: %s", strings.Join(expired, ", ")))
+	case len(expiring) > 0:
+		r.markFalseWithEvent(nutanixCluster, infrav1.TrustBundleValidCondition, infrav1.TrustBundleCertificateExpiring, capiv1.ConditionSeverityWarning,
+			fmt.Sprintf("trust bundle contains certificates expiring within %d days: %s", int(trustBundleExpiryWarningPeriod.Hours()/24), strings.Join(expiring, ", ")))
+	default:
+		conditions.MarkTrue(nutanixCluster, infrav1.TrustBundleValidCondition)
+	}
+}
+
+// reconcileTrustBundleConfigMap records the hash of the content of the trust bundle configmap of the NutanixCluster on
+// the configmap, and invalidates the cached clients using the configmap if its content changed
+func (r *NutanixClusterReconciler) reconcileTrustBundleConfigMap(ctx context.Context, nutanixCluster *infrav1.NutanixCluster) error {
+	log := ctrl.LoggerFrom(ctx)
+	trustBundleRef := nutanixCluster.Spec.PrismCentral.AdditionalTrustBundle
+	if trustBundleRef.Kind != credentialTypes.NutanixTrustBundleKindConfigMap {
+		return nil
+	}
+	namespace := trustBundleRef.Namespace
+	if namespace == "" {
+		namespace = nutanixCluster.Namespace
+	}
+	cm := &corev1.ConfigMap{}
+	cmKey := client.ObjectKey{
+		Namespace: namespace,
+		Name:      trustBundleRef.Name,
+	}
+	if err := r.Client.Get(ctx, cmKey, cm); err != nil {
+		return fmt.Errorf("error occurred while fetching trust bundle configmap %s for cluster %s: %v", trustBundleRef.Name, nutanixCluster.Name, err)
+	}
+	trustBundleHash := nutanixClient.GetTrustBundleConfigMapHash(cm)
+	previousTrustBundleHash := cm.GetAnnotations()[infrav1.NutanixClusterTrustBundleHashAnnotation]
+	if previousTrustBundleHash == trustBundleHash {
+		return nil
+	}
+	removed := nutanixClient.NutanixClientCache.InvalidateTrustBundleRef(cm.Namespace, cm.Name)
+	log.Info(fmt.Sprintf("content of trust bundle configmap %s for cluster %s changed. Invalidated %d cached nutanix clients", cm.Name, nutanixCluster.Name, removed))
+	annotations.AddAnnotations(cm, map[string]string{infrav1.NutanixClusterTrustBundleHashAnnotation: trustBundleHash})
+	if err := r.Client.Update(ctx, cm); err != nil {
+		errorMsg := fmt.Errorf("failed to update trust bundle configmap for cluster %s: %v", nutanixCluster.Name, err)
+		log.Error(errorMsg, "failed to update trust bundle configmap")
+		return errorMsg
+	}
+	// The hash is recorded for the first time when the configmap is first reconciled
+	if previousTrustBundleHash != "" {
+		r.recordEvent(nutanixCluster, corev1.EventTypeNormal, trustBundleChangedEventReason, fmt.Sprintf("Content of trust bundle configmap %s changed. Cached Prism Central clients were invalidated", cm.Name))
+	}
+	return nil
+}
+
+// getPrismCentralEndpoint returns the key of the Prism Central endpoint of the NutanixCluster used by the circuit breaker
+func getPrismCentralEndpoint(nutanixCluster *infrav1.NutanixCluster) string {
+	prismCentral := nutanixCluster.Spec.PrismCentral
+	if prismCentral == nil {
+		// The Prism Central endpoint of the manager is used
+		return ""
+	}
+	// Endpoints that only differ in the default port or the scheme share the circuit
+	if normalized, err := nutanixClient.NormalizePrismEndpoint(*prismCentral); err == nil {
+		prismCentral = &normalized
+	}
+	return nutanixClient.JoinHostPort(prismCentral.Address, strconv.Itoa(int(prismCentral.Port)))
+}
+
+// reconcilePrismCentralCircuit returns true and the result to requeue the NutanixCluster with if requests to its
+// Prism Central endpoint are held back after consecutive connection failures
+func (r *NutanixClusterReconciler) reconcilePrismCentralCircuit(ctx context.Context, nutanixCluster *infrav1.NutanixCluster) (reconcile.Result, bool) {
+	log := ctrl.LoggerFrom(ctx)
+	if r.circuitBreaker == nil {
+		return reconcile.Result{}, false
+	}
+	endpoint := getPrismCentralEndpoint(nutanixCluster)
+	allowed, delay := r.circuitBreaker.Allow(endpoint)
+	if allowed {
+		if r.circuitBreaker.State(endpoint) == nutanixClient.CircuitHalfOpen {
+			log.Info(fmt.Sprintf("probing whether Prism Central %s of cluster %s is reachable again", endpoint, nutanixCluster.Name))
+		}
+		return reconcile.Result{}, false
+	}
+	log.Info(fmt.Sprintf("Prism Central %s of cluster %s is unreachable. Requeueing after %s", endpoint, nutanixCluster.Name, delay))
+	return reconcile.Result{RequeueAfter: delay}, true
+}
+
+// recordPrismCentralResult records the result of the reconciliation in the circuit breaker of the Prism Central endpoint
+// of the NutanixCluster. Connection failures are requeued with the delay of the circuit breaker once it opened.
+func (r *NutanixClusterReconciler) recordPrismCentralResult(ctx context.Context, nutanixCluster *infrav1.NutanixCluster, result reconcile.Result, err error) (reconcile.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+	if r.circuitBreaker == nil {
+		return result, err
+	}
+	endpoint := getPrismCentralEndpoint(nutanixCluster)
+	if !nutanixClient.IsConnectionError(err) {
+		r.circuitBreaker.RecordSuccess(endpoint)
+		conditions.Delete(nutanixCluster, infrav1.PrismCentralUnreachableCondition)
+		return result, err
+	}
+	delay := r.circuitBreaker.RecordFailure(endpoint)
+	if delay == 0 {
+		return result, err
+	}
+	msg := fmt.Sprintf("Prism Central %s is unreachable: %v. Retrying in %s", endpoint, err, delay)
+	log.Error(err, fmt.Sprintf("holding back reconciliation of cluster %s for %s", nutanixCluster.Name, delay))
+	conditions.Set(nutanixCluster, &capiv1.Condition{
+		Type:    infrav1.PrismCentralUnreachableCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  infrav1.PrismCentralCircuitOpen,
+		Message: msg,
+	})
+	// Not returning the error to prevent the tight requeue of failed reconciliations
+	return reconcile.Result{RequeueAfter: delay}, nil
+}
+
+// recordEvent records an event for the NutanixCluster if an event recorder is configured
+func (r *NutanixClusterReconciler) recordEvent(nutanixCluster *infrav1.NutanixCluster, eventType, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(nutanixCluster, eventType, reason, message)
+	}
+}
+
+// markTrueWithEvent marks the condition of the NutanixCluster true and records a Normal event with the given reason if
+// the condition was not true before, so the event is only recorded once per transition
+func (r *NutanixClusterReconciler) markTrueWithEvent(nutanixCluster *infrav1.NutanixCluster, conditionType capiv1.ConditionType, reason, message string) {
+	transitioned := !conditions.IsTrue(nutanixCluster, conditionType)
+	conditions.MarkTrue(nutanixCluster, conditionType)
+	if transitioned {
+		r.recordEvent(nutanixCluster, corev1.EventTypeNormal, reason, message)
+	}
+}
+
+// markFalseWithEvent marks the condition of the NutanixCluster false and records a Warning event with the reason of the
+// condition if the condition was not false with the same reason and message before, so repeated failures are only
+// recorded once
+func (r *NutanixClusterReconciler) markFalseWithEvent(nutanixCluster *infrav1.NutanixCluster, conditionType capiv1.ConditionType, reason string, severity capiv1.ConditionSeverity, message string) {
+	transitioned := !conditions.IsFalse(nutanixCluster, conditionType) ||
+		conditions.GetReason(nutanixCluster, conditionType) != reason ||
+		conditions.GetMessage(nutanixCluster, conditionType) != message
+	conditions.MarkFalse(nutanixCluster, conditionType, reason, severity, "%s", message)
+	if transitioned {
+		r.recordEvent(nutanixCluster, corev1.EventTypeWarning, reason, message)
+	}
+}
+
+// reconcileInsecureTLS emits a warning and sets the InsecureTLS condition if the TLS certificate of Prism Central is not verified
+func (r *NutanixClusterReconciler) reconcileInsecureTLS(ctx context.Context, nutanixCluster *infrav1.NutanixCluster) {
+	log := ctrl.LoggerFrom(ctx)
+	if !nutanixClient.IsInsecureSkipVerify(nutanixCluster) {
+		conditions.Delete(nutanixCluster, infrav1.InsecureTLSCondition)
+		return
+	}
+	msg := fmt.Sprintf("TLS certificate verification of Prism Central is disabled for cluster %s. This must not be used in production", nutanixCluster.Name)
+	log.Info(fmt.Sprintf("[WARNING] %s", msg))
+	if r.Recorder != nil {
+		r.Recorder.Event(nutanixCluster, corev1.EventTypeWarning, infrav1.InsecureSkipVerifyEnabled, msg)
+	}
+	conditions.Set(nutanixCluster, &capiv1.Condition{
+		Type:    infrav1.InsecureTLSCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  infrav1.InsecureSkipVerifyEnabled,
+		Message: msg,
+	})
+}
+
+// getTrustBundle returns the PEM encoded additional trust bundle referenced by the NutanixCluster
+func (r *NutanixClusterReconciler) getTrustBundle(ctx context.Context, nutanixCluster *infrav1.NutanixCluster) (string, error) {
+	trustBundleRef := nutanixCluster.Spec.PrismCentral.AdditionalTrustBundle
+	switch trustBundleRef.Kind {
+	case credentialTypes.NutanixTrustBundleKindString:
+		return trustBundleRef.Data, nil
+	case credentialTypes.NutanixTrustBundleKindConfigMap:
+		namespace := trustBundleRef.Namespace
+		if namespace == "" {
+			namespace = nutanixCluster.Namespace
+		}
+		cm := &corev1.ConfigMap{}
+		cmKey := client.ObjectKey{
+			Namespace: namespace,
+			Name:      trustBundleRef.Name,
+		}
+		if err := r.Client.Get(ctx, cmKey, cm); err != nil {
+			return "", fmt.Errorf("error occurred while fetching trust bundle configmap %s for cluster %s: %v", trustBundleRef.Name, nutanixCluster.Name, err)
+		}
+		if trustBundle, ok := cm.Data[nutanixClient.TrustBundleConfigMapKey]; ok {
+			return trustBundle, nil
+		}
+		if trustBundle, ok := cm.BinaryData[nutanixClient.TrustBundleConfigMapKey]; ok {
+			return string(trustBundle), nil
+		}
+		return "", fmt.Errorf("key %s not found in trust bundle configmap %s for cluster %s", nutanixClient.TrustBundleConfigMapKey, trustBundleRef.Name, nutanixCluster.Name)
+	default:
+		return "", fmt.Errorf("unsupported trust bundle kind %s for cluster %s", trustBundleRef.Kind, nutanixCluster.Name)
+	}
+}
@@ -18,21 +18,41 @@ package controllers
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 
 	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
 	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
 	capiutil "sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
 	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -54,7 +74,7 @@ func TestNutanixClusterReconciler(t *testing.T) {
 		var (
 			ntnxCluster *infrav1.NutanixCluster
 			ctx         context.Context
-			fd1         infrav1.NutanixFailureDomain
+			fd1         infrav1.NutanixFailureDomainConfig
 			reconciler  *NutanixClusterReconciler
 			ntnxSecret  *corev1.Secret
 			r           string
@@ -69,7 +89,7 @@ func TestNutanixClusterReconciler(t *testing.T) {
 					Namespace: corev1.NamespaceDefault,
 				},
 				StringData: map[string]string{
-					r: r,
+					"credentials": testCredentials(r),
 				},
 			}
 			ntnxCluster = &infrav1.NutanixCluster{
@@ -89,16 +109,17 @@ func TestNutanixClusterReconciler(t *testing.T) {
 					},
 				},
 			}
-			fd1 = infrav1.NutanixFailureDomain{
+			// The cluster and subnet are found by fakeLookupService
+			fd1 = infrav1.NutanixFailureDomainConfig{
 				Name: fd1Name,
 				Cluster: infrav1.NutanixResourceIdentifier{
 					Type: infrav1.NutanixIdentifierName,
-					Name: &r,
+					Name: utils.StringPtr("pe"),
 				},
 				Subnets: []infrav1.NutanixResourceIdentifier{
 					{
 						Type: infrav1.NutanixIdentifierName,
-						Name: &r,
+						Name: utils.StringPtr("subnet"),
 					},
 				},
 			}
@@ -147,6 +168,7 @@ func TestNutanixClusterReconciler(t *testing.T) {
 				ntnxCluster.Status.Ready = true
 				result, err := reconciler.reconcileNormal(&nctx.ClusterContext{
 					Context:        ctx,
+					Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: corev1.NamespaceDefault}},
 					NutanixCluster: ntnxCluster,
 				})
 				g.Expect(err).NotTo(HaveOccurred())
@@ -154,14 +176,16 @@ func TestNutanixClusterReconciler(t *testing.T) {
 				g.Expect(result.Requeue).To(BeFalse())
 			})
 			It("should not error and not requeue if failure domains are configured and cluster is Ready", func() {
-				ntnxCluster.Spec.FailureDomains = []infrav1.NutanixFailureDomain{
+				ntnxCluster.Spec.FailureDomains = []infrav1.NutanixFailureDomainConfig{
 					fd1,
 				}
 				g.Expect(k8sClient.Create(ctx, ntnxCluster)).To(Succeed())
 				ntnxCluster.Status.Ready = true
 				result, err := reconciler.reconcileNormal(&nctx.ClusterContext{
 					Context:        ctx,
+					Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: corev1.NamespaceDefault}},
 					NutanixCluster: ntnxCluster,
+					NutanixClient:  &nutanixClientV3.Client{V3: &fakeLookupService{}},
 				})
 				g.Expect(err).NotTo(HaveOccurred())
 				g.Expect(result.RequeueAfter).To(BeZero())
@@ -171,7 +195,7 @@ func TestNutanixClusterReconciler(t *testing.T) {
 
 		Context("Reconcile failure domains", func() {
 			It("sets the failure domains in the nutanixcluster status and failure domain reconciled condition", func() {
-				ntnxCluster.Spec.FailureDomains = []infrav1.NutanixFailureDomain{
+				ntnxCluster.Spec.FailureDomains = []infrav1.NutanixFailureDomainConfig{
 					fd1,
 				}
 
@@ -184,11 +208,13 @@ func TestNutanixClusterReconciler(t *testing.T) {
 					Name:      ntnxCluster.Name,
 				}, appliedNtnxCluster)
 
-				err := reconciler.reconcileFailureDomains(&nctx.ClusterContext{
+				requeue, err := reconciler.reconcileFailureDomains(&nctx.ClusterContext{
 					Context:        ctx,
 					NutanixCluster: appliedNtnxCluster,
+					NutanixClient:  &nutanixClientV3.Client{V3: &fakeLookupService{}},
 				})
 				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(requeue).To(BeFalse())
 
 				g.Expect(appliedNtnxCluster.Status.Conditions).To(ContainElement(
 					gstruct.MatchFields(
@@ -218,11 +244,12 @@ func TestNutanixClusterReconciler(t *testing.T) {
 					Name:      ntnxCluster.Name,
 				}, appliedNtnxCluster)
 
-				err := reconciler.reconcileFailureDomains(&nctx.ClusterContext{
+				requeue, err := reconciler.reconcileFailureDomains(&nctx.ClusterContext{
 					Context:        ctx,
 					NutanixCluster: appliedNtnxCluster,
 				})
 				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(requeue).To(BeFalse())
 
 				g.Expect(appliedNtnxCluster.Status.Conditions).To(ContainElement(
 					gstruct.MatchFields(
@@ -394,3 +421,1433 @@ func TestNutanixClusterReconciler(t *testing.T) {
 		})
 	})
 }
+
+// testCredentials returns basic auth credentials data with the given password
+func testCredentials(password string) string {
+	return fmt.Sprintf(`[{"type":"basic_auth","data":{"prismCentral":{"username":"user","password":%q}}}]`, password)
+}
+
+func TestReconcileCredentialRefInvalidSecret(t *testing.T) {
+	tests := []struct {
+		name            string
+		data            map[string][]byte
+		expectedReason  string
+		expectedMessage string
+	}{
+		{
+			name:            "malformed JSON",
+			data:            map[string][]byte{"credentials": []byte(`[{"type":"basic_auth"`)},
+			expectedReason:  infrav1.CredentialSecretInvalid,
+			expectedMessage: "credentials: malformed JSON at offset 21",
+		},
+		{
+			name:            "missing password",
+			data:            map[string][]byte{"credentials": []byte(`[{"type":"basic_auth","data":{"prismCentral":{"username":"user"}}}]`)},
+			expectedReason:  infrav1.CredentialSecretInvalid,
+			expectedMessage: "credentials[0].data.prismCentral.password: must be set",
+		},
+		{
+			name:            "unsupported type",
+			data:            map[string][]byte{"credentials": []byte(`[{"type":"kerberos","data":{}}]`)},
+			expectedReason:  infrav1.CredentialSecretInvalid,
+			expectedMessage: `credentials[0].type: unsupported credentials type "kerberos"`,
+		},
+		{
+			name:            "missing key",
+			data:            map[string][]byte{"creds": []byte(testCredentials("password"))},
+			expectedReason:  infrav1.CredentialSecretInvalid,
+			expectedMessage: `has no "credentials" key`,
+		},
+		{
+			name:            "secret not found",
+			expectedReason:  infrav1.CredentialRefSecretOwnerSetFailed,
+			expectedMessage: "not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+			scheme := runtime.NewScheme()
+			g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(capiv1.AddToScheme(scheme)).To(Succeed())
+
+			ntnxCluster := &infrav1.NutanixCluster{
+				TypeMeta: metav1.TypeMeta{Kind: infrav1.NutanixClusterKind, APIVersion: infrav1.GroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "invalid-secret-test",
+					Namespace:       "default",
+					UID:             utilruntime.NewUUID(),
+					OwnerReferences: []metav1.OwnerReference{{APIVersion: capiv1.GroupVersion.String(), Kind: "Cluster", Name: "invalid-secret-test", UID: "cluster-uid"}},
+				},
+				Spec: infrav1.NutanixClusterSpec{
+					PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+						Address: "prism.example.com",
+						Port:    9440,
+						CredentialRef: &credentialTypes.NutanixCredentialReference{
+							Kind: credentialTypes.SecretKind,
+							Name: "invalid-secret-test-creds",
+						},
+					},
+				},
+			}
+			capiCluster := &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "invalid-secret-test", Namespace: "default", UID: "cluster-uid"}}
+			objects := []client.Object{ntnxCluster, capiCluster}
+			if tt.data != nil {
+				objects = append(objects, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "invalid-secret-test-creds", Namespace: "default"},
+					Data:       tt.data,
+				})
+			}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixClusterReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+				Scheme:   scheme,
+				Recorder: recorder,
+			}
+
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ntnxCluster)})
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(errors.Is(err, nutanixClient.ErrInvalidCredentials)).To(Equal(tt.expectedReason == infrav1.CredentialSecretInvalid))
+
+			g.Expect(reconciler.Client.Get(ctx, client.ObjectKeyFromObject(ntnxCluster), ntnxCluster)).To(Succeed())
+			condition := conditions.Get(ntnxCluster, infrav1.CredentialRefSecretOwnerSetCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+			g.Expect(condition.Reason).To(Equal(tt.expectedReason))
+			g.Expect(condition.Message).To(ContainSubstring(tt.expectedMessage))
+		})
+	}
+}
+
+func TestReconcileCredentialRefRotation(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	ntnxCluster := &infrav1.NutanixCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       infrav1.NutanixClusterKind,
+			APIVersion: infrav1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rotation-test",
+			Namespace: "default",
+			UID:       utilruntime.NewUUID(),
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+				Address: "prism.example.com",
+				Port:    9440,
+				CredentialRef: &credentialTypes.NutanixCredentialReference{
+					Kind: credentialTypes.SecretKind,
+					Name: "rotation-test-creds",
+				},
+			},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rotation-test-creds",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"credentials": []byte(testCredentials("old")),
+		},
+	}
+	reconciler := &NutanixClusterReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+	}
+	secretKey := client.ObjectKeyFromObject(secret)
+	cachedClient := &nutanixClientV3.Client{}
+	defer nutanixClient.NutanixClientCache.Delete(ntnxCluster)
+
+	// The hash of the secret content is recorded on the secret
+	g.Expect(reconciler.reconcileCredentialRef(ctx, ntnxCluster)).To(Succeed())
+	g.Expect(reconciler.Client.Get(ctx, secretKey, secret)).To(Succeed())
+	g.Expect(secret.Annotations).To(HaveKey(infrav1.NutanixClusterCredentialHashAnnotation))
+
+	// The cached client is kept as long as the secret does not change
+	nutanixClient.NutanixClientCache.Set(ntnxCluster, ntnxCluster.Spec.PrismCentral, "", cachedClient)
+	g.Expect(reconciler.reconcileCredentialRef(ctx, ntnxCluster)).To(Succeed())
+	c, ok := nutanixClient.NutanixClientCache.Get(ntnxCluster, "")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(c).To(BeIdenticalTo(cachedClient))
+
+	// Rotating the credentials invalidates the cached client so it is rebuilt on the next reconcile
+	g.Expect(reconciler.Client.Get(ctx, secretKey, secret)).To(Succeed())
+	oldHash := secret.Annotations[infrav1.NutanixClusterCredentialHashAnnotation]
+	secret.Data["credentials"] = []byte(testCredentials("new"))
+	g.Expect(reconciler.Client.Update(ctx, secret)).To(Succeed())
+	g.Expect(reconciler.reconcileCredentialRef(ctx, ntnxCluster)).To(Succeed())
+	_, ok = nutanixClient.NutanixClientCache.Get(ntnxCluster, "")
+	g.Expect(ok).To(BeFalse())
+	g.Expect(reconciler.Client.Get(ctx, secretKey, secret)).To(Succeed())
+	g.Expect(secret.Annotations[infrav1.NutanixClusterCredentialHashAnnotation]).NotTo(Equal(oldHash))
+}
+
+func TestReconcileCredentialRefNamespace(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	NewWithT(t).Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	newSecret := func(namespace string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: namespace},
+			Data:       map[string][]byte{"credentials": []byte(testCredentials("password"))},
+		}
+	}
+	newCluster := func(credentialNamespace string) *infrav1.NutanixCluster {
+		return &infrav1.NutanixCluster{
+			TypeMeta:   metav1.TypeMeta{Kind: infrav1.NutanixClusterKind, APIVersion: infrav1.GroupVersion.String()},
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "tenant-a", UID: utilruntime.NewUUID()},
+			Spec: infrav1.NutanixClusterSpec{
+				PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+					Address: "prism.example.com",
+					Port:    9440,
+					CredentialRef: &credentialTypes.NutanixCredentialReference{
+						Kind:      credentialTypes.SecretKind,
+						Name:      "creds",
+						Namespace: credentialNamespace,
+					},
+				},
+			},
+		}
+	}
+	config := &ControllerConfig{RestrictCredentialNamespaces: true, AllowedCredentialNamespaces: []string{"shared"}}
+
+	t.Run("same namespace is allowed", func(t *testing.T) {
+		g := NewWithT(t)
+		secret := newSecret("tenant-a")
+		reconciler := &NutanixClusterReconciler{
+			Client:           fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+			controllerConfig: config,
+		}
+		ntnxCluster := newCluster("")
+		g.Expect(reconciler.reconcileCredentialRef(ctx, ntnxCluster)).To(Succeed())
+		g.Expect(reconciler.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		g.Expect(capiutil.IsOwnedByObject(secret, ntnxCluster)).To(BeTrue())
+		g.Expect(secret.Finalizers).To(ContainElement(infrav1.NutanixClusterCredentialFinalizer))
+	})
+
+	t.Run("other namespace is blocked", func(t *testing.T) {
+		g := NewWithT(t)
+		secret := newSecret("tenant-b")
+		reconciler := &NutanixClusterReconciler{
+			Client:           fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+			controllerConfig: config,
+		}
+		err := reconciler.reconcileCredentialRef(ctx, newCluster("tenant-b"))
+		g.Expect(errors.Is(err, errCredentialNamespaceNotAllowed)).To(BeTrue())
+		g.Expect(reconciler.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		g.Expect(secret.Annotations).NotTo(HaveKey(infrav1.NutanixClusterCredentialHashAnnotation))
+
+		// Other namespaces are allowed without restriction
+		reconciler.controllerConfig = nil
+		g.Expect(reconciler.reconcileCredentialRef(ctx, newCluster("tenant-b"))).To(Succeed())
+	})
+
+	t.Run("allow-listed namespace is allowed but not owned", func(t *testing.T) {
+		g := NewWithT(t)
+		secret := newSecret("shared")
+		reconciler := &NutanixClusterReconciler{
+			Client:           fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+			controllerConfig: config,
+		}
+		ntnxCluster := newCluster("shared")
+		g.Expect(reconciler.reconcileCredentialRef(ctx, ntnxCluster)).To(Succeed())
+		g.Expect(reconciler.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		g.Expect(secret.Annotations).To(HaveKey(infrav1.NutanixClusterCredentialHashAnnotation))
+		g.Expect(secret.OwnerReferences).To(BeEmpty())
+		g.Expect(secret.Finalizers).To(BeEmpty())
+
+		// The shared secret is kept when the cluster is deleted
+		g.Expect(reconciler.reconcileCredentialRefDelete(ctx, ntnxCluster)).To(Succeed())
+		g.Expect(reconciler.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+	})
+}
+
+func TestReconcileCredentialRefUnmanagedFinalizers(t *testing.T) {
+	const externalFinalizer = "gitops.example.com/keep"
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	NewWithT(t).Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	newCluster := func() *infrav1.NutanixCluster {
+		return &infrav1.NutanixCluster{
+			TypeMeta:   metav1.TypeMeta{Kind: infrav1.NutanixClusterKind, APIVersion: infrav1.GroupVersion.String()},
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default", UID: utilruntime.NewUUID()},
+			Spec: infrav1.NutanixClusterSpec{
+				PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+					Address: "prism.example.com",
+					Port:    9440,
+					CredentialRef: &credentialTypes.NutanixCredentialReference{
+						Kind: credentialTypes.SecretKind,
+						Name: "creds",
+					},
+				},
+			},
+		}
+	}
+	config := &ControllerConfig{UnmanagedCredentialFinalizers: true}
+
+	t.Run("finalizer is not added", func(t *testing.T) {
+		g := NewWithT(t)
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default", Finalizers: []string{externalFinalizer}},
+			Data:       map[string][]byte{"credentials": []byte(testCredentials("password"))},
+		}
+		reconciler := &NutanixClusterReconciler{
+			Client:           fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+			controllerConfig: config,
+		}
+		ntnxCluster := newCluster()
+
+		// The secret is still owned and used by the cluster
+		g.Expect(reconciler.reconcileCredentialRef(ctx, ntnxCluster)).To(Succeed())
+		g.Expect(reconciler.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		g.Expect(capiutil.IsOwnedByObject(secret, ntnxCluster)).To(BeTrue())
+		g.Expect(secret.Annotations).To(HaveKey(infrav1.NutanixClusterCredentialHashAnnotation))
+		g.Expect(secret.Finalizers).To(ConsistOf(externalFinalizer))
+
+		// The deletion does not wait for the external finalizer
+		g.Expect(reconciler.reconcileCredentialRefDelete(ctx, ntnxCluster)).To(Succeed())
+		g.Expect(reconciler.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		g.Expect(secret.DeletionTimestamp.IsZero()).To(BeFalse())
+		g.Expect(secret.Finalizers).To(ConsistOf(externalFinalizer))
+		g.Expect(reconciler.reconcileCredentialRefDelete(ctx, ntnxCluster)).To(Succeed())
+	})
+
+	t.Run("finalizer added before is removed on deletion", func(t *testing.T) {
+		g := NewWithT(t)
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default", Finalizers: []string{infrav1.NutanixClusterCredentialFinalizer}},
+			Data:       map[string][]byte{"credentials": []byte(testCredentials("password"))},
+		}
+		reconciler := &NutanixClusterReconciler{
+			Client:           fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build(),
+			controllerConfig: config,
+		}
+		ntnxCluster := newCluster()
+
+		g.Expect(reconciler.reconcileCredentialRef(ctx, ntnxCluster)).To(Succeed())
+		g.Expect(reconciler.reconcileCredentialRefDelete(ctx, ntnxCluster)).To(Succeed())
+		g.Expect(apierrors.IsNotFound(reconciler.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret))).To(BeTrue())
+	})
+}
+
+func TestReconcileTrustBundleRef(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	policy := nutanixClient.TrustBundlePolicy{
+		MinRSAKeySize:           2048,
+		WeakSignatureAlgorithms: []x509.SignatureAlgorithm{x509.SHA1WithRSA},
+	}
+
+	tests := []struct {
+		name         string
+		keySize      int
+		signatureAlg x509.SignatureAlgorithm
+		useConfigMap bool
+		expectErr    bool
+	}{
+		{
+			name:         "strong certificate",
+			keySize:      2048,
+			signatureAlg: x509.SHA256WithRSA,
+		},
+		{
+			name:         "strong certificate in configmap",
+			keySize:      2048,
+			signatureAlg: x509.SHA256WithRSA,
+			useConfigMap: true,
+		},
+		{
+			name:         "weak key size",
+			keySize:      1024,
+			signatureAlg: x509.SHA256WithRSA,
+			expectErr:    true,
+		},
+		{
+			name:         "weak signature algorithm",
+			keySize:      2048,
+			signatureAlg: x509.SHA1WithRSA,
+			useConfigMap: true,
+			expectErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			certPEM := generateTestCertificate(g, tt.keySize, tt.signatureAlg)
+			ntnxCluster := &infrav1.NutanixCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: infrav1.NutanixClusterSpec{
+					PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+						AdditionalTrustBundle: &credentialTypes.NutanixTrustBundleReference{
+							Kind: credentialTypes.NutanixTrustBundleKindString,
+							Data: certPEM,
+						},
+					},
+				},
+			}
+			objs := make([]client.Object, 0)
+			if tt.useConfigMap {
+				ntnxCluster.Spec.PrismCentral.AdditionalTrustBundle = &credentialTypes.NutanixTrustBundleReference{
+					Kind: credentialTypes.NutanixTrustBundleKindConfigMap,
+					Name: "trust-bundle",
+				}
+				objs = append(objs, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "trust-bundle",
+						Namespace: "default",
+					},
+					Data: map[string]string{
+						nutanixClient.TrustBundleConfigMapKey: certPEM,
+					},
+				})
+			}
+			reconciler := &NutanixClusterReconciler{
+				Client:           fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+				controllerConfig: &ControllerConfig{TrustBundlePolicy: policy},
+			}
+
+			err := reconciler.reconcileTrustBundleRef(ctx, ntnxCluster)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(conditions.GetReason(ntnxCluster, infrav1.TrustBundleSecureCondition)).To(Equal(infrav1.TrustBundleWeakCertificate))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(conditions.IsTrue(ntnxCluster, infrav1.TrustBundleSecureCondition)).To(BeTrue())
+			}
+
+			// The condition is removed once the policy is removed
+			reconciler.controllerConfig = &ControllerConfig{}
+			g.Expect(reconciler.reconcileTrustBundleRef(ctx, ntnxCluster)).To(Succeed())
+			g.Expect(conditions.Has(ntnxCluster, infrav1.TrustBundleSecureCondition)).To(BeFalse())
+
+			// The conditions are removed once the trust bundle is removed
+			reconciler.controllerConfig = &ControllerConfig{TrustBundlePolicy: policy}
+			g.Expect(reconciler.reconcileTrustBundleRef(ctx, ntnxCluster) != nil).To(Equal(tt.expectErr))
+			g.Expect(conditions.Has(ntnxCluster, infrav1.TrustBundleSecureCondition)).To(BeTrue())
+			ntnxCluster.Spec.PrismCentral.AdditionalTrustBundle = nil
+			g.Expect(reconciler.reconcileTrustBundleRef(ctx, ntnxCluster)).To(Succeed())
+			g.Expect(conditions.Has(ntnxCluster, infrav1.TrustBundleSecureCondition)).To(BeFalse())
+			g.Expect(conditions.Has(ntnxCluster, infrav1.TrustBundleValidCondition)).To(BeFalse())
+		})
+	}
+}
+
+func TestReconcileTrustBundleValidity(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name             string
+		trustBundle      func(g *WithT) string
+		expectErr        bool
+		expectedValid    bool
+		expectedReason   string
+		expectedSeverity capiv1.ConditionSeverity
+	}{
+		{
+			name: "valid certificate",
+			trustBundle: func(g *WithT) string {
+				return generateTestCertificate(g, 2048, x509.SHA256WithRSA)
+			},
+			expectedValid: true,
+		},
+		{
+			name:             "malformed content",
+			trustBundle:      func(_ *WithT) string { return "not a certificate" },
+			expectErr:        true,
+			expectedReason:   infrav1.TrustBundleInvalid,
+			expectedSeverity: capiv1.ConditionSeverityError,
+		},
+		{
+			name: "malformed certificate",
+			trustBundle: func(_ *WithT) string {
+				return "-----BEGIN CERTIFICATE-----\nbm90IGEgY2VydGlmaWNhdGU=\n-----END CERTIFICATE-----\n"
+			},
+			expectErr:        true,
+			expectedReason:   infrav1.TrustBundleInvalid,
+			expectedSeverity: capiv1.ConditionSeverityError,
+		},
+		{
+			name: "expired certificate",
+			trustBundle: func(g *WithT) string {
+				return generateTestCertificate(g, 2048, x509.SHA256WithRSA) +
+					generateTestCertificateWithExpiry(g, 2048, x509.SHA256WithRSA, time.Now().Add(-time.Hour))
+			},
+			expectedReason:   infrav1.TrustBundleCertificateExpired,
+			expectedSeverity: capiv1.ConditionSeverityWarning,
+		},
+		{
+			name: "certificate expiring soon",
+			trustBundle: func(g *WithT) string {
+				return generateTestCertificateWithExpiry(g, 2048, x509.SHA256WithRSA, time.Now().Add(7*24*time.Hour))
+			},
+			expectedReason:   infrav1.TrustBundleCertificateExpiring,
+			expectedSeverity: capiv1.ConditionSeverityWarning,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxCluster := &infrav1.NutanixCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: infrav1.NutanixClusterSpec{
+					PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+						AdditionalTrustBundle: &credentialTypes.NutanixTrustBundleReference{
+							Kind: credentialTypes.NutanixTrustBundleKindString,
+							Data: tt.trustBundle(g),
+						},
+					},
+				},
+			}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixClusterReconciler{
+				Client:   fake.NewClientBuilder().Build(),
+				Recorder: recorder,
+			}
+
+			err := reconciler.reconcileTrustBundleRef(ctx, ntnxCluster)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				// Parseable trust bundles are still used
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			if tt.expectedValid {
+				g.Expect(conditions.IsTrue(ntnxCluster, infrav1.TrustBundleValidCondition)).To(BeTrue())
+				g.Expect(receivedEventReasons(recorder)).To(BeEmpty())
+				return
+			}
+			g.Expect(conditions.IsFalse(ntnxCluster, infrav1.TrustBundleValidCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(ntnxCluster, infrav1.TrustBundleValidCondition)).To(Equal(tt.expectedReason))
+			g.Expect(*conditions.GetSeverity(ntnxCluster, infrav1.TrustBundleValidCondition)).To(Equal(tt.expectedSeverity))
+			g.Expect(receivedEventReasons(recorder)).To(ConsistOf(tt.expectedReason))
+
+			// The warning is only recorded once
+			_ = reconciler.reconcileTrustBundleRef(ctx, ntnxCluster)
+			g.Expect(receivedEventReasons(recorder)).To(BeEmpty())
+		})
+	}
+}
+
+func TestReconcileTrustBundleRefRotation(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	ntnxCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rotation-test",
+			Namespace: "default",
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+				Address: "prism.example.com",
+				Port:    9440,
+				AdditionalTrustBundle: &credentialTypes.NutanixTrustBundleReference{
+					Kind: credentialTypes.NutanixTrustBundleKindConfigMap,
+					Name: "rotation-test-trust-bundle",
+				},
+			},
+		},
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rotation-test-trust-bundle",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			nutanixClient.TrustBundleConfigMapKey: generateTestCertificate(g, 2048, x509.SHA256WithRSA),
+		},
+	}
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &NutanixClusterReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm).Build(),
+		Recorder: recorder,
+	}
+	cmKey := client.ObjectKeyFromObject(cm)
+	cachedClient := &nutanixClientV3.Client{}
+	defer nutanixClient.NutanixClientCache.Delete(ntnxCluster)
+
+	// The hash of the configmap content is recorded on the configmap
+	g.Expect(reconciler.reconcileTrustBundleRef(ctx, ntnxCluster)).To(Succeed())
+	g.Expect(reconciler.Client.Get(ctx, cmKey, cm)).To(Succeed())
+	g.Expect(cm.Annotations).To(HaveKey(infrav1.NutanixClusterTrustBundleHashAnnotation))
+
+	// The cached client is kept as long as the configmap does not change
+	nutanixClient.NutanixClientCache.Set(ntnxCluster, ntnxCluster.Spec.PrismCentral, "", cachedClient)
+	g.Expect(reconciler.reconcileTrustBundleRef(ctx, ntnxCluster)).To(Succeed())
+	c, ok := nutanixClient.NutanixClientCache.Get(ntnxCluster, "")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(c).To(BeIdenticalTo(cachedClient))
+	g.Expect(receivedEventReasons(recorder)).To(BeEmpty())
+
+	// Rotating the CA invalidates the cached client so it is rebuilt with the new trust bundle on the next reconcile
+	g.Expect(reconciler.Client.Get(ctx, cmKey, cm)).To(Succeed())
+	oldHash := cm.Annotations[infrav1.NutanixClusterTrustBundleHashAnnotation]
+	cm.Data[nutanixClient.TrustBundleConfigMapKey] = generateTestCertificate(g, 2048, x509.SHA256WithRSA)
+	g.Expect(reconciler.Client.Update(ctx, cm)).To(Succeed())
+	g.Expect(reconciler.reconcileTrustBundleRef(ctx, ntnxCluster)).To(Succeed())
+	_, ok = nutanixClient.NutanixClientCache.Get(ntnxCluster, "")
+	g.Expect(ok).To(BeFalse())
+	g.Expect(reconciler.Client.Get(ctx, cmKey, cm)).To(Succeed())
+	g.Expect(cm.Annotations[infrav1.NutanixClusterTrustBundleHashAnnotation]).NotTo(Equal(oldHash))
+	g.Expect(receivedEventReasons(recorder)).To(ConsistOf(trustBundleChangedEventReason))
+}
+
+func TestMapTrustBundleConfigMapToNutanixClusters(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	newCluster := func(namespace, name string, trustBundleRef *credentialTypes.NutanixTrustBundleReference) *infrav1.NutanixCluster {
+		return &infrav1.NutanixCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: infrav1.NutanixClusterSpec{
+				PrismCentral: &credentialTypes.NutanixPrismEndpoint{AdditionalTrustBundle: trustBundleRef},
+			},
+		}
+	}
+	clusters := []client.Object{
+		newCluster("default", "same-namespace", &credentialTypes.NutanixTrustBundleReference{
+			Kind: credentialTypes.NutanixTrustBundleKindConfigMap,
+			Name: "trust-bundle",
+		}),
+		newCluster("other", "explicit-namespace", &credentialTypes.NutanixTrustBundleReference{
+			Kind:      credentialTypes.NutanixTrustBundleKindConfigMap,
+			Name:      "trust-bundle",
+			Namespace: "default",
+		}),
+		newCluster("other", "other-namespace", &credentialTypes.NutanixTrustBundleReference{
+			Kind: credentialTypes.NutanixTrustBundleKindConfigMap,
+			Name: "trust-bundle",
+		}),
+		newCluster("default", "other-configmap", &credentialTypes.NutanixTrustBundleReference{
+			Kind: credentialTypes.NutanixTrustBundleKindConfigMap,
+			Name: "other-trust-bundle",
+		}),
+		newCluster("default", "string", &credentialTypes.NutanixTrustBundleReference{
+			Kind: credentialTypes.NutanixTrustBundleKindString,
+			Data: "trust-bundle",
+		}),
+		newCluster("default", "no-trust-bundle", nil),
+	}
+	reconciler := &NutanixClusterReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(clusters...).Build(),
+	}
+
+	requests := reconciler.mapTrustBundleConfigMapToNutanixClusters(ctx)(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "trust-bundle", Namespace: "default"},
+	})
+	g.Expect(requests).To(ConsistOf(
+		ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "same-namespace"}},
+		ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "other", Name: "explicit-namespace"}},
+	))
+}
+
+func TestMapSecretToNutanixClusters(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	newCluster := func(namespace, name string, credentialRef *credentialTypes.NutanixCredentialReference) *infrav1.NutanixCluster {
+		return &infrav1.NutanixCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: infrav1.NutanixClusterSpec{
+				PrismCentral: &credentialTypes.NutanixPrismEndpoint{CredentialRef: credentialRef},
+			},
+		}
+	}
+	clientCertificateCluster := newCluster("default", "client-certificate", nil)
+	clientCertificateCluster.Spec.ClientCertificate = &infrav1.NutanixClientCertificate{
+		CertificateRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "creds"}, Key: "tls.crt"},
+		KeyRef:         &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "creds"}, Key: "tls.key"},
+	}
+	clusters := []client.Object{
+		newCluster("default", "same-namespace", &credentialTypes.NutanixCredentialReference{
+			Kind: credentialTypes.SecretKind,
+			Name: "creds",
+		}),
+		// Secrets of other namespaces are not owned by the NutanixCluster
+		newCluster("other", "explicit-namespace", &credentialTypes.NutanixCredentialReference{
+			Kind:      credentialTypes.SecretKind,
+			Name:      "creds",
+			Namespace: "default",
+		}),
+		newCluster("other", "other-namespace", &credentialTypes.NutanixCredentialReference{
+			Kind: credentialTypes.SecretKind,
+			Name: "creds",
+		}),
+		newCluster("default", "other-secret", &credentialTypes.NutanixCredentialReference{
+			Kind: credentialTypes.SecretKind,
+			Name: "other-creds",
+		}),
+		newCluster("default", "no-credentials", nil),
+		clientCertificateCluster,
+	}
+	reconciler := &NutanixClusterReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(clusters...).Build(),
+	}
+
+	requests := reconciler.mapSecretToNutanixClusters(ctx)(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default"},
+	})
+	g.Expect(requests).To(ConsistOf(
+		ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "same-namespace"}},
+		ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "other", Name: "explicit-namespace"}},
+		ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "client-certificate"}},
+	))
+}
+
+func TestReconcileInsecureTLS(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	ntnxCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "insecure-test",
+			Namespace: "default",
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			InsecureSkipVerify: utils.BoolPtr(true),
+		},
+	}
+	recorder := record.NewFakeRecorder(1)
+	reconciler := &NutanixClusterReconciler{
+		Recorder: recorder,
+	}
+
+	reconciler.reconcileInsecureTLS(ctx, ntnxCluster)
+	g.Expect(conditions.IsTrue(ntnxCluster, infrav1.InsecureTLSCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(ntnxCluster, infrav1.InsecureTLSCondition)).To(Equal(infrav1.InsecureSkipVerifyEnabled))
+	g.Expect(recorder.Events).To(Receive(HavePrefix(corev1.EventTypeWarning + " " + infrav1.InsecureSkipVerifyEnabled)))
+
+	// The condition is removed once the TLS certificate is verified again
+	ntnxCluster.Spec.InsecureSkipVerify = utils.BoolPtr(false)
+	reconciler.reconcileInsecureTLS(ctx, ntnxCluster)
+	g.Expect(conditions.Has(ntnxCluster, infrav1.InsecureTLSCondition)).To(BeFalse())
+	g.Expect(recorder.Events).NotTo(Receive())
+}
+
+func TestReconcileFailureDomainMigration(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	ntnxCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			UID:       utilruntime.NewUUID(),
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			FailureDomains: []infrav1.NutanixFailureDomainConfig{
+				{
+					Name: "fd-1",
+					Cluster: infrav1.NutanixResourceIdentifier{
+						Type: infrav1.NutanixIdentifierName,
+						Name: utils.StringPtr("pe-1"),
+					},
+					Subnets: []infrav1.NutanixResourceIdentifier{
+						{
+							Type: infrav1.NutanixIdentifierName,
+							Name: utils.StringPtr("subnet-1"),
+						},
+					},
+					ControlPlane: true,
+				},
+				{
+					Name: "fd-2",
+					Cluster: infrav1.NutanixResourceIdentifier{
+						Type: infrav1.NutanixIdentifierName,
+						Name: utils.StringPtr("pe-2"),
+					},
+					Subnets: []infrav1.NutanixResourceIdentifier{
+						{
+							Type: infrav1.NutanixIdentifierName,
+							Name: utils.StringPtr("subnet-2"),
+						},
+					},
+				},
+			},
+		},
+	}
+	reconciler := &NutanixClusterReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(ntnxCluster).Build(),
+		Scheme: scheme,
+	}
+	rctx := &nctx.ClusterContext{
+		Context:        ctx,
+		Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		NutanixCluster: ntnxCluster,
+	}
+
+	g.Expect(reconciler.reconcileFailureDomainMigration(rctx)).To(Succeed())
+	g.Expect(conditions.IsTrue(ntnxCluster, infrav1.FailureDomainsMigratedCondition)).To(BeTrue())
+
+	resourceVersions := make(map[string]string)
+	for _, fd := range ntnxCluster.Spec.FailureDomains {
+		nfd := &infrav1.NutanixFailureDomain{}
+		key := client.ObjectKey{Namespace: "default", Name: GetNutanixFailureDomainName(ntnxCluster.Name, fd.Name)}
+		g.Expect(reconciler.Client.Get(ctx, key, nfd)).To(Succeed())
+		g.Expect(nfd.Spec.PrismElementCluster).To(Equal(fd.Cluster))
+		g.Expect(nfd.Spec.Subnets).To(Equal(fd.Subnets))
+		g.Expect(nfd.Spec.ControlPlane).To(Equal(fd.ControlPlane))
+		g.Expect(nfd.Labels).To(HaveKeyWithValue(capiv1.ClusterLabelName, "test"))
+		g.Expect(metav1.IsControlledBy(nfd, ntnxCluster)).To(BeTrue())
+		resourceVersions[key.Name] = nfd.ResourceVersion
+	}
+
+	// Reconciling again does not modify the NutanixFailureDomain objects
+	g.Expect(reconciler.reconcileFailureDomainMigration(rctx)).To(Succeed())
+	nfdList := &infrav1.NutanixFailureDomainList{}
+	g.Expect(reconciler.Client.List(ctx, nfdList, client.InNamespace("default"))).To(Succeed())
+	g.Expect(nfdList.Items).To(HaveLen(2))
+	for _, nfd := range nfdList.Items {
+		g.Expect(nfd.ResourceVersion).To(Equal(resourceVersions[nfd.Name]))
+	}
+
+	// Legacy failure domains are still populated on the status. Their clusters and subnets are not looked up.
+	rctx.NutanixCluster.Annotations = map[string]string{infrav1.SkipFailureDomainValidationAnnotation: ""}
+	requeue, err := reconciler.reconcileFailureDomains(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(requeue).To(BeFalse())
+	g.Expect(ntnxCluster.Status.FailureDomains).To(HaveKeyWithValue("fd-1", capiv1.FailureDomainSpec{ControlPlane: true}))
+	g.Expect(ntnxCluster.Status.FailureDomains).To(HaveKeyWithValue("fd-2", capiv1.FailureDomainSpec{ControlPlane: false}))
+
+	// NutanixFailureDomain objects of the cluster not controlled by the NutanixCluster are kept
+	userNFD := &infrav1.NutanixFailureDomain{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "user-fd",
+			Namespace: "default",
+			Labels:    map[string]string{capiv1.ClusterLabelName: "test"},
+		},
+	}
+	g.Expect(reconciler.Client.Create(ctx, userNFD)).To(Succeed())
+
+	// The NutanixFailureDomain of a removed failure domain is deleted
+	ntnxCluster.Spec.FailureDomains = ntnxCluster.Spec.FailureDomains[:1]
+	g.Expect(reconciler.reconcileFailureDomainMigration(rctx)).To(Succeed())
+	g.Expect(reconciler.Client.List(ctx, nfdList, client.InNamespace("default"))).To(Succeed())
+	g.Expect(nfdList.Items).To(HaveLen(2))
+	g.Expect([]string{nfdList.Items[0].Name, nfdList.Items[1].Name}).To(ConsistOf(GetNutanixFailureDomainName(ntnxCluster.Name, "fd-1"), "user-fd"))
+	g.Expect(conditions.IsTrue(ntnxCluster, infrav1.FailureDomainsMigratedCondition)).To(BeTrue())
+
+	// All NutanixFailureDomains and the condition are removed with the last failure domain
+	ntnxCluster.Spec.FailureDomains = nil
+	g.Expect(reconciler.reconcileFailureDomainMigration(rctx)).To(Succeed())
+	g.Expect(reconciler.Client.List(ctx, nfdList, client.InNamespace("default"))).To(Succeed())
+	g.Expect(nfdList.Items).To(HaveLen(1))
+	g.Expect(nfdList.Items[0].Name).To(Equal("user-fd"))
+	g.Expect(conditions.Has(ntnxCluster, infrav1.FailureDomainsMigratedCondition)).To(BeFalse())
+}
+
+func TestReconcilePartiallyResolvedFailureDomains(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	ntnxCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			UID:       utilruntime.NewUUID(),
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			FailureDomains: []infrav1.NutanixFailureDomainConfig{
+				{
+					Name: "fd-1",
+					Cluster: infrav1.NutanixResourceIdentifier{
+						Type: infrav1.NutanixIdentifierName,
+						Name: utils.StringPtr("pe"),
+					},
+					Subnets: []infrav1.NutanixResourceIdentifier{
+						{
+							Type: infrav1.NutanixIdentifierName,
+							Name: utils.StringPtr("subnet"),
+						},
+					},
+					ControlPlane: true,
+				},
+				{
+					Name: "fd-2",
+					Cluster: infrav1.NutanixResourceIdentifier{
+						Type: infrav1.NutanixIdentifierName,
+						Name: utils.StringPtr("pe"),
+					},
+					Subnets: []infrav1.NutanixResourceIdentifier{
+						{
+							Type: infrav1.NutanixIdentifierName,
+							Name: utils.StringPtr("missing"),
+						},
+					},
+					ControlPlane: true,
+				},
+			},
+		},
+		Status: infrav1.NutanixClusterStatus{
+			Ready: true,
+			// fd-2 was resolved by an earlier reconciliation
+			FailureDomains: capiv1.FailureDomains{"fd-2": capiv1.FailureDomainSpec{ControlPlane: true}},
+		},
+	}
+	reconciler := &NutanixClusterReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(ntnxCluster).Build(),
+		Scheme: scheme,
+	}
+	rctx := &nctx.ClusterContext{
+		Context:        ctx,
+		Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		NutanixCluster: ntnxCluster,
+		NutanixClient:  &nutanixClientV3.Client{V3: &fakeLookupService{}},
+	}
+
+	requeue, err := reconciler.reconcileFailureDomains(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(requeue).To(BeTrue())
+	g.Expect(ntnxCluster.Status.FailureDomains).To(HaveKey("fd-1"))
+	g.Expect(ntnxCluster.Status.FailureDomains).NotTo(HaveKey("fd-2"))
+	g.Expect(conditions.IsFalse(ntnxCluster, infrav1.FailureDomainsReconciled)).To(BeTrue())
+	g.Expect(conditions.GetSeverity(ntnxCluster, infrav1.FailureDomainsReconciled)).To(HaveValue(Equal(capiv1.ConditionSeverityWarning)))
+	g.Expect(conditions.GetMessage(ntnxCluster, infrav1.FailureDomainsReconciled)).To(And(
+		ContainSubstring("failed to resolve 1 of 2 failure domains"),
+		ContainSubstring("fd-2"),
+		Not(ContainSubstring("fd-1")),
+	))
+
+	// The cluster stays Ready while the unresolved failure domain is retried
+	result, err := reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(failureDomainsRetryInterval))
+	g.Expect(ntnxCluster.Status.Ready).To(BeTrue())
+
+	// The failure domain is recorded once its subnet is found
+	ntnxCluster.Spec.FailureDomains[1].Subnets[0].Name = utils.StringPtr("subnet")
+	requeue, err = reconciler.reconcileFailureDomains(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(requeue).To(BeFalse())
+	g.Expect(ntnxCluster.Status.FailureDomains).To(HaveKey("fd-2"))
+	g.Expect(conditions.IsTrue(ntnxCluster, infrav1.FailureDomainsReconciled)).To(BeTrue())
+}
+
+// categoriesTestService is a Prism v3 service finding the Prism Element cluster, subnet and all categories
+type categoriesTestService struct {
+	fakeLookupService
+}
+
+func (s *categoriesTestService) GetCategoryKey(_ context.Context, name string) (*nutanixClientV3.CategoryKeyStatus, error) {
+	return &nutanixClientV3.CategoryKeyStatus{Name: utils.StringPtr(name)}, nil
+}
+
+func (s *categoriesTestService) GetCategoryValue(_ context.Context, _, value string) (*nutanixClientV3.CategoryValueStatus, error) {
+	return &nutanixClientV3.CategoryValueStatus{Value: utils.StringPtr(value)}, nil
+}
+
+// receivedEventReasons returns the reasons of the events recorded by the fake recorder so far
+func receivedEventReasons(recorder *record.FakeRecorder) []string {
+	reasons := make([]string, 0)
+	for {
+		select {
+		case event := <-recorder.Events:
+			reasons = append(reasons, strings.Fields(event)[1])
+		default:
+			return reasons
+		}
+	}
+}
+
+func TestNutanixClusterReconcileEvents(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	ntnxCluster := &infrav1.NutanixCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       infrav1.NutanixClusterKind,
+			APIVersion: infrav1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "events-test",
+			Namespace: "default",
+			UID:       utilruntime.NewUUID(),
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+				Address: "prism.example.com",
+				Port:    9440,
+				CredentialRef: &credentialTypes.NutanixCredentialReference{
+					Kind: credentialTypes.SecretKind,
+					Name: "events-test-creds",
+				},
+			},
+			FailureDomains: []infrav1.NutanixFailureDomainConfig{
+				{
+					Name:    "fd-1",
+					Cluster: infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("pe")},
+					Subnets: []infrav1.NutanixResourceIdentifier{{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("subnet")}},
+				},
+			},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "events-test-creds", Namespace: "default"},
+		Data:       map[string][]byte{"credentials": []byte(testCredentials("password"))},
+	}
+	recorder := record.NewFakeRecorder(20)
+	reconciler := &NutanixClusterReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret, ntnxCluster).Build(),
+		Scheme:   scheme,
+		Recorder: recorder,
+	}
+	rctx := &nctx.ClusterContext{
+		Context:        ctx,
+		Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "events-test", Namespace: "default"}},
+		NutanixCluster: ntnxCluster,
+		NutanixClient:  &nutanixClientV3.Client{V3: &categoriesTestService{}},
+	}
+	defer nutanixClient.NutanixClientCache.Delete(ntnxCluster)
+
+	// The create flow records an event for each transition
+	g.Expect(reconciler.reconcileCredentialRef(ctx, ntnxCluster)).To(Succeed())
+	_, err := reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(receivedEventReasons(recorder)).To(Equal([]string{
+		credentialSecretFinalizerAddedEventReason,
+		finalizerAddedEventReason,
+		failureDomainsReconciledEventReason,
+		categoriesCreatedEventReason,
+		clusterReadyEventReason,
+	}))
+
+	// No events are recorded without transitions
+	g.Expect(reconciler.reconcileCredentialRef(ctx, ntnxCluster)).To(Succeed())
+	_, err = reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(receivedEventReasons(recorder)).To(BeEmpty())
+
+	// A failure is recorded once until it changes
+	ntnxCluster.Spec.FailureDomains[0].Subnets[0].Name = utils.StringPtr("missing")
+	_, err = reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(receivedEventReasons(recorder)).To(Equal([]string{infrav1.FailureDomainsReconciliationFailed}))
+	_, err = reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(receivedEventReasons(recorder)).To(BeEmpty())
+
+	ntnxCluster.Spec.FailureDomains[0].Subnets[0].Name = utils.StringPtr("subnet")
+	_, err = reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(receivedEventReasons(recorder)).To(Equal([]string{failureDomainsReconciledEventReason}))
+
+	// Rotating the credentials is recorded
+	g.Expect(reconciler.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+	secret.Data["credentials"] = []byte(testCredentials("rotated"))
+	g.Expect(reconciler.Client.Update(ctx, secret)).To(Succeed())
+	g.Expect(reconciler.reconcileCredentialRef(ctx, ntnxCluster)).To(Succeed())
+	g.Expect(receivedEventReasons(recorder)).To(Equal([]string{credentialSecretChangedEventReason}))
+}
+
+func TestNutanixClusterReconcileObservedGeneration(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	ntnxCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test",
+			Namespace:  "default",
+			UID:        utilruntime.NewUUID(),
+			Generation: 2,
+		},
+		Status: infrav1.NutanixClusterStatus{
+			Ready:              true,
+			ObservedGeneration: 1,
+		},
+	}
+	reconciler := &NutanixClusterReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(ntnxCluster).Build(),
+		Scheme: scheme,
+	}
+	rctx := &nctx.ClusterContext{
+		Context:        ctx,
+		Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		NutanixCluster: ntnxCluster,
+	}
+
+	// The generation is not observed if the reconciliation fails early
+	ntnxCluster.Spec.FailureDomains = []infrav1.NutanixFailureDomainConfig{{Name: "fd-1", Weight: pointer.Int32(0)}}
+	_, err := reconciler.reconcileNormal(rctx)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(ntnxCluster.Status.ObservedGeneration).To(Equal(int64(1)))
+
+	ntnxCluster.Spec.FailureDomains = nil
+	_, err = reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ntnxCluster.Status.ObservedGeneration).To(Equal(int64(2)))
+}
+
+func TestNutanixClusterReconcilePaused(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(capiv1.AddToScheme(scheme)).To(Succeed())
+	cluster := &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	ntnxCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: capiv1.GroupVersion.String(), Kind: "Cluster", Name: "test"},
+			},
+			Annotations:       map[string]string{infrav1.PausedAnnotation: ""},
+			Finalizers:        []string{infrav1.NutanixClusterFinalizer},
+			DeletionTimestamp: &metav1.Time{Time: time.Now()},
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			PrismCentral: &credentialTypes.NutanixPrismEndpoint{Address: "prism.example.com", Port: 9440},
+		},
+	}
+	writeClient := &writeCountingClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, ntnxCluster).Build()}
+	reconciler := &NutanixClusterReconciler{
+		Client: writeClient,
+		Scheme: scheme,
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ntnxCluster)}
+
+	// Only the Paused condition is set
+	_, err := reconciler.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(writeClient.writes).To(BeNumerically(">", 0))
+	writes := writeClient.writes
+	paused := &infrav1.NutanixCluster{}
+	g.Expect(writeClient.Get(ctx, req.NamespacedName, paused)).To(Succeed())
+	g.Expect(conditions.IsTrue(paused, infrav1.PausedCondition)).To(BeTrue())
+	g.Expect(paused.Finalizers).To(Equal(ntnxCluster.Finalizers))
+	g.Expect(paused.Spec).To(Equal(ntnxCluster.Spec))
+	g.Expect(paused.Status.Ready).To(BeFalse())
+
+	// Nothing is written while the NutanixCluster stays paused
+	_, err = reconciler.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(writeClient.writes).To(Equal(writes))
+	g.Expect(writeClient.Get(ctx, req.NamespacedName, paused)).To(Succeed())
+	g.Expect(paused.Finalizers).To(Equal(ntnxCluster.Finalizers))
+}
+
+func TestReconcileControlPlaneSpread(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	newMachine := func(name, failureDomain string, controlPlane bool) *infrav1.NutanixMachine {
+		labels := map[string]string{capiv1.ClusterLabelName: "test"}
+		if controlPlane {
+			labels[capiv1.MachineControlPlaneLabelName] = ""
+		}
+		return &infrav1.NutanixMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    labels,
+			},
+			Status: infrav1.NutanixMachineStatus{
+				FailureDomain: &failureDomain,
+			},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		machines       []client.Object
+		expectedSpread bool
+	}{
+		{
+			name: "control plane machines in distinct failure domains",
+			machines: []client.Object{
+				newMachine("cp-0", "fd-1", true),
+				newMachine("cp-1", "fd-2", true),
+				newMachine("cp-2", "fd-3", true),
+				newMachine("worker-0", "fd-1", false),
+			},
+			expectedSpread: true,
+		},
+		{
+			name: "control plane machines sharing a failure domain",
+			machines: []client.Object{
+				newMachine("cp-0", "fd-1", true),
+				newMachine("cp-1", "fd-1", true),
+				newMachine("cp-2", "fd-3", true),
+			},
+			expectedSpread: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxCluster := &infrav1.NutanixCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: infrav1.NutanixClusterSpec{
+					FailureDomains: []infrav1.NutanixFailureDomainConfig{
+						{Name: "fd-1", ControlPlane: true},
+						{Name: "fd-2", ControlPlane: true},
+						{Name: "fd-3", ControlPlane: true},
+					},
+				},
+			}
+			reconciler := &NutanixClusterReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.machines...).Build(),
+			}
+			rctx := &nctx.ClusterContext{
+				Context:        ctx,
+				NutanixCluster: ntnxCluster,
+			}
+
+			g.Expect(reconciler.reconcileControlPlaneSpread(rctx)).To(Succeed())
+			if tt.expectedSpread {
+				g.Expect(conditions.IsTrue(ntnxCluster, infrav1.ControlPlaneSpreadCondition)).To(BeTrue())
+			} else {
+				g.Expect(conditions.IsFalse(ntnxCluster, infrav1.ControlPlaneSpreadCondition)).To(BeTrue())
+				g.Expect(conditions.GetReason(ntnxCluster, infrav1.ControlPlaneSpreadCondition)).To(Equal(infrav1.ControlPlaneNotSpread))
+				g.Expect(conditions.GetMessage(ntnxCluster, infrav1.ControlPlaneSpreadCondition)).To(ContainSubstring("fd-1 (cp-0, cp-1)"))
+			}
+		})
+	}
+}
+
+// generateTestCertificate returns a PEM encoded self-signed certificate with the given key size and signature algorithm
+func generateTestCertificate(g *WithT, keySize int, signatureAlg x509.SignatureAlgorithm) string {
+	return generateTestCertificateWithExpiry(g, keySize, signatureAlg, time.Now().AddDate(1, 0, 0))
+}
+
+// generateTestCertificateWithExpiry returns a PEM encoded self-signed CA certificate that expires at the given time
+func generateTestCertificateWithExpiry(g *WithT, keySize int, signatureAlg x509.SignatureAlgorithm, notAfter time.Time) string {
+	key, err := rsa.GenerateKey(rand.Reader, keySize)
+	g.Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             notAfter.AddDate(-2, 0, 0),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		SignatureAlgorithm:    signatureAlg,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	g.Expect(err).NotTo(HaveOccurred())
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestReconcilePrismCentralCircuitBreaker(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	r := &NutanixClusterReconciler{
+		circuitBreaker: nutanixClient.NewCircuitBreaker(clock, prismCentralFailureThreshold, prismCentralBaseBackoff, prismCentralMaxBackoff),
+	}
+	nutanixCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: infrav1.NutanixClusterSpec{
+			PrismCentral: &credentialTypes.NutanixPrismEndpoint{Address: "prism-central", Port: 9440},
+		},
+	}
+	connectionErr := errors.New("dial tcp 10.0.0.1:9440: connect: connection refused")
+
+	// closed: connection failures below the threshold are returned
+	for i := 1; i < prismCentralFailureThreshold; i++ {
+		_, open := r.reconcilePrismCentralCircuit(ctx, nutanixCluster)
+		g.Expect(open).To(BeFalse())
+		_, err := r.recordPrismCentralResult(ctx, nutanixCluster, reconcile.Result{}, connectionErr)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(conditions.Has(nutanixCluster, infrav1.PrismCentralUnreachableCondition)).To(BeFalse())
+	}
+
+	// open: the NutanixCluster is requeued after the backoff delay
+	result, err := r.recordPrismCentralResult(ctx, nutanixCluster, reconcile.Result{}, connectionErr)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(prismCentralBaseBackoff))
+	g.Expect(conditions.IsTrue(nutanixCluster, infrav1.PrismCentralUnreachableCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(nutanixCluster, infrav1.PrismCentralUnreachableCondition)).To(Equal(infrav1.PrismCentralCircuitOpen))
+	result, open := r.reconcilePrismCentralCircuit(ctx, nutanixCluster)
+	g.Expect(open).To(BeTrue())
+	g.Expect(result.RequeueAfter).To(Equal(prismCentralBaseBackoff))
+
+	// half-open: a failed probe doubles the backoff delay
+	clock.SetTime(clock.Now().Add(prismCentralBaseBackoff))
+	_, open = r.reconcilePrismCentralCircuit(ctx, nutanixCluster)
+	g.Expect(open).To(BeFalse())
+	result, err = r.recordPrismCentralResult(ctx, nutanixCluster, reconcile.Result{}, connectionErr)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(2 * prismCentralBaseBackoff))
+
+	// closed: a successful probe resets the circuit breaker and removes the condition
+	clock.SetTime(clock.Now().Add(2 * prismCentralBaseBackoff))
+	_, open = r.reconcilePrismCentralCircuit(ctx, nutanixCluster)
+	g.Expect(open).To(BeFalse())
+	_, err = r.recordPrismCentralResult(ctx, nutanixCluster, reconcile.Result{}, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions.Has(nutanixCluster, infrav1.PrismCentralUnreachableCondition)).To(BeFalse())
+	_, open = r.reconcilePrismCentralCircuit(ctx, nutanixCluster)
+	g.Expect(open).To(BeFalse())
+}
+
+func TestGetPrismCentralEndpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(getPrismCentralEndpoint(&infrav1.NutanixCluster{})).To(BeEmpty())
+	for address, endpoint := range map[string]string{
+		"prism-central": "prism-central:9440",
+		"10.0.0.1":      "10.0.0.1:9440",
+		"fd00::10":      "[fd00::10]:9440",
+		"[fd00::10]":    "[fd00::10]:9440",
+	} {
+		nutanixCluster := &infrav1.NutanixCluster{
+			Spec: infrav1.NutanixClusterSpec{
+				PrismCentral: &credentialTypes.NutanixPrismEndpoint{Address: address, Port: 9440},
+			},
+		}
+		g.Expect(getPrismCentralEndpoint(nutanixCluster)).To(Equal(endpoint))
+	}
+}
+
+func TestNutanixClusterPhase(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name   string
+		modify func(*infrav1.NutanixCluster)
+		phase  infrav1.NutanixClusterPhase
+	}{
+		{
+			name:   "new cluster",
+			modify: func(*infrav1.NutanixCluster) {},
+			phase:  infrav1.NutanixClusterPhaseProvisioning,
+		},
+		{
+			name: "failure domains retried with warning severity",
+			modify: func(ncl *infrav1.NutanixCluster) {
+				conditions.MarkFalse(ncl, infrav1.FailureDomainsReconciled, infrav1.FailureDomainsReconciliationFailed, capiv1.ConditionSeverityWarning, "")
+			},
+			phase: infrav1.NutanixClusterPhaseProvisioning,
+		},
+		{
+			name: "ready cluster",
+			modify: func(ncl *infrav1.NutanixCluster) {
+				ncl.Status.Ready = true
+				conditions.MarkTrue(ncl, infrav1.PrismCentralClientCondition)
+			},
+			phase: infrav1.NutanixClusterPhaseReady,
+		},
+		{
+			name: "ready cluster with a condition in error",
+			modify: func(ncl *infrav1.NutanixCluster) {
+				ncl.Status.Ready = true
+				conditions.MarkFalse(ncl, infrav1.PrismCentralClientCondition, infrav1.PrismCentralClientInitializationFailed, capiv1.ConditionSeverityError, "")
+			},
+			phase: infrav1.NutanixClusterPhaseFailed,
+		},
+		{
+			name: "terminal failure",
+			modify: func(ncl *infrav1.NutanixCluster) {
+				ncl.Status.FailureMessage = pointer.String("failed")
+			},
+			phase: infrav1.NutanixClusterPhaseFailed,
+		},
+		{
+			name: "failed cluster being deleted",
+			modify: func(ncl *infrav1.NutanixCluster) {
+				ncl.DeletionTimestamp = &now
+				ncl.Status.Ready = true
+				ncl.Status.FailureMessage = pointer.String("failed")
+			},
+			phase: infrav1.NutanixClusterPhaseDeleting,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxCluster := &infrav1.NutanixCluster{}
+			tt.modify(ntnxCluster)
+			g.Expect(nutanixClusterPhase(ntnxCluster)).To(Equal(tt.phase))
+		})
+	}
+}
+
+func TestCollectSeedVMs(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        *ControllerConfig
+		expectDeleted []string
+	}{
+		{
+			name:          "seed VMs are deleted once if the seed disk pool is disabled",
+			config:        &ControllerConfig{},
+			expectDeleted: []string{"seed-vm"},
+		},
+		{
+			name:   "seed VMs are kept if the seed disk pool is enabled",
+			config: &ControllerConfig{SeedDiskPoolSize: 1},
+		},
+		{
+			name: "seed VMs are kept if the config is not set",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			service := newSeedPoolTestService()
+			service.addSeedVM(seedVMNamePrefix+"image-uuid-abcdefgh", "seed-vm", "seed-disk", "image-uuid", "pe-uuid", "cluster")
+			reconciler := &NutanixClusterReconciler{controllerConfig: tt.config}
+			rctx := &nctx.ClusterContext{
+				Context:        context.Background(),
+				Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+				NutanixCluster: &infrav1.NutanixCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default", UID: "uid"}},
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+			}
+
+			reconciler.collectSeedVMs(rctx)
+			// Seed VMs created later by another controller are not looked up again
+			service.addSeedVM(seedVMNamePrefix+"image-uuid-ijklmnop", "later-seed-vm", "later-seed-disk", "image-uuid", "pe-uuid", "cluster")
+			reconciler.collectSeedVMs(rctx)
+			g.Expect(service.deleted).To(ConsistOf(tt.expectDeleted))
+		})
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
+)
+
+//+kubebuilder:webhook:path=/mutate-infrastructure-cluster-x-k8s-io-v1beta1-nutanixcluster,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=nutanixclusters,verbs=create;update,versions=v1beta1,name=default.nutanixcluster.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-nutanixcluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=nutanixclusters,verbs=create;update;delete,versions=v1beta1,name=validation.nutanixcluster.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+
+// NutanixClusterValidator defaults and validates NutanixCluster objects on admission
+type NutanixClusterValidator struct {
+	Client            client.Client
+	SecretInformer    coreinformers.SecretInformer
+	ConfigMapInformer coreinformers.ConfigMapInformer
+
+	// getNutanixClient returns the client used to look up the failure domain resources in Prism Central
+	getNutanixClient func(ctx context.Context, nutanixCluster *infrav1.NutanixCluster) (*nutanixClientV3.Client, error)
+}
+
+var (
+	_ admission.CustomDefaulter = &NutanixClusterValidator{}
+	_ admission.CustomValidator = &NutanixClusterValidator{}
+)
+
+func NewNutanixClusterValidator(client client.Client, secretInformer coreinformers.SecretInformer, configMapInformer coreinformers.ConfigMapInformer) *NutanixClusterValidator {
+	v := &NutanixClusterValidator{
+		Client:            client,
+		SecretInformer:    secretInformer,
+		ConfigMapInformer: configMapInformer,
+	}
+	v.getNutanixClient = func(ctx context.Context, nutanixCluster *infrav1.NutanixCluster) (*nutanixClientV3.Client, error) {
+		return CreateNutanixClient(ctx, v.SecretInformer, v.ConfigMapInformer, nutanixCluster)
+	}
+	return v
+}
+
+// SetupWebhookWithManager registers the NutanixCluster defaulting and validating webhooks with the Manager.
+func (v *NutanixClusterValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.NutanixCluster{}).
+		WithDefaulter(v).
+		WithValidator(v).
+		Complete()
+}
+
+// Default implements admission.CustomDefaulter
+func (v *NutanixClusterValidator) Default(_ context.Context, obj runtime.Object) error {
+	nutanixCluster, ok := obj.(*infrav1.NutanixCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixCluster but got %T", obj))
+	}
+	DefaultPrismCentral(nutanixCluster)
+	allErrs := DefaultFailureDomains(nutanixCluster)
+	allErrs = append(allErrs, DefaultMachineDefaults(nutanixCluster)...)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixClusterKind).GroupKind(), nutanixCluster.Name, allErrs)
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *NutanixClusterValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	nutanixCluster, ok := obj.(*infrav1.NutanixCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixCluster but got %T", obj))
+	}
+	if err := validateSpec(nutanixCluster); err != nil {
+		return err
+	}
+	if err := v.validateCredentials(nutanixCluster); err != nil {
+		return err
+	}
+	return v.validateFailureDomains(ctx, nutanixCluster)
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (v *NutanixClusterValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldNutanixCluster, ok := oldObj.(*infrav1.NutanixCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixCluster but got %T", oldObj))
+	}
+	nutanixCluster, ok := newObj.(*infrav1.NutanixCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixCluster but got %T", newObj))
+	}
+	if err := validateSpec(nutanixCluster); err != nil {
+		return err
+	}
+	if !apiequality.Semantic.DeepEqual(oldNutanixCluster.Spec.PrismCentral, nutanixCluster.Spec.PrismCentral) {
+		if err := v.validateCredentials(nutanixCluster); err != nil {
+			return err
+		}
+	}
+	// Only look up the failure domains if they changed to not depend on Prism Central for unrelated updates
+	if apiequality.Semantic.DeepEqual(oldNutanixCluster.Spec.FailureDomains, nutanixCluster.Spec.FailureDomains) {
+		return nil
+	}
+	return v.validateFailureDomains(ctx, nutanixCluster)
+}
+
+// ValidateDelete implements admission.CustomValidator. The deletion is rejected while NutanixMachines of the cluster
+// exist, since their VMs would be orphaned, unless the force delete annotation is set.
+func (v *NutanixClusterValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	log := ctrl.LoggerFrom(ctx)
+	nutanixCluster, ok := obj.(*infrav1.NutanixCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixCluster but got %T", obj))
+	}
+	if _, ok := nutanixCluster.GetAnnotations()[infrav1.ForceDeleteAnnotation]; ok {
+		log.Info(fmt.Sprintf("allowing deletion of NutanixCluster %s without checking for NutanixMachines because annotation %s is set", nutanixCluster.Name, infrav1.ForceDeleteAnnotation))
+		return nil
+	}
+	machineList := &infrav1.NutanixMachineList{}
+	err := v.Client.List(ctx, machineList,
+		client.InNamespace(nutanixCluster.Namespace), client.MatchingLabels{capiv1.ClusterLabelName: nutanixCluster.Name})
+	if err != nil {
+		return apierrors.NewInternalError(fmt.Errorf("failed to list NutanixMachines of NutanixCluster %s: %v", nutanixCluster.Name, err))
+	}
+	if len(machineList.Items) == 0 {
+		return nil
+	}
+	machineNames := make([]string, 0, len(machineList.Items))
+	for _, machine := range machineList.Items {
+		machineNames = append(machineNames, machine.Name)
+	}
+	sort.Strings(machineNames)
+	return apierrors.NewForbidden(infrav1.GroupVersion.WithResource("nutanixclusters").GroupResource(), nutanixCluster.Name,
+		fmt.Errorf("NutanixMachines %s of the cluster still exist, delete them first or set annotation %s to delete the NutanixCluster anyway",
+			strings.Join(machineNames, ", "), infrav1.ForceDeleteAnnotation))
+}
+
+// validateSpec verifies the fields of the NutanixCluster spec that do not require Prism Central
+func validateSpec(nutanixCluster *infrav1.NutanixCluster) error {
+	allErrs := ValidateNutanixClusterSpec(nutanixCluster)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixClusterKind).GroupKind(), nutanixCluster.Name, allErrs)
+}
+
+// validateCredentials verifies that the credential secret referenced by the NutanixCluster holds basic auth or bearer
+// token credentials. Secrets that do not exist yet are verified when the client is created.
+func (v *NutanixClusterValidator) validateCredentials(nutanixCluster *infrav1.NutanixCluster) error {
+	credentialRef, err := nutanixClient.GetCredentialRefForCluster(nutanixCluster)
+	if err != nil || credentialRef == nil || credentialRef.Kind != credentialTypes.SecretKind || v.SecretInformer == nil {
+		return nil
+	}
+	namespace := credentialRef.Namespace
+	if namespace == "" {
+		namespace = nutanixCluster.Namespace
+	}
+	secret, err := v.SecretInformer.Lister().Secrets(namespace).Get(credentialRef.Name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return apierrors.NewInternalError(fmt.Errorf("failed to get credential secret %s/%s: %v", namespace, credentialRef.Name, err))
+	}
+	credentialRefPath := field.NewPath("spec", "prismCentral", "credentialRef")
+	data, ok := secret.Data[credentialTypes.KeyName]
+	if !ok {
+		allErrs := field.ErrorList{
+			field.Invalid(credentialRefPath, credentialRef.Name, fmt.Sprintf("secret %s/%s has no %q key", namespace, credentialRef.Name, credentialTypes.KeyName)),
+		}
+		return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixClusterKind).GroupKind(), nutanixCluster.Name, allErrs)
+	}
+	if err := nutanixClient.ValidateCredentials(data); err != nil {
+		allErrs := field.ErrorList{
+			field.Invalid(credentialRefPath, credentialRef.Name, fmt.Sprintf("secret %s/%s holds no supported credentials: %v", namespace, credentialRef.Name, err)),
+		}
+		return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixClusterKind).GroupKind(), nutanixCluster.Name, allErrs)
+	}
+	return nil
+}
+
+// validateFailureDomains verifies the failure domains of the NutanixCluster against Prism Central unless
+// the validation is skipped with an annotation
+func (v *NutanixClusterValidator) validateFailureDomains(ctx context.Context, nutanixCluster *infrav1.NutanixCluster) error {
+	log := ctrl.LoggerFrom(ctx)
+	if len(nutanixCluster.Spec.FailureDomains) == 0 {
+		return nil
+	}
+	if _, ok := nutanixCluster.GetAnnotations()[infrav1.SkipFailureDomainValidationAnnotation]; ok {
+		log.Info(fmt.Sprintf("skipping validation of failure domains of NutanixCluster %s because annotation %s is set", nutanixCluster.Name, infrav1.SkipFailureDomainValidationAnnotation))
+		return nil
+	}
+
+	allErrs, err := ValidateFailureDomainReferences(ctx, func() (*nutanixClientV3.Client, error) {
+		c, err := v.getNutanixClient(ctx, nutanixCluster)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client to validate failure domains against Prism Central. Set annotation %s to skip the validation: %v", infrav1.SkipFailureDomainValidationAnnotation, err)
+		}
+		return c, nil
+	}, nutanixCluster)
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixClusterKind).GroupKind(), nutanixCluster.Name, allErrs)
+}
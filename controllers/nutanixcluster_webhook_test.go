@@ -0,0 +1,562 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
+)
+
+const (
+	testPEUUID     = "00000000-0000-0000-0000-000000000001"
+	testSubnetUUID = "00000000-0000-0000-0000-000000000002"
+)
+
+// fakeLookupService returns a single Prism Element cluster named pe and a single subnet named subnet
+type fakeLookupService struct {
+	nutanixClientV3.Service
+}
+
+func (f *fakeLookupService) ListCluster(_ context.Context, getEntitiesRequest *nutanixClientV3.DSMetadata) (*nutanixClientV3.ClusterListIntentResponse, error) {
+	entities := make([]*nutanixClientV3.ClusterIntentResponse, 0)
+	if utils.StringValue(getEntitiesRequest.Filter) == getFilterForName("pe") {
+		entities = append(entities, &nutanixClientV3.ClusterIntentResponse{
+			Metadata: &nutanixClientV3.Metadata{UUID: utils.StringPtr(testPEUUID)},
+			Spec:     &nutanixClientV3.Cluster{Name: utils.StringPtr("pe")},
+			Status: &nutanixClientV3.ClusterDefStatus{
+				Resources: &nutanixClientV3.ClusterObj{
+					Config: &nutanixClientV3.ClusterConfig{
+						ServiceList: []*string{utils.StringPtr(serviceNamePECluster)},
+					},
+				},
+			},
+		})
+	}
+	return &nutanixClientV3.ClusterListIntentResponse{Entities: entities}, nil
+}
+
+func (f *fakeLookupService) ListSubnet(_ context.Context, getEntitiesRequest *nutanixClientV3.DSMetadata) (*nutanixClientV3.SubnetListIntentResponse, error) {
+	entities := make([]*nutanixClientV3.SubnetIntentResponse, 0)
+	// Subnets are listed without filter to select them by category
+	if filter := utils.StringValue(getEntitiesRequest.Filter); filter == getFilterForName("subnet") || filter == "" {
+		entities = append(entities, &nutanixClientV3.SubnetIntentResponse{
+			Metadata: &nutanixClientV3.Metadata{
+				UUID:       utils.StringPtr(testSubnetUUID),
+				Categories: map[string]string{"network": "prod"},
+			},
+			Spec: &nutanixClientV3.Subnet{
+				Name: utils.StringPtr("subnet"),
+				Resources: &nutanixClientV3.SubnetResources{
+					SubnetType: utils.StringPtr("VLAN"),
+				},
+				ClusterReference: &nutanixClientV3.Reference{UUID: utils.StringPtr(testPEUUID)},
+			},
+		})
+	}
+	return &nutanixClientV3.SubnetListIntentResponse{Entities: entities}, nil
+}
+
+func TestNutanixClusterValidatorValidateCreate(t *testing.T) {
+	nameIdentifier := func(name string) infrav1.NutanixResourceIdentifier {
+		return infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr(name)}
+	}
+	uuidIdentifier := func(uuid string) infrav1.NutanixResourceIdentifier {
+		return infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr(uuid)}
+	}
+
+	tests := []struct {
+		name          string
+		cluster       infrav1.NutanixResourceIdentifier
+		subnet        infrav1.NutanixResourceIdentifier
+		annotations   map[string]string
+		clientErr     error
+		expectInvalid bool
+		expectErr     bool
+	}{
+		{
+			name:    "existing names",
+			cluster: nameIdentifier("pe"),
+			subnet:  nameIdentifier("subnet"),
+		},
+		{
+			name:    "valid uuids",
+			cluster: uuidIdentifier(testPEUUID),
+			subnet:  uuidIdentifier(testSubnetUUID),
+		},
+		{
+			name:    "valid uuids without client",
+			cluster: uuidIdentifier(testPEUUID),
+			subnet:  uuidIdentifier(testSubnetUUID),
+			// No lookups are required for UUIDs
+			clientErr: fmt.Errorf("prism central not reachable"),
+		},
+		{
+			name:          "unknown cluster name",
+			cluster:       nameIdentifier("missing"),
+			subnet:        nameIdentifier("subnet"),
+			expectInvalid: true,
+		},
+		{
+			name:          "unknown subnet name",
+			cluster:       nameIdentifier("pe"),
+			subnet:        nameIdentifier("missing"),
+			expectInvalid: true,
+		},
+		{
+			name:          "invalid cluster uuid",
+			cluster:       uuidIdentifier("not-a-uuid"),
+			subnet:        uuidIdentifier(testSubnetUUID),
+			expectInvalid: true,
+		},
+		{
+			name:          "invalid subnet uuid",
+			cluster:       uuidIdentifier(testPEUUID),
+			subnet:        uuidIdentifier("not-a-uuid"),
+			expectInvalid: true,
+		},
+		{
+			name:        "unknown names with skip annotation",
+			cluster:     nameIdentifier("missing"),
+			subnet:      nameIdentifier("missing"),
+			annotations: map[string]string{infrav1.SkipFailureDomainValidationAnnotation: ""},
+			clientErr:   fmt.Errorf("prism central not reachable"),
+		},
+		{
+			name:      "names without client",
+			cluster:   nameIdentifier("pe"),
+			subnet:    nameIdentifier("subnet"),
+			clientErr: fmt.Errorf("prism central not reachable"),
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			v := &NutanixClusterValidator{
+				getNutanixClient: func(_ context.Context, _ *infrav1.NutanixCluster) (*nutanixClientV3.Client, error) {
+					if tt.clientErr != nil {
+						return nil, tt.clientErr
+					}
+					return &nutanixClientV3.Client{V3: &fakeLookupService{}}, nil
+				},
+			}
+			ntnxCluster := &infrav1.NutanixCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "default",
+					Annotations: tt.annotations,
+				},
+				Spec: infrav1.NutanixClusterSpec{
+					FailureDomains: []infrav1.NutanixFailureDomainConfig{
+						{
+							Name:    "fd-1",
+							Cluster: tt.cluster,
+							Subnets: []infrav1.NutanixResourceIdentifier{tt.subnet},
+						},
+					},
+				},
+			}
+
+			err := v.ValidateCreate(context.Background(), ntnxCluster)
+			switch {
+			case tt.expectInvalid:
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			case tt.expectErr:
+				g.Expect(err).To(HaveOccurred())
+			default:
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestNutanixClusterValidatorValidateUpdate(t *testing.T) {
+	g := NewWithT(t)
+	v := &NutanixClusterValidator{
+		getNutanixClient: func(_ context.Context, _ *infrav1.NutanixCluster) (*nutanixClientV3.Client, error) {
+			return &nutanixClientV3.Client{V3: &fakeLookupService{}}, nil
+		},
+	}
+	oldCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			FailureDomains: []infrav1.NutanixFailureDomainConfig{
+				{
+					Name:    "fd-1",
+					Cluster: infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("missing")},
+					Subnets: []infrav1.NutanixResourceIdentifier{{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("subnet")}},
+				},
+			},
+		},
+	}
+
+	// Updates that do not change the failure domains are not validated against Prism Central
+	newCluster := oldCluster.DeepCopy()
+	newCluster.Labels = map[string]string{"foo": "bar"}
+	g.Expect(v.ValidateUpdate(context.Background(), oldCluster, newCluster)).To(Succeed())
+
+	newCluster.Spec.FailureDomains[0].Subnets[0].Name = utils.StringPtr("missing")
+	g.Expect(apierrors.IsInvalid(v.ValidateUpdate(context.Background(), oldCluster, newCluster))).To(BeTrue())
+
+	newCluster.Spec.FailureDomains[0].Cluster.Name = utils.StringPtr("pe")
+	newCluster.Spec.FailureDomains[0].Subnets[0].Name = utils.StringPtr("subnet")
+	g.Expect(v.ValidateUpdate(context.Background(), oldCluster, newCluster)).To(Succeed())
+}
+
+func TestNutanixClusterValidatorValidateCredentialRef(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name          string
+		credentialRef *credentialTypes.NutanixCredentialReference
+		expectInvalid bool
+	}{
+		{
+			name:          "secret",
+			credentialRef: &credentialTypes.NutanixCredentialReference{Kind: credentialTypes.SecretKind, Name: "creds"},
+		},
+		{
+			name:          "file",
+			credentialRef: &credentialTypes.NutanixCredentialReference{Kind: nutanixClient.FileCredentialKind, Name: filepath.Join(dir, "credentials")},
+		},
+		{
+			name:          "file without path",
+			credentialRef: &credentialTypes.NutanixCredentialReference{Kind: nutanixClient.FileCredentialKind, Name: " "},
+			expectInvalid: true,
+		},
+		{
+			name:          "file outside of the credential file directory",
+			credentialRef: &credentialTypes.NutanixCredentialReference{Kind: nutanixClient.FileCredentialKind, Name: "/var/run/secrets/kubernetes.io/serviceaccount/token"},
+			expectInvalid: true,
+		},
+		{
+			name:          "file escaping the credential file directory",
+			credentialRef: &credentialTypes.NutanixCredentialReference{Kind: nutanixClient.FileCredentialKind, Name: filepath.Join(dir, "..", "token")},
+			expectInvalid: true,
+		},
+		{
+			name:          "file with relative path",
+			credentialRef: &credentialTypes.NutanixCredentialReference{Kind: nutanixClient.FileCredentialKind, Name: "credentials"},
+			expectInvalid: true,
+		},
+	}
+	nutanixClient.CredentialFiles.SetDirectory(dir)
+	t.Cleanup(func() { nutanixClient.CredentialFiles.SetDirectory("") })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			v := &NutanixClusterValidator{}
+			ntnxCluster := &infrav1.NutanixCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: infrav1.NutanixClusterSpec{
+					PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+						Address:       "prism.example.com",
+						Port:          9440,
+						CredentialRef: tt.credentialRef,
+					},
+				},
+			}
+
+			err := v.ValidateCreate(context.Background(), ntnxCluster)
+			if tt.expectInvalid {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			g.Expect(apierrors.IsInvalid(v.ValidateUpdate(context.Background(), ntnxCluster, ntnxCluster))).To(Equal(tt.expectInvalid))
+		})
+	}
+}
+
+func TestNutanixClusterValidatorValidateCredentials(t *testing.T) {
+	tests := []struct {
+		name          string
+		secret        *corev1.Secret
+		expectInvalid bool
+	}{
+		{
+			name: "basic auth",
+			secret: credentialSecret(map[string][]byte{
+				credentialTypes.KeyName: []byte(`[{"type":"basic_auth","data":{"prismCentral":{"username":"user","password":"password"}}}]`),
+			}),
+		},
+		{
+			name: "bearer token",
+			secret: credentialSecret(map[string][]byte{
+				credentialTypes.KeyName: []byte(`[{"type":"bearer_token","data":{"prismCentral":{"token":"abc"}}}]`),
+			}),
+		},
+		{
+			name: "unsupported type",
+			secret: credentialSecret(map[string][]byte{
+				credentialTypes.KeyName: []byte(`[{"type":"kerberos","data":{}}]`),
+			}),
+			expectInvalid: true,
+		},
+		{
+			name:          "missing key",
+			secret:        credentialSecret(map[string][]byte{"other": []byte("x")}),
+			expectInvalid: true,
+		},
+		{
+			name: "secret not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			secretInformer := informers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), 0).Core().V1().Secrets()
+			if tt.secret != nil {
+				g.Expect(secretInformer.Informer().GetIndexer().Add(tt.secret)).To(Succeed())
+			}
+			v := &NutanixClusterValidator{SecretInformer: secretInformer}
+			ntnxCluster := &infrav1.NutanixCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: infrav1.NutanixClusterSpec{
+					PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+						Address: "prism.example.com",
+						Port:    9440,
+						CredentialRef: &credentialTypes.NutanixCredentialReference{
+							Kind: credentialTypes.SecretKind,
+							Name: "creds",
+						},
+					},
+				},
+			}
+
+			err := v.ValidateCreate(context.Background(), ntnxCluster)
+			if tt.expectInvalid {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			// The credentials are only validated on update if the Prism Central changes
+			g.Expect(v.ValidateUpdate(context.Background(), ntnxCluster, ntnxCluster)).To(Succeed())
+		})
+	}
+}
+
+func credentialSecret(data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "creds",
+			Namespace: "default",
+		},
+		Data: data,
+	}
+}
+
+func TestNutanixClusterValidatorValidateClientCertificate(t *testing.T) {
+	secretKey := func(key string) *corev1.SecretKeySelector {
+		return &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "client-cert"},
+			Key:                  key,
+		}
+	}
+
+	tests := []struct {
+		name              string
+		clientCertificate *infrav1.NutanixClientCertificate
+		expectInvalid     bool
+	}{
+		{
+			name: "no client certificate",
+		},
+		{
+			name: "certificate and key",
+			clientCertificate: &infrav1.NutanixClientCertificate{
+				CertificateRef: secretKey("tls.crt"),
+				KeyRef:         secretKey("tls.key"),
+			},
+		},
+		{
+			name: "certificate without key",
+			clientCertificate: &infrav1.NutanixClientCertificate{
+				CertificateRef: secretKey("tls.crt"),
+			},
+			expectInvalid: true,
+		},
+		{
+			name: "key without certificate",
+			clientCertificate: &infrav1.NutanixClientCertificate{
+				KeyRef: secretKey("tls.key"),
+			},
+			expectInvalid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			v := &NutanixClusterValidator{}
+			ntnxCluster := &infrav1.NutanixCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: infrav1.NutanixClusterSpec{
+					ClientCertificate: tt.clientCertificate,
+				},
+			}
+
+			err := v.ValidateCreate(context.Background(), ntnxCluster)
+			if tt.expectInvalid {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestNutanixClusterValidatorValidateInsecureSkipVerify(t *testing.T) {
+	tests := []struct {
+		name               string
+		insecureSkipVerify *bool
+		annotations        map[string]string
+		expectInvalid      bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:               "disabled",
+			insecureSkipVerify: utils.BoolPtr(false),
+		},
+		{
+			name:               "enabled without annotation",
+			insecureSkipVerify: utils.BoolPtr(true),
+			expectInvalid:      true,
+		},
+		{
+			name:               "enabled with annotation",
+			insecureSkipVerify: utils.BoolPtr(true),
+			annotations:        map[string]string{infrav1.AllowInsecureSkipVerifyAnnotation: ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			v := &NutanixClusterValidator{}
+			ntnxCluster := &infrav1.NutanixCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "default",
+					Annotations: tt.annotations,
+				},
+				Spec: infrav1.NutanixClusterSpec{
+					InsecureSkipVerify: tt.insecureSkipVerify,
+				},
+			}
+
+			err := v.ValidateCreate(context.Background(), ntnxCluster)
+			if tt.expectInvalid {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestNutanixClusterValidatorValidateDelete(t *testing.T) {
+	nutanixMachine := func(name, clusterName string) *infrav1.NutanixMachine {
+		return &infrav1.NutanixMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{capiv1.ClusterLabelName: clusterName},
+			},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		machines         []client.Object
+		annotations      map[string]string
+		blockingMachines string
+	}{
+		{
+			name: "no NutanixMachines",
+		},
+		{
+			name:     "NutanixMachines of other clusters",
+			machines: []client.Object{nutanixMachine("machine-1", "other")},
+		},
+		{
+			name:             "NutanixMachines of the cluster",
+			machines:         []client.Object{nutanixMachine("machine-2", "test"), nutanixMachine("machine-1", "test"), nutanixMachine("machine-3", "other")},
+			blockingMachines: "machine-1, machine-2",
+		},
+		{
+			name:        "NutanixMachines of the cluster with force delete annotation",
+			machines:    []client.Object{nutanixMachine("machine-1", "test")},
+			annotations: map[string]string{infrav1.ForceDeleteAnnotation: ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			v := NewNutanixClusterValidator(fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.machines...).Build(), nil, nil)
+			ntnxCluster := &infrav1.NutanixCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "default",
+					Annotations: tt.annotations,
+				},
+			}
+
+			err := v.ValidateDelete(context.Background(), ntnxCluster)
+			if tt.blockingMachines == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			g.Expect(err.Error()).To(ContainSubstring(tt.blockingMachines))
+			g.Expect(err.Error()).NotTo(ContainSubstring("machine-3"))
+			g.Expect(err.Error()).To(ContainSubstring(infrav1.ForceDeleteAnnotation))
+		})
+	}
+}
@@ -18,7 +18,6 @@ package controllers
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
 	"strings"
 	"time"
@@ -28,10 +27,15 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	apitypes "k8s.io/apimachinery/pkg/types"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
 	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
 	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	capiutil "sigs.k8s.io/cluster-api/util"
@@ -56,6 +60,69 @@ import (
 
 const (
 	projectKind = "project"
+	userKind    = "user"
+
+	// imageNotReadyEventReason is the reason of the events recorded while waiting for the image of a VM to become ready
+	imageNotReadyEventReason = "WaitingForImage"
+	// imageReadyRequeueInterval is the interval in which the state of an image that is not ready is checked again
+	imageReadyRequeueInterval = 30 * time.Second
+
+	// secureBootType is the boot type of VMs with UEFI secure boot
+	secureBootType = "SECURE_BOOT"
+	// secureBootMachineType is the machine type of VMs with UEFI secure boot
+	secureBootMachineType = "Q35"
+
+	// vmOwnershipRestoredEventReason is the reason of the events recorded when the ownership categories of a VM that
+	// were removed or changed in Prism Central were restored
+	vmOwnershipRestoredEventReason = "VMOwnershipRestored"
+
+	// vmRenamedEventReason is the reason of the events recorded when a VM was renamed to the expected VM name
+	vmRenamedEventReason = "VMRenamed"
+	// vmRenameBlockedEventReason is the reason of the events recorded when a VM could not be renamed since another VM
+	// already has the expected VM name
+	vmRenameBlockedEventReason = "VMRenameBlocked"
+
+	// hostnameUpdatedEventReason is the reason of the events recorded when the hostname of a VM that was not powered on
+	// yet was updated in its guest customization
+	hostnameUpdatedEventReason = "HostnameUpdated"
+	// hostnameChangePendingEventReason is the reason of the events recorded when the hostname of a VM that was already
+	// powered on differs from the expected hostname
+	hostnameChangePendingEventReason = "HostnameChangePending"
+
+	// extraVMConfigIgnoredEventReason is the reason of the events recorded when a VM is created without the
+	// extraVMConfig of the NutanixMachine since it is disabled in the controller
+	extraVMConfigIgnoredEventReason = "ExtraVMConfigIgnored"
+
+	// vmAdoptedEventReason is the reason of the events recorded when an existing VM was adopted as the VM of a Machine
+	vmAdoptedEventReason = "VMAdopted"
+
+	// systemDiskResizedEventReason is the reason of the events recorded when the system disk of a VM was grown
+	systemDiskResizedEventReason = "SystemDiskResized"
+
+	// bootstrapISOEjectedEventReason is the reason of the events recorded when the bootstrap ISO was ejected from the
+	// CD-ROM of a VM
+	bootstrapISOEjectedEventReason = "BootstrapISOEjected"
+
+	// failureDomainMissingEventReason is the reason of the events recorded when the failure domain of a VM was removed
+	// from the NutanixCluster
+	failureDomainMissingEventReason = "FailureDomainMissing"
+
+	// vmDeletionBlockedEventReason is the reason of the events recorded when the deletion of a VM carrying the
+	// protection category was refused
+	vmDeletionBlockedEventReason = "VMDeletionBlocked"
+	// vmRecreationRequestedEventReason is the reason of the events recorded when the VM of a NutanixMachine was deleted
+	// on request of the recreate-vm annotation
+	vmRecreationRequestedEventReason = "VMRecreationRequested"
+	// vmRecreatedEventReason is the reason of the events recorded when the deletion of a VM requested by the recreate-vm
+	// annotation completed and a new VM will be created
+	vmRecreatedEventReason = "VMRecreated"
+	// vmRecreationRefusedEventReason is the reason of the events recorded when the recreate-vm annotation was removed
+	// without deleting the VM
+	vmRecreationRefusedEventReason = "VMRecreationRefused"
+
+	// vmDeletionBlockedRequeueInterval is the interval in which a protected VM is checked until the protection
+	// category was removed from it
+	vmDeletionBlockedRequeueInterval = time.Minute
 )
 
 var (
@@ -65,6 +132,9 @@ var (
 	minVCPUSockets           = 1
 )
 
+// errImageNotReady is returned when the creation of a VM is postponed since its image is not ready yet
+var errImageNotReady = errors.New("image not ready")
+
 func init() {
 	minMachineSystemDiskSize = resource.MustParse("20Gi")
 	minMachineMemorySize = resource.MustParse("2Gi")
@@ -76,7 +146,20 @@ type NutanixMachineReconciler struct {
 	SecretInformer    coreinformers.SecretInformer
 	ConfigMapInformer coreinformers.ConfigMapInformer
 	Scheme            *runtime.Scheme
+	Recorder          record.EventRecorder
 	controllerConfig  *ControllerConfig
+
+	// taskPollInterval is the interval in which the state of the creation task of a VM is polled.
+	// The default interval of nutanixClient.WaitOptions is used if not set.
+	taskPollInterval time.Duration
+	// seedDiskPool holds the seed disks the system disks of new VMs are cloned from. Nil if disabled.
+	seedDiskPool *SeedDiskPool
+	// vmCreations prevents concurrent reconciliations of a NutanixMachine from creating more than one VM
+	vmCreations vmCreationGuard
+	// leadership tracks whether the controller holds the leader election lease. Nil if not set up with a manager.
+	leadership *leadership
+	// clock measures the shutdown grace period of VMs and the time waited for images to become ready
+	clock clock.Clock
 }
 
 func NewNutanixMachineReconciler(client client.Client, secretInformer coreinformers.SecretInformer, configMapInformer coreinformers.ConfigMapInformer, scheme *runtime.Scheme, copts ...ControllerConfigOpts) (*NutanixMachineReconciler, error) {
@@ -87,17 +170,36 @@ func NewNutanixMachineReconciler(client client.Client, secretInformer coreinform
 		}
 	}
 
-	return &NutanixMachineReconciler{
+	r := &NutanixMachineReconciler{
 		Client:            client,
 		SecretInformer:    secretInformer,
 		ConfigMapInformer: configMapInformer,
 		Scheme:            scheme,
 		controllerConfig:  controllerConf,
-	}, nil
+		clock:             clock.RealClock{},
+	}
+	if controllerConf.SeedDiskPoolSize > 0 {
+		seedDiskPool, err := NewSeedDiskPool(controllerConf.SeedDiskPoolSize)
+		if err != nil {
+			return nil, err
+		}
+		r.seedDiskPool = seedDiskPool
+	}
+	return r, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *NutanixMachineReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, copts ...ControllerConfigOpts) error {
+	r.Recorder = mgr.GetEventRecorderFor("nutanixmachine-controller")
+	r.leadership = newLeadership()
+	if err := mgr.Add(r.leadership); err != nil {
+		return fmt.Errorf("failed to add leadership: %v", err)
+	}
+	if r.seedDiskPool != nil {
+		if err := mgr.Add(r.seedDiskPool); err != nil {
+			return fmt.Errorf("failed to add seed disk pool: %v", err)
+		}
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&infrav1.NutanixMachine{}).
 		// Watch the CAPI resource that owns this infrastructure resource.
@@ -111,10 +213,15 @@ func (r *NutanixMachineReconciler) SetupWithManager(ctx context.Context, mgr ctr
 			&source.Kind{Type: &infrav1.NutanixCluster{}},
 			handler.EnqueueRequestsFromMapFunc(r.mapNutanixClusterToNutanixMachines(ctx)),
 		).
-		WithOptions(controller.Options{MaxConcurrentReconciles: r.controllerConfig.MaxConcurrentReconciles}).
+		WithOptions(r.controllerOptions()).
 		Complete(r)
 }
 
+// controllerOptions returns the options of the NutanixMachine controller
+func (r *NutanixMachineReconciler) controllerOptions() controller.Options {
+	return controller.Options{MaxConcurrentReconciles: r.controllerConfig.MaxConcurrentReconciles}
+}
+
 func (r *NutanixMachineReconciler) mapNutanixClusterToNutanixMachines(ctx context.Context) handler.MapFunc {
 	return func(o client.Object) []ctrl.Request {
 		log := ctrl.LoggerFrom(ctx)
@@ -156,7 +263,9 @@ func (r *NutanixMachineReconciler) mapNutanixClusterToNutanixMachines(ctx contex
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update;delete
 //+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;patch
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;update;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinesets,verbs=get;list;watch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=nutanixmachines,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=nutanixmachines/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=nutanixmachines/finalizers,verbs=update
@@ -175,7 +284,17 @@ func (r *NutanixMachineReconciler) mapNutanixClusterToNutanixMachines(ctx contex
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.8.3/pkg/reconcile
 func (r *NutanixMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, reterr error) {
 	log := log.FromContext(ctx)
+	if !r.leadership.isLeader() {
+		log.Info("Skipping the reconciliation of the NutanixMachine since the controller is not the leader")
+		return reconcile.Result{}, nil
+	}
 	log.Info("Reconciling the NutanixMachine.")
+	defer func() {
+		res = jitterRequeue(res, r.controllerConfig.requeueJitterFactor())
+	}()
+	// Tasks are not polled and VMs are not mutated anymore once the leadership is lost
+	ctx, cancel := r.leadership.withLeadership(ctx)
+	defer cancel()
 
 	// Get the NutanixMachine resource for this request.
 	ntxMachine := &infrav1.NutanixMachine{}
@@ -201,7 +320,9 @@ func (r *NutanixMachineReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		log.Info("Waiting for capi Machine Controller to set OwnerRef on NutanixMachine")
 		return reconcile.Result{}, nil
 	}
-	log.Info(fmt.Sprintf("Fetched the owner Machine: %s", machine.Name))
+	ctx = nutanixClient.WithLogValues(ctx, nutanixClient.LogKeyMachine, machine.Name)
+	log = ctrl.LoggerFrom(ctx)
+	log.V(1).Info("Fetched the owner Machine")
 
 	// Fetch the CAPI Cluster.
 	cluster, err := capiutil.GetClusterFromMetadata(ctx, r.Client, machine.ObjectMeta)
@@ -209,8 +330,18 @@ func (r *NutanixMachineReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		log.Error(err, "Machine is missing cluster label or cluster does not exist")
 		return reconcile.Result{}, nil
 	}
-	if annotations.IsPaused(cluster, machine) {
-		log.V(1).Info("linked to a cluster that is paused")
+	ctx = nutanixClient.WithLogValues(ctx, nutanixClient.LogKeyCluster, cluster.Name)
+	if ntxMachine.Status.VmUUID != "" {
+		ctx = nutanixClient.WithLogValues(ctx, nutanixClient.LogKeyVMUUID, ntxMachine.Status.VmUUID)
+	}
+	log = ctrl.LoggerFrom(ctx)
+	if isReconciliationPaused(cluster, machine, ntxMachine) {
+		log.V(1).Info("paused or linked to a cluster that is paused")
+		// Finalizers are kept while the reconciliation is paused
+		if err := markReconciliationPaused(ctx, r.Client, ntxMachine); err != nil {
+			log.Error(err, "failed to set the Paused condition of the NutanixMachine")
+			return reconcile.Result{}, err
+		}
 		return reconcile.Result{}, nil
 	}
 
@@ -230,17 +361,23 @@ func (r *NutanixMachineReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	patchHelper, err := patch.NewHelper(ntxMachine, r.Client)
 	if err != nil {
 		log.Error(err, "failed to configure the patch helper")
-		return ctrl.Result{Requeue: true}, nil
+		return requeueFor(requeueForTransientError, 0), nil
 	}
+	conditions.Delete(ntxMachine, infrav1.PausedCondition)
 
-	v3Client, err := CreateNutanixClient(ctx, r.SecretInformer, r.ConfigMapInformer, ntxCluster)
+	// Prism Central requests are canceled once the reconcile timeout expired. The NutanixMachine is still patched
+	// with the context of the reconcile request.
+	reconcileCtx, cancel := withReconcileTimeout(ctx, r.controllerConfig.reconcileTimeout())
+	defer cancel()
+
+	v3Client, err := CreateNutanixClient(reconcileCtx, r.SecretInformer, r.ConfigMapInformer, ntxCluster)
 	if err != nil {
 		conditions.MarkFalse(ntxMachine, infrav1.PrismCentralClientCondition, infrav1.PrismCentralClientInitializationFailed, capiv1.ConditionSeverityError, err.Error())
-		return ctrl.Result{Requeue: true}, fmt.Errorf("client auth error: %v", err)
+		return ctrl.Result{Requeue: true}, checkReconcileTimeout(reconcileCtx, r.controllerConfig.reconcileTimeout(), fmt.Errorf("client auth error: %v", err))
 	}
 	conditions.MarkTrue(ntxMachine, infrav1.PrismCentralClientCondition)
 	rctx := &nctx.MachineContext{
-		Context:        ctx,
+		Context:        reconcileCtx,
 		Cluster:        cluster,
 		Machine:        machine,
 		NutanixCluster: ntxCluster,
@@ -262,20 +399,28 @@ func (r *NutanixMachineReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		}
 	}()
 
-	// Handle deleted machines
+	var result reconcile.Result
+	var reconcileErr error
 	if !ntxMachine.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(rctx)
+		// Handle deleted machines
+		result, reconcileErr = r.reconcileDelete(rctx)
+	} else {
+		// Handle non-deleted machines
+		result, reconcileErr = r.reconcileNormal(rctx)
 	}
-
-	// Handle non-deleted machines
-	return r.reconcileNormal(rctx)
+	return result, checkReconcileTimeout(reconcileCtx, r.controllerConfig.reconcileTimeout(), reconcileErr)
 }
 
 func (r *NutanixMachineReconciler) reconcileDelete(rctx *nctx.MachineContext) (reconcile.Result, error) {
 	ctx := rctx.Context
 	log := ctrl.LoggerFrom(ctx)
 	nc := rctx.NutanixClient
-	vmName := rctx.Machine.Name
+	vmName, err := getVMName(rctx)
+	if err != nil {
+		errorMsg := fmt.Errorf("failed to get the VM name during delete: %v", err)
+		log.Error(errorMsg, "failed to delete VM")
+		return reconcile.Result{}, errorMsg
+	}
 	log.Info(fmt.Sprintf("Handling deletion of VM: %s", vmName))
 	conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, capiv1.DeletingReason, capiv1.ConditionSeverityInfo, "")
 	vmUUID, err := GetVMUUID(rctx.NutanixMachine)
@@ -305,11 +450,17 @@ func (r *NutanixMachineReconciler) reconcileDelete(rctx *nctx.MachineContext) (r
 			// Earlier, we were creating VMs with the same name as the NutanixMachine name.
 			// Now, we create VMs with the same name as the Machine name in line with other CAPI providers.
 			// This check is to ensure that we are deleting the correct VM for both cases as older CAPX VMs
-			// will have the NutanixMachine name as the VM name.
-			if *vm.Spec.Name != vmName && *vm.Spec.Name != rctx.NutanixMachine.Name {
-				return reconcile.Result{}, fmt.Errorf("found VM with UUID %s but name %s did not match Machine name %s or NutanixMachineName %s", vmUUID, *vm.Spec.Name, vmName, rctx.NutanixMachine.Name)
+			// will have the NutanixMachine name as the VM name. Adopted VMs keep their name.
+			if vmUUID != rctx.NutanixMachine.Spec.ExistingVMUUID && *vm.Spec.Name != vmName && *vm.Spec.Name != rctx.NutanixMachine.Name {
+				return reconcile.Result{}, fmt.Errorf("found VM with UUID %s but name %s did not match VM name %s or NutanixMachineName %s", vmUUID, *vm.Spec.Name, vmName, rctx.NutanixMachine.Name)
 			}
 			log.V(1).Info(fmt.Sprintf("VM %s with UUID %s was found.", *vm.Spec.Name, vmUUID))
+			if r.isVMDeletionBlocked(rctx, vm) {
+				return reconcile.Result{RequeueAfter: vmDeletionBlockedRequeueInterval}, nil
+			}
+			if !r.reconcileNodeDrain(rctx) {
+				return requeueFor(requeueForExternalCondition, conditionAge(rctx.NutanixMachine, infrav1.NodeDrainedCondition)), nil
+			}
 			lastTaskUUID, err := GetTaskUUIDFromVM(vm)
 			if err != nil {
 				errorMsg := fmt.Errorf("error occurred fetching task UUID from vm: %v", err)
@@ -321,16 +472,34 @@ func (r *NutanixMachineReconciler) reconcileDelete(rctx *nctx.MachineContext) (r
 				log.Info(fmt.Sprintf("checking if VM %s with UUID %s has in progress tasks", vmName, vmUUID))
 				taskInProgress, err := HasTaskInProgress(ctx, rctx.NutanixClient, lastTaskUUID)
 				if err != nil {
-					log.Error(err, fmt.Sprintf("error occurred while checking task %s for VM %s. Trying to delete VM", lastTaskUUID, vmName))
+					log.Error(err, fmt.Sprintf("error occurred while checking task of VM %s. Trying to delete VM", vmName), nutanixClient.LogKeyTaskUUID, lastTaskUUID)
 				}
 				if taskInProgress {
-					log.Info(fmt.Sprintf("VM %s task with UUID %s still in progress. Requeuing", vmName, vmUUID))
-					return reconcile.Result{RequeueAfter: 5 * time.Second}, nil
+					log.Info(fmt.Sprintf("VM %s task still in progress. Requeuing", vmName), nutanixClient.LogKeyTaskUUID, lastTaskUUID)
+					return requeueFor(requeueForTransientError, 0), nil
 				}
 				log.V(1).Info(fmt.Sprintf("No running tasks anymore... Initiating delete for vm %s with UUID %s", vmName, vmUUID))
 			} else {
 				log.V(1).Info(fmt.Sprintf("no task UUID found on VM %s. Starting delete.", *vm.Spec.Name))
 			}
+			if r.controllerConfig != nil && r.controllerConfig.VMShutdownGracePeriod > 0 {
+				shutDown, err := r.reconcileVMShutdown(rctx, vm)
+				if err != nil {
+					errorMsg := fmt.Errorf("failed to shut down VM %s with UUID %s: %v", vmName, vmUUID, err)
+					conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.DeletionFailed, capiv1.ConditionSeverityWarning, errorMsg.Error())
+					log.Error(errorMsg, "failed to shut down VM")
+					return reconcile.Result{}, err
+				}
+				if !shutDown {
+					return requeueFor(requeueForTransientError, 0), nil
+				}
+			}
+			if err := r.detachVolumeGroups(rctx, vmUUID); err != nil {
+				errorMsg := fmt.Errorf("failed to detach volume groups from VM %s with UUID %s: %v", vmName, vmUUID, err)
+				conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.DeletionFailed, capiv1.ConditionSeverityWarning, errorMsg.Error())
+				log.Error(errorMsg, "failed to detach volume groups")
+				return reconcile.Result{}, err
+			}
 			// Delete the VM since the VM was found (err was nil)
 			deleteTaskUUID, err := DeleteVM(ctx, nc, vmName, vmUUID)
 			if err != nil {
@@ -339,14 +508,15 @@ func (r *NutanixMachineReconciler) reconcileDelete(rctx *nctx.MachineContext) (r
 				log.Error(errorMsg, "failed to delete VM")
 				return reconcile.Result{}, err
 			}
-			log.Info(fmt.Sprintf("Deletion task with UUID %s received for vm %s with UUID %s. Requeueing", deleteTaskUUID, vmName, vmUUID))
-			return reconcile.Result{RequeueAfter: 5 * time.Second}, nil
+			log.Info(fmt.Sprintf("Deletion task received for VM %s. Requeueing", vmName), nutanixClient.LogKeyTaskUUID, deleteTaskUUID)
+			return requeueFor(requeueForTransientError, 0), nil
 		}
 	}
 
 	// Remove the finalizer from the NutanixMachine object
 	log.Info(fmt.Sprintf("Removing finalizers for VM %s during delete reconciliation", vmName))
 	ctrlutil.RemoveFinalizer(rctx.NutanixMachine, infrav1.NutanixMachineFinalizer)
+	r.vmCreations.forget(rctx.NutanixMachine.UID)
 
 	return reconcile.Result{}, nil
 }
@@ -355,7 +525,7 @@ func (r *NutanixMachineReconciler) reconcileNormal(rctx *nctx.MachineContext) (r
 	log := ctrl.LoggerFrom(rctx.Context)
 	if rctx.NutanixMachine.Status.FailureReason != nil || rctx.NutanixMachine.Status.FailureMessage != nil {
 		log.Error(fmt.Errorf("nutanix machine has failed. Will not reconcile"), "nutanix machine failed")
-		return reconcile.Result{}, nil
+		return requeueFor(requeueForTerminalError, 0), nil
 	}
 	log.Info("Handling NutanixMachine reconciling")
 	var err error
@@ -365,19 +535,82 @@ func (r *NutanixMachineReconciler) reconcileNormal(rctx *nctx.MachineContext) (r
 		ctrlutil.AddFinalizer(rctx.NutanixMachine, infrav1.NutanixMachineFinalizer)
 	}
 
+	if pending, err := r.reconcileVMRecreation(rctx); err != nil || pending {
+		if err != nil {
+			log.Error(err, "failed to recreate the VM")
+		}
+		return requeueFor(requeueForTransientError, 0), err
+	}
+
+	// The defaults are only merged before the VM is created to not change the spec of existing Machines
+	if rctx.NutanixMachine.Status.VmUUID == "" {
+		mergeMachineDefaults(rctx)
+	}
+
 	log.V(1).Info(fmt.Sprintf("Checking current machine status for machine %s: Status %+v Spec %+v", rctx.NutanixMachine.Name, rctx.NutanixMachine.Status, rctx.NutanixMachine.Spec))
 	if rctx.NutanixMachine.Status.Ready {
 		if !rctx.Machine.Status.InfrastructureReady || rctx.Machine.Spec.ProviderID == nil {
 			log.Info("The NutanixMachine is ready, wait for the owner Machine's update.")
-			return reconcile.Result{RequeueAfter: 5 * time.Second}, nil
+			return requeueFor(requeueForTransientError, 0), nil
 		}
 		log.Info(fmt.Sprintf("The NutanixMachine is ready, providerID: %s", rctx.NutanixMachine.Spec.ProviderID))
+		// The VM is fetched once and shared by the reconcilers below, which refresh it after updating it
+		vmUUID := rctx.NutanixMachine.Status.VmUUID
+		vm, err := FindVMByUUID(rctx.Context, rctx.NutanixClient, vmUUID)
+		if err != nil || vm == nil || vm.Metadata == nil || vm.Spec == nil {
+			log.V(1).Info(fmt.Sprintf("skipping reconciliation of VM with UUID %s that could not be retrieved: %v", vmUUID, err))
+			vm = nil
+		}
+		r.reconcileVMDescription(rctx, vm)
+		r.reconcileFailureDomainMissing(rctx)
+		if pending, err := r.reconcileVMName(rctx, vm); err != nil || pending {
+			if err != nil {
+				log.Error(err, "failed to reconcile the name of the VM")
+			}
+			return requeueFor(requeueForTransientError, 0), err
+		}
+		if pending, err := r.reconcileHostname(rctx, vm); err != nil || pending {
+			if err != nil {
+				log.Error(err, "failed to reconcile the hostname of the VM")
+			}
+			return requeueFor(requeueForTransientError, 0), err
+		}
+		if pending, err := r.reconcileVMCategories(rctx, vm); err != nil || pending {
+			if err != nil {
+				log.Error(err, "failed to reconcile the categories of the VM")
+			}
+			return requeueFor(requeueForTransientError, 0), err
+		}
+		if pending, err := r.reconcileSystemDiskSize(rctx, vm); err != nil || pending {
+			if err != nil {
+				log.Error(err, "failed to reconcile the system disk size of the VM")
+			}
+			return requeueFor(requeueForTransientError, 0), err
+		}
+		// Nutanix Guest Tools are polled without holding back the Node
+		result := reconcile.Result{}
+		if !r.reconcileGuestTools(rctx, vm) {
+			result = requeueFor(requeueForExternalCondition, conditionAge(rctx.NutanixMachine, infrav1.GuestToolsReadyCondition))
+		}
 
 		if rctx.NutanixMachine.Status.NodeRef == nil {
-			return r.reconcileNode(rctx)
+			nodeResult, err := r.reconcileNode(rctx)
+			if err != nil {
+				return nodeResult, err
+			}
+			rctx.NutanixMachine.Status.ObservedGeneration = rctx.NutanixMachine.Generation
+			return capiutil.LowestNonZeroResult(result, nodeResult), nil
 		}
 
-		return reconcile.Result{}, nil
+		if pending, err := r.reconcileBootstrapISOEject(rctx, vm); err != nil || pending {
+			if err != nil {
+				log.Error(err, "failed to eject the bootstrap ISO of the VM")
+			}
+			return requeueFor(requeueForTransientError, 0), err
+		}
+
+		rctx.NutanixMachine.Status.ObservedGeneration = rctx.NutanixMachine.Generation
+		return result, nil
 	}
 
 	// Make sure Cluster.Status.InfrastructureReady is true
@@ -391,6 +624,8 @@ func (r *NutanixMachineReconciler) reconcileNormal(rctx *nctx.MachineContext) (r
 	// Make sure bootstrap data is available and populated.
 	if rctx.NutanixMachine.Spec.BootstrapRef == nil {
 		if rctx.Machine.Spec.Bootstrap.DataSecretName == nil {
+			conditions.MarkFalse(rctx.NutanixMachine, infrav1.BootstrapDataReadyCondition, infrav1.WaitingForBootstrapData, capiv1.ConditionSeverityInfo,
+				"the bootstrap data secret of the Machine is not set yet")
 			if !nctx.IsControlPlaneMachine(rctx.NutanixMachine) &&
 				!conditions.IsTrue(rctx.Cluster, capiv1.ControlPlaneInitializedCondition) {
 				log.Info("Waiting for the control plane to be initialized")
@@ -410,15 +645,57 @@ func (r *NutanixMachineReconciler) reconcileNormal(rctx *nctx.MachineContext) (r
 		}
 		log.V(1).Info(fmt.Sprintf("Added the spec.bootstrapRef to NutanixMachine object: %v", rctx.NutanixMachine.Spec.BootstrapRef))
 	}
+	if ready, err := r.reconcileBootstrapData(rctx); err != nil || !ready {
+		if err != nil {
+			log.Error(err, "failed to check the bootstrap data")
+			return reconcile.Result{}, err
+		}
+		return requeueFor(requeueForExternalCondition, conditionAge(rctx.NutanixMachine, infrav1.BootstrapDataReadyCondition)), nil
+	}
+
+	// The IP addresses claimed from IPAM providers are needed to create the VM
+	if rctx.NutanixMachine.Status.VmUUID == "" {
+		allocated, err := r.reconcileIPAddressClaims(rctx)
+		if err != nil {
+			log.Error(err, "failed to reconcile IPAddressClaims")
+			return reconcile.Result{}, err
+		}
+		if !allocated {
+			conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.WaitingForIPAddresses, capiv1.ConditionSeverityInfo, "")
+			return r.ipAddressClaimRequeue(rctx), nil
+		}
+	}
+
+	// The creation of a VM rejected since a quota was exceeded is retried rarely
+	if rctx.NutanixMachine.Status.VmUUID == "" {
+		if remaining := quotaExceededBackoff(rctx); remaining > 0 {
+			log.Info(fmt.Sprintf("Retrying the creation of VM %s rejected since a Prism Central quota was exceeded in %s", rctx.Machine.Name, remaining.Round(time.Second)))
+			return reconcile.Result{RequeueAfter: remaining}, nil
+		}
+	}
 
 	// Create or get existing VM
 	vm, err := r.getOrCreateVM(rctx)
 	if err != nil {
+		if errors.Is(err, errImageNotReady) {
+			log.Info(fmt.Sprintf("Waiting for the image of VM %s to become ready: %v", rctx.Machine.Name, err))
+			return reconcile.Result{RequeueAfter: imageReadyRequeueInterval}, nil
+		}
+		if isQuotaExceededError(err) {
+			log.Info(fmt.Sprintf("Failed to create VM %s since a Prism Central quota was exceeded: %v", rctx.Machine.Name, err))
+			r.recordEvent(rctx.NutanixMachine, corev1.EventTypeWarning, quotaExceededEventReason,
+				fmt.Sprintf("VM %s could not be created since a Prism Central quota was exceeded: %v", rctx.Machine.Name, err))
+			return reconcile.Result{RequeueAfter: quotaExceededRequeueInterval}, nil
+		}
 		log.Error(err, fmt.Sprintf("Failed to create VM %s.", rctx.Machine.Name))
 		return reconcile.Result{}, err
 	}
 	log.V(1).Info(fmt.Sprintf("Found VM with name: %s, vmUUID: %s", rctx.Machine.Name, *vm.Metadata.UUID))
 	rctx.NutanixMachine.Status.VmUUID = *vm.Metadata.UUID
+	rctx.NutanixMachine.Status.PowerState = GetVMPowerState(vm)
+	if clusterUUID := GetVMClusterUUID(vm); clusterUUID != "" {
+		rctx.NutanixMachine.Status.ClusterUUID = clusterUUID
+	}
 
 	log.V(1).Info(fmt.Sprintf("Patching machine post creation vmUUID: %s", rctx.NutanixMachine.Status.VmUUID))
 	if err := r.patchMachine(rctx); err != nil {
@@ -427,6 +704,25 @@ func (r *NutanixMachineReconciler) reconcileNormal(rctx *nctx.MachineContext) (r
 		return reconcile.Result{}, errorMsg
 	}
 
+	if err := r.attachVolumeGroups(rctx); err != nil {
+		errorMsg := fmt.Errorf("failed to attach volume groups to VM %s with UUID %s: %v", rctx.Machine.Name, rctx.NutanixMachine.Status.VmUUID, err)
+		log.Error(errorMsg, "failed to attach volume groups")
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.VolumeGroupsAttachedCondition, infrav1.VolumeGroupsAttachFailed, capiv1.ConditionSeverityError, err.Error())
+		return reconcile.Result{}, errorMsg
+	}
+
+	if pending, err := r.reconcileHostname(rctx, vm); err != nil || pending {
+		if err != nil {
+			log.Error(err, "failed to reconcile the hostname of the VM")
+		}
+		return requeueFor(requeueForTransientError, 0), err
+	}
+
+	if !r.reconcileVMPowerOn(rctx, vm) {
+		log.Info(fmt.Sprintf("Waiting for VM %s with UUID %s to be powered on", rctx.Machine.Name, rctx.NutanixMachine.Status.VmUUID))
+		return requeueFor(requeueForExternalCondition, conditionAge(rctx.NutanixMachine, infrav1.VMPoweredOnCondition)), nil
+	}
+
 	log.Info(fmt.Sprintf("Assigning IP addresses to VM with name: %s, vmUUID: %s", rctx.NutanixMachine.Name, rctx.NutanixMachine.Status.VmUUID))
 	if err := r.assignAddressesToMachine(rctx, vm); err != nil {
 		errorMsg := fmt.Errorf("failed to assign addresses to VM %s with UUID %s...: %v", rctx.Machine.Name, rctx.NutanixMachine.Status.VmUUID, err)
@@ -439,6 +735,8 @@ func (r *NutanixMachineReconciler) reconcileNormal(rctx *nctx.MachineContext) (r
 	// Update the NutanixMachine Spec.ProviderID
 	rctx.NutanixMachine.Spec.ProviderID = GenerateProviderID(rctx.NutanixMachine.Status.VmUUID)
 	rctx.NutanixMachine.Status.Ready = true
+	rctx.NutanixMachine.Status.ObservedGeneration = rctx.NutanixMachine.Generation
+	observeMachineTimeToReady(rctx)
 	log.V(1).Info(fmt.Sprintf("Created VM %s for cluster %s, update NutanixMachine spec.providerID to %s, and machinespec %+v, vmUuid: %s",
 		rctx.Machine.Name, rctx.NutanixCluster.Name, rctx.NutanixMachine.Spec.ProviderID,
 		rctx.NutanixMachine, rctx.NutanixMachine.Status.VmUUID))
@@ -459,14 +757,14 @@ func (r *NutanixMachineReconciler) reconcileNode(rctx *nctx.MachineContext) (rec
 	if err != nil {
 		if r.isGetRemoteClientConnectionError(err) {
 			log.Info(fmt.Sprintf("Controlplane endpoint not yet responding. Requeuing: %v", err))
-			return reconcile.Result{Requeue: true}, nil
+			return requeueFor(requeueForExternalCondition, conditionAge(rctx.NutanixMachine, infrav1.VMProvisionedCondition)), nil
 		}
 		log.Info(fmt.Sprintf("Failed to get the client to access remote workload cluster %s. %v", rctx.Cluster.Name, err))
 		return reconcile.Result{}, err
 	}
 
-	// Retrieve the remote node
-	nodeName := rctx.Machine.Name
+	// Retrieve the remote node, which is named after the hostname of the VM
+	nodeName := getMachineHostname(rctx)
 	node := &corev1.Node{}
 	nodeKey := apitypes.NamespacedName{
 		Namespace: "",
@@ -476,7 +774,7 @@ func (r *NutanixMachineReconciler) reconcileNode(rctx *nctx.MachineContext) (rec
 	if err := remoteClient.Get(rctx.Context, nodeKey, node); err != nil {
 		if apierrors.IsNotFound(err) {
 			log.Info(fmt.Sprintf("workload node %s not yet ready. Requeuing", nodeName))
-			return reconcile.Result{Requeue: true}, nil
+			return requeueFor(requeueForExternalCondition, conditionAge(rctx.NutanixMachine, infrav1.VMProvisionedCondition)), nil
 		} else {
 			log.Error(err, fmt.Sprintf("failed to retrieve the remote workload cluster node %s", nodeName))
 			return reconcile.Result{}, err
@@ -512,167 +810,920 @@ func (r *NutanixMachineReconciler) reconcileNode(rctx *nctx.MachineContext) (rec
 	return reconcile.Result{}, nil
 }
 
-func (r *NutanixMachineReconciler) validateMachineConfig(rctx *nctx.MachineContext) error {
-	if rctx.Machine.Spec.FailureDomain == nil {
-		if len(rctx.NutanixMachine.Spec.Subnets) == 0 {
-			return fmt.Errorf("atleast one subnet is needed to create the VM %s if no failure domain is set", rctx.NutanixMachine.Name)
+// getImageUUID returns the UUID of the image of the NutanixMachine. The image is resolved among the copies present on
+// the Prism Element cluster of the VM if imageClusterScoped is set.
+func (r *NutanixMachineReconciler) getImageUUID(rctx *nctx.MachineContext, peUUID string) (string, error) {
+	image := rctx.NutanixMachine.Spec.Image
+	if rctx.NutanixMachine.Spec.ImageClusterScoped {
+		return GetClusterImageUUID(rctx.Context, rctx.NutanixClient, image.Name, image.UUID, peUUID)
+	}
+	return GetImageUUID(rctx.Context, rctx.NutanixClient, image.Name, image.UUID)
+}
+
+// checkImageReady checks once whether the image of the VM is ready to be used, e.g. whether an upload completed, and
+// records the progress of the image in an event. The ImageReady condition is set accordingly. Returns false while the
+// image is not ready, so the creation of the VM is requeued instead of blocking a worker. An error is returned if the
+// image failed, or did not become ready within the image ready timeout since the NutanixMachine started waiting for it.
+func (r *NutanixMachineReconciler) checkImageReady(rctx *nctx.MachineContext, imageUUID string) (bool, error) {
+	state, percentageComplete, err := nutanixClient.GetImageProgress(rctx.Context, rctx.NutanixClient, imageUUID)
+	if err != nil {
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.ImageReadyCondition, infrav1.ImageReadyFailed, capiv1.ConditionSeverityError, err.Error())
+		return false, err
+	}
+	if nutanixClient.IsImageReady(state) {
+		conditions.MarkTrue(rctx.NutanixMachine, infrav1.ImageReadyCondition)
+		return true, nil
+	}
+
+	var timeout time.Duration
+	if r.controllerConfig != nil {
+		timeout = r.controllerConfig.ImageReadyTimeout
+	}
+	switch conditions.GetReason(rctx.NutanixMachine, infrav1.ImageReadyCondition) {
+	case infrav1.ImageReadyTimeout:
+		return false, fmt.Errorf("image with UUID %s did not become ready within %s", imageUUID, timeout)
+	case infrav1.WaitingForImage:
+		// The condition keeps the time the NutanixMachine started waiting for the image, since its message is not changed
+		waitingSince := conditions.GetLastTransitionTime(rctx.NutanixMachine, infrav1.ImageReadyCondition)
+		if timeout > 0 && waitingSince != nil && r.clock.Since(waitingSince.Time) >= timeout {
+			conditions.MarkFalse(rctx.NutanixMachine, infrav1.ImageReadyCondition, infrav1.ImageReadyTimeout, capiv1.ConditionSeverityWarning,
+				"image with UUID %s did not become ready within %s", imageUUID, timeout)
+			return false, fmt.Errorf("image with UUID %s did not become ready within %s", imageUUID, timeout)
 		}
-		if (rctx.NutanixMachine.Spec.Cluster.Name == nil || *rctx.NutanixMachine.Spec.Cluster.Name == "") &&
-			(rctx.NutanixMachine.Spec.Cluster.UUID == nil || *rctx.NutanixMachine.Spec.Cluster.UUID == "") {
-			return fmt.Errorf("cluster name or uuid are required to create the VM %s if no failure domain is set", rctx.NutanixMachine.Name)
+	default:
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.ImageReadyCondition, infrav1.WaitingForImage, capiv1.ConditionSeverityInfo,
+			"waiting for image with UUID %s to become ready", imageUUID)
+	}
+	if state != "" {
+		msg := fmt.Sprintf("Waiting for image with UUID %s in state %s", imageUUID, state)
+		if percentageComplete >= 0 {
+			msg = fmt.Sprintf("%s (%d%% complete)", msg, percentageComplete)
 		}
+		r.recordEvent(rctx.NutanixMachine, corev1.EventTypeNormal, imageNotReadyEventReason, msg)
 	}
+	return false, nil
+}
 
-	diskSize := rctx.NutanixMachine.Spec.SystemDiskSize
-	// Validate disk size
-	if diskSize.Cmp(minMachineSystemDiskSize) < 0 {
-		diskSizeMib := GetMibValueOfQuantity(diskSize)
-		minMachineSystemDiskSizeMib := GetMibValueOfQuantity(minMachineSystemDiskSize)
-		return fmt.Errorf("minimum systemDiskSize is %vMib but given %vMib", minMachineSystemDiskSizeMib, diskSizeMib)
+// attachVolumeGroups attaches the volume groups of the NutanixMachine to its VM
+func (r *NutanixMachineReconciler) attachVolumeGroups(rctx *nctx.MachineContext) error {
+	if len(rctx.NutanixMachine.Spec.VolumeGroups) == 0 {
+		return nil
 	}
-
-	memorySize := rctx.NutanixMachine.Spec.MemorySize
-	// Validate memory size
-	if memorySize.Cmp(minMachineMemorySize) < 0 {
-		memorySizeMib := GetMibValueOfQuantity(memorySize)
-		minMachineMemorySizeMib := GetMibValueOfQuantity(minMachineMemorySize)
-		return fmt.Errorf("minimum memorySize is %vMib but given %vMib", minMachineMemorySizeMib, memorySizeMib)
+	for _, vgIdentifier := range rctx.NutanixMachine.Spec.VolumeGroups {
+		vg, err := FindVolumeGroup(rctx.Context, rctx.NutanixClient, vgIdentifier.Name, vgIdentifier.UUID)
+		if err != nil {
+			return err
+		}
+		if vg == nil {
+			return fmt.Errorf("failed to find volume group %s", getResourceIdentifierString(vgIdentifier))
+		}
+		if err := AttachVolumeGroup(rctx.Context, rctx.NutanixClient, vg, rctx.NutanixMachine.Status.VmUUID); err != nil {
+			return err
+		}
 	}
+	conditions.MarkTrue(rctx.NutanixMachine, infrav1.VolumeGroupsAttachedCondition)
+	return nil
+}
 
-	vcpusPerSocket := rctx.NutanixMachine.Spec.VCPUsPerSocket
-	if vcpusPerSocket < int32(minVCPUsPerSocket) {
-		return fmt.Errorf("minimum vcpus per socket is %v but given %v", minVCPUsPerSocket, vcpusPerSocket)
+// detachVolumeGroups detaches the volume groups of the NutanixMachine from the VM before it is deleted.
+// Volume groups that no longer exist are skipped.
+func (r *NutanixMachineReconciler) detachVolumeGroups(rctx *nctx.MachineContext, vmUUID string) error {
+	log := ctrl.LoggerFrom(rctx.Context)
+	for _, vgIdentifier := range rctx.NutanixMachine.Spec.VolumeGroups {
+		vg, err := FindVolumeGroup(rctx.Context, rctx.NutanixClient, vgIdentifier.Name, vgIdentifier.UUID)
+		if err != nil {
+			return err
+		}
+		if vg == nil {
+			log.V(1).Info(fmt.Sprintf("volume group %s not found. Skipping detach", getResourceIdentifierString(vgIdentifier)))
+			continue
+		}
+		if err := DetachVolumeGroup(rctx.Context, rctx.NutanixClient, vg, vmUUID); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	vcpuSockets := rctx.NutanixMachine.Spec.VCPUSockets
-	if vcpuSockets < int32(minVCPUSockets) {
-		return fmt.Errorf("minimum vcpu sockets is %v but given %v", minVCPUSockets, vcpuSockets)
-	}
+// reconcileFailureDomainMissing sets the FailureDomainMissing condition if the failure domain recorded on the
+// NutanixMachine no longer exists in the NutanixCluster. The running VM is not moved; it is placed in an existing
+// failure domain once the Machine is recreated.
+func (r *NutanixMachineReconciler) reconcileFailureDomainMissing(rctx *nctx.MachineContext) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	failureDomainName := rctx.NutanixMachine.Status.FailureDomain
+	if failureDomainName == nil || *failureDomainName == "" {
+		conditions.Delete(rctx.NutanixMachine, infrav1.FailureDomainMissingCondition)
+		return
+	}
+	if _, err := GetFailureDomain(*failureDomainName, rctx.NutanixCluster); err == nil {
+		conditions.Delete(rctx.NutanixMachine, infrav1.FailureDomainMissingCondition)
+		return
+	}
+	msg := fmt.Sprintf("failure domain %s of the VM was removed from NutanixCluster %s. The VM is not moved and is placed in another failure domain once the Machine is recreated",
+		*failureDomainName, rctx.NutanixCluster.Name)
+	log.Info(msg)
+	if !conditions.IsTrue(rctx.NutanixMachine, infrav1.FailureDomainMissingCondition) {
+		r.recordEvent(rctx.NutanixMachine, corev1.EventTypeWarning, failureDomainMissingEventReason, msg)
+	}
+	conditions.Set(rctx.NutanixMachine, &capiv1.Condition{
+		Type:    infrav1.FailureDomainMissingCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  infrav1.FailureDomainRemoved,
+		Message: msg,
+	})
+}
 
-	return nil
+// isVMDeletionBlocked returns true and sets the DeletionBlocked condition if the VM carries the configured protection
+// category. Protected VMs are never deleted; the NutanixMachine is kept until the category is removed from the VM.
+func (r *NutanixMachineReconciler) isVMDeletionBlocked(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) bool {
+	log := ctrl.LoggerFrom(rctx.Context)
+	category := r.controllerConfig.vmProtectionCategory()
+	if category == nil || vm.Metadata == nil || vm.Metadata.Categories[category.Key] != category.Value {
+		conditions.Delete(rctx.NutanixMachine, infrav1.DeletionBlockedCondition)
+		return false
+	}
+	msg := fmt.Sprintf("VM %s with UUID %s carries the protection category %s:%s and is not deleted", *vm.Spec.Name, *vm.Metadata.UUID, category.Key, category.Value)
+	log.Info(fmt.Sprintf("%s. Requeuing", msg))
+	if !conditions.IsTrue(rctx.NutanixMachine, infrav1.DeletionBlockedCondition) {
+		r.recordEvent(rctx.NutanixMachine, corev1.EventTypeWarning, vmDeletionBlockedEventReason, msg)
+	}
+	conditions.Set(rctx.NutanixMachine, &capiv1.Condition{
+		Type:    infrav1.DeletionBlockedCondition,
+		Status:  corev1.ConditionTrue,
+		Reason:  infrav1.VMDeletionProtected,
+		Message: msg,
+	})
+	return true
 }
 
-// GetOrCreateVM creates a VM and is invoked by the NutanixMachineReconciler
-func (r *NutanixMachineReconciler) getOrCreateVM(rctx *nctx.MachineContext) (*nutanixClientV3.VMIntentResponse, error) {
-	var err error
-	var vm *nutanixClientV3.VMIntentResponse
-	ctx := rctx.Context
-	log := ctrl.LoggerFrom(ctx)
+// reconcileVMShutdown requests a guest shutdown of the VM of a deleted NutanixMachine and returns true once the VM
+// is powered off or the shutdown grace period expired. The VM is deleted without shutting it down after the grace period.
+func (r *NutanixMachineReconciler) reconcileVMShutdown(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) (bool, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
 	vmName := rctx.Machine.Name
-	nc := rctx.NutanixClient
-
-	// Check if the VM already exists
-	vm, err = FindVM(ctx, nc, rctx.NutanixMachine, vmName)
-	if err != nil {
-		log.Error(err, fmt.Sprintf("error occurred finding VM %s by name or uuid", vmName))
-		return nil, err
+	if IsVMPoweredOff(vm) {
+		log.Info(fmt.Sprintf("VM %s is powered off. Continuing with delete", vmName))
+		return true, nil
 	}
-
-	// if VM exists
-	if vm != nil {
-		log.Info(fmt.Sprintf("vm %s found with UUID %s", *vm.Spec.Name, rctx.NutanixMachine.Status.VmUUID))
-		conditions.MarkTrue(rctx.NutanixMachine, infrav1.VMProvisionedCondition)
-		return vm, nil
+	gracePeriod := r.controllerConfig.VMShutdownGracePeriod
+	shutdownRequested, ok := rctx.NutanixMachine.GetAnnotations()[infrav1.NutanixMachineShutdownRequestedAnnotation]
+	if !ok {
+		taskUUID, err := ShutdownVM(rctx.Context, rctx.NutanixClient, vm)
+		if err != nil {
+			return false, err
+		}
+		annotations.AddAnnotations(rctx.NutanixMachine, map[string]string{
+			infrav1.NutanixMachineShutdownRequestedAnnotation: r.clock.Now().UTC().Format(time.RFC3339),
+		})
+		log.Info(fmt.Sprintf("Shutdown task received for VM %s. Waiting up to %s for VM to power off", vmName, gracePeriod), nutanixClient.LogKeyTaskUUID, taskUUID)
+		return false, nil
 	}
-
-	log.Info(fmt.Sprintf("No existing VM found. Starting creation process of VM %s.", vmName))
-	err = r.validateMachineConfig(rctx)
+	shutdownRequestedAt, err := time.Parse(time.RFC3339, shutdownRequested)
 	if err != nil {
-		rctx.SetFailureStatus(capierrors.CreateMachineError, err)
-		return nil, err
+		log.Error(err, fmt.Sprintf("invalid shutdown request time %q on NutanixMachine %s. Forcing delete of VM %s", shutdownRequested, rctx.NutanixMachine.Name, vmName))
+		return true, nil
 	}
+	if r.clock.Since(shutdownRequestedAt) >= gracePeriod {
+		log.Info(fmt.Sprintf("VM %s did not power off within shutdown grace period of %s. Forcing delete", vmName, gracePeriod))
+		return true, nil
+	}
+	log.V(1).Info(fmt.Sprintf("Waiting for VM %s to power off", vmName))
+	return false, nil
+}
 
-	peUUID, subnetUUIDs, err := r.GetSubnetAndPEUUIDs(rctx)
-	if err != nil {
-		log.Error(err, fmt.Sprintf("failed to get the config for VM %s.", vmName))
-		rctx.SetFailureStatus(capierrors.CreateMachineError, err)
-		return nil, err
+// getVMPowerStateAfterCreate returns the power state the VM of the NutanixMachine is created with
+func getVMPowerStateAfterCreate(nutanixMachine *infrav1.NutanixMachine) string {
+	if nutanixMachine.Spec.PowerOnAfterCreate != nil && !*nutanixMachine.Spec.PowerOnAfterCreate {
+		return vmPowerStateOff
 	}
+	return vmPowerStateOn
+}
 
-	// Get Image UUID
-	imageUUID, err := GetImageUUID(ctx, nc, rctx.NutanixMachine.Spec.Image.Name, rctx.NutanixMachine.Spec.Image.UUID)
-	if err != nil {
-		errorMsg := fmt.Errorf("failed to get the image UUID to create the VM %s. %v", vmName, err)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, err
+// reconcileVMRecreation deletes the VM of a NutanixMachine carrying the recreate-vm annotation. Returns true while the
+// deletion is pending. Once the VM is gone, the status of the NutanixMachine is reset and the annotation is removed, so
+// the VM is created again without deleting the NutanixMachine.
+func (r *NutanixMachineReconciler) reconcileVMRecreation(rctx *nctx.MachineContext) (bool, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	if _, ok := rctx.NutanixMachine.GetAnnotations()[infrav1.NutanixMachineRecreateVMAnnotation]; !ok {
+		return false, nil
+	}
+	vmUUID := rctx.NutanixMachine.Status.VmUUID
+	if vmUUID == "" {
+		log.Info(fmt.Sprintf("VM of NutanixMachine %s not created yet. Ignoring request to recreate the VM", rctx.NutanixMachine.Name))
+		delete(rctx.NutanixMachine.Annotations, infrav1.NutanixMachineRecreateVMAnnotation)
+		return false, nil
+	}
+	// An adopted VM was not created by the controller and cannot be created again
+	if vmUUID == rctx.NutanixMachine.Spec.ExistingVMUUID {
+		log.Info(fmt.Sprintf("VM with UUID %s was adopted. Ignoring request to recreate the VM", vmUUID))
+		r.recordEvent(rctx.NutanixMachine, corev1.EventTypeWarning, vmRecreationRefusedEventReason, fmt.Sprintf("Adopted VM %s is not recreated", vmUUID))
+		delete(rctx.NutanixMachine.Annotations, infrav1.NutanixMachineRecreateVMAnnotation)
+		return false, nil
 	}
 
-	// Get the bootstrapData from the referenced secret
-	bootstrapData, err := r.getBootstrapData(rctx)
+	vm, err := FindVMByUUID(rctx.Context, rctx.NutanixClient, vmUUID)
 	if err != nil {
-		log.Error(err, fmt.Sprintf("failed to get the bootstrap data to create the VM %s", vmName))
-		return nil, err
+		return false, fmt.Errorf("error finding VM with UUID %s: %v", vmUUID, err)
 	}
-	// Encode the bootstrapData by base64
-	bsdataEncoded := base64.StdEncoding.EncodeToString(bootstrapData)
-	log.V(1).Info(fmt.Sprintf("Retrieved the bootstrap data from secret %s (before encoding size: %d, encoded string size:%d)",
-		rctx.NutanixMachine.Spec.BootstrapRef.Name, len(bootstrapData), len(bsdataEncoded)))
+	if vm != nil {
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.VMRecreating, capiv1.ConditionSeverityInfo, "")
+		if r.isVMDeletionBlocked(rctx, vm) {
+			return true, nil
+		}
+		lastTaskUUID, err := GetTaskUUIDFromVM(vm)
+		if err != nil {
+			return false, fmt.Errorf("error occurred fetching task UUID from VM with UUID %s: %v", vmUUID, err)
+		}
+		// The task of the VM is the deletion task once the deletion was submitted
+		if lastTaskUUID != "" {
+			taskInProgress, err := HasTaskInProgress(rctx.Context, rctx.NutanixClient, lastTaskUUID)
+			if err != nil {
+				log.Error(err, fmt.Sprintf("error occurred while checking task of VM with UUID %s. Trying to delete VM", vmUUID), nutanixClient.LogKeyTaskUUID, lastTaskUUID)
+			}
+			if taskInProgress {
+				log.Info(fmt.Sprintf("VM with UUID %s task still in progress. Requeuing", vmUUID), nutanixClient.LogKeyTaskUUID, lastTaskUUID)
+				return true, nil
+			}
+		}
+		if err := r.detachVolumeGroups(rctx, vmUUID); err != nil {
+			return false, fmt.Errorf("failed to detach volume groups from VM with UUID %s: %v", vmUUID, err)
+		}
+		deleteTaskUUID, err := DeleteVM(rctx.Context, rctx.NutanixClient, *vm.Spec.Name, vmUUID)
+		if err != nil {
+			return false, fmt.Errorf("failed to delete VM with UUID %s: %v", vmUUID, err)
+		}
+		log.Info(fmt.Sprintf("Deletion task received for VM with UUID %s to be recreated. Requeueing", vmUUID), nutanixClient.LogKeyTaskUUID, deleteTaskUUID)
+		r.recordEvent(rctx.NutanixMachine, corev1.EventTypeNormal, vmRecreationRequestedEventReason, fmt.Sprintf("Deleting VM %s to recreate it", vmUUID))
+		return true, nil
+	}
+
+	// The VM is gone. The provider ID and status are reset so the VM is not found by its old UUID and a new VM is
+	// created.
+	log.Info(fmt.Sprintf("VM with UUID %s was deleted. Creating a new VM", vmUUID))
+	rctx.NutanixMachine.Spec.ProviderID = ""
+	rctx.NutanixMachine.Status.VmUUID = ""
+	rctx.NutanixMachine.Status.Ready = false
+	rctx.NutanixMachine.Status.Addresses = nil
+	rctx.NutanixMachine.Status.PowerState = ""
+	rctx.NutanixMachine.Status.NodeRef = nil
+	r.vmCreations.forget(rctx.NutanixMachine.UID)
+	delete(rctx.NutanixMachine.Annotations, infrav1.NutanixMachineRecreateVMAnnotation)
+	r.recordEvent(rctx.NutanixMachine, corev1.EventTypeNormal, vmRecreatedEventReason, fmt.Sprintf("VM %s was deleted and will be recreated", vmUUID))
+	return false, nil
+}
 
-	// Generate metadata for the VM
-	vmUUID := uuid.New()
-	metadata := fmt.Sprintf("{\"hostname\": \"%s\", \"uuid\": \"%s\"}", rctx.Machine.Name, vmUUID)
-	// Encode the metadata by base64
-	metadataEncoded := base64.StdEncoding.EncodeToString([]byte(metadata))
+// reconcileVMPowerOn sets the VMPoweredOn condition of NutanixMachines whose VM is created powered off. Returns false
+// while the VM was not powered on yet.
+func (r *NutanixMachineReconciler) reconcileVMPowerOn(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) bool {
+	if getVMPowerStateAfterCreate(rctx.NutanixMachine) == vmPowerStateOn {
+		return true
+	}
+	if GetVMPowerState(vm) != vmPowerStateOn {
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMPoweredOnCondition, infrav1.WaitingForPowerOn, capiv1.ConditionSeverityInfo,
+			"VM was created powered off and waits to be powered on")
+		return false
+	}
+	conditions.MarkTrue(rctx.NutanixMachine, infrav1.VMPoweredOnCondition)
+	return true
+}
 
-	vmInput := &nutanixClientV3.VMIntentInput{}
-	vmSpec := &nutanixClientV3.VM{Name: utils.StringPtr(vmName)}
+// getVMDescription returns the description of the VM of the NutanixMachine identifying the owning CAPI objects,
+// followed by the annotations of the Machine with the configured VM description annotation prefix
+func (r *NutanixMachineReconciler) getVMDescription(rctx *nctx.MachineContext) string {
+	return buildVMDescriptionWithAnnotations(rctx.Machine.Namespace, rctx.Cluster.Name, rctx.Machine.Name,
+		rctx.Machine.Annotations, r.controllerConfig.vmDescriptionAnnotationPrefix())
+}
 
-	nicList := make([]*nutanixClientV3.VMNic, len(subnetUUIDs))
-	for idx, subnetUUID := range subnetUUIDs {
-		nicList[idx] = &nutanixClientV3.VMNic{
-			SubnetReference: &nutanixClientV3.Reference{
-				UUID: utils.StringPtr(subnetUUID),
-				Kind: utils.StringPtr("subnet"),
-			},
+// reconcileVMDescription restores the description of the VM identifying the owning CAPI objects if it was changed
+// in Prism Central or the description annotations of the Machine changed, and refreshes the observed power state and cluster of the VM. Failures are logged only, since the
+// description, power state and cluster are informational. Nothing is done if the VM could not be retrieved.
+func (r *NutanixMachineReconciler) reconcileVMDescription(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	if vm == nil {
+		return
+	}
+	vmUUID := rctx.NutanixMachine.Status.VmUUID
+	rctx.NutanixMachine.Status.PowerState = GetVMPowerState(vm)
+	if clusterUUID := GetVMClusterUUID(vm); clusterUUID != "" {
+		rctx.NutanixMachine.Status.ClusterUUID = clusterUUID
+	}
+	description := r.getVMDescription(rctx)
+	if utils.StringValue(vm.Spec.Description) == description {
+		return
+	}
+	if taskInProgress, err := vmHasTaskInProgress(rctx, vm); err != nil || taskInProgress {
+		if err != nil {
+			log.Error(err, "failed to check the tasks of the VM")
 		}
+		return
+	}
+	log.Info(fmt.Sprintf("Updating stale description of VM with UUID %s", vmUUID))
+	if _, err := UpdateVMDescription(rctx.Context, rctx.NutanixClient, vm, description); err != nil {
+		log.Error(err, fmt.Sprintf("failed to update description of VM with UUID %s", vmUUID))
+		return
+	}
+	// The update task is not awaited, so the following reconcilers postpone their updates until it completes
+	if err := refreshVM(rctx, vm); err != nil {
+		log.Error(err, fmt.Sprintf("failed to fetch VM with UUID %s after updating its description", vmUUID))
 	}
+}
 
-	// Create Disk Spec for systemdisk to be set later in VM Spec
-	diskSize := rctx.NutanixMachine.Spec.SystemDiskSize
-	diskSizeMib := GetMibValueOfQuantity(diskSize)
-	systemDisk, err := CreateSystemDiskSpec(imageUUID, diskSizeMib)
+// reconcileGuestTools sets the GuestToolsReady condition of the NutanixMachine from the Nutanix Guest Tools status of
+// its VM if checkGuestTools is enabled. Returns false if the VM is polled again until Nutanix Guest Tools are ready,
+// which includes a VM that could not be retrieved.
+func (r *NutanixMachineReconciler) reconcileGuestTools(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) bool {
+	if !rctx.NutanixMachine.Spec.CheckGuestTools {
+		conditions.Delete(rctx.NutanixMachine, infrav1.GuestToolsReadyCondition)
+		return true
+	}
+	if vm == nil {
+		return false
+	}
+	ready, reason, msg := getGuestToolsReadiness(vm)
+	switch {
+	case ready:
+		conditions.MarkTrue(rctx.NutanixMachine, infrav1.GuestToolsReadyCondition)
+	case reason == infrav1.GuestToolsNotInstalled:
+		conditions.MarkUnknown(rctx.NutanixMachine, infrav1.GuestToolsReadyCondition, reason, "%s", msg)
+	default:
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.GuestToolsReadyCondition, reason, capiv1.ConditionSeverityInfo, "%s", msg)
+	}
+	return ready
+}
+
+// getGuestToolsReadiness returns true if Nutanix Guest Tools are installed and enabled on the VM, and the VM is
+// reachable through them. Otherwise the reason and message of the GuestToolsReady condition are returned.
+func getGuestToolsReadiness(vm *nutanixClientV3.VMIntentResponse) (bool, string, string) {
+	if vm.Status == nil || vm.Status.Resources == nil || vm.Status.Resources.GuestTools == nil ||
+		vm.Status.Resources.GuestTools.NutanixGuestTools == nil {
+		return false, infrav1.GuestToolsNotInstalled, "Nutanix Guest Tools are not installed on the VM"
+	}
+	ngt := vm.Status.Resources.GuestTools.NutanixGuestTools
+	if ngtState := utils.StringValue(ngt.NgtState); ngtState != "INSTALLED" {
+		return false, infrav1.GuestToolsNotInstalled, fmt.Sprintf("Nutanix Guest Tools are not installed on the VM (state %q)", ngtState)
+	}
+	if state := utils.StringValue(ngt.State); state != "ENABLED" {
+		return false, infrav1.GuestToolsNotReady, fmt.Sprintf("Nutanix Guest Tools are installed but not enabled on the VM (state %q)", state)
+	}
+	if ngt.IsReachable == nil || !*ngt.IsReachable {
+		return false, infrav1.GuestToolsNotReady, "Nutanix Guest Tools are installed but the guest agent is not reachable"
+	}
+	return true, "", ""
+}
+
+// reconcileVMName renames the VM if its name diverged from the expected VM name, e.g. since the VM name template renders
+// a different name, and waits for the update to complete. Adopted VMs keep their name. The VM is not renamed if another
+// VM already has the expected name. Returns true if the update is postponed since another task of the VM is in progress.
+// Nothing is done if the VM could not be retrieved.
+func (r *NutanixMachineReconciler) reconcileVMName(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) (bool, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	vmUUID := rctx.NutanixMachine.Status.VmUUID
+	if vm == nil || vmUUID == rctx.NutanixMachine.Spec.ExistingVMUUID {
+		return false, nil
+	}
+	vmName, err := getVMName(rctx)
 	if err != nil {
-		errorMsg := fmt.Errorf("error occurred while creating system disk spec: %v", err)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, errorMsg
+		return false, err
 	}
-	diskList := []*nutanixClientV3.VMDisk{
-		systemDisk,
+	currentName := utils.StringValue(vm.Spec.Name)
+	if currentName == vmName {
+		return false, nil
 	}
 
-	// Set Categories to VM Sepc before creating VM
-	categories, err := GetCategoryVMSpec(ctx, nc, r.getMachineCategoryIdentifiers(rctx))
+	conflictingVM, err := FindVMByName(rctx.Context, rctx.NutanixClient, vmName)
 	if err != nil {
-		errorMsg := fmt.Errorf("error occurred while creating category spec for vm %s: %v", vmName, err)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, errorMsg
+		return false, fmt.Errorf("failed to check if name %s of VM with UUID %s is in use: %v", vmName, vmUUID, err)
+	}
+	if conflictingVM != nil && conflictingVM.Metadata != nil && utils.StringValue(conflictingVM.Metadata.UUID) != vmUUID {
+		log.Info(fmt.Sprintf("not renaming VM with UUID %s to %s that is the name of VM with UUID %s", vmUUID, vmName, utils.StringValue(conflictingVM.Metadata.UUID)))
+		r.recordEvent(rctx.NutanixMachine, corev1.EventTypeWarning, vmRenameBlockedEventReason,
+			fmt.Sprintf("Cannot rename VM %s from %s to %s since the name is used by VM %s", vmUUID, currentName, vmName, utils.StringValue(conflictingVM.Metadata.UUID)))
+		return false, nil
 	}
 
-	vmMetadata := &nutanixClientV3.Metadata{
-		Kind:        utils.StringPtr("vm"),
-		SpecVersion: utils.Int64Ptr(1),
-		Categories:  categories,
+	if taskInProgress, err := vmHasTaskInProgress(rctx, vm); err != nil || taskInProgress {
+		return taskInProgress, err
 	}
-	// Set Project in VM Spec before creating VM
-	err = r.addVMToProject(rctx, vmMetadata)
+	log.Info(fmt.Sprintf("Renaming VM with UUID %s from %s to %s", vmUUID, currentName, vmName))
+	taskUUID, err := UpdateVMName(rctx.Context, rctx.NutanixClient, vm, vmName)
 	if err != nil {
-		errorMsg := fmt.Errorf("error occurred while trying to add VM %s to project: %v", vmName, err)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, err
+		return false, fmt.Errorf("failed to update name of VM with UUID %s: %v", vmUUID, err)
+	}
+	if err := nutanixClient.WaitForTaskCompletion(rctx.Context, rctx.NutanixClient, taskUUID); err != nil {
+		return false, fmt.Errorf("failed to wait for task %s updating the name of VM with UUID %s: %v", taskUUID, vmUUID, err)
+	}
+	if err := refreshVM(rctx, vm); err != nil {
+		return false, fmt.Errorf("failed to fetch VM with UUID %s after updating its name: %v", vmUUID, err)
 	}
+	r.recordEvent(rctx.NutanixMachine, corev1.EventTypeNormal, vmRenamedEventReason,
+		fmt.Sprintf("Renamed VM %s from %s to %s", vmUUID, currentName, vmName))
+	return false, nil
+}
 
-	// Get GPU list
-	gpuList, err := GetGPUList(ctx, nc, rctx.NutanixMachine.Spec.GPUs, peUUID)
+// reconcileHostname reconciles the hostname passed to the VM in its guest customization with the expected hostname.
+// The guest customization is only applied on the first boot of a VM, so the hostname is only updated for VMs that were
+// created powered off and were not powered on yet. The HostnameChangePending condition is set for VMs that were already
+// powered on, since their hostname only changes once they are recreated. Adopted VMs keep their hostname. Returns true
+// if the update is postponed since another task of the VM is in progress. Nothing is done if the VM could not be
+// retrieved.
+func (r *NutanixMachineReconciler) reconcileHostname(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) (bool, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	vmUUID := rctx.NutanixMachine.Status.VmUUID
+	if vmUUID == "" || vmUUID == rctx.NutanixMachine.Spec.ExistingVMUUID {
+		conditions.Delete(rctx.NutanixMachine, infrav1.HostnameChangePendingCondition)
+		return false, nil
+	}
+	if vm == nil {
+		return false, nil
+	}
+	hostname, err := getHostname(rctx)
 	if err != nil {
-		errorMsg := fmt.Errorf("failed to get the GPU list to create the VM %s. %v", vmName, err)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, err
+		return false, err
+	}
+	metadata, ok := getVMGuestMetadata(vm)
+	if !ok {
+		log.V(1).Info(fmt.Sprintf("skipping hostname update of VM with UUID %s without guest customization metadata", vmUUID))
+		return false, nil
+	}
+	if metadata.Hostname == hostname {
+		conditions.Delete(rctx.NutanixMachine, infrav1.HostnameChangePendingCondition)
+		return false, nil
 	}
 
-	memorySize := rctx.NutanixMachine.Spec.MemorySize
+	if !isVMPreBoot(rctx, vm) {
+		msg := fmt.Sprintf("hostname of VM %s changed from %s to %s. The VM was already powered on and keeps its hostname until it is recreated, e.g. with the %s annotation",
+			vmUUID, metadata.Hostname, hostname, infrav1.NutanixMachineRecreateVMAnnotation)
+		log.Info(msg)
+		if !conditions.IsTrue(rctx.NutanixMachine, infrav1.HostnameChangePendingCondition) {
+			r.recordEvent(rctx.NutanixMachine, corev1.EventTypeWarning, hostnameChangePendingEventReason, msg)
+		}
+		conditions.Set(rctx.NutanixMachine, &capiv1.Condition{
+			Type:    infrav1.HostnameChangePendingCondition,
+			Status:  corev1.ConditionTrue,
+			Reason:  infrav1.HostnameChangeRequiresRecreation,
+			Message: msg,
+		})
+		return false, nil
+	}
+
+	if taskInProgress, err := vmHasTaskInProgress(rctx, vm); err != nil || taskInProgress {
+		return taskInProgress, err
+	}
+	bootstrapData, err := r.getBootstrapData(rctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get the bootstrap data to update the hostname of VM with UUID %s: %v", vmUUID, err)
+	}
+	bootstrapData, err = AddDNSConfig(rctx.NutanixMachine.Spec.BootstrapFormat, bootstrapData, rctx.NutanixMachine.Spec.NameServers, rctx.NutanixMachine.Spec.SearchDomains)
+	if err != nil {
+		return false, fmt.Errorf("failed to add the name servers and search domains to the bootstrap data of VM with UUID %s: %v", vmUUID, err)
+	}
+	// The UUID of the metadata is kept, since cloud-init treats a new instance ID as a new instance
+	guestCustomization, err := CreateGuestCustomizationSpec(rctx.NutanixMachine.Spec.BootstrapFormat, bootstrapData, hostname, metadata.UUID,
+		r.controllerConfig.bootstrapDataCompressionThreshold())
+	if err != nil {
+		return false, fmt.Errorf("failed to create the guest customization for VM with UUID %s: %v", vmUUID, err)
+	}
+	log.Info(fmt.Sprintf("Updating hostname of VM with UUID %s from %s to %s", vmUUID, metadata.Hostname, hostname))
+	taskUUID, err := UpdateVMGuestCustomization(rctx.Context, rctx.NutanixClient, vm, guestCustomization)
+	if err != nil {
+		return false, fmt.Errorf("failed to update guest customization of VM with UUID %s: %v", vmUUID, err)
+	}
+	if err := nutanixClient.WaitForTaskCompletion(rctx.Context, rctx.NutanixClient, taskUUID); err != nil {
+		return false, fmt.Errorf("failed to wait for task %s updating the guest customization of VM with UUID %s: %v", taskUUID, vmUUID, err)
+	}
+	if err := refreshVM(rctx, vm); err != nil {
+		return false, fmt.Errorf("failed to fetch VM with UUID %s after updating its guest customization: %v", vmUUID, err)
+	}
+	conditions.Delete(rctx.NutanixMachine, infrav1.HostnameChangePendingCondition)
+	r.recordEvent(rctx.NutanixMachine, corev1.EventTypeNormal, hostnameUpdatedEventReason,
+		fmt.Sprintf("Updated hostname of VM %s from %s to %s", vmUUID, metadata.Hostname, hostname))
+	return false, nil
+}
+
+// isVMPreBoot returns true if the VM was created powered off and was not powered on yet, so that its guest
+// customization was not applied yet
+func isVMPreBoot(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) bool {
+	return getVMPowerStateAfterCreate(rctx.NutanixMachine) != vmPowerStateOn &&
+		GetVMPowerState(vm) != vmPowerStateOn &&
+		!conditions.IsTrue(rctx.NutanixMachine, infrav1.VMPoweredOnCondition)
+}
+
+// vmHasTaskInProgress returns true if the last task of the VM is still in progress, in which case updates of the VM are
+// postponed. A failed last task, or a last task whose status cannot be retrieved, does not prevent updates.
+func vmHasTaskInProgress(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) (bool, error) {
+	lastTaskUUID, err := GetTaskUUIDFromVM(vm)
+	if err != nil {
+		return false, fmt.Errorf("failed to get last task of VM with UUID %s: %v", rctx.NutanixMachine.Status.VmUUID, err)
+	}
+	if lastTaskUUID == "" {
+		return false, nil
+	}
+	taskInProgress, err := HasTaskInProgress(rctx.Context, rctx.NutanixClient, lastTaskUUID)
+	if err == nil && taskInProgress {
+		ctrl.LoggerFrom(rctx.Context).V(1).Info("postponing update of VM with task in progress", nutanixClient.LogKeyTaskUUID, lastTaskUUID)
+		return true, nil
+	}
+	return false, nil
+}
+
+// refreshVM replaces the VM with its current state in Prism Central after it was updated, so that later updates are
+// submitted with its current spec version and wait for its latest task
+func refreshVM(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) error {
+	vmUUID := rctx.NutanixMachine.Status.VmUUID
+	current, err := FindVMByUUID(rctx.Context, rctx.NutanixClient, vmUUID)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Metadata == nil || current.Spec == nil {
+		return fmt.Errorf("VM with UUID %s was not found", vmUUID)
+	}
+	*vm = *current
+	return nil
+}
+
+// reconcileVMCategories updates the categories of the VM if they drifted from the additional categories of the
+// NutanixMachine, and waits for the update to complete. The ownership categories of the cluster are never removed.
+// They are restored if they were changed in Prism Central and drift reconciliation is enabled, or if all of them were
+// removed from a VM that still matches the NutanixMachine. Returns true if the update is postponed since another task
+// of the VM is in progress. Nothing is done if the VM could not be retrieved.
+func (r *NutanixMachineReconciler) reconcileVMCategories(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) (bool, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	if vm == nil {
+		return false, nil
+	}
+	vmUUID := rctx.NutanixMachine.Status.VmUUID
+	additionalCategories := map[string]string{}
+	for _, ci := range r.getAssignedCategoryIdentifiers(rctx) {
+		additionalCategories[ci.Key] = ci.Value
+	}
+	restoreOwnership := r.controllerConfig.reconcileDrift() || isVMOwnershipLost(rctx, vm)
+	categories := buildVMCategories(vm.Metadata.Categories, additionalCategories, rctx.Cluster.Name, restoreOwnership)
+	if categoriesEqual(vm.Metadata.Categories, categories) {
+		return false, nil
+	}
+	ownershipRestored := false
+	if restoreOwnership {
+		for _, ci := range GetDefaultCAPICategoryIdentifiers(rctx.Cluster.Name) {
+			if value, ok := vm.Metadata.Categories[ci.Key]; !ok || value != ci.Value {
+				ownershipRestored = true
+			}
+		}
+	}
+
+	// The additional categories must exist before they can be assigned
+	if _, err := GetCategoryVMSpec(rctx.Context, rctx.NutanixClient, r.getAssignedCategoryIdentifiers(rctx)); err != nil {
+		return false, err
+	}
+	if taskInProgress, err := vmHasTaskInProgress(rctx, vm); err != nil || taskInProgress {
+		return taskInProgress, err
+	}
+	log.Info(fmt.Sprintf("Updating categories of VM with UUID %s to %v", vmUUID, categories))
+	taskUUID, err := UpdateVMCategories(rctx.Context, rctx.NutanixClient, vm, categories)
+	if err != nil {
+		return false, fmt.Errorf("failed to update categories of VM with UUID %s: %v", vmUUID, err)
+	}
+	if err := nutanixClient.WaitForTaskCompletion(rctx.Context, rctx.NutanixClient, taskUUID); err != nil {
+		return false, fmt.Errorf("failed to wait for task %s updating the categories of VM with UUID %s: %v", taskUUID, vmUUID, err)
+	}
+	if err := refreshVM(rctx, vm); err != nil {
+		return false, fmt.Errorf("failed to fetch VM with UUID %s after updating its categories: %v", vmUUID, err)
+	}
+	if ownershipRestored {
+		r.recordEvent(rctx.NutanixMachine, corev1.EventTypeWarning, vmOwnershipRestoredEventReason,
+			fmt.Sprintf("Restored the ownership categories of VM %s that were removed or changed in Prism Central", vmUUID))
+	}
+	return false, nil
+}
+
+// reconcileSystemDiskSize grows the system disk of the VM if it is smaller than the system disk size of the
+// NutanixMachine, and waits for the update to complete. The system disk is never shrunk. Returns true if the update is
+// postponed since another task of the VM is in progress. Nothing is done if the VM could not be retrieved.
+func (r *NutanixMachineReconciler) reconcileSystemDiskSize(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) (bool, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	if vm == nil {
+		return false, nil
+	}
+	vmUUID := rctx.NutanixMachine.Status.VmUUID
+	systemDisk := GetVMSystemDisk(vm)
+	if systemDisk == nil {
+		log.V(1).Info(fmt.Sprintf("skipping system disk update of VM with UUID %s without system disk", vmUUID))
+		return false, nil
+	}
+	currentSizeMib := getVMDiskSizeMib(systemDisk)
+	desiredSizeMib := GetMibValueOfQuantity(rctx.NutanixMachine.Spec.SystemDiskSize)
+	if desiredSizeMib <= currentSizeMib {
+		return false, nil
+	}
+
+	if taskInProgress, err := vmHasTaskInProgress(rctx, vm); err != nil || taskInProgress {
+		return taskInProgress, err
+	}
+	log.Info(fmt.Sprintf("Growing system disk of VM with UUID %s from %dMib to %dMib", vmUUID, currentSizeMib, desiredSizeMib))
+	taskUUID, err := UpdateVMSystemDiskSize(rctx.Context, rctx.NutanixClient, vm, desiredSizeMib)
+	if err != nil {
+		return false, fmt.Errorf("failed to update system disk of VM with UUID %s: %v", vmUUID, err)
+	}
+	if err := nutanixClient.WaitForTaskCompletion(rctx.Context, rctx.NutanixClient, taskUUID); err != nil {
+		return false, fmt.Errorf("failed to wait for task %s growing the system disk of VM with UUID %s: %v", taskUUID, vmUUID, err)
+	}
+	if err := refreshVM(rctx, vm); err != nil {
+		return false, fmt.Errorf("failed to fetch VM with UUID %s after growing its system disk: %v", vmUUID, err)
+	}
+	r.recordEvent(rctx.NutanixMachine, corev1.EventTypeNormal, systemDiskResizedEventReason,
+		fmt.Sprintf("Grew system disk of VM %s from %dMib to %dMib", vmUUID, currentSizeMib, desiredSizeMib))
+	return false, nil
+}
+
+// getBootstrapISODisk returns the CD-ROM holding the bootstrap ISO of the NutanixMachine, or nil if no bootstrap ISO
+// is set
+func (r *NutanixMachineReconciler) getBootstrapISODisk(rctx *nctx.MachineContext) (*nutanixClientV3.VMDisk, error) {
+	bootstrapISO := rctx.NutanixMachine.Spec.BootstrapISO
+	if bootstrapISO == nil {
+		return nil, nil
+	}
+	imageUUID, err := GetImageUUID(rctx.Context, rctx.NutanixClient, bootstrapISO.Name, bootstrapISO.UUID)
+	if err != nil {
+		return nil, err
+	}
+	return CreateBootstrapISODiskSpec(imageUUID), nil
+}
+
+// reconcileBootstrapISOEject ejects the bootstrap ISO from the CD-ROM of the VM once the Node of the Machine exists.
+// Returns true if the eject is postponed because another task of the VM is in progress. Nothing is done if the VM could
+// not be retrieved.
+func (r *NutanixMachineReconciler) reconcileBootstrapISOEject(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) (bool, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	vmUUID := rctx.NutanixMachine.Status.VmUUID
+	if !rctx.NutanixMachine.Spec.EjectBootstrapISO || rctx.NutanixMachine.Status.NodeRef == nil || vm == nil {
+		return false, nil
+	}
+	if len(getVMBootstrapISODisks(vm)) == 0 {
+		return false, nil
+	}
+
+	if taskInProgress, err := vmHasTaskInProgress(rctx, vm); err != nil || taskInProgress {
+		return taskInProgress, err
+	}
+	log.Info(fmt.Sprintf("Ejecting bootstrap ISO of VM with UUID %s", vmUUID))
+	taskUUID, err := EjectVMBootstrapISOs(rctx.Context, rctx.NutanixClient, vm)
+	if err != nil {
+		return false, fmt.Errorf("failed to eject bootstrap ISO of VM with UUID %s: %v", vmUUID, err)
+	}
+	if err := nutanixClient.WaitForTaskCompletion(rctx.Context, rctx.NutanixClient, taskUUID); err != nil {
+		return false, fmt.Errorf("failed to wait for task %s ejecting the bootstrap ISO of VM with UUID %s: %v", taskUUID, vmUUID, err)
+	}
+	r.recordEvent(rctx.NutanixMachine, corev1.EventTypeNormal, bootstrapISOEjectedEventReason, fmt.Sprintf("Ejected bootstrap ISO of VM %s", vmUUID))
+	return false, nil
+}
+
+// getAdditionalCategoryIdentifiers returns the additional categories of the NutanixMachine
+func (r *NutanixMachineReconciler) getAdditionalCategoryIdentifiers(rctx *nctx.MachineContext) []*infrav1.NutanixCategoryIdentifier {
+	categoryIdentifiers := make([]*infrav1.NutanixCategoryIdentifier, 0, len(rctx.NutanixMachine.Spec.AdditionalCategories))
+	for _, at := range rctx.NutanixMachine.Spec.AdditionalCategories {
+		additionalCat := at
+		categoryIdentifiers = append(categoryIdentifiers, &additionalCat)
+	}
+	return categoryIdentifiers
+}
+
+// getAssignedCategoryIdentifiers returns the additional and the placement policy categories of the NutanixMachine,
+// which are assigned to the VM in addition to the categories managed by the controller
+func (r *NutanixMachineReconciler) getAssignedCategoryIdentifiers(rctx *nctx.MachineContext) []*infrav1.NutanixCategoryIdentifier {
+	return append(r.getAdditionalCategoryIdentifiers(rctx), getPlacementPolicyCategoryIdentifiers(rctx)...)
+}
+
+// recordEvent records an event for the NutanixMachine if an event recorder is configured
+func (r *NutanixMachineReconciler) recordEvent(nutanixMachine *infrav1.NutanixMachine, eventType, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(nutanixMachine, eventType, reason, message)
+	}
+}
+
+func (r *NutanixMachineReconciler) validateMachineConfig(rctx *nctx.MachineContext) error {
+	if rctx.Machine.Spec.FailureDomain == nil && !needsFailureDomainPlacement(rctx) {
+		if len(rctx.NutanixMachine.Spec.Subnets) == 0 && len(rctx.NutanixMachine.Spec.NICs) == 0 {
+			return fmt.Errorf("atleast one subnet or nic is needed to create the VM %s if no failure domain is set", rctx.NutanixMachine.Name)
+		}
+		if (rctx.NutanixMachine.Spec.Cluster.Name == nil || *rctx.NutanixMachine.Spec.Cluster.Name == "") &&
+			(rctx.NutanixMachine.Spec.Cluster.UUID == nil || *rctx.NutanixMachine.Spec.Cluster.UUID == "") {
+			return fmt.Errorf("cluster name or uuid are required to create the VM %s if no failure domain is set", rctx.NutanixMachine.Name)
+		}
+	}
+
+	diskSize := rctx.NutanixMachine.Spec.SystemDiskSize
+	// Validate disk size
+	if diskSize.Cmp(minMachineSystemDiskSize) < 0 {
+		diskSizeMib := GetMibValueOfQuantity(diskSize)
+		minMachineSystemDiskSizeMib := GetMibValueOfQuantity(minMachineSystemDiskSize)
+		return fmt.Errorf("minimum systemDiskSize is %vMib but given %vMib", minMachineSystemDiskSizeMib, diskSizeMib)
+	}
+
+	memorySize := rctx.NutanixMachine.Spec.MemorySize
+	// Validate memory size
+	if memorySize.Cmp(minMachineMemorySize) < 0 {
+		memorySizeMib := GetMibValueOfQuantity(memorySize)
+		minMachineMemorySizeMib := GetMibValueOfQuantity(minMachineMemorySize)
+		return fmt.Errorf("minimum memorySize is %vMib but given %vMib", minMachineMemorySizeMib, memorySizeMib)
+	}
+
+	vcpusPerSocket := rctx.NutanixMachine.Spec.VCPUsPerSocket
+	if vcpusPerSocket < int32(minVCPUsPerSocket) {
+		return fmt.Errorf("minimum vcpus per socket is %v but given %v", minVCPUsPerSocket, vcpusPerSocket)
+	}
+
+	vcpuSockets := rctx.NutanixMachine.Spec.VCPUSockets
+	if vcpuSockets < int32(minVCPUSockets) {
+		return fmt.Errorf("minimum vcpu sockets is %v but given %v", minVCPUSockets, vcpuSockets)
+	}
+
+	return nil
+}
+
+// GetOrCreateVM creates a VM and is invoked by the NutanixMachineReconciler
+func (r *NutanixMachineReconciler) getOrCreateVM(rctx *nctx.MachineContext) (*nutanixClientV3.VMIntentResponse, error) {
+	var err error
+	var vm *nutanixClientV3.VMIntentResponse
+	ctx := rctx.Context
+	log := ctrl.LoggerFrom(ctx)
+	nc := rctx.NutanixClient
+	vmName, err := getVMName(rctx)
+	if err != nil {
+		failVMProvisioning(rctx, err)
+		return nil, err
+	}
+
+	// Check if the VM already exists
+	vm, err = r.findExistingVM(rctx)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("error occurred finding VM %s by name or uuid", vmName))
+		return nil, err
+	}
+
+	// if VM exists
+	if vm != nil {
+		log.Info(fmt.Sprintf("vm %s found with UUID %s", *vm.Spec.Name, *vm.Metadata.UUID))
+		conditions.MarkTrue(rctx.NutanixMachine, infrav1.VMProvisionedCondition)
+		return vm, nil
+	}
+
+	log.Info(fmt.Sprintf("No existing VM found. Starting creation process of VM %s.", vmName))
+	err = r.validateMachineConfig(rctx)
+	if err != nil {
+		failVMProvisioning(rctx, err)
+		return nil, err
+	}
+
+	peUUID, subnetUUIDs, err := r.GetSubnetAndPEUUIDs(rctx)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("failed to get the config for VM %s.", vmName))
+		failVMProvisioning(rctx, err)
+		return nil, err
+	}
+	rctx.NutanixMachine.Status.ClusterUUID = peUUID
+
+	// Get Image UUID
+	imageUUID, err := r.getImageUUID(rctx, peUUID)
+	if err != nil {
+		errorMsg := fmt.Errorf("failed to get the image UUID to create the VM %s. %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, err
+	}
+	imageReady, err := r.checkImageReady(rctx, imageUUID)
+	if err != nil {
+		errorMsg := fmt.Errorf("image with UUID %s is not ready to create the VM %s: %v", imageUUID, vmName, err)
+		log.Error(errorMsg, "image not ready")
+		return nil, errorMsg
+	}
+	if !imageReady {
+		return nil, fmt.Errorf("image with UUID %s of VM %s: %w", imageUUID, vmName, errImageNotReady)
+	}
+
+	// Get the bootstrapData from the referenced secret
+	bootstrapData, err := r.getBootstrapData(rctx)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("failed to get the bootstrap data to create the VM %s", vmName))
+		return nil, err
+	}
+	log.V(1).Info(fmt.Sprintf("Retrieved the bootstrap data from secret %s (size: %d)",
+		rctx.NutanixMachine.Spec.BootstrapRef.Name, len(bootstrapData)))
+
+	bootstrapData, err = AddDNSConfig(rctx.NutanixMachine.Spec.BootstrapFormat, bootstrapData, rctx.NutanixMachine.Spec.NameServers, rctx.NutanixMachine.Spec.SearchDomains)
+	if err != nil {
+		errorMsg := fmt.Errorf("failed to add the name servers and search domains to the bootstrap data of the VM %s: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, errorMsg
+	}
+
+	hostname, err := getHostname(rctx)
+	if err != nil {
+		errorMsg := fmt.Errorf("failed to get the hostname of the VM %s: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, errorMsg
+	}
+
+	// Generate the guest customization passing the bootstrap data and metadata to the VM
+	guestCustomization, err := CreateGuestCustomizationSpec(rctx.NutanixMachine.Spec.BootstrapFormat, bootstrapData, hostname, uuid.New().String(),
+		r.controllerConfig.bootstrapDataCompressionThreshold())
+	if err != nil {
+		errorMsg := fmt.Errorf("failed to create the guest customization for the VM %s: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, errorMsg
+	}
+
+	vmInput := &nutanixClientV3.VMIntentInput{}
+	vmSpec := &nutanixClientV3.VM{
+		Name:        utils.StringPtr(vmName),
+		Description: utils.StringPtr(r.getVMDescription(rctx)),
+	}
+
+	nicList := make([]*nutanixClientV3.VMNic, len(subnetUUIDs))
+	for idx, subnetUUID := range subnetUUIDs {
+		nicList[idx] = &nutanixClientV3.VMNic{
+			SubnetReference: &nutanixClientV3.Reference{
+				UUID: utils.StringPtr(subnetUUID),
+				Kind: utils.StringPtr("subnet"),
+			},
+		}
+	}
+	if err := r.assignNICIPAddresses(rctx, nicList); err != nil {
+		errorMsg := fmt.Errorf("failed to assign the IP addresses of the network interfaces of VM %s: %v", vmName, err)
+		log.Error(errorMsg, "failed to assign IP addresses")
+		return nil, errorMsg
+	}
+
+	// Create Disk Spec for systemdisk to be set later in VM Spec
+	diskSize := rctx.NutanixMachine.Spec.SystemDiskSize
+	diskSizeMib := GetMibValueOfQuantity(diskSize)
+	systemDisk, err := CreateSystemDiskSpec(imageUUID, diskSizeMib, rctx.NutanixMachine.Spec.SystemDiskBus)
+	if err != nil {
+		errorMsg := fmt.Errorf("error occurred while creating system disk spec: %v", err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, errorMsg
+	}
+	if err := r.addStorageContainerToSystemDisk(rctx, systemDisk); err != nil {
+		errorMsg := fmt.Errorf("error occurred while adding storage container to system disk of VM %s: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, errorMsg
+	}
+	diskList := []*nutanixClientV3.VMDisk{
+		systemDisk,
+	}
+	bootstrapISODisk, err := r.getBootstrapISODisk(rctx)
+	if err != nil {
+		errorMsg := fmt.Errorf("failed to get the bootstrap ISO to create the VM %s: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, errorMsg
+	}
+	if bootstrapISODisk != nil {
+		diskList = append(diskList, bootstrapISODisk)
+	}
+
+	// Set Categories to VM Sepc before creating VM
+	categoryIdentifiers, err := r.getMachineCategoryIdentifiers(rctx)
+	if err != nil {
+		errorMsg := fmt.Errorf("error occurred while getting the categories of VM %s: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, err
+	}
+	categories, err := GetCategoryVMSpec(ctx, nc, categoryIdentifiers)
+	if err != nil {
+		errorMsg := fmt.Errorf("error occurred while creating category spec for vm %s: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, errorMsg
+	}
+
+	vmMetadata := &nutanixClientV3.Metadata{
+		Kind:        utils.StringPtr("vm"),
+		SpecVersion: utils.Int64Ptr(1),
+		Categories:  categories,
+	}
+	// Set Project in VM Spec before creating VM
+	err = r.addVMToProject(rctx, vmMetadata)
+	if err != nil {
+		errorMsg := fmt.Errorf("error occurred while trying to add VM %s to project: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, err
+	}
+	// Set the owner in VM Spec before creating VM
+	err = r.setVMOwner(rctx, vmMetadata)
+	if err != nil {
+		errorMsg := fmt.Errorf("error occurred while trying to set the owner of VM %s: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, err
+	}
+
+	// Get GPU list
+	gpuList, err := GetGPUList(ctx, nc, rctx.NutanixMachine.Spec.GPUs, peUUID)
+	if err != nil {
+		errorMsg := fmt.Errorf("failed to get the GPU list to create the VM %s. %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, err
+	}
+
+	memorySize := rctx.NutanixMachine.Spec.MemorySize
 	memorySizeMib := GetMibValueOfQuantity(memorySize)
 	vmSpec.Resources = &nutanixClientV3.VMResources{
-		PowerState:            utils.StringPtr("ON"),
+		PowerState:            utils.StringPtr(getVMPowerStateAfterCreate(rctx.NutanixMachine)),
 		HardwareClockTimezone: utils.StringPtr("UTC"),
 		NumVcpusPerSocket:     utils.Int64Ptr(int64(rctx.NutanixMachine.Spec.VCPUsPerSocket)),
 		NumSockets:            utils.Int64Ptr(int64(rctx.NutanixMachine.Spec.VCPUSockets)),
@@ -680,80 +1731,228 @@ func (r *NutanixMachineReconciler) getOrCreateVM(rctx *nctx.MachineContext) (*nu
 		NicList:               nicList,
 		DiskList:              diskList,
 		GpuList:               gpuList,
-		GuestCustomization: &nutanixClientV3.GuestCustomization{
-			IsOverridable: utils.BoolPtr(true),
-			CloudInit: &nutanixClientV3.GuestCustomizationCloudInit{
-				UserData: utils.StringPtr(bsdataEncoded),
-				MetaData: utils.StringPtr(metadataEncoded),
-			},
-		},
+		SerialPortList:        getSerialPortList(rctx.NutanixMachine.Spec.SerialPorts),
+		GuestCustomization:    guestCustomization,
 	}
 	vmSpec.ClusterReference = &nutanixClientV3.Reference{
 		Kind: utils.StringPtr("cluster"),
 		UUID: utils.StringPtr(peUUID),
 	}
 
-	// Set BootType in VM Spec before creating VM
-	err = r.addBootTypeToVM(rctx, vmSpec)
+	// Set BootType in VM Spec before creating VM
+	err = r.addBootTypeToVM(rctx, vmSpec)
+	if err != nil {
+		errorMsg := fmt.Errorf("error occurred while adding boot type to vm spec: %v", err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, err
+	}
+
+	// Clone the system disk from a seed disk if one is available
+	seedKey := newSeedPoolKey(rctx.Cluster, imageUUID, peUUID)
+	seed, fromSeed := r.takeSeedDisk(rctx, seedKey)
+	// The seed disk is returned to the pool unless the creation of the VM was submitted
+	submitted := false
+	if fromSeed {
+		defer func() {
+			r.putBackSeedDisk(rctx, seedKey, seed, submitted)
+		}()
+		log.V(1).Info(fmt.Sprintf("Cloning the system disk of VM %s from seed disk %s", vmName, seed.diskUUID))
+		systemDisk.DataSourceReference = &nutanixClientV3.Reference{
+			Kind: utils.StringPtr("vm_disk"),
+			UUID: utils.StringPtr(seed.diskUUID),
+		}
+	}
+
+	vmInput.Spec = vmSpec
+	vmInput.Metadata = vmMetadata
+	if extraVMConfig := rctx.NutanixMachine.Spec.ExtraVMConfig; extraVMConfig != nil {
+		if r.controllerConfig.extraVMConfig() {
+			vmInput, err = MergeExtraVMConfig(vmInput, extraVMConfig)
+			if err != nil {
+				errorMsg := fmt.Errorf("failed to apply the extraVMConfig to VM %s: %v", vmName, err)
+				failVMProvisioning(rctx, errorMsg)
+				return nil, err
+			}
+		} else {
+			log.Info(fmt.Sprintf("Ignoring the extraVMConfig of VM %s since it is disabled in the controller", vmName))
+			r.recordEvent(rctx.NutanixMachine, corev1.EventTypeWarning, extraVMConfigIgnoredEventReason,
+				fmt.Sprintf("The extraVMConfig of VM %s is ignored since it is disabled in the controller", vmName))
+		}
+	}
+	// The placement policy categories are assigned last, so no other setting can drop them from the create request
+	if err := applyPlacementPolicyCategories(rctx, vmInput); err != nil {
+		errorMsg := fmt.Errorf("failed to assign the placement policy categories to VM %s: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, errorMsg
+	}
+	// Create the actual VM/Machine
+	log.Info(fmt.Sprintf("Creating VM with name %s for cluster %s", vmName, rctx.NutanixCluster.Name))
+	vmResponse, created, err := r.submitVMCreation(rctx, vmInput)
+	if err != nil {
+		if errors.Is(err, errLeadershipLost) {
+			log.Info(fmt.Sprintf("Not creating VM %s since the controller is not the leader anymore", vmName))
+			return nil, err
+		}
+		errorMsg := fmt.Errorf("failed to create VM %s. error: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, err
+	}
+	if !created {
+		log.Info(fmt.Sprintf("VM %s with UUID %s was created by a concurrent reconciliation", vmName, *vmResponse.Metadata.UUID))
+		conditions.MarkTrue(rctx.NutanixMachine, infrav1.VMProvisionedCondition)
+		return vmResponse, nil
+	}
+	submitted = true
+
+	lastTaskUUID, err := r.recordVMCreation(rctx, vmResponse)
 	if err != nil {
-		errorMsg := fmt.Errorf("error occurred while adding boot type to vm spec: %v", err)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
+		return nil, err
+	}
+	vmUuid := rctx.NutanixMachine.Status.VmUUID
+	ctx = rctx.Context
+	log = ctrl.LoggerFrom(ctx)
+	log.Info("Waiting for the creation task of the VM to complete", nutanixClient.LogKeyTaskUUID, lastTaskUUID)
+	if err := r.waitForVMCreationTask(rctx, lastTaskUUID); err != nil {
 		return nil, err
 	}
 
-	vmInput.Spec = vmSpec
-	vmInput.Metadata = vmMetadata
-	// Create the actual VM/Machine
-	log.Info(fmt.Sprintf("Creating VM with name %s for cluster %s", vmName, rctx.NutanixCluster.Name))
-	vmResponse, err := nc.V3.CreateVM(ctx, vmInput)
+	log.Info("Fetching VM after creation")
+	vm, err = FindVMByUUID(ctx, nc, vmUuid)
 	if err != nil {
-		errorMsg := fmt.Errorf("failed to create VM %s. error: %v", vmName, err)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, err
+		errorMsg := fmt.Errorf("error occurred while getting VM %s after creation: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return nil, errorMsg
+	}
+
+	conditions.MarkTrue(rctx.NutanixMachine, infrav1.VMProvisionedCondition)
+	return vm, nil
+}
+
+// waitForVMCreationTask waits until the creation task of the VM succeeded. The NutanixMachine is failed if the task
+// failed or did not succeed within the VM task timeout, so the Machine can be remediated.
+func (r *NutanixMachineReconciler) waitForVMCreationTask(rctx *nctx.MachineContext, taskUUID string) error {
+	timeout := r.controllerConfig.vmTaskTimeout()
+	err := nutanixClient.WaitForTaskToSucceed(rctx.Context, rctx.NutanixClient, taskUUID, nutanixClient.WaitOptions{
+		Interval: r.taskPollInterval,
+		Timeout:  timeout,
+	})
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, wait.ErrWaitTimeout) {
+		errorMsg := fmt.Errorf("creation task %s of VM %s did not succeed within %s", taskUUID, rctx.Machine.Name, timeout)
+		failVMProvisioning(rctx, errorMsg)
+		return errorMsg
 	}
+	if errors.Is(err, nutanixClient.ErrTaskFailed) {
+		errorMsg := fmt.Errorf("creation task %s of VM %s failed: %v", taskUUID, rctx.Machine.Name, err)
+		if isQuotaExceededError(err) {
+			r.discardVMCreation(rctx)
+		}
+		failVMProvisioning(rctx, errorMsg)
+		return errorMsg
+	}
+	// The state of the task could not be retrieved. The VM is adopted on the next reconciliation since its UUID was
+	// recorded.
+	return fmt.Errorf("error occurred while waiting for creation task %s of VM %s: %v", taskUUID, rctx.Machine.Name, err)
+}
 
+// failVMProvisioning fails the provisioning of the VM of the NutanixMachine with the error. The failure reason and
+// message are set on the NutanixMachine and copied to the Machine by CAPI, so a MachineHealthCheck remediates the
+// Machine right away instead of waiting for its node startup timeout, and the VMProvisioned condition is marked false
+// with error severity. Transient errors, e.g. if Prism Central cannot be reached, do not fail the NutanixMachine so
+// that the provisioning is retried. Exceeded quotas do not fail the NutanixMachine either, but are reported in the
+// VMProvisioned condition.
+func failVMProvisioning(rctx *nctx.MachineContext, err error) {
+	if isQuotaExceededError(err) {
+		markQuotaExceeded(rctx, err)
+		return
+	}
+	if isTransientError(err) {
+		return
+	}
+	conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.VMProvisioningFailed, capiv1.ConditionSeverityError, "%s", err.Error())
+	rctx.SetFailureStatus(capierrors.CreateMachineError, err)
+}
+
+// recordVMCreation sets the UUID of the VM whose creation was submitted on the NutanixMachine and patches the
+// NutanixMachine right away, so the UUID is visible before the creation task completed. If the patch fails, the VM is
+// adopted by name on the next reconciliation since it carries the category of the cluster. Returns the UUID of the
+// creation task.
+func (r *NutanixMachineReconciler) recordVMCreation(rctx *nctx.MachineContext, vmResponse *nutanixClientV3.VMIntentResponse) (string, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	vmName := rctx.Machine.Name
 	if vmResponse == nil || vmResponse.Metadata == nil || vmResponse.Metadata.UUID == nil || *vmResponse.Metadata.UUID == "" {
-		errorMsg := fmt.Errorf("no valid VM UUID found in response after creating vm %s", rctx.Machine.Name)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, errorMsg
+		errorMsg := fmt.Errorf("no valid VM UUID found in response after creating vm %s", vmName)
+		failVMProvisioning(rctx, errorMsg)
+		return "", errorMsg
 	}
 	vmUuid := *vmResponse.Metadata.UUID
+	patchHelper, err := patch.NewHelper(rctx.NutanixMachine, r.Client)
+	if err != nil {
+		return "", fmt.Errorf("failed to create patch helper to patch machine %s: %v", rctx.NutanixMachine.Name, err)
+	}
 	// set the VM UUID on the nutanix machine as soon as it is available. VM UUID can be used for cleanup in case of failure
 	rctx.NutanixMachine.Spec.ProviderID = GenerateProviderID(vmUuid)
 	rctx.NutanixMachine.Status.VmUUID = vmUuid
+	rctx.NutanixMachine.Status.PowerState = GetVMPowerState(vmResponse)
+	if err := patchHelper.Patch(rctx.Context, rctx.NutanixMachine); err != nil {
+		return "", fmt.Errorf("failed to patch NutanixMachine %s with the UUID %s of the created VM: %v", rctx.NutanixMachine.Name, vmUuid, err)
+	}
+	rctx.Context = nutanixClient.WithLogValues(rctx.Context, nutanixClient.LogKeyVMUUID, vmUuid)
+	log = ctrl.LoggerFrom(rctx.Context)
 
-	log.V(1).Info(fmt.Sprintf("Sent the post request to create VM %s. Got the vm UUID: %s, status.state: %s", vmName, vmUuid, *vmResponse.Status.State))
-	log.V(1).Info(fmt.Sprintf("Getting task vmUUID for VM %s", vmName))
+	state := ""
+	if vmResponse.Status != nil {
+		state = utils.StringValue(vmResponse.Status.State)
+	}
+	log.V(1).Info(fmt.Sprintf("Sent the post request to create VM %s", vmName), "state", state)
 	lastTaskUUID, err := GetTaskUUIDFromVM(vmResponse)
 	if err != nil {
-		errorMsg := fmt.Errorf("error occurred fetching task UUID from vm %s after creation: %v", rctx.Machine.Name, err)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, errorMsg
+		errorMsg := fmt.Errorf("error occurred fetching task UUID from vm %s after creation: %v", vmName, err)
+		failVMProvisioning(rctx, errorMsg)
+		return "", errorMsg
 	}
 
 	if lastTaskUUID == "" {
 		errorMsg := fmt.Errorf("failed to retrieve task UUID for VM %s after creation", vmName)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, errorMsg
-	}
-	log.Info(fmt.Sprintf("Waiting for task %s to get completed for VM %s", lastTaskUUID, rctx.NutanixMachine.Name))
-	err = nutanixClient.WaitForTaskCompletion(ctx, nc, lastTaskUUID)
-	if err != nil {
-		errorMsg := fmt.Errorf("error occurred while waiting for task %s to start: %v", lastTaskUUID, err)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, errorMsg
+		failVMProvisioning(rctx, errorMsg)
+		return "", errorMsg
 	}
+	return lastTaskUUID, nil
+}
 
-	log.Info("Fetching VM after creation")
-	vm, err = FindVMByUUID(ctx, nc, vmUuid)
-	if err != nil {
-		errorMsg := fmt.Errorf("error occurred while getting VM %s after creation: %v", vmName, err)
-		rctx.SetFailureStatus(capierrors.CreateMachineError, errorMsg)
-		return nil, errorMsg
+// reconcileBootstrapData verifies that the bootstrap data secret of the NutanixMachine exists and is populated before the
+// VM is created, and sets the BootstrapDataReady condition accordingly. The secret is not checked anymore once the VM
+// was created. Returns false while waiting for the bootstrap data.
+func (r *NutanixMachineReconciler) reconcileBootstrapData(rctx *nctx.MachineContext) (bool, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	if rctx.NutanixMachine.Status.VmUUID != "" {
+		conditions.MarkTrue(rctx.NutanixMachine, infrav1.BootstrapDataReadyCondition)
+		return true, nil
 	}
-
-	conditions.MarkTrue(rctx.NutanixMachine, infrav1.VMProvisionedCondition)
-	return vm, nil
+	secretName := rctx.NutanixMachine.Spec.BootstrapRef.Name
+	secret := &corev1.Secret{}
+	secretKey := apitypes.NamespacedName{
+		Namespace: rctx.NutanixMachine.Spec.BootstrapRef.Namespace,
+		Name:      secretName,
+	}
+	message := ""
+	if err := r.Client.Get(rctx.Context, secretKey, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "failed to retrieve bootstrap data secret %s", secretName)
+		}
+		message = fmt.Sprintf("bootstrap data secret %s not found", secretName)
+	} else if len(secret.Data["value"]) == 0 {
+		message = fmt.Sprintf("bootstrap data secret %s is not populated yet", secretName)
+	}
+	if message != "" {
+		log.Info(fmt.Sprintf("Waiting for bootstrap data: %s", message))
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.BootstrapDataReadyCondition, infrav1.WaitingForBootstrapData, capiv1.ConditionSeverityInfo, message)
+		return false, nil
+	}
+	conditions.MarkTrue(rctx.NutanixMachine, infrav1.BootstrapDataReadyCondition)
+	return true, nil
 }
 
 // getBootstrapData returns the Bootstrap data from the ref secret
@@ -777,9 +1976,23 @@ func (r *NutanixMachineReconciler) getBootstrapData(rctx *nctx.MachineContext) (
 		return nil, errors.New("error retrieving bootstrap data: secret value key is missing")
 	}
 
+	// The format key is set by bootstrap providers supporting multiple formats
+	if format, ok := secret.Data["format"]; ok && !bootstrapFormatMatches(rctx.NutanixMachine.Spec.BootstrapFormat, string(format)) {
+		return nil, errors.Errorf("bootstrap data secret %s has format %s, which does not match the bootstrap format %s of the NutanixMachine",
+			secretName, format, rctx.NutanixMachine.Spec.BootstrapFormat)
+	}
+
 	return value, nil
 }
 
+// bootstrapFormatMatches returns true if the format of a bootstrap data secret matches the bootstrap format
+func bootstrapFormatMatches(bootstrapFormat infrav1.NutanixBootstrapFormat, secretFormat string) bool {
+	if bootstrapFormat == infrav1.NutanixBootstrapFormatIgnition {
+		return secretFormat == secretFormatIgnition
+	}
+	return secretFormat == secretFormatCloudConfig
+}
+
 func (r *NutanixMachineReconciler) patchMachine(rctx *nctx.MachineContext) error {
 	log := ctrl.LoggerFrom(rctx.Context)
 	patchHelper, err := patch.NewHelper(rctx.NutanixMachine, r.Client)
@@ -817,16 +2030,260 @@ func (r *NutanixMachineReconciler) assignAddressesToMachine(rctx *nctx.MachineCo
 		return fmt.Errorf("unable to determine network interfaces from VM. Retrying")
 	}
 	rctx.IP = rctx.NutanixMachine.Status.Addresses[0].Address
+	// The hostname the VM was created with is reported, even if a change of the hostname is pending. Adopted VMs are
+	// named after the Machine.
+	hostname := rctx.Machine.Name
+	if metadata, ok := getVMGuestMetadata(vm); ok && rctx.NutanixMachine.Status.VmUUID != rctx.NutanixMachine.Spec.ExistingVMUUID {
+		hostname = metadata.Hostname
+	}
 	rctx.NutanixMachine.Status.Addresses = append(rctx.NutanixMachine.Status.Addresses, capiv1.MachineAddress{
 		Type:    capiv1.MachineHostName,
-		Address: *vm.Spec.Name,
+		Address: hostname,
 	})
 	return nil
 }
 
-func (r *NutanixMachineReconciler) getMachineCategoryIdentifiers(rctx *nctx.MachineContext) []*infrav1.NutanixCategoryIdentifier {
+// submitVMCreation creates the VM unless a concurrent reconciliation of the NutanixMachine already created it. The
+// creation is serialized per NutanixMachine, and the VM is looked up again right before it is created. Returns false
+// with the existing VM if the VM was not created.
+func (r *NutanixMachineReconciler) submitVMCreation(rctx *nctx.MachineContext, vmInput *nutanixClientV3.VMIntentInput) (*nutanixClientV3.VMIntentResponse, bool, error) {
+	ctx := rctx.Context
+	uid := rctx.NutanixMachine.UID
+	unlock := r.vmCreations.lock(uid)
+	defer unlock()
+
+	// The cached NutanixMachine may not carry the UUID of a VM created by an earlier reconciliation yet
+	if vmUUID := r.vmCreations.submittedVM(uid); vmUUID != "" {
+		vm, err := FindVMByUUID(ctx, rctx.NutanixClient, vmUUID)
+		if err != nil {
+			return nil, false, err
+		}
+		if vm != nil {
+			return vm, false, nil
+		}
+	}
+	vmName := utils.StringValue(vmInput.Spec.Name)
+	vm, err := FindVMByName(ctx, rctx.NutanixClient, vmName)
+	if err != nil {
+		return nil, false, err
+	}
+	if vm != nil {
+		if !isVMOwnedByCluster(vm, rctx.Cluster.Name) {
+			return nil, false, fmt.Errorf("VM %s with UUID %s already exists and is not owned by cluster %s", vmName, utils.StringValue(vm.Metadata.UUID), rctx.Cluster.Name)
+		}
+		return vm, false, nil
+	}
+
+	// A new leader finds the VM by name, so the creation is not submitted once the leadership is lost
+	if !r.leadership.isLeader() || ctx.Err() != nil {
+		return nil, false, errLeadershipLost
+	}
+	vmResponse, err := rctx.NutanixClient.V3.CreateVM(ctx, vmInput)
+	if err != nil {
+		return nil, false, err
+	}
+	if vmResponse != nil && vmResponse.Metadata != nil && utils.StringValue(vmResponse.Metadata.UUID) != "" {
+		r.vmCreations.recordSubmission(uid, *vmResponse.Metadata.UUID)
+	}
+	return vmResponse, true, nil
+}
+
+// findExistingVM returns the VM of the NutanixMachine if it already exists. A VM only found by name is adopted if it
+// carries the category of the cluster and matches the spec of the NutanixMachine. Otherwise another machine owns the
+// name and an error is returned. Returns nil if no VM exists.
+func (r *NutanixMachineReconciler) findExistingVM(rctx *nctx.MachineContext) (*nutanixClientV3.VMIntentResponse, error) {
+	ctx := rctx.Context
+	log := ctrl.LoggerFrom(ctx)
+	vmName, err := getVMName(rctx)
+	if err != nil {
+		return nil, err
+	}
+	vmUUID, err := GetVMUUID(rctx.NutanixMachine)
+	if err != nil {
+		return nil, err
+	}
+	// The VM UUID is only known once the VM was created for this NutanixMachine
+	if vmUUID != "" {
+		return FindVM(ctx, rctx.NutanixClient, rctx.NutanixMachine, vmName)
+	}
+	if rctx.NutanixMachine.Spec.ExistingVMUUID != "" {
+		return r.adoptExistingVM(rctx)
+	}
+
+	vm, err := FindVMByName(ctx, rctx.NutanixClient, vmName)
+	if err != nil {
+		return nil, err
+	}
+	if vm == nil {
+		return nil, nil
+	}
+	existingVMUUID := utils.StringValue(vm.Metadata.UUID)
+	if !isVMOwnedByCluster(vm, rctx.Cluster.Name) {
+		err := fmt.Errorf("VM %s with UUID %s already exists and is not owned by cluster %s", vmName, existingVMUUID, rctx.Cluster.Name)
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.VMNameConflict, capiv1.ConditionSeverityError, err.Error())
+		return nil, err
+	}
+	if err := r.validateExistingVMSpec(rctx, vm); err != nil {
+		err := fmt.Errorf("VM %s with UUID %s already exists and does not match NutanixMachine %s: %v", vmName, existingVMUUID, rctx.NutanixMachine.Name, err)
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.VMNameConflict, capiv1.ConditionSeverityError, err.Error())
+		return nil, err
+	}
+	log.Info(fmt.Sprintf("adopting existing VM %s with UUID %s", vmName, existingVMUUID))
+	return vm, nil
+}
+
+// adoptExistingVM applies the ownership categories of the cluster, the additional categories and the description of
+// the Machine to the existing VM referenced by the NutanixMachine, and returns the VM. An error is returned if the VM
+// does not exist or is owned by another Machine.
+func (r *NutanixMachineReconciler) adoptExistingVM(rctx *nctx.MachineContext) (*nutanixClientV3.VMIntentResponse, error) {
+	ctx := rctx.Context
+	log := ctrl.LoggerFrom(ctx)
+	vmUUID := rctx.NutanixMachine.Spec.ExistingVMUUID
+	vm, err := FindVMByUUID(ctx, rctx.NutanixClient, vmUUID)
+	if err != nil {
+		return nil, err
+	}
+	if vm == nil || vm.Metadata == nil || vm.Spec == nil {
+		err := fmt.Errorf("existing VM with UUID %s to adopt was not found", vmUUID)
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.VMAdoptionFailed, capiv1.ConditionSeverityError, err.Error())
+		return nil, err
+	}
+	if err := r.validateVMAdoption(rctx, vm); err != nil {
+		err := fmt.Errorf("existing VM with UUID %s cannot be adopted by NutanixMachine %s: %v", vmUUID, rctx.NutanixMachine.Name, err)
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.VMAdoptionFailed, capiv1.ConditionSeverityError, err.Error())
+		return nil, err
+	}
+
+	additionalCategories := map[string]string{}
+	for _, ci := range r.getAssignedCategoryIdentifiers(rctx) {
+		additionalCategories[ci.Key] = ci.Value
+	}
+	categories := buildVMCategories(vm.Metadata.Categories, additionalCategories, rctx.Cluster.Name, true)
+	description := r.getVMDescription(rctx)
+	if categoriesEqual(vm.Metadata.Categories, categories) && utils.StringValue(vm.Spec.Description) == description {
+		return vm, nil
+	}
+	// The additional categories must exist before they can be assigned
+	if _, err := GetCategoryVMSpec(ctx, rctx.NutanixClient, r.getAssignedCategoryIdentifiers(rctx)); err != nil {
+		return nil, err
+	}
+	log.Info(fmt.Sprintf("adopting existing VM %s with UUID %s", utils.StringValue(vm.Spec.Name), vmUUID))
+	vm.Spec.Description = utils.StringPtr(description)
+	taskUUID, err := UpdateVMCategories(ctx, rctx.NutanixClient, vm, categories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update categories of VM with UUID %s to adopt it: %v", vmUUID, err)
+	}
+	if err := nutanixClient.WaitForTaskCompletion(ctx, rctx.NutanixClient, taskUUID); err != nil {
+		return nil, fmt.Errorf("failed to wait for task %s updating the categories of VM with UUID %s: %v", taskUUID, vmUUID, err)
+	}
+	r.recordEvent(rctx.NutanixMachine, corev1.EventTypeNormal, vmAdoptedEventReason,
+		fmt.Sprintf("Adopted existing VM %s with UUID %s", utils.StringValue(vm.Spec.Name), vmUUID))
+	return vm, nil
+}
+
+// validateVMAdoption verifies that an existing VM is not owned by another cluster or Machine before it is adopted
+func (r *NutanixMachineReconciler) validateVMAdoption(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) error {
+	vmUUID := utils.StringValue(vm.Metadata.UUID)
+	for key, value := range vm.Metadata.Categories {
+		if key == infrav1.DefaultCAPICategoryKeyForName && value != rctx.Cluster.Name {
+			return fmt.Errorf("VM is owned by cluster %s", value)
+		}
+		if strings.HasPrefix(key, infrav1.ObsoleteDefaultCAPICategoryPrefix) && key != infrav1.ObsoleteDefaultCAPICategoryPrefix+rctx.Cluster.Name {
+			return fmt.Errorf("VM is owned by cluster %s", strings.TrimPrefix(key, infrav1.ObsoleteDefaultCAPICategoryPrefix))
+		}
+	}
+	description := utils.StringValue(vm.Spec.Description)
+	if strings.HasPrefix(description, infrav1.DefaultCAPICategoryDescription) &&
+		!isVMDescriptionOfMachine(description, rctx.Machine.Namespace, rctx.Cluster.Name, rctx.Machine.Name) {
+		return fmt.Errorf("VM is owned by another Machine: %s", description)
+	}
+	nutanixMachines := &infrav1.NutanixMachineList{}
+	if err := r.Client.List(rctx.Context, nutanixMachines, client.InNamespace(rctx.NutanixMachine.Namespace)); err != nil {
+		return fmt.Errorf("failed to list NutanixMachines: %v", err)
+	}
+	for _, nutanixMachine := range nutanixMachines.Items {
+		if nutanixMachine.Name == rctx.NutanixMachine.Name {
+			continue
+		}
+		if nutanixMachine.Status.VmUUID == vmUUID || nutanixMachine.Spec.ExistingVMUUID == vmUUID {
+			return fmt.Errorf("VM is owned by NutanixMachine %s", nutanixMachine.Name)
+		}
+	}
+	return nil
+}
+
+// isVMOwnershipLost returns true if the VM of the NutanixMachine carries none of the ownership categories, e.g. since
+// they were dropped during a Prism Central upgrade, but still matches the NutanixMachine by the UUID in its status and
+// by name. Such a VM is not foreign, so its ownership categories are restored.
+func isVMOwnershipLost(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) bool {
+	if vm.Metadata == nil || vm.Spec == nil {
+		return false
+	}
+	vmUUID := utils.StringValue(vm.Metadata.UUID)
+	if vmUUID == "" || vmUUID != rctx.NutanixMachine.Status.VmUUID {
+		return false
+	}
+	for key := range vm.Metadata.Categories {
+		if key == infrav1.DefaultCAPICategoryKeyForName || strings.HasPrefix(key, infrav1.ObsoleteDefaultCAPICategoryPrefix) {
+			return false
+		}
+	}
+	// Adopted VMs keep their name
+	if vmUUID == rctx.NutanixMachine.Spec.ExistingVMUUID {
+		return true
+	}
+	vmName := utils.StringValue(vm.Spec.Name)
+	expectedName, err := getVMName(rctx)
+	if err != nil {
+		return false
+	}
+	return vmName == expectedName || vmName == rctx.NutanixMachine.Name
+}
+
+// isVMOwnedByCluster returns true if the VM carries the default or the obsolete default category of the cluster
+func isVMOwnedByCluster(vm *nutanixClientV3.VMIntentResponse, clusterName string) bool {
+	if vm.Metadata == nil {
+		return false
+	}
+	ownerCategories := append(GetDefaultCAPICategoryIdentifiers(clusterName), GetObsoleteDefaultCAPICategoryIdentifiers(clusterName)...)
+	for _, ownerCategory := range ownerCategories {
+		if value, ok := vm.Metadata.Categories[ownerCategory.Key]; ok && value == ownerCategory.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExistingVMSpec verifies that the compute resources of an existing VM match the spec of the NutanixMachine
+func (r *NutanixMachineReconciler) validateExistingVMSpec(rctx *nctx.MachineContext, vm *nutanixClientV3.VMIntentResponse) error {
+	if vm.Spec == nil || vm.Spec.Resources == nil {
+		return fmt.Errorf("VM has no resources")
+	}
+	resources := vm.Spec.Resources
+	spec := rctx.NutanixMachine.Spec
+	if utils.Int64Value(resources.NumSockets) != int64(spec.VCPUSockets) {
+		return fmt.Errorf("expected %d vCPU sockets but found %d", spec.VCPUSockets, utils.Int64Value(resources.NumSockets))
+	}
+	if utils.Int64Value(resources.NumVcpusPerSocket) != int64(spec.VCPUsPerSocket) {
+		return fmt.Errorf("expected %d vCPUs per socket but found %d", spec.VCPUsPerSocket, utils.Int64Value(resources.NumVcpusPerSocket))
+	}
+	memorySizeMib := GetMibValueOfQuantity(spec.MemorySize)
+	if utils.Int64Value(resources.MemorySizeMib) != memorySizeMib {
+		return fmt.Errorf("expected %dMib memory but found %dMib", memorySizeMib, utils.Int64Value(resources.MemorySizeMib))
+	}
+	return nil
+}
+
+func (r *NutanixMachineReconciler) getMachineCategoryIdentifiers(rctx *nctx.MachineContext) ([]*infrav1.NutanixCategoryIdentifier, error) {
 	log := ctrl.LoggerFrom(rctx.Context)
 	categoryIdentifiers := GetDefaultCAPICategoryIdentifiers(rctx.Cluster.Name)
+	if r.controllerConfig.machineRoleCategories() {
+		role, machineDeployment, err := r.getMachineRole(rctx)
+		if err != nil {
+			return nil, err
+		}
+		categoryIdentifiers = append(categoryIdentifiers, GetMachineRoleCategoryIdentifiers(role, machineDeployment)...)
+	}
 	// Only try to create default categories. ignoring error so that we can return all including
 	// additionalCategories as well
 	_, err := GetOrCreateCategories(rctx.Context, rctx.NutanixClient, categoryIdentifiers)
@@ -834,15 +2291,51 @@ func (r *NutanixMachineReconciler) getMachineCategoryIdentifiers(rctx *nctx.Mach
 		log.Error(err, "Failed to getOrCreateCategories")
 	}
 
-	additionalCategories := rctx.NutanixMachine.Spec.AdditionalCategories
-	if len(additionalCategories) > 0 {
-		for _, at := range additionalCategories {
-			additionalCat := at
-			categoryIdentifiers = append(categoryIdentifiers, &additionalCat)
+	return append(categoryIdentifiers, r.getAdditionalCategoryIdentifiers(rctx)...), nil
+}
+
+// getCAPIOwnerReference returns the owner reference to the CAPI object of the given kind, or nil if there is none
+func getCAPIOwnerReference(ownerReferences []metav1.OwnerReference, kind string) *metav1.OwnerReference {
+	for i := range ownerReferences {
+		ref := &ownerReferences[i]
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err == nil && gv.Group == capiv1.GroupVersion.Group && ref.Kind == kind {
+			return ref
 		}
 	}
+	return nil
+}
 
-	return categoryIdentifiers
+// getMachineRole returns the role of the Machine and the name of the MachineDeployment owning the Machine, derived
+// from the owner references of the Machine and its MachineSet. Machines owned by a control plane provider, e.g. a
+// KubeadmControlPlane, or carrying the control plane label are control plane Machines. The MachineDeployment is empty
+// if the Machine is not owned by a MachineDeployment.
+func (r *NutanixMachineReconciler) getMachineRole(rctx *nctx.MachineContext) (string, string, error) {
+	machine := rctx.Machine
+	if capiutil.IsControlPlaneMachine(machine) {
+		return infrav1.MachineRoleControlPlane, "", nil
+	}
+	for _, ref := range machine.OwnerReferences {
+		if strings.HasSuffix(ref.Kind, "ControlPlane") {
+			return infrav1.MachineRoleControlPlane, "", nil
+		}
+	}
+	machineSetRef := getCAPIOwnerReference(machine.OwnerReferences, "MachineSet")
+	if machineSetRef == nil {
+		return infrav1.MachineRoleWorker, "", nil
+	}
+	machineSet := &capiv1.MachineSet{}
+	if err := r.Client.Get(rctx.Context, client.ObjectKey{Namespace: machine.Namespace, Name: machineSetRef.Name}, machineSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			return infrav1.MachineRoleWorker, "", nil
+		}
+		return "", "", fmt.Errorf("failed to get MachineSet %s owning Machine %s: %v", machineSetRef.Name, machine.Name, err)
+	}
+	machineDeploymentRef := getCAPIOwnerReference(machineSet.OwnerReferences, "MachineDeployment")
+	if machineDeploymentRef == nil {
+		return infrav1.MachineRoleWorker, "", nil
+	}
+	return infrav1.MachineRoleWorker, machineDeploymentRef.Name, nil
 }
 
 func (r *NutanixMachineReconciler) addBootTypeToVM(rctx *nctx.MachineContext, vmSpec *nutanixClientV3.VM) error {
@@ -863,6 +2356,71 @@ func (r *NutanixMachineReconciler) addBootTypeToVM(rctx *nctx.MachineContext, vm
 		}
 	}
 
+	if secureBoot := rctx.NutanixMachine.Spec.SecureBoot; secureBoot != nil && *secureBoot {
+		if bootType != infrav1.NutanixBootTypeUEFI {
+			errorMsg := fmt.Errorf("secure boot requires the boot type %s but was %s", string(infrav1.NutanixBootTypeUEFI), bootType)
+			conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.VMBootTypeInvalid, capiv1.ConditionSeverityError, errorMsg.Error())
+			return errorMsg
+		}
+		// Secure boot is only supported by the Q35 machine type
+		vmSpec.Resources.BootConfig.BootType = utils.StringPtr(secureBootType)
+		vmSpec.Resources.MachineType = utils.StringPtr(secureBootMachineType)
+	}
+
+	return nil
+}
+
+// getSerialPortList returns the serial ports of the VM spec for the serial ports of the NutanixMachine
+func getSerialPortList(serialPorts []infrav1.NutanixSerialPort) []*nutanixClientV3.VMSerialPort {
+	if len(serialPorts) == 0 {
+		return nil
+	}
+	serialPortList := make([]*nutanixClientV3.VMSerialPort, 0, len(serialPorts))
+	for _, serialPort := range serialPorts {
+		serialPortList = append(serialPortList, &nutanixClientV3.VMSerialPort{
+			Index:       utils.Int64Ptr(serialPort.Index),
+			IsConnected: utils.BoolPtr(serialPort.IsConnected),
+		})
+	}
+	return serialPortList
+}
+
+// takeSeedDisk takes a seed disk of the key from the seed disk pool. Returns false if the pool is disabled or empty, or
+// the system disk is placed on a specific storage container.
+func (r *NutanixMachineReconciler) takeSeedDisk(rctx *nctx.MachineContext, key seedPoolKey) (seedDisk, bool) {
+	if r.seedDiskPool == nil || rctx.NutanixMachine.Spec.SystemDiskStorageContainer != nil {
+		return seedDisk{}, false
+	}
+	return r.seedDiskPool.Take(rctx.Context, rctx.NutanixClient, key)
+}
+
+// putBackSeedDisk returns a taken seed disk to the seed disk pool if the creation of the VM was not submitted. Otherwise
+// the seed disk was cloned, or may have been, and its seed VM is deleted.
+func (r *NutanixMachineReconciler) putBackSeedDisk(rctx *nctx.MachineContext, key seedPoolKey, seed seedDisk, submitted bool) {
+	if !submitted {
+		r.seedDiskPool.Return(key, seed)
+		return
+	}
+	if err := r.seedDiskPool.Release(rctx.Context, rctx.NutanixClient, seed); err != nil {
+		ctrl.LoggerFrom(rctx.Context).Error(err, fmt.Sprintf("failed to delete seed VM %s after cloning its seed disk", seed.vmUUID))
+	}
+}
+
+// addStorageContainerToSystemDisk places the system disk on the storage container of the NutanixMachine if one is set
+func (r *NutanixMachineReconciler) addStorageContainerToSystemDisk(rctx *nctx.MachineContext, systemDisk *nutanixClientV3.VMDisk) error {
+	storageContainer := rctx.NutanixMachine.Spec.SystemDiskStorageContainer
+	if storageContainer == nil {
+		return nil
+	}
+	if storageContainer.Type != infrav1.NutanixIdentifierUUID || storageContainer.UUID == nil {
+		return fmt.Errorf("storage container must be identified by uuid")
+	}
+	systemDisk.StorageConfig = &nutanixClientV3.VMStorageConfig{
+		StorageContainerReference: &nutanixClientV3.StorageContainerReference{
+			Kind: storageContainerKind,
+			UUID: *storageContainer.UUID,
+		},
+	}
 	return nil
 }
 
@@ -898,6 +2456,31 @@ func (r *NutanixMachineReconciler) addVMToProject(rctx *nctx.MachineContext, vmM
 	return nil
 }
 
+// setVMOwner sets the owner of the NutanixMachine in the metadata of the VM to create, so Prism Central attributes the
+// VM to this user instead of the user of the Prism Central credentials
+func (r *NutanixMachineReconciler) setVMOwner(rctx *nctx.MachineContext, vmMetadata *nutanixClientV3.Metadata) error {
+	log := ctrl.LoggerFrom(rctx.Context)
+	ownerRef := rctx.NutanixMachine.Spec.Owner
+	if ownerRef == nil {
+		return nil
+	}
+
+	ownerUUID, err := GetUserUUID(rctx.Context, rctx.NutanixClient, ownerRef.Name, ownerRef.UUID)
+	if err != nil {
+		errorMsg := fmt.Errorf("error occurred while searching for owner %s of VM %s: %v", getResourceIdentifierString(*ownerRef), rctx.Machine.Name, err)
+		log.Error(errorMsg, "error occurred while searching for owner")
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.OwnerAssignedCondition, infrav1.OwnerAssignationFailed, capiv1.ConditionSeverityError, errorMsg.Error())
+		return errorMsg
+	}
+
+	vmMetadata.OwnerReference = &nutanixClientV3.Reference{
+		Kind: utils.StringPtr(userKind),
+		UUID: utils.StringPtr(ownerUUID),
+	}
+	conditions.MarkTrue(rctx.NutanixMachine, infrav1.OwnerAssignedCondition)
+	return nil
+}
+
 func (r *NutanixMachineReconciler) isGetRemoteClientConnectionError(err error) bool {
 	// Check if error contains connection refused message. This can occur during provisioning when Kubernetes API is not available yet.
 	const expectedErrString = "connect: connection refused"
@@ -909,19 +2492,28 @@ func (r *NutanixMachineReconciler) GetSubnetAndPEUUIDs(rctx *nctx.MachineContext
 		return "", nil, fmt.Errorf("cannot create machine config if machine context is nil")
 	}
 	log := ctrl.LoggerFrom(rctx.Context)
+	if needsFailureDomainPlacement(rctx) {
+		failureDomain, err := r.selectFailureDomain(rctx)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to select failure domain for machine %s: %v", rctx.NutanixMachine.Name, err)
+		}
+		log.Info(fmt.Sprintf("no failure domain or cluster config found on machine. Placing machine in failure domain %s", failureDomain.Name))
+		annotations.AddAnnotations(rctx.NutanixMachine, map[string]string{infrav1.NutanixMachineFailureDomainAnnotation: failureDomain.Name})
+		return r.getSubnetAndPEUUIDsForFailureDomain(rctx, failureDomain)
+	}
 	if rctx.Machine.Spec.FailureDomain == nil || *rctx.Machine.Spec.FailureDomain == "" {
 		log.V(1).Info("no failure domain found on machine. Directly searching for Prism Element cluster")
 		if rctx.NutanixMachine.Spec.Cluster.Name == nil && rctx.NutanixMachine.Spec.Cluster.UUID == nil {
 			return "", nil, fmt.Errorf("cluster name or uuid must be passed if failure domain is not configured")
 		}
-		if len(rctx.NutanixMachine.Spec.Subnets) == 0 {
-			return "", nil, fmt.Errorf("subnets must be passed if failure domain is not configured")
+		if len(rctx.NutanixMachine.Spec.Subnets) == 0 && len(rctx.NutanixMachine.Spec.NICs) == 0 {
+			return "", nil, fmt.Errorf("subnets or nics must be passed if failure domain is not configured")
 		}
 		peUUID, err := GetPEUUID(rctx.Context, rctx.NutanixClient, rctx.NutanixMachine.Spec.Cluster.Name, rctx.NutanixMachine.Spec.Cluster.UUID)
 		if err != nil {
 			return "", nil, err
 		}
-		subnetUUIDs, err := GetSubnetUUIDList(rctx.Context, rctx.NutanixClient, rctx.NutanixMachine.Spec.Subnets, peUUID)
+		subnetUUIDs, err := GetSubnetUUIDList(rctx.Context, rctx.NutanixClient, machineSubnets(rctx.NutanixMachine, rctx.NutanixMachine.Spec.Subnets), peUUID)
 		if err != nil {
 			return "", nil, err
 		}
@@ -935,14 +2527,113 @@ func (r *NutanixMachineReconciler) GetSubnetAndPEUUIDs(rctx *nctx.MachineContext
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to find failure domain %s", failureDomainName)
 	}
+
+	return r.getSubnetAndPEUUIDsForFailureDomain(rctx, failureDomain)
+}
+
+func (r *NutanixMachineReconciler) getSubnetAndPEUUIDsForFailureDomain(rctx *nctx.MachineContext, failureDomain *infrav1.NutanixFailureDomainConfig) (string, []string, error) {
 	peUUID, err := GetPEUUID(rctx.Context, rctx.NutanixClient, failureDomain.Cluster.Name, failureDomain.Cluster.UUID)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to find prism element uuid for failure domain %s", failureDomainName)
+		return "", nil, fmt.Errorf("failed to find prism element uuid for failure domain %s", failureDomain.Name)
 	}
-	subnetUUIDs, err := GetSubnetUUIDList(rctx.Context, rctx.NutanixClient, failureDomain.Subnets, peUUID)
+	subnetUUIDs, err := GetSubnetUUIDList(rctx.Context, rctx.NutanixClient, machineSubnets(rctx.NutanixMachine, failureDomain.Subnets), peUUID)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to find subnet uuids for failure domain %s", failureDomainName)
+		return "", nil, fmt.Errorf("failed to find subnet uuids for failure domain %s", failureDomain.Name)
 	}
+	rctx.NutanixMachine.Status.FailureDomain = utils.StringPtr(failureDomain.Name)
 
 	return peUUID, subnetUUIDs, nil
 }
+
+// needsFailureDomainPlacement returns true if neither the Machine nor the NutanixMachine define where the VM must be
+// created, and the NutanixCluster has failure domains available to place the VM in.
+func needsFailureDomainPlacement(rctx *nctx.MachineContext) bool {
+	if rctx.Machine.Spec.FailureDomain != nil && *rctx.Machine.Spec.FailureDomain != "" {
+		return false
+	}
+	if rctx.NutanixMachine.Spec.Cluster.Name != nil || rctx.NutanixMachine.Spec.Cluster.UUID != nil || len(rctx.NutanixMachine.Spec.Subnets) > 0 {
+		return false
+	}
+	return rctx.NutanixCluster != nil && len(rctx.NutanixCluster.Spec.FailureDomains) > 0
+}
+
+// mergeMachineDefaults sets the fields of the NutanixMachine spec that are not set to the machine defaults of the
+// NutanixCluster. Values set on the NutanixMachine take precedence. The cluster and subnets are not merged for Machines
+// placed in a failure domain.
+func mergeMachineDefaults(rctx *nctx.MachineContext) {
+	if rctx.NutanixCluster == nil || rctx.NutanixCluster.Spec.MachineDefaults == nil {
+		return
+	}
+	machineDefaults := rctx.NutanixCluster.Spec.MachineDefaults
+	spec := &rctx.NutanixMachine.Spec
+	if isEmptyResourceIdentifier(spec.Image) && machineDefaults.Image != nil {
+		spec.Image = *machineDefaults.Image.DeepCopy()
+	}
+	if spec.Project == nil && machineDefaults.Project != nil {
+		spec.Project = machineDefaults.Project.DeepCopy()
+	}
+	if (rctx.Machine.Spec.FailureDomain != nil && *rctx.Machine.Spec.FailureDomain != "") || needsFailureDomainPlacement(rctx) {
+		return
+	}
+	if isEmptyResourceIdentifier(spec.Cluster) && machineDefaults.Cluster != nil {
+		spec.Cluster = *machineDefaults.Cluster.DeepCopy()
+	}
+	if len(spec.Subnets) == 0 && len(spec.NICs) == 0 && len(machineDefaults.Subnets) > 0 {
+		spec.Subnets = make([]infrav1.NutanixResourceIdentifier, 0, len(machineDefaults.Subnets))
+		for _, subnet := range machineDefaults.Subnets {
+			spec.Subnets = append(spec.Subnets, *subnet.DeepCopy())
+		}
+	}
+}
+
+// selectFailureDomain selects the failure domain for a machine based on the weights of the failure domains and the
+// placement of the other machines in the cluster. Control plane machines are only placed in failure domains suited for
+// control plane nodes. A failure domain recorded on the NutanixMachine by an earlier selection is preferred as long as
+// it is still eligible. Only machines without a failure domain on the Machine are placed, so the machines of a
+// KubeadmControlPlane, which always assigns a failure domain, are never placed by weight.
+func (r *NutanixMachineReconciler) selectFailureDomain(rctx *nctx.MachineContext) (*infrav1.NutanixFailureDomainConfig, error) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	isControlPlane := nctx.IsControlPlaneMachine(rctx.NutanixMachine)
+	eligibleFailureDomains := make([]infrav1.NutanixFailureDomainConfig, 0)
+	for _, fd := range rctx.NutanixCluster.Spec.FailureDomains {
+		if isControlPlane && !fd.ControlPlane {
+			continue
+		}
+		eligibleFailureDomains = append(eligibleFailureDomains, fd)
+	}
+	if len(eligibleFailureDomains) == 0 {
+		role := "worker"
+		if isControlPlane {
+			role = "control plane"
+		}
+		return nil, fmt.Errorf("no failure domains suited for %s nodes found on cluster %s", role, rctx.NutanixCluster.Name)
+	}
+
+	if recordedFailureDomain := rctx.NutanixMachine.GetAnnotations()[infrav1.NutanixMachineFailureDomainAnnotation]; recordedFailureDomain != "" {
+		for i := range eligibleFailureDomains {
+			if eligibleFailureDomains[i].Name == recordedFailureDomain {
+				log.V(1).Info(fmt.Sprintf("using failure domain %s recorded on machine %s", recordedFailureDomain, rctx.NutanixMachine.Name))
+				return &eligibleFailureDomains[i], nil
+			}
+		}
+		log.Info(fmt.Sprintf("failure domain %s recorded on machine %s is no longer available. Selecting a new failure domain", recordedFailureDomain, rctx.NutanixMachine.Name))
+	}
+
+	machineList := &infrav1.NutanixMachineList{}
+	err := r.List(rctx.Context, machineList,
+		client.InNamespace(rctx.NutanixMachine.Namespace),
+		client.MatchingLabels{capiv1.ClusterLabelName: rctx.Cluster.Name})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list machines of cluster %s: %v", rctx.Cluster.Name, err)
+	}
+	placedMachines := make(map[string]int)
+	for i := range machineList.Items {
+		m := &machineList.Items[i]
+		if m.Name == rctx.NutanixMachine.Name || m.Status.FailureDomain == nil || nctx.IsControlPlaneMachine(m) != isControlPlane {
+			continue
+		}
+		placedMachines[*m.Status.FailureDomain]++
+	}
+
+	return SelectFailureDomain(eligibleFailureDomains, placedMachines)
+}
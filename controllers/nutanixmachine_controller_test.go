@@ -18,18 +18,40 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
 	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
 	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+	prismgoclient "github.com/nutanix-cloud-native/prism-go-client"
 	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 )
@@ -212,7 +234,7 @@ func TestNutanixMachineReconciler(t *testing.T) {
 			})
 			It("should error if machine and nutanixCluster have failure domain and but nutanixClient is nil", func() {
 				machine.Spec.FailureDomain = &r
-				ntnxCluster.Spec.FailureDomains = []infrav1.NutanixFailureDomain{
+				ntnxCluster.Spec.FailureDomains = []infrav1.NutanixFailureDomainConfig{
 					{
 						Name: r,
 					},
@@ -228,3 +250,3071 @@ func TestNutanixMachineReconciler(t *testing.T) {
 		})
 	})
 }
+
+func TestNutanixMachineSelectFailureDomain(t *testing.T) {
+	scheme := runtime.NewScheme()
+	NewWithT(t).Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	newMachineContext := func(recordedFailureDomain string) *nctx.MachineContext {
+		ntnxMachine := &infrav1.NutanixMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+		}
+		if recordedFailureDomain != "" {
+			ntnxMachine.Annotations = map[string]string{infrav1.NutanixMachineFailureDomainAnnotation: recordedFailureDomain}
+		}
+		return &nctx.MachineContext{
+			Context: context.Background(),
+			Cluster: &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+			Machine: &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+			NutanixCluster: &infrav1.NutanixCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: infrav1.NutanixClusterSpec{
+					FailureDomains: []infrav1.NutanixFailureDomainConfig{
+						{Name: "fd-1", Weight: pointer.Int32(1)},
+						{Name: "fd-2", Weight: pointer.Int32(5)},
+					},
+				},
+			},
+			NutanixMachine: ntnxMachine,
+		}
+	}
+
+	tests := []struct {
+		name                  string
+		recordedFailureDomain string
+		expectedFailureDomain string
+	}{
+		{
+			name:                  "selects failure domain by weight without recorded failure domain",
+			expectedFailureDomain: "fd-2",
+		},
+		{
+			name:                  "prefers recorded failure domain",
+			recordedFailureDomain: "fd-1",
+			expectedFailureDomain: "fd-1",
+		},
+		{
+			name:                  "reselects if recorded failure domain was removed",
+			recordedFailureDomain: "fd-removed",
+			expectedFailureDomain: "fd-2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			reconciler := &NutanixMachineReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+			}
+			fd, err := reconciler.selectFailureDomain(newMachineContext(tt.recordedFailureDomain))
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(fd.Name).To(Equal(tt.expectedFailureDomain))
+		})
+	}
+}
+
+func TestNutanixMachineFailureDomainOfControlPlaneMachine(t *testing.T) {
+	g := NewWithT(t)
+	fd := func(name string, weight int32) infrav1.NutanixFailureDomainConfig {
+		return infrav1.NutanixFailureDomainConfig{
+			Name:         name,
+			Cluster:      infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("pe")},
+			Subnets:      []infrav1.NutanixResourceIdentifier{{Type: infrav1.NutanixIdentifierName, Name: pointer.String("subnet")}},
+			ControlPlane: true,
+			Weight:       pointer.Int32(weight),
+		}
+	}
+	// The KubeadmControlPlane assigns the failure domain with the fewest control plane machines to its Machines
+	machine := &capiv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			Labels:    map[string]string{capiv1.MachineControlPlaneLabelName: ""},
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "controlplane.cluster.x-k8s.io/v1beta1", Kind: "KubeadmControlPlane", Name: "test", Controller: pointer.Bool(true)},
+			},
+		},
+		Spec: capiv1.MachineSpec{FailureDomain: pointer.String("fd-1")},
+	}
+	rctx := &nctx.MachineContext{
+		Context: context.Background(),
+		Cluster: &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+		Machine: machine,
+		NutanixCluster: &infrav1.NutanixCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: infrav1.NutanixClusterSpec{
+				FailureDomains: []infrav1.NutanixFailureDomainConfig{fd("fd-1", 1), fd("fd-2", 100)},
+			},
+		},
+		NutanixMachine: &infrav1.NutanixMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", Labels: map[string]string{capiv1.MachineControlPlaneLabelName: ""}},
+		},
+		NutanixClient: &nutanixClientV3.Client{V3: &fakeLookupService{}},
+	}
+	g.Expect(needsFailureDomainPlacement(rctx)).To(BeFalse())
+
+	// The failure domain of the KubeadmControlPlane is used regardless of the weights
+	reconciler := &NutanixMachineReconciler{}
+	peUUID, subnetUUIDs, err := reconciler.GetSubnetAndPEUUIDs(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(peUUID).To(Equal(testPEUUID))
+	g.Expect(subnetUUIDs).To(ConsistOf(testSubnetUUID))
+	g.Expect(rctx.NutanixMachine.Status.FailureDomain).To(HaveValue(Equal("fd-1")))
+	g.Expect(rctx.NutanixMachine.Annotations).NotTo(HaveKey(infrav1.NutanixMachineFailureDomainAnnotation))
+}
+
+func TestNutanixMachineSelectFailureDomainNoneEligible(t *testing.T) {
+	g := NewWithT(t)
+	reconciler := &NutanixMachineReconciler{}
+	newMachineContext := func(labels map[string]string, failureDomains []infrav1.NutanixFailureDomainConfig) *nctx.MachineContext {
+		return &nctx.MachineContext{
+			Context:        context.Background(),
+			NutanixCluster: &infrav1.NutanixCluster{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Spec: infrav1.NutanixClusterSpec{FailureDomains: failureDomains}},
+			NutanixMachine: &infrav1.NutanixMachine{ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: labels}},
+		}
+	}
+
+	_, err := reconciler.selectFailureDomain(newMachineContext(map[string]string{capiv1.MachineControlPlaneLabelName: ""}, []infrav1.NutanixFailureDomainConfig{{Name: "fd-1"}}))
+	g.Expect(err).To(MatchError("no failure domains suited for control plane nodes found on cluster test"))
+
+	_, err = reconciler.selectFailureDomain(newMachineContext(nil, nil))
+	g.Expect(err).To(MatchError("no failure domains suited for worker nodes found on cluster test"))
+}
+
+func TestMergeMachineDefaults(t *testing.T) {
+	defaultImage := infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("default-image")}
+	defaultCluster := infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("default-pe")}
+	defaultSubnet := infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("default-subnet")}
+	defaultProject := infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("default-project")}
+	machineImage := infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: pointer.String(testImageUUID)}
+	machineCluster := infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("pe")}
+	machineSubnet := infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("subnet")}
+	machineProject := infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("project")}
+
+	tests := []struct {
+		name           string
+		spec           infrav1.NutanixMachineSpec
+		failureDomain  *string
+		failureDomains []infrav1.NutanixFailureDomainConfig
+		expectedSpec   infrav1.NutanixMachineSpec
+	}{
+		{
+			name: "merges defaults into unset fields",
+			expectedSpec: infrav1.NutanixMachineSpec{
+				Image:   defaultImage,
+				Cluster: defaultCluster,
+				Subnets: []infrav1.NutanixResourceIdentifier{defaultSubnet},
+				Project: &defaultProject,
+			},
+		},
+		{
+			name: "keeps machine values",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   machineImage,
+				Cluster: machineCluster,
+				Subnets: []infrav1.NutanixResourceIdentifier{machineSubnet},
+				Project: &machineProject,
+			},
+			expectedSpec: infrav1.NutanixMachineSpec{
+				Image:   machineImage,
+				Cluster: machineCluster,
+				Subnets: []infrav1.NutanixResourceIdentifier{machineSubnet},
+				Project: &machineProject,
+			},
+		},
+		{
+			name: "merges defaults next to machine values",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   machineImage,
+				Subnets: []infrav1.NutanixResourceIdentifier{machineSubnet},
+			},
+			expectedSpec: infrav1.NutanixMachineSpec{
+				Image:   machineImage,
+				Cluster: defaultCluster,
+				Subnets: []infrav1.NutanixResourceIdentifier{machineSubnet},
+				Project: &defaultProject,
+			},
+		},
+		{
+			name: "does not merge subnets if nics are set",
+			spec: infrav1.NutanixMachineSpec{
+				NICs: []infrav1.NutanixMachineNIC{{Subnet: machineSubnet}},
+			},
+			expectedSpec: infrav1.NutanixMachineSpec{
+				Image:   defaultImage,
+				Cluster: defaultCluster,
+				NICs:    []infrav1.NutanixMachineNIC{{Subnet: machineSubnet}},
+				Project: &defaultProject,
+			},
+		},
+		{
+			name:          "does not merge cluster and subnets for machines in a failure domain",
+			failureDomain: pointer.String("fd-1"),
+			expectedSpec: infrav1.NutanixMachineSpec{
+				Image:   defaultImage,
+				Project: &defaultProject,
+			},
+		},
+		{
+			name:           "does not merge cluster and subnets for machines placed in a failure domain",
+			failureDomains: []infrav1.NutanixFailureDomainConfig{{Name: "fd-1"}},
+			expectedSpec: infrav1.NutanixMachineSpec{
+				Image:   defaultImage,
+				Project: &defaultProject,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			rctx := &nctx.MachineContext{
+				Context: context.Background(),
+				Machine: &capiv1.Machine{Spec: capiv1.MachineSpec{FailureDomain: tt.failureDomain}},
+				NutanixCluster: &infrav1.NutanixCluster{
+					Spec: infrav1.NutanixClusterSpec{
+						FailureDomains: tt.failureDomains,
+						MachineDefaults: &infrav1.NutanixMachineDefaults{
+							Image:   &defaultImage,
+							Cluster: &defaultCluster,
+							Subnets: []infrav1.NutanixResourceIdentifier{defaultSubnet},
+							Project: &defaultProject,
+						},
+					},
+				},
+				NutanixMachine: &infrav1.NutanixMachine{Spec: tt.spec},
+			}
+			mergeMachineDefaults(rctx)
+			g.Expect(rctx.NutanixMachine.Spec).To(Equal(tt.expectedSpec))
+		})
+	}
+}
+
+// vmShutdownTestService is a Prism v3 service recording the VM updates requesting a shutdown
+type vmShutdownTestService struct {
+	nutanixClientV3.Service
+	updates []*nutanixClientV3.VMIntentInput
+}
+
+func (s *vmShutdownTestService) UpdateVM(_ context.Context, _ string, body *nutanixClientV3.VMIntentInput) (*nutanixClientV3.VMIntentResponse, error) {
+	s.updates = append(s.updates, body)
+	return &nutanixClientV3.VMIntentResponse{
+		Metadata: body.Metadata,
+		Spec:     body.Spec,
+		Status: &nutanixClientV3.VMDefStatus{
+			ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "shutdown-task"},
+		},
+	}, nil
+}
+
+func TestNutanixMachineReconcileVMShutdown(t *testing.T) {
+	gracePeriod := 5 * time.Minute
+	// The grace period is measured on the clock of the reconciler, not the wall clock
+	fakeClock := clocktesting.NewFakeClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name                 string
+		powerState           string
+		shutdownRequestedAgo time.Duration
+		expectedShutDown     bool
+		expectedUpdate       bool
+	}{
+		{
+			name:           "requests guest shutdown of running VM",
+			powerState:     "ON",
+			expectedUpdate: true,
+		},
+		{
+			name:                 "waits for VM to power off within grace period",
+			powerState:           "ON",
+			shutdownRequestedAgo: time.Minute,
+		},
+		{
+			name:                 "continues with delete once VM is powered off",
+			powerState:           "OFF",
+			shutdownRequestedAgo: time.Minute,
+			expectedShutDown:     true,
+		},
+		{
+			name:                 "forces delete after grace period",
+			powerState:           "ON",
+			shutdownRequestedAgo: 2 * gracePeriod,
+			expectedShutDown:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+			}
+			if tt.shutdownRequestedAgo > 0 {
+				ntnxMachine.Annotations = map[string]string{
+					infrav1.NutanixMachineShutdownRequestedAnnotation: fakeClock.Now().Add(-tt.shutdownRequestedAgo).UTC().Format(time.RFC3339),
+				}
+			}
+			vm := &nutanixClientV3.VMIntentResponse{
+				Metadata: &nutanixClientV3.Metadata{UUID: pointer.String("vm-uuid")},
+				Spec: &nutanixClientV3.VM{
+					Name:      pointer.String("test"),
+					Resources: &nutanixClientV3.VMResources{PowerState: pointer.String("ON")},
+				},
+				Status: &nutanixClientV3.VMDefStatus{
+					Resources: &nutanixClientV3.VMResourcesDefStatus{PowerState: pointer.String(tt.powerState)},
+				},
+			}
+			service := &vmShutdownTestService{}
+			reconciler := &NutanixMachineReconciler{
+				controllerConfig: &ControllerConfig{VMShutdownGracePeriod: gracePeriod},
+				clock:            fakeClock,
+			}
+			shutDown, err := reconciler.reconcileVMShutdown(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+				NutanixMachine: ntnxMachine,
+			}, vm)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(shutDown).To(Equal(tt.expectedShutDown))
+			if !tt.expectedUpdate {
+				g.Expect(service.updates).To(BeEmpty())
+				return
+			}
+			g.Expect(service.updates).To(HaveLen(1))
+			g.Expect(*service.updates[0].Spec.Resources.PowerState).To(Equal("OFF"))
+			g.Expect(*service.updates[0].Spec.Resources.PowerStateMechanism.Mechanism).To(Equal("ACPI"))
+			g.Expect(ntnxMachine.GetAnnotations()).To(HaveKeyWithValue(infrav1.NutanixMachineShutdownRequestedAnnotation, fakeClock.Now().UTC().Format(time.RFC3339)))
+		})
+	}
+}
+
+// volumeGroupTestService is a Prism v3 service holding volume groups whose attachments are updated synchronously
+type volumeGroupTestService struct {
+	nutanixClientV3.Service
+	vgs     map[string]*nutanixClientV3.VolumeGroupResponse
+	updates int
+}
+
+func newVolumeGroupTestService(names ...string) *volumeGroupTestService {
+	s := &volumeGroupTestService{vgs: make(map[string]*nutanixClientV3.VolumeGroupResponse)}
+	for _, name := range names {
+		uuid := name + "-uuid"
+		s.vgs[uuid] = &nutanixClientV3.VolumeGroupResponse{
+			Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(uuid)},
+			Spec: &nutanixClientV3.VolumeGroup{
+				Name:      pointer.String(name),
+				Resources: &nutanixClientV3.VolumeGroupResources{},
+			},
+			Status: &nutanixClientV3.VolumeGroupDefStatus{
+				State:     pointer.String("COMPLETE"),
+				Resources: &nutanixClientV3.VolumeGroupResources{},
+			},
+		}
+	}
+	return s
+}
+
+func (s *volumeGroupTestService) GetVolumeGroup(_ context.Context, uuid string) (*nutanixClientV3.VolumeGroupResponse, error) {
+	vg, ok := s.vgs[uuid]
+	if !ok {
+		return nil, fmt.Errorf("ENTITY_NOT_FOUND: volume group %s", uuid)
+	}
+	return vg, nil
+}
+
+func (s *volumeGroupTestService) ListVolumeGroup(_ context.Context, getEntitiesRequest *nutanixClientV3.DSMetadata) (*nutanixClientV3.VolumeGroupListResponse, error) {
+	entities := make([]*nutanixClientV3.VolumeGroupResponse, 0)
+	for _, vg := range s.vgs {
+		if getFilterForName(*vg.Spec.Name) == *getEntitiesRequest.Filter {
+			entities = append(entities, vg)
+		}
+	}
+	return &nutanixClientV3.VolumeGroupListResponse{Entities: entities}, nil
+}
+
+func (s *volumeGroupTestService) UpdateVolumeGroup(_ context.Context, uuid string, body *nutanixClientV3.VolumeGroupInput) (*nutanixClientV3.VolumeGroupResponse, error) {
+	s.updates++
+	vg := s.vgs[uuid]
+	vg.Spec = body.Spec
+	vg.Status.Resources = &nutanixClientV3.VolumeGroupResources{
+		AttachmentList: append([]*nutanixClientV3.VMAttachment{}, body.Spec.Resources.AttachmentList...),
+	}
+	return vg, nil
+}
+
+func TestNutanixMachineAttachAndDetachVolumeGroups(t *testing.T) {
+	g := NewWithT(t)
+	vmUUID := "vm-uuid"
+	service := newVolumeGroupTestService("vg-1", "vg-2")
+	ntnxMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: infrav1.NutanixMachineSpec{
+			VolumeGroups: []infrav1.NutanixResourceIdentifier{
+				{Type: infrav1.NutanixIdentifierName, Name: pointer.String("vg-1")},
+				{Type: infrav1.NutanixIdentifierUUID, UUID: pointer.String("vg-2-uuid")},
+			},
+		},
+		Status: infrav1.NutanixMachineStatus{
+			VmUUID: vmUUID,
+		},
+	}
+	rctx := &nctx.MachineContext{
+		Context:        context.Background(),
+		NutanixClient:  &nutanixClientV3.Client{V3: service},
+		NutanixMachine: ntnxMachine,
+	}
+	reconciler := &NutanixMachineReconciler{}
+
+	g.Expect(reconciler.attachVolumeGroups(rctx)).To(Succeed())
+	g.Expect(service.updates).To(Equal(2))
+	for _, vg := range service.vgs {
+		g.Expect(nutanixClient.IsVMAttachedToVolumeGroup(vg.Status.Resources, vmUUID)).To(BeTrue())
+	}
+	g.Expect(conditions.IsTrue(ntnxMachine, infrav1.VolumeGroupsAttachedCondition)).To(BeTrue())
+
+	// Volume groups the VM is attached to already are not updated again
+	g.Expect(reconciler.attachVolumeGroups(rctx)).To(Succeed())
+	g.Expect(service.updates).To(Equal(2))
+
+	// Volume groups that no longer exist are skipped on detach
+	delete(service.vgs, "vg-2-uuid")
+	g.Expect(reconciler.detachVolumeGroups(rctx, vmUUID)).To(Succeed())
+	g.Expect(service.updates).To(Equal(3))
+	g.Expect(nutanixClient.IsVMAttachedToVolumeGroup(service.vgs["vg-1-uuid"].Status.Resources, vmUUID)).To(BeFalse())
+
+	// Attaching a volume group that does not exist fails
+	g.Expect(reconciler.attachVolumeGroups(rctx)).NotTo(Succeed())
+}
+
+// imageTestService returns the image states in order, repeating the last state once all states were returned
+type imageTestService struct {
+	nutanixClientV3.Service
+	states []string
+	calls  int
+}
+
+func (s *imageTestService) GetImage(_ context.Context, uuid string) (*nutanixClientV3.ImageIntentResponse, error) {
+	state := s.states[len(s.states)-1]
+	if s.calls < len(s.states) {
+		state = s.states[s.calls]
+	}
+	s.calls++
+	return &nutanixClientV3.ImageIntentResponse{
+		Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(uuid)},
+		Status:   &nutanixClientV3.ImageDefStatus{State: pointer.String(state)},
+	}, nil
+}
+
+func TestNutanixMachineCheckImageReady(t *testing.T) {
+	const timeout = 10 * time.Minute
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	waitingCondition := func(waited time.Duration) *capiv1.Condition {
+		return &capiv1.Condition{
+			Type:               infrav1.ImageReadyCondition,
+			Status:             corev1.ConditionFalse,
+			Severity:           capiv1.ConditionSeverityInfo,
+			Reason:             infrav1.WaitingForImage,
+			Message:            "waiting for image with UUID image-uuid to become ready",
+			LastTransitionTime: metav1.NewTime(fakeClock.Now().Add(-waited)),
+		}
+	}
+
+	tests := []struct {
+		name           string
+		state          string
+		condition      *capiv1.Condition
+		expectReady    bool
+		expectError    bool
+		expectedReason string
+		expectedEvents int
+	}{
+		{
+			name:        "image is ready",
+			state:       "COMPLETE",
+			condition:   waitingCondition(time.Minute),
+			expectReady: true,
+		},
+		{
+			name:           "starts waiting for image that is not ready",
+			state:          "INACTIVE",
+			expectedReason: infrav1.WaitingForImage,
+			expectedEvents: 1,
+		},
+		{
+			name:           "keeps waiting for image within timeout",
+			state:          "INACTIVE",
+			condition:      waitingCondition(time.Minute),
+			expectedReason: infrav1.WaitingForImage,
+			expectedEvents: 1,
+		},
+		{
+			name:           "image does not become ready before timeout",
+			state:          "INACTIVE",
+			condition:      waitingCondition(2 * timeout),
+			expectError:    true,
+			expectedReason: infrav1.ImageReadyTimeout,
+		},
+		{
+			name:           "image in error state",
+			state:          "ERROR",
+			condition:      waitingCondition(time.Minute),
+			expectError:    true,
+			expectedReason: infrav1.ImageReadyFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+			}
+			if tt.condition != nil {
+				conditions.Set(ntnxMachine, tt.condition)
+			}
+			service := &imageTestService{states: []string{tt.state}}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixMachineReconciler{
+				Recorder:         recorder,
+				controllerConfig: &ControllerConfig{ImageReadyTimeout: timeout},
+				clock:            fakeClock,
+			}
+			ready, err := reconciler.checkImageReady(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+				NutanixMachine: ntnxMachine,
+			}, "image-uuid")
+			// The state of the image is checked once without blocking
+			g.Expect(service.calls).To(Equal(1))
+			g.Expect(ready).To(Equal(tt.expectReady))
+			g.Expect(err != nil).To(Equal(tt.expectError))
+			g.Expect(recorder.Events).To(HaveLen(tt.expectedEvents))
+			for i := 0; i < tt.expectedEvents; i++ {
+				g.Expect(<-recorder.Events).To(HavePrefix(corev1.EventTypeNormal + " " + imageNotReadyEventReason))
+			}
+			if tt.expectReady {
+				g.Expect(conditions.IsTrue(ntnxMachine, infrav1.ImageReadyCondition)).To(BeTrue())
+				return
+			}
+			g.Expect(conditions.IsFalse(ntnxMachine, infrav1.ImageReadyCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(ntnxMachine, infrav1.ImageReadyCondition)).To(Equal(tt.expectedReason))
+			if tt.condition != nil && tt.expectedReason == infrav1.WaitingForImage {
+				// The time the NutanixMachine started waiting for the image is kept
+				g.Expect(conditions.GetLastTransitionTime(ntnxMachine, infrav1.ImageReadyCondition).Time).To(BeTemporally("~", tt.condition.LastTransitionTime.Time, time.Second))
+			}
+		})
+	}
+
+	// The timeout is kept until the image becomes ready
+	g := NewWithT(t)
+	ntnxMachine := &infrav1.NutanixMachine{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	conditions.MarkFalse(ntnxMachine, infrav1.ImageReadyCondition, infrav1.ImageReadyTimeout, capiv1.ConditionSeverityWarning, "timed out")
+	service := &imageTestService{states: []string{"INACTIVE", "ACTIVE"}}
+	reconciler := &NutanixMachineReconciler{
+		Recorder:         record.NewFakeRecorder(10),
+		controllerConfig: &ControllerConfig{ImageReadyTimeout: timeout},
+		clock:            fakeClock,
+	}
+	rctx := &nctx.MachineContext{
+		Context:        context.Background(),
+		NutanixClient:  &nutanixClientV3.Client{V3: service},
+		NutanixMachine: ntnxMachine,
+	}
+	_, err := reconciler.checkImageReady(rctx, "image-uuid")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(conditions.GetReason(ntnxMachine, infrav1.ImageReadyCondition)).To(Equal(infrav1.ImageReadyTimeout))
+	ready, err := reconciler.checkImageReady(rctx, "image-uuid")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+	g.Expect(conditions.IsTrue(ntnxMachine, infrav1.ImageReadyCondition)).To(BeTrue())
+}
+
+// creationTaskTestService returns a task in the given state that was created at the given time, or the error if set
+type creationTaskTestService struct {
+	nutanixClientV3.Service
+	status       string
+	errorDetail  string
+	creationTime time.Time
+	err          error
+	deletedVMs   []string
+}
+
+func (s *creationTaskTestService) GetTask(_ context.Context, _ string) (*nutanixClientV3.TasksResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &nutanixClientV3.TasksResponse{
+		Status:       pointer.String(s.status),
+		ErrorDetail:  pointer.String(s.errorDetail),
+		CreationTime: &s.creationTime,
+	}, nil
+}
+
+func (s *creationTaskTestService) DeleteVM(_ context.Context, uuid string) (*nutanixClientV3.DeleteResponse, error) {
+	s.deletedVMs = append(s.deletedVMs, uuid)
+	return &nutanixClientV3.DeleteResponse{Status: &nutanixClientV3.DeleteStatus{ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "delete-task"}}}, nil
+}
+
+func TestNutanixMachineWaitForVMCreationTask(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          string
+		err             error
+		taskAge         time.Duration
+		taskTimeout     time.Duration
+		expectErr       bool
+		expectedFailure string
+	}{
+		{
+			name:        "task succeeded",
+			status:      "SUCCEEDED",
+			taskAge:     time.Hour,
+			taskTimeout: time.Minute,
+		},
+		{
+			name:            "task running longer than the timeout",
+			status:          "RUNNING",
+			taskAge:         time.Hour,
+			taskTimeout:     time.Minute,
+			expectErr:       true,
+			expectedFailure: "did not succeed within 1m0s",
+		},
+		{
+			name:            "task failed",
+			status:          "FAILED",
+			taskAge:         time.Second,
+			taskTimeout:     time.Minute,
+			expectErr:       true,
+			expectedFailure: "creation task task-uuid of VM test-machine failed",
+		},
+		{
+			name:        "Prism Central unavailable",
+			err:         errors.New("status: 503 Service Unavailable"),
+			taskTimeout: time.Minute,
+			expectErr:   true,
+		},
+		{
+			name:        "Prism Central not reachable",
+			err:         errors.New("dial tcp 10.0.0.1:9440: connect: connection refused"),
+			taskTimeout: time.Minute,
+			expectErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxMachine := &infrav1.NutanixMachine{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+			reconciler := &NutanixMachineReconciler{
+				controllerConfig: &ControllerConfig{VMTaskTimeout: tt.taskTimeout},
+				taskPollInterval: time.Millisecond,
+			}
+			service := &creationTaskTestService{status: tt.status, creationTime: time.Now().Add(-tt.taskAge), err: tt.err}
+			err := reconciler.waitForVMCreationTask(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"}},
+				NutanixMachine: ntnxMachine,
+			}, "task-uuid")
+			if !tt.expectErr {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(ntnxMachine.Status.FailureReason).To(BeNil())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			if tt.expectedFailure == "" {
+				// Transient errors are retried without failing the NutanixMachine
+				g.Expect(ntnxMachine.Status.FailureReason).To(BeNil())
+				g.Expect(ntnxMachine.Status.FailureMessage).To(BeNil())
+				g.Expect(conditions.Has(ntnxMachine, infrav1.VMProvisionedCondition)).To(BeFalse())
+				return
+			}
+			// The NutanixMachine is failed, so the Machine is remediated by a MachineHealthCheck
+			g.Expect(ntnxMachine.Status.FailureReason).NotTo(BeNil())
+			g.Expect(*ntnxMachine.Status.FailureReason).To(Equal(capierrors.CreateMachineError))
+			g.Expect(ntnxMachine.Status.FailureMessage).NotTo(BeNil())
+			g.Expect(*ntnxMachine.Status.FailureMessage).To(ContainSubstring(tt.expectedFailure))
+			g.Expect(conditions.IsFalse(ntnxMachine, infrav1.VMProvisionedCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(ntnxMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.VMProvisioningFailed))
+			g.Expect(*conditions.GetSeverity(ntnxMachine, infrav1.VMProvisionedCondition)).To(Equal(capiv1.ConditionSeverityError))
+
+			// A failed NutanixMachine is no longer reconciled
+			result, err := reconciler.reconcileNormal(&nctx.MachineContext{Context: context.Background(), NutanixMachine: ntnxMachine})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result).To(Equal(reconcile.Result{}))
+		})
+	}
+}
+
+func TestNutanixMachineWaitForVMCreationTaskQuotaExceeded(t *testing.T) {
+	g := NewWithT(t)
+	ntnxMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       infrav1.NutanixMachineSpec{ProviderID: GenerateProviderID("vm-uuid")},
+		Status:     infrav1.NutanixMachineStatus{VmUUID: "vm-uuid"},
+	}
+	reconciler := &NutanixMachineReconciler{
+		controllerConfig: &ControllerConfig{VMTaskTimeout: time.Minute},
+		taskPollInterval: time.Millisecond,
+	}
+	service := &creationTaskTestService{
+		status:       "FAILED",
+		errorDetail:  "Project default quota exceeded: requested 4 vCPUs but only 2 vCPUs are available",
+		creationTime: time.Now(),
+	}
+	err := reconciler.waitForVMCreationTask(&nctx.MachineContext{
+		Context:        context.Background(),
+		NutanixClient:  &nutanixClientV3.Client{V3: service},
+		Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"}},
+		NutanixMachine: ntnxMachine,
+	}, "task-uuid")
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(isQuotaExceededError(err)).To(BeTrue())
+
+	// The NutanixMachine is not failed, and the failed VM is discarded so a new VM is created once retried
+	g.Expect(ntnxMachine.Status.FailureReason).To(BeNil())
+	g.Expect(ntnxMachine.Status.FailureMessage).To(BeNil())
+	g.Expect(service.deletedVMs).To(ConsistOf("vm-uuid"))
+	g.Expect(ntnxMachine.Status.VmUUID).To(BeEmpty())
+	g.Expect(ntnxMachine.Spec.ProviderID).To(BeEmpty())
+	g.Expect(conditions.IsFalse(ntnxMachine, infrav1.VMProvisionedCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(ntnxMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.QuotaExceeded))
+	g.Expect(*conditions.GetSeverity(ntnxMachine, infrav1.VMProvisionedCondition)).To(Equal(capiv1.ConditionSeverityWarning))
+	g.Expect(conditions.GetMessage(ntnxMachine, infrav1.VMProvisionedCondition)).To(ContainSubstring("Project default quota exceeded"))
+}
+
+func TestNutanixMachineAddStorageContainerToSystemDisk(t *testing.T) {
+	const storageContainerUUID = "00000000-0000-0000-0000-000000000010"
+	tests := []struct {
+		name             string
+		storageContainer *infrav1.NutanixResourceIdentifier
+		expectedUUID     string
+		expectError      bool
+	}{
+		{
+			name: "storage container referenced by uuid",
+			storageContainer: &infrav1.NutanixResourceIdentifier{
+				Type: infrav1.NutanixIdentifierUUID,
+				UUID: pointer.String(storageContainerUUID),
+			},
+			expectedUUID: storageContainerUUID,
+		},
+		{
+			name: "storage container referenced by name",
+			storageContainer: &infrav1.NutanixResourceIdentifier{
+				Type: infrav1.NutanixIdentifierName,
+				Name: pointer.String("ssd"),
+			},
+			expectError: true,
+		},
+		{
+			name: "no storage container",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: infrav1.NutanixMachineSpec{
+					SystemDiskStorageContainer: tt.storageContainer,
+				},
+			}
+			systemDisk := &nutanixClientV3.VMDisk{}
+			reconciler := &NutanixMachineReconciler{}
+			err := reconciler.addStorageContainerToSystemDisk(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixMachine: ntnxMachine,
+			}, systemDisk)
+			if tt.expectError {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			if tt.expectedUUID == "" {
+				g.Expect(systemDisk.StorageConfig).To(BeNil())
+				return
+			}
+			g.Expect(systemDisk.StorageConfig).NotTo(BeNil())
+			g.Expect(systemDisk.StorageConfig.StorageContainerReference.Kind).To(Equal(storageContainerKind))
+			g.Expect(systemDisk.StorageConfig.StorageContainerReference.UUID).To(Equal(tt.expectedUUID))
+		})
+	}
+}
+
+// existingVMTestService returns the VMs with the given names
+type existingVMTestService struct {
+	nutanixClientV3.Service
+	vms []*nutanixClientV3.VMIntentResponse
+}
+
+func (s *existingVMTestService) ListVM(_ context.Context, getEntitiesRequest *nutanixClientV3.DSMetadata) (*nutanixClientV3.VMListIntentResponse, error) {
+	entities := make([]*nutanixClientV3.VMIntentResource, 0)
+	for _, vm := range s.vms {
+		if *getEntitiesRequest.Filter == fmt.Sprintf("vm_name==%s", *vm.Spec.Name) {
+			entities = append(entities, &nutanixClientV3.VMIntentResource{Metadata: vm.Metadata, Spec: vm.Spec})
+		}
+	}
+	return &nutanixClientV3.VMListIntentResponse{Entities: entities}, nil
+}
+
+func (s *existingVMTestService) GetVM(_ context.Context, uuid string) (*nutanixClientV3.VMIntentResponse, error) {
+	for _, vm := range s.vms {
+		if *vm.Metadata.UUID == uuid {
+			return vm, nil
+		}
+	}
+	return nil, fmt.Errorf("ENTITY_NOT_FOUND: VM %s not found", uuid)
+}
+
+func TestNutanixMachineSetVMOwner(t *testing.T) {
+	tests := []struct {
+		name              string
+		owner             *infrav1.NutanixResourceIdentifier
+		expectedOwnerUUID string
+		expectError       bool
+	}{
+		{
+			name:              "owner resolved by name",
+			owner:             &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("admin@example.com")},
+			expectedOwnerUUID: testUserUUID,
+		},
+		{
+			name:              "owner resolved by name in status",
+			owner:             &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("operator")},
+			expectedOwnerUUID: "00000000-0000-0000-0000-000000000043",
+		},
+		{
+			name:              "owner resolved by uuid",
+			owner:             &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: pointer.String(testUserUUID)},
+			expectedOwnerUUID: testUserUUID,
+		},
+		{
+			name:        "owner not found",
+			owner:       &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("guest@example.com")},
+			expectError: true,
+		},
+		{
+			name: "no owner",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxMachine := &infrav1.NutanixMachine{Spec: infrav1.NutanixMachineSpec{Owner: tt.owner}}
+			vmMetadata := &nutanixClientV3.Metadata{Kind: pointer.String("vm")}
+			reconciler := &NutanixMachineReconciler{}
+			err := reconciler.setVMOwner(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: &referenceTestService{}},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine"}},
+				NutanixMachine: ntnxMachine,
+			}, vmMetadata)
+			if tt.expectError {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(vmMetadata.OwnerReference).To(BeNil())
+				g.Expect(conditions.IsFalse(ntnxMachine, infrav1.OwnerAssignedCondition)).To(BeTrue())
+				g.Expect(conditions.GetReason(ntnxMachine, infrav1.OwnerAssignedCondition)).To(Equal(infrav1.OwnerAssignationFailed))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			if tt.expectedOwnerUUID == "" {
+				g.Expect(vmMetadata.OwnerReference).To(BeNil())
+				g.Expect(conditions.Has(ntnxMachine, infrav1.OwnerAssignedCondition)).To(BeFalse())
+				return
+			}
+			g.Expect(vmMetadata.OwnerReference).To(Equal(&nutanixClientV3.Reference{
+				Kind: pointer.String(userKind),
+				UUID: pointer.String(tt.expectedOwnerUUID),
+			}))
+			g.Expect(conditions.IsTrue(ntnxMachine, infrav1.OwnerAssignedCondition)).To(BeTrue())
+		})
+	}
+}
+
+func TestNutanixMachineFindExistingVM(t *testing.T) {
+	const (
+		clusterName = "test-cluster"
+		vmName      = "test-machine"
+		vmUUID      = "00000000-0000-0000-0000-000000000020"
+	)
+	testVM := func(categories map[string]string, numSockets int64) *nutanixClientV3.VMIntentResponse {
+		return &nutanixClientV3.VMIntentResponse{
+			Metadata: &nutanixClientV3.Metadata{
+				UUID:       pointer.String(vmUUID),
+				Categories: categories,
+			},
+			Spec: &nutanixClientV3.VM{
+				Name: pointer.String(vmName),
+				Resources: &nutanixClientV3.VMResources{
+					NumSockets:        pointer.Int64(numSockets),
+					NumVcpusPerSocket: pointer.Int64(1),
+					MemorySizeMib:     pointer.Int64(4096),
+				},
+			},
+		}
+	}
+	ownerCategories := map[string]string{infrav1.DefaultCAPICategoryKeyForName: clusterName}
+
+	tests := []struct {
+		name          string
+		vms           []*nutanixClientV3.VMIntentResponse
+		expectVM      bool
+		expectError   bool
+		expectedCause string
+	}{
+		{
+			name:     "adopts VM owned by the cluster matching the spec",
+			vms:      []*nutanixClientV3.VMIntentResponse{testVM(ownerCategories, 2)},
+			expectVM: true,
+		},
+		{
+			name:     "adopts VM with obsolete owner category",
+			vms:      []*nutanixClientV3.VMIntentResponse{testVM(map[string]string{infrav1.ObsoleteDefaultCAPICategoryPrefix + clusterName: infrav1.ObsoleteDefaultCAPICategoryOwnedValue}, 2)},
+			expectVM: true,
+		},
+		{
+			name:          "VM owned by another cluster",
+			vms:           []*nutanixClientV3.VMIntentResponse{testVM(map[string]string{infrav1.DefaultCAPICategoryKeyForName: "other-cluster"}, 2)},
+			expectError:   true,
+			expectedCause: "not owned by cluster",
+		},
+		{
+			name:          "VM owned by the cluster not matching the spec",
+			vms:           []*nutanixClientV3.VMIntentResponse{testVM(ownerCategories, 4)},
+			expectError:   true,
+			expectedCause: "does not match",
+		},
+		{
+			name: "VM not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: infrav1.NutanixMachineSpec{
+					VCPUSockets:    2,
+					VCPUsPerSocket: 1,
+					MemorySize:     resource.MustParse("4Gi"),
+				},
+			}
+			reconciler := &NutanixMachineReconciler{}
+			vm, err := reconciler.findExistingVM(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: &existingVMTestService{vms: tt.vms}},
+				Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: clusterName}},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: vmName}},
+				NutanixMachine: ntnxMachine,
+			})
+			if tt.expectError {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.expectedCause)))
+				g.Expect(conditions.GetReason(ntnxMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.VMNameConflict))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			if !tt.expectVM {
+				g.Expect(vm).To(BeNil())
+				return
+			}
+			g.Expect(*vm.Metadata.UUID).To(Equal(vmUUID))
+		})
+	}
+}
+
+// adoptVMTestService returns the VMs with the given UUIDs and records their updates and deletions
+type adoptVMTestService struct {
+	existingVMTestService
+	updates []*nutanixClientV3.VMIntentInput
+	deleted []string
+}
+
+func (s *adoptVMTestService) UpdateVM(_ context.Context, _ string, body *nutanixClientV3.VMIntentInput) (*nutanixClientV3.VMIntentResponse, error) {
+	s.updates = append(s.updates, body)
+	return &nutanixClientV3.VMIntentResponse{
+		Metadata: body.Metadata,
+		Spec:     body.Spec,
+		Status: &nutanixClientV3.VMDefStatus{
+			ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "update-task"},
+		},
+	}, nil
+}
+
+func (s *adoptVMTestService) DeleteVM(_ context.Context, uuid string) (*nutanixClientV3.DeleteResponse, error) {
+	s.deleted = append(s.deleted, uuid)
+	return &nutanixClientV3.DeleteResponse{
+		Status: &nutanixClientV3.DeleteStatus{
+			ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "delete-task"},
+		},
+	}, nil
+}
+
+func (s *adoptVMTestService) GetTask(_ context.Context, _ string) (*nutanixClientV3.TasksResponse, error) {
+	return &nutanixClientV3.TasksResponse{Status: pointer.String("SUCCEEDED")}, nil
+}
+
+func TestNutanixMachineAdoptExistingVM(t *testing.T) {
+	const (
+		clusterName = "test-cluster"
+		vmUUID      = "00000000-0000-0000-0000-000000000030"
+	)
+	ownDescription := buildVMDescription("default", clusterName, "test-machine")
+	testVM := func(categories map[string]string, description string) *nutanixClientV3.VMIntentResponse {
+		return &nutanixClientV3.VMIntentResponse{
+			Metadata: &nutanixClientV3.Metadata{
+				UUID:       pointer.String(vmUUID),
+				Categories: categories,
+			},
+			Spec: &nutanixClientV3.VM{
+				Name:        pointer.String("legacy-vm"),
+				Description: pointer.String(description),
+				Resources:   &nutanixClientV3.VMResources{},
+			},
+			Status: &nutanixClientV3.VMDefStatus{},
+		}
+	}
+
+	tests := []struct {
+		name               string
+		vms                []*nutanixClientV3.VMIntentResponse
+		otherMachines      []client.Object
+		expectedCause      string
+		expectedCategories map[string]string
+		expectedEvents     []string
+	}{
+		{
+			name:               "adopts unowned VM",
+			vms:                []*nutanixClientV3.VMIntentResponse{testVM(map[string]string{"env": "dev"}, "legacy VM")},
+			expectedCategories: map[string]string{infrav1.DefaultCAPICategoryKeyForName: clusterName},
+			expectedEvents:     []string{vmAdoptedEventReason},
+		},
+		{
+			name: "VM already adopted by the Machine",
+			vms:  []*nutanixClientV3.VMIntentResponse{testVM(map[string]string{infrav1.DefaultCAPICategoryKeyForName: clusterName}, ownDescription)},
+		},
+		{
+			name:          "VM not found",
+			expectedCause: "not found",
+		},
+		{
+			name:          "VM owned by another cluster",
+			vms:           []*nutanixClientV3.VMIntentResponse{testVM(map[string]string{infrav1.DefaultCAPICategoryKeyForName: "other-cluster"}, "")},
+			expectedCause: "owned by cluster other-cluster",
+		},
+		{
+			name:          "VM owned by another cluster with obsolete category",
+			vms:           []*nutanixClientV3.VMIntentResponse{testVM(map[string]string{infrav1.ObsoleteDefaultCAPICategoryPrefix + "other-cluster": infrav1.ObsoleteDefaultCAPICategoryOwnedValue}, "")},
+			expectedCause: "owned by cluster other-cluster",
+		},
+		{
+			name:          "VM owned by another Machine of the cluster",
+			vms:           []*nutanixClientV3.VMIntentResponse{testVM(map[string]string{infrav1.DefaultCAPICategoryKeyForName: clusterName}, buildVMDescription("default", clusterName, "other-machine"))},
+			expectedCause: "owned by another Machine",
+		},
+		{
+			name: "VM adopted by another NutanixMachine",
+			vms:  []*nutanixClientV3.VMIntentResponse{testVM(nil, "")},
+			otherMachines: []client.Object{&infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+				Spec:       infrav1.NutanixMachineSpec{ExistingVMUUID: vmUUID},
+			}},
+			expectedCause: "owned by NutanixMachine other",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			ntnxMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: infrav1.NutanixMachineSpec{ExistingVMUUID: vmUUID},
+			}
+			service := &adoptVMTestService{existingVMTestService: existingVMTestService{vms: tt.vms}}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixMachineReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(tt.otherMachines, ntnxMachine)...).Build(),
+				Recorder: recorder,
+			}
+			vm, err := reconciler.findExistingVM(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+				Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: "default"}},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"}},
+				NutanixMachine: ntnxMachine,
+			})
+			if tt.expectedCause != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.expectedCause)))
+				g.Expect(conditions.GetReason(ntnxMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.VMAdoptionFailed))
+				g.Expect(service.updates).To(BeEmpty())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(*vm.Metadata.UUID).To(Equal(vmUUID))
+			g.Expect(receivedEventReasons(recorder)).To(ConsistOf(tt.expectedEvents))
+			if tt.expectedCategories == nil {
+				g.Expect(service.updates).To(BeEmpty())
+				return
+			}
+			g.Expect(service.updates).To(HaveLen(1))
+			g.Expect(service.updates[0].Metadata.Categories).To(Equal(tt.expectedCategories))
+			g.Expect(*service.updates[0].Spec.Description).To(Equal(ownDescription))
+			// The adopted VM keeps its name
+			g.Expect(*service.updates[0].Spec.Name).To(Equal("legacy-vm"))
+
+			// The adopted VM is found by its UUID once it was recorded on the NutanixMachine
+			ntnxMachine.Status.VmUUID = vmUUID
+			vm, err = FindVM(context.Background(), &nutanixClientV3.Client{V3: service}, ntnxMachine, "test-machine")
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(*vm.Metadata.UUID).To(Equal(vmUUID))
+
+			// The adopted VM is deleted with the NutanixMachine although its name differs from the Machine
+			_, err = reconciler.reconcileDelete(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"}},
+				NutanixMachine: ntnxMachine,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(service.deleted).To(Equal([]string{vmUUID}))
+		})
+	}
+}
+
+func TestNutanixMachineReconcileFailureDomainMissing(t *testing.T) {
+	g := NewWithT(t)
+	ntnxCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: infrav1.NutanixClusterSpec{
+			FailureDomains: []infrav1.NutanixFailureDomainConfig{{Name: "fd-1"}, {Name: "fd-2"}},
+		},
+	}
+	newMachineContext := func(failureDomain *string) *nctx.MachineContext {
+		return &nctx.MachineContext{
+			Context:        context.Background(),
+			NutanixCluster: ntnxCluster,
+			NutanixMachine: &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Status:     infrav1.NutanixMachineStatus{FailureDomain: failureDomain},
+			},
+		}
+	}
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &NutanixMachineReconciler{Recorder: recorder}
+	fd1Machine := newMachineContext(pointer.String("fd-1"))
+	fd2Machine := newMachineContext(pointer.String("fd-2"))
+	unplacedMachine := newMachineContext(nil)
+	reconcileAll := func() {
+		for _, rctx := range []*nctx.MachineContext{fd1Machine, fd2Machine, unplacedMachine} {
+			reconciler.reconcileFailureDomainMissing(rctx)
+		}
+	}
+
+	reconcileAll()
+	g.Expect(receivedEventReasons(recorder)).To(BeEmpty())
+	for _, rctx := range []*nctx.MachineContext{fd1Machine, fd2Machine, unplacedMachine} {
+		g.Expect(conditions.Has(rctx.NutanixMachine, infrav1.FailureDomainMissingCondition)).To(BeFalse())
+	}
+
+	// Removing the failure domain only affects the machines placed in it
+	ntnxCluster.Spec.FailureDomains = ntnxCluster.Spec.FailureDomains[:1]
+	reconcileAll()
+	g.Expect(receivedEventReasons(recorder)).To(ConsistOf(failureDomainMissingEventReason))
+	g.Expect(conditions.IsTrue(fd2Machine.NutanixMachine, infrav1.FailureDomainMissingCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(fd2Machine.NutanixMachine, infrav1.FailureDomainMissingCondition)).To(Equal(infrav1.FailureDomainRemoved))
+	g.Expect(conditions.Has(fd1Machine.NutanixMachine, infrav1.FailureDomainMissingCondition)).To(BeFalse())
+	g.Expect(conditions.Has(unplacedMachine.NutanixMachine, infrav1.FailureDomainMissingCondition)).To(BeFalse())
+	// The placement of the running VM is kept
+	g.Expect(fd2Machine.NutanixMachine.Status.FailureDomain).To(Equal(pointer.String("fd-2")))
+
+	// The event is only recorded once while the failure domain is missing
+	reconcileAll()
+	g.Expect(receivedEventReasons(recorder)).To(BeEmpty())
+	g.Expect(conditions.IsTrue(fd2Machine.NutanixMachine, infrav1.FailureDomainMissingCondition)).To(BeTrue())
+
+	// The condition is removed once the failure domain is added back
+	ntnxCluster.Spec.FailureDomains = append(ntnxCluster.Spec.FailureDomains, infrav1.NutanixFailureDomainConfig{Name: "fd-2"})
+	reconcileAll()
+	g.Expect(conditions.Has(fd2Machine.NutanixMachine, infrav1.FailureDomainMissingCondition)).To(BeFalse())
+}
+
+func TestNutanixMachineReconcileDeleteProtectedVM(t *testing.T) {
+	const vmUUID = "00000000-0000-0000-0000-000000000031"
+	protectionCategory := &infrav1.NutanixCategoryIdentifier{Key: "protected", Value: "true"}
+
+	tests := []struct {
+		name               string
+		protectionCategory *infrav1.NutanixCategoryIdentifier
+		categories         map[string]string
+		expectBlocked      bool
+		expectedEvents     []string
+	}{
+		{
+			name:               "protected VM is not deleted",
+			protectionCategory: protectionCategory,
+			categories:         map[string]string{"protected": "true"},
+			expectBlocked:      true,
+			expectedEvents:     []string{vmDeletionBlockedEventReason},
+		},
+		{
+			name:               "VM with other category value is deleted",
+			protectionCategory: protectionCategory,
+			categories:         map[string]string{"protected": "false"},
+		},
+		{
+			name:               "VM without protection category is deleted",
+			protectionCategory: protectionCategory,
+		},
+		{
+			name:       "VM is deleted if protection is disabled",
+			categories: map[string]string{"protected": "true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			vm := &nutanixClientV3.VMIntentResponse{
+				Metadata: &nutanixClientV3.Metadata{
+					UUID:       pointer.String(vmUUID),
+					Categories: tt.categories,
+				},
+				Spec:   &nutanixClientV3.VM{Name: pointer.String("test-machine")},
+				Status: &nutanixClientV3.VMDefStatus{},
+			}
+			ntnxMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Status:     infrav1.NutanixMachineStatus{VmUUID: vmUUID},
+			}
+			ctrlutil.AddFinalizer(ntnxMachine, infrav1.NutanixMachineFinalizer)
+			service := &adoptVMTestService{existingVMTestService: existingVMTestService{vms: []*nutanixClientV3.VMIntentResponse{vm}}}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixMachineReconciler{
+				Recorder:         recorder,
+				controllerConfig: &ControllerConfig{VMProtectionCategory: tt.protectionCategory},
+			}
+			rctx := &nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"}},
+				NutanixMachine: ntnxMachine,
+			}
+
+			result, err := reconciler.reconcileDelete(rctx)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(receivedEventReasons(recorder)).To(ConsistOf(tt.expectedEvents))
+			if !tt.expectBlocked {
+				g.Expect(service.deleted).To(Equal([]string{vmUUID}))
+				g.Expect(conditions.Has(ntnxMachine, infrav1.DeletionBlockedCondition)).To(BeFalse())
+				return
+			}
+			g.Expect(service.deleted).To(BeEmpty())
+			g.Expect(result.RequeueAfter).To(Equal(vmDeletionBlockedRequeueInterval))
+			g.Expect(conditions.IsTrue(ntnxMachine, infrav1.DeletionBlockedCondition)).To(BeTrue())
+			g.Expect(conditions.GetReason(ntnxMachine, infrav1.DeletionBlockedCondition)).To(Equal(infrav1.VMDeletionProtected))
+			g.Expect(ctrlutil.ContainsFinalizer(ntnxMachine, infrav1.NutanixMachineFinalizer)).To(BeTrue())
+
+			// The event is only recorded once while the deletion is blocked
+			_, err = reconciler.reconcileDelete(rctx)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(receivedEventReasons(recorder)).To(BeEmpty())
+			g.Expect(service.deleted).To(BeEmpty())
+
+			// The VM is deleted once the protection category was removed
+			vm.Metadata.Categories = nil
+			_, err = reconciler.reconcileDelete(rctx)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(service.deleted).To(Equal([]string{vmUUID}))
+			g.Expect(conditions.Has(ntnxMachine, infrav1.DeletionBlockedCondition)).To(BeFalse())
+		})
+	}
+}
+
+func TestNutanixMachineReconcileVMRecreation(t *testing.T) {
+	g := NewWithT(t)
+	const vmUUID = "00000000-0000-0000-0000-000000000032"
+	vm := &nutanixClientV3.VMIntentResponse{
+		Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(vmUUID)},
+		Spec:     &nutanixClientV3.VM{Name: pointer.String("test-machine")},
+		Status:   &nutanixClientV3.VMDefStatus{},
+	}
+	ntnxMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "machine-uid"},
+		Spec:       infrav1.NutanixMachineSpec{ProviderID: GenerateProviderID(vmUUID)},
+		Status: infrav1.NutanixMachineStatus{
+			Ready:     true,
+			VmUUID:    vmUUID,
+			Addresses: []capiv1.MachineAddress{{Type: capiv1.MachineInternalIP, Address: "10.0.0.1"}},
+			NodeRef:   &corev1.ObjectReference{Kind: "Node", Name: "test-machine"},
+		},
+	}
+	service := &adoptVMTestService{existingVMTestService: existingVMTestService{vms: []*nutanixClientV3.VMIntentResponse{vm}}}
+	recorder := record.NewFakeRecorder(10)
+	reconciler := &NutanixMachineReconciler{Recorder: recorder}
+	reconciler.vmCreations.recordSubmission(ntnxMachine.UID, vmUUID)
+	rctx := &nctx.MachineContext{
+		Context:        context.Background(),
+		NutanixClient:  &nutanixClientV3.Client{V3: service},
+		Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"}},
+		NutanixMachine: ntnxMachine,
+	}
+
+	// The VM is kept without the annotation
+	pending, err := reconciler.reconcileVMRecreation(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeFalse())
+	g.Expect(service.deleted).To(BeEmpty())
+
+	// The VM is deleted while the status still refers to it
+	ntnxMachine.Annotations = map[string]string{infrav1.NutanixMachineRecreateVMAnnotation: ""}
+	pending, err = reconciler.reconcileVMRecreation(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeTrue())
+	g.Expect(service.deleted).To(Equal([]string{vmUUID}))
+	g.Expect(receivedEventReasons(recorder)).To(ConsistOf(vmRecreationRequestedEventReason))
+	g.Expect(ntnxMachine.Status.VmUUID).To(Equal(vmUUID))
+	g.Expect(conditions.GetReason(ntnxMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.VMRecreating))
+
+	// The status is reset once the VM is gone
+	service.vms = nil
+	pending, err = reconciler.reconcileVMRecreation(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeFalse())
+	g.Expect(service.deleted).To(Equal([]string{vmUUID}))
+	g.Expect(receivedEventReasons(recorder)).To(ConsistOf(vmRecreatedEventReason))
+	g.Expect(ntnxMachine.Spec.ProviderID).To(BeEmpty())
+	g.Expect(ntnxMachine.Status.VmUUID).To(BeEmpty())
+	g.Expect(ntnxMachine.Status.Ready).To(BeFalse())
+	g.Expect(ntnxMachine.Status.Addresses).To(BeEmpty())
+	g.Expect(ntnxMachine.Status.NodeRef).To(BeNil())
+	g.Expect(ntnxMachine.Annotations).NotTo(HaveKey(infrav1.NutanixMachineRecreateVMAnnotation))
+	g.Expect(reconciler.vmCreations.submittedVM(ntnxMachine.UID)).To(BeEmpty())
+	g.Expect(ntnxMachine.DeletionTimestamp).To(BeNil())
+
+	// Adopted VMs are not recreated
+	service.vms = []*nutanixClientV3.VMIntentResponse{vm}
+	ntnxMachine.Spec.ExistingVMUUID = vmUUID
+	ntnxMachine.Status.VmUUID = vmUUID
+	ntnxMachine.Annotations = map[string]string{infrav1.NutanixMachineRecreateVMAnnotation: ""}
+	pending, err = reconciler.reconcileVMRecreation(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeFalse())
+	g.Expect(service.deleted).To(Equal([]string{vmUUID}))
+	g.Expect(receivedEventReasons(recorder)).To(ConsistOf(vmRecreationRefusedEventReason))
+	g.Expect(ntnxMachine.Status.VmUUID).To(Equal(vmUUID))
+	g.Expect(ntnxMachine.Annotations).NotTo(HaveKey(infrav1.NutanixMachineRecreateVMAnnotation))
+}
+
+func TestNutanixMachineReconcileTimeout(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	// Prism Central only answers requests once they are canceled
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Minute):
+		}
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	defer server.Close()
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	g.Expect(err).NotTo(HaveOccurred())
+	v3Client, err := nutanixClientV3.NewV3Client(prismgoclient.Credentials{
+		URL:      server.Listener.Addr().String(),
+		Endpoint: host,
+		Port:     port,
+		Username: "user",
+		Password: "password",
+		Insecure: true,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(capiv1.AddToScheme(scheme)).To(Succeed())
+	cluster := &capiv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: capiv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{Name: "test", Namespace: "default"},
+		},
+	}
+	machine := &capiv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			Labels:    map[string]string{capiv1.ClusterLabelName: "test"},
+		},
+		Spec: capiv1.MachineSpec{ClusterName: "test"},
+	}
+	ntnxCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: infrav1.NutanixClusterSpec{
+			PrismCentral: &credentialTypes.NutanixPrismEndpoint{Address: host, Port: 9440},
+		},
+	}
+	ntnxMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: capiv1.GroupVersion.String(), Kind: "Machine", Name: "test"},
+			},
+			Finalizers:        []string{infrav1.NutanixMachineFinalizer},
+			DeletionTimestamp: &metav1.Time{Time: time.Now()},
+		},
+		Status: infrav1.NutanixMachineStatus{VmUUID: "00000000-0000-0000-0000-000000000050"},
+	}
+	nutanixClient.NutanixClientCache.Set(ntnxCluster, ntnxCluster.Spec.PrismCentral, "", v3Client)
+	defer nutanixClient.NutanixClientCache.Delete(ntnxCluster)
+
+	reconciler := &NutanixMachineReconciler{
+		Client:           fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, machine, ntnxCluster, ntnxMachine).Build(),
+		Scheme:           scheme,
+		Recorder:         record.NewFakeRecorder(10),
+		controllerConfig: &ControllerConfig{ReconcileTimeout: 200 * time.Millisecond},
+	}
+	start := time.Now()
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ntnxMachine)})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+	g.Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+}
+
+func TestNutanixMachineReconcilePaused(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(capiv1.AddToScheme(scheme)).To(Succeed())
+	cluster := &capiv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: capiv1.ClusterSpec{
+			Paused:            true,
+			InfrastructureRef: &corev1.ObjectReference{Name: "test", Namespace: "default"},
+		},
+	}
+	machine := &capiv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			Labels:    map[string]string{capiv1.ClusterLabelName: "test"},
+		},
+		Spec: capiv1.MachineSpec{ClusterName: "test"},
+	}
+	ntnxMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: capiv1.GroupVersion.String(), Kind: "Machine", Name: "test"},
+			},
+			Finalizers:        []string{infrav1.NutanixMachineFinalizer},
+			DeletionTimestamp: &metav1.Time{Time: time.Now()},
+		},
+		Spec:   infrav1.NutanixMachineSpec{ProviderID: "nutanix://00000000-0000-0000-0000-000000000050"},
+		Status: infrav1.NutanixMachineStatus{VmUUID: "00000000-0000-0000-0000-000000000050"},
+	}
+	writeClient := &writeCountingClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, machine, ntnxMachine).Build()}
+	reconciler := &NutanixMachineReconciler{
+		Client:   writeClient,
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ntnxMachine)}
+
+	// Only the Paused condition is set
+	_, err := reconciler.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(writeClient.writes).To(BeNumerically(">", 0))
+	writes := writeClient.writes
+	paused := &infrav1.NutanixMachine{}
+	g.Expect(writeClient.Get(ctx, req.NamespacedName, paused)).To(Succeed())
+	g.Expect(conditions.IsTrue(paused, infrav1.PausedCondition)).To(BeTrue())
+	g.Expect(paused.Finalizers).To(Equal(ntnxMachine.Finalizers))
+	g.Expect(paused.Spec.ProviderID).To(Equal(ntnxMachine.Spec.ProviderID))
+	g.Expect(paused.Status.VmUUID).To(Equal(ntnxMachine.Status.VmUUID))
+
+	// Nothing is written while the NutanixMachine stays paused
+	_, err = reconciler.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(writeClient.writes).To(Equal(writes))
+	g.Expect(writeClient.Get(ctx, req.NamespacedName, paused)).To(Succeed())
+	g.Expect(paused.Finalizers).To(Equal(ntnxMachine.Finalizers))
+}
+
+func TestRecordVMCreation(t *testing.T) {
+	const vmUUID = "00000000-0000-0000-0000-000000000060"
+	tests := []struct {
+		name             string
+		executionContext *nutanixClientV3.ExecutionContext
+		expectedTaskUUID string
+		expectedError    bool
+	}{
+		{
+			name:             "task uuid known",
+			executionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "task-uuid"},
+			expectedTaskUUID: "task-uuid",
+		},
+		{
+			name:          "task uuid missing",
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ctx := context.Background()
+
+			scheme := runtime.NewScheme()
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			ntnxMachine := &infrav1.NutanixMachine{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+			reconciler := &NutanixMachineReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(ntnxMachine).Build(),
+				Scheme: scheme,
+			}
+			rctx := &nctx.MachineContext{
+				Context:        ctx,
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}},
+				NutanixMachine: ntnxMachine,
+			}
+			vmResponse := &nutanixClientV3.VMIntentResponse{
+				Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(vmUUID)},
+				Spec:     &nutanixClientV3.VM{Name: pointer.String("test")},
+				Status: &nutanixClientV3.VMDefStatus{
+					State:            pointer.String("PENDING"),
+					ExecutionContext: tt.executionContext,
+					Resources:        &nutanixClientV3.VMResourcesDefStatus{PowerState: pointer.String("OFF")},
+				},
+			}
+
+			taskUUID, err := reconciler.recordVMCreation(rctx, vmResponse)
+			if tt.expectedError {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(taskUUID).To(Equal(tt.expectedTaskUUID))
+			}
+
+			// The UUID is stored before the creation task completed
+			stored := &infrav1.NutanixMachine{}
+			g.Expect(reconciler.Client.Get(ctx, client.ObjectKeyFromObject(ntnxMachine), stored)).To(Succeed())
+			g.Expect(stored.Status.VmUUID).To(Equal(vmUUID))
+			g.Expect(stored.Status.PowerState).To(Equal("OFF"))
+			g.Expect(stored.Spec.ProviderID).To(Equal(GenerateProviderID(vmUUID)))
+		})
+	}
+}
+
+// vmCreationTestService creates VMs that Prism Central does not list by name yet, as is the case right after the
+// creation was submitted, and counts the create calls
+type vmCreationTestService struct {
+	nutanixClientV3.Service
+	mu      sync.Mutex
+	creates int
+	vms     map[string]*nutanixClientV3.VMIntentResponse
+}
+
+func (s *vmCreationTestService) CreateVM(_ context.Context, body *nutanixClientV3.VMIntentInput) (*nutanixClientV3.VMIntentResponse, error) {
+	// Give a concurrent reconciliation the chance to submit a creation as well
+	time.Sleep(10 * time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.creates++
+	vm := &nutanixClientV3.VMIntentResponse{
+		Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(fmt.Sprintf("00000000-0000-0000-0000-%012d", s.creates))},
+		Spec:     body.Spec,
+		Status: &nutanixClientV3.VMDefStatus{
+			ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "create-task"},
+		},
+	}
+	s.vms[*vm.Metadata.UUID] = vm
+	return vm, nil
+}
+
+func (s *vmCreationTestService) ListVM(_ context.Context, _ *nutanixClientV3.DSMetadata) (*nutanixClientV3.VMListIntentResponse, error) {
+	return &nutanixClientV3.VMListIntentResponse{}, nil
+}
+
+func (s *vmCreationTestService) GetVM(_ context.Context, uuid string) (*nutanixClientV3.VMIntentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if vm, ok := s.vms[uuid]; ok {
+		return vm, nil
+	}
+	return nil, fmt.Errorf("ENTITY_NOT_FOUND: VM %s not found", uuid)
+}
+
+func TestNutanixMachineReconcileLeadership(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(capiv1.AddToScheme(scheme)).To(Succeed())
+	cluster := &capiv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: capiv1.ClusterSpec{
+			Paused:            true,
+			InfrastructureRef: &corev1.ObjectReference{Name: "test", Namespace: "default"},
+		},
+	}
+	machine := &capiv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			Labels:    map[string]string{capiv1.ClusterLabelName: "test"},
+		},
+		Spec: capiv1.MachineSpec{ClusterName: "test"},
+	}
+	ntnxMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			UID:       "machine-uid",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: capiv1.GroupVersion.String(), Kind: "Machine", Name: "test"},
+			},
+		},
+	}
+	writeClient := &writeCountingClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, machine, ntnxMachine).Build()}
+	reconciler := &NutanixMachineReconciler{
+		Client:     writeClient,
+		Scheme:     scheme,
+		Recorder:   record.NewFakeRecorder(10),
+		leadership: newLeadership(),
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ntnxMachine)}
+
+	// The reconcile short-circuits while the lease is not held
+	result, err := reconciler.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(reconcile.Result{}))
+	g.Expect(writeClient.writes).To(Equal(0))
+
+	// The NutanixMachine is reconciled once the lease is acquired
+	leaseCtx, loseLease := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		g.Expect(reconciler.leadership.Start(leaseCtx)).To(Succeed())
+	}()
+	g.Eventually(reconciler.leadership.isLeader).Should(BeTrue())
+	reconcileCtx, cancel := reconciler.leadership.withLeadership(ctx)
+	defer cancel()
+	_, err = reconciler.Reconcile(ctx, req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(writeClient.writes).To(BeNumerically(">", 0))
+
+	// Pending reconciles are canceled and no VM is created once the lease is lost
+	loseLease()
+	<-stopped
+	g.Expect(reconciler.leadership.isLeader()).To(BeFalse())
+	g.Eventually(reconcileCtx.Done()).Should(BeClosed())
+	service := &vmCreationTestService{vms: make(map[string]*nutanixClientV3.VMIntentResponse)}
+	rctx := &nctx.MachineContext{
+		Context:        ctx,
+		NutanixClient:  &nutanixClientV3.Client{V3: service},
+		Cluster:        cluster,
+		Machine:        machine,
+		NutanixMachine: ntnxMachine,
+	}
+	vmInput := &nutanixClientV3.VMIntentInput{Spec: &nutanixClientV3.VM{Name: pointer.String("test")}}
+	_, _, err = reconciler.submitVMCreation(rctx, vmInput)
+	g.Expect(err).To(MatchError(errLeadershipLost))
+	g.Expect(service.creates).To(Equal(0))
+}
+
+func TestNutanixMachineSubmitVMCreationConcurrently(t *testing.T) {
+	g := NewWithT(t)
+	service := &vmCreationTestService{vms: make(map[string]*nutanixClientV3.VMIntentResponse)}
+	reconciler := &NutanixMachineReconciler{}
+	ntnxMachine := &infrav1.NutanixMachine{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "machine-uid"}}
+
+	const reconciliations = 2
+	var wg sync.WaitGroup
+	vmUUIDs := make([]string, reconciliations)
+	created := make([]bool, reconciliations)
+	errs := make([]error, reconciliations)
+	for i := 0; i < reconciliations; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each reconciliation works on its own copy of the cached NutanixMachine, which does not carry a VM UUID
+			rctx := &nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+				Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"}},
+				NutanixMachine: ntnxMachine.DeepCopy(),
+			}
+			vmInput := &nutanixClientV3.VMIntentInput{Spec: &nutanixClientV3.VM{Name: pointer.String("test-machine")}}
+			vm, ok, err := reconciler.submitVMCreation(rctx, vmInput)
+			errs[i] = err
+			created[i] = ok
+			if vm != nil {
+				vmUUIDs[i] = *vm.Metadata.UUID
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	g.Expect(errs).To(HaveEach(BeNil()))
+	g.Expect(service.creates).To(Equal(1))
+	g.Expect(created).To(ConsistOf(true, false))
+	g.Expect(vmUUIDs[0]).To(Equal(vmUUIDs[1]))
+
+	// No locks are left, and the submitted VM is forgotten once the NutanixMachine is deleted
+	reconciler.vmCreations.forget(ntnxMachine.UID)
+	g.Expect(reconciler.vmCreations.submittedVM(ntnxMachine.UID)).To(BeEmpty())
+	g.Expect(reconciler.vmCreations.locks).To(BeEmpty())
+}
+
+// vmDescriptionTestService is a Prism v3 service holding a single VM whose updates are recorded
+type vmDescriptionTestService struct {
+	nutanixClientV3.Service
+	vm         *nutanixClientV3.VMIntentResponse
+	taskStatus string
+	updates    []*nutanixClientV3.VMIntentInput
+}
+
+func (s *vmDescriptionTestService) GetVM(_ context.Context, _ string) (*nutanixClientV3.VMIntentResponse, error) {
+	return s.vm, nil
+}
+
+func (s *vmDescriptionTestService) GetTask(_ context.Context, _ string) (*nutanixClientV3.TasksResponse, error) {
+	return &nutanixClientV3.TasksResponse{Status: pointer.String(s.taskStatus)}, nil
+}
+
+func (s *vmDescriptionTestService) UpdateVM(_ context.Context, _ string, body *nutanixClientV3.VMIntentInput) (*nutanixClientV3.VMIntentResponse, error) {
+	s.updates = append(s.updates, body)
+	return &nutanixClientV3.VMIntentResponse{
+		Metadata: body.Metadata,
+		Spec:     body.Spec,
+		Status: &nutanixClientV3.VMDefStatus{
+			ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "update-task"},
+		},
+	}, nil
+}
+
+func TestBuildVMDescription(t *testing.T) {
+	g := NewWithT(t)
+	description := buildVMDescription("namespace-1", "cluster-1", "machine-1")
+	g.Expect(description).To(HavePrefix(infrav1.DefaultCAPICategoryDescription))
+	g.Expect(description).To(ContainSubstring("Namespace: namespace-1"))
+	g.Expect(description).To(ContainSubstring("Cluster: cluster-1"))
+	g.Expect(description).To(ContainSubstring("Machine: machine-1"))
+}
+
+func TestBuildVMDescriptionWithAnnotations(t *testing.T) {
+	const prefix = "vm-description.example.com/"
+	machineDescription := buildVMDescription("default", "cluster", "machine")
+
+	tests := []struct {
+		name                string
+		annotations         map[string]string
+		prefix              string
+		expectedDescription string
+	}{
+		{
+			name:                "annotations with prefix are appended in order",
+			annotations:         map[string]string{prefix + "owner": "team-a", prefix + "cost-center": "42", "other": "ignored"},
+			prefix:              prefix,
+			expectedDescription: machineDescription + ". cost-center: 42, owner: team-a",
+		},
+		{
+			name:                "annotation named like the prefix is appended without name",
+			annotations:         map[string]string{prefix: " database server "},
+			prefix:              prefix,
+			expectedDescription: machineDescription + ". database server",
+		},
+		{
+			name:                "whitespace of values is collapsed",
+			annotations:         map[string]string{prefix + "note": "first line\n  second line"},
+			prefix:              prefix,
+			expectedDescription: machineDescription + ". note: first line second line",
+		},
+		{
+			name:                "empty values are ignored",
+			annotations:         map[string]string{prefix + "owner": " "},
+			prefix:              prefix,
+			expectedDescription: machineDescription,
+		},
+		{
+			name:                "annotations are ignored without prefix",
+			annotations:         map[string]string{prefix + "owner": "team-a"},
+			expectedDescription: machineDescription,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			description := buildVMDescriptionWithAnnotations("default", "cluster", "machine", tt.annotations, tt.prefix)
+			g.Expect(description).To(Equal(tt.expectedDescription))
+			g.Expect(isVMDescriptionOfMachine(description, "default", "cluster", "machine")).To(BeTrue())
+		})
+	}
+}
+
+func TestBuildVMDescriptionWithAnnotationsTruncates(t *testing.T) {
+	g := NewWithT(t)
+	const prefix = "vm-description.example.com/"
+	description := buildVMDescriptionWithAnnotations("default", "cluster", "machine",
+		map[string]string{prefix + "note": strings.Repeat("ä", maxVMDescriptionLength)}, prefix)
+	g.Expect(len(description)).To(BeNumerically("<=", maxVMDescriptionLength))
+	g.Expect(utf8.ValidString(description)).To(BeTrue())
+	g.Expect(description).To(HaveSuffix("ä..."))
+	g.Expect(description).To(HavePrefix(buildVMDescription("default", "cluster", "machine") + ". note: "))
+}
+
+func TestIsVMDescriptionOfMachine(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(isVMDescriptionOfMachine(buildVMDescription("default", "cluster", "machine"), "default", "cluster", "machine")).To(BeTrue())
+	g.Expect(isVMDescriptionOfMachine(buildVMDescription("default", "cluster", "machine-2"), "default", "cluster", "machine")).To(BeFalse())
+	g.Expect(isVMDescriptionOfMachine(buildVMDescription("default", "cluster", "machine")+". owner: team-a", "default", "cluster", "machine")).To(BeTrue())
+}
+
+func TestNutanixMachineReconcileVMDescription(t *testing.T) {
+	const prefix = "vm-description.example.com/"
+	expectedDescription := buildVMDescription("default", "cluster", "machine")
+
+	tests := []struct {
+		name                string
+		vmUUID              string
+		description         *string
+		annotations         map[string]string
+		taskStatus          string
+		expectedUpdate      bool
+		expectedDescription string
+	}{
+		{
+			name:           "refreshes stale description",
+			vmUUID:         "vm-uuid",
+			description:    pointer.String("changed in Prism Central"),
+			taskStatus:     "SUCCEEDED",
+			expectedUpdate: true,
+		},
+		{
+			name:           "sets missing description",
+			vmUUID:         "vm-uuid",
+			taskStatus:     "SUCCEEDED",
+			expectedUpdate: true,
+		},
+		{
+			name:        "keeps current description",
+			vmUUID:      "vm-uuid",
+			description: pointer.String(expectedDescription),
+			taskStatus:  "SUCCEEDED",
+		},
+		{
+			name:                "appends description annotations of the Machine",
+			vmUUID:              "vm-uuid",
+			description:         pointer.String(expectedDescription),
+			annotations:         map[string]string{prefix + "owner": "team-a"},
+			taskStatus:          "SUCCEEDED",
+			expectedUpdate:      true,
+			expectedDescription: expectedDescription + ". owner: team-a",
+		},
+		{
+			name:           "removes description annotations removed from the Machine",
+			vmUUID:         "vm-uuid",
+			description:    pointer.String(expectedDescription + ". owner: team-a"),
+			taskStatus:     "SUCCEEDED",
+			expectedUpdate: true,
+		},
+		{
+			name:        "postpones update while a task is in progress",
+			vmUUID:      "vm-uuid",
+			description: pointer.String("changed in Prism Central"),
+			taskStatus:  "RUNNING",
+		},
+		{
+			name:        "does nothing if the VM could not be retrieved",
+			description: pointer.String("changed in Prism Central"),
+			taskStatus:  "SUCCEEDED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			service := &vmDescriptionTestService{
+				vm: &nutanixClientV3.VMIntentResponse{
+					Metadata: &nutanixClientV3.Metadata{UUID: pointer.String("vm-uuid")},
+					Spec: &nutanixClientV3.VM{
+						Name:        pointer.String("machine"),
+						Description: tt.description,
+					},
+					Status: &nutanixClientV3.VMDefStatus{
+						ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "last-task"},
+					},
+				},
+				taskStatus: tt.taskStatus,
+			}
+			vm := service.vm
+			if tt.vmUUID == "" {
+				vm = nil
+			}
+			reconciler := &NutanixMachineReconciler{controllerConfig: &ControllerConfig{VMDescriptionAnnotationPrefix: prefix}}
+			reconciler.reconcileVMDescription(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+				Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default", Annotations: tt.annotations}},
+				NutanixMachine: &infrav1.NutanixMachine{Status: infrav1.NutanixMachineStatus{VmUUID: tt.vmUUID}},
+			}, vm)
+			if !tt.expectedUpdate {
+				g.Expect(service.updates).To(BeEmpty())
+				return
+			}
+			if tt.expectedDescription == "" {
+				tt.expectedDescription = expectedDescription
+			}
+			g.Expect(service.updates).To(HaveLen(1))
+			g.Expect(*service.updates[0].Spec.Description).To(Equal(tt.expectedDescription))
+			g.Expect(*service.updates[0].Metadata.UUID).To(Equal("vm-uuid"))
+		})
+	}
+}
+
+// vmNameTestService is a Prism v3 service holding a single VM whose updates are recorded, next to other VMs that are
+// only listed by name
+type vmNameTestService struct {
+	vmDescriptionTestService
+	otherVMs map[string]string
+}
+
+func (s *vmNameTestService) GetVM(ctx context.Context, uuid string) (*nutanixClientV3.VMIntentResponse, error) {
+	for name, otherUUID := range s.otherVMs {
+		if otherUUID == uuid {
+			return &nutanixClientV3.VMIntentResponse{
+				Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(uuid)},
+				Spec:     &nutanixClientV3.VM{Name: pointer.String(name)},
+			}, nil
+		}
+	}
+	return s.vmDescriptionTestService.GetVM(ctx, uuid)
+}
+
+func (s *vmNameTestService) ListVM(_ context.Context, getEntitiesRequest *nutanixClientV3.DSMetadata) (*nutanixClientV3.VMListIntentResponse, error) {
+	response := &nutanixClientV3.VMListIntentResponse{}
+	name := strings.TrimPrefix(*getEntitiesRequest.Filter, "vm_name==")
+	if uuid, ok := s.otherVMs[name]; ok {
+		response.Entities = append(response.Entities, &nutanixClientV3.VMIntentResource{
+			Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(uuid)},
+		})
+	}
+	if name == *s.vm.Spec.Name {
+		response.Entities = append(response.Entities, &nutanixClientV3.VMIntentResource{Metadata: s.vm.Metadata})
+	}
+	return response, nil
+}
+
+func TestNutanixMachineReconcileVMDescriptionObservesCluster(t *testing.T) {
+	g := NewWithT(t)
+	service := &vmDescriptionTestService{
+		vm: &nutanixClientV3.VMIntentResponse{
+			Metadata: &nutanixClientV3.Metadata{UUID: pointer.String("vm-uuid")},
+			Spec: &nutanixClientV3.VM{
+				Name:        pointer.String("machine"),
+				Description: pointer.String(buildVMDescription("default", "cluster", "machine")),
+			},
+			Status: &nutanixClientV3.VMDefStatus{
+				ClusterReference: &nutanixClientV3.Reference{Kind: pointer.String("cluster"), UUID: pointer.String(testPEUUID)},
+				Resources:        &nutanixClientV3.VMResourcesDefStatus{PowerState: pointer.String(vmPowerStateOn)},
+			},
+		},
+		taskStatus: "SUCCEEDED",
+	}
+	ntnxMachine := &infrav1.NutanixMachine{Status: infrav1.NutanixMachineStatus{VmUUID: "vm-uuid"}}
+	reconciler := &NutanixMachineReconciler{}
+	reconciler.reconcileVMDescription(&nctx.MachineContext{
+		Context:        context.Background(),
+		NutanixClient:  &nutanixClientV3.Client{V3: service},
+		Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+		Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}},
+		NutanixMachine: ntnxMachine,
+	}, service.vm)
+	g.Expect(ntnxMachine.Status.ClusterUUID).To(Equal(testPEUUID))
+	g.Expect(ntnxMachine.Status.PowerState).To(Equal(vmPowerStateOn))
+}
+
+func TestVMHasTaskInProgress(t *testing.T) {
+	tests := []struct {
+		name         string
+		lastTask     string
+		taskStatus   string
+		expectedBusy bool
+	}{
+		{
+			name:         "last task running",
+			lastTask:     "last-task",
+			taskStatus:   "RUNNING",
+			expectedBusy: true,
+		},
+		{
+			name:       "last task succeeded",
+			lastTask:   "last-task",
+			taskStatus: "SUCCEEDED",
+		},
+		{
+			name:       "last task failed",
+			lastTask:   "last-task",
+			taskStatus: "FAILED",
+		},
+		{
+			name: "no last task",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			vm := &nutanixClientV3.VMIntentResponse{
+				Metadata: &nutanixClientV3.Metadata{UUID: pointer.String("vm-uuid")},
+				Spec:     &nutanixClientV3.VM{Name: pointer.String("machine")},
+				Status: &nutanixClientV3.VMDefStatus{
+					ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: tt.lastTask},
+				},
+			}
+			busy, err := vmHasTaskInProgress(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: &vmDescriptionTestService{vm: vm, taskStatus: tt.taskStatus}},
+				NutanixMachine: &infrav1.NutanixMachine{Status: infrav1.NutanixMachineStatus{VmUUID: "vm-uuid"}},
+			}, vm)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(busy).To(Equal(tt.expectedBusy))
+		})
+	}
+}
+
+func TestNutanixMachineReconcileVMName(t *testing.T) {
+	tests := []struct {
+		name            string
+		vmName          string
+		vmNameTemplate  string
+		existingVMUUID  string
+		otherVMs        map[string]string
+		taskStatus      string
+		expectedPending bool
+		expectedName    string
+		expectedEvents  []string
+	}{
+		{
+			name:           "renames VM to the expected name",
+			vmName:         "machine-old",
+			taskStatus:     "SUCCEEDED",
+			expectedName:   "machine",
+			expectedEvents: []string{vmRenamedEventReason},
+		},
+		{
+			name:           "renames VM if the rendered name changes",
+			vmName:         "machine",
+			vmNameTemplate: "{{ .Cluster.Name }}-{{ .Machine.Name }}",
+			taskStatus:     "SUCCEEDED",
+			expectedName:   "cluster-machine",
+			expectedEvents: []string{vmRenamedEventReason},
+		},
+		{
+			name:       "keeps the expected name",
+			vmName:     "machine",
+			taskStatus: "SUCCEEDED",
+		},
+		{
+			name:           "keeps the name of adopted VMs",
+			vmName:         "adopted",
+			existingVMUUID: "vm-uuid",
+			taskStatus:     "SUCCEEDED",
+		},
+		{
+			name:           "does not rename VM to the name of another VM",
+			vmName:         "machine-old",
+			otherVMs:       map[string]string{"machine": "other-vm-uuid"},
+			taskStatus:     "SUCCEEDED",
+			expectedEvents: []string{vmRenameBlockedEventReason},
+		},
+		{
+			name:            "postpones update while a task is in progress",
+			vmName:          "machine-old",
+			taskStatus:      "RUNNING",
+			expectedPending: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			service := &vmNameTestService{
+				vmDescriptionTestService: vmDescriptionTestService{
+					vm: &nutanixClientV3.VMIntentResponse{
+						Metadata: &nutanixClientV3.Metadata{UUID: pointer.String("vm-uuid")},
+						Spec:     &nutanixClientV3.VM{Name: pointer.String(tt.vmName)},
+						Status: &nutanixClientV3.VMDefStatus{
+							ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "last-task"},
+						},
+					},
+					taskStatus: tt.taskStatus,
+				},
+				otherVMs: tt.otherVMs,
+			}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixMachineReconciler{Recorder: recorder}
+			pending, err := reconciler.reconcileVMName(&nctx.MachineContext{
+				Context:       context.Background(),
+				NutanixClient: &nutanixClientV3.Client{V3: service},
+				Cluster:       &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+				Machine:       &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}},
+				NutanixMachine: &infrav1.NutanixMachine{
+					Spec:   infrav1.NutanixMachineSpec{VMNameTemplate: tt.vmNameTemplate, ExistingVMUUID: tt.existingVMUUID},
+					Status: infrav1.NutanixMachineStatus{VmUUID: "vm-uuid"},
+				},
+			}, service.vm)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pending).To(Equal(tt.expectedPending))
+			g.Expect(receivedEventReasons(recorder)).To(ConsistOf(tt.expectedEvents))
+			if tt.expectedName == "" {
+				g.Expect(service.updates).To(BeEmpty())
+				return
+			}
+			g.Expect(service.updates).To(HaveLen(1))
+			g.Expect(*service.updates[0].Metadata.UUID).To(Equal("vm-uuid"))
+			g.Expect(*service.updates[0].Spec.Name).To(Equal(tt.expectedName))
+		})
+	}
+}
+
+// vmCategoriesTestService is a Prism v3 service holding a single VM whose updates are recorded. All category values exist.
+type vmCategoriesTestService struct {
+	vmDescriptionTestService
+}
+
+func (s *vmCategoriesTestService) GetCategoryValue(_ context.Context, _, value string) (*nutanixClientV3.CategoryValueStatus, error) {
+	return &nutanixClientV3.CategoryValueStatus{Value: pointer.String(value)}, nil
+}
+
+func TestNutanixMachineReconcileVMCategories(t *testing.T) {
+	ownerKey := infrav1.DefaultCAPICategoryKeyForName
+	obsoleteOwnerKey := infrav1.ObsoleteDefaultCAPICategoryPrefix + "cluster"
+
+	tests := []struct {
+		name                 string
+		vmName               string
+		vmCategories         map[string]string
+		additionalCategories []infrav1.NutanixCategoryIdentifier
+		placementCategories  []infrav1.NutanixCategoryIdentifier
+		reconcileDrift       bool
+		taskStatus           string
+		expectedPending      bool
+		expectedCategories   map[string]string
+		expectedEvents       []string
+	}{
+		{
+			name:                 "adds placement policy category",
+			vmCategories:         map[string]string{ownerKey: "cluster", "env": "dev"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "dev"}},
+			placementCategories:  []infrav1.NutanixCategoryIdentifier{{Key: "affinity", Value: "rack-1"}},
+			taskStatus:           "SUCCEEDED",
+			expectedCategories:   map[string]string{ownerKey: "cluster", "env": "dev", "affinity": "rack-1"},
+		},
+		{
+			name:                "keeps placement policy category",
+			vmCategories:        map[string]string{ownerKey: "cluster", "affinity": "rack-1"},
+			placementCategories: []infrav1.NutanixCategoryIdentifier{{Key: "affinity", Value: "rack-1"}},
+			taskStatus:          "SUCCEEDED",
+		},
+		{
+			name:                 "adds category",
+			vmCategories:         map[string]string{ownerKey: "cluster", "env": "dev"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "dev"}, {Key: "team", Value: "a"}},
+			taskStatus:           "SUCCEEDED",
+			expectedCategories:   map[string]string{ownerKey: "cluster", "env": "dev", "team": "a"},
+		},
+		{
+			name:               "removes category",
+			vmCategories:       map[string]string{ownerKey: "cluster", obsoleteOwnerKey: infrav1.ObsoleteDefaultCAPICategoryOwnedValue, "env": "dev"},
+			taskStatus:         "SUCCEEDED",
+			expectedCategories: map[string]string{ownerKey: "cluster", obsoleteOwnerKey: infrav1.ObsoleteDefaultCAPICategoryOwnedValue},
+		},
+		{
+			name:                 "changes category value",
+			vmCategories:         map[string]string{ownerKey: "cluster", "env": "dev"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "prod"}},
+			taskStatus:           "SUCCEEDED",
+			expectedCategories:   map[string]string{ownerKey: "cluster", "env": "prod"},
+		},
+		{
+			name:                 "keeps ownership categories",
+			vmCategories:         map[string]string{ownerKey: "cluster"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: ownerKey, Value: "other-cluster"}},
+			taskStatus:           "SUCCEEDED",
+		},
+		{
+			name: "keeps machine role categories",
+			vmCategories: map[string]string{
+				ownerKey: "cluster",
+				infrav1.DefaultCAPICategoryKeyForMachineRole:       infrav1.MachineRoleWorker,
+				infrav1.DefaultCAPICategoryKeyForMachineDeployment: "md-0",
+			},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "dev"}},
+			taskStatus:           "SUCCEEDED",
+			expectedCategories: map[string]string{
+				ownerKey: "cluster",
+				infrav1.DefaultCAPICategoryKeyForMachineRole:       infrav1.MachineRoleWorker,
+				infrav1.DefaultCAPICategoryKeyForMachineDeployment: "md-0",
+				"env": "dev",
+			},
+		},
+		{
+			name:                 "restores removed ownership category with drift reconciliation",
+			vmCategories:         map[string]string{"env": "dev"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "dev"}},
+			reconcileDrift:       true,
+			taskStatus:           "SUCCEEDED",
+			expectedCategories:   map[string]string{ownerKey: "cluster", "env": "dev"},
+			expectedEvents:       []string{vmOwnershipRestoredEventReason},
+		},
+		{
+			name:                 "restores changed ownership category with drift reconciliation",
+			vmCategories:         map[string]string{ownerKey: "other-cluster", "env": "dev"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "dev"}},
+			reconcileDrift:       true,
+			taskStatus:           "SUCCEEDED",
+			expectedCategories:   map[string]string{ownerKey: "cluster", "env": "dev"},
+			expectedEvents:       []string{vmOwnershipRestoredEventReason},
+		},
+		{
+			name:                 "restores removed ownership categories of matching VM without drift reconciliation",
+			vmCategories:         map[string]string{"env": "dev"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "dev"}},
+			taskStatus:           "SUCCEEDED",
+			expectedCategories:   map[string]string{ownerKey: "cluster", "env": "dev"},
+			expectedEvents:       []string{vmOwnershipRestoredEventReason},
+		},
+		{
+			name:                 "keeps removed ownership categories of VM not matching the machine without drift reconciliation",
+			vmName:               "other-machine",
+			vmCategories:         map[string]string{"env": "dev"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "dev"}},
+			taskStatus:           "SUCCEEDED",
+		},
+		{
+			name:                 "keeps changed ownership category without drift reconciliation",
+			vmCategories:         map[string]string{ownerKey: "other-cluster", "env": "dev"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "dev"}},
+			taskStatus:           "SUCCEEDED",
+		},
+		{
+			name:                 "keeps current categories",
+			vmCategories:         map[string]string{ownerKey: "cluster", "env": "dev"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "dev"}},
+			taskStatus:           "SUCCEEDED",
+		},
+		{
+			name:                 "postpones update while a task is in progress",
+			vmCategories:         map[string]string{ownerKey: "cluster"},
+			additionalCategories: []infrav1.NutanixCategoryIdentifier{{Key: "env", Value: "dev"}},
+			taskStatus:           "RUNNING",
+			expectedPending:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			vmName := tt.vmName
+			if vmName == "" {
+				vmName = "machine"
+			}
+			service := &vmCategoriesTestService{
+				vmDescriptionTestService: vmDescriptionTestService{
+					vm: &nutanixClientV3.VMIntentResponse{
+						Metadata: &nutanixClientV3.Metadata{UUID: pointer.String("vm-uuid"), Categories: tt.vmCategories},
+						Spec:     &nutanixClientV3.VM{Name: pointer.String(vmName)},
+						Status: &nutanixClientV3.VMDefStatus{
+							ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "last-task"},
+						},
+					},
+					taskStatus: tt.taskStatus,
+				},
+			}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixMachineReconciler{
+				Recorder:         recorder,
+				controllerConfig: &ControllerConfig{ReconcileDrift: tt.reconcileDrift},
+			}
+			pending, err := reconciler.reconcileVMCategories(&nctx.MachineContext{
+				Context:       context.Background(),
+				NutanixClient: &nutanixClientV3.Client{V3: service},
+				Cluster:       &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+				Machine:       &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}},
+				NutanixMachine: &infrav1.NutanixMachine{
+					Spec: infrav1.NutanixMachineSpec{
+						AdditionalCategories:      tt.additionalCategories,
+						PlacementPolicyCategories: tt.placementCategories,
+					},
+					Status: infrav1.NutanixMachineStatus{VmUUID: "vm-uuid"},
+				},
+			}, service.vm)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pending).To(Equal(tt.expectedPending))
+			if tt.expectedCategories == nil {
+				g.Expect(service.updates).To(BeEmpty())
+				return
+			}
+			g.Expect(service.updates).To(HaveLen(1))
+			g.Expect(*service.updates[0].Metadata.UUID).To(Equal("vm-uuid"))
+			g.Expect(service.updates[0].Metadata.Categories).To(Equal(tt.expectedCategories))
+			g.Expect(*service.updates[0].Spec.Name).To(Equal("machine"))
+			g.Expect(receivedEventReasons(recorder)).To(ConsistOf(tt.expectedEvents))
+		})
+	}
+}
+
+func TestNutanixMachineReconcileVMPowerOn(t *testing.T) {
+	poweredVM := func(powerState string) *nutanixClientV3.VMIntentResponse {
+		return &nutanixClientV3.VMIntentResponse{
+			Status: &nutanixClientV3.VMDefStatus{
+				Resources: &nutanixClientV3.VMResourcesDefStatus{PowerState: pointer.String(powerState)},
+			},
+		}
+	}
+	tests := []struct {
+		name                       string
+		powerOnAfterCreate         *bool
+		vm                         *nutanixClientV3.VMIntentResponse
+		expectedPowerStateOnCreate string
+		expectPoweredOn            bool
+		expectedCondition          *capiv1.Condition
+	}{
+		{
+			name:                       "powered on after create by default",
+			vm:                         poweredVM(vmPowerStateOn),
+			expectedPowerStateOnCreate: vmPowerStateOn,
+			expectPoweredOn:            true,
+		},
+		{
+			name:                       "powered on after create",
+			powerOnAfterCreate:         pointer.Bool(true),
+			vm:                         poweredVM(vmPowerStateOn),
+			expectedPowerStateOnCreate: vmPowerStateOn,
+			expectPoweredOn:            true,
+		},
+		{
+			name:                       "waits for power on",
+			powerOnAfterCreate:         pointer.Bool(false),
+			vm:                         poweredVM(vmPowerStateOff),
+			expectedPowerStateOnCreate: vmPowerStateOff,
+			expectedCondition: conditions.FalseCondition(infrav1.VMPoweredOnCondition, infrav1.WaitingForPowerOn, capiv1.ConditionSeverityInfo,
+				"VM was created powered off and waits to be powered on"),
+		},
+		{
+			name:                       "powered on outside of the controller",
+			powerOnAfterCreate:         pointer.Bool(false),
+			vm:                         poweredVM(vmPowerStateOn),
+			expectedPowerStateOnCreate: vmPowerStateOff,
+			expectPoweredOn:            true,
+			expectedCondition:          conditions.TrueCondition(infrav1.VMPoweredOnCondition),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec:       infrav1.NutanixMachineSpec{PowerOnAfterCreate: tt.powerOnAfterCreate},
+			}
+			reconciler := &NutanixMachineReconciler{}
+			rctx := &nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixMachine: ntnxMachine,
+			}
+
+			g.Expect(getVMPowerStateAfterCreate(ntnxMachine)).To(Equal(tt.expectedPowerStateOnCreate))
+			g.Expect(reconciler.reconcileVMPowerOn(rctx, tt.vm)).To(Equal(tt.expectPoweredOn))
+			if tt.expectedCondition == nil {
+				g.Expect(conditions.Has(ntnxMachine, infrav1.VMPoweredOnCondition)).To(BeFalse())
+				return
+			}
+			condition := conditions.Get(ntnxMachine, infrav1.VMPoweredOnCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(tt.expectedCondition.Status))
+			g.Expect(condition.Reason).To(Equal(tt.expectedCondition.Reason))
+			g.Expect(condition.Severity).To(Equal(tt.expectedCondition.Severity))
+			// Waiting for the power on does not fail the Machine
+			g.Expect(ntnxMachine.Status.FailureReason).To(BeNil())
+			g.Expect(ntnxMachine.Status.FailureMessage).To(BeNil())
+		})
+	}
+}
+
+func TestNutanixMachineReconcileGuestTools(t *testing.T) {
+	tests := []struct {
+		name            string
+		checkGuestTools bool
+		guestTools      *nutanixClientV3.NutanixGuestToolsStatus
+		expectedReady   bool
+		expectedStatus  corev1.ConditionStatus
+		expectedReason  string
+	}{
+		{
+			name:            "installed and ready",
+			checkGuestTools: true,
+			guestTools: &nutanixClientV3.NutanixGuestToolsStatus{
+				NgtState:    pointer.String("INSTALLED"),
+				State:       pointer.String("ENABLED"),
+				IsReachable: pointer.Bool(true),
+			},
+			expectedReady:  true,
+			expectedStatus: corev1.ConditionTrue,
+		},
+		{
+			name:            "installed but not reachable",
+			checkGuestTools: true,
+			guestTools: &nutanixClientV3.NutanixGuestToolsStatus{
+				NgtState:    pointer.String("INSTALLED"),
+				State:       pointer.String("ENABLED"),
+				IsReachable: pointer.Bool(false),
+			},
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: infrav1.GuestToolsNotReady,
+		},
+		{
+			name:            "installed but not enabled",
+			checkGuestTools: true,
+			guestTools: &nutanixClientV3.NutanixGuestToolsStatus{
+				NgtState: pointer.String("INSTALLED"),
+				State:    pointer.String("DISABLED"),
+			},
+			expectedStatus: corev1.ConditionFalse,
+			expectedReason: infrav1.GuestToolsNotReady,
+		},
+		{
+			name:            "not installed",
+			checkGuestTools: true,
+			expectedStatus:  corev1.ConditionUnknown,
+			expectedReason:  infrav1.GuestToolsNotInstalled,
+		},
+		{
+			name:            "uninstalled",
+			checkGuestTools: true,
+			guestTools: &nutanixClientV3.NutanixGuestToolsStatus{
+				NgtState: pointer.String("UNINSTALLED"),
+			},
+			expectedStatus: corev1.ConditionUnknown,
+			expectedReason: infrav1.GuestToolsNotInstalled,
+		},
+		{
+			name:          "check disabled",
+			expectedReady: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			vm := &nutanixClientV3.VMIntentResponse{
+				Metadata: &nutanixClientV3.Metadata{UUID: pointer.String("vm-uuid")},
+				Status:   &nutanixClientV3.VMDefStatus{Resources: &nutanixClientV3.VMResourcesDefStatus{}},
+			}
+			if tt.guestTools != nil {
+				vm.Status.Resources.GuestTools = &nutanixClientV3.GuestToolsStatus{NutanixGuestTools: tt.guestTools}
+			}
+			nutanixMachine := &infrav1.NutanixMachine{
+				Spec:   infrav1.NutanixMachineSpec{CheckGuestTools: tt.checkGuestTools},
+				Status: infrav1.NutanixMachineStatus{VmUUID: "vm-uuid"},
+			}
+			// The condition is removed once the check is disabled
+			conditions.MarkTrue(nutanixMachine, infrav1.GuestToolsReadyCondition)
+			reconciler := &NutanixMachineReconciler{}
+			ready := reconciler.reconcileGuestTools(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixMachine: nutanixMachine,
+			}, vm)
+			g.Expect(ready).To(Equal(tt.expectedReady))
+			if !tt.checkGuestTools {
+				g.Expect(conditions.Has(nutanixMachine, infrav1.GuestToolsReadyCondition)).To(BeFalse())
+				return
+			}
+			condition := conditions.Get(nutanixMachine, infrav1.GuestToolsReadyCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(tt.expectedStatus))
+			g.Expect(condition.Reason).To(Equal(tt.expectedReason))
+			if tt.expectedReason == infrav1.GuestToolsNotInstalled {
+				g.Expect(condition.Message).To(ContainSubstring("not installed"))
+			}
+		})
+	}
+}
+
+func TestNutanixMachineReconcileSystemDiskSize(t *testing.T) {
+	tests := []struct {
+		name             string
+		diskSizeMib      int64
+		systemDiskSize   string
+		taskStatus       string
+		expectedPending  bool
+		expectedSizeMib  int64
+		expectedResizing bool
+	}{
+		{
+			name:             "grows system disk",
+			diskSizeMib:      40 * 1024,
+			systemDiskSize:   "60Gi",
+			taskStatus:       "SUCCEEDED",
+			expectedSizeMib:  60 * 1024,
+			expectedResizing: true,
+		},
+		{
+			name:           "keeps system disk of desired size",
+			diskSizeMib:    40 * 1024,
+			systemDiskSize: "40Gi",
+			taskStatus:     "SUCCEEDED",
+		},
+		{
+			name:           "never shrinks system disk",
+			diskSizeMib:    60 * 1024,
+			systemDiskSize: "40Gi",
+			taskStatus:     "SUCCEEDED",
+		},
+		{
+			name:            "postpones update while a task is in progress",
+			diskSizeMib:     40 * 1024,
+			systemDiskSize:  "60Gi",
+			taskStatus:      "RUNNING",
+			expectedPending: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			service := &vmDescriptionTestService{
+				vm: &nutanixClientV3.VMIntentResponse{
+					Metadata: &nutanixClientV3.Metadata{UUID: pointer.String("vm-uuid")},
+					Spec: &nutanixClientV3.VM{
+						Name: pointer.String("machine"),
+						Resources: &nutanixClientV3.VMResources{
+							DiskList: []*nutanixClientV3.VMDisk{
+								{
+									DeviceProperties: &nutanixClientV3.VMDiskDeviceProperties{DeviceType: pointer.String("CDROM")},
+								},
+								{
+									DataSourceReference: &nutanixClientV3.Reference{Kind: pointer.String("image"), UUID: pointer.String(testImageUUID)},
+									DeviceProperties:    &nutanixClientV3.VMDiskDeviceProperties{DeviceType: pointer.String("DISK")},
+									DiskSizeMib:         pointer.Int64(tt.diskSizeMib),
+									DiskSizeBytes:       pointer.Int64(tt.diskSizeMib * 1024 * 1024),
+								},
+							},
+						},
+					},
+					Status: &nutanixClientV3.VMDefStatus{
+						ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "last-task"},
+					},
+				},
+				taskStatus: tt.taskStatus,
+			}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixMachineReconciler{Recorder: recorder}
+			pending, err := reconciler.reconcileSystemDiskSize(&nctx.MachineContext{
+				Context:       context.Background(),
+				NutanixClient: &nutanixClientV3.Client{V3: service},
+				NutanixMachine: &infrav1.NutanixMachine{
+					Spec:   infrav1.NutanixMachineSpec{SystemDiskSize: resource.MustParse(tt.systemDiskSize)},
+					Status: infrav1.NutanixMachineStatus{VmUUID: "vm-uuid"},
+				},
+			}, service.vm)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pending).To(Equal(tt.expectedPending))
+			if !tt.expectedResizing {
+				g.Expect(service.updates).To(BeEmpty())
+				g.Expect(recorder.Events).To(BeEmpty())
+				return
+			}
+			g.Expect(service.updates).To(HaveLen(1))
+			systemDisk := service.updates[0].Spec.Resources.DiskList[1]
+			g.Expect(*systemDisk.DiskSizeMib).To(Equal(tt.expectedSizeMib))
+			g.Expect(systemDisk.DiskSizeBytes).To(BeNil())
+			g.Expect(service.updates[0].Spec.Resources.DiskList[0].DiskSizeMib).To(BeNil())
+			g.Expect(recorder.Events).To(Receive(ContainSubstring(systemDiskResizedEventReason)))
+		})
+	}
+}
+
+func TestNutanixMachineGetBootstrapISODisk(t *testing.T) {
+	g := NewWithT(t)
+	rctx := &nctx.MachineContext{
+		Context:        context.Background(),
+		NutanixClient:  &nutanixClientV3.Client{V3: &referenceTestService{}},
+		NutanixMachine: &infrav1.NutanixMachine{},
+	}
+	reconciler := &NutanixMachineReconciler{}
+
+	disk, err := reconciler.getBootstrapISODisk(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(disk).To(BeNil())
+
+	// The CD-ROM of the bootstrap ISO is added to the disks of the VM in the create spec
+	rctx.NutanixMachine.Spec.BootstrapISO = &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: pointer.String("image")}
+	disk, err = reconciler.getBootstrapISODisk(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(*disk.DeviceProperties.DeviceType).To(Equal("CDROM"))
+	g.Expect(*disk.DataSourceReference.UUID).To(Equal(testImageUUID))
+
+	rctx.NutanixMachine.Spec.BootstrapISO.Name = pointer.String("missing")
+	_, err = reconciler.getBootstrapISODisk(rctx)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestNutanixMachineReconcileBootstrapISOEject(t *testing.T) {
+	tests := []struct {
+		name            string
+		eject           bool
+		nodeRef         *corev1.ObjectReference
+		taskStatus      string
+		expectedPending bool
+		expectedEject   bool
+	}{
+		{
+			name:          "ejects ISO once the node exists",
+			eject:         true,
+			nodeRef:       &corev1.ObjectReference{Name: "machine"},
+			taskStatus:    "SUCCEEDED",
+			expectedEject: true,
+		},
+		{
+			name:       "keeps ISO until the node exists",
+			eject:      true,
+			taskStatus: "SUCCEEDED",
+		},
+		{
+			name:       "keeps ISO if eject is disabled",
+			nodeRef:    &corev1.ObjectReference{Name: "machine"},
+			taskStatus: "SUCCEEDED",
+		},
+		{
+			name:            "postpones eject while a task is in progress",
+			eject:           true,
+			nodeRef:         &corev1.ObjectReference{Name: "machine"},
+			taskStatus:      "RUNNING",
+			expectedPending: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			guestCustomizationCDROM := &nutanixClientV3.VMDisk{
+				DeviceProperties: &nutanixClientV3.VMDiskDeviceProperties{DeviceType: pointer.String("CDROM")},
+			}
+			bootstrapISO := CreateBootstrapISODiskSpec("iso-uuid")
+			bootstrapISO.DiskSizeMib = pointer.Int64(4)
+			service := &vmDescriptionTestService{
+				vm: &nutanixClientV3.VMIntentResponse{
+					Metadata: &nutanixClientV3.Metadata{UUID: pointer.String("vm-uuid")},
+					Spec: &nutanixClientV3.VM{
+						Name: pointer.String("machine"),
+						Resources: &nutanixClientV3.VMResources{
+							DiskList: []*nutanixClientV3.VMDisk{guestCustomizationCDROM, bootstrapISO},
+						},
+					},
+					Status: &nutanixClientV3.VMDefStatus{
+						ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "last-task"},
+					},
+				},
+				taskStatus: tt.taskStatus,
+			}
+			recorder := record.NewFakeRecorder(10)
+			reconciler := &NutanixMachineReconciler{Recorder: recorder}
+			pending, err := reconciler.reconcileBootstrapISOEject(&nctx.MachineContext{
+				Context:       context.Background(),
+				NutanixClient: &nutanixClientV3.Client{V3: service},
+				NutanixMachine: &infrav1.NutanixMachine{
+					Spec: infrav1.NutanixMachineSpec{
+						BootstrapISO:      &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: pointer.String("iso-uuid")},
+						EjectBootstrapISO: tt.eject,
+					},
+					Status: infrav1.NutanixMachineStatus{VmUUID: "vm-uuid", NodeRef: tt.nodeRef},
+				},
+			}, service.vm)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pending).To(Equal(tt.expectedPending))
+			if !tt.expectedEject {
+				g.Expect(service.updates).To(BeEmpty())
+				g.Expect(recorder.Events).To(BeEmpty())
+				return
+			}
+			g.Expect(service.updates).To(HaveLen(1))
+			diskList := service.updates[0].Spec.Resources.DiskList
+			g.Expect(diskList).To(HaveLen(2))
+			g.Expect(diskList[1].DataSourceReference).To(BeNil())
+			g.Expect(diskList[1].DiskSizeMib).To(BeNil())
+			g.Expect(*diskList[1].DeviceProperties.DeviceType).To(Equal("CDROM"))
+			g.Expect(recorder.Events).To(Receive(ContainSubstring(bootstrapISOEjectedEventReason)))
+		})
+	}
+}
+
+func TestNutanixMachineReconcileObservedGeneration(t *testing.T) {
+	g := NewWithT(t)
+	ntnxMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "machine",
+			Namespace:  "default",
+			Generation: 2,
+		},
+		Spec: infrav1.NutanixMachineSpec{
+			BootstrapRef: &corev1.ObjectReference{Kind: "Secret", Name: "bootstrap", Namespace: "default"},
+		},
+		Status: infrav1.NutanixMachineStatus{ObservedGeneration: 1},
+	}
+	bootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	// The VM name is taken by a VM of another cluster
+	service := &existingVMTestService{
+		vms: []*nutanixClientV3.VMIntentResponse{
+			{
+				Metadata: &nutanixClientV3.Metadata{
+					UUID:       pointer.String("00000000-0000-0000-0000-000000000060"),
+					Categories: map[string]string{infrav1.DefaultCAPICategoryKeyForName: "other-cluster"},
+				},
+				Spec: &nutanixClientV3.VM{Name: pointer.String("machine")},
+			},
+		},
+	}
+	rctx := &nctx.MachineContext{
+		Context:       context.Background(),
+		NutanixClient: &nutanixClientV3.Client{V3: service},
+		Cluster: &capiv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+			Status:     capiv1.ClusterStatus{InfrastructureReady: true},
+		},
+		Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}},
+		NutanixCluster: &infrav1.NutanixCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}},
+		NutanixMachine: ntnxMachine,
+	}
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	reconciler := &NutanixMachineReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(bootstrapSecret).Build(),
+	}
+
+	// The generation is not observed if the reconciliation fails early
+	_, err := reconciler.reconcileNormal(rctx)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(ntnxMachine.Status.ObservedGeneration).To(Equal(int64(1)))
+
+	ntnxMachine.Spec.ProviderID = GenerateProviderID("00000000-0000-0000-0000-000000000061")
+	ntnxMachine.Status.Ready = true
+	ntnxMachine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: "machine"}
+	rctx.Machine.Spec.ProviderID = pointer.String(ntnxMachine.Spec.ProviderID)
+	rctx.Machine.Status.InfrastructureReady = true
+	_, err = reconciler.reconcileNormal(rctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ntnxMachine.Status.ObservedGeneration).To(Equal(int64(2)))
+}
+
+func TestNutanixMachineReconcileBootstrapData(t *testing.T) {
+	tests := []struct {
+		name          string
+		secret        *corev1.Secret
+		vmUUID        string
+		expectReady   bool
+		expectMessage string
+	}{
+		{
+			name:          "missing secret",
+			expectMessage: "bootstrap data secret bootstrap not found",
+		},
+		{
+			name: "empty secret",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "default"},
+				Data:       map[string][]byte{"value": {}},
+			},
+			expectMessage: "bootstrap data secret bootstrap is not populated yet",
+		},
+		{
+			name: "ready secret",
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "default"},
+				Data:       map[string][]byte{"value": []byte("#cloud-config")},
+			},
+			expectReady: true,
+		},
+		{
+			name:        "secret is not checked after the VM was created",
+			vmUUID:      "00000000-0000-0000-0000-000000000070",
+			expectReady: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if tt.secret != nil {
+				builder = builder.WithObjects(tt.secret)
+			}
+			reconciler := &NutanixMachineReconciler{Client: builder.Build()}
+			ntnxMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+				Spec: infrav1.NutanixMachineSpec{
+					BootstrapRef: &corev1.ObjectReference{Kind: "Secret", Name: "bootstrap", Namespace: "default"},
+				},
+				Status: infrav1.NutanixMachineStatus{VmUUID: tt.vmUUID},
+			}
+			rctx := &nctx.MachineContext{
+				Context: context.Background(),
+				Cluster: &capiv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+					Status:     capiv1.ClusterStatus{InfrastructureReady: true},
+				},
+				Machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"}},
+				NutanixMachine: ntnxMachine,
+			}
+
+			ready, err := reconciler.reconcileBootstrapData(rctx)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(ready).To(Equal(tt.expectReady))
+			condition := conditions.Get(ntnxMachine, infrav1.BootstrapDataReadyCondition)
+			g.Expect(condition).NotTo(BeNil())
+			if tt.expectReady {
+				g.Expect(condition.Status).To(Equal(corev1.ConditionTrue))
+				return
+			}
+			g.Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+			g.Expect(condition.Reason).To(Equal(infrav1.WaitingForBootstrapData))
+			g.Expect(condition.Message).To(Equal(tt.expectMessage))
+
+			// The reconciliation is requeued without touching the VM provisioning condition
+			result, err := reconciler.reconcileNormal(rctx)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.RequeueAfter).To(Equal(externalConditionMinRequeueInterval))
+			g.Expect(conditions.Get(ntnxMachine, infrav1.VMProvisionedCondition)).To(BeNil())
+		})
+	}
+}
+
+func TestGetSerialPortList(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(getSerialPortList(nil)).To(BeNil())
+
+	serialPortList := getSerialPortList([]infrav1.NutanixSerialPort{
+		{Index: 0, IsConnected: true},
+		{Index: 2},
+	})
+	g.Expect(serialPortList).To(Equal([]*nutanixClientV3.VMSerialPort{
+		{Index: pointer.Int64(0), IsConnected: pointer.Bool(true)},
+		{Index: pointer.Int64(2), IsConnected: pointer.Bool(false)},
+	}))
+}
+
+// roleCategoriesTestService is a Prism v3 service holding all category keys and the given category values, and
+// recording the created category values
+type roleCategoriesTestService struct {
+	nutanixClientV3.Service
+	values  map[string]string
+	created map[string]string
+}
+
+func (s *roleCategoriesTestService) GetCategoryKey(_ context.Context, name string) (*nutanixClientV3.CategoryKeyStatus, error) {
+	return &nutanixClientV3.CategoryKeyStatus{Name: pointer.String(name)}, nil
+}
+
+func (s *roleCategoriesTestService) GetCategoryValue(_ context.Context, key, value string) (*nutanixClientV3.CategoryValueStatus, error) {
+	if s.values[key] != value && s.created[key] != value {
+		return nil, fmt.Errorf("CATEGORY_NAME_VALUE_MISMATCH: value %s not found in category %s", value, key)
+	}
+	return &nutanixClientV3.CategoryValueStatus{Value: pointer.String(value)}, nil
+}
+
+func (s *roleCategoriesTestService) CreateOrUpdateCategoryValue(_ context.Context, key string, body *nutanixClientV3.CategoryValue) (*nutanixClientV3.CategoryValueStatus, error) {
+	s.created[key] = *body.Value
+	return &nutanixClientV3.CategoryValueStatus{Value: body.Value}, nil
+}
+
+func TestNutanixMachineGetMachineCategoryIdentifiers(t *testing.T) {
+	const clusterName = "test-cluster"
+	machineSet := &capiv1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "md-0-abcde",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: capiv1.GroupVersion.String(), Kind: "MachineDeployment", Name: "md-0"},
+			},
+		},
+	}
+	tests := []struct {
+		name               string
+		roleCategories     bool
+		machine            *capiv1.Machine
+		expectedCategories map[string]string
+	}{
+		{
+			name:           "control plane machine",
+			roleCategories: true,
+			machine: &capiv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cp-abcde",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: "controlplane.cluster.x-k8s.io/v1beta1", Kind: "KubeadmControlPlane", Name: "cp"},
+					},
+				},
+			},
+			expectedCategories: map[string]string{
+				infrav1.DefaultCAPICategoryKeyForName:        clusterName,
+				infrav1.DefaultCAPICategoryKeyForMachineRole: infrav1.MachineRoleControlPlane,
+			},
+		},
+		{
+			name:           "worker machine of a MachineDeployment",
+			roleCategories: true,
+			machine: &capiv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "md-0-abcde-fghij",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: capiv1.GroupVersion.String(), Kind: "MachineSet", Name: machineSet.Name},
+					},
+				},
+			},
+			expectedCategories: map[string]string{
+				infrav1.DefaultCAPICategoryKeyForName:              clusterName,
+				infrav1.DefaultCAPICategoryKeyForMachineRole:       infrav1.MachineRoleWorker,
+				infrav1.DefaultCAPICategoryKeyForMachineDeployment: "md-0",
+			},
+		},
+		{
+			name:           "worker machine without owner",
+			roleCategories: true,
+			machine:        &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"}},
+			expectedCategories: map[string]string{
+				infrav1.DefaultCAPICategoryKeyForName:        clusterName,
+				infrav1.DefaultCAPICategoryKeyForMachineRole: infrav1.MachineRoleWorker,
+			},
+		},
+		{
+			name: "role categories disabled",
+			machine: &capiv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cp-abcde",
+					Namespace: "default",
+					Labels:    map[string]string{capiv1.MachineControlPlaneLabelName: ""},
+				},
+			},
+			expectedCategories: map[string]string{
+				infrav1.DefaultCAPICategoryKeyForName: clusterName,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(capiv1.AddToScheme(scheme)).To(Succeed())
+			service := &roleCategoriesTestService{
+				values:  map[string]string{infrav1.DefaultCAPICategoryKeyForName: clusterName},
+				created: map[string]string{},
+			}
+			reconciler := &NutanixMachineReconciler{
+				Client:           fake.NewClientBuilder().WithScheme(scheme).WithObjects(machineSet).Build(),
+				controllerConfig: &ControllerConfig{MachineRoleCategories: tt.roleCategories},
+			}
+			rctx := &nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: service},
+				Cluster:        &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: clusterName}},
+				Machine:        tt.machine,
+				NutanixMachine: &infrav1.NutanixMachine{},
+			}
+
+			categoryIdentifiers, err := reconciler.getMachineCategoryIdentifiers(rctx)
+			g.Expect(err).NotTo(HaveOccurred())
+			categories, err := GetCategoryVMSpec(rctx.Context, rctx.NutanixClient, categoryIdentifiers)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(categories).To(Equal(tt.expectedCategories))
+			// The missing category values are created
+			delete(tt.expectedCategories, infrav1.DefaultCAPICategoryKeyForName)
+			g.Expect(service.created).To(Equal(tt.expectedCategories))
+		})
+	}
+}
+
+func TestNutanixMachineAddBootTypeToVM(t *testing.T) {
+	tests := []struct {
+		name              string
+		bootType          infrav1.NutanixBootType
+		secureBoot        *bool
+		expectBootType    *string
+		expectMachineType *string
+		expectError       bool
+	}{
+		{
+			name: "legacy",
+		},
+		{
+			name:           "uefi",
+			bootType:       infrav1.NutanixBootTypeUEFI,
+			expectBootType: pointer.String("UEFI"),
+		},
+		{
+			name:              "uefi with secure boot",
+			bootType:          infrav1.NutanixBootTypeUEFI,
+			secureBoot:        pointer.Bool(true),
+			expectBootType:    pointer.String("SECURE_BOOT"),
+			expectMachineType: pointer.String("Q35"),
+		},
+		{
+			name:           "uefi with secure boot disabled",
+			bootType:       infrav1.NutanixBootTypeUEFI,
+			secureBoot:     pointer.Bool(false),
+			expectBootType: pointer.String("UEFI"),
+		},
+		{
+			name:        "legacy with secure boot",
+			bootType:    infrav1.NutanixBootTypeLegacy,
+			secureBoot:  pointer.Bool(true),
+			expectError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			ntnxMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine", Namespace: "default"},
+				Spec:       infrav1.NutanixMachineSpec{BootType: tt.bootType, SecureBoot: tt.secureBoot},
+			}
+			rctx := &nctx.MachineContext{Context: context.Background(), NutanixMachine: ntnxMachine}
+			vmSpec := &nutanixClientV3.VM{Resources: &nutanixClientV3.VMResources{}}
+
+			err := (&NutanixMachineReconciler{}).addBootTypeToVM(rctx, vmSpec)
+			if tt.expectError {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(conditions.GetReason(ntnxMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.VMBootTypeInvalid))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			if tt.expectBootType == nil {
+				g.Expect(vmSpec.Resources.BootConfig).To(BeNil())
+			} else {
+				g.Expect(vmSpec.Resources.BootConfig.BootType).To(Equal(tt.expectBootType))
+			}
+			g.Expect(vmSpec.Resources.MachineType).To(Equal(tt.expectMachineType))
+		})
+	}
+}
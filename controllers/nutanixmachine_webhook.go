@@ -0,0 +1,291 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+//+kubebuilder:webhook:path=/mutate-infrastructure-cluster-x-k8s-io-v1beta1-nutanixmachine,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=nutanixmachines,verbs=create;update,versions=v1beta1,name=default.nutanixmachine.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-nutanixmachine,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=nutanixmachines,verbs=create;update,versions=v1beta1,name=validation.nutanixmachine.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+
+// NutanixMachineValidator defaults and validates NutanixMachine objects on admission
+type NutanixMachineValidator struct {
+	Client            client.Client
+	SecretInformer    coreinformers.SecretInformer
+	ConfigMapInformer coreinformers.ConfigMapInformer
+
+	// getNutanixClient returns the client used to look up the resources referenced by the NutanixMachine in Prism Central
+	getNutanixClient func(ctx context.Context, nutanixMachine *infrav1.NutanixMachine) (*nutanixClientV3.Client, error)
+}
+
+var (
+	_ admission.CustomDefaulter = &NutanixMachineValidator{}
+	_ admission.CustomValidator = &NutanixMachineValidator{}
+)
+
+func NewNutanixMachineValidator(client client.Client, secretInformer coreinformers.SecretInformer, configMapInformer coreinformers.ConfigMapInformer) *NutanixMachineValidator {
+	v := &NutanixMachineValidator{
+		Client:            client,
+		SecretInformer:    secretInformer,
+		ConfigMapInformer: configMapInformer,
+	}
+	v.getNutanixClient = v.createNutanixClient
+	return v
+}
+
+// createNutanixClient creates the client of the NutanixCluster of the cluster the NutanixMachine belongs to
+func (v *NutanixMachineValidator) createNutanixClient(ctx context.Context, nutanixMachine *infrav1.NutanixMachine) (*nutanixClientV3.Client, error) {
+	nutanixCluster, err := v.getNutanixCluster(ctx, nutanixMachine)
+	if err != nil {
+		return nil, err
+	}
+	return CreateNutanixClient(ctx, v.SecretInformer, v.ConfigMapInformer, nutanixCluster)
+}
+
+// getNutanixCluster returns the NutanixCluster of the cluster the NutanixMachine belongs to
+func (v *NutanixMachineValidator) getNutanixCluster(ctx context.Context, nutanixMachine *infrav1.NutanixMachine) (*infrav1.NutanixCluster, error) {
+	cluster := &capiv1.Cluster{}
+	clusterKey := client.ObjectKey{
+		Namespace: nutanixMachine.Namespace,
+		Name:      nutanixMachine.Labels[capiv1.ClusterLabelName],
+	}
+	if err := v.Client.Get(ctx, clusterKey, cluster); err != nil {
+		return nil, fmt.Errorf("failed to get cluster %s: %v", clusterKey, err)
+	}
+	if cluster.Spec.InfrastructureRef == nil {
+		return nil, fmt.Errorf("infrastructureRef of cluster %s is not set", clusterKey)
+	}
+	nutanixCluster := &infrav1.NutanixCluster{}
+	nutanixClusterKey := client.ObjectKey{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Spec.InfrastructureRef.Name,
+	}
+	if err := v.Client.Get(ctx, nutanixClusterKey, nutanixCluster); err != nil {
+		return nil, fmt.Errorf("failed to get NutanixCluster %s: %v", nutanixClusterKey, err)
+	}
+	return nutanixCluster, nil
+}
+
+// SetupWebhookWithManager registers the NutanixMachine defaulting and validating webhooks with the Manager.
+func (v *NutanixMachineValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.NutanixMachine{}).
+		WithDefaulter(v).
+		WithValidator(v).
+		Complete()
+}
+
+// Default implements admission.CustomDefaulter
+func (v *NutanixMachineValidator) Default(_ context.Context, obj runtime.Object) error {
+	nutanixMachine, ok := obj.(*infrav1.NutanixMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixMachine but got %T", obj))
+	}
+	allErrs := DefaultNutanixMachineSpec(field.NewPath("spec"), &nutanixMachine.Spec)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixMachineKind).GroupKind(), nutanixMachine.Name, allErrs)
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *NutanixMachineValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	nutanixMachine, ok := obj.(*infrav1.NutanixMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixMachine but got %T", obj))
+	}
+	if err := validateMachineSpec(nutanixMachine); err != nil {
+		return err
+	}
+	if err := v.validateVMName(ctx, nutanixMachine); err != nil {
+		return err
+	}
+	if isPrismValidationSkipped(ctx, nutanixMachine) {
+		return nil
+	}
+	getClient := v.prismClientGetter(ctx, nutanixMachine)
+	return v.validateOwner(ctx, nutanixMachine, getClient)
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (v *NutanixMachineValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldNutanixMachine, ok := oldObj.(*infrav1.NutanixMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixMachine but got %T", oldObj))
+	}
+	nutanixMachine, ok := newObj.(*infrav1.NutanixMachine)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixMachine but got %T", newObj))
+	}
+	if err := validateMachineSpec(nutanixMachine); err != nil {
+		return err
+	}
+	// The VM name is rendered from the template whenever the VM is looked up
+	if nutanixMachine.Spec.VMNameTemplate != oldNutanixMachine.Spec.VMNameTemplate {
+		allErrs := field.ErrorList{
+			field.Forbidden(field.NewPath("spec", "vmNameTemplate"), "cannot be changed"),
+		}
+		return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixMachineKind).GroupKind(), nutanixMachine.Name, allErrs)
+	}
+	// The adopted VM cannot be replaced
+	if nutanixMachine.Spec.ExistingVMUUID != oldNutanixMachine.Spec.ExistingVMUUID {
+		allErrs := field.ErrorList{
+			field.Forbidden(field.NewPath("spec", "existingVMUUID"), "cannot be changed"),
+		}
+		return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixMachineKind).GroupKind(), nutanixMachine.Name, allErrs)
+	}
+	// The system disk of an existing VM can only be grown
+	if nutanixMachine.Spec.SystemDiskSize.Cmp(oldNutanixMachine.Spec.SystemDiskSize) < 0 {
+		allErrs := field.ErrorList{
+			field.Forbidden(field.NewPath("spec", "systemDiskSize"), fmt.Sprintf("cannot be decreased from %s to %s", oldNutanixMachine.Spec.SystemDiskSize.String(), nutanixMachine.Spec.SystemDiskSize.String())),
+		}
+		return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixMachineKind).GroupKind(), nutanixMachine.Name, allErrs)
+	}
+	// Only look up resources in Prism Central if they changed to not depend on Prism Central for unrelated updates
+	if apiequality.Semantic.DeepEqual(oldNutanixMachine.Spec.Owner, nutanixMachine.Spec.Owner) || isPrismValidationSkipped(ctx, nutanixMachine) {
+		return nil
+	}
+	return v.validateOwner(ctx, nutanixMachine, v.prismClientGetter(ctx, nutanixMachine))
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *NutanixMachineValidator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+// isPrismValidationSkipped returns true if the verification of the NutanixMachine against Prism Central is skipped with
+// an annotation
+func isPrismValidationSkipped(ctx context.Context, nutanixMachine *infrav1.NutanixMachine) bool {
+	if _, ok := nutanixMachine.GetAnnotations()[infrav1.NutanixMachineSkipPrismValidationAnnotation]; !ok {
+		return false
+	}
+	log := ctrl.LoggerFrom(ctx)
+	log.Info(fmt.Sprintf("skipping validation of NutanixMachine %s against Prism Central because annotation %s is set", nutanixMachine.Name, infrav1.NutanixMachineSkipPrismValidationAnnotation))
+	return true
+}
+
+// prismClientGetter returns a function creating the client of the NutanixMachine on its first call, so all lookups of
+// an admission request share one client and Prism Central is not contacted if nothing needs to be looked up
+func (v *NutanixMachineValidator) prismClientGetter(ctx context.Context, nutanixMachine *infrav1.NutanixMachine) func() (*nutanixClientV3.Client, error) {
+	var c *nutanixClientV3.Client
+	var err error
+	created := false
+	return func() (*nutanixClientV3.Client, error) {
+		if created {
+			return c, err
+		}
+		created = true
+		c, err = v.getNutanixClient(ctx, nutanixMachine)
+		if err != nil {
+			err = fmt.Errorf("failed to create client to validate NutanixMachine against Prism Central. Set annotation %s to skip the validation: %v", infrav1.NutanixMachineSkipPrismValidationAnnotation, err)
+		}
+		return c, err
+	}
+}
+
+// validateMachineSpec verifies the fields of the NutanixMachine spec that do not require Prism Central
+func validateMachineSpec(nutanixMachine *infrav1.NutanixMachine) error {
+	allErrs := ValidateNutanixMachineSpec(field.NewPath("spec"), &nutanixMachine.Spec)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixMachineKind).GroupKind(), nutanixMachine.Name, allErrs)
+}
+
+// validateVMName verifies that the VM name template of the NutanixMachine renders a valid VM name for the owning
+// Cluster and Machine. The verification is skipped if the NutanixMachine is not owned by a Machine yet.
+func (v *NutanixMachineValidator) validateVMName(ctx context.Context, nutanixMachine *infrav1.NutanixMachine) error {
+	log := ctrl.LoggerFrom(ctx)
+	if nutanixMachine.Spec.VMNameTemplate == "" {
+		return nil
+	}
+	if nutanixMachine.Labels[capiv1.ClusterLabelName] == "" {
+		log.V(1).Info(fmt.Sprintf("skipping verification of VM name of NutanixMachine %s without %s label", nutanixMachine.Name, capiv1.ClusterLabelName))
+		return nil
+	}
+	machine, err := util.GetOwnerMachine(ctx, v.Client, nutanixMachine.ObjectMeta)
+	if err != nil {
+		return apierrors.NewInternalError(fmt.Errorf("failed to get owner Machine of NutanixMachine %s: %v", nutanixMachine.Name, err))
+	}
+	if machine == nil {
+		log.V(1).Info(fmt.Sprintf("skipping verification of VM name of NutanixMachine %s without owner Machine", nutanixMachine.Name))
+		return nil
+	}
+	cluster := &capiv1.Cluster{}
+	clusterKey := client.ObjectKey{
+		Namespace: nutanixMachine.Namespace,
+		Name:      nutanixMachine.Labels[capiv1.ClusterLabelName],
+	}
+	if err := v.Client.Get(ctx, clusterKey, cluster); err != nil {
+		return apierrors.NewInternalError(fmt.Errorf("failed to get cluster %s: %v", clusterKey, err))
+	}
+	if _, err := renderVMName(nutanixMachine.Spec.VMNameTemplate, vmNameTemplateData{Cluster: cluster, Machine: machine, NutanixMachine: nutanixMachine}); err != nil {
+		allErrs := field.ErrorList{
+			field.Invalid(field.NewPath("spec", "vmNameTemplate"), nutanixMachine.Spec.VMNameTemplate, err.Error()),
+		}
+		return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixMachineKind).GroupKind(), nutanixMachine.Name, allErrs)
+	}
+	return nil
+}
+
+// validateOwner verifies that the owner of the NutanixMachine is a user of Prism Central. The verification is skipped if
+// the NutanixMachine does not belong to a cluster yet, or if the users cannot be looked up, e.g. since the Prism
+// Central credentials are not allowed to list users.
+func (v *NutanixMachineValidator) validateOwner(ctx context.Context, nutanixMachine *infrav1.NutanixMachine, getClient func() (*nutanixClientV3.Client, error)) error {
+	log := ctrl.LoggerFrom(ctx)
+	owner := nutanixMachine.Spec.Owner
+	if owner == nil {
+		return nil
+	}
+	if nutanixMachine.Labels[capiv1.ClusterLabelName] == "" {
+		log.V(1).Info(fmt.Sprintf("skipping verification of owner of NutanixMachine %s without %s label", nutanixMachine.Name, capiv1.ClusterLabelName))
+		return nil
+	}
+
+	c, err := getClient()
+	if err != nil {
+		return apierrors.NewInternalError(err)
+	}
+	if _, err := GetUserUUID(ctx, c, owner.Name, owner.UUID); err != nil {
+		if !errors.Is(err, errUserNotFound) {
+			log.V(1).Info(fmt.Sprintf("skipping verification of owner of NutanixMachine %s: %v", nutanixMachine.Name, err))
+			return nil
+		}
+		allErrs := field.ErrorList{
+			field.NotFound(field.NewPath("spec", "owner"), getResourceIdentifierString(*owner)),
+		}
+		return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixMachineKind).GroupKind(), nutanixMachine.Name, allErrs)
+	}
+	return nil
+}
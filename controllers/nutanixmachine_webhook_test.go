@@ -0,0 +1,436 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+func TestNutanixMachineValidatorValidateVolumeGroups(t *testing.T) {
+	tests := []struct {
+		name         string
+		volumeGroups []infrav1.NutanixResourceIdentifier
+		expectError  bool
+	}{
+		{
+			name: "distinct volume groups",
+			volumeGroups: []infrav1.NutanixResourceIdentifier{
+				{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("vg-1")},
+				{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("vg-2")},
+				{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr("00000000-0000-0000-0000-000000000001")},
+			},
+		},
+		{
+			name: "duplicate volume group name",
+			volumeGroups: []infrav1.NutanixResourceIdentifier{
+				{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("vg-1")},
+				{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("vg-1")},
+			},
+			expectError: true,
+		},
+		{
+			name: "duplicate volume group uuid",
+			volumeGroups: []infrav1.NutanixResourceIdentifier{
+				{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr("00000000-0000-0000-0000-000000000001")},
+				{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr("00000000-0000-0000-0000-000000000001")},
+			},
+			expectError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			nutanixMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+				},
+				Spec: infrav1.NutanixMachineSpec{
+					VolumeGroups: tt.volumeGroups,
+				},
+			}
+			v := NewNutanixMachineValidator(nil, nil, nil)
+			for _, err := range []error{
+				v.ValidateCreate(context.Background(), nutanixMachine),
+				v.ValidateUpdate(context.Background(), nutanixMachine, nutanixMachine),
+			} {
+				if tt.expectError {
+					g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				} else {
+					g.Expect(err).NotTo(HaveOccurred())
+				}
+			}
+		})
+	}
+}
+
+func TestNutanixMachineValidatorValidateSystemDiskStorageContainer(t *testing.T) {
+	tests := []struct {
+		name             string
+		storageContainer *infrav1.NutanixResourceIdentifier
+		expectError      bool
+	}{
+		{
+			name:             "storage container referenced by uuid",
+			storageContainer: &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr("00000000-0000-0000-0000-000000000010")},
+		},
+		{
+			name:             "invalid storage container uuid",
+			storageContainer: &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr("not-a-uuid")},
+			expectError:      true,
+		},
+		{
+			name:             "storage container referenced by name",
+			storageContainer: &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("ssd")},
+			expectError:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			nutanixMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+					Labels:    map[string]string{capiv1.ClusterLabelName: "test-cluster"},
+				},
+				Spec: infrav1.NutanixMachineSpec{
+					Cluster:                    infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("pe")},
+					SystemDiskStorageContainer: tt.storageContainer,
+				},
+			}
+			// The storage container is verified without Prism Central
+			v := &NutanixMachineValidator{
+				getNutanixClient: func(_ context.Context, _ *infrav1.NutanixMachine) (*nutanixClientV3.Client, error) {
+					return nil, fmt.Errorf("Prism Central must not be contacted")
+				},
+			}
+			err := v.ValidateCreate(context.Background(), nutanixMachine)
+			if tt.expectError {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+// forbiddenUserTestService is a Prism v3 service whose users cannot be listed with the credentials of the client
+type forbiddenUserTestService struct {
+	nutanixClientV3.Service
+}
+
+func (s *forbiddenUserTestService) ListUser(_ context.Context, _ *nutanixClientV3.DSMetadata) (*nutanixClientV3.UserListResponse, error) {
+	return nil, fmt.Errorf("status: 403 Forbidden")
+}
+
+func TestNutanixMachineValidatorValidateOwner(t *testing.T) {
+	tests := []struct {
+		name        string
+		owner       *infrav1.NutanixResourceIdentifier
+		service     nutanixClientV3.Service
+		expectError bool
+	}{
+		{
+			name:    "owner exists",
+			owner:   &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("admin@example.com")},
+			service: &referenceTestService{},
+		},
+		{
+			name:    "owner exists by uuid",
+			owner:   &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr(testUserUUID)},
+			service: &referenceTestService{},
+		},
+		{
+			name:        "owner does not exist",
+			owner:       &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("guest@example.com")},
+			service:     &referenceTestService{},
+			expectError: true,
+		},
+		{
+			name:        "owner does not exist by uuid",
+			owner:       &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr("00000000-0000-0000-0000-000000000099")},
+			service:     &referenceTestService{},
+			expectError: true,
+		},
+		{
+			name:        "invalid owner uuid",
+			owner:       &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr("not-a-uuid")},
+			service:     &referenceTestService{},
+			expectError: true,
+		},
+		{
+			name:    "users cannot be listed",
+			owner:   &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("guest@example.com")},
+			service: &forbiddenUserTestService{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			nutanixMachine := &infrav1.NutanixMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: "default",
+					Labels:    map[string]string{capiv1.ClusterLabelName: "test-cluster"},
+				},
+				Spec: infrav1.NutanixMachineSpec{
+					Owner: tt.owner,
+				},
+			}
+			v := &NutanixMachineValidator{
+				getNutanixClient: func(_ context.Context, _ *infrav1.NutanixMachine) (*nutanixClientV3.Client, error) {
+					return &nutanixClientV3.Client{V3: tt.service}, nil
+				},
+			}
+			err := v.ValidateCreate(context.Background(), nutanixMachine)
+			if tt.expectError {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+func TestNutanixMachineValidatorPrismClient(t *testing.T) {
+	g := NewWithT(t)
+	nutanixMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			Labels:    map[string]string{capiv1.ClusterLabelName: "test-cluster"},
+		},
+		Spec: infrav1.NutanixMachineSpec{
+			Owner: &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("admin@example.com")},
+		},
+	}
+	service := &referenceTestService{}
+	clientCreated := 0
+	v := &NutanixMachineValidator{
+		getNutanixClient: func(_ context.Context, _ *infrav1.NutanixMachine) (*nutanixClientV3.Client, error) {
+			clientCreated++
+			return &nutanixClientV3.Client{V3: service}, nil
+		},
+	}
+
+	// All lookups of an admission request share one client
+	g.Expect(v.ValidateCreate(context.Background(), nutanixMachine)).To(Succeed())
+	g.Expect(clientCreated).To(Equal(1))
+
+	// The validation fails if Prism Central cannot be reached
+	v.getNutanixClient = func(_ context.Context, _ *infrav1.NutanixMachine) (*nutanixClientV3.Client, error) {
+		clientCreated++
+		return nil, fmt.Errorf("prism central not reachable")
+	}
+	err := v.ValidateCreate(context.Background(), nutanixMachine)
+	g.Expect(apierrors.IsInternalError(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring(infrav1.NutanixMachineSkipPrismValidationAnnotation))
+	g.Expect(clientCreated).To(Equal(2))
+
+	// The validation against Prism Central is skipped with the annotation
+	nutanixMachine.Annotations = map[string]string{infrav1.NutanixMachineSkipPrismValidationAnnotation: ""}
+	g.Expect(v.ValidateCreate(context.Background(), nutanixMachine)).To(Succeed())
+	updatedNutanixMachine := nutanixMachine.DeepCopy()
+	updatedNutanixMachine.Spec.Owner.Name = utils.StringPtr("guest@example.com")
+	g.Expect(v.ValidateUpdate(context.Background(), nutanixMachine, updatedNutanixMachine)).To(Succeed())
+	g.Expect(clientCreated).To(Equal(2))
+}
+
+func TestNutanixMachineValidatorValidateUpdateSystemDiskSize(t *testing.T) {
+	g := NewWithT(t)
+	oldNutanixMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       infrav1.NutanixMachineSpec{SystemDiskSize: resource.MustParse("40Gi")},
+	}
+	v := &NutanixMachineValidator{}
+
+	grown := oldNutanixMachine.DeepCopy()
+	grown.Spec.SystemDiskSize = resource.MustParse("60Gi")
+	g.Expect(v.ValidateUpdate(context.Background(), oldNutanixMachine, grown)).To(Succeed())
+
+	// The same size in another format is not a change
+	unchanged := oldNutanixMachine.DeepCopy()
+	unchanged.Spec.SystemDiskSize = resource.MustParse("40960Mi")
+	g.Expect(v.ValidateUpdate(context.Background(), oldNutanixMachine, unchanged)).To(Succeed())
+
+	shrunk := oldNutanixMachine.DeepCopy()
+	shrunk.Spec.SystemDiskSize = resource.MustParse("30Gi")
+	err := v.ValidateUpdate(context.Background(), oldNutanixMachine, shrunk)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("spec.systemDiskSize"))
+}
+
+func TestNutanixMachineValidatorValidateSecureBoot(t *testing.T) {
+	g := NewWithT(t)
+	oldNutanixMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       infrav1.NutanixMachineSpec{BootType: infrav1.NutanixBootTypeUEFI, SecureBoot: utils.BoolPtr(true)},
+	}
+	v := &NutanixMachineValidator{}
+	g.Expect(v.ValidateUpdate(context.Background(), oldNutanixMachine, oldNutanixMachine.DeepCopy())).To(Succeed())
+
+	legacy := oldNutanixMachine.DeepCopy()
+	legacy.Spec.BootType = infrav1.NutanixBootTypeLegacy
+	err := v.ValidateUpdate(context.Background(), oldNutanixMachine, legacy)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("spec.secureBoot"))
+}
+
+func TestNutanixMachineValidatorValidateSystemDiskBus(t *testing.T) {
+	g := NewWithT(t)
+	nutanixMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       infrav1.NutanixMachineSpec{SystemDiskBus: infrav1.NutanixDiskBusSATA},
+	}
+	v := &NutanixMachineValidator{}
+	g.Expect(v.ValidateCreate(context.Background(), nutanixMachine)).To(Succeed())
+
+	nutanixMachine.Spec.SystemDiskBus = "IDE"
+	err := v.ValidateCreate(context.Background(), nutanixMachine)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("spec.systemDiskBus"))
+}
+
+func TestNutanixMachineValidatorValidateSerialPorts(t *testing.T) {
+	g := NewWithT(t)
+	nutanixMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       infrav1.NutanixMachineSpec{SerialPorts: []infrav1.NutanixSerialPort{{Index: 0}, {Index: 1}}},
+	}
+	v := &NutanixMachineValidator{}
+	g.Expect(v.ValidateCreate(context.Background(), nutanixMachine)).To(Succeed())
+
+	nutanixMachine.Spec.SerialPorts[1].Index = 0
+	err := v.ValidateCreate(context.Background(), nutanixMachine)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("spec.serialPorts[1].index"))
+}
+
+func TestNutanixMachineValidatorValidateExistingVMUUID(t *testing.T) {
+	g := NewWithT(t)
+	oldNutanixMachine := &infrav1.NutanixMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec:       infrav1.NutanixMachineSpec{ExistingVMUUID: "00000000-0000-0000-0000-000000000001"},
+	}
+	v := &NutanixMachineValidator{}
+	g.Expect(v.ValidateUpdate(context.Background(), oldNutanixMachine, oldNutanixMachine.DeepCopy())).To(Succeed())
+
+	changed := oldNutanixMachine.DeepCopy()
+	changed.Spec.ExistingVMUUID = "00000000-0000-0000-0000-000000000002"
+	err := v.ValidateUpdate(context.Background(), oldNutanixMachine, changed)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("spec.existingVMUUID"))
+}
+
+func TestNutanixMachineValidatorValidateVMName(t *testing.T) {
+	g := NewWithT(t)
+	scheme := runtime.NewScheme()
+	g.Expect(capiv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+
+	cluster := &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "default"}}
+	machine := &capiv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "default"}}
+	newNutanixMachine := func(nameTemplate string, owned bool) *infrav1.NutanixMachine {
+		nutanixMachine := &infrav1.NutanixMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+				Labels:    map[string]string{capiv1.ClusterLabelName: "test-cluster"},
+			},
+			Spec: infrav1.NutanixMachineSpec{VMNameTemplate: nameTemplate},
+		}
+		if owned {
+			nutanixMachine.OwnerReferences = []metav1.OwnerReference{{
+				APIVersion: capiv1.GroupVersion.String(),
+				Kind:       "Machine",
+				Name:       machine.Name,
+			}}
+		}
+		return nutanixMachine
+	}
+	longTemplate := "{{ .Cluster.Name }}-" + strings.Repeat("a", maxVMNameLength)
+
+	tests := []struct {
+		name           string
+		nutanixMachine *infrav1.NutanixMachine
+		expectError    bool
+	}{
+		{
+			name:           "valid template",
+			nutanixMachine: newNutanixMachine("{{ .Cluster.Name }}-{{ .Machine.Name }}", true),
+		},
+		{
+			name:           "too long name",
+			nutanixMachine: newNutanixMachine(longTemplate, true),
+			expectError:    true,
+		},
+		{
+			name:           "unknown field",
+			nutanixMachine: newNutanixMachine("{{ .Machine.Role }}", true),
+			expectError:    true,
+		},
+		{
+			name:           "invalid template without owner Machine",
+			nutanixMachine: newNutanixMachine("{{ .Machine.Name ", false),
+			expectError:    true,
+		},
+		{
+			name:           "rendering skipped without owner Machine",
+			nutanixMachine: newNutanixMachine(longTemplate, false),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			v := &NutanixMachineValidator{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, machine).Build(),
+			}
+			err := v.ValidateCreate(context.Background(), tt.nutanixMachine)
+			if tt.expectError {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				g.Expect(err.Error()).To(ContainSubstring("spec.vmNameTemplate"))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+
+	// The template cannot be changed once set
+	v := &NutanixMachineValidator{}
+	oldNutanixMachine := newNutanixMachine("{{ .Machine.Name }}", false)
+	changed := oldNutanixMachine.DeepCopy()
+	changed.Spec.VMNameTemplate = "prod-{{ .Machine.Name }}"
+	err := v.ValidateUpdate(context.Background(), oldNutanixMachine, changed)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(v.ValidateUpdate(context.Background(), oldNutanixMachine, oldNutanixMachine.DeepCopy())).To(Succeed())
+}
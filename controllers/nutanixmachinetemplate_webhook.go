@@ -0,0 +1,158 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/cluster-api/util/topology"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+//+kubebuilder:webhook:path=/mutate-infrastructure-cluster-x-k8s-io-v1beta1-nutanixmachinetemplate,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=nutanixmachinetemplates,verbs=create;update,versions=v1beta1,name=default.nutanixmachinetemplate.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-nutanixmachinetemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=nutanixmachinetemplates,verbs=create;update,versions=v1beta1,name=validation.nutanixmachinetemplate.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+
+// NutanixMachineTemplateValidator defaults and validates NutanixMachineTemplate objects on admission
+type NutanixMachineTemplateValidator struct{}
+
+var (
+	_ admission.CustomDefaulter = &NutanixMachineTemplateValidator{}
+	_ admission.CustomValidator = &NutanixMachineTemplateValidator{}
+)
+
+func NewNutanixMachineTemplateValidator() *NutanixMachineTemplateValidator {
+	return &NutanixMachineTemplateValidator{}
+}
+
+// SetupWebhookWithManager registers the NutanixMachineTemplate defaulting and validating webhooks with the Manager.
+func (v *NutanixMachineTemplateValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&infrav1.NutanixMachineTemplate{}).
+		WithDefaulter(v).
+		WithValidator(v).
+		Complete()
+}
+
+// Default implements admission.CustomDefaulter
+func (v *NutanixMachineTemplateValidator) Default(_ context.Context, obj runtime.Object) error {
+	template, ok := obj.(*infrav1.NutanixMachineTemplate)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixMachineTemplate but got %T", obj))
+	}
+	allErrs := DefaultNutanixMachineSpec(field.NewPath("spec", "template", "spec"), &template.Spec.Template.Spec)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixMachineTemplateKind).GroupKind(), template.Name, allErrs)
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *NutanixMachineTemplateValidator) ValidateCreate(_ context.Context, obj runtime.Object) error {
+	template, ok := obj.(*infrav1.NutanixMachineTemplate)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixMachineTemplate but got %T", obj))
+	}
+	allErrs := ValidateNutanixMachineSpec(field.NewPath("spec", "template", "spec"), &template.Spec.Template.Spec)
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixMachineTemplateKind).GroupKind(), template.Name, allErrs)
+}
+
+// ValidateUpdate implements admission.CustomValidator. The spec of the template is immutable, since Machines created
+// from the template are not updated. Changes of the template metadata are allowed.
+func (v *NutanixMachineTemplateValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldTemplate, ok := oldObj.(*infrav1.NutanixMachineTemplate)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixMachineTemplate but got %T", oldObj))
+	}
+	template, ok := newObj.(*infrav1.NutanixMachineTemplate)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a NutanixMachineTemplate but got %T", newObj))
+	}
+
+	specPath := field.NewPath("spec", "template", "spec")
+	allErrs := ValidateNutanixMachineSpec(specPath, &template.Spec.Template.Spec)
+	// The topology controller of ClusterClass based clusters dry-runs changes of templates to detect whether it has
+	// to rotate them
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil || !topology.ShouldSkipImmutabilityChecks(req, template) {
+		changed, err := changedTemplateSpecFields(&oldTemplate.Spec.Template.Spec, &template.Spec.Template.Spec)
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		for _, name := range changed {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child(name),
+				"field of NutanixMachineTemplate is immutable, create a new NutanixMachineTemplate and reference it from the owner of the Machines instead"))
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(infrav1.GroupVersion.WithKind(infrav1.NutanixMachineTemplateKind).GroupKind(), template.Name, allErrs)
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *NutanixMachineTemplateValidator) ValidateDelete(_ context.Context, _ runtime.Object) error {
+	return nil
+}
+
+// changedTemplateSpecFields returns the sorted names of the fields that differ between two NutanixMachine specs of a
+// template. The fields that are only set on NutanixMachines by the controllers are ignored.
+func changedTemplateSpecFields(oldSpec, newSpec *infrav1.NutanixMachineSpec) ([]string, error) {
+	if apiequality.Semantic.DeepEqual(oldSpec, newSpec) {
+		return nil, nil
+	}
+	oldFields, err := templateSpecFields(oldSpec)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := templateSpecFields(newSpec)
+	if err != nil {
+		return nil, err
+	}
+	changed := make([]string, 0)
+	for name, value := range newFields {
+		if !apiequality.Semantic.DeepEqual(oldFields[name], value) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range oldFields {
+		if _, ok := newFields[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+// templateSpecFields returns the fields of a NutanixMachine spec of a template by their JSON name
+func templateSpecFields(spec *infrav1.NutanixMachineSpec) (map[string]interface{}, error) {
+	spec = spec.DeepCopy()
+	spec.ProviderID = ""
+	spec.BootstrapRef = nil
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(spec)
+}
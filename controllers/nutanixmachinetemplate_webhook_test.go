@@ -0,0 +1,153 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+func newTestNutanixMachineTemplate() *infrav1.NutanixMachineTemplate {
+	return &infrav1.NutanixMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "template",
+			Namespace: "default",
+		},
+		Spec: infrav1.NutanixMachineTemplateSpec{
+			Template: infrav1.NutanixMachineTemplateResource{
+				Spec: infrav1.NutanixMachineSpec{
+					VCPUsPerSocket: 1,
+					VCPUSockets:    2,
+					MemorySize:     resource.MustParse("4Gi"),
+					SystemDiskSize: resource.MustParse("40Gi"),
+					Image: infrav1.NutanixResourceIdentifier{
+						Type: infrav1.NutanixIdentifierName,
+						Name: pointer.String("image"),
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestNutanixMachineTemplateValidatorValidateCreate(t *testing.T) {
+	g := NewWithT(t)
+	v := NewNutanixMachineTemplateValidator()
+	template := newTestNutanixMachineTemplate()
+	g.Expect(v.ValidateCreate(context.Background(), template)).To(Succeed())
+
+	template.Spec.Template.Spec.VolumeGroups = []infrav1.NutanixResourceIdentifier{
+		{Type: infrav1.NutanixIdentifierName, Name: pointer.String("vg-1")},
+		{Type: infrav1.NutanixIdentifierName, Name: pointer.String("vg-1")},
+	}
+	err := v.ValidateCreate(context.Background(), template)
+	g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	g.Expect(err.Error()).To(ContainSubstring("spec.template.spec.volumeGroups"))
+}
+
+func TestNutanixMachineTemplateValidatorValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name            string
+		mutate          func(template *infrav1.NutanixMachineTemplate)
+		dryRun          bool
+		expectedChanged []string
+	}{
+		{
+			name: "metadata change is allowed",
+			mutate: func(template *infrav1.NutanixMachineTemplate) {
+				template.Labels = map[string]string{"team": "platform"}
+				template.Annotations = map[string]string{"description": "workers"}
+				template.Spec.Template.ObjectMeta = capiv1.ObjectMeta{Labels: map[string]string{"role": "worker"}}
+			},
+		},
+		{
+			name: "unchanged spec is allowed",
+			mutate: func(template *infrav1.NutanixMachineTemplate) {
+				template.Spec.Template.Spec.MemorySize = resource.MustParse("4096Mi")
+			},
+		},
+		{
+			name: "immutable field change is rejected",
+			mutate: func(template *infrav1.NutanixMachineTemplate) {
+				template.Spec.Template.Spec.VCPUSockets = 4
+			},
+			expectedChanged: []string{"spec.template.spec.vcpuSockets"},
+		},
+		{
+			name: "all changed fields are listed",
+			mutate: func(template *infrav1.NutanixMachineTemplate) {
+				template.Spec.Template.Spec.Image.Name = pointer.String("other-image")
+				template.Spec.Template.Spec.Project = &infrav1.NutanixResourceIdentifier{
+					Type: infrav1.NutanixIdentifierName,
+					Name: pointer.String("project"),
+				}
+			},
+			expectedChanged: []string{"spec.template.spec.image", "spec.template.spec.project"},
+		},
+		{
+			name: "dry-run of topology controller is allowed",
+			mutate: func(template *infrav1.NutanixMachineTemplate) {
+				template.Annotations = map[string]string{capiv1.TopologyDryRunAnnotation: ""}
+				template.Spec.Template.Spec.VCPUSockets = 4
+			},
+			dryRun: true,
+		},
+		{
+			name: "dry-run without topology annotation is rejected",
+			mutate: func(template *infrav1.NutanixMachineTemplate) {
+				template.Spec.Template.Spec.VCPUSockets = 4
+			},
+			dryRun:          true,
+			expectedChanged: []string{"spec.template.spec.vcpuSockets"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			oldTemplate := newTestNutanixMachineTemplate()
+			template := oldTemplate.DeepCopy()
+			tt.mutate(template)
+
+			ctx := admission.NewContextWithRequest(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{DryRun: pointer.Bool(tt.dryRun)},
+			})
+			err := NewNutanixMachineTemplateValidator().ValidateUpdate(ctx, oldTemplate, template)
+			if len(tt.expectedChanged) == 0 {
+				g.Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			statusErr := err.(*apierrors.StatusError)
+			g.Expect(statusErr.ErrStatus.Details.Causes).To(HaveLen(len(tt.expectedChanged)))
+			for i, cause := range statusErr.ErrStatus.Details.Causes {
+				g.Expect(cause.Field).To(Equal(tt.expectedChanged[i]))
+				g.Expect(cause.Message).To(ContainSubstring("immutable"))
+			}
+		})
+	}
+}
@@ -1,10 +1,187 @@
 package controllers
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
+)
 
 // ControllerConfig is the configuration for cluster and machine controllers
 type ControllerConfig struct {
 	MaxConcurrentReconciles int
+	TrustBundlePolicy       nutanixClient.TrustBundlePolicy
+	// VMShutdownGracePeriod is the time given to the guest OS of a VM to shut down before the VM is deleted.
+	// VMs are deleted without shutting them down first if set to 0.
+	VMShutdownGracePeriod time.Duration
+	// ImageReadyTimeout is the time to wait for the image of a VM to become ready before the VM is created
+	ImageReadyTimeout time.Duration
+	// ReconcileTimeout is the deadline of a single reconcile, after which pending Prism Central requests are canceled.
+	// Reconciles have no deadline if set to 0.
+	ReconcileTimeout time.Duration
+	// BootstrapDataCompressionThreshold is the size in bytes of cloud-init bootstrap data above which the bootstrap data
+	// is gzip compressed before it is passed to the VM. Bootstrap data is never compressed if set to 0.
+	BootstrapDataCompressionThreshold int
+	// SeedDiskPoolSize is the number of pre-cloned seed disks kept per cluster, image and Prism Element cluster to create
+	// VMs from. VMs are cloned from their image and existing seed VMs are deleted if set to 0.
+	SeedDiskPoolSize int
+	// ReconcileDrift enables restoring the ownership categories of VMs that were changed in Prism Central
+	ReconcileDrift bool
+	// MachineRoleCategories enables applying categories with the role of the Machine and the owning MachineDeployment
+	// to the VMs created for NutanixMachines
+	MachineRoleCategories bool
+	// ExtraVMConfig enables merging the extraVMConfig of NutanixMachines into the Prism Central VM spec of new VMs
+	ExtraVMConfig bool
+	// VMProtectionCategory is the category that protects VMs from being deleted with their NutanixMachine.
+	// VMs are always deleted if not set.
+	VMProtectionCategory *infrav1.NutanixCategoryIdentifier
+	// RequeueJitterFactor is the maximum fraction of a requeue interval that is randomly added to it, to spread out
+	// the reconciles of objects that were requeued at the same time. Requeue intervals are not jittered if set to 0.
+	RequeueJitterFactor float64
+	// VMTaskTimeout is the maximum duration of the creation task of a VM, after which the NutanixMachine is failed.
+	// Creation tasks are waited for without timeout if set to 0.
+	VMTaskTimeout time.Duration
+	// IPAddressClaimRequeueInterval is the maximum interval in which NutanixMachines waiting for their IPAddressClaims
+	// to be fulfilled are reconciled again. The interval of other external conditions applies if set to 0.
+	IPAddressClaimRequeueInterval time.Duration
+	// RestrictCredentialNamespaces rejects credential secrets of NutanixClusters in another namespace than the
+	// NutanixCluster, unless the namespace of the secret is in AllowedCredentialNamespaces.
+	RestrictCredentialNamespaces bool
+	// AllowedCredentialNamespaces are the namespaces whose credential secrets can be referenced by NutanixClusters of
+	// any namespace if RestrictCredentialNamespaces is set.
+	AllowedCredentialNamespaces []string
+	// UnmanagedCredentialFinalizers disables adding finalizers to the credential secrets of NutanixClusters, e.g. if
+	// the finalizers of the secrets are managed by a GitOps tool.
+	UnmanagedCredentialFinalizers bool
+	// VMDescriptionAnnotationPrefix is the prefix of the annotations of Machines whose values are appended to the
+	// description of their VMs. Annotations are not copied if not set.
+	VMDescriptionAnnotationPrefix string
+	// NodeDrainTimeout is the maximum time the VM of a deleted NutanixMachine is kept until its Node is cordoned and
+	// drained. VMs are deleted without checking their Node if set to 0.
+	NodeDrainTimeout time.Duration
+}
+
+// reconcileTimeout returns the deadline of a single reconcile, or 0 if the config is not set
+func (c *ControllerConfig) reconcileTimeout() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return c.ReconcileTimeout
+}
+
+// bootstrapDataCompressionThreshold returns the size of bootstrap data above which it is compressed, or 0 if the config
+// is not set
+func (c *ControllerConfig) bootstrapDataCompressionThreshold() int {
+	if c == nil {
+		return 0
+	}
+	return c.BootstrapDataCompressionThreshold
+}
+
+// reconcileDrift returns true if the ownership categories of VMs are restored, or false if the config is not set
+func (c *ControllerConfig) reconcileDrift() bool {
+	if c == nil {
+		return false
+	}
+	return c.ReconcileDrift
+}
+
+// machineRoleCategories returns true if the role categories are applied to new VMs, or false if the config is not set
+func (c *ControllerConfig) machineRoleCategories() bool {
+	if c == nil {
+		return false
+	}
+	return c.MachineRoleCategories
+}
+
+// extraVMConfig returns true if the extraVMConfig of NutanixMachines is applied to new VMs, or false if the config is
+// not set
+func (c *ControllerConfig) extraVMConfig() bool {
+	if c == nil {
+		return false
+	}
+	return c.ExtraVMConfig
+}
+
+// vmProtectionCategory returns the category that protects VMs from being deleted, or nil if the config is not set
+func (c *ControllerConfig) vmProtectionCategory() *infrav1.NutanixCategoryIdentifier {
+	if c == nil {
+		return nil
+	}
+	return c.VMProtectionCategory
+}
+
+// requeueJitterFactor returns the maximum fraction of a requeue interval added as jitter, or 0 if the config is not set
+func (c *ControllerConfig) requeueJitterFactor() float64 {
+	if c == nil {
+		return 0
+	}
+	return c.RequeueJitterFactor
+}
+
+// vmTaskTimeout returns the maximum duration of the creation task of a VM, or 0 if the config is not set
+func (c *ControllerConfig) vmTaskTimeout() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return c.VMTaskTimeout
+}
+
+// nodeDrainTimeout returns the maximum time a VM is kept until its Node is drained, or 0 if the config is not set
+func (c *ControllerConfig) nodeDrainTimeout() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return c.NodeDrainTimeout
+}
+
+// ipAddressClaimRequeueInterval returns the maximum requeue interval while waiting for IPAddressClaims, or 0 if the
+// config is not set
+func (c *ControllerConfig) ipAddressClaimRequeueInterval() time.Duration {
+	if c == nil {
+		return 0
+	}
+	return c.IPAddressClaimRequeueInterval
+}
+
+// credentialNamespaceAllowed returns true if a NutanixCluster in the given namespace can reference a credential secret
+// in the credential namespace. All namespaces are allowed if the config is not set.
+func (c *ControllerConfig) credentialNamespaceAllowed(namespace, credentialNamespace string) bool {
+	if c == nil || !c.RestrictCredentialNamespaces || credentialNamespace == "" || credentialNamespace == namespace {
+		return true
+	}
+	for _, allowed := range c.AllowedCredentialNamespaces {
+		if allowed == credentialNamespace {
+			return true
+		}
+	}
+	return false
+}
+
+// manageCredentialFinalizers returns true if finalizers are added to credential secrets, which is the default if the
+// config is not set
+func (c *ControllerConfig) manageCredentialFinalizers() bool {
+	return c == nil || !c.UnmanagedCredentialFinalizers
+}
+
+// vmDescriptionAnnotationPrefix returns the prefix of the Machine annotations appended to VM descriptions, or an empty
+// string if the config is not set
+func (c *ControllerConfig) vmDescriptionAnnotationPrefix() string {
+	if c == nil {
+		return ""
+	}
+	return c.VMDescriptionAnnotationPrefix
+}
+
+// collectSeedVMs returns true if the seed VMs left by an earlier run with the seed disk pool enabled are deleted, which
+// is the case if the seed disk pool is disabled. Seed VMs are kept if the config is not set.
+func (c *ControllerConfig) collectSeedVMs() bool {
+	return c != nil && c.SeedDiskPoolSize == 0
 }
 
 // ControllerConfigOpts is a function that can be used to configure the controller config
@@ -20,3 +197,217 @@ func WithMaxConcurrentReconciles(max int) ControllerConfigOpts {
 		return nil
 	}
 }
+
+// WithTrustBundleMinRSAKeySize sets the minimum RSA key size of the certificates in an additional trust bundle
+func WithTrustBundleMinRSAKeySize(size int) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if size < 0 {
+			return errors.New("minimum RSA key size of trust bundle certificates cannot be negative")
+		}
+		c.TrustBundlePolicy.MinRSAKeySize = size
+		return nil
+	}
+}
+
+// WithTrustBundleWeakSignatureAlgorithms sets the signature algorithms that are not allowed for the certificates in an additional trust bundle
+func WithTrustBundleWeakSignatureAlgorithms(algorithms []string) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		for _, name := range algorithms {
+			alg, err := nutanixClient.ParseSignatureAlgorithm(name)
+			if err != nil {
+				return err
+			}
+			c.TrustBundlePolicy.WeakSignatureAlgorithms = append(c.TrustBundlePolicy.WeakSignatureAlgorithms, alg)
+		}
+		return nil
+	}
+}
+
+// WithVMShutdownGracePeriod sets the time given to the guest OS of a VM to shut down before the VM is deleted
+func WithVMShutdownGracePeriod(gracePeriod time.Duration) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if gracePeriod < 0 {
+			return errors.New("VM shutdown grace period cannot be negative")
+		}
+		c.VMShutdownGracePeriod = gracePeriod
+		return nil
+	}
+}
+
+// WithImageReadyTimeout sets the time to wait for the image of a VM to become ready before the VM is created
+func WithImageReadyTimeout(timeout time.Duration) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if timeout <= 0 {
+			return errors.New("image ready timeout must be greater than 0")
+		}
+		c.ImageReadyTimeout = timeout
+		return nil
+	}
+}
+
+// WithReconcileTimeout sets the deadline of a single reconcile, after which pending Prism Central requests are canceled
+func WithReconcileTimeout(timeout time.Duration) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if timeout < 0 {
+			return errors.New("reconcile timeout cannot be negative")
+		}
+		c.ReconcileTimeout = timeout
+		return nil
+	}
+}
+
+// WithBootstrapDataCompressionThreshold sets the size in bytes of cloud-init bootstrap data above which it is compressed
+func WithBootstrapDataCompressionThreshold(threshold int) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if threshold < 0 {
+			return errors.New("bootstrap data compression threshold cannot be negative")
+		}
+		c.BootstrapDataCompressionThreshold = threshold
+		return nil
+	}
+}
+
+// WithSeedDiskPoolSize sets the number of pre-cloned seed disks kept per cluster, image and Prism Element cluster
+func WithSeedDiskPoolSize(size int) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if size < 0 {
+			return errors.New("seed disk pool size cannot be negative")
+		}
+		c.SeedDiskPoolSize = size
+		return nil
+	}
+}
+
+// WithReconcileDrift enables or disables restoring the ownership categories of VMs that were changed in Prism Central
+func WithReconcileDrift(enabled bool) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		c.ReconcileDrift = enabled
+		return nil
+	}
+}
+
+// WithMachineRoleCategories enables or disables applying categories with the role of the Machine and the owning
+// MachineDeployment to new VMs
+func WithMachineRoleCategories(enabled bool) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		c.MachineRoleCategories = enabled
+		return nil
+	}
+}
+
+// WithExtraVMConfig enables or disables merging the extraVMConfig of NutanixMachines into the VM spec of new VMs
+func WithExtraVMConfig(enabled bool) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		c.ExtraVMConfig = enabled
+		return nil
+	}
+}
+
+// WithVMProtectionCategory sets the category in key=value format that protects VMs from being deleted with their
+// NutanixMachine. An empty category disables the protection.
+func WithVMProtectionCategory(category string) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if category == "" {
+			c.VMProtectionCategory = nil
+			return nil
+		}
+		key, value, found := strings.Cut(category, "=")
+		if !found || key == "" || value == "" {
+			return fmt.Errorf("VM protection category %q must be in key=value format", category)
+		}
+		c.VMProtectionCategory = &infrav1.NutanixCategoryIdentifier{Key: key, Value: value}
+		return nil
+	}
+}
+
+// WithRequeueJitterFactor sets the maximum fraction of a requeue interval that is randomly added to it
+func WithRequeueJitterFactor(factor float64) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if factor < 0 || factor > 1 {
+			return errors.New("requeue jitter factor must be between 0 and 1")
+		}
+		c.RequeueJitterFactor = factor
+		return nil
+	}
+}
+
+// WithVMTaskTimeout sets the maximum duration of the creation task of a VM, after which the NutanixMachine is failed
+func WithVMTaskTimeout(timeout time.Duration) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if timeout < 0 {
+			return errors.New("VM task timeout cannot be negative")
+		}
+		c.VMTaskTimeout = timeout
+		return nil
+	}
+}
+
+// WithIPAddressClaimRequeueInterval sets the maximum interval in which NutanixMachines waiting for their
+// IPAddressClaims to be fulfilled are reconciled again
+func WithIPAddressClaimRequeueInterval(interval time.Duration) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if interval < 0 {
+			return errors.New("IPAddressClaim requeue interval cannot be negative")
+		}
+		c.IPAddressClaimRequeueInterval = interval
+		return nil
+	}
+}
+
+// WithRestrictCredentialNamespaces enables or disables rejecting credential secrets of NutanixClusters in another
+// namespace than the NutanixCluster
+func WithRestrictCredentialNamespaces(enabled bool) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		c.RestrictCredentialNamespaces = enabled
+		return nil
+	}
+}
+
+// WithAllowedCredentialNamespaces sets the namespaces whose credential secrets can be referenced by NutanixClusters of
+// any namespace
+func WithAllowedCredentialNamespaces(namespaces []string) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		for _, namespace := range namespaces {
+			if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+				return fmt.Errorf("allowed credential namespace %q is invalid: %s", namespace, strings.Join(errs, ", "))
+			}
+		}
+		c.AllowedCredentialNamespaces = namespaces
+		return nil
+	}
+}
+
+// WithManageCredentialFinalizers enables or disables adding finalizers to the credential secrets of NutanixClusters
+func WithManageCredentialFinalizers(enabled bool) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		c.UnmanagedCredentialFinalizers = !enabled
+		return nil
+	}
+}
+
+// WithVMDescriptionAnnotationPrefix sets the prefix of the annotations of Machines whose values are appended to the
+// description of their VMs. An empty prefix disables copying annotations.
+func WithVMDescriptionAnnotationPrefix(prefix string) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if prefix != "" {
+			// Any annotation key with the prefix must be valid, so the prefix is verified with a name appended
+			if errs := validation.IsQualifiedName(prefix + "x"); len(errs) > 0 {
+				return fmt.Errorf("VM description annotation prefix %q is invalid: %s", prefix, strings.Join(errs, ", "))
+			}
+		}
+		c.VMDescriptionAnnotationPrefix = prefix
+		return nil
+	}
+}
+
+// WithNodeDrainTimeout sets the maximum time the VM of a deleted NutanixMachine is kept until its Node is drained.
+// A timeout of 0 disables waiting for Nodes to be drained.
+func WithNodeDrainTimeout(timeout time.Duration) ControllerConfigOpts {
+	return func(c *ControllerConfig) error {
+		if timeout < 0 {
+			return errors.New("node drain timeout cannot be negative")
+		}
+		c.NodeDrainTimeout = timeout
+		return nil
+	}
+}
@@ -1,9 +1,13 @@
 package controllers
 
 import (
+	"crypto/x509"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
 )
 
 func TestWithMaxConcurrentReconciles(t *testing.T) {
@@ -37,3 +41,200 @@ func TestWithMaxConcurrentReconciles(t *testing.T) {
 		})
 	}
 }
+
+func TestWithTrustBundleWeakSignatureAlgorithms(t *testing.T) {
+	tests := []struct {
+		name        string
+		algorithms  []string
+		expected    []x509.SignatureAlgorithm
+		expectError bool
+	}{
+		{
+			name:       "TestWithTrustBundleWeakSignatureAlgorithmsValid",
+			algorithms: []string{"SHA1-RSA", "md5-rsa"},
+			expected:   []x509.SignatureAlgorithm{x509.SHA1WithRSA, x509.MD5WithRSA},
+		},
+		{
+			name:        "TestWithTrustBundleWeakSignatureAlgorithmsUnknown",
+			algorithms:  []string{"SHA1-FOO"},
+			expectError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := WithTrustBundleWeakSignatureAlgorithms(tt.algorithms)
+			config := &ControllerConfig{}
+			err := opt(config)
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, config.TrustBundlePolicy.WeakSignatureAlgorithms)
+			}
+		})
+	}
+}
+
+func TestWithVMShutdownGracePeriod(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.NoError(t, WithVMShutdownGracePeriod(2*time.Minute)(config))
+	assert.Equal(t, 2*time.Minute, config.VMShutdownGracePeriod)
+
+	assert.Error(t, WithVMShutdownGracePeriod(-time.Minute)(config))
+}
+
+func TestWithImageReadyTimeout(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.NoError(t, WithImageReadyTimeout(5*time.Minute)(config))
+	assert.Equal(t, 5*time.Minute, config.ImageReadyTimeout)
+
+	assert.Error(t, WithImageReadyTimeout(0)(config))
+}
+
+func TestWithReconcileTimeout(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.NoError(t, WithReconcileTimeout(15*time.Minute)(config))
+	assert.Equal(t, 15*time.Minute, config.ReconcileTimeout)
+
+	assert.NoError(t, WithReconcileTimeout(0)(config))
+	assert.Equal(t, time.Duration(0), config.ReconcileTimeout)
+
+	assert.Error(t, WithReconcileTimeout(-time.Minute)(config))
+}
+
+func TestWithBootstrapDataCompressionThreshold(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.NoError(t, WithBootstrapDataCompressionThreshold(16*1024)(config))
+	assert.Equal(t, 16*1024, config.BootstrapDataCompressionThreshold)
+
+	assert.NoError(t, WithBootstrapDataCompressionThreshold(0)(config))
+	assert.Equal(t, 0, config.BootstrapDataCompressionThreshold)
+
+	assert.Error(t, WithBootstrapDataCompressionThreshold(-1)(config))
+}
+
+func TestReconcilerControllerOptions(t *testing.T) {
+	clusterReconciler, err := NewNutanixClusterReconciler(nil, nil, nil, nil, WithMaxConcurrentReconciles(3))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, clusterReconciler.controllerOptions().MaxConcurrentReconciles)
+
+	machineReconciler, err := NewNutanixMachineReconciler(nil, nil, nil, nil, WithMaxConcurrentReconciles(7))
+	assert.NoError(t, err)
+	assert.Equal(t, 7, machineReconciler.controllerOptions().MaxConcurrentReconciles)
+
+	_, err = NewNutanixClusterReconciler(nil, nil, nil, nil, WithMaxConcurrentReconciles(-1))
+	assert.Error(t, err)
+	_, err = NewNutanixMachineReconciler(nil, nil, nil, nil, WithMaxConcurrentReconciles(0))
+	assert.Error(t, err)
+}
+
+func TestWithReconcileDrift(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.False(t, config.reconcileDrift())
+	assert.NoError(t, WithReconcileDrift(true)(config))
+	assert.True(t, config.reconcileDrift())
+}
+
+func TestWithMachineRoleCategories(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.False(t, config.machineRoleCategories())
+	assert.NoError(t, WithMachineRoleCategories(true)(config))
+	assert.True(t, config.machineRoleCategories())
+}
+
+func TestWithExtraVMConfig(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.False(t, config.extraVMConfig())
+	assert.NoError(t, WithExtraVMConfig(true)(config))
+	assert.True(t, config.extraVMConfig())
+}
+
+func TestWithVMProtectionCategory(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.Nil(t, config.vmProtectionCategory())
+	assert.NoError(t, WithVMProtectionCategory("protected=true")(config))
+	assert.Equal(t, &infrav1.NutanixCategoryIdentifier{Key: "protected", Value: "true"}, config.vmProtectionCategory())
+
+	assert.NoError(t, WithVMProtectionCategory("")(config))
+	assert.Nil(t, config.vmProtectionCategory())
+
+	for _, category := range []string{"protected", "=true", "protected="} {
+		assert.Error(t, WithVMProtectionCategory(category)(config), category)
+	}
+}
+
+func TestWithRequeueJitterFactor(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.NoError(t, WithRequeueJitterFactor(0.2)(config))
+	assert.Equal(t, 0.2, config.requeueJitterFactor())
+
+	assert.Error(t, WithRequeueJitterFactor(-0.1)(config))
+	assert.Error(t, WithRequeueJitterFactor(1.5)(config))
+}
+
+func TestWithVMTaskTimeout(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.Equal(t, time.Duration(0), config.vmTaskTimeout())
+	assert.NoError(t, WithVMTaskTimeout(30*time.Minute)(config))
+	assert.Equal(t, 30*time.Minute, config.vmTaskTimeout())
+
+	assert.Error(t, WithVMTaskTimeout(-time.Minute)(config))
+}
+
+func TestWithIPAddressClaimRequeueInterval(t *testing.T) {
+	config := &ControllerConfig{}
+	assert.Equal(t, time.Duration(0), config.ipAddressClaimRequeueInterval())
+	assert.NoError(t, WithIPAddressClaimRequeueInterval(30*time.Second)(config))
+	assert.Equal(t, 30*time.Second, config.ipAddressClaimRequeueInterval())
+
+	assert.Error(t, WithIPAddressClaimRequeueInterval(-time.Second)(config))
+}
+
+func TestCredentialNamespaceAllowed(t *testing.T) {
+	var config *ControllerConfig
+	assert.True(t, config.credentialNamespaceAllowed("tenant-a", "tenant-b"))
+
+	config = &ControllerConfig{}
+	assert.NoError(t, WithRestrictCredentialNamespaces(true)(config))
+	assert.NoError(t, WithAllowedCredentialNamespaces([]string{"shared"})(config))
+	assert.True(t, config.credentialNamespaceAllowed("tenant-a", "tenant-a"))
+	assert.True(t, config.credentialNamespaceAllowed("tenant-a", "shared"))
+	assert.False(t, config.credentialNamespaceAllowed("tenant-a", "tenant-b"))
+
+	assert.Error(t, WithAllowedCredentialNamespaces([]string{"Not_A_Namespace"})(config))
+}
+
+func TestWithManageCredentialFinalizers(t *testing.T) {
+	var config *ControllerConfig
+	assert.True(t, config.manageCredentialFinalizers())
+
+	config = &ControllerConfig{}
+	assert.True(t, config.manageCredentialFinalizers())
+	assert.NoError(t, WithManageCredentialFinalizers(false)(config))
+	assert.False(t, config.manageCredentialFinalizers())
+	assert.NoError(t, WithManageCredentialFinalizers(true)(config))
+	assert.True(t, config.manageCredentialFinalizers())
+}
+
+func TestWithVMDescriptionAnnotationPrefix(t *testing.T) {
+	var config *ControllerConfig
+	assert.Empty(t, config.vmDescriptionAnnotationPrefix())
+
+	config = &ControllerConfig{}
+	assert.NoError(t, WithVMDescriptionAnnotationPrefix("vm-description.example.com/")(config))
+	assert.Equal(t, "vm-description.example.com/", config.vmDescriptionAnnotationPrefix())
+	assert.NoError(t, WithVMDescriptionAnnotationPrefix("")(config))
+	assert.Empty(t, config.vmDescriptionAnnotationPrefix())
+	assert.Error(t, WithVMDescriptionAnnotationPrefix("vm description/")(config))
+}
+
+func TestWithNodeDrainTimeout(t *testing.T) {
+	var config *ControllerConfig
+	assert.Equal(t, time.Duration(0), config.nodeDrainTimeout())
+
+	config = &ControllerConfig{}
+	assert.NoError(t, WithNodeDrainTimeout(10*time.Minute)(config))
+	assert.Equal(t, 10*time.Minute, config.nodeDrainTimeout())
+
+	assert.Error(t, WithNodeDrainTimeout(-time.Minute)(config))
+}
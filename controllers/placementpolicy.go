@@ -0,0 +1,64 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+// getPlacementPolicyCategoryIdentifiers returns the placement policy categories of the NutanixMachine
+func getPlacementPolicyCategoryIdentifiers(rctx *nctx.MachineContext) []*infrav1.NutanixCategoryIdentifier {
+	categoryIdentifiers := make([]*infrav1.NutanixCategoryIdentifier, 0, len(rctx.NutanixMachine.Spec.PlacementPolicyCategories))
+	for i := range rctx.NutanixMachine.Spec.PlacementPolicyCategories {
+		categoryIdentifiers = append(categoryIdentifiers, &rctx.NutanixMachine.Spec.PlacementPolicyCategories[i])
+	}
+	return categoryIdentifiers
+}
+
+// applyPlacementPolicyCategories assigns the placement policy categories of the NutanixMachine to the metadata of the
+// create request of its VM, so Prism Central places the VM according to the placement policies driven by the
+// categories. The categories override values of the same keys set by other settings, e.g. the extraVMConfig. The
+// PlacementPolicyApplied condition is set to false if a category does not exist in Prism Central.
+func applyPlacementPolicyCategories(rctx *nctx.MachineContext, vmInput *nutanixClientV3.VMIntentInput) error {
+	categoryIdentifiers := getPlacementPolicyCategoryIdentifiers(rctx)
+	if len(categoryIdentifiers) == 0 {
+		conditions.Delete(rctx.NutanixMachine, infrav1.PlacementPolicyAppliedCondition)
+		return nil
+	}
+	categories, err := GetCategoryVMSpec(rctx.Context, rctx.NutanixClient, categoryIdentifiers)
+	if err != nil {
+		conditions.MarkFalse(rctx.NutanixMachine, infrav1.PlacementPolicyAppliedCondition, infrav1.PlacementPolicyCategoriesNotFound, capiv1.ConditionSeverityError, err.Error())
+		return err
+	}
+	if vmInput.Metadata == nil {
+		vmInput.Metadata = &nutanixClientV3.Metadata{Kind: utils.StringPtr("vm")}
+	}
+	if vmInput.Metadata.Categories == nil {
+		vmInput.Metadata.Categories = map[string]string{}
+	}
+	for key, value := range categories {
+		vmInput.Metadata.Categories[key] = value
+	}
+	conditions.MarkTrue(rctx.NutanixMachine, infrav1.PlacementPolicyAppliedCondition)
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+// placementPolicyCategoriesTestService is a Prism v3 service in which only the category values of the "affinity" key
+// exist
+type placementPolicyCategoriesTestService struct {
+	nutanixClientV3.Service
+}
+
+func (s *placementPolicyCategoriesTestService) GetCategoryValue(_ context.Context, key, value string) (*nutanixClientV3.CategoryValueStatus, error) {
+	if key != "affinity" {
+		return nil, errors.New("CATEGORY_NAME_VALUE_MISMATCH")
+	}
+	return &nutanixClientV3.CategoryValueStatus{Value: pointer.String(value)}, nil
+}
+
+func TestApplyPlacementPolicyCategories(t *testing.T) {
+	tests := []struct {
+		name                      string
+		placementPolicyCategories []infrav1.NutanixCategoryIdentifier
+		categories                map[string]string
+		expectErr                 bool
+		expectedCategories        map[string]string
+		expectedCondition         corev1.ConditionStatus
+	}{
+		{
+			name:               "keeps the create request without placement policy categories",
+			categories:         map[string]string{"env": "dev"},
+			expectedCategories: map[string]string{"env": "dev"},
+		},
+		{
+			name:                      "adds the placement policy categories to the create request",
+			placementPolicyCategories: []infrav1.NutanixCategoryIdentifier{{Key: "affinity", Value: "rack-1"}},
+			categories:                map[string]string{"env": "dev"},
+			expectedCategories:        map[string]string{"env": "dev", "affinity": "rack-1"},
+			expectedCondition:         corev1.ConditionTrue,
+		},
+		{
+			name:                      "overrides values set by other settings",
+			placementPolicyCategories: []infrav1.NutanixCategoryIdentifier{{Key: "affinity", Value: "rack-1"}},
+			categories:                map[string]string{"affinity": "rack-2"},
+			expectedCategories:        map[string]string{"affinity": "rack-1"},
+			expectedCondition:         corev1.ConditionTrue,
+		},
+		{
+			name:                      "fails for categories that do not exist",
+			placementPolicyCategories: []infrav1.NutanixCategoryIdentifier{{Key: "affinity", Value: "rack-1"}, {Key: "unknown", Value: "value"}},
+			categories:                map[string]string{"env": "dev"},
+			expectErr:                 true,
+			expectedCategories:        map[string]string{"env": "dev"},
+			expectedCondition:         corev1.ConditionFalse,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			nutanixMachine := &infrav1.NutanixMachine{
+				Spec: infrav1.NutanixMachineSpec{PlacementPolicyCategories: tt.placementPolicyCategories},
+			}
+			vmInput := &nutanixClientV3.VMIntentInput{
+				Spec:     &nutanixClientV3.VM{Name: pointer.String("machine")},
+				Metadata: &nutanixClientV3.Metadata{Kind: pointer.String("vm"), Categories: tt.categories},
+			}
+			err := applyPlacementPolicyCategories(&nctx.MachineContext{
+				Context:        context.Background(),
+				NutanixClient:  &nutanixClientV3.Client{V3: &placementPolicyCategoriesTestService{}},
+				NutanixMachine: nutanixMachine,
+			}, vmInput)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			// The categories are part of the create request before it is submitted
+			g.Expect(vmInput.Metadata.Categories).To(Equal(tt.expectedCategories))
+			if tt.expectedCondition == "" {
+				g.Expect(conditions.Has(nutanixMachine, infrav1.PlacementPolicyAppliedCondition)).To(BeFalse())
+				return
+			}
+			g.Expect(conditions.Get(nutanixMachine, infrav1.PlacementPolicyAppliedCondition).Status).To(Equal(tt.expectedCondition))
+			if tt.expectedCondition == corev1.ConditionFalse {
+				g.Expect(conditions.GetReason(nutanixMachine, infrav1.PlacementPolicyAppliedCondition)).To(Equal(infrav1.PlacementPolicyCategoriesNotFound))
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+// PrismCentralHealthChecker periodically checks the connectivity to the Prism Central endpoints referenced by the
+// NutanixClusters and sets the PrismCentralHealthy condition of the NutanixClusters. The checks run independently of
+// the reconciliation of the NutanixClusters.
+type PrismCentralHealthChecker struct {
+	Client            client.Client
+	SecretInformer    coreinformers.SecretInformer
+	ConfigMapInformer coreinformers.ConfigMapInformer
+	// Interval is the interval between two checks of all Prism Central endpoints
+	Interval time.Duration
+}
+
+var _ manager.Runnable = &PrismCentralHealthChecker{}
+
+func NewPrismCentralHealthChecker(client client.Client, secretInformer coreinformers.SecretInformer, configMapInformer coreinformers.ConfigMapInformer, interval time.Duration) (*PrismCentralHealthChecker, error) {
+	if interval <= 0 {
+		return nil, errors.New("Prism Central health check interval must be greater than 0")
+	}
+	return &PrismCentralHealthChecker{
+		Client:            client,
+		SecretInformer:    secretInformer,
+		ConfigMapInformer: configMapInformer,
+		Interval:          interval,
+	}, nil
+}
+
+// Start implements manager.Runnable. The checks run until the context is canceled.
+func (c *PrismCentralHealthChecker) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, c.checkAll, c.Interval)
+	return nil
+}
+
+// checkAll checks each Prism Central endpoint once and updates the condition of all NutanixClusters referencing it
+func (c *PrismCentralHealthChecker) checkAll(ctx context.Context) {
+	log := ctrl.LoggerFrom(ctx)
+	nutanixClusters := &infrav1.NutanixClusterList{}
+	if err := c.Client.List(ctx, nutanixClusters); err != nil {
+		log.Error(err, "failed to list NutanixClusters for the Prism Central health check")
+		return
+	}
+	endpoints := make([]string, 0)
+	clustersByEndpoint := make(map[string][]*infrav1.NutanixCluster)
+	for i := range nutanixClusters.Items {
+		nutanixCluster := &nutanixClusters.Items[i]
+		// Paused and deleted NutanixClusters are not changed
+		if !nutanixCluster.DeletionTimestamp.IsZero() || conditions.IsTrue(nutanixCluster, infrav1.PausedCondition) {
+			continue
+		}
+		endpoint := getPrismCentralEndpoint(nutanixCluster)
+		if _, ok := clustersByEndpoint[endpoint]; !ok {
+			endpoints = append(endpoints, endpoint)
+		}
+		clustersByEndpoint[endpoint] = append(clustersByEndpoint[endpoint], nutanixCluster)
+	}
+
+	for _, endpoint := range endpoints {
+		clusters := clustersByEndpoint[endpoint]
+		err := c.checkPrismCentral(ctx, clusters)
+		if err != nil {
+			log.Info(fmt.Sprintf("Prism Central %s is unhealthy: %v", endpoint, err))
+		}
+		for _, nutanixCluster := range clusters {
+			if err := c.setHealthyCondition(ctx, nutanixCluster, err); err != nil {
+				log.Error(err, fmt.Sprintf("failed to set the %s condition of NutanixCluster %s/%s", infrav1.PrismCentralHealthyCondition, nutanixCluster.Namespace, nutanixCluster.Name))
+			}
+		}
+	}
+}
+
+// checkPrismCentral requests the current user from the Prism Central endpoint of the given NutanixClusters. The
+// client of the first NutanixCluster that can be created is used.
+func (c *PrismCentralHealthChecker) checkPrismCentral(ctx context.Context, nutanixClusters []*infrav1.NutanixCluster) error {
+	var v3Client *nutanixClientV3.Client
+	var err error
+	for _, nutanixCluster := range nutanixClusters {
+		v3Client, err = CreateNutanixClient(ctx, c.SecretInformer, c.ConfigMapInformer, nutanixCluster)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create client: %v", err)
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, c.Interval)
+	defer cancel()
+	if _, err := v3Client.V3.GetCurrentLoggedInUser(checkCtx); err != nil {
+		return err
+	}
+	return nil
+}
+
+// setHealthyCondition sets the PrismCentralHealthy condition of the NutanixCluster to the result of the check. The
+// NutanixCluster is only patched if the condition changed.
+func (c *PrismCentralHealthChecker) setHealthyCondition(ctx context.Context, nutanixCluster *infrav1.NutanixCluster, checkErr error) error {
+	healthy := conditions.IsTrue(nutanixCluster, infrav1.PrismCentralHealthyCondition)
+	if checkErr == nil && healthy {
+		return nil
+	}
+	if checkErr != nil && conditions.IsFalse(nutanixCluster, infrav1.PrismCentralHealthyCondition) &&
+		conditions.GetMessage(nutanixCluster, infrav1.PrismCentralHealthyCondition) == checkErr.Error() {
+		return nil
+	}
+	patchHelper, err := patch.NewHelper(nutanixCluster, c.Client)
+	if err != nil {
+		return err
+	}
+	if checkErr != nil {
+		conditions.MarkFalse(nutanixCluster, infrav1.PrismCentralHealthyCondition, infrav1.PrismCentralHealthCheckFailed, capiv1.ConditionSeverityWarning, checkErr.Error())
+	} else {
+		conditions.MarkTrue(nutanixCluster, infrav1.PrismCentralHealthyCondition)
+	}
+	return patchHelper.Patch(ctx, nutanixCluster, patch.WithOwnedConditions{Conditions: []capiv1.ConditionType{infrav1.PrismCentralHealthyCondition}})
+}
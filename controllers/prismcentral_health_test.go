@@ -0,0 +1,118 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
+)
+
+// healthTestService is a Prism v3 service that answers requests for the current user unless it is unhealthy
+type healthTestService struct {
+	nutanixClientV3.Service
+	unhealthy bool
+	requests  int
+}
+
+func (s *healthTestService) GetCurrentLoggedInUser(_ context.Context) (*nutanixClientV3.UserIntentResponse, error) {
+	s.requests++
+	if s.unhealthy {
+		return nil, errors.New("connection refused")
+	}
+	return &nutanixClientV3.UserIntentResponse{}, nil
+}
+
+func TestNewPrismCentralHealthChecker(t *testing.T) {
+	g := NewWithT(t)
+	_, err := NewPrismCentralHealthChecker(nil, nil, nil, 0)
+	g.Expect(err).To(HaveOccurred())
+	checker, err := NewPrismCentralHealthChecker(nil, nil, nil, time.Minute)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(checker.Interval).To(Equal(time.Minute))
+}
+
+func TestPrismCentralHealthCheck(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	prismCentral := &credentialTypes.NutanixPrismEndpoint{Address: "prism.example.com", Port: 9440}
+	nutanixClusters := []*infrav1.NutanixCluster{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-1", Namespace: "default"},
+			Spec:       infrav1.NutanixClusterSpec{PrismCentral: prismCentral},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-2", Namespace: "default"},
+			Spec:       infrav1.NutanixClusterSpec{PrismCentral: prismCentral},
+		},
+	}
+	service := &healthTestService{}
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, nutanixCluster := range nutanixClusters {
+		builder = builder.WithObjects(nutanixCluster)
+		nutanixClient.NutanixClientCache.Set(nutanixCluster, prismCentral, "", &nutanixClientV3.Client{V3: service})
+		defer nutanixClient.NutanixClientCache.Delete(nutanixCluster)
+	}
+	writeClient := &writeCountingClient{Client: builder.Build()}
+	checker := &PrismCentralHealthChecker{Client: writeClient, Interval: time.Minute}
+
+	expectCondition := func(status corev1.ConditionStatus) {
+		for _, nutanixCluster := range nutanixClusters {
+			checked := &infrav1.NutanixCluster{}
+			g.Expect(writeClient.Get(ctx, client.ObjectKeyFromObject(nutanixCluster), checked)).To(Succeed())
+			condition := conditions.Get(checked, infrav1.PrismCentralHealthyCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Status).To(Equal(status))
+		}
+	}
+
+	// Prism Central is requested once for all NutanixClusters referencing it
+	checker.checkAll(ctx)
+	g.Expect(service.requests).To(Equal(1))
+	expectCondition(corev1.ConditionTrue)
+
+	service.unhealthy = true
+	checker.checkAll(ctx)
+	expectCondition(corev1.ConditionFalse)
+
+	// The NutanixClusters are not patched while the health does not change
+	writes := writeClient.writes
+	checker.checkAll(ctx)
+	g.Expect(writeClient.writes).To(Equal(writes))
+
+	service.unhealthy = false
+	checker.checkAll(ctx)
+	expectCondition(corev1.ConditionTrue)
+	g.Expect(service.requests).To(Equal(4))
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+const (
+	// quotaExceededRequeueInterval is the interval after which the creation of a VM that was rejected since a Prism
+	// Central quota was exceeded is retried. Retrying does not free the quota, so the creation is retried rarely to
+	// not flood Prism Central with requests.
+	quotaExceededRequeueInterval = 10 * time.Minute
+
+	// quotaExceededEventReason is the reason of the events recorded when a VM could not be created since a Prism
+	// Central quota was exceeded
+	quotaExceededEventReason = "QuotaExceeded"
+)
+
+// isQuotaExceededError returns true if the error reports that a Prism Central quota, e.g. the resource quota of a
+// project, was exceeded. The Prism client does not return error codes, so the message is matched.
+func isQuotaExceededError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "quota") && (strings.Contains(msg, "exceed") || strings.Contains(msg, "violat"))
+}
+
+// markQuotaExceeded marks the VMProvisioned condition false since the VM could not be created as a Prism Central quota
+// was exceeded. The condition is replaced, so its transition time is the time of the last rejected creation the retry
+// interval is measured from.
+func markQuotaExceeded(rctx *nctx.MachineContext, err error) {
+	conditions.Delete(rctx.NutanixMachine, infrav1.VMProvisionedCondition)
+	conditions.MarkFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition, infrav1.QuotaExceeded, capiv1.ConditionSeverityWarning,
+		"Prism Central quota exceeded, the VM is created again in %s once the quota allows it: %s", quotaExceededRequeueInterval, err.Error())
+}
+
+// quotaExceededBackoff returns the time left until the creation of a VM that was rejected since a Prism Central quota
+// was exceeded is retried, or 0 if the creation can be attempted
+func quotaExceededBackoff(rctx *nctx.MachineContext) time.Duration {
+	if !conditions.IsFalse(rctx.NutanixMachine, infrav1.VMProvisionedCondition) ||
+		conditions.GetReason(rctx.NutanixMachine, infrav1.VMProvisionedCondition) != infrav1.QuotaExceeded {
+		return 0
+	}
+	remaining := quotaExceededRequeueInterval - conditionAge(rctx.NutanixMachine, infrav1.VMProvisionedCondition)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// discardVMCreation deletes the VM whose creation task failed and resets the recorded UUID of the VM, so a new VM is
+// created when the creation is retried instead of adopting the failed VM. The deletion is best effort since Prism
+// Central does not keep VMs whose creation failed in all cases.
+func (r *NutanixMachineReconciler) discardVMCreation(rctx *nctx.MachineContext) {
+	log := ctrl.LoggerFrom(rctx.Context)
+	vmUUID := rctx.NutanixMachine.Status.VmUUID
+	if vmUUID == "" {
+		return
+	}
+	if _, err := DeleteVM(rctx.Context, rctx.NutanixClient, rctx.Machine.Name, vmUUID); err != nil {
+		log.V(1).Info(fmt.Sprintf("failed to delete VM with UUID %s whose creation failed: %v", vmUUID, err))
+	}
+	rctx.NutanixMachine.Spec.ProviderID = ""
+	rctx.NutanixMachine.Status.VmUUID = ""
+	rctx.NutanixMachine.Status.PowerState = ""
+	r.vmCreations.forget(rctx.NutanixMachine.UID)
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+func TestIsQuotaExceededError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{err: nil},
+		{err: errors.New(`{"api_version":"3.1","code":422,"message_list":[{"message":"Project quota exceeded for resource vcpus"}],"state":"ERROR"}`), expected: true},
+		{err: errors.New("error_detail: QUOTA_EXCEEDED: memory of project dev, progress_message: create_vm_intentful"), expected: true},
+		{err: errors.New("Quota violation: requested disk capacity exceeds the limit"), expected: true},
+		{err: errors.New("status: 503 Service Unavailable")},
+		{err: errors.New("quota of project dev updated")},
+	}
+	for _, tt := range tests {
+		g := NewWithT(t)
+		g.Expect(isQuotaExceededError(tt.err)).To(Equal(tt.expected), "%v", tt.err)
+	}
+}
+
+func TestFailVMProvisioningQuotaExceeded(t *testing.T) {
+	g := NewWithT(t)
+	ntnxMachine := &infrav1.NutanixMachine{}
+	rctx := &nctx.MachineContext{Context: context.Background(), NutanixMachine: ntnxMachine}
+	quotaErr := errors.New(`failed to create VM test: {"code":422,"message_list":[{"message":"Project quota exceeded for resource vcpus"}]}`)
+
+	failVMProvisioning(rctx, quotaErr)
+	g.Expect(ntnxMachine.Status.FailureReason).To(BeNil())
+	g.Expect(ntnxMachine.Status.FailureMessage).To(BeNil())
+	g.Expect(conditions.IsFalse(ntnxMachine, infrav1.VMProvisionedCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(ntnxMachine, infrav1.VMProvisionedCondition)).To(Equal(infrav1.QuotaExceeded))
+	g.Expect(conditions.GetMessage(ntnxMachine, infrav1.VMProvisionedCondition)).To(ContainSubstring("Project quota exceeded for resource vcpus"))
+
+	// The creation is retried once the requeue interval passed since the last rejected creation
+	g.Expect(quotaExceededBackoff(rctx)).To(BeNumerically("~", quotaExceededRequeueInterval, time.Minute))
+	for i := range ntnxMachine.Status.Conditions {
+		ntnxMachine.Status.Conditions[i].LastTransitionTime = metav1.NewTime(time.Now().Add(-quotaExceededRequeueInterval))
+	}
+	g.Expect(quotaExceededBackoff(rctx)).To(BeZero())
+	failVMProvisioning(rctx, quotaErr)
+	g.Expect(quotaExceededBackoff(rctx)).To(BeNumerically("~", quotaExceededRequeueInterval, time.Minute))
+
+	// Other reasons do not hold back the creation
+	conditions.MarkFalse(ntnxMachine, infrav1.VMProvisionedCondition, infrav1.BootstrapDataNotReady, capiv1.ConditionSeverityInfo, "")
+	g.Expect(quotaExceededBackoff(rctx)).To(BeZero())
+	g.Expect(conditions.Get(ntnxMachine, infrav1.VMProvisionedCondition).Status).To(Equal(corev1.ConditionFalse))
+}
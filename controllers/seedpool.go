@@ -0,0 +1,359 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
+)
+
+const (
+	// seedVMNamePrefix is the prefix of the names of seed VMs, followed by the UUID of the image of the seed disk
+	seedVMNamePrefix = "capx-seed-"
+	// seedVMDescription is the description of seed VMs
+	seedVMDescription = "Seed VM of the Nutanix CAPI provider holding a clone of an image. Do not modify."
+	// seedVMMemorySizeMib is the memory size of seed VMs, which are never powered on
+	seedVMMemorySizeMib = 512
+	// seedPoolRefillQueueLength is the maximum number of pending refills of the seed disk pool
+	seedPoolRefillQueueLength = 100
+)
+
+// seedPoolKey identifies the seed disks of an image on a Prism Element cluster owned by a cluster. Seed disks are not
+// shared between clusters, so each cluster's seed VMs are created and deleted with its own Prism Central client.
+type seedPoolKey struct {
+	clusterNamespace string
+	clusterName      string
+	imageUUID        string
+	peUUID           string
+}
+
+// newSeedPoolKey returns the key of the seed disks of the image on the Prism Element cluster owned by the cluster
+func newSeedPoolKey(cluster *capiv1.Cluster, imageUUID, peUUID string) seedPoolKey {
+	return seedPoolKey{
+		clusterNamespace: cluster.Namespace,
+		clusterName:      cluster.Name,
+		imageUUID:        imageUUID,
+		peUUID:           peUUID,
+	}
+}
+
+// seedDisk is a pre-cloned image disk held by a powered off seed VM
+type seedDisk struct {
+	vmUUID   string
+	diskUUID string
+}
+
+// seedPoolRefill is a request to refill the seed disks of an image on a Prism Element cluster
+type seedPoolRefill struct {
+	key    seedPoolKey
+	client *nutanixClientV3.Client
+}
+
+// SeedDiskPool maintains a pool of pre-cloned seed disks per cluster, image and Prism Element cluster. New VMs clone
+// their system disk from a seed disk instead of the image if one is available, and the pool is refilled in the
+// background. The seed disks are held by powered off seed VMs carrying the ownership categories of their cluster. Seed
+// VMs left by earlier runs of the controller are adopted, and those exceeding the size of the pool are deleted.
+type SeedDiskPool struct {
+	// size is the number of seed disks kept per cluster, image and Prism Element cluster
+	size int
+
+	mu sync.Mutex
+	// seeds are the available seed disks
+	seeds map[seedPoolKey][]seedDisk
+	// discovered holds the keys whose existing seed VMs were looked up in Prism Central
+	discovered map[seedPoolKey]bool
+	// refilling holds the keys with a pending refill
+	refilling map[seedPoolKey]bool
+	refills   chan seedPoolRefill
+}
+
+var _ manager.Runnable = &SeedDiskPool{}
+
+func NewSeedDiskPool(size int) (*SeedDiskPool, error) {
+	if size <= 0 {
+		return nil, errors.New("seed disk pool size must be greater than 0")
+	}
+	return &SeedDiskPool{
+		size:       size,
+		seeds:      make(map[seedPoolKey][]seedDisk),
+		discovered: make(map[seedPoolKey]bool),
+		refilling:  make(map[seedPoolKey]bool),
+		refills:    make(chan seedPoolRefill, seedPoolRefillQueueLength),
+	}, nil
+}
+
+// Start implements manager.Runnable. The pool is refilled until the context is canceled.
+func (p *SeedDiskPool) Start(ctx context.Context) error {
+	log := ctrl.LoggerFrom(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case refill := <-p.refills:
+			if err := p.refill(ctx, refill.client, refill.key); err != nil {
+				log.Error(err, fmt.Sprintf("failed to refill seed disks of image %s on cluster %s", refill.key.imageUUID, refill.key.peUUID))
+			}
+			p.mu.Lock()
+			delete(p.refilling, refill.key)
+			p.mu.Unlock()
+		}
+	}
+}
+
+// Take removes a seed disk of the key from the pool and requests a refill with the Prism Central client of the cluster of
+// the key. Returns false if no seed disk is available, in which case the VM is cloned from the image.
+func (p *SeedDiskPool) Take(ctx context.Context, client *nutanixClientV3.Client, key seedPoolKey) (seedDisk, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requestRefill(ctx, client, key)
+	seeds := p.seeds[key]
+	if len(seeds) == 0 {
+		return seedDisk{}, false
+	}
+	seed := seeds[0]
+	p.seeds[key] = seeds[1:]
+	return seed, true
+}
+
+// Return puts a seed disk that was taken but not used back into the pool
+func (p *SeedDiskPool) Return(key seedPoolKey, seed seedDisk) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.seeds[key] = append(p.seeds[key], seed)
+}
+
+// Release deletes the seed VM of a seed disk that was cloned
+func (p *SeedDiskPool) Release(ctx context.Context, client *nutanixClientV3.Client, seed seedDisk) error {
+	_, err := DeleteVM(ctx, client, "seed", seed.vmUUID)
+	return err
+}
+
+// requestRefill queues a refill of the seed disks of the key unless one is pending. Must be called with the lock held.
+func (p *SeedDiskPool) requestRefill(ctx context.Context, client *nutanixClientV3.Client, key seedPoolKey) {
+	if p.refilling[key] {
+		return
+	}
+	select {
+	case p.refills <- seedPoolRefill{key: key, client: client}:
+		p.refilling[key] = true
+	default:
+		ctrl.LoggerFrom(ctx).V(1).Info(fmt.Sprintf("seed disk pool refill queue is full. Skipping refill of image %s on cluster %s", key.imageUUID, key.peUUID))
+	}
+}
+
+// refill adopts the existing seed VMs of the key on first use and deletes those exceeding the size of the pool, and
+// creates seed VMs until the pool is full
+func (p *SeedDiskPool) refill(ctx context.Context, client *nutanixClientV3.Client, key seedPoolKey) error {
+	log := ctrl.LoggerFrom(ctx)
+	p.mu.Lock()
+	discovered := p.discovered[key]
+	p.mu.Unlock()
+	if !discovered {
+		seeds, err := findSeedDisks(ctx, client, key)
+		if err != nil {
+			return err
+		}
+		p.mu.Lock()
+		adopted := p.size - len(p.seeds[key])
+		if adopted < 0 {
+			adopted = 0
+		}
+		if adopted > len(seeds) {
+			adopted = len(seeds)
+		}
+		p.seeds[key] = append(p.seeds[key], seeds[:adopted]...)
+		p.mu.Unlock()
+		if adopted > 0 {
+			log.Info(fmt.Sprintf("adopted %d seed disks of image %s on cluster %s", adopted, key.imageUUID, key.peUUID))
+		}
+		// The pool was shrunk since the seed VMs were created
+		for _, seed := range seeds[adopted:] {
+			if _, err := DeleteVM(ctx, client, "seed", seed.vmUUID); err != nil {
+				return fmt.Errorf("failed to delete seed VM %s exceeding the seed disk pool size: %v", seed.vmUUID, err)
+			}
+		}
+		p.mu.Lock()
+		p.discovered[key] = true
+		p.mu.Unlock()
+	}
+	for {
+		p.mu.Lock()
+		missing := p.size - len(p.seeds[key])
+		p.mu.Unlock()
+		if missing <= 0 {
+			return nil
+		}
+		seed, err := createSeedDisk(ctx, client, key)
+		if err != nil {
+			return err
+		}
+		log.V(1).Info(fmt.Sprintf("created seed VM %s of image %s on cluster %s", seed.vmUUID, key.imageUUID, key.peUUID))
+		p.mu.Lock()
+		p.seeds[key] = append(p.seeds[key], seed)
+		p.mu.Unlock()
+	}
+}
+
+// findSeedDisks returns the seed disks held by the existing seed VMs of the cluster, image and Prism Element cluster of
+// the key
+func findSeedDisks(ctx context.Context, client *nutanixClientV3.Client, key seedPoolKey) ([]seedDisk, error) {
+	namePrefix := seedVMNamePrefix + key.imageUUID + "-"
+	vms, err := nutanixClient.ListAllVMs(ctx, client, fmt.Sprintf("vm_name==%s.*", namePrefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list seed VMs of image %s: %v", key.imageUUID, err)
+	}
+	seeds := make([]seedDisk, 0)
+	for _, entity := range vms {
+		if entity == nil || entity.Metadata == nil || entity.Spec == nil {
+			continue
+		}
+		if !strings.HasPrefix(utils.StringValue(entity.Spec.Name), namePrefix) {
+			continue
+		}
+		if entity.Spec.ClusterReference == nil || utils.StringValue(entity.Spec.ClusterReference.UUID) != key.peUUID {
+			continue
+		}
+		if !isSeedVMOfCluster(entity.Metadata, key.clusterName) {
+			continue
+		}
+		seed, ok := getSeedDisk(&nutanixClientV3.VMIntentResponse{Metadata: entity.Metadata, Spec: entity.Spec})
+		if ok {
+			seeds = append(seeds, seed)
+		}
+	}
+	return seeds, nil
+}
+
+// createSeedDisk creates a powered off seed VM with a disk cloned from the image on the Prism Element cluster, and
+// waits for its creation. The seed VM carries the ownership categories of the cluster of the key.
+func createSeedDisk(ctx context.Context, client *nutanixClientV3.Client, key seedPoolKey) (seedDisk, error) {
+	vmName := seedVMNamePrefix + key.imageUUID + "-" + utilrand.String(8)
+	categories := make(map[string]string)
+	for _, ci := range GetDefaultCAPICategoryIdentifiers(key.clusterName) {
+		categories[ci.Key] = ci.Value
+	}
+	vmInput := &nutanixClientV3.VMIntentInput{
+		Metadata: &nutanixClientV3.Metadata{
+			Kind:        utils.StringPtr("vm"),
+			SpecVersion: utils.Int64Ptr(1),
+			Categories:  categories,
+		},
+		Spec: &nutanixClientV3.VM{
+			Name:        utils.StringPtr(vmName),
+			Description: utils.StringPtr(seedVMDescription),
+			ClusterReference: &nutanixClientV3.Reference{
+				Kind: utils.StringPtr("cluster"),
+				UUID: utils.StringPtr(key.peUUID),
+			},
+			Resources: &nutanixClientV3.VMResources{
+				PowerState:        utils.StringPtr("OFF"),
+				NumSockets:        utils.Int64Ptr(1),
+				NumVcpusPerSocket: utils.Int64Ptr(1),
+				MemorySizeMib:     utils.Int64Ptr(seedVMMemorySizeMib),
+				DiskList: []*nutanixClientV3.VMDisk{
+					{
+						DataSourceReference: &nutanixClientV3.Reference{
+							Kind: utils.StringPtr("image"),
+							UUID: utils.StringPtr(key.imageUUID),
+						},
+					},
+				},
+			},
+		},
+	}
+	vmResponse, err := client.V3.CreateVM(ctx, vmInput)
+	if err != nil {
+		return seedDisk{}, fmt.Errorf("failed to create seed VM %s: %v", vmName, err)
+	}
+	if vmResponse.Metadata == nil || utils.StringValue(vmResponse.Metadata.UUID) == "" {
+		return seedDisk{}, fmt.Errorf("no VM UUID found in response after creating seed VM %s", vmName)
+	}
+	taskUUID, err := GetTaskUUIDFromVM(vmResponse)
+	if err != nil {
+		return seedDisk{}, err
+	}
+	if err := nutanixClient.WaitForTaskCompletion(ctx, client, taskUUID); err != nil {
+		return seedDisk{}, fmt.Errorf("failed to wait for task %s creating seed VM %s: %v", taskUUID, vmName, err)
+	}
+	vm, err := FindVMByUUID(ctx, client, *vmResponse.Metadata.UUID)
+	if err != nil {
+		return seedDisk{}, err
+	}
+	if vm == nil {
+		return seedDisk{}, fmt.Errorf("seed VM %s not found after creation", vmName)
+	}
+	seed, ok := getSeedDisk(vm)
+	if !ok {
+		return seedDisk{}, fmt.Errorf("seed VM %s has no disk", vmName)
+	}
+	return seed, nil
+}
+
+// deleteSeedVMs deletes the seed VMs of all images and Prism Element clusters owned by the cluster, and returns the
+// number of deleted seed VMs
+func deleteSeedVMs(ctx context.Context, client *nutanixClientV3.Client, clusterName string) (int, error) {
+	vms, err := nutanixClient.ListAllVMs(ctx, client, fmt.Sprintf("vm_name==%s.*", seedVMNamePrefix))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list seed VMs of cluster %s: %v", clusterName, err)
+	}
+	deleted := 0
+	for _, entity := range vms {
+		if entity == nil || entity.Metadata == nil || entity.Spec == nil {
+			continue
+		}
+		vmName := utils.StringValue(entity.Spec.Name)
+		if !strings.HasPrefix(vmName, seedVMNamePrefix) || !isSeedVMOfCluster(entity.Metadata, clusterName) {
+			continue
+		}
+		if _, err := DeleteVM(ctx, client, vmName, utils.StringValue(entity.Metadata.UUID)); err != nil {
+			return deleted, fmt.Errorf("failed to delete seed VM %s of cluster %s: %v", vmName, clusterName, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// isSeedVMOfCluster returns true if the seed VM carries the ownership categories of the cluster
+func isSeedVMOfCluster(metadata *nutanixClientV3.Metadata, clusterName string) bool {
+	for _, ci := range GetDefaultCAPICategoryIdentifiers(clusterName) {
+		if value, ok := metadata.Categories[ci.Key]; !ok || value != ci.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// getSeedDisk returns the seed disk held by a seed VM
+func getSeedDisk(vm *nutanixClientV3.VMIntentResponse) (seedDisk, bool) {
+	disk := GetVMSystemDisk(vm)
+	if disk == nil || utils.StringValue(disk.UUID) == "" {
+		return seedDisk{}, false
+	}
+	return seedDisk{vmUUID: *vm.Metadata.UUID, diskUUID: *disk.UUID}, true
+}
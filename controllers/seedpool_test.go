@@ -0,0 +1,347 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
+
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+// seedPoolTestService is a Prism v3 service holding the VMs created through it
+type seedPoolTestService struct {
+	nutanixClientV3.Service
+	vms     map[string]*nutanixClientV3.VMIntentResponse
+	created int
+	deleted []string
+}
+
+func newSeedPoolTestService() *seedPoolTestService {
+	return &seedPoolTestService{vms: make(map[string]*nutanixClientV3.VMIntentResponse)}
+}
+
+// addSeedVM adds an existing seed VM of the image on the Prism Element cluster owned by the cluster. The seed VM has no
+// ownership categories if clusterName is empty.
+func (s *seedPoolTestService) addSeedVM(name, vmUUID, diskUUID, imageUUID, peUUID, clusterName string) {
+	categories := make(map[string]string)
+	if clusterName != "" {
+		for _, ci := range GetDefaultCAPICategoryIdentifiers(clusterName) {
+			categories[ci.Key] = ci.Value
+		}
+	}
+	s.vms[vmUUID] = &nutanixClientV3.VMIntentResponse{
+		Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(vmUUID), Categories: categories},
+		Spec: &nutanixClientV3.VM{
+			Name:             pointer.String(name),
+			ClusterReference: &nutanixClientV3.Reference{Kind: pointer.String("cluster"), UUID: pointer.String(peUUID)},
+			Resources: &nutanixClientV3.VMResources{
+				DiskList: []*nutanixClientV3.VMDisk{
+					{
+						UUID:                pointer.String(diskUUID),
+						DataSourceReference: &nutanixClientV3.Reference{Kind: pointer.String("image"), UUID: pointer.String(imageUUID)},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (s *seedPoolTestService) CreateVM(_ context.Context, body *nutanixClientV3.VMIntentInput) (*nutanixClientV3.VMIntentResponse, error) {
+	s.created++
+	vmUUID := fmt.Sprintf("seed-vm-%d", s.created)
+	disk := body.Spec.Resources.DiskList[0]
+	s.addSeedVM(*body.Spec.Name, vmUUID, fmt.Sprintf("seed-disk-%d", s.created), *disk.DataSourceReference.UUID, *body.Spec.ClusterReference.UUID, "")
+	s.vms[vmUUID].Metadata.Categories = body.Metadata.Categories
+	return &nutanixClientV3.VMIntentResponse{
+		Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(vmUUID)},
+		Spec:     body.Spec,
+		Status: &nutanixClientV3.VMDefStatus{
+			ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "create-task"},
+		},
+	}, nil
+}
+
+func (s *seedPoolTestService) GetVM(_ context.Context, uuid string) (*nutanixClientV3.VMIntentResponse, error) {
+	vm, ok := s.vms[uuid]
+	if !ok {
+		return nil, fmt.Errorf("VM_NOT_FOUND")
+	}
+	return vm, nil
+}
+
+func (s *seedPoolTestService) ListVM(_ context.Context, _ *nutanixClientV3.DSMetadata) (*nutanixClientV3.VMListIntentResponse, error) {
+	res := &nutanixClientV3.VMListIntentResponse{}
+	for _, vm := range s.vms {
+		res.Entities = append(res.Entities, &nutanixClientV3.VMIntentResource{Metadata: vm.Metadata, Spec: vm.Spec})
+	}
+	return res, nil
+}
+
+func (s *seedPoolTestService) GetTask(_ context.Context, _ string) (*nutanixClientV3.TasksResponse, error) {
+	return &nutanixClientV3.TasksResponse{Status: pointer.String("SUCCEEDED")}, nil
+}
+
+func (s *seedPoolTestService) DeleteVM(_ context.Context, uuid string) (*nutanixClientV3.DeleteResponse, error) {
+	s.deleted = append(s.deleted, uuid)
+	delete(s.vms, uuid)
+	return &nutanixClientV3.DeleteResponse{
+		Status: &nutanixClientV3.DeleteStatus{
+			ExecutionContext: &nutanixClientV3.ExecutionContext{TaskUUID: "delete-task"},
+		},
+	}, nil
+}
+
+func TestNewSeedDiskPool(t *testing.T) {
+	g := NewWithT(t)
+	_, err := NewSeedDiskPool(0)
+	g.Expect(err).To(HaveOccurred())
+	pool, err := NewSeedDiskPool(2)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pool.size).To(Equal(2))
+}
+
+func TestSeedDiskPoolTake(t *testing.T) {
+	const imageUUID, peUUID = "image-uuid", "pe-uuid"
+	key := seedPoolKey{clusterNamespace: "default", clusterName: "cluster", imageUUID: imageUUID, peUUID: peUUID}
+
+	tests := []struct {
+		name            string
+		seeds           []seedDisk
+		refilling       bool
+		expectHit       bool
+		expectSeed      seedDisk
+		expectRemaining int
+		expectRefills   int
+	}{
+		{
+			name:          "miss requests a refill",
+			expectRefills: 1,
+		},
+		{
+			name:            "hit takes the first seed and requests a refill",
+			seeds:           []seedDisk{{vmUUID: "vm-1", diskUUID: "disk-1"}, {vmUUID: "vm-2", diskUUID: "disk-2"}},
+			expectHit:       true,
+			expectSeed:      seedDisk{vmUUID: "vm-1", diskUUID: "disk-1"},
+			expectRemaining: 1,
+			expectRefills:   1,
+		},
+		{
+			name:      "pending refill is not requested again",
+			refilling: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			pool, err := NewSeedDiskPool(2)
+			g.Expect(err).NotTo(HaveOccurred())
+			pool.seeds[key] = tt.seeds
+			pool.refilling[key] = tt.refilling
+
+			seed, ok := pool.Take(context.Background(), &nutanixClientV3.Client{}, key)
+			g.Expect(ok).To(Equal(tt.expectHit))
+			g.Expect(seed).To(Equal(tt.expectSeed))
+			g.Expect(pool.seeds[key]).To(HaveLen(tt.expectRemaining))
+			g.Expect(pool.refills).To(HaveLen(tt.expectRefills))
+			g.Expect(pool.refilling[key]).To(BeTrue())
+		})
+	}
+}
+
+func TestSeedDiskPoolReturn(t *testing.T) {
+	g := NewWithT(t)
+	pool, err := NewSeedDiskPool(1)
+	g.Expect(err).NotTo(HaveOccurred())
+	key := seedPoolKey{clusterNamespace: "default", clusterName: "cluster", imageUUID: "image-uuid", peUUID: "pe-uuid"}
+	seed := seedDisk{vmUUID: "vm-1", diskUUID: "disk-1"}
+	pool.Return(key, seed)
+	taken, ok := pool.Take(context.Background(), &nutanixClientV3.Client{}, key)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(taken).To(Equal(seed))
+}
+
+func TestSeedDiskPoolRefill(t *testing.T) {
+	const imageUUID, peUUID = "image-uuid", "pe-uuid"
+	key := seedPoolKey{clusterNamespace: "default", clusterName: "cluster", imageUUID: imageUUID, peUUID: peUUID}
+
+	tests := []struct {
+		name          string
+		existingSeeds func(s *seedPoolTestService)
+		poolSeeds     []seedDisk
+		expectSeeds   []seedDisk
+		expectCreated int
+		expectDeleted []string
+	}{
+		{
+			name:          "empty pool is filled",
+			expectSeeds:   []seedDisk{{vmUUID: "seed-vm-1", diskUUID: "seed-disk-1"}, {vmUUID: "seed-vm-2", diskUUID: "seed-disk-2"}},
+			expectCreated: 2,
+		},
+		{
+			name: "existing seed VMs are adopted",
+			existingSeeds: func(s *seedPoolTestService) {
+				s.addSeedVM(seedVMNamePrefix+imageUUID+"-abcdefgh", "existing-vm", "existing-disk", imageUUID, peUUID, "cluster")
+				// seed VMs of other clusters, Prism Element clusters and images, and other VMs are ignored
+				s.addSeedVM(seedVMNamePrefix+imageUUID+"-qrstuvwx", "other-cluster-vm", "other-cluster-disk", imageUUID, peUUID, "other-cluster")
+				s.addSeedVM(seedVMNamePrefix+imageUUID+"-yzabcdef", "unowned-vm", "unowned-disk", imageUUID, peUUID, "")
+				s.addSeedVM(seedVMNamePrefix+imageUUID+"-ijklmnop", "other-pe-vm", "other-pe-disk", imageUUID, "other-pe-uuid", "cluster")
+				s.addSeedVM(seedVMNamePrefix+"other-image-uuid-abcdefgh", "other-image-vm", "other-image-disk", "other-image-uuid", peUUID, "cluster")
+				s.addSeedVM("machine-1", "machine-vm", "machine-disk", imageUUID, peUUID, "cluster")
+			},
+			expectSeeds:   []seedDisk{{vmUUID: "existing-vm", diskUUID: "existing-disk"}, {vmUUID: "seed-vm-1", diskUUID: "seed-disk-1"}},
+			expectCreated: 1,
+		},
+		{
+			name: "seed VMs exceeding the shrunk pool are deleted",
+			existingSeeds: func(s *seedPoolTestService) {
+				s.addSeedVM(seedVMNamePrefix+imageUUID+"-abcdefgh", "existing-vm-1", "existing-disk-1", imageUUID, peUUID, "cluster")
+				s.addSeedVM(seedVMNamePrefix+imageUUID+"-ijklmnop", "existing-vm-2", "existing-disk-2", imageUUID, peUUID, "cluster")
+			},
+			poolSeeds:     []seedDisk{{vmUUID: "vm-1", diskUUID: "disk-1"}},
+			expectSeeds:   []seedDisk{{vmUUID: "vm-1", diskUUID: "disk-1"}, {vmUUID: "existing-vm-1", diskUUID: "existing-disk-1"}},
+			expectCreated: 0,
+			expectDeleted: []string{"existing-vm-2"},
+		},
+		{
+			name:          "full pool is not refilled",
+			poolSeeds:     []seedDisk{{vmUUID: "vm-1", diskUUID: "disk-1"}, {vmUUID: "vm-2", diskUUID: "disk-2"}},
+			expectSeeds:   []seedDisk{{vmUUID: "vm-1", diskUUID: "disk-1"}, {vmUUID: "vm-2", diskUUID: "disk-2"}},
+			expectCreated: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			service := newSeedPoolTestService()
+			if tt.existingSeeds != nil {
+				tt.existingSeeds(service)
+			}
+			pool, err := NewSeedDiskPool(2)
+			g.Expect(err).NotTo(HaveOccurred())
+			pool.seeds[key] = tt.poolSeeds
+
+			err = pool.refill(context.Background(), &nutanixClientV3.Client{V3: service}, key)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(pool.seeds[key]).To(Equal(tt.expectSeeds))
+			g.Expect(service.created).To(Equal(tt.expectCreated))
+			g.Expect(service.deleted).To(ConsistOf(tt.expectDeleted))
+			g.Expect(pool.discovered[key]).To(BeTrue())
+			for uuid, vm := range service.vms {
+				if strings.HasPrefix(uuid, "seed-vm-") {
+					g.Expect(*vm.Spec.Name).To(HavePrefix(seedVMNamePrefix + imageUUID + "-"))
+					g.Expect(len(*vm.Spec.Name)).To(BeNumerically("<=", maxVMNameLength))
+					g.Expect(isSeedVMOfCluster(vm.Metadata, "cluster")).To(BeTrue())
+				}
+			}
+		})
+	}
+}
+
+func TestSeedDiskPoolRelease(t *testing.T) {
+	g := NewWithT(t)
+	service := newSeedPoolTestService()
+	service.addSeedVM(seedVMNamePrefix+"image-uuid-abcdefgh", "seed-vm", "seed-disk", "image-uuid", "pe-uuid", "cluster")
+	pool, err := NewSeedDiskPool(1)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	err = pool.Release(context.Background(), &nutanixClientV3.Client{V3: service}, seedDisk{vmUUID: "seed-vm", diskUUID: "seed-disk"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(service.deleted).To(ConsistOf("seed-vm"))
+}
+
+func TestPutBackSeedDisk(t *testing.T) {
+	key := seedPoolKey{clusterNamespace: "default", clusterName: "cluster", imageUUID: "image-uuid", peUUID: "pe-uuid"}
+	seed := seedDisk{vmUUID: "seed-vm", diskUUID: "seed-disk"}
+
+	tests := []struct {
+		name          string
+		submitted     bool
+		expectSeeds   []seedDisk
+		expectDeleted []string
+	}{
+		{
+			name:        "seed disk is returned if the VM creation was not submitted",
+			expectSeeds: []seedDisk{seed},
+		},
+		{
+			name:          "seed VM is deleted if the VM creation was submitted",
+			submitted:     true,
+			expectDeleted: []string{"seed-vm"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			service := newSeedPoolTestService()
+			service.addSeedVM(seedVMNamePrefix+"image-uuid-abcdefgh", "seed-vm", "seed-disk", "image-uuid", "pe-uuid", "cluster")
+			pool, err := NewSeedDiskPool(1)
+			g.Expect(err).NotTo(HaveOccurred())
+			reconciler := &NutanixMachineReconciler{seedDiskPool: pool}
+			rctx := &nctx.MachineContext{
+				Context:       context.Background(),
+				NutanixClient: &nutanixClientV3.Client{V3: service},
+			}
+
+			reconciler.putBackSeedDisk(rctx, key, seed, tt.submitted)
+			g.Expect(pool.seeds[key]).To(Equal(tt.expectSeeds))
+			g.Expect(service.deleted).To(ConsistOf(tt.expectDeleted))
+		})
+	}
+}
+
+func TestDeleteSeedVMs(t *testing.T) {
+	g := NewWithT(t)
+	service := newSeedPoolTestService()
+	service.addSeedVM(seedVMNamePrefix+"image-uuid-abcdefgh", "seed-vm-1", "seed-disk-1", "image-uuid", "pe-uuid", "cluster")
+	service.addSeedVM(seedVMNamePrefix+"other-image-uuid-abcdefgh", "seed-vm-2", "seed-disk-2", "other-image-uuid", "other-pe-uuid", "cluster")
+	// seed VMs of other clusters and other VMs of the cluster are kept
+	service.addSeedVM(seedVMNamePrefix+"image-uuid-ijklmnop", "other-cluster-vm", "other-cluster-disk", "image-uuid", "pe-uuid", "other-cluster")
+	service.addSeedVM("machine-1", "machine-vm", "machine-disk", "image-uuid", "pe-uuid", "cluster")
+
+	deleted, err := deleteSeedVMs(context.Background(), &nutanixClientV3.Client{V3: service}, "cluster")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(Equal(2))
+	g.Expect(service.deleted).To(ConsistOf("seed-vm-1", "seed-vm-2"))
+	g.Expect(service.vms).To(HaveKey("other-cluster-vm"))
+	g.Expect(service.vms).To(HaveKey("machine-vm"))
+}
+
+func TestGetVMSystemDiskFromSeed(t *testing.T) {
+	g := NewWithT(t)
+	vm := &nutanixClientV3.VMIntentResponse{
+		Spec: &nutanixClientV3.VM{
+			Resources: &nutanixClientV3.VMResources{
+				DiskList: []*nutanixClientV3.VMDisk{
+					{
+						UUID:                pointer.String("disk-uuid"),
+						DataSourceReference: &nutanixClientV3.Reference{Kind: pointer.String("vm_disk"), UUID: pointer.String("seed-disk-uuid")},
+					},
+				},
+			},
+		},
+	}
+	disk := GetVMSystemDisk(vm)
+	g.Expect(disk).NotTo(BeNil())
+	g.Expect(*disk.UUID).To(Equal("disk-uuid"))
+}
@@ -0,0 +1,581 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
+)
+
+// Minimum memory size and number of vCPUs of VMs per boot type. VMs with UEFI secure boot are created with the Q35
+// machine type and need more resources to boot.
+//
+//	boot type         | memory | vCPUs
+//	legacy            | 2Gi    | 1
+//	uefi              | 2Gi    | 1
+//	uefi, secure boot | 4Gi    | 2
+const (
+	legacyBootMinMemorySize = "2Gi"
+	legacyBootMinVCPUs      = 1
+	uefiBootMinMemorySize   = "2Gi"
+	uefiBootMinVCPUs        = 1
+	secureBootMinMemorySize = "4Gi"
+	secureBootMinVCPUs      = 2
+)
+
+// getMachineResourceMinimums returns the minimum memory size and number of vCPUs of the VM of the NutanixMachine spec
+// for its boot type
+func getMachineResourceMinimums(spec *infrav1.NutanixMachineSpec) (resource.Quantity, int32) {
+	switch {
+	case spec.SecureBoot != nil && *spec.SecureBoot:
+		return resource.MustParse(secureBootMinMemorySize), secureBootMinVCPUs
+	case spec.BootType == infrav1.NutanixBootTypeUEFI:
+		return resource.MustParse(uefiBootMinMemorySize), uefiBootMinVCPUs
+	default:
+		return resource.MustParse(legacyBootMinMemorySize), legacyBootMinVCPUs
+	}
+}
+
+// ValidateNutanixClusterSpec verifies the fields of the NutanixCluster spec that do not require Prism Central
+func ValidateNutanixClusterSpec(nutanixCluster *infrav1.NutanixCluster) field.ErrorList {
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, validateCredentialRef(nutanixCluster)...)
+	allErrs = append(allErrs, validateClientCertificate(nutanixCluster)...)
+	allErrs = append(allErrs, validateInsecureSkipVerify(nutanixCluster)...)
+	allErrs = append(allErrs, validateEndpoints(nutanixCluster)...)
+	return allErrs
+}
+
+// validateCredentialRef verifies that a credential reference of kind File references a file path in the credential
+// file directory of the controller
+func validateCredentialRef(nutanixCluster *infrav1.NutanixCluster) field.ErrorList {
+	if nutanixCluster.Spec.PrismCentral == nil || nutanixCluster.Spec.PrismCentral.CredentialRef == nil {
+		return nil
+	}
+	credentialRef := nutanixCluster.Spec.PrismCentral.CredentialRef
+	if credentialRef.Kind != nutanixClient.FileCredentialKind {
+		return nil
+	}
+	namePath := field.NewPath("spec", "prismCentral", "credentialRef", "name")
+	if strings.TrimSpace(credentialRef.Name) == "" {
+		return field.ErrorList{field.Required(namePath, "path of the credential file must be set for kind File")}
+	}
+	if err := nutanixClient.CredentialFiles.ValidatePath(credentialRef.Name); err != nil {
+		return field.ErrorList{field.Forbidden(namePath, err.Error())}
+	}
+	return nil
+}
+
+// validateClientCertificate verifies that the client certificate and its private key are referenced together
+func validateClientCertificate(nutanixCluster *infrav1.NutanixCluster) field.ErrorList {
+	clientCertificate := nutanixCluster.Spec.ClientCertificate
+	if clientCertificate == nil {
+		return nil
+	}
+	allErrs := field.ErrorList{}
+	clientCertificatePath := field.NewPath("spec", "clientCertificate")
+	if clientCertificate.CertificateRef == nil {
+		allErrs = append(allErrs, field.Required(clientCertificatePath.Child("certificateRef"), "certificateRef and keyRef must be set together"))
+	}
+	if clientCertificate.KeyRef == nil {
+		allErrs = append(allErrs, field.Required(clientCertificatePath.Child("keyRef"), "certificateRef and keyRef must be set together"))
+	}
+	return allErrs
+}
+
+// validateInsecureSkipVerify verifies that insecureSkipVerify is only enabled if explicitly allowed with an annotation
+func validateInsecureSkipVerify(nutanixCluster *infrav1.NutanixCluster) field.ErrorList {
+	if nutanixCluster.Spec.InsecureSkipVerify == nil || !*nutanixCluster.Spec.InsecureSkipVerify {
+		return nil
+	}
+	if _, ok := nutanixCluster.GetAnnotations()[infrav1.AllowInsecureSkipVerifyAnnotation]; ok {
+		return nil
+	}
+	return field.ErrorList{
+		field.Forbidden(field.NewPath("spec", "insecureSkipVerify"), fmt.Sprintf("insecureSkipVerify can only be enabled if annotation %s is set", infrav1.AllowInsecureSkipVerifyAnnotation)),
+	}
+}
+
+// validateEndpoints verifies that the Prism Central endpoint can be normalized and that IPv6 addresses of the control
+// plane endpoint are well-formed. The Prism Central address may be enclosed in brackets or be an https URL. The control
+// plane endpoint host must not be enclosed in brackets, as Cluster API encloses IPv6 addresses in brackets when
+// formatting the endpoint.
+func validateEndpoints(nutanixCluster *infrav1.NutanixCluster) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if prismCentral := nutanixCluster.Spec.PrismCentral; prismCentral != nil && prismCentral.Address != "" {
+		prismCentralPath := field.NewPath("spec", "prismCentral")
+		if _, err := nutanixClient.NormalizePrismEndpoint(*prismCentral); errors.Is(err, nutanixClient.ErrInvalidPrismPort) {
+			allErrs = append(allErrs, field.Invalid(prismCentralPath.Child("port"), prismCentral.Port, err.Error()))
+		} else if err != nil {
+			allErrs = append(allErrs, field.Invalid(prismCentralPath.Child("address"), prismCentral.Address, err.Error()))
+		}
+	}
+	if host := nutanixCluster.Spec.ControlPlaneEndpoint.Host; host != "" {
+		hostPath := field.NewPath("spec", "controlPlaneEndpoint", "host")
+		if parsedHost, err := nutanixClient.ParseHost(host); err != nil {
+			allErrs = append(allErrs, field.Invalid(hostPath, host, err.Error()))
+		} else if parsedHost != host {
+			allErrs = append(allErrs, field.Invalid(hostPath, host, "IPv6 address must not be enclosed in brackets"))
+		}
+	}
+	return allErrs
+}
+
+// ValidateFailureDomainReferences verifies the cluster and subnet identifiers of all failure domains of the NutanixCluster.
+// UUID identifiers are checked for a valid format, name identifiers must exist in Prism Central. The client is only
+// created once a name identifier must be looked up. An error is returned if the client cannot be created.
+// If newClient is nil, name identifiers are not looked up.
+func ValidateFailureDomainReferences(ctx context.Context, newClient func() (*nutanixClientV3.Client, error), nutanixCluster *infrav1.NutanixCluster) (field.ErrorList, error) {
+	var client *nutanixClientV3.Client
+	getClient := func() (*nutanixClientV3.Client, error) {
+		if client != nil || newClient == nil {
+			return client, nil
+		}
+		c, err := newClient()
+		if err != nil {
+			return nil, err
+		}
+		client = c
+		return client, nil
+	}
+
+	allErrs := field.ErrorList{}
+	fdsPath := field.NewPath("spec", "failureDomains")
+	for i, fd := range nutanixCluster.Spec.FailureDomains {
+		fdPath := fdsPath.Index(i)
+		clusterPath := fdPath.Child("cluster")
+		peUUID := ""
+		switch fd.Cluster.Type {
+		case infrav1.NutanixIdentifierUUID:
+			if err := validateIdentifierUUID(fd.Cluster.UUID); err != nil {
+				allErrs = append(allErrs, field.Invalid(clusterPath.Child("uuid"), fd.Cluster.UUID, err.Error()))
+				continue
+			}
+			peUUID = *fd.Cluster.UUID
+		case infrav1.NutanixIdentifierName:
+			if fd.Cluster.Name == nil || *fd.Cluster.Name == "" {
+				allErrs = append(allErrs, field.Required(clusterPath.Child("name"), "name must be set for identifier type name"))
+				continue
+			}
+			c, err := getClient()
+			if err != nil {
+				return nil, err
+			}
+			if c == nil {
+				break
+			}
+			peUUID, err = GetPEUUID(ctx, c, fd.Cluster.Name, nil)
+			if err != nil {
+				allErrs = append(allErrs, field.NotFound(clusterPath.Child("name"), *fd.Cluster.Name))
+				continue
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(clusterPath.Child("type"), fd.Cluster.Type, []string{string(infrav1.NutanixIdentifierUUID), string(infrav1.NutanixIdentifierName)}))
+			continue
+		}
+
+		for j, subnet := range fd.Subnets {
+			subnetPath := fdPath.Child("subnets").Index(j)
+			switch subnet.Type {
+			case infrav1.NutanixIdentifierUUID:
+				if err := validateIdentifierUUID(subnet.UUID); err != nil {
+					allErrs = append(allErrs, field.Invalid(subnetPath.Child("uuid"), subnet.UUID, err.Error()))
+				}
+			case infrav1.NutanixIdentifierName:
+				if subnet.Name == nil || *subnet.Name == "" {
+					allErrs = append(allErrs, field.Required(subnetPath.Child("name"), "name must be set for identifier type name"))
+					continue
+				}
+				c, err := getClient()
+				if err != nil {
+					return nil, err
+				}
+				if c == nil {
+					continue
+				}
+				if _, err := GetSubnetUUID(ctx, c, peUUID, subnet.Name, nil); err != nil {
+					allErrs = append(allErrs, field.NotFound(subnetPath.Child("name"), *subnet.Name))
+				}
+			case infrav1.NutanixIdentifierCategory:
+				c, err := getClient()
+				if err != nil {
+					return nil, err
+				}
+				allErrs = append(allErrs, validateSubnetReference(ctx, c, subnetPath, subnet, peUUID)...)
+			default:
+				allErrs = append(allErrs, field.NotSupported(subnetPath.Child("type"), subnet.Type, []string{string(infrav1.NutanixIdentifierUUID), string(infrav1.NutanixIdentifierName), string(infrav1.NutanixIdentifierCategory)}))
+			}
+		}
+	}
+	return allErrs, nil
+}
+
+// ValidateNutanixMachineSpec verifies the fields of the NutanixMachine spec at the given path that do not require Prism Central
+func ValidateNutanixMachineSpec(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	allErrs = append(allErrs, validateVolumeGroups(specPath, spec)...)
+	if spec.SystemDiskStorageContainer != nil {
+		allErrs = append(allErrs, validateStorageContainerIdentifier(specPath.Child("systemDiskStorageContainer"), *spec.SystemDiskStorageContainer)...)
+	}
+	if spec.Owner != nil {
+		allErrs = append(allErrs, validateResourceIdentifier(specPath.Child("owner"), *spec.Owner)...)
+	}
+	allErrs = append(allErrs, validateDNSConfig(specPath, spec)...)
+	allErrs = append(allErrs, validateNICs(specPath, spec)...)
+	allErrs = append(allErrs, validateVMNameTemplate(specPath, spec)...)
+	allErrs = append(allErrs, validateHostnameTemplate(specPath, spec)...)
+	allErrs = append(allErrs, validateSecureBoot(specPath, spec)...)
+	allErrs = append(allErrs, validateMachineResources(specPath, spec)...)
+	allErrs = append(allErrs, validateSystemDiskBus(specPath, spec)...)
+	allErrs = append(allErrs, validateSerialPorts(specPath, spec)...)
+	allErrs = append(allErrs, validateBootstrapISO(specPath, spec)...)
+	allErrs = append(allErrs, validateExtraVMConfig(specPath, spec)...)
+	if spec.ExistingVMUUID != "" {
+		if _, err := uuid.Parse(spec.ExistingVMUUID); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("existingVMUUID"), spec.ExistingVMUUID, "must be a valid UUID"))
+		}
+	}
+	return allErrs
+}
+
+// validateExtraVMConfig verifies that the extraVMConfig only sets known fields of the VM intent that are not managed by
+// the controller
+func validateExtraVMConfig(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	if spec.ExtraVMConfig == nil {
+		return nil
+	}
+	if err := ValidateExtraVMConfig(spec.ExtraVMConfig); err != nil {
+		return field.ErrorList{field.Invalid(specPath.Child("extraVMConfig"), string(spec.ExtraVMConfig.Raw), err.Error())}
+	}
+	return nil
+}
+
+// validateSystemDiskBus verifies that the system disk is attached to a supported bus
+func validateSystemDiskBus(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	switch spec.SystemDiskBus {
+	case "", infrav1.NutanixDiskBusSCSI, infrav1.NutanixDiskBusPCI, infrav1.NutanixDiskBusSATA:
+		return nil
+	}
+	return field.ErrorList{
+		field.NotSupported(specPath.Child("systemDiskBus"), spec.SystemDiskBus, []string{
+			string(infrav1.NutanixDiskBusSCSI), string(infrav1.NutanixDiskBusPCI), string(infrav1.NutanixDiskBusSATA),
+		}),
+	}
+}
+
+// validateSerialPorts verifies that the indices of the serial ports are unique
+func validateSerialPorts(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	seen := make(map[int64]bool)
+	for i, serialPort := range spec.SerialPorts {
+		if seen[serialPort.Index] {
+			allErrs = append(allErrs, field.Duplicate(specPath.Child("serialPorts").Index(i).Child("index"), serialPort.Index))
+		}
+		seen[serialPort.Index] = true
+	}
+	return allErrs
+}
+
+// validateBootstrapISO verifies the bootstrap ISO identifier, and that the bootstrap ISO is not combined with the
+// ignition bootstrap format, whose images consume the bootstrap data from the guest customization only
+func validateBootstrapISO(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if spec.BootstrapISO == nil {
+		if spec.EjectBootstrapISO {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("ejectBootstrapISO"), "ejectBootstrapISO requires bootstrapISO"))
+		}
+		return allErrs
+	}
+	bootstrapISOPath := specPath.Child("bootstrapISO")
+	allErrs = append(allErrs, validateResourceIdentifier(bootstrapISOPath, *spec.BootstrapISO)...)
+	if spec.BootstrapFormat == infrav1.NutanixBootstrapFormatIgnition {
+		allErrs = append(allErrs, field.Forbidden(bootstrapISOPath, fmt.Sprintf("bootstrapISO is not supported with the bootstrapFormat %s", infrav1.NutanixBootstrapFormatIgnition)))
+	}
+	return allErrs
+}
+
+// validateMachineResources verifies that the memory size and number of vCPUs are not below the minimums of the boot
+// type. Unset values are skipped, they are set to the minimums by DefaultNutanixMachineSpec.
+func validateMachineResources(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	minMemorySize, minVCPUs := getMachineResourceMinimums(spec)
+	bootType := describeBootType(spec)
+	if !spec.MemorySize.IsZero() && spec.MemorySize.Cmp(minMemorySize) < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("memorySize"), spec.MemorySize.String(),
+			fmt.Sprintf("must be at least %s for VMs with %s", minMemorySize.String(), bootType)))
+	}
+	if spec.VCPUsPerSocket > 0 && spec.VCPUSockets > 0 && spec.VCPUsPerSocket*spec.VCPUSockets < minVCPUs {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("vcpusPerSocket"), spec.VCPUsPerSocket,
+			fmt.Sprintf("vcpusPerSocket times vcpuSockets must be at least %d for VMs with %s", minVCPUs, bootType)))
+	}
+	return allErrs
+}
+
+// describeBootType returns a description of the boot type of the VM of the NutanixMachine spec for error messages
+func describeBootType(spec *infrav1.NutanixMachineSpec) string {
+	switch {
+	case spec.SecureBoot != nil && *spec.SecureBoot:
+		return "UEFI secure boot"
+	case spec.BootType == infrav1.NutanixBootTypeUEFI:
+		return "the uefi boot type"
+	default:
+		return "the legacy boot type"
+	}
+}
+
+// validateSecureBoot verifies that secure boot is only enabled for the UEFI boot type
+func validateSecureBoot(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if spec.SecureBoot != nil && *spec.SecureBoot && spec.BootType != infrav1.NutanixBootTypeUEFI {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("secureBoot"), fmt.Sprintf("secure boot requires the bootType %s", infrav1.NutanixBootTypeUEFI)))
+	}
+	return allErrs
+}
+
+// validateNICs verifies that the network interfaces are not combined with subnets, and that each network interface
+// has either a valid static IPv4 address or a reference to an IPAM pool, or neither
+func validateNICs(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	nicsPath := specPath.Child("nics")
+	if len(spec.NICs) > 0 && len(spec.Subnets) > 0 {
+		allErrs = append(allErrs, field.Forbidden(nicsPath, "nics must not be set together with subnet"))
+	}
+	for i, nic := range spec.NICs {
+		nicPath := nicsPath.Index(i)
+		allErrs = append(allErrs, validateSubnetIdentifier(nicPath.Child("subnet"), nic.Subnet)...)
+		if nic.IPAddress != nil {
+			if ip := net.ParseIP(*nic.IPAddress); ip == nil || ip.To4() == nil {
+				allErrs = append(allErrs, field.Invalid(nicPath.Child("ipAddress"), *nic.IPAddress, "must be a valid IPv4 address"))
+			}
+			if nic.IPAddressPoolRef != nil {
+				allErrs = append(allErrs, field.Forbidden(nicPath.Child("ipAddressPoolRef"), "ipAddressPoolRef must not be set together with ipAddress"))
+			}
+		}
+		if nic.IPAddressPoolRef != nil {
+			if nic.IPAddressPoolRef.Kind == "" {
+				allErrs = append(allErrs, field.Required(nicPath.Child("ipAddressPoolRef", "kind"), "kind of the IPAM pool must be set"))
+			}
+			if nic.IPAddressPoolRef.Name == "" {
+				allErrs = append(allErrs, field.Required(nicPath.Child("ipAddressPoolRef", "name"), "name of the IPAM pool must be set"))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateDNSConfig verifies that the name servers are IP addresses and the search domains are DNS subdomains
+func validateDNSConfig(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, nameServer := range spec.NameServers {
+		if net.ParseIP(nameServer) == nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("nameServers").Index(i), nameServer, "must be a valid IPv4 or IPv6 address"))
+		}
+	}
+	for i, searchDomain := range spec.SearchDomains {
+		for _, msg := range validation.IsDNS1123Subdomain(searchDomain) {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("searchDomains").Index(i), searchDomain, msg))
+		}
+	}
+	return allErrs
+}
+
+// validateVolumeGroups verifies that no volume group is referenced more than once
+func validateVolumeGroups(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	vgsPath := specPath.Child("volumeGroups")
+	seen := make(map[string]bool)
+	for i, vg := range spec.VolumeGroups {
+		var key string
+		switch vg.Type {
+		case infrav1.NutanixIdentifierUUID:
+			key = fmt.Sprintf("uuid/%s", utils.StringValue(vg.UUID))
+		case infrav1.NutanixIdentifierName:
+			key = fmt.Sprintf("name/%s", utils.StringValue(vg.Name))
+		default:
+			continue
+		}
+		if seen[key] {
+			allErrs = append(allErrs, field.Duplicate(vgsPath.Index(i), getResourceIdentifierString(vg)))
+		}
+		seen[key] = true
+	}
+	return allErrs
+}
+
+// ValidateNutanixMachineReferences verifies the image, bootstrap ISO, project, owner, cluster and subnet identifiers of the NutanixMachine spec
+// at the given path. UUID identifiers are checked for a valid format, name identifiers must exist in Prism Central.
+// The cluster and subnets, including the subnets of the network interfaces, are only verified if the cluster is set, as they are taken from the failure domain otherwise.
+// The image is only verified if it is set, as it is taken from the machine defaults of the NutanixCluster otherwise.
+// If client is nil, name identifiers are not looked up.
+func ValidateNutanixMachineReferences(ctx context.Context, client *nutanixClientV3.Client, specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if !isEmptyResourceIdentifier(spec.Image) {
+		allErrs = append(allErrs, validateReference(client, specPath.Child("image"), spec.Image, func(name *string) error {
+			if spec.ImageClusterScoped {
+				// Images with the same name may exist on several clusters, the copy is resolved once the VM is created
+				return findImageByName(ctx, client, *name)
+			}
+			_, err := GetImageUUID(ctx, client, name, nil)
+			return err
+		})...)
+	}
+	if spec.BootstrapISO != nil {
+		allErrs = append(allErrs, validateReference(client, specPath.Child("bootstrapISO"), *spec.BootstrapISO, func(name *string) error {
+			_, err := GetImageUUID(ctx, client, name, nil)
+			return err
+		})...)
+	}
+	if spec.Project != nil {
+		allErrs = append(allErrs, validateReference(client, specPath.Child("project"), *spec.Project, func(name *string) error {
+			_, err := GetProjectUUID(ctx, client, name, nil)
+			return err
+		})...)
+	}
+	if spec.Owner != nil {
+		allErrs = append(allErrs, validateReference(client, specPath.Child("owner"), *spec.Owner, func(name *string) error {
+			_, err := GetUserUUID(ctx, client, name, nil)
+			return err
+		})...)
+	}
+	if spec.Cluster.Type == "" {
+		return allErrs
+	}
+
+	peUUID := utils.StringValue(spec.Cluster.UUID)
+	clusterErrs := validateReference(client, specPath.Child("cluster"), spec.Cluster, func(name *string) error {
+		var err error
+		peUUID, err = GetPEUUID(ctx, client, name, nil)
+		return err
+	})
+	if len(clusterErrs) > 0 {
+		return append(allErrs, clusterErrs...)
+	}
+	for i, subnet := range spec.Subnets {
+		allErrs = append(allErrs, validateSubnetReference(ctx, client, specPath.Child("subnet").Index(i), subnet, peUUID)...)
+	}
+	for i, nic := range spec.NICs {
+		allErrs = append(allErrs, validateSubnetReference(ctx, client, specPath.Child("nics").Index(i).Child("subnet"), nic.Subnet, peUUID)...)
+	}
+	return allErrs
+}
+
+// validateSubnetReference verifies the subnet identifier and looks up the subnet on the Prism Element cluster with the
+// given UUID if it is referenced by name or category and a client is given
+func validateSubnetReference(ctx context.Context, client *nutanixClientV3.Client, path *field.Path, identifier infrav1.NutanixResourceIdentifier, peUUID string) field.ErrorList {
+	if identifier.Type != infrav1.NutanixIdentifierCategory {
+		return validateReference(client, path, identifier, func(name *string) error {
+			_, err := GetSubnetUUID(ctx, client, peUUID, name, nil)
+			return err
+		})
+	}
+	if allErrs := validateSubnetIdentifier(path, identifier); len(allErrs) > 0 || client == nil {
+		return allErrs
+	}
+	if _, err := GetSubnetUUIDByIdentifier(ctx, client, peUUID, identifier); err != nil {
+		return field.ErrorList{field.Invalid(path.Child("category"), fmt.Sprintf("%s=%s", identifier.Category.Key, identifier.Category.Value), err.Error())}
+	}
+	return nil
+}
+
+// findImageByName returns an error if no image with the given name exists on any cluster
+func findImageByName(ctx context.Context, client *nutanixClientV3.Client, name string) error {
+	responseImages, err := nutanixClient.ListAllImages(ctx, client, getFilterForName(name))
+	if err != nil {
+		return err
+	}
+	for _, image := range responseImages {
+		if *image.Spec.Name == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to retrieve image by name %s", name)
+}
+
+// validateReference verifies the resource identifier and looks up the resource if it is referenced by name and a client is given
+func validateReference(client *nutanixClientV3.Client, path *field.Path, identifier infrav1.NutanixResourceIdentifier, lookupByName func(name *string) error) field.ErrorList {
+	if allErrs := validateResourceIdentifier(path, identifier); len(allErrs) > 0 {
+		return allErrs
+	}
+	if identifier.Type != infrav1.NutanixIdentifierName || client == nil {
+		return nil
+	}
+	if err := lookupByName(identifier.Name); err != nil {
+		return field.ErrorList{field.NotFound(path.Child("name"), *identifier.Name)}
+	}
+	return nil
+}
+
+// validateResourceIdentifier verifies that the uuid or name matching the type of the identifier is set
+func validateResourceIdentifier(path *field.Path, identifier infrav1.NutanixResourceIdentifier) field.ErrorList {
+	switch identifier.Type {
+	case infrav1.NutanixIdentifierUUID:
+		if err := validateIdentifierUUID(identifier.UUID); err != nil {
+			return field.ErrorList{field.Invalid(path.Child("uuid"), identifier.UUID, err.Error())}
+		}
+	case infrav1.NutanixIdentifierName:
+		if identifier.Name == nil || *identifier.Name == "" {
+			return field.ErrorList{field.Required(path.Child("name"), "name must be set for identifier type name")}
+		}
+	default:
+		return field.ErrorList{field.NotSupported(path.Child("type"), identifier.Type, []string{string(infrav1.NutanixIdentifierUUID), string(infrav1.NutanixIdentifierName)})}
+	}
+	return nil
+}
+
+// validateSubnetIdentifier verifies the subnet identifier, which can identify the subnet by category in addition to
+// uuid and name
+func validateSubnetIdentifier(path *field.Path, identifier infrav1.NutanixResourceIdentifier) field.ErrorList {
+	if identifier.Type != infrav1.NutanixIdentifierCategory {
+		return validateResourceIdentifier(path, identifier)
+	}
+	if identifier.Category == nil || identifier.Category.Key == "" || identifier.Category.Value == "" {
+		return field.ErrorList{field.Required(path.Child("category"), "category key and value must be set for identifier type category")}
+	}
+	return nil
+}
+
+// validateStorageContainerIdentifier verifies the storage container identifier, which can only identify the storage
+// container by uuid since Prism Central cannot look up storage containers by name
+func validateStorageContainerIdentifier(path *field.Path, identifier infrav1.NutanixResourceIdentifier) field.ErrorList {
+	if identifier.Type != infrav1.NutanixIdentifierUUID {
+		return field.ErrorList{field.NotSupported(path.Child("type"), identifier.Type, []string{string(infrav1.NutanixIdentifierUUID)})}
+	}
+	return validateResourceIdentifier(path, identifier)
+}
+
+func validateIdentifierUUID(id *string) error {
+	if id == nil || *id == "" {
+		return fmt.Errorf("uuid must be set for identifier type uuid")
+	}
+	if _, err := uuid.Parse(*id); err != nil {
+		return fmt.Errorf("invalid uuid %s: %v", *id, err)
+	}
+	return nil
+}
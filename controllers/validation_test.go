@@ -0,0 +1,689 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+const (
+	testImageUUID   = "00000000-0000-0000-0000-000000000040"
+	testProjectUUID = "00000000-0000-0000-0000-000000000041"
+	testUserUUID    = "00000000-0000-0000-0000-000000000042"
+)
+
+// referenceTestService additionally returns a single image named image, two images named shared-image, a single
+// project named project and a single user named admin@example.com
+type referenceTestService struct {
+	fakeLookupService
+}
+
+func (s *referenceTestService) ListImage(_ context.Context, getEntitiesRequest *nutanixClientV3.DSMetadata) (*nutanixClientV3.ImageListIntentResponse, error) {
+	filter := utils.StringValue(getEntitiesRequest.Filter)
+	entities := make([]*nutanixClientV3.ImageIntentResponse, 0)
+	if filter == getFilterForName("image") {
+		entities = append(entities, &nutanixClientV3.ImageIntentResponse{
+			Metadata: &nutanixClientV3.Metadata{UUID: utils.StringPtr(testImageUUID)},
+			Spec:     &nutanixClientV3.Image{Name: utils.StringPtr("image")},
+		})
+	}
+	if filter == getFilterForName("shared-image") {
+		for _, uuid := range []string{"shared-image-1", "shared-image-2"} {
+			entities = append(entities, &nutanixClientV3.ImageIntentResponse{
+				Metadata: &nutanixClientV3.Metadata{UUID: utils.StringPtr(uuid)},
+				Spec:     &nutanixClientV3.Image{Name: utils.StringPtr("shared-image")},
+			})
+		}
+	}
+	return &nutanixClientV3.ImageListIntentResponse{Entities: entities}, nil
+}
+
+func (s *referenceTestService) ListProject(_ context.Context, getEntitiesRequest *nutanixClientV3.DSMetadata) (*nutanixClientV3.ProjectListResponse, error) {
+	entities := make([]*nutanixClientV3.Project, 0)
+	if utils.StringValue(getEntitiesRequest.Filter) == getFilterForName("project") {
+		entities = append(entities, &nutanixClientV3.Project{
+			Metadata: &nutanixClientV3.Metadata{UUID: utils.StringPtr(testProjectUUID)},
+			Spec:     &nutanixClientV3.ProjectSpec{Name: "project"},
+		})
+	}
+	return &nutanixClientV3.ProjectListResponse{Entities: entities}, nil
+}
+
+func (s *referenceTestService) ListUser(_ context.Context, _ *nutanixClientV3.DSMetadata) (*nutanixClientV3.UserListResponse, error) {
+	return &nutanixClientV3.UserListResponse{Entities: []*nutanixClientV3.UserIntentResponse{
+		{
+			Metadata: &nutanixClientV3.Metadata{UUID: utils.StringPtr(testUserUUID)},
+			Spec: &nutanixClientV3.UserSpec{Resources: &nutanixClientV3.UserResources{
+				DirectoryServiceUser: &nutanixClientV3.DirectoryServiceUser{UserPrincipalName: utils.StringPtr("admin@example.com")},
+			}},
+		},
+		{
+			Metadata: &nutanixClientV3.Metadata{UUID: utils.StringPtr("00000000-0000-0000-0000-000000000043")},
+			Status:   &nutanixClientV3.UserStatus{Name: utils.StringPtr("operator")},
+		},
+	}}, nil
+}
+
+func (s *referenceTestService) GetUser(_ context.Context, uuid string) (*nutanixClientV3.UserIntentResponse, error) {
+	if uuid != testUserUUID {
+		return nil, fmt.Errorf("ENTITY_NOT_FOUND: user %s not found", uuid)
+	}
+	return &nutanixClientV3.UserIntentResponse{Metadata: &nutanixClientV3.Metadata{UUID: utils.StringPtr(testUserUUID)}}, nil
+}
+
+func TestValidateNutanixClusterSpec(t *testing.T) {
+	g := NewWithT(t)
+
+	nutanixCluster := &infrav1.NutanixCluster{
+		Spec: infrav1.NutanixClusterSpec{
+			ClientCertificate:  &infrav1.NutanixClientCertificate{},
+			InsecureSkipVerify: utils.BoolPtr(true),
+		},
+	}
+	allErrs := ValidateNutanixClusterSpec(nutanixCluster)
+	g.Expect(allErrs).To(HaveLen(3))
+	g.Expect(allErrs[0].Field).To(Equal("spec.clientCertificate.certificateRef"))
+	g.Expect(allErrs[1].Field).To(Equal("spec.clientCertificate.keyRef"))
+	g.Expect(allErrs[2].Field).To(Equal("spec.insecureSkipVerify"))
+
+	g.Expect(ValidateNutanixClusterSpec(&infrav1.NutanixCluster{})).To(BeEmpty())
+}
+
+func TestValidateEndpoints(t *testing.T) {
+	tests := []struct {
+		name                string
+		prismCentralAddress string
+		prismCentralPort    int32
+		controlPlaneHost    string
+		expectInvalidFields []string
+	}{
+		{name: "host names", prismCentralAddress: "prism-central.example.com", controlPlaneHost: "cp.example.com"},
+		{name: "IPv4 addresses", prismCentralAddress: "10.0.0.1", controlPlaneHost: "10.0.0.2"},
+		{name: "IPv6 addresses", prismCentralAddress: "fd00::1", controlPlaneHost: "fd00::2"},
+		{name: "bracketed IPv6 Prism Central address", prismCentralAddress: "[fd00::1]", controlPlaneHost: "fd00::2"},
+		{
+			name:                "bracketed IPv6 control plane host",
+			prismCentralAddress: "fd00::1",
+			controlPlaneHost:    "[fd00::2]",
+			expectInvalidFields: []string{"spec.controlPlaneEndpoint.host"},
+		},
+		{
+			name:                "malformed IPv6 addresses",
+			prismCentralAddress: "[fd00::1",
+			controlPlaneHost:    "fd00:::2",
+			expectInvalidFields: []string{"spec.prismCentral.address", "spec.controlPlaneEndpoint.host"},
+		},
+		{
+			name:                "address with port",
+			prismCentralAddress: "prism-central.example.com:9440",
+			expectInvalidFields: []string{"spec.prismCentral.address"},
+		},
+		{name: "Prism Central URL", prismCentralAddress: "https://prism-central.example.com:9440"},
+		{name: "explicit Prism Central port", prismCentralAddress: "prism-central.example.com", prismCentralPort: 9440},
+		{
+			name:                "http Prism Central URL",
+			prismCentralAddress: "http://prism-central.example.com",
+			expectInvalidFields: []string{"spec.prismCentral.address"},
+		},
+		{
+			name:                "invalid Prism Central port",
+			prismCentralAddress: "prism-central.example.com",
+			prismCentralPort:    65536,
+			expectInvalidFields: []string{"spec.prismCentral.port"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			nutanixCluster := &infrav1.NutanixCluster{
+				Spec: infrav1.NutanixClusterSpec{
+					PrismCentral:         &credentialTypes.NutanixPrismEndpoint{Address: tt.prismCentralAddress, Port: tt.prismCentralPort},
+					ControlPlaneEndpoint: capiv1.APIEndpoint{Host: tt.controlPlaneHost, Port: 6443},
+				},
+			}
+			fields := make([]string, 0)
+			for _, err := range ValidateNutanixClusterSpec(nutanixCluster) {
+				g.Expect(err.Type).To(Equal(field.ErrorTypeInvalid))
+				fields = append(fields, err.Field)
+			}
+			if len(tt.expectInvalidFields) == 0 {
+				g.Expect(fields).To(BeEmpty())
+				return
+			}
+			g.Expect(fields).To(Equal(tt.expectInvalidFields))
+		})
+	}
+}
+
+func TestValidateFailureDomainReferences(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	nutanixCluster := &infrav1.NutanixCluster{
+		Spec: infrav1.NutanixClusterSpec{
+			FailureDomains: []infrav1.NutanixFailureDomainConfig{
+				{
+					Name:    "fd-1",
+					Cluster: infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("pe")},
+					Subnets: []infrav1.NutanixResourceIdentifier{{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("missing")}},
+				},
+			},
+		},
+	}
+
+	clientCreated := 0
+	newClient := func() (*nutanixClientV3.Client, error) {
+		clientCreated++
+		return &nutanixClientV3.Client{V3: &fakeLookupService{}}, nil
+	}
+	allErrs, err := ValidateFailureDomainReferences(ctx, newClient, nutanixCluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Type).To(Equal(field.ErrorTypeNotFound))
+	g.Expect(allErrs[0].Field).To(Equal("spec.failureDomains[0].subnets[0].name"))
+	g.Expect(clientCreated).To(Equal(1))
+
+	_, err = ValidateFailureDomainReferences(ctx, func() (*nutanixClientV3.Client, error) {
+		return nil, fmt.Errorf("prism central not reachable")
+	}, nutanixCluster)
+	g.Expect(err).To(HaveOccurred())
+
+	// Without a client only the format of the identifiers is verified
+	nutanixCluster.Spec.FailureDomains[0].Subnets = append(nutanixCluster.Spec.FailureDomains[0].Subnets, infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr("not-a-uuid")})
+	allErrs, err = ValidateFailureDomainReferences(ctx, nil, nutanixCluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.failureDomains[0].subnets[1].uuid"))
+
+	// Subnets selected by category must match exactly one subnet of the cluster
+	nutanixCluster.Spec.FailureDomains[0].Subnets = []infrav1.NutanixResourceIdentifier{
+		{Type: infrav1.NutanixIdentifierCategory, Category: &infrav1.NutanixCategoryIdentifier{Key: "network", Value: "prod"}},
+		{Type: infrav1.NutanixIdentifierCategory, Category: &infrav1.NutanixCategoryIdentifier{Key: "network", Value: "dev"}},
+	}
+	allErrs, err = ValidateFailureDomainReferences(ctx, newClient, nutanixCluster)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.failureDomains[0].subnets[1].category"))
+}
+
+func TestValidateNutanixMachineSpec(t *testing.T) {
+	g := NewWithT(t)
+
+	spec := &infrav1.NutanixMachineSpec{
+		VolumeGroups: []infrav1.NutanixResourceIdentifier{
+			{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("vg")},
+			{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("vg")},
+		},
+		SystemDiskStorageContainer: &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr("not-a-uuid")},
+		NameServers:                []string{"10.0.0.1", "fd00::1", "10.0.0"},
+		SearchDomains:              []string{"corp.example.com", "Example_Domain"},
+	}
+	allErrs := ValidateNutanixMachineSpec(field.NewPath("spec", "template", "spec"), spec)
+	g.Expect(allErrs).To(HaveLen(4))
+	g.Expect(allErrs[0].Field).To(Equal("spec.template.spec.volumeGroups[1]"))
+	g.Expect(allErrs[1].Field).To(Equal("spec.template.spec.systemDiskStorageContainer.uuid"))
+	g.Expect(allErrs[2].Field).To(Equal("spec.template.spec.nameServers[2]"))
+	g.Expect(allErrs[3].Field).To(Equal("spec.template.spec.searchDomains[1]"))
+
+	// Storage containers can only be identified by uuid
+	spec = &infrav1.NutanixMachineSpec{
+		SystemDiskStorageContainer: &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("ssd")},
+	}
+	allErrs = ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Type).To(Equal(field.ErrorTypeNotSupported))
+	g.Expect(allErrs[0].Field).To(Equal("spec.systemDiskStorageContainer.type"))
+}
+
+func TestValidateNICs(t *testing.T) {
+	g := NewWithT(t)
+	subnet := infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("subnet")}
+	poolRef := &corev1.TypedLocalObjectReference{Kind: "InClusterIPPool", Name: "pool"}
+
+	spec := &infrav1.NutanixMachineSpec{
+		NICs: []infrav1.NutanixMachineNIC{
+			{Subnet: subnet, IPAddress: utils.StringPtr("10.0.0.10")},
+			{Subnet: subnet, IPAddressPoolRef: poolRef},
+			{Subnet: subnet},
+		},
+	}
+	g.Expect(ValidateNutanixMachineSpec(field.NewPath("spec"), spec)).To(BeEmpty())
+
+	spec = &infrav1.NutanixMachineSpec{
+		Subnets: []infrav1.NutanixResourceIdentifier{subnet},
+		NICs: []infrav1.NutanixMachineNIC{
+			{Subnet: subnet, IPAddress: utils.StringPtr("fd00::10")},
+			{Subnet: subnet, IPAddress: utils.StringPtr("10.0.0.10"), IPAddressPoolRef: poolRef},
+			{Subnet: infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID}, IPAddressPoolRef: &corev1.TypedLocalObjectReference{}},
+		},
+	}
+	allErrs := ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+	fields := make([]string, 0, len(allErrs))
+	for _, err := range allErrs {
+		fields = append(fields, err.Field)
+	}
+	g.Expect(fields).To(Equal([]string{
+		"spec.nics",
+		"spec.nics[0].ipAddress",
+		"spec.nics[1].ipAddressPoolRef",
+		"spec.nics[2].subnet.uuid",
+		"spec.nics[2].ipAddressPoolRef.kind",
+		"spec.nics[2].ipAddressPoolRef.name",
+	}))
+}
+
+func TestValidateSecureBoot(t *testing.T) {
+	g := NewWithT(t)
+	spec := &infrav1.NutanixMachineSpec{BootType: infrav1.NutanixBootTypeUEFI, SecureBoot: utils.BoolPtr(true)}
+	g.Expect(ValidateNutanixMachineSpec(field.NewPath("spec"), spec)).To(BeEmpty())
+
+	for _, bootType := range []infrav1.NutanixBootType{"", infrav1.NutanixBootTypeLegacy} {
+		spec = &infrav1.NutanixMachineSpec{BootType: bootType, SecureBoot: utils.BoolPtr(true)}
+		allErrs := ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+		g.Expect(allErrs).To(HaveLen(1))
+		g.Expect(allErrs[0].Field).To(Equal("spec.secureBoot"))
+
+		spec.SecureBoot = utils.BoolPtr(false)
+		g.Expect(ValidateNutanixMachineSpec(field.NewPath("spec"), spec)).To(BeEmpty())
+	}
+}
+
+func TestValidateSystemDiskBus(t *testing.T) {
+	g := NewWithT(t)
+	for _, bus := range []infrav1.NutanixDiskBus{"", infrav1.NutanixDiskBusSCSI, infrav1.NutanixDiskBusPCI, infrav1.NutanixDiskBusSATA} {
+		spec := &infrav1.NutanixMachineSpec{SystemDiskBus: bus}
+		g.Expect(ValidateNutanixMachineSpec(field.NewPath("spec"), spec)).To(BeEmpty())
+	}
+
+	for _, bus := range []infrav1.NutanixDiskBus{"IDE", "scsi"} {
+		spec := &infrav1.NutanixMachineSpec{SystemDiskBus: bus}
+		allErrs := ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+		g.Expect(allErrs).To(HaveLen(1))
+		g.Expect(allErrs[0].Field).To(Equal("spec.systemDiskBus"))
+		g.Expect(allErrs[0].Type).To(Equal(field.ErrorTypeNotSupported))
+	}
+}
+
+func TestValidateSerialPorts(t *testing.T) {
+	g := NewWithT(t)
+	spec := &infrav1.NutanixMachineSpec{SerialPorts: []infrav1.NutanixSerialPort{{Index: 0, IsConnected: true}, {Index: 1}}}
+	g.Expect(ValidateNutanixMachineSpec(field.NewPath("spec"), spec)).To(BeEmpty())
+
+	spec.SerialPorts = append(spec.SerialPorts, infrav1.NutanixSerialPort{Index: 0})
+	allErrs := ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.serialPorts[2].index"))
+	g.Expect(allErrs[0].Type).To(Equal(field.ErrorTypeDuplicate))
+}
+
+func TestValidateMachineResources(t *testing.T) {
+	tests := []struct {
+		name           string
+		bootType       infrav1.NutanixBootType
+		secureBoot     bool
+		memorySize     string
+		vcpusPerSocket int32
+		vcpuSockets    int32
+		expectFields   []string
+	}{
+		{
+			name:           "legacy minimums",
+			memorySize:     "2Gi",
+			vcpusPerSocket: 1,
+			vcpuSockets:    1,
+		},
+		{
+			name:           "legacy below minimum memory",
+			memorySize:     "1Gi",
+			vcpusPerSocket: 1,
+			vcpuSockets:    1,
+			expectFields:   []string{"spec.memorySize"},
+		},
+		{
+			name:           "uefi minimums",
+			bootType:       infrav1.NutanixBootTypeUEFI,
+			memorySize:     "2Gi",
+			vcpusPerSocket: 1,
+			vcpuSockets:    1,
+		},
+		{
+			name:           "secure boot minimums across sockets",
+			bootType:       infrav1.NutanixBootTypeUEFI,
+			secureBoot:     true,
+			memorySize:     "4Gi",
+			vcpusPerSocket: 1,
+			vcpuSockets:    2,
+		},
+		{
+			name:           "secure boot below minimums",
+			bootType:       infrav1.NutanixBootTypeUEFI,
+			secureBoot:     true,
+			memorySize:     "2Gi",
+			vcpusPerSocket: 1,
+			vcpuSockets:    1,
+			expectFields:   []string{"spec.memorySize", "spec.vcpusPerSocket"},
+		},
+		{
+			name:     "unset values are skipped",
+			bootType: infrav1.NutanixBootTypeUEFI,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			spec := &infrav1.NutanixMachineSpec{
+				BootType:       tt.bootType,
+				SecureBoot:     pointer.Bool(tt.secureBoot),
+				VCPUsPerSocket: tt.vcpusPerSocket,
+				VCPUSockets:    tt.vcpuSockets,
+			}
+			if tt.memorySize != "" {
+				spec.MemorySize = resource.MustParse(tt.memorySize)
+			}
+			allErrs := ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+			fields := make([]string, 0, len(allErrs))
+			for _, err := range allErrs {
+				fields = append(fields, err.Field)
+			}
+			g.Expect(fields).To(ConsistOf(tt.expectFields))
+		})
+	}
+}
+
+func TestValidateBootstrapISO(t *testing.T) {
+	g := NewWithT(t)
+	spec := &infrav1.NutanixMachineSpec{
+		BootstrapISO:      &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("unattend")},
+		EjectBootstrapISO: true,
+	}
+	g.Expect(ValidateNutanixMachineSpec(field.NewPath("spec"), spec)).To(BeEmpty())
+
+	spec.BootstrapFormat = infrav1.NutanixBootstrapFormatIgnition
+	allErrs := ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.bootstrapISO"))
+	g.Expect(allErrs[0].Type).To(Equal(field.ErrorTypeForbidden))
+
+	spec.BootstrapFormat = ""
+	spec.BootstrapISO = &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr("unattend")}
+	allErrs = ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.bootstrapISO.uuid"))
+
+	spec.BootstrapISO = nil
+	allErrs = ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.ejectBootstrapISO"))
+}
+
+func TestValidateExistingVMUUID(t *testing.T) {
+	g := NewWithT(t)
+	spec := &infrav1.NutanixMachineSpec{ExistingVMUUID: "00000000-0000-0000-0000-000000000001"}
+	g.Expect(ValidateNutanixMachineSpec(field.NewPath("spec"), spec)).To(BeEmpty())
+
+	spec.ExistingVMUUID = "legacy-vm"
+	allErrs := ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.existingVMUUID"))
+}
+
+func TestValidateNutanixMachineReferences(t *testing.T) {
+	nameIdentifier := func(name string) infrav1.NutanixResourceIdentifier {
+		return infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr(name)}
+	}
+	uuidIdentifier := func(uuid string) infrav1.NutanixResourceIdentifier {
+		return infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierUUID, UUID: utils.StringPtr(uuid)}
+	}
+	categoryIdentifier := func(key, value string) infrav1.NutanixResourceIdentifier {
+		return infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierCategory, Category: &infrav1.NutanixCategoryIdentifier{Key: key, Value: value}}
+	}
+
+	tests := []struct {
+		name         string
+		spec         infrav1.NutanixMachineSpec
+		noClient     bool
+		expectFields []string
+	}{
+		{
+			name: "existing names",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   nameIdentifier("image"),
+				Project: &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("project")},
+				Owner:   &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("admin@example.com")},
+				Cluster: nameIdentifier("pe"),
+				Subnets: []infrav1.NutanixResourceIdentifier{nameIdentifier("subnet")},
+			},
+		},
+		{
+			name: "valid uuids",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   uuidIdentifier(testImageUUID),
+				Cluster: uuidIdentifier(testPEUUID),
+				Subnets: []infrav1.NutanixResourceIdentifier{uuidIdentifier(testSubnetUUID)},
+			},
+		},
+		{
+			name: "unknown image, project and owner",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   nameIdentifier("missing"),
+				Project: &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("missing")},
+				Owner:   &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("missing")},
+			},
+			expectFields: []string{"spec.image.name", "spec.project.name", "spec.owner.name"},
+		},
+		{
+			name: "unknown bootstrap ISO",
+			spec: infrav1.NutanixMachineSpec{
+				Image:        nameIdentifier("image"),
+				BootstrapISO: &infrav1.NutanixResourceIdentifier{Type: infrav1.NutanixIdentifierName, Name: utils.StringPtr("missing")},
+			},
+			expectFields: []string{"spec.bootstrapISO.name"},
+		},
+		{
+			name: "unknown subnet",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   nameIdentifier("image"),
+				Cluster: nameIdentifier("pe"),
+				Subnets: []infrav1.NutanixResourceIdentifier{nameIdentifier("subnet"), nameIdentifier("missing")},
+			},
+			expectFields: []string{"spec.subnet[1].name"},
+		},
+		{
+			name: "subnet by category",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   nameIdentifier("image"),
+				Cluster: nameIdentifier("pe"),
+				Subnets: []infrav1.NutanixResourceIdentifier{categoryIdentifier("network", "prod")},
+			},
+		},
+		{
+			name: "no subnet with category",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   nameIdentifier("image"),
+				Cluster: nameIdentifier("pe"),
+				NICs:    []infrav1.NutanixMachineNIC{{Subnet: categoryIdentifier("network", "dev")}},
+			},
+			expectFields: []string{"spec.nics[0].subnet.category"},
+		},
+		{
+			name: "subnet category without value",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   nameIdentifier("image"),
+				Cluster: nameIdentifier("pe"),
+				Subnets: []infrav1.NutanixResourceIdentifier{categoryIdentifier("network", "")},
+			},
+			expectFields: []string{"spec.subnet[0].category"},
+		},
+		{
+			name: "category identifies only subnets",
+			spec: infrav1.NutanixMachineSpec{
+				Image: categoryIdentifier("os", "ubuntu"),
+			},
+			expectFields: []string{"spec.image.type"},
+		},
+		{
+			name: "unknown cluster skips subnets",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   nameIdentifier("image"),
+				Cluster: nameIdentifier("missing"),
+				Subnets: []infrav1.NutanixResourceIdentifier{nameIdentifier("missing")},
+			},
+			expectFields: []string{"spec.cluster.name"},
+		},
+		{
+			name: "image name on several clusters",
+			spec: infrav1.NutanixMachineSpec{
+				Image: nameIdentifier("shared-image"),
+			},
+			expectFields: []string{"spec.image.name"},
+		},
+		{
+			name: "cluster scoped image name on several clusters",
+			spec: infrav1.NutanixMachineSpec{
+				Image:              nameIdentifier("shared-image"),
+				ImageClusterScoped: true,
+			},
+		},
+		{
+			name: "unknown cluster scoped image",
+			spec: infrav1.NutanixMachineSpec{
+				Image:              nameIdentifier("missing"),
+				ImageClusterScoped: true,
+			},
+			expectFields: []string{"spec.image.name"},
+		},
+		{
+			name: "invalid image uuid",
+			spec: infrav1.NutanixMachineSpec{
+				Image: uuidIdentifier("not-a-uuid"),
+			},
+			expectFields: []string{"spec.image.uuid"},
+		},
+		{
+			name: "names are not looked up without client",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   nameIdentifier("missing"),
+				Cluster: nameIdentifier("missing"),
+				Subnets: []infrav1.NutanixResourceIdentifier{nameIdentifier("missing"), uuidIdentifier("not-a-uuid")},
+			},
+			noClient:     true,
+			expectFields: []string{"spec.subnet[1].uuid"},
+		},
+		{
+			name: "subnets of failure domain are not verified",
+			spec: infrav1.NutanixMachineSpec{
+				Image:   nameIdentifier("image"),
+				Subnets: []infrav1.NutanixResourceIdentifier{nameIdentifier("missing")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			var client *nutanixClientV3.Client
+			if !tt.noClient {
+				client = &nutanixClientV3.Client{V3: &referenceTestService{}}
+			}
+			allErrs := ValidateNutanixMachineReferences(context.Background(), client, field.NewPath("spec"), &tt.spec)
+			fields := make([]string, 0, len(allErrs))
+			for _, err := range allErrs {
+				fields = append(fields, err.Field)
+			}
+			if len(tt.expectFields) == 0 {
+				g.Expect(fields).To(BeEmpty())
+				return
+			}
+			g.Expect(fields).To(Equal(tt.expectFields))
+		})
+	}
+}
+
+func TestValidateExtraVMConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		extraVMConfig string
+		expectErr     bool
+	}{
+		{
+			name:          "spec and metadata",
+			extraVMConfig: `{"spec":{"resources":{"vga_console_enabled":false}},"metadata":{"categories":{"Environment":"test"}}}`,
+		},
+		{
+			name:          "VM name",
+			extraVMConfig: `{"spec":{"name":"other"}}`,
+			expectErr:     true,
+		},
+		{
+			name:          "cluster name category",
+			extraVMConfig: `{"metadata":{"categories":{"KubernetesClusterName":"other"}}}`,
+			expectErr:     true,
+		},
+		{
+			name:          "obsolete cluster category",
+			extraVMConfig: `{"metadata":{"categories":{"kubernetes-io-cluster-other":"owned"}}}`,
+			expectErr:     true,
+		},
+		{
+			name:          "unknown field",
+			extraVMConfig: `{"spec":{"resources":{"vga_console":false}}}`,
+			expectErr:     true,
+		},
+		{
+			name:          "unknown top-level field",
+			extraVMConfig: `{"api_version":"3.1"}`,
+			expectErr:     true,
+		},
+		{
+			name:          "spec is not an object",
+			extraVMConfig: `{"spec":null}`,
+			expectErr:     true,
+		},
+		{
+			name:          "not an object",
+			extraVMConfig: `["spec"]`,
+			expectErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			spec := &infrav1.NutanixMachineSpec{ExtraVMConfig: &runtime.RawExtension{Raw: []byte(tt.extraVMConfig)}}
+			allErrs := ValidateNutanixMachineSpec(field.NewPath("spec"), spec)
+			if !tt.expectErr {
+				g.Expect(allErrs).To(BeEmpty())
+				return
+			}
+			g.Expect(allErrs).To(HaveLen(1))
+			g.Expect(allErrs[0].Field).To(Equal("spec.extraVMConfig"))
+		})
+	}
+}
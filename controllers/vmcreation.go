@@ -0,0 +1,93 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// vmCreationGuard serializes the creation of the VM of a NutanixMachine and remembers the VMs whose creation was
+// submitted. A reconciliation that starts before the UUID of a created VM is visible in the cached NutanixMachine,
+// and before Prism Central lists the VM by name, finds the VM through the guard instead of creating a second one.
+// The zero value is ready to use.
+type vmCreationGuard struct {
+	mu sync.Mutex
+	// locks holds the lock of each NutanixMachine whose VM creation is in progress
+	locks map[types.UID]*vmCreationLock
+	// submitted holds the UUIDs of the VMs whose creation was submitted, by NutanixMachine
+	submitted map[types.UID]string
+}
+
+// vmCreationLock is the lock of a NutanixMachine, shared by its concurrent reconciliations
+type vmCreationLock struct {
+	sync.Mutex
+	// holders is the number of reconciliations holding or waiting for the lock
+	holders int
+}
+
+// lock blocks until no other reconciliation creates the VM of the NutanixMachine, and returns the function releasing
+// the lock
+func (g *vmCreationGuard) lock(uid types.UID) func() {
+	g.mu.Lock()
+	if g.locks == nil {
+		g.locks = make(map[types.UID]*vmCreationLock)
+	}
+	l, ok := g.locks[uid]
+	if !ok {
+		l = &vmCreationLock{}
+		g.locks[uid] = l
+	}
+	l.holders++
+	g.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		l.holders--
+		if l.holders == 0 {
+			delete(g.locks, uid)
+		}
+	}
+}
+
+// submittedVM returns the UUID of the VM whose creation was submitted for the NutanixMachine, or an empty string
+func (g *vmCreationGuard) submittedVM(uid types.UID) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.submitted[uid]
+}
+
+// recordSubmission remembers the UUID of the VM whose creation was submitted for the NutanixMachine
+func (g *vmCreationGuard) recordSubmission(uid types.UID, vmUUID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.submitted == nil {
+		g.submitted = make(map[types.UID]string)
+	}
+	g.submitted[uid] = vmUUID
+}
+
+// forget drops the VM recorded for the NutanixMachine once the NutanixMachine is deleted
+func (g *vmCreationGuard) forget(uid types.UID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.submitted, uid)
+}
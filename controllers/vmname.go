@@ -0,0 +1,91 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+// maxVMNameLength is the maximum length of the name of a VM in Prism Central
+const maxVMNameLength = 64
+
+// vmNameTemplateData holds the objects the VM name template of a NutanixMachine has access to
+type vmNameTemplateData struct {
+	Cluster        *capiv1.Cluster
+	Machine        *capiv1.Machine
+	NutanixMachine *infrav1.NutanixMachine
+}
+
+// parseVMNameTemplate parses the VM name template. Missing map keys are an error to not silently render incomplete names.
+func parseVMNameTemplate(nameTemplate string) (*template.Template, error) {
+	return template.New("vmName").Option("missingkey=error").Parse(nameTemplate)
+}
+
+// renderVMName renders the VM name template with the given objects. The rendered name is not truncated to keep it
+// unique, so an error is returned if it is empty or exceeds the maximum VM name length.
+func renderVMName(nameTemplate string, data vmNameTemplateData) (string, error) {
+	tmpl, err := parseVMNameTemplate(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid VM name template: %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("failed to render VM name template: %v", err)
+	}
+	name := strings.TrimSpace(buf.String())
+	if name == "" {
+		return "", fmt.Errorf("VM name template rendered an empty name")
+	}
+	if len(name) > maxVMNameLength {
+		return "", fmt.Errorf("rendered VM name %s exceeds the maximum length of %d characters", name, maxVMNameLength)
+	}
+	return name, nil
+}
+
+// validateVMNameTemplate verifies that the VM name template of the NutanixMachine spec at the given path can be parsed
+func validateVMNameTemplate(specPath *field.Path, spec *infrav1.NutanixMachineSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if spec.VMNameTemplate == "" {
+		return allErrs
+	}
+	if _, err := parseVMNameTemplate(spec.VMNameTemplate); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("vmNameTemplate"), spec.VMNameTemplate, err.Error()))
+	}
+	return allErrs
+}
+
+// getVMName returns the name of the VM of the NutanixMachine in Prism Central, which is the rendered VM name template
+// if set, and the name of the Machine otherwise
+func getVMName(rctx *nctx.MachineContext) (string, error) {
+	if rctx.NutanixMachine.Spec.VMNameTemplate == "" {
+		return rctx.Machine.Name, nil
+	}
+	return renderVMName(rctx.NutanixMachine.Spec.VMNameTemplate, vmNameTemplateData{
+		Cluster:        rctx.Cluster,
+		Machine:        rctx.Machine,
+		NutanixMachine: rctx.NutanixMachine,
+	})
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+	nctx "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/context"
+)
+
+func newVMNameTestMachineContext(nameTemplate string) *nctx.MachineContext {
+	return &nctx.MachineContext{
+		Context: context.Background(),
+		Cluster: &capiv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "prod"}},
+		Machine: &capiv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cluster-md-0-abcde",
+				Namespace: "prod",
+				Labels:    map[string]string{capiv1.MachineDeploymentLabelName: "cluster-md-0"},
+			},
+		},
+		NutanixMachine: &infrav1.NutanixMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-md-0-xyz", Namespace: "prod"},
+			Spec:       infrav1.NutanixMachineSpec{VMNameTemplate: nameTemplate},
+		},
+	}
+}
+
+func TestGetVMName(t *testing.T) {
+	tests := []struct {
+		name          string
+		nameTemplate  string
+		expected      string
+		expectedError string
+	}{
+		{
+			name:     "uses the Machine name without template",
+			expected: "cluster-md-0-abcde",
+		},
+		{
+			name:         "renders cluster and machine fields",
+			nameTemplate: "{{ .Cluster.Namespace }}-worker-{{ .Machine.Name }}",
+			expected:     "prod-worker-cluster-md-0-abcde",
+		},
+		{
+			name:         "renders labels",
+			nameTemplate: `{{ index .Machine.Labels "cluster.x-k8s.io/deployment-name" }}-{{ .NutanixMachine.Name }}`,
+			expected:     "cluster-md-0-cluster-md-0-xyz",
+		},
+		{
+			name:         "trims whitespace",
+			nameTemplate: " {{ .Machine.Name }}\n",
+			expected:     "cluster-md-0-abcde",
+		},
+		{
+			name:         "renders name of maximum length",
+			nameTemplate: strings.Repeat("a", maxVMNameLength-len("cluster-md-0-abcde")) + "{{ .Machine.Name }}",
+			expected:     strings.Repeat("a", maxVMNameLength-len("cluster-md-0-abcde")) + "cluster-md-0-abcde",
+		},
+		{
+			name:          "rejects too long name instead of truncating it",
+			nameTemplate:  strings.Repeat("a", maxVMNameLength) + "-{{ .Machine.Name }}",
+			expectedError: "exceeds the maximum length of 64 characters",
+		},
+		{
+			name:          "rejects empty name",
+			nameTemplate:  `{{ if false }}{{ .Machine.Name }}{{ end }}`,
+			expectedError: "empty name",
+		},
+		{
+			name:          "rejects missing label",
+			nameTemplate:  "{{ .Machine.Labels.role }}-{{ .Machine.Name }}",
+			expectedError: "failed to render",
+		},
+		{
+			name:          "rejects unknown field",
+			nameTemplate:  "{{ .Machine.Role }}",
+			expectedError: "failed to render",
+		},
+		{
+			name:          "rejects invalid template",
+			nameTemplate:  "{{ .Machine.Name ",
+			expectedError: "invalid VM name template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			vmName, err := getVMName(newVMNameTestMachineContext(tt.nameTemplate))
+			if tt.expectedError != "" {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.expectedError)))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(vmName).To(Equal(tt.expected))
+		})
+	}
+}
+
+func TestValidateVMNameTemplate(t *testing.T) {
+	g := NewWithT(t)
+	spec := &infrav1.NutanixMachineSpec{VMNameTemplate: "{{ .Machine.Name }}"}
+	g.Expect(validateVMNameTemplate(field.NewPath("spec"), spec)).To(BeEmpty())
+
+	spec.VMNameTemplate = "{{ .Machine.Name "
+	allErrs := validateVMNameTemplate(field.NewPath("spec"), spec)
+	g.Expect(allErrs).To(HaveLen(1))
+	g.Expect(allErrs[0].Field).To(Equal("spec.vmNameTemplate"))
+}
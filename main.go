@@ -17,8 +17,10 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"flag"
 	"os"
+	"strings"
 	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.
@@ -40,6 +42,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -47,6 +50,7 @@ import (
 	infrav1alpha4 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1alpha4"
 	infrav1beta1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
 	"github.com/nutanix-cloud-native/cluster-api-provider-nutanix/controllers"
+	nutanixClient "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/pkg/client"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -65,6 +69,8 @@ func init() {
 
 	utilruntime.Must(bootstrapv1.AddToScheme(scheme))
 
+	utilruntime.Must(ipamv1.AddToScheme(scheme))
+
 	utilruntime.Must(infrav1alpha4.AddToScheme(scheme))
 	utilruntime.Must(infrav1beta1.AddToScheme(scheme))
 
@@ -74,26 +80,254 @@ func init() {
 const (
 	// DefaultMaxConcurrentReconciles is the default maximum number of concurrent reconciles
 	defaultMaxConcurrentReconciles = 10
+
+	// defaultImageReadyTimeout is the default time to wait for the image of a VM to become ready
+	defaultImageReadyTimeout = 10 * time.Minute
+
+	// defaultReconcileTimeout is the default deadline of a single reconcile
+	defaultReconcileTimeout = 15 * time.Minute
+
+	// defaultBootstrapDataCompressionThreshold is the default size in bytes of bootstrap data above which it is compressed
+	defaultBootstrapDataCompressionThreshold = 16 * 1024
+
+	// defaultPrismCentralHealthCheckInterval is the default interval of the Prism Central health checks
+	defaultPrismCentralHealthCheckInterval = time.Minute
+
+	// defaultRequeueJitterFactor is the default maximum fraction of a requeue interval that is added as jitter
+	defaultRequeueJitterFactor = 0.1
+
+	// defaultPrismClientMaxIdleConns is the default maximum number of idle connections kept open per Prism endpoint
+	defaultPrismClientMaxIdleConns = 100
+
+	// defaultPrismClientIdleConnTimeout is the default time after which idle connections to Prism endpoints are closed
+	defaultPrismClientIdleConnTimeout = 90 * time.Second
+
+	// defaultTaskInvalidUUIDGracePeriod is the default time within which Prism Central reporting the UUID of a task as
+	// invalid is retried
+	defaultTaskInvalidUUIDGracePeriod = 30 * time.Second
+
+	// defaultSyncPeriod is the default interval after which the objects of the controller caches are resynced and
+	// reconciled again, which is the default of controller-runtime
+	defaultSyncPeriod = 10 * time.Hour
 )
 
 func main() {
 	var (
-		metricsAddr             string
-		enableLeaderElection    bool
-		probeAddr               string
-		maxConcurrentReconciles int
+		metricsAddr               string
+		enableLeaderElection      bool
+		probeAddr                 string
+		maxConcurrentReconciles   int
+		nutanixClusterConcurrency int
+		nutanixMachineConcurrency int
+
+		trustBundleMinRSAKeySize           int
+		trustBundleWeakSignatureAlgorithms string
+		credentialFileRefreshInterval      time.Duration
+		credentialFileDir                  string
+		vmShutdownGracePeriod              time.Duration
+		imageReadyTimeout                  time.Duration
+		reconcileTimeout                   time.Duration
+		bootstrapDataCompressionThreshold  int
+		prismCentralHealthCheckInterval    time.Duration
+		seedDiskPoolSize                   int
+		reconcileDrift                     bool
+		machineRoleCategories              bool
+		extraVMConfig                      bool
+		vmProtectionCategory               string
+		requeueJitterFactor                float64
+		vmTaskTimeout                      time.Duration
+		ipAddressClaimRequeueInterval      time.Duration
+		restrictCredentialNamespaces       bool
+		allowedCredentialNamespaces        string
+		manageCredentialFinalizers         bool
+		vmDescriptionAnnotationPrefix      string
+		prismClientMaxIdleConns            int
+		prismClientMaxConnsPerHost         int
+		prismClientIdleConnTimeout         time.Duration
+		taskInvalidUUIDGracePeriod         time.Duration
+		nodeDrainTimeout                   time.Duration
+		syncPeriod                         time.Duration
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
+			"Enabling this will ensure there is only one active controller manager. Only the active controller manager "+
+			"polls Prism Central tasks and mutates VMs, and it stops doing so as soon as it loses the leadership.")
 	flag.IntVar(
 		&maxConcurrentReconciles,
 		"max-concurrent-reconciles",
 		defaultMaxConcurrentReconciles,
-		"The maximum number of allowed, concurrent reconciles.")
+		"The maximum number of allowed, concurrent reconciles. "+
+			"Used for the controllers whose concurrency is not set with --nutanixcluster-concurrency or --nutanixmachine-concurrency.")
+	flag.IntVar(
+		&nutanixClusterConcurrency,
+		"nutanixcluster-concurrency",
+		0,
+		"The maximum number of concurrent reconciles of NutanixClusters. Must be positive. Defaults to --max-concurrent-reconciles if not set.")
+	flag.IntVar(
+		&nutanixMachineConcurrency,
+		"nutanixmachine-concurrency",
+		0,
+		"The maximum number of concurrent reconciles of NutanixMachines. Must be positive. Defaults to --max-concurrent-reconciles if not set.")
+	flag.IntVar(
+		&trustBundleMinRSAKeySize,
+		"trust-bundle-min-rsa-key-size",
+		0,
+		"The minimum RSA key size of the certificates in the additional trust bundle of a NutanixCluster. Disabled if set to 0.")
+	flag.StringVar(
+		&trustBundleWeakSignatureAlgorithms,
+		"trust-bundle-weak-signature-algorithms",
+		"",
+		"Comma-separated list of signature algorithms (e.g. SHA1-RSA) that are not allowed for the certificates in the additional trust bundle of a NutanixCluster.")
+	flag.DurationVar(
+		&credentialFileRefreshInterval,
+		"credential-file-refresh-interval",
+		nutanixClient.DefaultCredentialFileRefreshInterval,
+		"The interval after which credential files referenced with kind File are read again to pick up rotated credentials.")
+	flag.StringVar(
+		&credentialFileDir,
+		"credential-file-dir",
+		"",
+		"The directory of the controller, e.g. the mount path of a secret, that credential files referenced by NutanixClusters with kind File must be located in. "+
+			"Credential references of kind File are rejected if not set.")
+	flag.DurationVar(
+		&vmShutdownGracePeriod,
+		"vm-shutdown-grace-period",
+		0,
+		"The time given to the guest OS of a VM to shut down before the VM of a deleted NutanixMachine is deleted. "+
+			"VMs are deleted without shutting them down first if set to 0.")
+	flag.DurationVar(
+		&imageReadyTimeout,
+		"image-ready-timeout",
+		defaultImageReadyTimeout,
+		"The time to wait for the image of a NutanixMachine to become ready, e.g. while it is still being uploaded, before creating the VM fails. "+
+			"The state of the image is checked again every 30 seconds while waiting.")
+	flag.DurationVar(
+		&reconcileTimeout,
+		"reconcile-timeout",
+		defaultReconcileTimeout,
+		"The deadline of a single reconcile of a NutanixCluster or NutanixMachine, after which pending Prism Central requests are canceled. "+
+			"Should be greater than the image ready timeout. Reconciles have no deadline if set to 0.")
+	flag.IntVar(
+		&bootstrapDataCompressionThreshold,
+		"bootstrap-data-compression-threshold",
+		defaultBootstrapDataCompressionThreshold,
+		"The size in bytes of cloud-init bootstrap data above which it is gzip compressed before it is passed to the VM of a NutanixMachine. "+
+			"Bootstrap data is never compressed if set to 0.")
+	flag.DurationVar(
+		&prismCentralHealthCheckInterval,
+		"prism-central-health-check-interval",
+		defaultPrismCentralHealthCheckInterval,
+		"The interval in which the Prism Central endpoints of the NutanixClusters are checked to update their PrismCentralHealthy condition. "+
+			"The health checks are disabled if set to 0.")
+	flag.IntVar(
+		&seedDiskPoolSize,
+		"seed-disk-pool-size",
+		0,
+		"The number of pre-cloned seed disks kept per cluster, image and Prism Element cluster, from which the system disks of new VMs are cloned to speed up scale-up. "+
+			"Seed disks are held by powered off VMs, which are deleted with their cluster. VMs are cloned from their image if set to 0, and existing seed VMs are deleted.")
+	flag.BoolVar(
+		&reconcileDrift,
+		"reconcile-drift",
+		false,
+		"Restore the ownership categories of the VMs of NutanixMachines if they were removed or changed in Prism Central. "+
+			"VMs that lost all ownership categories but still match their NutanixMachine by UUID and name are always restored.")
+	flag.BoolVar(
+		&machineRoleCategories,
+		"machine-role-categories",
+		false,
+		"Apply the categories "+infrav1beta1.DefaultCAPICategoryKeyForMachineRole+" (control-plane or worker) and "+infrav1beta1.DefaultCAPICategoryKeyForMachineDeployment+
+			" (name of the owning MachineDeployment) to the VMs created for NutanixMachines. Missing category values are created.")
+	flag.BoolVar(
+		&extraVMConfig,
+		"enable-extra-vm-config",
+		false,
+		"Merge the extraVMConfig of NutanixMachines into the Prism Central VM spec of new VMs. The extraVMConfig of "+
+			"NutanixMachines is ignored if disabled.")
+	flag.StringVar(
+		&vmProtectionCategory,
+		"vm-protection-category",
+		"",
+		"The category in key=value format (e.g. protected=true) that protects VMs from being deleted with their NutanixMachine. "+
+			"Deleted NutanixMachines are kept until the category is removed from their VM. VMs are always deleted if not set.")
+	flag.Float64Var(
+		&requeueJitterFactor,
+		"requeue-jitter-factor",
+		defaultRequeueJitterFactor,
+		"The maximum fraction (between 0 and 1) of the requeue interval of a NutanixCluster or NutanixMachine that is randomly added to it, "+
+			"so objects requeued at the same time, e.g. after a Prism Central outage, are not reconciled at the same instant. Disabled if set to 0.")
+	flag.DurationVar(
+		&vmTaskTimeout,
+		"vm-task-timeout",
+		0,
+		"The maximum duration of the creation task of the VM of a NutanixMachine in Prism Central, after which the NutanixMachine is failed "+
+			"so it can be remediated by a MachineHealthCheck. Creation tasks are waited for without timeout if set to 0.")
+	flag.DurationVar(
+		&ipAddressClaimRequeueInterval,
+		"ip-address-claim-requeue-interval",
+		0,
+		"The maximum interval in which a NutanixMachine waiting for an IPAM provider to fulfill its IPAddressClaims is reconciled again. "+
+			"The interval starts short and grows the longer the claims are waited for. The default maximum of external conditions applies if set to 0.")
+	flag.BoolVar(
+		&restrictCredentialNamespaces,
+		"restrict-credential-namespaces",
+		false,
+		"Reject credential secrets of NutanixClusters in another namespace than the NutanixCluster, unless the namespace is in --allowed-credential-namespaces.")
+	flag.StringVar(
+		&allowedCredentialNamespaces,
+		"allowed-credential-namespaces",
+		"",
+		"Comma-separated list of namespaces whose credential secrets can be referenced by NutanixClusters of any namespace if --restrict-credential-namespaces is set.")
+	flag.BoolVar(
+		&manageCredentialFinalizers,
+		"manage-credential-finalizers",
+		true,
+		"Add a finalizer to the credential secrets of NutanixClusters so they are kept until the NutanixCluster is deleted. "+
+			"Disable if the finalizers of the secrets are managed externally, e.g. by a GitOps tool.")
+	flag.StringVar(
+		&vmDescriptionAnnotationPrefix,
+		"vm-description-annotation-prefix",
+		"",
+		"The prefix of the annotations of Machines whose values are appended to the description of their VMs in Prism Central, "+
+			"e.g. vm-description.example.com/. The description is kept in sync with the annotations and truncated to 1000 characters. "+
+			"Annotations are not copied if not set.")
+	flag.IntVar(
+		&prismClientMaxIdleConns,
+		"prism-client-max-idle-conns",
+		defaultPrismClientMaxIdleConns,
+		"The maximum number of idle connections kept open to each Prism Central endpoint for reuse by later requests.")
+	flag.IntVar(
+		&prismClientMaxConnsPerHost,
+		"prism-client-max-conns-per-host",
+		0,
+		"The maximum number of connections to each Prism Central endpoint, including connections in use. "+
+			"Requests wait for a connection to become available once it is reached. Connections are not limited if set to 0.")
+	flag.DurationVar(
+		&prismClientIdleConnTimeout,
+		"prism-client-idle-conn-timeout",
+		defaultPrismClientIdleConnTimeout,
+		"The time after which idle connections to Prism Central endpoints are closed.")
+	flag.DurationVar(
+		&taskInvalidUUIDGracePeriod,
+		"task-invalid-uuid-grace-period",
+		defaultTaskInvalidUUIDGracePeriod,
+		"The time from the start of waiting for a Prism Central task within which Prism Central reporting the UUID of the task as invalid is retried, "+
+			"e.g. since the task is not known yet while Prism Central restarts. Tasks with an invalid UUID fail right away if set to 0.")
+	flag.DurationVar(
+		&nodeDrainTimeout,
+		"node-drain-timeout",
+		0,
+		"The maximum time the VM of a deleted NutanixMachine is kept until its Node is cordoned and drained in the workload cluster, "+
+			"in addition to the drain of Cluster API. The VM is deleted anyway once the timeout expired. VMs are deleted without checking their Node if set to 0.")
+
+	flag.DurationVar(
+		&syncPeriod,
+		"sync-period",
+		defaultSyncPeriod,
+		"The interval after which all objects of the controller caches are resynced and reconciled again. "+
+			"Shorter intervals detect drift sooner, longer intervals reduce the load of large deployments. Must be positive.")
 
 	opts := zap.Options{
 		TimeEncoder: zapcore.RFC3339TimeEncoder,
@@ -102,16 +336,28 @@ func main() {
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	if !isFlagSet("nutanixcluster-concurrency") {
+		nutanixClusterConcurrency = maxConcurrentReconciles
+	}
+	if !isFlagSet("nutanixmachine-concurrency") {
+		nutanixMachineConcurrency = maxConcurrentReconciles
+	}
+	nutanixClient.CredentialFiles.SetRefreshInterval(credentialFileRefreshInterval)
+	nutanixClient.CredentialFiles.SetDirectory(credentialFileDir)
+	nutanixClient.SetTransportLimits(nutanixClient.TransportLimits{
+		MaxIdleConns:    prismClientMaxIdleConns,
+		MaxConnsPerHost: prismClientMaxConnsPerHost,
+		IdleConnTimeout: prismClientIdleConnTimeout,
+	})
+	nutanixClient.SetTaskInvalidUUIDGracePeriod(taskInvalidUUIDGracePeriod)
 	setupLog.Info("Initializing Nutanix Cluster API Infrastructure Provider", "Git Hash", gitCommitHash)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "f265110d.cluster.x-k8s.io",
-	})
+	mgrOptions, err := newManagerOptions(metricsAddr, probeAddr, enableLeaderElection, syncPeriod)
+	if err != nil {
+		setupLog.Error(err, "invalid manager options")
+		os.Exit(1)
+	}
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), mgrOptions)
 	if err != nil {
 		setupLog.Error(err, "unable to create manager")
 		os.Exit(1)
@@ -142,7 +388,15 @@ func main() {
 		secretInformer,
 		configMapInformer,
 		mgr.GetScheme(),
-		controllers.WithMaxConcurrentReconciles(maxConcurrentReconciles),
+		controllers.WithMaxConcurrentReconciles(nutanixClusterConcurrency),
+		controllers.WithTrustBundleMinRSAKeySize(trustBundleMinRSAKeySize),
+		controllers.WithTrustBundleWeakSignatureAlgorithms(splitFlagValues(trustBundleWeakSignatureAlgorithms)),
+		controllers.WithReconcileTimeout(reconcileTimeout),
+		controllers.WithRequeueJitterFactor(requeueJitterFactor),
+		controllers.WithRestrictCredentialNamespaces(restrictCredentialNamespaces),
+		controllers.WithAllowedCredentialNamespaces(splitFlagValues(allowedCredentialNamespaces)),
+		controllers.WithManageCredentialFinalizers(manageCredentialFinalizers),
+		controllers.WithSeedDiskPoolSize(seedDiskPoolSize),
 	)
 	if err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NutanixCluster")
@@ -158,7 +412,21 @@ func main() {
 		secretInformer,
 		configMapInformer,
 		mgr.GetScheme(),
-		controllers.WithMaxConcurrentReconciles(maxConcurrentReconciles),
+		controllers.WithMaxConcurrentReconciles(nutanixMachineConcurrency),
+		controllers.WithVMShutdownGracePeriod(vmShutdownGracePeriod),
+		controllers.WithImageReadyTimeout(imageReadyTimeout),
+		controllers.WithReconcileTimeout(reconcileTimeout),
+		controllers.WithRequeueJitterFactor(requeueJitterFactor),
+		controllers.WithBootstrapDataCompressionThreshold(bootstrapDataCompressionThreshold),
+		controllers.WithSeedDiskPoolSize(seedDiskPoolSize),
+		controllers.WithReconcileDrift(reconcileDrift),
+		controllers.WithMachineRoleCategories(machineRoleCategories),
+		controllers.WithExtraVMConfig(extraVMConfig),
+		controllers.WithVMProtectionCategory(vmProtectionCategory),
+		controllers.WithVMTaskTimeout(vmTaskTimeout),
+		controllers.WithIPAddressClaimRequeueInterval(ipAddressClaimRequeueInterval),
+		controllers.WithVMDescriptionAnnotationPrefix(vmDescriptionAnnotationPrefix),
+		controllers.WithNodeDrainTimeout(nodeDrainTimeout),
 	)
 	if err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NutanixMachine")
@@ -168,6 +436,31 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "NutanixMachine")
 		os.Exit(1)
 	}
+	if prismCentralHealthCheckInterval > 0 {
+		healthChecker, err := controllers.NewPrismCentralHealthChecker(mgr.GetClient(), secretInformer, configMapInformer, prismCentralHealthCheckInterval)
+		if err != nil {
+			setupLog.Error(err, "unable to create Prism Central health checker")
+			os.Exit(1)
+		}
+		if err = mgr.Add(healthChecker); err != nil {
+			setupLog.Error(err, "unable to add Prism Central health checker")
+			os.Exit(1)
+		}
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = controllers.NewNutanixClusterValidator(mgr.GetClient(), secretInformer, configMapInformer).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NutanixCluster")
+			os.Exit(1)
+		}
+		if err = controllers.NewNutanixMachineValidator(mgr.GetClient(), secretInformer, configMapInformer).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NutanixMachine")
+			os.Exit(1)
+		}
+		if err = controllers.NewNutanixMachineTemplateValidator().SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NutanixMachineTemplate")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -185,3 +478,42 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newManagerOptions returns the options of the controller manager. Returns an error if the sync period of the caches
+// is not positive.
+func newManagerOptions(metricsAddr, probeAddr string, enableLeaderElection bool, syncPeriod time.Duration) (ctrl.Options, error) {
+	if syncPeriod <= 0 {
+		return ctrl.Options{}, errors.New("sync period must be positive")
+	}
+	return ctrl.Options{
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		Port:                   9443,
+		HealthProbeBindAddress: probeAddr,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "f265110d.cluster.x-k8s.io",
+		SyncPeriod:             &syncPeriod,
+	}, nil
+}
+
+// isFlagSet returns true if the flag with the given name was set on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// splitFlagValues splits a comma-separated flag value into its trimmed, non-empty values
+func splitFlagValues(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
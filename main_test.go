@@ -0,0 +1,40 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewManagerOptions(t *testing.T) {
+	options, err := newManagerOptions(":8080", ":8081", true, 30*time.Minute)
+	assert.NoError(t, err)
+	if assert.NotNil(t, options.SyncPeriod) {
+		assert.Equal(t, 30*time.Minute, *options.SyncPeriod)
+	}
+	assert.Equal(t, ":8080", options.MetricsBindAddress)
+	assert.Equal(t, ":8081", options.HealthProbeBindAddress)
+	assert.True(t, options.LeaderElection)
+
+	_, err = newManagerOptions(":8080", ":8081", false, 0)
+	assert.Error(t, err)
+	_, err = newManagerOptions(":8080", ":8081", false, -time.Minute)
+	assert.Error(t, err)
+}
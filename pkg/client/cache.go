@@ -0,0 +1,249 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+// NutanixClientCache is the cache of the Prism Central clients shared by the controllers
+var NutanixClientCache = NewClientCache()
+
+// ClientCache caches the Prism Central client of each NutanixCluster
+type ClientCache struct {
+	mu      sync.RWMutex
+	clients map[string]*cachedClient
+}
+
+type cachedClient struct {
+	client *nutanixClientV3.Client
+	// namespace is the namespace of the NutanixCluster
+	namespace string
+	// prismCentral is the Prism Central endpoint of the NutanixCluster the client was created for
+	prismCentral *credentialTypes.NutanixPrismEndpoint
+	// proxy is the proxy configuration of the NutanixCluster the client was created for
+	proxy *infrav1.NutanixProxySpec
+	// clientCertificate is the client certificate reference of the NutanixCluster the client was created for
+	clientCertificate *infrav1.NutanixClientCertificate
+	// insecureSkipVerify is set if the client was created without TLS verification
+	insecureSkipVerify bool
+	// credentialHash is the hash of the credentials the client was created with
+	credentialHash string
+	// prismCentralVersion is the version of Prism Central detected when the client was created
+	prismCentralVersion string
+}
+
+func NewClientCache() *ClientCache {
+	return &ClientCache{
+		clients: make(map[string]*cachedClient),
+	}
+}
+
+func clientCacheKey(nutanixCluster *infrav1.NutanixCluster) string {
+	return fmt.Sprintf("%s/%s", nutanixCluster.Namespace, nutanixCluster.Name)
+}
+
+// Get returns the cached client of the NutanixCluster. No client is returned if the Prism Central
+// endpoint, the proxy configuration or the TLS settings of the NutanixCluster changed since the client was created,
+// or if the hash of its credentials differs from the given hash, e.g. since the credentials were rotated.
+func (c *ClientCache) Get(nutanixCluster *infrav1.NutanixCluster, credentialHash string) (*nutanixClientV3.Client, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cached, ok := c.clients[clientCacheKey(nutanixCluster)]
+	if !ok ||
+		cached.credentialHash != credentialHash ||
+		!apiequality.Semantic.DeepEqual(cached.prismCentral, nutanixCluster.Spec.PrismCentral) ||
+		!apiequality.Semantic.DeepEqual(cached.proxy, nutanixCluster.Spec.Proxy) ||
+		!apiequality.Semantic.DeepEqual(cached.clientCertificate, nutanixCluster.Spec.ClientCertificate) ||
+		cached.insecureSkipVerify != IsInsecureSkipVerify(nutanixCluster) {
+		return nil, false
+	}
+	return cached.client, true
+}
+
+// Set caches the client of the NutanixCluster created for the given Prism Central endpoint with the credentials of
+// the given hash
+func (c *ClientCache) Set(nutanixCluster *infrav1.NutanixCluster, prismCentral *credentialTypes.NutanixPrismEndpoint, credentialHash string, client *nutanixClientV3.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clients[clientCacheKey(nutanixCluster)] = &cachedClient{
+		client:             client,
+		namespace:          nutanixCluster.Namespace,
+		prismCentral:       prismCentral.DeepCopy(),
+		proxy:              nutanixCluster.Spec.Proxy.DeepCopy(),
+		clientCertificate:  nutanixCluster.Spec.ClientCertificate.DeepCopy(),
+		insecureSkipVerify: IsInsecureSkipVerify(nutanixCluster),
+		credentialHash:     credentialHash,
+	}
+}
+
+// SetPrismCentralVersion records the version of Prism Central detected with the cached client of the NutanixCluster
+func (c *ClientCache) SetPrismCentralVersion(nutanixCluster *infrav1.NutanixCluster, version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.clients[clientCacheKey(nutanixCluster)]; ok {
+		cached.prismCentralVersion = version
+	}
+}
+
+// PrismCentralVersion returns the version of Prism Central detected with the cached client of the NutanixCluster, or
+// an empty string if the version is not known
+func (c *ClientCache) PrismCentralVersion(nutanixCluster *infrav1.NutanixCluster) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if cached, ok := c.clients[clientCacheKey(nutanixCluster)]; ok {
+		return cached.prismCentralVersion
+	}
+	return ""
+}
+
+// Delete removes the cached client of the NutanixCluster
+func (c *ClientCache) Delete(nutanixCluster *infrav1.NutanixCluster) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.clients, clientCacheKey(nutanixCluster))
+}
+
+// InvalidateCredentialRef removes all cached clients that were created with the credentials of the given secret.
+// It returns the number of removed clients.
+func (c *ClientCache) InvalidateCredentialRef(namespace, name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key, cached := range c.clients {
+		if cached.prismCentral == nil || cached.prismCentral.CredentialRef == nil {
+			continue
+		}
+		credentialRef := cached.prismCentral.CredentialRef
+		// The credential secret is in the namespace of the NutanixCluster if no namespace is set
+		credentialNamespace := credentialRef.Namespace
+		if credentialNamespace == "" {
+			credentialNamespace = cached.namespace
+		}
+		if credentialRef.Kind == credentialTypes.SecretKind && credentialNamespace == namespace && credentialRef.Name == name {
+			delete(c.clients, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// InvalidateTrustBundleRef removes all cached clients that were created with the additional trust bundle of the given
+// configmap. It returns the number of removed clients.
+func (c *ClientCache) InvalidateTrustBundleRef(namespace, name string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key, cached := range c.clients {
+		if cached.prismCentral == nil || cached.prismCentral.AdditionalTrustBundle == nil {
+			continue
+		}
+		trustBundleRef := cached.prismCentral.AdditionalTrustBundle
+		// The trust bundle configmap is in the namespace of the NutanixCluster if no namespace is set
+		trustBundleNamespace := trustBundleRef.Namespace
+		if trustBundleNamespace == "" {
+			trustBundleNamespace = cached.namespace
+		}
+		if trustBundleRef.Kind == credentialTypes.NutanixTrustBundleKindConfigMap && trustBundleNamespace == namespace && trustBundleRef.Name == name {
+			delete(c.clients, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// InvalidateCredentialFile removes all cached clients that were created with the credentials of the given file.
+// It returns the number of removed clients.
+func (c *ClientCache) InvalidateCredentialFile(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key, cached := range c.clients {
+		if cached.prismCentral == nil || cached.prismCentral.CredentialRef == nil {
+			continue
+		}
+		credentialRef := cached.prismCentral.CredentialRef
+		if credentialRef.Kind == FileCredentialKind && credentialRef.Name == path {
+			delete(c.clients, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RefreshCredentialFile reads the credential file referenced by the NutanixCluster if its refresh interval passed
+// and invalidates the cached clients if the content of the file changed. It returns the number of removed clients.
+func RefreshCredentialFile(nutanixCluster *infrav1.NutanixCluster) (int, error) {
+	prismCentral := nutanixCluster.Spec.PrismCentral
+	if prismCentral == nil || prismCentral.CredentialRef == nil || prismCentral.CredentialRef.Kind != FileCredentialKind {
+		return 0, nil
+	}
+	_, changed, err := CredentialFiles.Read(prismCentral.CredentialRef.Name)
+	if err != nil {
+		return 0, err
+	}
+	if !changed {
+		return 0, nil
+	}
+	return NutanixClientCache.InvalidateCredentialFile(prismCentral.CredentialRef.Name), nil
+}
+
+// GetCredentialSecretHash returns a hash of the content of the credential secret
+func GetCredentialSecretHash(secret *corev1.Secret) string {
+	return hashData(secret.Data)
+}
+
+// GetTrustBundleConfigMapHash returns a hash of the content of the trust bundle configmap
+func GetTrustBundleConfigMapHash(cm *corev1.ConfigMap) string {
+	// Keys of Data and BinaryData of a configmap do not overlap
+	data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+	for k, v := range cm.Data {
+		data[k] = []byte(v)
+	}
+	for k, v := range cm.BinaryData {
+		data[k] = v
+	}
+	return hashData(data)
+}
+
+// hashData returns a hash of the keys and values of the data, independent of the order of the keys
+func hashData(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write(data[k])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"testing"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+func TestClientCache(t *testing.T) {
+	nutanixCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+				Address: "prism.example.com",
+				Port:    9440,
+				CredentialRef: &credentialTypes.NutanixCredentialReference{
+					Kind: credentialTypes.SecretKind,
+					Name: "creds",
+				},
+			},
+		},
+	}
+	cache := NewClientCache()
+	client := &nutanixClientV3.Client{}
+
+	_, ok := cache.Get(nutanixCluster, "")
+	assert.False(t, ok)
+
+	cache.Set(nutanixCluster, nutanixCluster.Spec.PrismCentral, "", client)
+	cached, ok := cache.Get(nutanixCluster, "")
+	assert.True(t, ok)
+	assert.Same(t, client, cached)
+
+	// Changing the endpoint requires a new client
+	changed := nutanixCluster.DeepCopy()
+	changed.Spec.PrismCentral.Address = "other.example.com"
+	_, ok = cache.Get(changed, "")
+	assert.False(t, ok)
+
+	// Changing the proxy requires a new client
+	changed = nutanixCluster.DeepCopy()
+	changed.Spec.Proxy = &infrav1.NutanixProxySpec{HTTPSProxy: "http://proxy.example.com:3128"}
+	_, ok = cache.Get(changed, "")
+	assert.False(t, ok)
+
+	// Rotated credentials require a new client
+	_, ok = cache.Get(nutanixCluster, "rotated")
+	assert.False(t, ok)
+
+	// Only clients using the given secret are invalidated
+	assert.Equal(t, 0, cache.InvalidateCredentialRef("default", "other-creds"))
+	assert.Equal(t, 0, cache.InvalidateCredentialRef("other", "creds"))
+	assert.Equal(t, 1, cache.InvalidateCredentialRef("default", "creds"))
+	_, ok = cache.Get(nutanixCluster, "")
+	assert.False(t, ok)
+
+	cache.Set(nutanixCluster, nutanixCluster.Spec.PrismCentral, "", client)
+	cache.Delete(nutanixCluster)
+	_, ok = cache.Get(nutanixCluster, "")
+	assert.False(t, ok)
+}
+
+func TestClientCacheInvalidateCredentialFile(t *testing.T) {
+	nutanixCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+				Address: "prism.example.com",
+				Port:    9440,
+				CredentialRef: &credentialTypes.NutanixCredentialReference{
+					Kind: FileCredentialKind,
+					Name: "/etc/nutanix/credentials",
+				},
+			},
+		},
+	}
+	cache := NewClientCache()
+	cache.Set(nutanixCluster, nutanixCluster.Spec.PrismCentral, "", &nutanixClientV3.Client{})
+
+	// Secrets with the same name do not invalidate clients using a credential file
+	assert.Equal(t, 0, cache.InvalidateCredentialRef("default", "/etc/nutanix/credentials"))
+	assert.Equal(t, 0, cache.InvalidateCredentialFile("/etc/nutanix/other"))
+	assert.Equal(t, 1, cache.InvalidateCredentialFile("/etc/nutanix/credentials"))
+	_, ok := cache.Get(nutanixCluster, "")
+	assert.False(t, ok)
+}
+
+func TestClientCacheInvalidateTrustBundleRef(t *testing.T) {
+	nutanixCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+				Address: "prism.example.com",
+				Port:    9440,
+				AdditionalTrustBundle: &credentialTypes.NutanixTrustBundleReference{
+					Kind: credentialTypes.NutanixTrustBundleKindConfigMap,
+					Name: "trust-bundle",
+				},
+			},
+		},
+	}
+	cache := NewClientCache()
+	cache.Set(nutanixCluster, nutanixCluster.Spec.PrismCentral, "", &nutanixClientV3.Client{})
+
+	// Only clients using the given configmap are invalidated
+	assert.Equal(t, 0, cache.InvalidateCredentialRef("default", "trust-bundle"))
+	assert.Equal(t, 0, cache.InvalidateTrustBundleRef("default", "other-trust-bundle"))
+	assert.Equal(t, 0, cache.InvalidateTrustBundleRef("other", "trust-bundle"))
+	assert.Equal(t, 1, cache.InvalidateTrustBundleRef("default", "trust-bundle"))
+	_, ok := cache.Get(nutanixCluster, "")
+	assert.False(t, ok)
+}
+
+func TestGetCredentialHash(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "shared"},
+		Data:       map[string][]byte{credentialTypes.KeyName: []byte("old")},
+	}
+	secretInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+	assert.NoError(t, secretInformer.Informer().GetIndexer().Add(secret))
+	helper, err := NewNutanixClientHelper(secretInformer, nil)
+	assert.NoError(t, err)
+	nutanixCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: infrav1.NutanixClusterSpec{
+			PrismCentral: &credentialTypes.NutanixPrismEndpoint{
+				Address: "prism.example.com",
+				Port:    9440,
+				CredentialRef: &credentialTypes.NutanixCredentialReference{
+					Kind:      credentialTypes.SecretKind,
+					Name:      "creds",
+					Namespace: "shared",
+				},
+			},
+		},
+	}
+
+	oldHash, err := helper.GetCredentialHash(nutanixCluster)
+	assert.NoError(t, err)
+	assert.Equal(t, GetCredentialSecretHash(secret), oldHash)
+
+	rotated := secret.DeepCopy()
+	rotated.Data[credentialTypes.KeyName] = []byte("new")
+	assert.NoError(t, secretInformer.Informer().GetIndexer().Update(rotated))
+	newHash, err := helper.GetCredentialHash(nutanixCluster)
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldHash, newHash)
+
+	nutanixCluster.Spec.PrismCentral.CredentialRef.Name = "missing"
+	_, err = helper.GetCredentialHash(nutanixCluster)
+	assert.Error(t, err)
+}
+
+func TestGetCredentialSecretHash(t *testing.T) {
+	secret := &corev1.Secret{
+		Data: map[string][]byte{
+			"credentials": []byte("old"),
+		},
+	}
+	hash := GetCredentialSecretHash(secret)
+	assert.Equal(t, hash, GetCredentialSecretHash(secret.DeepCopy()))
+
+	secret.Data["credentials"] = []byte("new")
+	assert.NotEqual(t, hash, GetCredentialSecretHash(secret))
+}
+
+func TestGetTrustBundleConfigMapHash(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		Data: map[string]string{
+			TrustBundleConfigMapKey: "old",
+		},
+	}
+	hash := GetTrustBundleConfigMapHash(cm)
+	assert.Equal(t, hash, GetTrustBundleConfigMapHash(cm.DeepCopy()))
+
+	cm.Data[TrustBundleConfigMapKey] = "new"
+	assert.NotEqual(t, hash, GetTrustBundleConfigMapHash(cm))
+
+	// Binary data is part of the content
+	binary := &corev1.ConfigMap{
+		BinaryData: map[string][]byte{
+			TrustBundleConfigMapKey: []byte("new"),
+		},
+	}
+	binaryHash := GetTrustBundleConfigMapHash(binary)
+	binary.BinaryData[TrustBundleConfigMapKey] = []byte("old")
+	assert.NotEqual(t, binaryHash, GetTrustBundleConfigMapHash(binary))
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// CircuitState is the state of the circuit of a Prism Central endpoint
+type CircuitState string
+
+const (
+	// CircuitClosed lets all requests to the endpoint through
+	CircuitClosed CircuitState = "Closed"
+	// CircuitOpen holds back requests to the endpoint until the open delay expired
+	CircuitOpen CircuitState = "Open"
+	// CircuitHalfOpen lets a single request probe whether the endpoint recovered
+	CircuitHalfOpen CircuitState = "HalfOpen"
+)
+
+// connectionErrorMessages are the messages of errors returned if a Prism Central endpoint cannot be reached.
+// The Prism client does not wrap the underlying network errors, so their messages are matched as well.
+var connectionErrorMessages = []string{
+	"connection refused",
+	"connection reset by peer",
+	"no such host",
+	"no route to host",
+	"network is unreachable",
+	"i/o timeout",
+	"TLS handshake timeout",
+}
+
+// CircuitBreaker tracks the consecutive connection failures of each Prism Central endpoint. Once the failure
+// threshold is reached, the circuit of the endpoint opens and requests are held back for an exponentially
+// increasing delay. After the delay expired, the circuit half-opens to let a single request probe the endpoint.
+// A successful request closes the circuit again.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	clock            clock.PassiveClock
+	failureThreshold int
+	baseDelay        time.Duration
+	maxDelay         time.Duration
+	endpoints        map[string]*endpointCircuit
+}
+
+type endpointCircuit struct {
+	// failures is the number of consecutive connection failures
+	failures int
+	// delay is the delay the circuit was last opened for
+	delay time.Duration
+	// openUntil is the time until which requests are held back
+	openUntil time.Time
+	// probing is set while a request probes the endpoint in half-open state
+	probing bool
+}
+
+func NewCircuitBreaker(clock clock.PassiveClock, failureThreshold int, baseDelay, maxDelay time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		clock:            clock,
+		failureThreshold: failureThreshold,
+		baseDelay:        baseDelay,
+		maxDelay:         maxDelay,
+		endpoints:        make(map[string]*endpointCircuit),
+	}
+}
+
+// Allow returns whether a request may be sent to the endpoint. If not, the delay until the endpoint
+// may be probed again is returned. Only a single probe is let through once the open delay expired.
+func (b *CircuitBreaker) Allow(endpoint string) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	circuit, ok := b.endpoints[endpoint]
+	if !ok || circuit.failures < b.failureThreshold {
+		return true, 0
+	}
+	now := b.clock.Now()
+	if now.Before(circuit.openUntil) {
+		return false, circuit.openUntil.Sub(now)
+	}
+	// Hold back other requests while probing, until the probe is considered lost after another delay
+	circuit.probing = true
+	circuit.openUntil = now.Add(circuit.delay)
+	return true, 0
+}
+
+// RecordSuccess closes the circuit of the endpoint
+func (b *CircuitBreaker) RecordSuccess(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.endpoints, endpoint)
+}
+
+// RecordFailure records a connection failure of the endpoint. If the circuit opens, the delay until the
+// endpoint may be probed again is returned. The delay doubles each time a probe fails.
+func (b *CircuitBreaker) RecordFailure(endpoint string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	circuit, ok := b.endpoints[endpoint]
+	if !ok {
+		circuit = &endpointCircuit{}
+		b.endpoints[endpoint] = circuit
+	}
+	circuit.failures++
+	if circuit.failures < b.failureThreshold {
+		return 0
+	}
+	if circuit.delay == 0 {
+		circuit.delay = b.baseDelay
+	} else {
+		circuit.delay *= 2
+	}
+	if circuit.delay > b.maxDelay {
+		circuit.delay = b.maxDelay
+	}
+	circuit.openUntil = b.clock.Now().Add(circuit.delay)
+	circuit.probing = false
+	return circuit.delay
+}
+
+// State returns the state of the circuit of the endpoint
+func (b *CircuitBreaker) State(endpoint string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	circuit, ok := b.endpoints[endpoint]
+	if !ok || circuit.failures < b.failureThreshold {
+		return CircuitClosed
+	}
+	if circuit.probing || !b.clock.Now().Before(circuit.openUntil) {
+		return CircuitHalfOpen
+	}
+	return CircuitOpen
+}
+
+// IsConnectionError returns true if the error indicates that the Prism Central endpoint cannot be reached
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	msg := err.Error()
+	for _, connectionErrorMessage := range connectionErrorMessages {
+		if strings.Contains(msg, connectionErrorMessage) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const endpoint = "prism-central:9440"
+	clock := clocktesting.NewFakePassiveClock(time.Now())
+	breaker := NewCircuitBreaker(clock, 2, 10*time.Second, 30*time.Second)
+
+	// closed: failures below the threshold are let through
+	assert.Equal(t, CircuitClosed, breaker.State(endpoint))
+	assert.Equal(t, time.Duration(0), breaker.RecordFailure(endpoint))
+	allowed, _ := breaker.Allow(endpoint)
+	assert.True(t, allowed)
+
+	// open: requests are held back once the threshold is reached
+	assert.Equal(t, 10*time.Second, breaker.RecordFailure(endpoint))
+	assert.Equal(t, CircuitOpen, breaker.State(endpoint))
+	allowed, delay := breaker.Allow(endpoint)
+	assert.False(t, allowed)
+	assert.Equal(t, 10*time.Second, delay)
+	allowed, _ = breaker.Allow("other-prism-central:9440")
+	assert.True(t, allowed)
+
+	// half-open: a single probe is let through once the delay expired
+	clock.SetTime(clock.Now().Add(10 * time.Second))
+	assert.Equal(t, CircuitHalfOpen, breaker.State(endpoint))
+	allowed, _ = breaker.Allow(endpoint)
+	assert.True(t, allowed)
+	allowed, _ = breaker.Allow(endpoint)
+	assert.False(t, allowed)
+
+	// a failed probe reopens the circuit with a doubled delay, capped at the maximum delay
+	assert.Equal(t, 20*time.Second, breaker.RecordFailure(endpoint))
+	assert.Equal(t, CircuitOpen, breaker.State(endpoint))
+	clock.SetTime(clock.Now().Add(20 * time.Second))
+	allowed, _ = breaker.Allow(endpoint)
+	assert.True(t, allowed)
+	assert.Equal(t, 30*time.Second, breaker.RecordFailure(endpoint))
+
+	// closed: a successful probe resets the circuit
+	clock.SetTime(clock.Now().Add(30 * time.Second))
+	allowed, _ = breaker.Allow(endpoint)
+	assert.True(t, allowed)
+	breaker.RecordSuccess(endpoint)
+	assert.Equal(t, CircuitClosed, breaker.State(endpoint))
+	assert.Equal(t, time.Duration(0), breaker.RecordFailure(endpoint))
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "no error",
+		},
+		{
+			name: "network error",
+			err:  fmt.Errorf("request failed: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("refused")}),
+			want: true,
+		},
+		{
+			name: "unwrapped connection refused",
+			err:  errors.New("Get \"https://prism-central:9440/api/nutanix/v3/clusters/list\": dial tcp 10.0.0.1:9440: connect: connection refused"),
+			want: true,
+		},
+		{
+			name: "API error",
+			err:  errors.New("ENTITY_NOT_FOUND: cluster not found"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsConnectionError(tt.err))
+		})
+	}
+}
@@ -18,10 +18,12 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	prismgoclient "github.com/nutanix-cloud-native/prism-go-client"
 	"github.com/nutanix-cloud-native/prism-go-client/environment"
@@ -29,18 +31,19 @@ import (
 	kubernetesEnv "github.com/nutanix-cloud-native/prism-go-client/environment/providers/kubernetes"
 	envTypes "github.com/nutanix-cloud-native/prism-go-client/environment/types"
 	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	corev1 "k8s.io/api/core/v1"
 	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
 )
 
 const (
-	defaultEndpointPort = "9440"
-	ProviderName        = "nutanix"
-	configPath          = "/etc/nutanix/config"
-	endpointKey         = "prismCentral"
-	capxNamespaceKey    = "POD_NAMESPACE"
+	ProviderName     = "nutanix"
+	configPath       = "/etc/nutanix/config"
+	endpointKey      = "prismCentral"
+	capxNamespaceKey = "POD_NAMESPACE"
 )
 
 type NutanixClientHelper struct {
@@ -63,18 +66,17 @@ func (n *NutanixClientHelper) GetClientFromEnvironment(ctx context.Context, nuta
 	// If PrismCentral is set, add the required env provider
 	prismCentralInfo := nutanixCluster.Spec.PrismCentral
 	if prismCentralInfo != nil {
-		if prismCentralInfo.Address == "" {
-			return nil, fmt.Errorf("cannot get credentials if Prism Address is not set")
-		}
-		if prismCentralInfo.Port == 0 {
-			return nil, fmt.Errorf("cannot get credentials if Prism Port is not set")
+		prismEndpoint, err := NormalizePrismEndpoint(*prismCentralInfo)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get credentials for the Prism endpoint of cluster %s in namespace %s: %v", nutanixCluster.Name, nutanixCluster.Namespace, err)
 		}
+		prismCentralInfo = &prismEndpoint
 		credentialRef := prismCentralInfo.CredentialRef
 		if credentialRef == nil {
 			return nil, fmt.Errorf("credentialRef must be set on prismCentral attribute for cluster %s in namespace %s", nutanixCluster.Name, nutanixCluster.Namespace)
 		}
 		// If namespace is empty, use the cluster namespace
-		if credentialRef.Namespace == "" {
+		if credentialRef.Kind != FileCredentialKind && credentialRef.Namespace == "" {
 			credentialRef.Namespace = nutanixCluster.Namespace
 		}
 		additionalTrustBundleRef := prismCentralInfo.AdditionalTrustBundle
@@ -83,10 +85,10 @@ func (n *NutanixClientHelper) GetClientFromEnvironment(ctx context.Context, nuta
 			additionalTrustBundleRef.Namespace == "" {
 			additionalTrustBundleRef.Namespace = nutanixCluster.Namespace
 		}
-		providers = append(providers, kubernetesEnv.NewProvider(
-			*nutanixCluster.Spec.PrismCentral,
-			n.secretInformer,
-			n.configMapInformer))
+		if tokenSource := n.getBearerTokenSource(*prismCentralInfo); tokenSource != nil {
+			return n.getBearerTokenClient(ctx, nutanixCluster, *prismCentralInfo, tokenSource)
+		}
+		providers = append(providers, n.newProvider(*prismCentralInfo))
 	} else {
 		log.Info(fmt.Sprintf("[WARNING] prismCentral attribute was not set on NutanixCluster %s in namespace %s. Defaulting to CAPX manager credentials", nutanixCluster.Name, nutanixCluster.Namespace))
 	}
@@ -97,7 +99,7 @@ func (n *NutanixClientHelper) GetClientFromEnvironment(ctx context.Context, nuta
 		return nil, err
 	}
 	// If namespaces is not set, set it to the namespace of the CAPX manager
-	if npe.CredentialRef.Namespace == "" {
+	if npe.CredentialRef.Kind != FileCredentialKind && npe.CredentialRef.Namespace == "" {
 		capxNamespace := os.Getenv(capxNamespaceKey)
 		if capxNamespace == "" {
 			return nil, fmt.Errorf("failed to retrieve capx-namespace. Make sure %s env variable is set", capxNamespaceKey)
@@ -111,10 +113,12 @@ func (n *NutanixClientHelper) GetClientFromEnvironment(ctx context.Context, nuta
 		}
 		npe.AdditionalTrustBundle.Namespace = capxNamespace
 	}
-	providers = append(providers, kubernetesEnv.NewProvider(
-		*npe,
-		n.secretInformer,
-		n.configMapInformer))
+	if prismCentralInfo == nil {
+		if tokenSource := n.getBearerTokenSource(*npe); tokenSource != nil {
+			return n.getBearerTokenClient(ctx, nutanixCluster, *npe, tokenSource)
+		}
+	}
+	providers = append(providers, n.newProvider(*npe))
 
 	// init env with providers
 	env := environment.NewEnvironment(
@@ -128,15 +132,190 @@ func (n *NutanixClientHelper) GetClientFromEnvironment(ctx context.Context, nuta
 	creds := prismgoclient.Credentials{
 		URL:      me.Address.Host,
 		Endpoint: me.Address.Host,
-		Insecure: me.Insecure,
+		Insecure: me.Insecure || IsInsecureSkipVerify(nutanixCluster),
 		Username: me.ApiCredentials.Username,
 		Password: me.ApiCredentials.Password,
 	}
 
-	return n.GetClient(creds, me.AdditionalTrustBundle)
+	clientOpts := make([]nutanixClientV3.ClientOption, 0)
+	if nutanixCluster.Spec.Proxy != nil || nutanixCluster.Spec.ClientCertificate != nil {
+		clientCertificate, err := n.getClientCertificate(nutanixCluster)
+		if err != nil {
+			return nil, err
+		}
+		if nutanixCluster.Spec.Proxy != nil {
+			log.V(1).Info(fmt.Sprintf("using proxy settings of NutanixCluster %s in namespace %s", nutanixCluster.Name, nutanixCluster.Namespace))
+		}
+		transport, err := NewTransport(nutanixCluster.Spec.Proxy, me.AdditionalTrustBundle, clientCertificate)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts = append(clientOpts, nutanixClientV3.WithRoundTripper(transport))
+	}
+
+	return n.GetClient(ctx, creds, me.AdditionalTrustBundle, clientOpts...)
 }
 
-func (n *NutanixClientHelper) GetClient(cred prismgoclient.Credentials, additionalTrustBundle string) (*nutanixClientV3.Client, error) {
+// GetCredentialHash returns a hash of the credentials the client of the NutanixCluster is created with. These are the
+// credentials of the Prism Central endpoint of the NutanixCluster, or of the CAPX manager if no endpoint is set.
+// Comparing the hash detects rotated credentials even if no event of the credential secret was received.
+func (n *NutanixClientHelper) GetCredentialHash(nutanixCluster *infrav1.NutanixCluster) (string, error) {
+	var credentialRef *credentialTypes.NutanixCredentialReference
+	namespace := nutanixCluster.Namespace
+	if nutanixCluster.Spec.PrismCentral != nil {
+		credentialRef = nutanixCluster.Spec.PrismCentral.CredentialRef
+	} else {
+		npe, err := n.getManagerNutanixPrismEndpoint()
+		if err != nil {
+			return "", err
+		}
+		credentialRef = npe.CredentialRef
+		namespace = os.Getenv(capxNamespaceKey)
+	}
+	if credentialRef == nil {
+		return "", fmt.Errorf("credentialRef must be set on prismCentral attribute for cluster %s in namespace %s", nutanixCluster.Name, nutanixCluster.Namespace)
+	}
+	if credentialRef.Kind == FileCredentialKind {
+		data, _, err := CredentialFiles.Read(credentialRef.Name)
+		if err != nil {
+			return "", err
+		}
+		return hashData(map[string][]byte{credentialTypes.KeyName: data}), nil
+	}
+	if credentialRef.Namespace != "" {
+		namespace = credentialRef.Namespace
+	}
+	if n.secretInformer == nil {
+		return "", fmt.Errorf("cannot read credential secret %s/%s without secret informer", namespace, credentialRef.Name)
+	}
+	secret, err := n.secretInformer.Lister().Secrets(namespace).Get(credentialRef.Name)
+	if err != nil {
+		return "", err
+	}
+	return GetCredentialSecretHash(secret), nil
+}
+
+// getBearerTokenSource returns the token source of the credentials referenced by the Prism endpoint, or nil if the
+// credentials are not of type bearer_token. Credentials that cannot be read are left to the env providers to report.
+func (n *NutanixClientHelper) getBearerTokenSource(prismEndpoint credentialTypes.NutanixPrismEndpoint) TokenSource {
+	credentialRef := prismEndpoint.CredentialRef
+	readBearerToken := func() (*BearerToken, error) {
+		var data []byte
+		var err error
+		if credentialRef.Kind == FileCredentialKind {
+			data, _, err = CredentialFiles.Read(credentialRef.Name)
+		} else {
+			data, err = n.getSecretKey(credentialRef.Namespace, &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: credentialRef.Name},
+				Key:                  credentialTypes.KeyName,
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ParseBearerToken(data)
+	}
+	if token, err := readBearerToken(); err != nil || token == nil {
+		return nil
+	}
+	return func() (*BearerToken, error) {
+		token, err := readBearerToken()
+		if err != nil {
+			return nil, err
+		}
+		if token == nil {
+			return nil, fmt.Errorf("credentials %s are no longer of type %s", credentialRef.Name, BearerTokenCredentialType)
+		}
+		return token, nil
+	}
+}
+
+// getBearerTokenClient returns a client authenticating against the Prism endpoint with the bearer token of the token
+// source. The transport is configured here since the Prism client cannot configure a wrapped transport.
+func (n *NutanixClientHelper) getBearerTokenClient(ctx context.Context, nutanixCluster *infrav1.NutanixCluster, prismEndpoint credentialTypes.NutanixPrismEndpoint, tokenSource TokenSource) (*nutanixClientV3.Client, error) {
+	trustBundleProvider := &fileProvider{prismEndpoint: prismEndpoint, cmInformer: n.configMapInformer}
+	additionalTrustBundle, err := trustBundleProvider.getAdditionalTrustBundle()
+	if err != nil {
+		return nil, err
+	}
+	clientCertificate, err := n.getClientCertificate(nutanixCluster)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := NewTransport(nutanixCluster.Spec.Proxy, additionalTrustBundle, clientCertificate)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = prismEndpoint.Insecure || IsInsecureSkipVerify(nutanixCluster)
+	host := JoinHostPort(prismEndpoint.Address, strconv.Itoa(int(prismEndpoint.Port)))
+	creds := prismgoclient.Credentials{
+		URL:      host,
+		Endpoint: host,
+		Username: bearerTokenUsername,
+		Password: bearerTokenPassword,
+	}
+	return n.GetClient(ctx, creds, "", nutanixClientV3.WithRoundTripper(NewBearerTokenRoundTripper(transport, tokenSource, clock.RealClock{})))
+}
+
+// IsInsecureSkipVerify returns true if the verification of the TLS certificate of Prism Central is disabled for the NutanixCluster
+func IsInsecureSkipVerify(nutanixCluster *infrav1.NutanixCluster) bool {
+	if nutanixCluster.Spec.InsecureSkipVerify != nil && *nutanixCluster.Spec.InsecureSkipVerify {
+		return true
+	}
+	return nutanixCluster.Spec.PrismCentral != nil && nutanixCluster.Spec.PrismCentral.Insecure
+}
+
+// getClientCertificate returns the client certificate referenced by the NutanixCluster or nil if none is referenced
+func (n *NutanixClientHelper) getClientCertificate(nutanixCluster *infrav1.NutanixCluster) (*tls.Certificate, error) {
+	clientCertificate := nutanixCluster.Spec.ClientCertificate
+	if clientCertificate == nil {
+		return nil, nil
+	}
+	if clientCertificate.CertificateRef == nil || clientCertificate.KeyRef == nil {
+		return nil, fmt.Errorf("both certificateRef and keyRef must be set on clientCertificate attribute for cluster %s in namespace %s", nutanixCluster.Name, nutanixCluster.Namespace)
+	}
+	certPEM, err := n.getSecretKey(nutanixCluster.Namespace, clientCertificate.CertificateRef)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := n.getSecretKey(nutanixCluster.Namespace, clientCertificate.KeyRef)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate for cluster %s in namespace %s: %v", nutanixCluster.Name, nutanixCluster.Namespace, err)
+	}
+	return &cert, nil
+}
+
+// getSecretKey returns the value of the key of a secret
+func (n *NutanixClientHelper) getSecretKey(namespace string, selector *corev1.SecretKeySelector) ([]byte, error) {
+	secret, err := n.secretInformer.Lister().Secrets(namespace).Get(selector.Name)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return nil, fmt.Errorf("no %q data found in secret %s/%s", selector.Key, namespace, selector.Name)
+	}
+	return value, nil
+}
+
+// newProvider returns the env provider for the credential reference kind of the Prism endpoint
+func (n *NutanixClientHelper) newProvider(prismEndpoint credentialTypes.NutanixPrismEndpoint) envTypes.Provider {
+	// The providers format the address as host:port, which requires IPv6 addresses to be enclosed in brackets
+	prismEndpoint.Address = URLHost(prismEndpoint.Address)
+	if prismEndpoint.CredentialRef.Kind == FileCredentialKind {
+		return newFileProvider(prismEndpoint, CredentialFiles, n.configMapInformer)
+	}
+	return kubernetesEnv.NewProvider(
+		prismEndpoint,
+		n.secretInformer,
+		n.configMapInformer)
+}
+
+func (n *NutanixClientHelper) GetClient(ctx context.Context, cred prismgoclient.Credentials, additionalTrustBundle string, opts ...nutanixClientV3.ClientOption) (*nutanixClientV3.Client, error) {
 	if cred.Username == "" {
 		return nil, fmt.Errorf("could not create client because username was not set")
 	}
@@ -144,12 +323,18 @@ func (n *NutanixClientHelper) GetClient(cred prismgoclient.Credentials, addition
 		return nil, fmt.Errorf("could not create client because password was not set")
 	}
 	if cred.Port == "" {
-		cred.Port = defaultEndpointPort
+		cred.Port = strconv.Itoa(DefaultPrismCentralPort)
 	}
 	if cred.URL == "" {
-		cred.URL = fmt.Sprintf("%s:%s", cred.Endpoint, cred.Port)
+		cred.URL = JoinHostPort(cred.Endpoint, cred.Port)
 	}
-	clientOpts := make([]nutanixClientV3.ClientOption, 0)
+	// Every client gets its own transport, so the connection pooling limits apply per endpoint and the TLS settings
+	// of an endpoint are not applied to the shared default transport. A round tripper of the options takes precedence.
+	transport, err := NewTransport(nil, additionalTrustBundle, nil)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts := append([]nutanixClientV3.ClientOption{nutanixClientV3.WithRoundTripper(transport)}, opts...)
 	if additionalTrustBundle != "" {
 		clientOpts = append(clientOpts, nutanixClientV3.WithPEMEncodedCertBundle([]byte(additionalTrustBundle)))
 	}
@@ -159,7 +344,7 @@ func (n *NutanixClientHelper) GetClient(cred prismgoclient.Credentials, addition
 	}
 
 	// Check if the client is working
-	_, err = cli.V3.GetCurrentLoggedInUser(context.Background())
+	_, err = cli.V3.GetCurrentLoggedInUser(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -179,7 +364,11 @@ func (n *NutanixClientHelper) getManagerNutanixPrismEndpoint() (*credentialTypes
 	if npe.CredentialRef == nil {
 		return nil, fmt.Errorf("credentialRef must be set on CAPX manager")
 	}
-	return npe, nil
+	normalized, err := NormalizePrismEndpoint(*npe)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Prism endpoint of CAPX manager: %v", err)
+	}
+	return &normalized, nil
 }
 
 func (n *NutanixClientHelper) readEndpointConfig() ([]byte, error) {
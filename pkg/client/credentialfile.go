@@ -0,0 +1,229 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	envTypes "github.com/nutanix-cloud-native/prism-go-client/environment/types"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+)
+
+const (
+	// FileCredentialKind is the kind of a credential reference whose name is the path of a file holding the
+	// credentials, e.g. a mounted secret or a projected token. The file has the same format as the credentials
+	// key of a credential secret.
+	FileCredentialKind = credentialTypes.NutanixCredentialKind("File")
+
+	// DefaultCredentialFileRefreshInterval is the default interval after which credential files are read again
+	DefaultCredentialFileRefreshInterval = time.Minute
+)
+
+// CredentialFiles is the reader of the credential files shared by the controllers
+var CredentialFiles = NewCredentialFileReader(DefaultCredentialFileRefreshInterval)
+
+// CredentialFileReader reads credential files and keeps their content until the refresh interval passed. Only
+// credential files in its directory are read.
+type CredentialFileReader struct {
+	mu              sync.Mutex
+	refreshInterval time.Duration
+	// dir is the directory credential files must be located in. No credential file is read if it is not set.
+	dir   string
+	files map[string]*credentialFile
+	// now returns the current time and can be replaced in tests
+	now func() time.Time
+}
+
+type credentialFile struct {
+	data   []byte
+	readAt time.Time
+}
+
+func NewCredentialFileReader(refreshInterval time.Duration) *CredentialFileReader {
+	return &CredentialFileReader{
+		refreshInterval: refreshInterval,
+		files:           make(map[string]*credentialFile),
+		now:             time.Now,
+	}
+}
+
+// SetRefreshInterval sets the interval after which credential files are read again
+func (r *CredentialFileReader) SetRefreshInterval(refreshInterval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refreshInterval = refreshInterval
+}
+
+// SetDirectory sets the directory credential files must be located in. Credential files are rejected if the
+// directory is not set.
+func (r *CredentialFileReader) SetDirectory(dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dir = dir
+	r.files = make(map[string]*credentialFile)
+}
+
+// ValidatePath returns an error if the credential file at the given path is not located in the directory of the
+// reader. Symlinks of a missing file cannot be resolved, so only its cleaned path is checked.
+func (r *CredentialFileReader) ValidatePath(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := resolveCredentialFilePath(r.dir, path)
+	return err
+}
+
+// resolveCredentialFilePath returns the path of the credential file with its symlinks resolved, or an error if the
+// file is not located in the given directory
+func resolveCredentialFilePath(dir, path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path of credential file must be set")
+	}
+	if dir == "" {
+		return "", fmt.Errorf("credential files are disabled since no credential file directory is configured")
+	}
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("path of credential file %s must be absolute", path)
+	}
+	dir = filepath.Clean(dir)
+	resolvedPath := filepath.Clean(path)
+	if !isInDirectory(dir, resolvedPath) {
+		return "", fmt.Errorf("credential file %s is not located in the credential file directory %s", path, dir)
+	}
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the credential file directory %s: %v", dir, err)
+	}
+	if target, err := filepath.EvalSymlinks(resolvedPath); err == nil {
+		resolvedPath = target
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to resolve credential file %s: %v", path, err)
+	}
+	if !isInDirectory(resolvedDir, resolvedPath) && !isInDirectory(dir, resolvedPath) {
+		return "", fmt.Errorf("credential file %s resolves to %s outside of the credential file directory %s", path, resolvedPath, dir)
+	}
+	return resolvedPath, nil
+}
+
+// isInDirectory returns true if the cleaned path is located in the cleaned directory or one of its subdirectories
+func isInDirectory(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Read returns the content of the credential file at the given path. The file is only read again once the
+// refresh interval passed since it was last read. changed is true if the content differs from the previous read.
+// Files that are not located in the directory of the reader are rejected.
+func (r *CredentialFileReader) Read(path string) (data []byte, changed bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	resolvedPath, err := resolveCredentialFilePath(r.dir, path)
+	if err != nil {
+		return nil, false, err
+	}
+	now := r.now()
+	cached, ok := r.files[path]
+	if ok && now.Sub(cached.readAt) < r.refreshInterval {
+		return cached.data, false, nil
+	}
+	data, err = os.ReadFile(resolvedPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read credential file %s: %v", path, err)
+	}
+	changed = ok && !bytes.Equal(cached.data, data)
+	r.files[path] = &credentialFile{
+		data:   data,
+		readAt: now,
+	}
+	return data, changed, nil
+}
+
+// fileProvider is an environment provider reading the credentials of a Prism endpoint from a credential file
+type fileProvider struct {
+	prismEndpoint credentialTypes.NutanixPrismEndpoint
+	reader        *CredentialFileReader
+	cmInformer    coreinformers.ConfigMapInformer
+}
+
+func newFileProvider(prismEndpoint credentialTypes.NutanixPrismEndpoint, reader *CredentialFileReader, cmInformer coreinformers.ConfigMapInformer) envTypes.Provider {
+	return &fileProvider{
+		prismEndpoint: prismEndpoint,
+		reader:        reader,
+		cmInformer:    cmInformer,
+	}
+}
+
+func (prov *fileProvider) GetManagementEndpoint(_ envTypes.Topology) (*envTypes.ManagementEndpoint, error) {
+	data, _, err := prov.reader.Read(prov.prismEndpoint.CredentialRef.Name)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := credentialTypes.ParseCredentials(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credential file %s: %v", prov.prismEndpoint.CredentialRef.Name, err)
+	}
+	addr, err := url.Parse(fmt.Sprintf("https://%s", JoinHostPort(prov.prismEndpoint.Address, strconv.Itoa(int(prov.prismEndpoint.Port)))))
+	if err != nil {
+		return nil, err
+	}
+	trustBundle, err := prov.getAdditionalTrustBundle()
+	if err != nil {
+		return nil, err
+	}
+	return &envTypes.ManagementEndpoint{
+		Address:               addr,
+		Insecure:              prov.prismEndpoint.Insecure,
+		AdditionalTrustBundle: trustBundle,
+		ApiCredentials:        *creds,
+	}, nil
+}
+
+func (prov *fileProvider) getAdditionalTrustBundle() (string, error) {
+	trustBundleRef := prov.prismEndpoint.AdditionalTrustBundle
+	if trustBundleRef == nil {
+		return "", nil
+	}
+	if trustBundleRef.Kind == credentialTypes.NutanixTrustBundleKindString {
+		return trustBundleRef.Data, nil
+	}
+	cm, err := prov.cmInformer.Lister().ConfigMaps(trustBundleRef.Namespace).Get(trustBundleRef.Name)
+	if err != nil {
+		return "", err
+	}
+	if trustBundle, ok := cm.Data[TrustBundleConfigMapKey]; ok {
+		return trustBundle, nil
+	}
+	if trustBundle, ok := cm.BinaryData[TrustBundleConfigMapKey]; ok {
+		return string(trustBundle), nil
+	}
+	return "", nil
+}
+
+func (prov *fileProvider) Get(_ envTypes.Topology, _ string) (interface{}, error) {
+	return nil, envTypes.ErrNotFound
+}
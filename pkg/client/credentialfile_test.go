@@ -0,0 +1,176 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	envTypes "github.com/nutanix-cloud-native/prism-go-client/environment/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func credentialFileContent(username, password string) []byte {
+	return []byte(fmt.Sprintf(`[{"type": "basic_auth", "data": {"prismCentral": {"username": %q, "password": %q}}}]`, username, password))
+}
+
+func TestCredentialFileReader(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials")
+	assert.NoError(t, os.WriteFile(path, []byte("old"), 0o600))
+
+	now := time.Now()
+	reader := NewCredentialFileReader(time.Minute)
+	reader.SetDirectory(dir)
+	reader.now = func() time.Time { return now }
+
+	data, changed, err := reader.Read(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("old"), data)
+	assert.False(t, changed)
+
+	// The file is not read again before the refresh interval passed
+	assert.NoError(t, os.WriteFile(path, []byte("new"), 0o600))
+	now = now.Add(30 * time.Second)
+	data, changed, err = reader.Read(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("old"), data)
+	assert.False(t, changed)
+
+	now = now.Add(time.Minute)
+	data, changed, err = reader.Read(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("new"), data)
+	assert.True(t, changed)
+
+	// Reading the same content again is not a change
+	now = now.Add(time.Minute)
+	_, changed, err = reader.Read(path)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	_, _, err = reader.Read("")
+	assert.Error(t, err)
+	_, _, err = reader.Read(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestCredentialFileReaderDirectory(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials")
+	assert.NoError(t, os.WriteFile(path, []byte("credentials"), 0o600))
+	outside := filepath.Join(t.TempDir(), "outside")
+	assert.NoError(t, os.WriteFile(outside, []byte("secret"), 0o600))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+	// Mounted secrets link their files to a data directory next to them
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "..data"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "..data", "mounted"), []byte("mounted"), 0o600))
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "mounted"), filepath.Join(dir, "mounted")))
+
+	reader := NewCredentialFileReader(time.Minute)
+	_, _, err := reader.Read(path)
+	assert.Error(t, err, "credential files are rejected without directory")
+	assert.Error(t, reader.ValidatePath(path))
+
+	reader.SetDirectory(dir)
+	data, _, err := reader.Read(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("credentials"), data)
+	data, _, err = reader.Read(filepath.Join(dir, "mounted"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("mounted"), data)
+	assert.NoError(t, reader.ValidatePath(filepath.Join(dir, "not-mounted-yet")))
+
+	for _, rejected := range []string{
+		outside,
+		filepath.Join(dir, "..", filepath.Base(filepath.Dir(outside)), "outside"),
+		filepath.Join(dir, "link"),
+		dir,
+		"credentials",
+	} {
+		_, _, err := reader.Read(rejected)
+		assert.Error(t, err, rejected)
+		assert.Error(t, reader.ValidatePath(rejected), rejected)
+	}
+}
+
+func TestFileProviderGetManagementEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials")
+	assert.NoError(t, os.WriteFile(path, credentialFileContent("user", "old"), 0o600))
+
+	reader := NewCredentialFileReader(0)
+	reader.SetDirectory(dir)
+	prov := newFileProvider(credentialTypes.NutanixPrismEndpoint{
+		Address: "prism.example.com",
+		Port:    9440,
+		CredentialRef: &credentialTypes.NutanixCredentialReference{
+			Kind: FileCredentialKind,
+			Name: path,
+		},
+		AdditionalTrustBundle: &credentialTypes.NutanixTrustBundleReference{
+			Kind: credentialTypes.NutanixTrustBundleKindString,
+			Data: "bundle",
+		},
+	}, reader, nil)
+
+	me, err := prov.GetManagementEndpoint(envTypes.Topology{})
+	assert.NoError(t, err)
+	assert.Equal(t, "prism.example.com:9440", me.Address.Host)
+	assert.Equal(t, "bundle", me.AdditionalTrustBundle)
+	assert.Equal(t, "user", me.ApiCredentials.Username)
+	assert.Equal(t, "old", me.ApiCredentials.Password)
+
+	// Rotated credentials are picked up on the next read
+	assert.NoError(t, os.WriteFile(path, credentialFileContent("user", "new"), 0o600))
+	me, err = prov.GetManagementEndpoint(envTypes.Topology{})
+	assert.NoError(t, err)
+	assert.Equal(t, "new", me.ApiCredentials.Password)
+
+	assert.NoError(t, os.WriteFile(path, []byte("invalid"), 0o600))
+	_, err = prov.GetManagementEndpoint(envTypes.Topology{})
+	assert.Error(t, err)
+}
+
+func TestFileProviderGetManagementEndpointIPv6(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials")
+	assert.NoError(t, os.WriteFile(path, credentialFileContent("user", "password"), 0o600))
+	CredentialFiles.SetDirectory(dir)
+	t.Cleanup(func() { CredentialFiles.SetDirectory("") })
+
+	helper, err := NewNutanixClientHelper(nil, nil)
+	assert.NoError(t, err)
+	for _, address := range []string{"fd00::10", "[fd00::10]"} {
+		prov := helper.newProvider(credentialTypes.NutanixPrismEndpoint{
+			Address: address,
+			Port:    9440,
+			CredentialRef: &credentialTypes.NutanixCredentialReference{
+				Kind: FileCredentialKind,
+				Name: path,
+			},
+		})
+		me, err := prov.GetManagementEndpoint(envTypes.Topology{})
+		assert.NoError(t, err)
+		assert.Equal(t, "[fd00::10]:9440", me.Address.Host)
+		assert.Equal(t, "fd00::10", me.Address.Hostname())
+	}
+}
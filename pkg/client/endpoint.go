@@ -0,0 +1,128 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+)
+
+// DefaultPrismCentralPort is the port of Prism endpoints that do not set a port
+const DefaultPrismCentralPort = 9440
+
+// ErrInvalidPrismPort is returned by NormalizePrismEndpoint if the port of a Prism endpoint is invalid
+var ErrInvalidPrismPort = errors.New("invalid port")
+
+// NormalizePrismEndpoint returns the Prism endpoint with its address and port in the form used to connect to it. The
+// https scheme and any path are removed from the address. The port defaults to the port of an address with scheme, or
+// to 9440. Prism is always connected to over https, so other schemes are rejected, as are malformed addresses, ports
+// outside of 1-65535 and a port that differs from the port of the address.
+func NormalizePrismEndpoint(prismEndpoint credentialTypes.NutanixPrismEndpoint) (credentialTypes.NutanixPrismEndpoint, error) {
+	address := strings.TrimSpace(prismEndpoint.Address)
+	port := prismEndpoint.Port
+	if address == "" {
+		return prismEndpoint, fmt.Errorf("address is not set")
+	}
+	if strings.Contains(address, "://") {
+		u, err := url.Parse(address)
+		if err != nil {
+			return prismEndpoint, fmt.Errorf("address %s is not a valid URL: %v", address, err)
+		}
+		if !strings.EqualFold(u.Scheme, "https") {
+			return prismEndpoint, fmt.Errorf("scheme %s of address %s is not supported, only https is supported", u.Scheme, address)
+		}
+		if u.Port() != "" {
+			urlPort, err := strconv.ParseInt(u.Port(), 10, 32)
+			if err != nil || urlPort < 1 || urlPort > 65535 {
+				return prismEndpoint, fmt.Errorf("%w: port %s of address %s must be between 1 and 65535", ErrInvalidPrismPort, u.Port(), address)
+			}
+			if port != 0 && port != int32(urlPort) {
+				return prismEndpoint, fmt.Errorf("%w: port %d differs from port %d of address %s", ErrInvalidPrismPort, port, urlPort, address)
+			}
+			port = int32(urlPort)
+		}
+		address = u.Hostname()
+		if IsIPv6Host(address) {
+			address = URLHost(address)
+		}
+	}
+	if _, err := ParseHost(address); err != nil {
+		return prismEndpoint, err
+	}
+	if port == 0 {
+		port = DefaultPrismCentralPort
+	}
+	if port < 1 || port > 65535 {
+		return prismEndpoint, fmt.Errorf("%w: port %d must be between 1 and 65535", ErrInvalidPrismPort, port)
+	}
+	prismEndpoint.Address = address
+	prismEndpoint.Port = port
+	return prismEndpoint, nil
+}
+
+// ParseHost returns the host of an endpoint address without enclosing brackets. The address is a host name, an IPv4
+// address or an IPv6 address, optionally enclosed in brackets. An error is returned for malformed IPv6 addresses.
+func ParseHost(address string) (string, error) {
+	host := address
+	bracketed := strings.HasPrefix(address, "[") || strings.HasSuffix(address, "]")
+	if bracketed {
+		if !strings.HasPrefix(address, "[") || !strings.HasSuffix(address, "]") {
+			return "", fmt.Errorf("address %s has unbalanced brackets", address)
+		}
+		host = address[1 : len(address)-1]
+	}
+	if !bracketed && !strings.Contains(host, ":") {
+		return host, nil
+	}
+	if !IsIPv6Host(host) {
+		return "", fmt.Errorf("address %s is neither a host name nor a valid IPv4 or IPv6 address", address)
+	}
+	return host, nil
+}
+
+// IsIPv6Host returns true if the host is an IPv6 address, optionally enclosed in brackets
+func IsIPv6Host(host string) bool {
+	host = trimBrackets(host)
+	return strings.Contains(host, ":") && net.ParseIP(host) != nil
+}
+
+// URLHost returns the host as used in the host part of a URL, i.e. IPv6 addresses are enclosed in brackets
+func URLHost(host string) string {
+	if IsIPv6Host(host) && !strings.HasPrefix(host, "[") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// JoinHostPort returns the host and port of an endpoint formatted as host:port. IPv6 addresses are enclosed in
+// brackets, regardless of whether the host is already enclosed in brackets.
+func JoinHostPort(host, port string) string {
+	return net.JoinHostPort(trimBrackets(host), port)
+}
+
+func trimBrackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
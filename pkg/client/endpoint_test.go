@@ -0,0 +1,119 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"errors"
+	"testing"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHost(t *testing.T) {
+	tests := []struct {
+		address  string
+		wantHost string
+		wantErr  bool
+	}{
+		{address: "prism.example.com", wantHost: "prism.example.com"},
+		{address: "10.0.0.1", wantHost: "10.0.0.1"},
+		{address: "fd00::10", wantHost: "fd00::10"},
+		{address: "[fd00::10]", wantHost: "fd00::10"},
+		{address: "::ffff:10.0.0.1", wantHost: "::ffff:10.0.0.1"},
+		{address: "[fd00::10", wantErr: true},
+		{address: "fd00::10]", wantErr: true},
+		{address: "[10.0.0.1]", wantErr: true},
+		{address: "[prism.example.com]", wantErr: true},
+		{address: "fd00::10::1", wantErr: true},
+		{address: "prism.example.com:9440", wantErr: true},
+		{address: "fe80::1%eth0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			host, err := ParseHost(tt.address)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantHost, host)
+		})
+	}
+}
+
+func TestURLHost(t *testing.T) {
+	assert.Equal(t, "prism.example.com", URLHost("prism.example.com"))
+	assert.Equal(t, "10.0.0.1", URLHost("10.0.0.1"))
+	assert.Equal(t, "[fd00::10]", URLHost("fd00::10"))
+	assert.Equal(t, "[fd00::10]", URLHost("[fd00::10]"))
+}
+
+func TestJoinHostPort(t *testing.T) {
+	assert.Equal(t, "prism.example.com:9440", JoinHostPort("prism.example.com", "9440"))
+	assert.Equal(t, "10.0.0.1:9440", JoinHostPort("10.0.0.1", "9440"))
+	assert.Equal(t, "[fd00::10]:9440", JoinHostPort("fd00::10", "9440"))
+	assert.Equal(t, "[fd00::10]:9440", JoinHostPort("[fd00::10]", "9440"))
+}
+
+func TestNormalizePrismEndpoint(t *testing.T) {
+	tests := []struct {
+		name        string
+		address     string
+		port        int32
+		wantAddress string
+		wantPort    int32
+		wantErr     bool
+		invalidPort bool
+	}{
+		{name: "missing port", address: "prism.example.com", wantAddress: "prism.example.com", wantPort: 9440},
+		{name: "explicit port", address: "prism.example.com", port: 9441, wantAddress: "prism.example.com", wantPort: 9441},
+		{name: "address with scheme", address: "https://prism.example.com", wantAddress: "prism.example.com", wantPort: 9440},
+		{name: "address with scheme and path", address: "https://prism.example.com/console/", port: 9440, wantAddress: "prism.example.com", wantPort: 9440},
+		{name: "address with scheme and port", address: "https://prism.example.com:9441", wantAddress: "prism.example.com", wantPort: 9441},
+		{name: "address with scheme and same port", address: "HTTPS://prism.example.com:9441", port: 9441, wantAddress: "prism.example.com", wantPort: 9441},
+		{name: "IPv6 address with scheme", address: "https://[fd00::10]:9440", wantAddress: "[fd00::10]", wantPort: 9440},
+		{name: "surrounding whitespace", address: " 10.0.0.1 ", wantAddress: "10.0.0.1", wantPort: 9440},
+		{name: "address with scheme and different port", address: "https://prism.example.com:9441", port: 9440, wantErr: true, invalidPort: true},
+		{name: "http scheme", address: "http://prism.example.com", wantErr: true},
+		{name: "port out of range", address: "prism.example.com", port: 65536, wantErr: true, invalidPort: true},
+		{name: "negative port", address: "prism.example.com", port: -1, wantErr: true, invalidPort: true},
+		{name: "port of address out of range", address: "https://prism.example.com:0", wantErr: true, invalidPort: true},
+		{name: "address with port without scheme", address: "prism.example.com:9440", wantErr: true},
+		{name: "missing address", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoint := credentialTypes.NutanixPrismEndpoint{
+				Address:       tt.address,
+				Port:          tt.port,
+				CredentialRef: &credentialTypes.NutanixCredentialReference{Name: "creds"},
+			}
+			normalized, err := NormalizePrismEndpoint(endpoint)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Equal(t, tt.invalidPort, errors.Is(err, ErrInvalidPrismPort))
+				assert.Equal(t, endpoint, normalized)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantAddress, normalized.Address)
+			assert.Equal(t, tt.wantPort, normalized.Port)
+			assert.Equal(t, endpoint.CredentialRef, normalized.CredentialRef)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+)
+
+const (
+	// listPageSize is the number of entities requested per page of a Prism Central list call
+	listPageSize = 250
+	// maxListPages is the maximum number of pages of a Prism Central list call. It guards against Prism Central
+	// reporting more matches than it returns, which would otherwise page forever.
+	maxListPages = 200
+)
+
+// ErrListTooLarge is returned if a Prism Central list call did not complete within the maximum number of pages
+var ErrListTooLarge = errors.New("list exceeds the maximum number of pages")
+
+// listPage returns the entities of the page at the given offset and the total number of matches, or nil if Prism
+// Central did not report it
+type listPage[T any] func(ctx context.Context, offset, length int64) ([]T, *int64, error)
+
+// listAllPages requests the pages of a list call until all entities are collected. The list is complete once the
+// reported total number of matches was collected, or, if no total is reported, once a page is not full. An empty page
+// always ends the list.
+func listAllPages[T any](ctx context.Context, kind string, list listPage[T]) ([]T, error) {
+	entities := make([]T, 0)
+	for page := 0; page < maxListPages; page++ {
+		pageEntities, totalMatches, err := list(ctx, int64(len(entities)), listPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s at offset %d: %w", kind, len(entities), err)
+		}
+		entities = append(entities, pageEntities...)
+		if len(pageEntities) == 0 {
+			return entities, nil
+		}
+		if totalMatches != nil {
+			if int64(len(entities)) >= *totalMatches {
+				return entities, nil
+			}
+			continue
+		}
+		if len(pageEntities) < listPageSize {
+			return entities, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to list %s: %w (%d pages of %d entities)", kind, ErrListTooLarge, maxListPages, listPageSize)
+}
+
+// listMetadata returns the metadata requesting a page of entities of the given kind matching the filter. An empty
+// filter matches all entities.
+func listMetadata(kind, filter string, offset, length int64) *nutanixClientV3.DSMetadata {
+	metadata := &nutanixClientV3.DSMetadata{
+		Kind:   utils.StringPtr(kind),
+		Offset: utils.Int64Ptr(offset),
+		Length: utils.Int64Ptr(length),
+	}
+	if filter != "" {
+		metadata.Filter = utils.StringPtr(filter)
+	}
+	return metadata
+}
+
+// totalMatches returns the total number of matches of list metadata, or nil if it is not reported
+func totalMatches(metadata *nutanixClientV3.ListMetadataOutput) *int64 {
+	if metadata == nil {
+		return nil
+	}
+	return metadata.TotalMatches
+}
+
+// ListAllVMs returns all VMs matching the filter
+func ListAllVMs(ctx context.Context, client *nutanixClientV3.Client, filter string) ([]*nutanixClientV3.VMIntentResource, error) {
+	return listAllPages(ctx, "VMs", func(ctx context.Context, offset, length int64) ([]*nutanixClientV3.VMIntentResource, *int64, error) {
+		res, err := client.V3.ListVM(ctx, listMetadata("vm", filter, offset, length))
+		if err != nil {
+			return nil, nil, err
+		}
+		return res.Entities, totalMatches(res.Metadata), nil
+	})
+}
+
+// ListAllSubnets returns all subnets matching the filter
+func ListAllSubnets(ctx context.Context, client *nutanixClientV3.Client, filter string) ([]*nutanixClientV3.SubnetIntentResponse, error) {
+	return listAllPages(ctx, "subnets", func(ctx context.Context, offset, length int64) ([]*nutanixClientV3.SubnetIntentResponse, *int64, error) {
+		res, err := client.V3.ListSubnet(ctx, listMetadata("subnet", filter, offset, length))
+		if err != nil {
+			return nil, nil, err
+		}
+		return res.Entities, totalMatches(res.Metadata), nil
+	})
+}
+
+// ListAllImages returns all images matching the filter
+func ListAllImages(ctx context.Context, client *nutanixClientV3.Client, filter string) ([]*nutanixClientV3.ImageIntentResponse, error) {
+	return listAllPages(ctx, "images", func(ctx context.Context, offset, length int64) ([]*nutanixClientV3.ImageIntentResponse, *int64, error) {
+		res, err := client.V3.ListImage(ctx, listMetadata("image", filter, offset, length))
+		if err != nil {
+			return nil, nil, err
+		}
+		return res.Entities, totalMatches(res.Metadata), nil
+	})
+}
+
+// ListAllClusters returns all clusters matching the filter, including Prism Central
+func ListAllClusters(ctx context.Context, client *nutanixClientV3.Client, filter string) ([]*nutanixClientV3.ClusterIntentResponse, error) {
+	return listAllPages(ctx, "clusters", func(ctx context.Context, offset, length int64) ([]*nutanixClientV3.ClusterIntentResponse, *int64, error) {
+		res, err := client.V3.ListCluster(ctx, listMetadata("cluster", filter, offset, length))
+		if err != nil {
+			return nil, nil, err
+		}
+		return res.Entities, totalMatches(res.Metadata), nil
+	})
+}
+
+// ListAllHosts returns all hosts
+func ListAllHosts(ctx context.Context, client *nutanixClientV3.Client) ([]*nutanixClientV3.HostResponse, error) {
+	return listAllPages(ctx, "hosts", func(ctx context.Context, offset, length int64) ([]*nutanixClientV3.HostResponse, *int64, error) {
+		res, err := client.V3.ListHost(ctx, listMetadata("host", "", offset, length))
+		if err != nil {
+			return nil, nil, err
+		}
+		return res.Entities, totalMatches(res.Metadata), nil
+	})
+}
+
+// ListAllProjects returns all projects matching the filter
+func ListAllProjects(ctx context.Context, client *nutanixClientV3.Client, filter string) ([]*nutanixClientV3.Project, error) {
+	return listAllPages(ctx, "projects", func(ctx context.Context, offset, length int64) ([]*nutanixClientV3.Project, *int64, error) {
+		res, err := client.V3.ListProject(ctx, listMetadata("project", filter, offset, length))
+		if err != nil {
+			return nil, nil, err
+		}
+		return res.Entities, totalMatches(res.Metadata), nil
+	})
+}
+
+// ListAllUsers returns all users matching the filter
+func ListAllUsers(ctx context.Context, client *nutanixClientV3.Client, filter string) ([]*nutanixClientV3.UserIntentResponse, error) {
+	return listAllPages(ctx, "users", func(ctx context.Context, offset, length int64) ([]*nutanixClientV3.UserIntentResponse, *int64, error) {
+		res, err := client.V3.ListUser(ctx, listMetadata("user", filter, offset, length))
+		if err != nil {
+			return nil, nil, err
+		}
+		return res.Entities, totalMatches(res.Metadata), nil
+	})
+}
+
+// ListAllVolumeGroups returns all volume groups matching the filter
+func ListAllVolumeGroups(ctx context.Context, client *nutanixClientV3.Client, filter string) ([]*nutanixClientV3.VolumeGroupResponse, error) {
+	return listAllPages(ctx, "volume groups", func(ctx context.Context, offset, length int64) ([]*nutanixClientV3.VolumeGroupResponse, *int64, error) {
+		res, err := client.V3.ListVolumeGroup(ctx, listMetadata("volume_group", filter, offset, length))
+		if err != nil {
+			return nil, nil, err
+		}
+		return res.Entities, totalMatches(res.Metadata), nil
+	})
+}
+
+// ListAllCategoryValues returns all values of the category key
+func ListAllCategoryValues(ctx context.Context, client *nutanixClientV3.Client, key string) ([]*nutanixClientV3.CategoryValueStatus, error) {
+	return listAllPages(ctx, "values of category "+key, func(ctx context.Context, offset, length int64) ([]*nutanixClientV3.CategoryValueStatus, *int64, error) {
+		res, err := client.V3.ListCategoryValues(ctx, key, &nutanixClientV3.CategoryListMetadata{
+			Kind:   utils.StringPtr("category"),
+			Offset: utils.Int64Ptr(offset),
+			Length: utils.Int64Ptr(length),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if res.Metadata == nil {
+			return res.Entities, nil, nil
+		}
+		return res.Entities, res.Metadata.TotalMatches, nil
+	})
+}
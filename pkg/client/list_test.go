@@ -0,0 +1,180 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+// pagedListTestService is a Prism v3 service returning its subnets, images and category values in pages and recording
+// the requested offsets
+type pagedListTestService struct {
+	nutanixClientV3.Service
+	count int
+	// reportTotal makes the service report the total number of matches
+	reportTotal bool
+	// totalMatches overrides the reported total number of matches if set
+	totalMatches int64
+	offsets      []int64
+	err          error
+}
+
+// page returns the names of the entities of the requested page
+func (s *pagedListTestService) page(offset, length *int64) ([]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.offsets = append(s.offsets, utils.Int64Value(offset))
+	names := make([]string, 0)
+	for i := utils.Int64Value(offset); i < utils.Int64Value(offset)+utils.Int64Value(length) && i < int64(s.count); i++ {
+		names = append(names, fmt.Sprintf("entity-%d", i))
+	}
+	return names, nil
+}
+
+func (s *pagedListTestService) listMetadata() *nutanixClientV3.ListMetadataOutput {
+	if !s.reportTotal {
+		return nil
+	}
+	total := int64(s.count)
+	if s.totalMatches != 0 {
+		total = s.totalMatches
+	}
+	return &nutanixClientV3.ListMetadataOutput{TotalMatches: utils.Int64Ptr(total)}
+}
+
+func (s *pagedListTestService) ListSubnet(_ context.Context, getEntitiesRequest *nutanixClientV3.DSMetadata) (*nutanixClientV3.SubnetListIntentResponse, error) {
+	names, err := s.page(getEntitiesRequest.Offset, getEntitiesRequest.Length)
+	if err != nil {
+		return nil, err
+	}
+	res := &nutanixClientV3.SubnetListIntentResponse{Metadata: s.listMetadata()}
+	for _, name := range names {
+		res.Entities = append(res.Entities, &nutanixClientV3.SubnetIntentResponse{Spec: &nutanixClientV3.Subnet{Name: utils.StringPtr(name)}})
+	}
+	return res, nil
+}
+
+func (s *pagedListTestService) ListImage(_ context.Context, getEntitiesRequest *nutanixClientV3.DSMetadata) (*nutanixClientV3.ImageListIntentResponse, error) {
+	names, err := s.page(getEntitiesRequest.Offset, getEntitiesRequest.Length)
+	if err != nil {
+		return nil, err
+	}
+	res := &nutanixClientV3.ImageListIntentResponse{Metadata: s.listMetadata()}
+	for _, name := range names {
+		res.Entities = append(res.Entities, &nutanixClientV3.ImageIntentResponse{Spec: &nutanixClientV3.Image{Name: utils.StringPtr(name)}})
+	}
+	return res, nil
+}
+
+func (s *pagedListTestService) ListCategoryValues(_ context.Context, _ string, getEntitiesRequest *nutanixClientV3.CategoryListMetadata) (*nutanixClientV3.CategoryValueListResponse, error) {
+	names, err := s.page(getEntitiesRequest.Offset, getEntitiesRequest.Length)
+	if err != nil {
+		return nil, err
+	}
+	res := &nutanixClientV3.CategoryValueListResponse{}
+	if metadata := s.listMetadata(); metadata != nil {
+		res.Metadata = &nutanixClientV3.CategoryListMetadata{TotalMatches: metadata.TotalMatches}
+	}
+	for _, name := range names {
+		res.Entities = append(res.Entities, &nutanixClientV3.CategoryValueStatus{Value: utils.StringPtr(name)})
+	}
+	return res, nil
+}
+
+func TestListAllSubnets(t *testing.T) {
+	tests := []struct {
+		name            string
+		service         *pagedListTestService
+		expectedCount   int
+		expectedOffsets []int64
+		expectedErr     string
+	}{
+		{
+			name:            "multiple pages with total",
+			service:         &pagedListTestService{count: 2*listPageSize + 10, reportTotal: true},
+			expectedCount:   2*listPageSize + 10,
+			expectedOffsets: []int64{0, listPageSize, 2 * listPageSize},
+		},
+		{
+			name:            "multiple pages without total",
+			service:         &pagedListTestService{count: 2 * listPageSize},
+			expectedCount:   2 * listPageSize,
+			expectedOffsets: []int64{0, listPageSize, 2 * listPageSize},
+		},
+		{
+			name:            "single page",
+			service:         &pagedListTestService{count: 3, reportTotal: true},
+			expectedCount:   3,
+			expectedOffsets: []int64{0},
+		},
+		{
+			name:            "total larger than the returned entities",
+			service:         &pagedListTestService{count: listPageSize + 1, reportTotal: true, totalMatches: 5 * listPageSize},
+			expectedCount:   listPageSize + 1,
+			expectedOffsets: []int64{0, listPageSize, listPageSize + 1},
+		},
+		{
+			name:        "exceeding the maximum number of pages",
+			service:     &pagedListTestService{count: maxListPages*listPageSize + 1},
+			expectedErr: "failed to list subnets: list exceeds the maximum number of pages",
+		},
+		{
+			name:        "error listing a page",
+			service:     &pagedListTestService{err: errors.New("unavailable")},
+			expectedErr: "failed to list subnets at offset 0: unavailable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &nutanixClientV3.Client{V3: tt.service}
+			subnets, err := ListAllSubnets(context.Background(), client, "")
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, subnets, tt.expectedCount)
+			assert.Equal(t, tt.expectedOffsets, tt.service.offsets)
+			// Entities are collected in order and without duplicates
+			for i, subnet := range subnets {
+				assert.Equal(t, fmt.Sprintf("entity-%d", i), *subnet.Spec.Name)
+			}
+		})
+	}
+}
+
+func TestListAllImagesAndCategoryValues(t *testing.T) {
+	ctx := context.Background()
+	service := &pagedListTestService{count: listPageSize + 1, reportTotal: true}
+	client := &nutanixClientV3.Client{V3: service}
+
+	images, err := ListAllImages(ctx, client, "name==image")
+	assert.NoError(t, err)
+	assert.Len(t, images, listPageSize+1)
+
+	values, err := ListAllCategoryValues(ctx, client, "key")
+	assert.NoError(t, err)
+	assert.Len(t, values, listPageSize+1)
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Keys of the structured logging fields used to correlate the log lines of a VM across reconciles
+const (
+	// LogKeyCluster is the name of the CAPI cluster
+	LogKeyCluster = "cluster"
+	// LogKeyMachine is the name of the CAPI machine
+	LogKeyMachine = "machine"
+	// LogKeyVMUUID is the UUID of the VM in Prism Central
+	LogKeyVMUUID = "vmUUID"
+	// LogKeyTaskUUID is the UUID of the Prism Central task that is waited for
+	LogKeyTaskUUID = "taskUUID"
+)
+
+// WithLogValues returns a copy of the context whose logger carries the given key/value pairs
+func WithLogValues(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	return ctrl.LoggerInto(ctx, ctrl.LoggerFrom(ctx).WithValues(keysAndValues...))
+}
@@ -18,64 +18,354 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"github.com/nutanix-cloud-native/prism-go-client/utils"
 	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
 )
 
-type stateRefreshFunc func() (string, error)
+const (
+	defaultPollInterval = 2 * time.Second
+	defaultPollTimeout  = 30 * time.Minute
 
+	taskStateSucceeded = "SUCCEEDED"
+
+	imageStateError = "ERROR"
+
+	volumeGroupStateComplete = "COMPLETE"
+	volumeGroupAttached      = "ATTACHED"
+	volumeGroupDetached      = "DETACHED"
+)
+
+var (
+	// ErrTaskFailed is returned if a task failed or does not exist. Retrying the same request is not expected to succeed.
+	ErrTaskFailed = errors.New("task failed")
+	// ErrTaskInvalidUUID is returned if Prism Central does not know the UUID of a task. It wraps ErrTaskFailed.
+	ErrTaskInvalidUUID = fmt.Errorf("%w: invalid task UUID", ErrTaskFailed)
+)
+
+// taskInvalidUUIDGracePeriod is the grace period of tasks with an invalid UUID applied if the wait options set none
+var taskInvalidUUIDGracePeriod atomic.Int64
+
+// SetTaskInvalidUUIDGracePeriod sets the time from the start of waiting for a task within which Prism Central reporting
+// the UUID of the task as invalid is retried, unless the wait options set a grace period. Tasks with an invalid UUID
+// fail right away if set to 0.
+func SetTaskInvalidUUIDGracePeriod(gracePeriod time.Duration) {
+	taskInvalidUUIDGracePeriod.Store(int64(gracePeriod))
+}
+
+// imageReadyStates are the states of an image that can be used to create VMs
+var imageReadyStates = []string{"COMPLETE", "ACTIVE"}
+
+type progressRefreshFunc func() (state string, percentageComplete int64, err error)
+
+// ProgressFunc is invoked after every poll with the current state of the polled entity and the completion
+// percentage of the running operation. The percentage is -1 if it is unknown.
+type ProgressFunc func(state string, percentageComplete int64)
+
+// WaitOptions configures how often and how long the state of an entity is polled.
+type WaitOptions struct {
+	// Interval between two polls. Defaults to 2 seconds.
+	Interval time.Duration
+	// Timeout after which waiting is aborted with wait.ErrWaitTimeout. Defaults to 30 minutes.
+	Timeout time.Duration
+	// OnProgress is invoked after every poll if set.
+	OnProgress ProgressFunc
+	// Clock measures the timeout and the interval between two polls. Defaults to the real clock.
+	Clock clock.Clock
+	// InvalidUUIDGracePeriod is the time from the start of waiting for a task within which Prism Central reporting the
+	// UUID of the task as invalid is retried, e.g. since the task is not known yet while Prism Central restarts.
+	// Defaults to the grace period set with SetTaskInvalidUUIDGracePeriod.
+	InvalidUUIDGracePeriod time.Duration
+}
+
+// invalidUUIDGracePeriod returns the grace period of tasks with an invalid UUID of the options
+func (o WaitOptions) invalidUUIDGracePeriod() time.Duration {
+	if o.InvalidUUIDGracePeriod > 0 {
+		return o.InvalidUUIDGracePeriod
+	}
+	return time.Duration(taskInvalidUUIDGracePeriod.Load())
+}
+
+// clock returns the clock of the options, or the real clock if none is set
+func (o WaitOptions) clock() clock.Clock {
+	if o.Clock == nil {
+		return clock.RealClock{}
+	}
+	return o.Clock
+}
+
+// WaitForTaskCompletion waits until the task with the given UUID succeeded. Returns an error if the task failed or
+// the context is done before the task succeeded.
 func WaitForTaskCompletion(ctx context.Context, conn *nutanixClientV3.Client, uuid string) error {
-	errCh := make(chan error, 1)
-	go waitForState(
-		errCh,
-		"SUCCEEDED",
-		waitUntilTaskStateFunc(ctx, conn, uuid))
+	return WaitForTaskToSucceed(ctx, conn, uuid, WaitOptions{})
+}
 
-	err := <-errCh
+// WaitForTaskToSucceed waits until the task with the given UUID succeeded. If the options set a timeout, waiting is
+// aborted with an error wrapping wait.ErrWaitTimeout once the task has been running for longer than the timeout,
+// counted from the creation of the task. The task is waited for without timeout otherwise. An invalid task UUID is
+// retried within the grace period of the options. Returns an error if the task failed or the context is done before
+// the task succeeded.
+func WaitForTaskToSucceed(ctx context.Context, conn *nutanixClientV3.Client, uuid string, opts WaitOptions) error {
+	ctx = WithLogValues(ctx, LogKeyTaskUUID, uuid)
+	log := ctrl.LoggerFrom(ctx)
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	clk := opts.clock()
+	waitStarted := clk.Now()
+	started := waitStarted
+	invalidUUIDGracePeriod := opts.invalidUUIDGracePeriod()
+	err := pollImmediate(ctx, clk, interval, 0, func(ctx context.Context) (bool, error) {
+		task, err := getTask(ctx, conn, uuid)
+		if errors.Is(err, ErrTaskInvalidUUID) && clk.Since(waitStarted) < invalidUUIDGracePeriod {
+			log.V(1).Info("Task UUID is not known to Prism Central yet. Retrying", "gracePeriod", invalidUUIDGracePeriod)
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if *task.Status == taskStateSucceeded {
+			return true, nil
+		}
+		if opts.Timeout > 0 {
+			if task.CreationTime != nil {
+				started = *task.CreationTime
+			}
+			if clk.Since(started) > opts.Timeout {
+				return false, fmt.Errorf("task with UUID %s did not succeed within %s: %w", uuid, opts.Timeout, wait.ErrWaitTimeout)
+			}
+		}
+		return false, nil
+	})
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("stopped waiting for task with UUID %s: %w", uuid, ctxErr)
+	}
 	return err
 }
 
-func waitForState(errCh chan<- error, target string, refresh stateRefreshFunc) {
-	err := Retry(2, 2, 0, func(_ uint) (bool, error) {
-		state, err := refresh()
+// WaitForTasksToSucceed waits concurrently until all tasks with the given UUIDs succeeded. Returns an aggregate of the
+// errors of all tasks that failed or did not succeed before the context is done.
+func WaitForTasksToSucceed(ctx context.Context, conn *nutanixClientV3.Client, uuids []string) error {
+	errs := make([]error, len(uuids))
+	wg := sync.WaitGroup{}
+	for i, uuid := range uuids {
+		wg.Add(1)
+		go func(i int, uuid string) {
+			defer wg.Done()
+			if err := WaitForTaskCompletion(ctx, conn, uuid); err != nil {
+				errs[i] = fmt.Errorf("task with UUID %s did not succeed: %w", uuid, err)
+			}
+		}(i, uuid)
+	}
+	wg.Wait()
+	return kerrors.NewAggregate(errs)
+}
+
+// WaitForImageReady waits until the image with the given UUID is ready to be used. Returns wait.ErrWaitTimeout if
+// the image did not become ready before the timeout.
+func WaitForImageReady(ctx context.Context, conn *nutanixClientV3.Client, imageUUID string, opts WaitOptions) error {
+	return waitForStateWithProgress(ctx, imageReadyStates, imageProgressFunc(ctx, conn, imageUUID), opts)
+}
+
+// GetImageProgress returns the state of the image with the given UUID, and the completion percentage of the task
+// processing the image, or -1 if it is unknown. Returns an error if the image is in the error state.
+func GetImageProgress(ctx context.Context, conn *nutanixClientV3.Client, imageUUID string) (string, int64, error) {
+	return imageProgressFunc(ctx, conn, imageUUID)()
+}
+
+// IsImageReady returns true if an image in the given state can be used to create VMs
+func IsImageReady(state string) bool {
+	for _, readyState := range imageReadyStates {
+		if state == readyState {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForStateWithProgress polls the refresh function until it returns one of the target states.
+func waitForStateWithProgress(ctx context.Context, targets []string, refresh progressRefreshFunc, opts WaitOptions) error {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultPollTimeout
+	}
+	return pollImmediate(ctx, opts.clock(), interval, timeout, func(_ context.Context) (bool, error) {
+		state, percentageComplete, err := refresh()
 		if err != nil {
 			return false, err
-		} else if state == target {
-			return true, nil
+		}
+		if opts.OnProgress != nil {
+			opts.OnProgress(state, percentageComplete)
+		}
+		for _, target := range targets {
+			if state == target {
+				return true, nil
+			}
 		}
 		return false, nil
 	})
-	errCh <- err
 }
 
-func waitUntilTaskStateFunc(ctx context.Context, conn *nutanixClientV3.Client, uuid string) stateRefreshFunc {
-	return func() (string, error) {
-		return GetTaskState(ctx, conn, uuid)
+// pollImmediate invokes the condition right away and then in the given interval of the clock until it is done or
+// returns an error. Polling is aborted with wait.ErrWaitTimeout once the timeout expired, if it is positive, or the
+// context is done.
+func pollImmediate(ctx context.Context, clk clock.Clock, interval, timeout time.Duration, condition wait.ConditionWithContextFunc) error {
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		timeoutTimer := clk.NewTimer(timeout)
+		defer timeoutTimer.Stop()
+		timeoutC = timeoutTimer.C()
+	}
+	for {
+		done, err := condition(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		intervalTimer := clk.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			intervalTimer.Stop()
+			return wait.ErrWaitTimeout
+		case <-timeoutC:
+			intervalTimer.Stop()
+			return wait.ErrWaitTimeout
+		case <-intervalTimer.C():
+		}
+	}
+}
+
+// WaitForVolumeGroupAttachment waits until the VM with the given UUID is attached to the volume group with the given
+// UUID, or detached from it if attached is false. Returns wait.ErrWaitTimeout if the attachment did not change before the timeout.
+func WaitForVolumeGroupAttachment(ctx context.Context, conn *nutanixClientV3.Client, vgUUID, vmUUID string, attached bool, opts WaitOptions) error {
+	target := volumeGroupDetached
+	if attached {
+		target = volumeGroupAttached
+	}
+	return waitForStateWithProgress(ctx, []string{target}, volumeGroupAttachmentFunc(ctx, conn, vgUUID, vmUUID), opts)
+}
+
+// IsVMAttachedToVolumeGroup returns true if the VM with the given UUID is in the attachment list of the volume group resources
+func IsVMAttachedToVolumeGroup(resources *nutanixClientV3.VolumeGroupResources, vmUUID string) bool {
+	if resources == nil {
+		return false
+	}
+	for _, attachment := range resources.AttachmentList {
+		if attachment != nil && attachment.VMReference != nil && utils.StringValue(attachment.VMReference.UUID) == vmUUID {
+			return true
+		}
+	}
+	return false
+}
+
+func volumeGroupAttachmentFunc(ctx context.Context, conn *nutanixClientV3.Client, vgUUID, vmUUID string) progressRefreshFunc {
+	return func() (string, int64, error) {
+		vg, err := conn.V3.GetVolumeGroup(ctx, vgUUID)
+		if err != nil {
+			return "", -1, fmt.Errorf("error occurred while waiting for volume group with UUID %s: %v", vgUUID, err)
+		}
+		if vg.Status == nil || utils.StringValue(vg.Status.State) != volumeGroupStateComplete {
+			return "", -1, nil
+		}
+		if IsVMAttachedToVolumeGroup(vg.Status.Resources, vmUUID) {
+			return volumeGroupAttached, -1, nil
+		}
+		return volumeGroupDetached, -1, nil
+	}
+}
+
+func imageProgressFunc(ctx context.Context, conn *nutanixClientV3.Client, imageUUID string) progressRefreshFunc {
+	return func() (string, int64, error) {
+		log := ctrl.LoggerFrom(ctx)
+		image, err := conn.V3.GetImage(ctx, imageUUID)
+		if err != nil {
+			return "", -1, fmt.Errorf("error occurred while waiting for image with UUID %s: %v", imageUUID, err)
+		}
+		if image.Status == nil || image.Status.State == nil {
+			return "", -1, nil
+		}
+		state := *image.Status.State
+		if state == imageStateError {
+			messages := make([]string, 0)
+			for _, m := range image.Status.MessageList {
+				if m != nil {
+					messages = append(messages, utils.StringValue(m.Message))
+				}
+			}
+			return state, -1, fmt.Errorf("image with UUID %s is in state %s: %s", imageUUID, state, strings.Join(messages, ", "))
+		}
+		// The completion percentage is only known if the image is still being processed by a task
+		percentageComplete := int64(-1)
+		if image.Status.ExecutionContext != nil {
+			if taskUUID, ok := image.Status.ExecutionContext.TaskUUID.(string); ok && taskUUID != "" {
+				task, err := conn.V3.GetTask(ctx, taskUUID)
+				if err != nil {
+					log.V(1).Info(fmt.Sprintf("failed to get progress of task %s for image %s: %v", taskUUID, imageUUID, err))
+				} else if task.PercentageComplete != nil {
+					percentageComplete = *task.PercentageComplete
+				}
+			}
+		}
+		log.V(1).Info(fmt.Sprintf("Image with UUID %s is in state %s (%d%%)", imageUUID, state, percentageComplete))
+		return state, percentageComplete, nil
 	}
 }
 
+// GetTaskState returns the state of the task with the given UUID. Returns an error if the task failed.
 func GetTaskState(ctx context.Context, client *nutanixClientV3.Client, taskUUID string) (string, error) {
+	return getTaskState(WithLogValues(ctx, LogKeyTaskUUID, taskUUID), client, taskUUID)
+}
+
+// getTaskState returns the state of the task with the given UUID. The logger of the context is expected to carry the
+// task UUID.
+func getTaskState(ctx context.Context, client *nutanixClientV3.Client, taskUUID string) (string, error) {
+	task, err := getTask(ctx, client, taskUUID)
+	if task == nil {
+		return "", err
+	}
+	return *task.Status, err
+}
+
+// getTask returns the task with the given UUID. Returns the task and an error if the task failed. The logger of the
+// context is expected to carry the task UUID.
+func getTask(ctx context.Context, client *nutanixClientV3.Client, taskUUID string) (*nutanixClientV3.TasksResponse, error) {
 	log := ctrl.LoggerFrom(ctx)
-	log.V(1).Info(fmt.Sprintf("Getting task with UUID %s", taskUUID))
+	log.V(1).Info("Getting task")
 	v, err := client.V3.GetTask(ctx, taskUUID)
 	if err != nil {
-		log.Error(err, fmt.Sprintf("error occurred while waiting for task with UUID %s", taskUUID))
-		return "", err
+		log.Error(err, "error occurred while getting task")
+		return nil, err
 	}
 
-	if *v.Status == "INVALID_UUID" || *v.Status == "FAILED" {
-		return *v.Status,
-			fmt.Errorf("error_detail: %s, progress_message: %s", utils.StringValue(v.ErrorDetail), utils.StringValue(v.ProgressMessage))
+	if *v.Status == "INVALID_UUID" {
+		return v,
+			fmt.Errorf("%w: error_detail: %s, progress_message: %s", ErrTaskInvalidUUID, utils.StringValue(v.ErrorDetail), utils.StringValue(v.ProgressMessage))
+	}
+	if *v.Status == "FAILED" {
+		return v,
+			fmt.Errorf("%w: error_detail: %s, progress_message: %s", ErrTaskFailed, utils.StringValue(v.ErrorDetail), utils.StringValue(v.ProgressMessage))
 	}
-	taskStatus := *v.Status
-	log.V(1).Info(fmt.Sprintf("Status for task with UUID %s: %s", taskUUID, taskStatus))
-	return taskStatus, nil
+	log.V(1).Info("Got task status", "status", *v.Status)
+	return v, nil
 }
 
 // RetryableFunc performs an action and returns a bool indicating whether the
@@ -95,6 +385,11 @@ Returns an error if initial > max intervals, if retries are exhausted, or if the
 an error.
 */
 func Retry(initialInterval float64, maxInterval float64, numTries uint, function RetryableFunc) error {
+	return RetryWithClock(clock.RealClock{}, initialInterval, maxInterval, numTries, function)
+}
+
+// RetryWithClock is Retry with the delays between the retries slept on the given clock.
+func RetryWithClock(clk clock.Clock, initialInterval float64, maxInterval float64, numTries uint, function RetryableFunc) error {
 	if maxInterval == 0 {
 		maxInterval = math.Inf(1)
 	} else if initialInterval < 0 || initialInterval > maxInterval {
@@ -112,7 +407,7 @@ func Retry(initialInterval float64, maxInterval float64, numTries uint, function
 
 		if !done {
 			// Retry after delay. Calculate next delay.
-			time.Sleep(time.Duration(interval) * time.Second)
+			clk.Sleep(time.Duration(interval) * time.Second)
 			interval = math.Min(interval*2, maxInterval)
 		}
 	}
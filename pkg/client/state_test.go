@@ -0,0 +1,437 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	prismgoclient "github.com/nutanix-cloud-native/prism-go-client"
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	"github.com/stretchr/testify/assert"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// fakeImageService returns the image states in order, repeating the last state once all states were returned
+type fakeImageService struct {
+	nutanixClientV3.Service
+	states []string
+	calls  int
+}
+
+func (f *fakeImageService) GetImage(_ context.Context, uuid string) (*nutanixClientV3.ImageIntentResponse, error) {
+	state := f.states[len(f.states)-1]
+	if f.calls < len(f.states) {
+		state = f.states[f.calls]
+	}
+	f.calls++
+	return &nutanixClientV3.ImageIntentResponse{
+		Metadata: &nutanixClientV3.Metadata{UUID: utils.StringPtr(uuid)},
+		Status: &nutanixClientV3.ImageDefStatus{
+			State: utils.StringPtr(state),
+			ExecutionContext: &nutanixClientV3.ExecutionContext{
+				TaskUUID: "task-uuid",
+			},
+		},
+	}, nil
+}
+
+func (f *fakeImageService) GetTask(_ context.Context, _ string) (*nutanixClientV3.TasksResponse, error) {
+	return &nutanixClientV3.TasksResponse{
+		Status:             utils.StringPtr("RUNNING"),
+		PercentageComplete: utils.Int64Ptr(int64(f.calls * 10)),
+	}, nil
+}
+
+func TestWaitForImageReady(t *testing.T) {
+	tests := []struct {
+		name          string
+		states        []string
+		expectedCalls int
+		expectTimeout bool
+		expectError   bool
+	}{
+		{
+			name:          "TestWaitForImageReadyComplete",
+			states:        []string{"PENDING", "PENDING", "COMPLETE"},
+			expectedCalls: 3,
+		},
+		{
+			name:          "TestWaitForImageReadyActive",
+			states:        []string{"ACTIVE"},
+			expectedCalls: 1,
+		},
+		{
+			name:          "TestWaitForImageReadyTimeout",
+			states:        []string{"PENDING"},
+			expectTimeout: true,
+		},
+		{
+			name:        "TestWaitForImageReadyError",
+			states:      []string{"PENDING", "ERROR"},
+			expectError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeImageService{states: tt.states}
+			progressCalls := 0
+			err := WaitForImageReady(context.Background(), &nutanixClientV3.Client{V3: service}, "image-uuid", WaitOptions{
+				Interval: time.Millisecond,
+				Timeout:  50 * time.Millisecond,
+				OnProgress: func(state string, percentageComplete int64) {
+					progressCalls++
+					assert.Equal(t, int64(service.calls*10), percentageComplete)
+				},
+			})
+			switch {
+			case tt.expectTimeout:
+				assert.True(t, errors.Is(err, wait.ErrWaitTimeout))
+			case tt.expectError:
+				assert.Error(t, err)
+				assert.False(t, errors.Is(err, wait.ErrWaitTimeout))
+			default:
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedCalls, service.calls)
+				assert.Equal(t, tt.expectedCalls, progressCalls)
+			}
+		})
+	}
+}
+
+func TestGetImageProgress(t *testing.T) {
+	service := &fakeImageService{states: []string{"PENDING", "COMPLETE", "ERROR"}}
+	client := &nutanixClientV3.Client{V3: service}
+
+	state, percentageComplete, err := GetImageProgress(context.Background(), client, "image-uuid")
+	assert.NoError(t, err)
+	assert.Equal(t, "PENDING", state)
+	assert.Equal(t, int64(10), percentageComplete)
+	assert.False(t, IsImageReady(state))
+
+	state, _, err = GetImageProgress(context.Background(), client, "image-uuid")
+	assert.NoError(t, err)
+	assert.True(t, IsImageReady(state))
+
+	_, _, err = GetImageProgress(context.Background(), client, "image-uuid")
+	assert.Error(t, err)
+}
+
+// newSlowPrismServer returns a Prism Central server answering requests only once they are canceled by the client
+func newSlowPrismServer(t *testing.T) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Minute):
+		}
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newSlowPrismCredentials(server *httptest.Server) prismgoclient.Credentials {
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	return prismgoclient.Credentials{
+		Endpoint: host,
+		Port:     port,
+		Username: "user",
+		Password: "password",
+		Insecure: true,
+	}
+}
+
+func TestWaitForTaskCompletionHonorsContext(t *testing.T) {
+	cred := newSlowPrismCredentials(newSlowPrismServer(t))
+	cred.URL = JoinHostPort(cred.Endpoint, cred.Port)
+	client, err := nutanixClientV3.NewV3Client(cred)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = WaitForTaskCompletion(ctx, client, "task-uuid")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestWaitForTaskCompletion(t *testing.T) {
+	ctx := context.Background()
+	client := &nutanixClientV3.Client{V3: &fakeTaskService{states: []string{"SUCCEEDED"}}}
+	assert.NoError(t, WaitForTaskCompletion(ctx, client, "task-uuid"))
+
+	client = &nutanixClientV3.Client{V3: &fakeTaskService{states: []string{"FAILED"}}}
+	assert.ErrorIs(t, WaitForTaskCompletion(ctx, client, "task-uuid"), ErrTaskFailed)
+}
+
+func TestGetClientHonorsContext(t *testing.T) {
+	helper, err := NewNutanixClientHelper(nil, nil)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = helper.GetClient(ctx, newSlowPrismCredentials(newSlowPrismServer(t)), "")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+// fakeTaskService returns the task states in order, repeating the last state once all states were returned
+type fakeTaskService struct {
+	nutanixClientV3.Service
+	states []string
+	calls  int
+}
+
+func (f *fakeTaskService) GetTask(_ context.Context, _ string) (*nutanixClientV3.TasksResponse, error) {
+	state := f.states[len(f.states)-1]
+	if f.calls < len(f.states) {
+		state = f.states[f.calls]
+	}
+	f.calls++
+	return &nutanixClientV3.TasksResponse{Status: utils.StringPtr(state)}, nil
+}
+
+// loggingTaskService logs with the logger of the context of every task request
+type loggingTaskService struct {
+	nutanixClientV3.Service
+}
+
+func (f *loggingTaskService) GetTask(ctx context.Context, _ string) (*nutanixClientV3.TasksResponse, error) {
+	ctrl.LoggerFrom(ctx).Info("getting task")
+	return &nutanixClientV3.TasksResponse{Status: utils.StringPtr(taskStateSucceeded)}, nil
+}
+
+func TestWaitForTaskCompletionLogsTaskUUID(t *testing.T) {
+	lines := make([]string, 0)
+	logger := funcr.New(func(prefix, args string) {
+		lines = append(lines, args)
+	}, funcr.Options{})
+	ctx := ctrl.LoggerInto(context.Background(), logger.WithValues(LogKeyVMUUID, "vm-uuid"))
+
+	err := WaitForTaskCompletion(ctx, &nutanixClientV3.Client{V3: &loggingTaskService{}}, "task-uuid")
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, lines) {
+		for _, line := range lines {
+			assert.True(t, strings.Contains(line, `"taskUUID"="task-uuid"`), line)
+			assert.True(t, strings.Contains(line, `"vmUUID"="vm-uuid"`), line)
+		}
+	}
+}
+
+// fakeTasksService returns the state of each task by UUID
+type fakeTasksService struct {
+	nutanixClientV3.Service
+	states map[string]string
+}
+
+func (f *fakeTasksService) GetTask(_ context.Context, uuid string) (*nutanixClientV3.TasksResponse, error) {
+	return &nutanixClientV3.TasksResponse{
+		Status:      utils.StringPtr(f.states[uuid]),
+		ErrorDetail: utils.StringPtr("failed " + uuid),
+	}, nil
+}
+
+func TestWaitForTasksToSucceed(t *testing.T) {
+	conn := &nutanixClientV3.Client{V3: &fakeTasksService{states: map[string]string{
+		"task-1": "SUCCEEDED",
+		"task-2": "FAILED",
+		"task-3": "SUCCEEDED",
+		"task-4": "INVALID_UUID",
+	}}}
+
+	assert.NoError(t, WaitForTasksToSucceed(context.Background(), conn, nil))
+	assert.NoError(t, WaitForTasksToSucceed(context.Background(), conn, []string{"task-1", "task-3"}))
+
+	err := WaitForTasksToSucceed(context.Background(), conn, []string{"task-1", "task-2", "task-3", "task-4"})
+	var aggregate kerrors.Aggregate
+	if assert.True(t, errors.As(err, &aggregate)) {
+		assert.Len(t, aggregate.Errors(), 2)
+	}
+	assert.Contains(t, err.Error(), "task with UUID task-2 did not succeed")
+	assert.Contains(t, err.Error(), "failed task-2")
+	assert.Contains(t, err.Error(), "task with UUID task-4 did not succeed")
+	assert.NotContains(t, err.Error(), "task-1")
+	assert.NotContains(t, err.Error(), "task-3")
+}
+
+func TestWaitForTasksToSucceedHonorsContext(t *testing.T) {
+	conn := &nutanixClientV3.Client{V3: &fakeTasksService{states: map[string]string{
+		"task-1": "SUCCEEDED",
+		"task-2": "RUNNING",
+	}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := WaitForTasksToSucceed(ctx, conn, []string{"task-1", "task-2"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "task with UUID task-2 did not succeed")
+	assert.NotContains(t, err.Error(), "task-1 did not succeed")
+}
+
+// fakeRunningTaskService returns a running task created at the given time
+type fakeRunningTaskService struct {
+	nutanixClientV3.Service
+	creationTime *time.Time
+}
+
+func (f *fakeRunningTaskService) GetTask(_ context.Context, _ string) (*nutanixClientV3.TasksResponse, error) {
+	return &nutanixClientV3.TasksResponse{Status: utils.StringPtr("RUNNING"), CreationTime: f.creationTime}, nil
+}
+
+func TestWaitForTaskToSucceedTimeout(t *testing.T) {
+	ctx := context.Background()
+	opts := WaitOptions{Interval: time.Millisecond, Timeout: 50 * time.Millisecond}
+
+	// The timeout is counted from the start of waiting if the creation time of the task is unknown
+	start := time.Now()
+	err := WaitForTaskToSucceed(ctx, &nutanixClientV3.Client{V3: &fakeRunningTaskService{}}, "task-uuid", opts)
+	assert.ErrorIs(t, err, wait.ErrWaitTimeout)
+	assert.Contains(t, err.Error(), "task with UUID task-uuid did not succeed within 50ms")
+	assert.GreaterOrEqual(t, time.Since(start), opts.Timeout)
+
+	// Tasks created before the timeout are given up right away
+	created := time.Now().Add(-time.Hour)
+	opts.Timeout = time.Minute
+	err = WaitForTaskToSucceed(ctx, &nutanixClientV3.Client{V3: &fakeRunningTaskService{creationTime: &created}}, "task-uuid", opts)
+	assert.ErrorIs(t, err, wait.ErrWaitTimeout)
+
+	client := &nutanixClientV3.Client{V3: &fakeTaskService{states: []string{"RUNNING", "SUCCEEDED"}}}
+	assert.NoError(t, WaitForTaskToSucceed(ctx, client, "task-uuid", opts))
+
+	client = &nutanixClientV3.Client{V3: &fakeTaskService{states: []string{"RUNNING", "FAILED"}}}
+	err = WaitForTaskToSucceed(ctx, client, "task-uuid", opts)
+	assert.ErrorIs(t, err, ErrTaskFailed)
+	assert.NotErrorIs(t, err, wait.ErrWaitTimeout)
+}
+
+func TestWaitForTaskToSucceedInvalidUUID(t *testing.T) {
+	ctx := context.Background()
+	opts := WaitOptions{Interval: time.Millisecond, InvalidUUIDGracePeriod: time.Minute}
+
+	// Invalid task UUIDs are retried within the grace period, e.g. while Prism Central restarts
+	service := &fakeTaskService{states: []string{"INVALID_UUID", "INVALID_UUID", "SUCCEEDED"}}
+	assert.NoError(t, WaitForTaskToSucceed(ctx, &nutanixClientV3.Client{V3: service}, "task-uuid", opts))
+	assert.Equal(t, 3, service.calls)
+
+	// Tasks that fail after their UUID became valid are not retried
+	service = &fakeTaskService{states: []string{"INVALID_UUID", "FAILED", "SUCCEEDED"}}
+	err := WaitForTaskToSucceed(ctx, &nutanixClientV3.Client{V3: service}, "task-uuid", opts)
+	assert.ErrorIs(t, err, ErrTaskFailed)
+	assert.NotErrorIs(t, err, ErrTaskInvalidUUID)
+
+	// Invalid task UUIDs fail once the grace period passed
+	opts.InvalidUUIDGracePeriod = 20 * time.Millisecond
+	start := time.Now()
+	err = WaitForTaskToSucceed(ctx, &nutanixClientV3.Client{V3: &fakeTaskService{states: []string{"INVALID_UUID"}}}, "task-uuid", opts)
+	assert.ErrorIs(t, err, ErrTaskInvalidUUID)
+	assert.ErrorIs(t, err, ErrTaskFailed)
+	assert.GreaterOrEqual(t, time.Since(start), opts.InvalidUUIDGracePeriod)
+
+	// Invalid task UUIDs fail right away without grace period
+	service = &fakeTaskService{states: []string{"INVALID_UUID", "SUCCEEDED"}}
+	err = WaitForTaskToSucceed(ctx, &nutanixClientV3.Client{V3: service}, "task-uuid", WaitOptions{Interval: time.Millisecond})
+	assert.ErrorIs(t, err, ErrTaskInvalidUUID)
+	assert.Equal(t, 1, service.calls)
+
+	// The grace period set for the controller applies if the options set none
+	SetTaskInvalidUUIDGracePeriod(time.Minute)
+	t.Cleanup(func() { SetTaskInvalidUUIDGracePeriod(0) })
+	service = &fakeTaskService{states: []string{"INVALID_UUID", "SUCCEEDED"}}
+	assert.NoError(t, WaitForTaskToSucceed(ctx, &nutanixClientV3.Client{V3: service}, "task-uuid", WaitOptions{Interval: time.Millisecond}))
+	assert.Equal(t, 2, service.calls)
+}
+
+// runWithFakeClock runs the function and advances the fake clock by the step whenever the function waits for the
+// clock, until the function returned. Returns the time the clock was advanced by and the error of the function.
+func runWithFakeClock(fakeClock *clocktesting.FakeClock, step time.Duration, f func() error) (time.Duration, error) {
+	start := fakeClock.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- f()
+	}()
+	for {
+		select {
+		case err := <-errCh:
+			return fakeClock.Since(start), err
+		default:
+		}
+		if fakeClock.HasWaiters() {
+			fakeClock.Step(step)
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestWaitForTaskToSucceedWithFakeClock(t *testing.T) {
+	ctx := context.Background()
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	opts := WaitOptions{Interval: time.Second, Timeout: 10 * time.Second, Clock: fakeClock}
+
+	// The task is polled every second until it is running for longer than the timeout
+	service := &fakeTaskService{states: []string{"RUNNING"}}
+	waited, err := runWithFakeClock(fakeClock, opts.Interval, func() error {
+		return WaitForTaskToSucceed(ctx, &nutanixClientV3.Client{V3: service}, "task-uuid", opts)
+	})
+	assert.ErrorIs(t, err, wait.ErrWaitTimeout)
+	assert.Equal(t, 11*time.Second, waited)
+	assert.Equal(t, 12, service.calls)
+
+	service = &fakeTaskService{states: []string{"RUNNING", "RUNNING", "SUCCEEDED"}}
+	waited, err = runWithFakeClock(fakeClock, opts.Interval, func() error {
+		return WaitForTaskToSucceed(ctx, &nutanixClientV3.Client{V3: service}, "task-uuid", opts)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, waited)
+	assert.Equal(t, 3, service.calls)
+}
+
+func TestRetryWithClockBacksOff(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	start := fakeClock.Now()
+	delays := []time.Duration{}
+	last := start
+	err := RetryWithClock(fakeClock, 1, 4, 5, func(_ uint) (bool, error) {
+		delays = append(delays, fakeClock.Since(last))
+		last = fakeClock.Now()
+		return false, nil
+	})
+	assert.EqualError(t, err, "function never succeeded in Retry")
+	// The delay doubles after every attempt up to the maximum interval
+	assert.Equal(t, []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}, delays)
+	assert.Equal(t, 15*time.Second, fakeClock.Since(start))
+
+	fakeClock = clocktesting.NewFakeClock(time.Now())
+	start = fakeClock.Now()
+	err = RetryWithClock(fakeClock, 1, 4, 0, func(attempt uint) (bool, error) {
+		return attempt == 2, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3*time.Second, fakeClock.Since(start))
+}
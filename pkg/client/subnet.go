@@ -0,0 +1,86 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+)
+
+// subnetTypeOverlay is the type of overlay subnets, which are available on all Prism Element clusters
+const subnetTypeOverlay = "OVERLAY"
+
+var (
+	// ErrSubnetNotFound is returned if no subnet matches a subnet selector
+	ErrSubnetNotFound = errors.New("no subnet found")
+	// ErrAmbiguousSubnet is returned if more than one subnet matches a subnet selector
+	ErrAmbiguousSubnet = errors.New("more than one subnet found")
+)
+
+// FindSubnetByCategory returns the subnet available on the Prism Element cluster with the given UUID that has the
+// category key and value assigned. Overlay subnets are available on all Prism Element clusters. Returns
+// ErrSubnetNotFound if no subnet matches, and ErrAmbiguousSubnet if more than one subnet matches.
+func FindSubnetByCategory(ctx context.Context, client *nutanixClientV3.Client, peUUID, key, value string) (*nutanixClientV3.SubnetIntentResponse, error) {
+	// Prism Central list filters cannot select by category, so subnets are matched after listing them
+	subnets, err := ListAllSubnets(ctx, client, "")
+	if err != nil {
+		return nil, err
+	}
+	found := make([]*nutanixClientV3.SubnetIntentResponse, 0)
+	for _, subnet := range subnets {
+		if subnet == nil || subnet.Metadata == nil || subnet.Metadata.UUID == nil {
+			continue
+		}
+		if v, ok := subnet.Metadata.Categories[key]; !ok || v != value {
+			continue
+		}
+		if !isSubnetAvailableOnCluster(subnet, peUUID) {
+			continue
+		}
+		found = append(found, subnet)
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%w with category %s=%s on cluster with UUID %s", ErrSubnetNotFound, key, value, peUUID)
+	case 1:
+		return found[0], nil
+	default:
+		uuids := make([]string, 0, len(found))
+		for _, subnet := range found {
+			uuids = append(uuids, *subnet.Metadata.UUID)
+		}
+		sort.Strings(uuids)
+		return nil, fmt.Errorf("%w with category %s=%s on cluster with UUID %s: %s", ErrAmbiguousSubnet, key, value, peUUID, strings.Join(uuids, ", "))
+	}
+}
+
+// isSubnetAvailableOnCluster returns true if the subnet is an overlay subnet or belongs to the Prism Element cluster
+// with the given UUID
+func isSubnetAvailableOnCluster(subnet *nutanixClientV3.SubnetIntentResponse, peUUID string) bool {
+	if subnet.Spec == nil {
+		return false
+	}
+	if subnet.Spec.Resources != nil && subnet.Spec.Resources.SubnetType != nil && *subnet.Spec.Resources.SubnetType == subnetTypeOverlay {
+		return true
+	}
+	return subnet.Spec.ClusterReference != nil && subnet.Spec.ClusterReference.UUID != nil && *subnet.Spec.ClusterReference.UUID == peUUID
+}
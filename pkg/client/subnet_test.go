@@ -0,0 +1,116 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"testing"
+
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/pointer"
+)
+
+// subnetTestService is a Prism v3 service returning canned subnets
+type subnetTestService struct {
+	nutanixClientV3.Service
+	subnets []*nutanixClientV3.SubnetIntentResponse
+}
+
+func (s *subnetTestService) ListSubnet(_ context.Context, _ *nutanixClientV3.DSMetadata) (*nutanixClientV3.SubnetListIntentResponse, error) {
+	return &nutanixClientV3.SubnetListIntentResponse{Entities: s.subnets}, nil
+}
+
+func testSubnet(uuid, subnetType, peUUID string, categories map[string]string) *nutanixClientV3.SubnetIntentResponse {
+	return &nutanixClientV3.SubnetIntentResponse{
+		Metadata: &nutanixClientV3.Metadata{UUID: pointer.String(uuid), Categories: categories},
+		Spec: &nutanixClientV3.Subnet{
+			Name:             pointer.String(uuid),
+			Resources:        &nutanixClientV3.SubnetResources{SubnetType: pointer.String(subnetType)},
+			ClusterReference: &nutanixClientV3.Reference{Kind: pointer.String("cluster"), UUID: pointer.String(peUUID)},
+		},
+	}
+}
+
+func TestFindSubnetByCategory(t *testing.T) {
+	ctx := context.Background()
+	client := &nutanixClientV3.Client{V3: &subnetTestService{
+		subnets: []*nutanixClientV3.SubnetIntentResponse{
+			testSubnet("vlan-prod", "VLAN", "pe-1", map[string]string{"network": "prod"}),
+			testSubnet("vlan-prod-other-pe", "VLAN", "pe-2", map[string]string{"network": "prod"}),
+			testSubnet("vlan-dev", "VLAN", "pe-1", map[string]string{"network": "dev", "tier": "web"}),
+			testSubnet("overlay-dev", "OVERLAY", "pe-2", map[string]string{"network": "dev"}),
+			testSubnet("vlan-untagged", "VLAN", "pe-1", nil),
+		},
+	}}
+
+	tests := []struct {
+		name         string
+		peUUID       string
+		key          string
+		value        string
+		expectedUUID string
+		expectedErr  error
+	}{
+		{
+			name:         "single match on the cluster",
+			peUUID:       "pe-1",
+			key:          "network",
+			value:        "prod",
+			expectedUUID: "vlan-prod",
+		},
+		{
+			name:         "match by another category",
+			peUUID:       "pe-1",
+			key:          "tier",
+			value:        "web",
+			expectedUUID: "vlan-dev",
+		},
+		{
+			name:        "no match",
+			peUUID:      "pe-1",
+			key:         "network",
+			value:       "test",
+			expectedErr: ErrSubnetNotFound,
+		},
+		{
+			name:        "no match on the cluster",
+			peUUID:      "pe-3",
+			key:         "network",
+			value:       "prod",
+			expectedErr: ErrSubnetNotFound,
+		},
+		{
+			name:        "ambiguous match with overlay subnet of another cluster",
+			peUUID:      "pe-1",
+			key:         "network",
+			value:       "dev",
+			expectedErr: ErrAmbiguousSubnet,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subnet, err := FindSubnetByCategory(ctx, client, tt.peUUID, tt.key, tt.value)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedUUID, *subnet.Metadata.UUID)
+		})
+	}
+}
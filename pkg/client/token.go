@@ -0,0 +1,211 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	"k8s.io/utils/clock"
+)
+
+const (
+	// BearerTokenCredentialType is the type of credentials holding a bearer token, e.g. issued by an identity provider
+	// integrated with Prism Central, instead of a username and password
+	BearerTokenCredentialType = credentialTypes.CredentialType("bearer_token")
+
+	// DefaultTokenRefreshMargin is the time before the expiry of a bearer token at which the token is read again
+	DefaultTokenRefreshMargin = time.Minute
+
+	// bearerTokenUsername and bearerTokenPassword satisfy the basic auth credentials required by the Prism client. The
+	// authorization header is replaced with the bearer token on every request.
+	bearerTokenUsername = "bearer-token"
+	bearerTokenPassword = "bearer-token"
+)
+
+// BearerTokenCredential is the data of credentials of type bearer_token
+type BearerTokenCredential struct {
+	// The bearer token for the Prism Central
+	PrismCentral PrismCentralBearerToken `json:"prismCentral"`
+}
+
+// PrismCentralBearerToken is a bearer token for the Prism Central
+type PrismCentralBearerToken struct {
+	// Token is the bearer token
+	Token string `json:"token"`
+	// ExpiresAt is the time the token expires. Tokens without expiry are read again for every request.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// BearerToken is a bearer token and the time it expires. The expiry is zero if the token does not expire.
+type BearerToken struct {
+	Token  string
+	Expiry time.Time
+}
+
+// TokenSource returns the current bearer token
+type TokenSource func() (*BearerToken, error)
+
+// ParseBearerToken returns the bearer token of credentials data of type bearer_token, or nil if the credentials are
+// of another type. Like the basic auth credentials, only the first credentials are taken into account.
+func ParseBearerToken(credsData []byte) (*BearerToken, error) {
+	creds := &credentialTypes.NutanixCredentials{}
+	if err := json.Unmarshal(credsData, &creds.Credentials); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the credentials data. %w", err)
+	}
+	if len(creds.Credentials) == 0 || creds.Credentials[0].Type != BearerTokenCredentialType {
+		return nil, nil
+	}
+	bearerTokenCreds := BearerTokenCredential{}
+	if err := json.Unmarshal(creds.Credentials[0].Data, &bearerTokenCreds); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the bearer token data. %w", err)
+	}
+	pc := bearerTokenCreds.PrismCentral
+	if pc.Token == "" {
+		return nil, fmt.Errorf("the PrismCentral bearer token is not set")
+	}
+	token := &BearerToken{Token: pc.Token}
+	if pc.ExpiresAt != nil {
+		token.Expiry = *pc.ExpiresAt
+	}
+	return token, nil
+}
+
+// ErrInvalidCredentials is wrapped by the errors of credentials data that cannot be used to authenticate to Prism
+// Central
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ValidateCredentials verifies that the credentials data holds basic auth or bearer token credentials. Like the
+// clients, only the first credentials are taken into account. The returned error wraps ErrInvalidCredentials and
+// names the invalid field.
+func ValidateCredentials(credsData []byte) error {
+	path := credentialTypes.KeyName
+	creds := []credentialTypes.Credential{}
+	if err := json.Unmarshal(credsData, &creds); err != nil {
+		return invalidCredentials(path, err)
+	}
+	if len(creds) == 0 {
+		return invalidCredentials(path, errors.New("no credentials"))
+	}
+	path += "[0]"
+	cred := creds[0]
+	if cred.Type == "" {
+		return invalidCredentials(path+".type", errors.New("must be set"))
+	}
+	if cred.Type != credentialTypes.BasicAuthCredentialType && cred.Type != BearerTokenCredentialType {
+		return invalidCredentials(path+".type", fmt.Errorf("unsupported credentials type %q", cred.Type))
+	}
+	path += ".data"
+	if len(cred.Data) == 0 {
+		return invalidCredentials(path, errors.New("must be set"))
+	}
+	if cred.Type == BearerTokenCredentialType {
+		bearerTokenCreds := BearerTokenCredential{}
+		if err := json.Unmarshal(cred.Data, &bearerTokenCreds); err != nil {
+			return invalidCredentials(path, err)
+		}
+		if bearerTokenCreds.PrismCentral.Token == "" {
+			return invalidCredentials(path+".prismCentral.token", errors.New("must be set"))
+		}
+		return nil
+	}
+	basicAuthCreds := credentialTypes.BasicAuthCredential{}
+	if err := json.Unmarshal(cred.Data, &basicAuthCreds); err != nil {
+		return invalidCredentials(path, err)
+	}
+	if basicAuthCreds.PrismCentral.Username == "" {
+		return invalidCredentials(path+".prismCentral.username", errors.New("must be set"))
+	}
+	if basicAuthCreds.PrismCentral.Password == "" {
+		return invalidCredentials(path+".prismCentral.password", errors.New("must be set"))
+	}
+	return nil
+}
+
+// invalidCredentials returns an error wrapping ErrInvalidCredentials for the field at the path of the credentials data
+func invalidCredentials(path string, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		err = fmt.Errorf("malformed JSON at offset %d: %v", syntaxErr.Offset, syntaxErr)
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			path += "." + typeErr.Field
+		}
+		err = fmt.Errorf("unexpected JSON %s", typeErr.Value)
+	}
+	return fmt.Errorf("%w: %s: %v", ErrInvalidCredentials, path, err)
+}
+
+// bearerTokenRoundTripper sets the authorization header of requests to the bearer token of the token source. Tokens
+// are kept until the refresh margin before their expiry.
+type bearerTokenRoundTripper struct {
+	next          http.RoundTripper
+	source        TokenSource
+	clock         clock.PassiveClock
+	refreshMargin time.Duration
+
+	mu    sync.Mutex
+	token *BearerToken
+}
+
+// NewBearerTokenRoundTripper returns a round tripper authenticating the requests passed to the next round tripper with
+// the bearer token of the token source
+func NewBearerTokenRoundTripper(next http.RoundTripper, source TokenSource, clock clock.PassiveClock) http.RoundTripper {
+	return &bearerTokenRoundTripper{
+		next:          next,
+		source:        source,
+		clock:         clock,
+		refreshMargin: DefaultTokenRefreshMargin,
+	}
+}
+
+func (t *bearerTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.getToken()
+	if err != nil {
+		return nil, err
+	}
+	// Round trippers must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.next.RoundTrip(req)
+}
+
+// getToken returns the kept token, or reads the token from the token source if it expires within the refresh margin
+func (t *bearerTokenRoundTripper) getToken() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock.Now()
+	if t.token != nil && !t.token.Expiry.IsZero() && now.Before(t.token.Expiry.Add(-t.refreshMargin)) {
+		return t.token.Token, nil
+	}
+	token, err := t.source()
+	if err != nil {
+		return "", fmt.Errorf("failed to get bearer token: %v", err)
+	}
+	if !token.Expiry.IsZero() && !now.Before(token.Expiry) {
+		return "", fmt.Errorf("bearer token expired at %s", token.Expiry.Format(time.RFC3339))
+	}
+	t.token = token
+	return token.Token, nil
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestParseBearerToken(t *testing.T) {
+	expiry := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		data          string
+		expectedToken *BearerToken
+		expectErr     bool
+	}{
+		{
+			name:          "token with expiry",
+			data:          `[{"type":"bearer_token","data":{"prismCentral":{"token":"abc","expiresAt":"2022-01-01T12:00:00Z"}}}]`,
+			expectedToken: &BearerToken{Token: "abc", Expiry: expiry},
+		},
+		{
+			name:          "token without expiry",
+			data:          `[{"type":"bearer_token","data":{"prismCentral":{"token":"abc"}}}]`,
+			expectedToken: &BearerToken{Token: "abc"},
+		},
+		{
+			name: "basic auth",
+			data: `[{"type":"basic_auth","data":{"prismCentral":{"username":"user","password":"password"}}}]`,
+		},
+		{
+			name:      "empty token",
+			data:      `[{"type":"bearer_token","data":{"prismCentral":{"token":""}}}]`,
+			expectErr: true,
+		},
+		{
+			name:      "malformed",
+			data:      `{`,
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := ParseBearerToken([]byte(tt.data))
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedToken, token)
+		})
+	}
+}
+
+func TestValidateCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		expectedErr string
+	}{
+		{
+			name: "bearer token",
+			data: `[{"type":"bearer_token","data":{"prismCentral":{"token":"abc"}}}]`,
+		},
+		{
+			name: "basic auth",
+			data: `[{"type":"basic_auth","data":{"prismCentral":{"username":"user","password":"password"}}}]`,
+		},
+		{
+			name:        "malformed JSON",
+			data:        `[{"type":"basic_auth",`,
+			expectedErr: "credentials: malformed JSON at offset 22",
+		},
+		{
+			name:        "not a list",
+			data:        `{"type":"basic_auth"}`,
+			expectedErr: "credentials: unexpected JSON object",
+		},
+		{
+			name:        "empty list",
+			data:        `[]`,
+			expectedErr: "credentials: no credentials",
+		},
+		{
+			name:        "missing type",
+			data:        `[{"data":{}}]`,
+			expectedErr: "credentials[0].type: must be set",
+		},
+		{
+			name:        "unsupported type",
+			data:        `[{"type":"kerberos","data":{}}]`,
+			expectedErr: `credentials[0].type: unsupported credentials type "kerberos"`,
+		},
+		{
+			name:        "missing data",
+			data:        `[{"type":"basic_auth"}]`,
+			expectedErr: "credentials[0].data: must be set",
+		},
+		{
+			name:        "wrong type of field",
+			data:        `[{"type":"basic_auth","data":{"prismCentral":{"username":1,"password":"password"}}}]`,
+			expectedErr: "credentials[0].data.prismCentral.username: unexpected JSON number",
+		},
+		{
+			name:        "missing username",
+			data:        `[{"type":"basic_auth","data":{"prismCentral":{"password":"password"}}}]`,
+			expectedErr: "credentials[0].data.prismCentral.username: must be set",
+		},
+		{
+			name:        "missing password",
+			data:        `[{"type":"basic_auth","data":{"prismCentral":{"username":"user"}}}]`,
+			expectedErr: "credentials[0].data.prismCentral.password: must be set",
+		},
+		{
+			name:        "missing token",
+			data:        `[{"type":"bearer_token","data":{"prismCentral":{}}}]`,
+			expectedErr: "credentials[0].data.prismCentral.token: must be set",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCredentials([]byte(tt.data))
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+			assert.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestBearerTokenRoundTripper(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clocktesting.NewFakePassiveClock(now)
+	reads := 0
+	source := func() (*BearerToken, error) {
+		reads++
+		return &BearerToken{Token: fmt.Sprintf("token-%d", reads), Expiry: now.Add(10 * time.Minute)}, nil
+	}
+	client := &http.Client{Transport: NewBearerTokenRoundTripper(http.DefaultTransport, source, fakeClock)}
+	get := func() {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		assert.NoError(t, err)
+		req.SetBasicAuth(bearerTokenUsername, bearerTokenPassword)
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	get()
+	assert.Equal(t, "Bearer token-1", authorization)
+
+	// The token is kept until the refresh margin before its expiry
+	fakeClock.SetTime(now.Add(8 * time.Minute))
+	get()
+	assert.Equal(t, "Bearer token-1", authorization)
+	assert.Equal(t, 1, reads)
+
+	fakeClock.SetTime(now.Add(9 * time.Minute))
+	get()
+	assert.Equal(t, "Bearer token-2", authorization)
+	assert.Equal(t, 2, reads)
+}
+
+func TestBearerTokenRoundTripperExpiredToken(t *testing.T) {
+	now := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	source := func() (*BearerToken, error) {
+		return &BearerToken{Token: "abc", Expiry: now.Add(-time.Second)}, nil
+	}
+	rt := NewBearerTokenRoundTripper(http.DefaultTransport, source, clocktesting.NewFakePassiveClock(now))
+	req, err := http.NewRequest(http.MethodGet, "https://prism.example.com:9440/api/nutanix/v3/users/me", nil)
+	assert.NoError(t, err)
+	_, err = rt.RoundTrip(req)
+	assert.ErrorContains(t, err, "expired")
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+// TransportLimits are the connection pooling limits of the HTTP transports used to access Prism endpoints. Every client
+// has its own transport, so the limits apply per endpoint. Zero values keep the defaults of the Go HTTP transport.
+type TransportLimits struct {
+	// MaxIdleConns is the maximum number of idle connections kept open to the endpoint
+	MaxIdleConns int
+	// MaxConnsPerHost is the maximum number of connections to the endpoint, including connections in use. Requests
+	// wait for a connection to become available once it is reached.
+	MaxConnsPerHost int
+	// IdleConnTimeout is the time after which idle connections are closed
+	IdleConnTimeout time.Duration
+}
+
+var (
+	transportLimitsMu sync.RWMutex
+	transportLimits   TransportLimits
+)
+
+// SetTransportLimits sets the connection pooling limits of the transports created by NewTransport afterwards
+func SetTransportLimits(limits TransportLimits) {
+	transportLimitsMu.Lock()
+	defer transportLimitsMu.Unlock()
+	transportLimits = limits
+}
+
+// getTransportLimits returns the connection pooling limits of new transports
+func getTransportLimits() TransportLimits {
+	transportLimitsMu.RLock()
+	defer transportLimitsMu.RUnlock()
+	return transportLimits
+}
+
+// NewProxyFunc returns the proxy function of an HTTP transport for the proxy settings.
+// The proxy environment variables of the process are not taken into account.
+func NewProxyFunc(proxy *infrav1.NutanixProxySpec) func(*http.Request) (*url.URL, error) {
+	proxyConfig := &httpproxy.Config{
+		HTTPProxy:  proxy.HTTPProxy,
+		HTTPSProxy: proxy.HTTPSProxy,
+		NoProxy:    strings.Join(proxy.NoProxy, ","),
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}
+}
+
+// NewTLSConfig returns the TLS config used to access a Prism endpoint. The certificates of the additional
+// trust bundle are added to the system cert pool. The client certificate is presented if the server requests one.
+func NewTLSConfig(additionalTrustBundle string, clientCertificate *tls.Certificate) (*tls.Config, error) {
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get system cert pool: %v", err)
+	}
+	if additionalTrustBundle != "" {
+		certs, err := ParseTrustBundle(additionalTrustBundle)
+		if err != nil {
+			return nil, err
+		}
+		for _, cert := range certs {
+			rootCAs.AddCert(cert)
+		}
+	}
+	tlsConfig := &tls.Config{
+		RootCAs:    rootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	if clientCertificate != nil {
+		tlsConfig.Certificates = []tls.Certificate{*clientCertificate}
+	}
+	return tlsConfig, nil
+}
+
+// NewTransport returns the HTTP transport used to access a Prism endpoint through the given proxy
+// and with the TLS config returned by NewTLSConfig. The connection pooling limits set with SetTransportLimits
+// are applied.
+func NewTransport(proxy *infrav1.NutanixProxySpec, additionalTrustBundle string, clientCertificate *tls.Certificate) (*http.Transport, error) {
+	tlsConfig, err := NewTLSConfig(additionalTrustBundle, clientCertificate)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if proxy != nil {
+		transport.Proxy = NewProxyFunc(proxy)
+	}
+	limits := getTransportLimits()
+	if limits.MaxIdleConns > 0 {
+		// The transport only connects to a single endpoint, so all idle connections can be kept for it
+		transport.MaxIdleConns = limits.MaxIdleConns
+		transport.MaxIdleConnsPerHost = limits.MaxIdleConns
+	}
+	if limits.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = limits.MaxConnsPerHost
+	}
+	if limits.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = limits.IdleConnTimeout
+	}
+	return transport, nil
+}
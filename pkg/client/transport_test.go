@@ -0,0 +1,200 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+func TestNewTransportLimits(t *testing.T) {
+	defaultTransport := http.DefaultTransport.(*http.Transport)
+	t.Cleanup(func() { SetTransportLimits(TransportLimits{}) })
+
+	// Zero values keep the defaults
+	transport, err := NewTransport(nil, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultTransport.MaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, defaultTransport.MaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, defaultTransport.MaxConnsPerHost, transport.MaxConnsPerHost)
+	assert.Equal(t, defaultTransport.IdleConnTimeout, transport.IdleConnTimeout)
+
+	SetTransportLimits(TransportLimits{MaxIdleConns: 50, MaxConnsPerHost: 20, IdleConnTimeout: 30 * time.Second})
+	transport, err = NewTransport(&infrav1.NutanixProxySpec{HTTPSProxy: "http://proxy.example.com:3128"}, "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, transport.MaxIdleConns)
+	assert.Equal(t, 50, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 20, transport.MaxConnsPerHost)
+	assert.Equal(t, 30*time.Second, transport.IdleConnTimeout)
+
+	// Every transport has its own connection pool
+	other, err := NewTransport(nil, "", nil)
+	assert.NoError(t, err)
+	assert.NotSame(t, transport, other)
+	assert.NotSame(t, defaultTransport, other)
+	assert.Equal(t, 20, other.MaxConnsPerHost)
+}
+
+func TestNewTransportProxy(t *testing.T) {
+	// The proxy settings of the NutanixCluster take precedence over the environment
+	t.Setenv("HTTPS_PROXY", "http://env-proxy.example.com:3128")
+	t.Setenv("NO_PROXY", "")
+
+	transport, err := NewTransport(&infrav1.NutanixProxySpec{
+		HTTPProxy:  "http://http-proxy.example.com:3128",
+		HTTPSProxy: "http://https-proxy.example.com:3128",
+		NoProxy:    []string{"direct.example.com", ".internal.example.com", "10.0.0.0/8"},
+	}, "", nil)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		url           string
+		expectedProxy string
+	}{
+		{
+			name:          "https host",
+			url:           "https://prism.example.com:9440/api/nutanix/v3/users/me",
+			expectedProxy: "http://https-proxy.example.com:3128",
+		},
+		{
+			name:          "http host",
+			url:           "http://prism.example.com:9440/api/nutanix/v3/users/me",
+			expectedProxy: "http://http-proxy.example.com:3128",
+		},
+		{
+			name: "no proxy host",
+			url:  "https://direct.example.com:9440/api/nutanix/v3/users/me",
+		},
+		{
+			name: "no proxy domain",
+			url:  "https://prism.internal.example.com:9440/api/nutanix/v3/users/me",
+		},
+		{
+			name: "no proxy cidr",
+			url:  "https://10.1.2.3:9440/api/nutanix/v3/users/me",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			assert.NoError(t, err)
+			proxyURL, err := transport.Proxy(req)
+			assert.NoError(t, err)
+			if tt.expectedProxy == "" {
+				assert.Nil(t, proxyURL)
+			} else {
+				assert.Equal(t, tt.expectedProxy, proxyURL.String())
+			}
+		})
+	}
+}
+
+func TestNewTransportInvalidTrustBundle(t *testing.T) {
+	_, err := NewTransport(&infrav1.NutanixProxySpec{}, "not a certificate", nil)
+	assert.Error(t, err)
+}
+
+// generateClientCertificate returns a PEM encoded self-signed client certificate and its private key
+func generateClientCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "capx"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestNewTLSConfigClientCertificate(t *testing.T) {
+	certPEM, keyPEM := generateClientCertificate(t)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "client-cert",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+		},
+	}
+	secretInformer := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Core().V1().Secrets()
+	assert.NoError(t, secretInformer.Informer().GetIndexer().Add(secret))
+	helper, err := NewNutanixClientHelper(secretInformer, nil)
+	assert.NoError(t, err)
+
+	nutanixCluster := &infrav1.NutanixCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+		},
+		Spec: infrav1.NutanixClusterSpec{
+			ClientCertificate: &infrav1.NutanixClientCertificate{
+				CertificateRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "client-cert"},
+					Key:                  corev1.TLSCertKey,
+				},
+				KeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "client-cert"},
+					Key:                  corev1.TLSPrivateKeyKey,
+				},
+			},
+		},
+	}
+	clientCertificate, err := helper.getClientCertificate(nutanixCluster)
+	assert.NoError(t, err)
+
+	// The client certificate composes with the additional trust bundle
+	tlsConfig, err := NewTLSConfig(string(certPEM), clientCertificate)
+	assert.NoError(t, err)
+	assert.Len(t, tlsConfig.Certificates, 1)
+	leaf, err := x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "capx", leaf.Subject.CommonName)
+
+	tlsConfig, err = NewTLSConfig("", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, tlsConfig.Certificates)
+
+	// The private key must be found in the secret
+	nutanixCluster.Spec.ClientCertificate.KeyRef.Key = "missing"
+	_, err = helper.getClientCertificate(nutanixCluster)
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+const (
+	// TrustBundleConfigMapKey is the key of the additional trust bundle in a ConfigMap
+	TrustBundleConfigMapKey = "ca.crt"
+
+	certificateBlockType = "CERTIFICATE"
+)
+
+// TrustBundlePolicy defines the requirements the certificates of an additional trust bundle must meet.
+type TrustBundlePolicy struct {
+	// MinRSAKeySize is the minimum size in bits of RSA public keys. 0 disables the check.
+	MinRSAKeySize int
+	// WeakSignatureAlgorithms are the signature algorithms that are not allowed.
+	WeakSignatureAlgorithms []x509.SignatureAlgorithm
+}
+
+// IsEmpty returns true if the policy does not define any requirements.
+func (p TrustBundlePolicy) IsEmpty() bool {
+	return p.MinRSAKeySize == 0 && len(p.WeakSignatureAlgorithms) == 0
+}
+
+// ParseSignatureAlgorithm returns the x509 signature algorithm with the given name (for example SHA1-RSA).
+func ParseSignatureAlgorithm(name string) (x509.SignatureAlgorithm, error) {
+	for alg := x509.MD2WithRSA; alg <= x509.PureEd25519; alg++ {
+		if strings.EqualFold(alg.String(), name) {
+			return alg, nil
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unknown signature algorithm %s", name)
+}
+
+// ParseTrustBundle parses all PEM encoded certificates in the trust bundle.
+func ParseTrustBundle(trustBundle string) ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0)
+	rest := []byte(trustBundle)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != certificateBlockType {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate in trust bundle: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no PEM encoded certificates found in trust bundle")
+	}
+	return certs, nil
+}
+
+// ValidateCertificateStrength returns an error if the certificate does not meet the requirements of the policy.
+func ValidateCertificateStrength(cert *x509.Certificate, policy TrustBundlePolicy) error {
+	for _, alg := range policy.WeakSignatureAlgorithms {
+		if cert.SignatureAlgorithm == alg {
+			return fmt.Errorf("certificate %q uses weak signature algorithm %s", cert.Subject.String(), alg)
+		}
+	}
+	if rsaKey, ok := cert.PublicKey.(*rsa.PublicKey); ok && policy.MinRSAKeySize > 0 {
+		if keySize := rsaKey.N.BitLen(); keySize < policy.MinRSAKeySize {
+			return fmt.Errorf("certificate %q has RSA key size %d but minimum is %d", cert.Subject.String(), keySize, policy.MinRSAKeySize)
+		}
+	}
+	return nil
+}
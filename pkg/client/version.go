@@ -0,0 +1,63 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nutanix-cloud-native/prism-go-client/utils"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+)
+
+// prismCentralServiceName is the service Prism Central lists itself with in the list of clusters
+const prismCentralServiceName = "PRISM_CENTRAL"
+
+// GetPrismCentralVersion returns the version of Prism Central, e.g. pc.2022.6. Prism Central lists itself as a
+// cluster running the PRISM_CENTRAL service. Fields of the cluster list that are unknown to the client, e.g. added
+// in newer Prism Central versions, are ignored.
+func GetPrismCentralVersion(ctx context.Context, client *nutanixClientV3.Client) (string, error) {
+	clusters, err := ListAllClusters(ctx, client, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to list clusters: %v", err)
+	}
+	for _, cluster := range clusters {
+		if cluster == nil || cluster.Status == nil || cluster.Status.Resources == nil || cluster.Status.Resources.Config == nil {
+			continue
+		}
+		config := cluster.Status.Resources.Config
+		if !hasService(config.ServiceList, prismCentralServiceName) {
+			continue
+		}
+		if config.Build == nil || utils.StringValue(config.Build.Version) == "" {
+			return "", errors.New("Prism Central does not report its version")
+		}
+		return *config.Build.Version, nil
+	}
+	return "", errors.New("Prism Central is not part of the list of clusters")
+}
+
+func hasService(serviceList []*string, serviceName string) bool {
+	for _, s := range serviceList {
+		if s != nil && strings.EqualFold(*s, serviceName) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2022 Nutanix
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	prismgoclient "github.com/nutanix-cloud-native/prism-go-client"
+	credentialTypes "github.com/nutanix-cloud-native/prism-go-client/environment/credentials"
+	nutanixClientV3 "github.com/nutanix-cloud-native/prism-go-client/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/nutanix-cloud-native/cluster-api-provider-nutanix/api/v1beta1"
+)
+
+const (
+	// clusterListPC2022 is a cluster list as returned by Prism Central pc.2022.6
+	clusterListPC2022 = `{
+  "api_version": "3.1",
+  "metadata": {"kind": "cluster", "length": 2, "offset": 0, "total_matches": 2},
+  "entities": [
+    {
+      "metadata": {"kind": "cluster", "uuid": "00000000-0000-0000-0000-000000000001"},
+      "spec": {"name": "pe"},
+      "status": {
+        "state": "COMPLETE",
+        "name": "pe",
+        "resources": {"config": {"service_list": ["AOS"], "build": {"version": "6.5.2"}}}
+      }
+    },
+    {
+      "metadata": {"kind": "cluster", "uuid": "00000000-0000-0000-0000-000000000002"},
+      "spec": {"name": "pc"},
+      "status": {
+        "state": "COMPLETE",
+        "name": "pc",
+        "resources": {"config": {"service_list": ["PRISM_CENTRAL"], "build": {"version": "pc.2022.6", "build_type": "release"}}}
+      }
+    }
+  ]
+}`
+
+	// clusterListPC2024 is a cluster list as returned by Prism Central pc.2024.1, with fields unknown to the client
+	clusterListPC2024 = `{
+  "api_version": "3.1",
+  "metadata": {"kind": "cluster", "length": 1, "offset": 0, "total_matches": 1, "sort_attribute": "name"},
+  "entities": [
+    {
+      "metadata": {"kind": "cluster", "uuid": "00000000-0000-0000-0000-000000000002", "owner_reference": {"kind": "user", "uuid": "00000000-0000-0000-0000-000000000003"}},
+      "spec": {"name": "pc", "description": "Prism Central"},
+      "status": {
+        "state": "COMPLETE",
+        "name": "pc",
+        "resources": {
+          "config": {
+            "service_list": ["PRISM_CENTRAL"],
+            "build": {"version": "pc.2024.1", "full_version": "el8.5-release-fraser-2024.1", "build_metadata": {"channel": "stable"}},
+            "cluster_function_list": ["PRISM_CENTRAL"],
+            "deployment_type": {"scale_out": true, "nodes": 3}
+          },
+          "runtime_status_list": [],
+          "resource_summary": {"vm_count": 12}
+        }
+      }
+    }
+  ]
+}`
+)
+
+func newClusterListServer(t *testing.T, clusterList string) *nutanixClientV3.Client {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/nutanix/v3/clusters/list" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(clusterList))
+	}))
+	t.Cleanup(server.Close)
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	client, err := nutanixClientV3.NewV3Client(prismgoclient.Credentials{
+		URL:      server.Listener.Addr().String(),
+		Endpoint: host,
+		Port:     port,
+		Username: "user",
+		Password: "password",
+		Insecure: true,
+	})
+	require.NoError(t, err)
+	return client
+}
+
+func TestGetPrismCentralVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		clusterList     string
+		expectedVersion string
+		expectedErr     bool
+	}{
+		{
+			name:            "pc.2022.6",
+			clusterList:     clusterListPC2022,
+			expectedVersion: "pc.2022.6",
+		},
+		{
+			name:            "pc.2024.1 with unknown fields",
+			clusterList:     clusterListPC2024,
+			expectedVersion: "pc.2024.1",
+		},
+		{
+			name:        "Prism Central not listed",
+			clusterList: `{"metadata": {"kind": "cluster", "total_matches": 0}, "entities": []}`,
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClusterListServer(t, tt.clusterList)
+			version, err := GetPrismCentralVersion(context.Background(), client)
+			if tt.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedVersion, version)
+		})
+	}
+}
+
+func TestClientCachePrismCentralVersion(t *testing.T) {
+	cache := NewClientCache()
+	nutanixCluster := &infrav1.NutanixCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"}}
+	// The version is only recorded for cached clients
+	cache.SetPrismCentralVersion(nutanixCluster, "pc.2022.6")
+	assert.Empty(t, cache.PrismCentralVersion(nutanixCluster))
+
+	cache.Set(nutanixCluster, &credentialTypes.NutanixPrismEndpoint{Address: "prism.example.com", Port: 9440}, "", &nutanixClientV3.Client{})
+	cache.SetPrismCentralVersion(nutanixCluster, "pc.2022.6")
+	assert.Equal(t, "pc.2022.6", cache.PrismCentralVersion(nutanixCluster))
+	cache.Delete(nutanixCluster)
+	assert.Empty(t, cache.PrismCentralVersion(nutanixCluster))
+}
@@ -19,6 +19,7 @@ limitations under the License.
 package e2e
 
 import (
+	"context"
 	"encoding/base64"
 	"flag"
 	"fmt"
@@ -128,7 +129,7 @@ func initNutanixClient(e2eConfig clusterctl.E2EConfig) (*prismGoClientV3.Client,
 		trustBundle = string(decodedCert)
 	}
 	nch := nutanixClientHelper.NutanixClientHelper{}
-	nutanixClient, err := nch.GetClient(*creds, trustBundle)
+	nutanixClient, err := nch.GetClient(context.Background(), *creds, trustBundle)
 	if err != nil {
 		return nil, err
 	}